@@ -14,9 +14,11 @@ import (
 	"errors"
 	"fmt"
 	"internal/godebug"
+	"io"
 	"io/fs"
 	"maps"
 	"math"
+	"os"
 	"path"
 	"reflect"
 	"strconv"
@@ -197,6 +199,23 @@ type Header struct {
 	// other fields in Header take precedence over PAXRecords.
 	PAXRecords map[string]string
 
+	// SparseHoles represents a sequence of holes in a sparse file.
+	//
+	// A file is sparse if len(SparseHoles) > 0 or Typeflag is TypeGNUSparse.
+	// If TypeGNUSparse is set, then the format is GNU, otherwise
+	// the format is PAX (by using the GNU sparse format 1.0 PAX records).
+	//
+	// A sparse file consists of fragments of data, intermixed with holes
+	// (described by this field). A hole is semantically a block of NUL-bytes,
+	// but does not actually exist within the tar file.
+	// The holes must be sorted in ascending order,
+	// not overlap with each other, and not extend past the specified Size.
+	//
+	// This is set by Reader.Next when reading a sparse file.
+	// When writing, [Header.DetectSparseHoles] may be used to populate
+	// this field from a file on disk.
+	SparseHoles []SparseEntry
+
 	// Format specifies the format of the tar header.
 	//
 	// This is set by Reader.Next as a best-effort guess at the format.
@@ -209,10 +228,11 @@ type Header struct {
 	Format Format
 }
 
-// sparseEntry represents a Length-sized fragment at Offset in the file.
-type sparseEntry struct{ Offset, Length int64 }
+// SparseEntry represents a Length-sized fragment at Offset in the file.
+// See [Header.SparseHoles] for how it is used.
+type SparseEntry struct{ Offset, Length int64 }
 
-func (s sparseEntry) endOffset() int64 { return s.Offset + s.Length }
+func (s SparseEntry) endOffset() int64 { return s.Offset + s.Length }
 
 // A sparse file can be represented as either a sparseDatas or a sparseHoles.
 // As long as the total size is known, they are equivalent and one can be
@@ -233,11 +253,11 @@ func (s sparseEntry) endOffset() int64 { return s.Offset + s.Length }
 //
 // And the sparse map has the following entries:
 //
-//	var spd sparseDatas = []sparseEntry{
+//	var spd sparseDatas = []SparseEntry{
 //		{Offset: 2,  Length: 5},  // Data fragment for 2..6
 //		{Offset: 18, Length: 3},  // Data fragment for 18..20
 //	}
-//	var sph sparseHoles = []sparseEntry{
+//	var sph sparseHoles = []SparseEntry{
 //		{Offset: 0,  Length: 2},  // Hole fragment for 0..1
 //		{Offset: 7,  Length: 11}, // Hole fragment for 7..17
 //		{Offset: 21, Length: 4},  // Hole fragment for 21..24
@@ -247,19 +267,19 @@ func (s sparseEntry) endOffset() int64 { return s.Offset + s.Length }
 //
 //	var sparseFile = "\x00"*2 + "abcde" + "\x00"*11 + "fgh" + "\x00"*4
 type (
-	sparseDatas []sparseEntry
-	sparseHoles []sparseEntry
+	sparseDatas []SparseEntry
+	sparseHoles []SparseEntry
 )
 
 // validateSparseEntries reports whether sp is a valid sparse map.
 // It does not matter whether sp represents data fragments or hole fragments.
-func validateSparseEntries(sp []sparseEntry, size int64) bool {
+func validateSparseEntries(sp []SparseEntry, size int64) bool {
 	// Validate all sparse entries. These are the same checks as performed by
 	// the BSD tar utility.
 	if size < 0 {
 		return false
 	}
-	var pre sparseEntry
+	var pre SparseEntry
 	for _, cur := range sp {
 		switch {
 		case cur.Offset < 0 || cur.Length < 0:
@@ -283,7 +303,7 @@ func validateSparseEntries(sp []sparseEntry, size int64) bool {
 // Even though the Go tar Reader and the BSD tar utility can handle entries
 // with arbitrary offsets and lengths, the GNU tar utility can only handle
 // offsets and lengths that are multiples of blockSize.
-func alignSparseEntries(src []sparseEntry, size int64) []sparseEntry {
+func alignSparseEntries(src []SparseEntry, size int64) []SparseEntry {
 	dst := src[:0]
 	for _, s := range src {
 		pos, end := s.Offset, s.endOffset()
@@ -292,7 +312,7 @@ func alignSparseEntries(src []sparseEntry, size int64) []sparseEntry {
 			end -= blockPadding(-end) // Round-down to nearest blockSize
 		}
 		if pos < end {
-			dst = append(dst, sparseEntry{Offset: pos, Length: end - pos})
+			dst = append(dst, SparseEntry{Offset: pos, Length: end - pos})
 		}
 	}
 	return dst
@@ -306,9 +326,9 @@ func alignSparseEntries(src []sparseEntry, size int64) []sparseEntry {
 //   - adjacent fragments are coalesced together
 //   - only the last fragment may be empty
 //   - the endOffset of the last fragment is the total size
-func invertSparseEntries(src []sparseEntry, size int64) []sparseEntry {
+func invertSparseEntries(src []SparseEntry, size int64) []SparseEntry {
 	dst := src[:0]
-	var pre sparseEntry
+	var pre SparseEntry
 	for _, cur := range src {
 		if cur.Length == 0 {
 			continue // Skip empty fragments
@@ -490,28 +510,24 @@ func (h Header) allowedFormats() (format Format, paxHdrs map[string]string, err
 		}
 	}
 
-	// TODO(dsnet): Re-enable this when adding sparse support.
-	// See https://golang.org/issue/22735
-	/*
-		// Check sparse files.
-		if len(h.SparseHoles) > 0 || h.Typeflag == TypeGNUSparse {
-			if isHeaderOnlyType(h.Typeflag) {
-				return FormatUnknown, nil, headerError{"header-only type cannot be sparse"}
-			}
-			if !validateSparseEntries(h.SparseHoles, h.Size) {
-				return FormatUnknown, nil, headerError{"invalid sparse holes"}
-			}
-			if h.Typeflag == TypeGNUSparse {
-				whyOnlyGNU = "only GNU supports TypeGNUSparse"
-				format.mayOnlyBe(FormatGNU)
-			} else {
-				whyNoGNU = "GNU supports sparse files only with TypeGNUSparse"
-				format.mustNotBe(FormatGNU)
-			}
-			whyNoUSTAR = "USTAR does not support sparse files"
-			format.mustNotBe(FormatUSTAR)
+	// Check sparse files.
+	if len(h.SparseHoles) > 0 || h.Typeflag == TypeGNUSparse {
+		if isHeaderOnlyType(h.Typeflag) {
+			return FormatUnknown, nil, headerError{"header-only type cannot be sparse"}
+		}
+		if !validateSparseEntries(h.SparseHoles, h.Size) {
+			return FormatUnknown, nil, headerError{"invalid sparse holes"}
+		}
+		if h.Typeflag == TypeGNUSparse {
+			whyOnlyGNU = "only GNU supports TypeGNUSparse"
+			format.mayOnlyBe(FormatGNU)
+		} else {
+			whyNoGNU = "GNU supports sparse files only with TypeGNUSparse"
+			format.mustNotBe(FormatGNU)
 		}
-	*/
+		whyNoUSTAR = "USTAR does not support sparse files"
+		format.mustNotBe(FormatUSTAR)
+	}
 
 	// Check desired format.
 	if wantFormat := h.Format; wantFormat != FormatUnknown {
@@ -540,6 +556,35 @@ func (h *Header) FileInfo() fs.FileInfo {
 	return headerFileInfo{h}
 }
 
+// DetectSparseHoles searches for holes within f to populate SparseHoles
+// on supported operating systems and filesystems.
+// The file offset is cleared to zero.
+//
+// Holes are detected using SEEK_DATA and SEEK_HOLE, which are supported
+// on Linux. On other systems, or if the filesystem does not support
+// these operations, DetectSparseHoles leaves SparseHoles empty and
+// the file is archived as a regular file.
+//
+// When packing a sparse file, DetectSparseHoles should be called prior to
+// serializing the header to the archive with [Writer.WriteHeader],
+// and the file contents should be written with [Writer.ReadFrom]
+// so that the holes are skipped rather than read.
+func (h *Header) DetectSparseHoles(f *os.File) (err error) {
+	defer func() {
+		if _, serr := f.Seek(0, io.SeekStart); err == nil {
+			err = serr
+		}
+	}()
+
+	h.SparseHoles = nil
+	if sysSparseDetect != nil {
+		sph, err := sysSparseDetect(f)
+		h.SparseHoles = sph
+		return err
+	}
+	return nil
+}
+
 // headerFileInfo implements fs.FileInfo.
 type headerFileInfo struct {
 	h *Header
@@ -615,6 +660,9 @@ func (fi headerFileInfo) String() string {
 // sysStat, if non-nil, populates h from system-dependent fields of fi.
 var sysStat func(fi fs.FileInfo, h *Header, doNameLookups bool) error
 
+// sysSparseDetect, if non-nil, reports the holes within f.
+var sysSparseDetect func(f *os.File) (sparseHoles, error)
+
 const (
 	// Mode constants from the USTAR spec:
 	// See http://pubs.opengroup.org/onlinepubs/9699919799/utilities/pax.html#tag_20_92_13_06
@@ -0,0 +1,62 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+func init() {
+	sysSparseDetect = sparseDetectLinux
+}
+
+func sparseDetectLinux(f *os.File) (sph sparseHoles, err error) {
+	const seekData = 3 // SEEK_DATA from unistd.h
+	const seekHole = 4 // SEEK_HOLE from unistd.h
+
+	// Check for seekData/seekHole support.
+	// Different filesystems may differ in the exact errno that is returned
+	// when there is no support. Rather than special-casing every possible
+	// errno representing "not supported", just assume that a non-nil error
+	// means that seekData/seekHole is not supported.
+	if _, err := f.Seek(0, seekHole); err != nil {
+		return nil, nil
+	}
+
+	// Populate the SparseHoles.
+	var last, pos int64 = -1, 0
+	for {
+		// Get the location of the next hole section.
+		if pos, err = fseek(f, pos, seekHole); pos == last || err != nil {
+			return sph, err
+		}
+		offset := pos
+		last = pos
+
+		// Get the location of the next data section.
+		if pos, err = fseek(f, pos, seekData); pos == last || err != nil {
+			return sph, err
+		}
+		length := pos - offset
+		last = pos
+
+		if length > 0 {
+			sph = append(sph, SparseEntry{offset, length})
+		}
+	}
+}
+
+func fseek(f *os.File, pos int64, whence int) (int64, error) {
+	pos, err := f.Seek(pos, whence)
+	if errors.Is(err, syscall.ENXIO) {
+		// SEEK_DATA returns ENXIO when past the last data fragment,
+		// which makes determining the size of the last hole difficult.
+		pos, err = f.Seek(0, io.SeekEnd)
+	}
+	return pos, err
+}
@@ -102,88 +102,88 @@ func (f *testFile) Seek(pos int64, whence int) (int64, error) {
 
 func TestSparseEntries(t *testing.T) {
 	vectors := []struct {
-		in   []sparseEntry
+		in   []SparseEntry
 		size int64
 
 		wantValid    bool          // Result of validateSparseEntries
-		wantAligned  []sparseEntry // Result of alignSparseEntries
-		wantInverted []sparseEntry // Result of invertSparseEntries
+		wantAligned  []SparseEntry // Result of alignSparseEntries
+		wantInverted []SparseEntry // Result of invertSparseEntries
 	}{{
-		in: []sparseEntry{}, size: 0,
+		in: []SparseEntry{}, size: 0,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 0}},
+		wantInverted: []SparseEntry{{0, 0}},
 	}, {
-		in: []sparseEntry{}, size: 5000,
+		in: []SparseEntry{}, size: 5000,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{0, 5000}},
 	}, {
-		in: []sparseEntry{{0, 5000}}, size: 5000,
+		in: []SparseEntry{{0, 5000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 5000}},
-		wantInverted: []sparseEntry{{5000, 0}},
+		wantAligned:  []SparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{5000, 0}},
 	}, {
-		in: []sparseEntry{{1000, 4000}}, size: 5000,
+		in: []SparseEntry{{1000, 4000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{1024, 3976}},
-		wantInverted: []sparseEntry{{0, 1000}, {5000, 0}},
+		wantAligned:  []SparseEntry{{1024, 3976}},
+		wantInverted: []SparseEntry{{0, 1000}, {5000, 0}},
 	}, {
-		in: []sparseEntry{{0, 3000}}, size: 5000,
+		in: []SparseEntry{{0, 3000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 2560}},
-		wantInverted: []sparseEntry{{3000, 2000}},
+		wantAligned:  []SparseEntry{{0, 2560}},
+		wantInverted: []SparseEntry{{3000, 2000}},
 	}, {
-		in: []sparseEntry{{3000, 2000}}, size: 5000,
+		in: []SparseEntry{{3000, 2000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{3072, 1928}},
-		wantInverted: []sparseEntry{{0, 3000}, {5000, 0}},
+		wantAligned:  []SparseEntry{{3072, 1928}},
+		wantInverted: []SparseEntry{{0, 3000}, {5000, 0}},
 	}, {
-		in: []sparseEntry{{2000, 2000}}, size: 5000,
+		in: []SparseEntry{{2000, 2000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{2048, 1536}},
-		wantInverted: []sparseEntry{{0, 2000}, {4000, 1000}},
+		wantAligned:  []SparseEntry{{2048, 1536}},
+		wantInverted: []SparseEntry{{0, 2000}, {4000, 1000}},
 	}, {
-		in: []sparseEntry{{0, 2000}, {8000, 2000}}, size: 10000,
+		in: []SparseEntry{{0, 2000}, {8000, 2000}}, size: 10000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 1536}, {8192, 1808}},
-		wantInverted: []sparseEntry{{2000, 6000}, {10000, 0}},
+		wantAligned:  []SparseEntry{{0, 1536}, {8192, 1808}},
+		wantInverted: []SparseEntry{{2000, 6000}, {10000, 0}},
 	}, {
-		in: []sparseEntry{{0, 2000}, {2000, 2000}, {4000, 0}, {4000, 3000}, {7000, 1000}, {8000, 0}, {8000, 2000}}, size: 10000,
+		in: []SparseEntry{{0, 2000}, {2000, 2000}, {4000, 0}, {4000, 3000}, {7000, 1000}, {8000, 0}, {8000, 2000}}, size: 10000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 1536}, {2048, 1536}, {4096, 2560}, {7168, 512}, {8192, 1808}},
-		wantInverted: []sparseEntry{{10000, 0}},
+		wantAligned:  []SparseEntry{{0, 1536}, {2048, 1536}, {4096, 2560}, {7168, 512}, {8192, 1808}},
+		wantInverted: []SparseEntry{{10000, 0}},
 	}, {
-		in: []sparseEntry{{0, 0}, {1000, 0}, {2000, 0}, {3000, 0}, {4000, 0}, {5000, 0}}, size: 5000,
+		in: []SparseEntry{{0, 0}, {1000, 0}, {2000, 0}, {3000, 0}, {4000, 0}, {5000, 0}}, size: 5000,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{0, 5000}},
 	}, {
-		in: []sparseEntry{{1, 0}}, size: 0,
+		in: []SparseEntry{{1, 0}}, size: 0,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{-1, 0}}, size: 100,
+		in: []SparseEntry{{-1, 0}}, size: 100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{0, -1}}, size: 100,
+		in: []SparseEntry{{0, -1}}, size: 100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{0, 0}}, size: -100,
+		in: []SparseEntry{{0, 0}}, size: -100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{math.MaxInt64, 3}, {6, -5}}, size: 35,
+		in: []SparseEntry{{math.MaxInt64, 3}, {6, -5}}, size: 35,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{1, 3}, {6, -5}}, size: 35,
+		in: []SparseEntry{{1, 3}, {6, -5}}, size: 35,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{math.MaxInt64, math.MaxInt64}}, size: math.MaxInt64,
+		in: []SparseEntry{{math.MaxInt64, math.MaxInt64}}, size: math.MaxInt64,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{3, 3}}, size: 5,
+		in: []SparseEntry{{3, 3}}, size: 5,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{2, 0}, {1, 0}, {0, 0}}, size: 3,
+		in: []SparseEntry{{2, 0}, {1, 0}, {0, 0}}, size: 3,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{1, 3}, {2, 2}}, size: 10,
+		in: []SparseEntry{{1, 3}, {2, 2}}, size: 10,
 		wantValid: false,
 	}}
 
@@ -195,11 +195,11 @@ func TestSparseEntries(t *testing.T) {
 		if !v.wantValid {
 			continue
 		}
-		gotAligned := alignSparseEntries(append([]sparseEntry{}, v.in...), v.size)
+		gotAligned := alignSparseEntries(append([]SparseEntry{}, v.in...), v.size)
 		if !slices.Equal(gotAligned, v.wantAligned) {
 			t.Errorf("test %d, alignSparseEntries():\ngot  %v\nwant %v", i, gotAligned, v.wantAligned)
 		}
-		gotInverted := invertSparseEntries(append([]sparseEntry{}, v.in...), v.size)
+		gotInverted := invertSparseEntries(append([]SparseEntry{}, v.in...), v.size)
 		if !slices.Equal(gotInverted, v.wantInverted) {
 			t.Errorf("test %d, inverseSparseEntries():\ngot  %v\nwant %v", i, gotInverted, v.wantInverted)
 		}
@@ -329,6 +329,118 @@ func TestRoundTrip(t *testing.T) {
 	}
 }
 
+func TestSparseRoundTrip(t *testing.T) {
+	// Holes are aligned to blockSize so that they round-trip exactly.
+	const size = 64 * blockSize
+	data := make([]byte, size)
+	copy(data[blockSize:], "first data fragment")
+	copy(data[40*blockSize:], "second data fragment")
+	holes := []SparseEntry{
+		{Offset: 0, Length: blockSize},
+		{Offset: 2 * blockSize, Length: 38 * blockSize},
+		{Offset: 41 * blockSize, Length: 23 * blockSize},
+	}
+
+	var b bytes.Buffer
+	tw := NewWriter(&b)
+	hdr := &Header{
+		Name:        "sparse.db",
+		Size:        size,
+		ModTime:     time.Unix(0, 0),
+		Typeflag:    TypeReg,
+		SparseHoles: holes,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatalf("tw.WriteHeader: %v", err)
+	}
+	if _, err := tw.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatalf("tw.ReadFrom: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tw.Close: %v", err)
+	}
+	if b.Len() >= size {
+		t.Errorf("archive size = %d, want less than %d", b.Len(), size)
+	}
+
+	// Read it back.
+	tr := NewReader(&b)
+	rHdr, err := tr.Next()
+	if err != nil {
+		t.Fatalf("tr.Next: %v", err)
+	}
+	if rHdr.Name != hdr.Name || rHdr.Size != hdr.Size || rHdr.Format != FormatPAX {
+		t.Errorf("Header mismatch.\n got %+v\nwant %+v", rHdr, hdr)
+	}
+	if !slices.Equal(rHdr.SparseHoles, holes) {
+		t.Errorf("SparseHoles mismatch.\n got %v\nwant %v", rHdr.SparseHoles, holes)
+	}
+	rData, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !bytes.Equal(rData, data) {
+		t.Errorf("Data mismatch")
+	}
+}
+
+func TestDetectSparseHoles(t *testing.T) {
+	const size = 1 << 20
+	f, err := os.Create(filepath.Join(t.TempDir(), "sparse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt([]byte("hello"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("world"), size/2); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+
+	hdr := &Header{Name: "sparse", Size: size, Typeflag: TypeReg, ModTime: time.Unix(0, 0)}
+	if err := hdr.DetectSparseHoles(f); err != nil {
+		t.Fatalf("DetectSparseHoles: %v", err)
+	}
+	if len(hdr.SparseHoles) == 0 {
+		t.Logf("no holes detected; filesystem or OS may not support SEEK_HOLE")
+	}
+	if !validateSparseEntries(hdr.SparseHoles, size) {
+		t.Fatalf("invalid SparseHoles: %v", hdr.SparseHoles)
+	}
+
+	var b bytes.Buffer
+	tw := NewWriter(&b)
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatalf("tw.WriteHeader: %v", err)
+	}
+	if _, err := tw.ReadFrom(f); err != nil {
+		t.Fatalf("tw.ReadFrom: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tw.Close: %v", err)
+	}
+
+	want, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewReader(&b)
+	if _, err := tr.Next(); err != nil {
+		t.Fatalf("tr.Next: %v", err)
+	}
+	got, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Data mismatch")
+	}
+}
+
 type headerRoundTripTest struct {
 	h  *Header
 	fm fs.FileMode
@@ -735,6 +847,24 @@ func TestHeaderAllowedFormats(t *testing.T) {
 	}, {
 		header:  &Header{Name: "foo/", Typeflag: TypeSymlink},
 		formats: FormatUSTAR | FormatPAX | FormatGNU,
+	}, {
+		header:  &Header{Size: 10, SparseHoles: []SparseEntry{{Offset: 2, Length: 3}}},
+		formats: FormatPAX,
+	}, {
+		header:  &Header{Size: 10, SparseHoles: []SparseEntry{{Offset: 2, Length: 3}}, Format: FormatGNU},
+		formats: FormatUnknown,
+	}, {
+		header:  &Header{Typeflag: TypeGNUSparse, Size: 10, SparseHoles: []SparseEntry{{Offset: 2, Length: 3}}},
+		formats: FormatGNU,
+	}, {
+		header:  &Header{Typeflag: TypeGNUSparse, Size: 10, Format: FormatPAX},
+		formats: FormatUnknown,
+	}, {
+		header:  &Header{Size: 10, SparseHoles: []SparseEntry{{Offset: 8, Length: 3}}},
+		formats: FormatUnknown,
+	}, {
+		header:  &Header{Typeflag: TypeDir, SparseHoles: []SparseEntry{{Offset: 0, Length: 0}}},
+		formats: FormatUnknown,
 	}}
 
 	for i, v := range vectors {
@@ -59,6 +59,7 @@ var bootstrapDirs = []string{
 	"cmd/link/internal/...",
 	"compress/flate",
 	"compress/zlib",
+	"compress/zstd",
 	"container/heap",
 	"debug/dwarf",
 	"debug/elf",
@@ -92,7 +93,6 @@ var bootstrapDirs = []string{
 	"internal/types/errors",
 	"internal/unsafeheader",
 	"internal/xcoff",
-	"internal/zstd",
 	"math/bits",
 	"sort",
 }
@@ -38,6 +38,7 @@
 // Additional help topics:
 //
 //	buildconstraint build constraints
+//	buildjson       build -json encoding
 //	buildmode       build modes
 //	c               calling between Go and C
 //	cache           build and test caching
@@ -101,6 +102,10 @@
 // ends with a slash or backslash, then any resulting executables
 // will be written to that directory.
 //
+// The -json flag prints the build output in JSON form instead of text.
+// It is also accepted by 'go install'. See 'go help buildjson' for the
+// encoding details.
+//
 // The build flags are shared by the build, clean, get, install, list, run,
 // and test commands:
 //
@@ -1920,6 +1925,7 @@
 //	-json
 //	    Convert test output to JSON suitable for automated processing.
 //	    See 'go doc test2json' for the encoding details.
+//	    Also emits build output in JSON. See 'go help buildjson'.
 //
 //	-o file
 //	    Compile the test binary to the named file.
@@ -2137,6 +2143,56 @@
 // has a term for a Go major release, the language version used when compiling
 // the file will be the minimum version implied by the build constraint.
 //
+// # Build -json encoding
+//
+// The 'go build', 'go install', and 'go test' commands take a -json flag that
+// reports build output and failures as structured JSON output on standard
+// output.
+//
+// The JSON stream is a newline-separated sequence of BuildEvent objects
+// corresponding to the Go struct:
+//
+//	type BuildEvent struct {
+//		ImportPath string
+//		Action     string
+//		Output     string
+//	}
+//
+// The ImportPath field gives the package ID of the package being built.
+// This matches the Package.ImportPath field of go list -json and the
+// TestEvent.FailedBuild field of go test -json. Note that it does not
+// match TestEvent.Package.
+//
+// The Action field is one of the following:
+//
+//	build-start - The build of the package started
+//	build-output - The toolchain printed output
+//	build-fail - The build failed
+//	build-pass - The build of the package succeeded
+//
+// The Output field is set for Action == "build-output" and is a portion of
+// the build's output. The concatenation of the Output fields of all output
+// events is the exact output of the build. A single event may contain one
+// or more lines of output and there may be more than one output event for
+// a given ImportPath. This matches the definition of the TestEvent.Output
+// field produced by go test -json.
+//
+// For go test -json, this struct is designed so that parsers can distinguish
+// interleaved TestEvents and BuildEvents by inspecting the Action field.
+// Furthermore, as with TestEvent, parsers can simply concatenate the Output
+// fields of all events to reconstruct the text format output, as it would
+// have appeared from go build without the -json flag.
+//
+// Each package that is built has a build-start event followed by either a
+// build-fail or a build-pass event. Packages that are not built because a
+// dependency failed to build have no events. Output and failures that
+// occur while loading packages, before any package is built, are reported
+// with build-output and build-fail events but no build-start event.
+//
+// Note that there may also be non-JSON error text on standard error, even
+// with the -json flag. Typically, this indicates an early, serious error.
+// Consumers should be robust to this.
+//
 // # Build modes
 //
 // The 'go build' and 'go install' commands take a -buildmode argument which
@@ -78,6 +78,7 @@ var (
 	BuildCover         bool                    // -cover flag
 	BuildCoverMode     string                  // -covermode flag
 	BuildCoverPkg      []string                // -coverpkg flag
+	BuildJSON          bool                    // -json flag
 	BuildN             bool                    // -n flag
 	BuildO             string                  // -o flag
 	BuildP             = runtime.GOMAXPROCS(0) // -p flag
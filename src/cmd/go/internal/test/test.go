@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"internal/coverage"
+	"internal/godebug"
 	"internal/platform"
 	"io"
 	"io/fs"
@@ -158,6 +159,7 @@ In addition to the build flags, the flags handled by 'go test' itself are:
 	-json
 	    Convert test output to JSON suitable for automated processing.
 	    See 'go doc test2json' for the encoding details.
+	    Also emits build output in JSON. See 'go help buildjson'.
 
 	-o file
 	    Compile the test binary to the named file.
@@ -602,6 +604,8 @@ var (
 	testODir = false
 )
 
+var gotestjsonbuildtext = godebug.New("gotestjsonbuildtext")
+
 // testProfile returns the name of an arbitrary single-package profiling flag
 // that is set, if any.
 func testProfile() string {
@@ -678,6 +682,16 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 	pkgArgs, testArgs = testFlags(args)
 	modload.InitWorkfile() // The test command does custom flag processing; initialize workspaces after that.
 
+	if testJSON {
+		// Report build output and failures as JSON events too, unless the
+		// user asked for the old text build output.
+		if gotestjsonbuildtext.Value() == "1" {
+			gotestjsonbuildtext.IncNonDefault()
+		} else {
+			cfg.BuildJSON = true
+		}
+	}
+
 	if cfg.DebugTrace != "" {
 		var close func() error
 		var err error
@@ -994,11 +1008,19 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 			str := err.Error()
 			str = strings.TrimPrefix(str, "\n")
 			if p.ImportPath != "" {
-				base.Errorf("# %s\n%s", p.ImportPath, str)
+				load.DefaultPrinter().Errorf(p, "# %s\n%s", p.ImportPath, str)
+			} else {
+				load.DefaultPrinter().Errorf(p, "%s", str)
+			}
+			if testJSON {
+				json := test2json.NewConverter(lockedStdout{}, p.ImportPath, test2json.Timestamp)
+				fmt.Fprintf(json, "FAIL\t%s [setup failed]\n", p.ImportPath)
+				json.Exited(errors.New("setup failed"))
+				json.SetFailedBuild(p.Desc())
+				json.Close()
 			} else {
-				base.Errorf("%s", str)
+				fmt.Printf("FAIL\t%s [setup failed]\n", p.ImportPath)
 			}
-			fmt.Printf("FAIL\t%s [setup failed]\n", p.ImportPath)
 			continue
 		}
 		builds = append(builds, buildTest)
@@ -1374,8 +1396,9 @@ func (r *runTestActor) Act(b *work.Builder, ctx context.Context, a *work.Action)
 
 	var stdout io.Writer = os.Stdout
 	var err error
+	var json *test2json.Converter
 	if testJSON {
-		json := test2json.NewConverter(lockedStdout{}, a.Package.ImportPath, test2json.Timestamp)
+		json = test2json.NewConverter(lockedStdout{}, a.Package.ImportPath, test2json.Timestamp)
 		defer func() {
 			json.Exited(err)
 			json.Close()
@@ -1386,9 +1409,12 @@ func (r *runTestActor) Act(b *work.Builder, ctx context.Context, a *work.Action)
 	// Release next test to start (test2json.NewConverter writes the start event).
 	close(r.next)
 
-	if a.Failed {
+	if failed := a.Failed; failed != nil {
 		// We were unable to build the binary.
-		a.Failed = false
+		a.Failed = nil
+		if json != nil && failed.Package != nil {
+			json.SetFailedBuild(failed.Package.Desc())
+		}
 		fmt.Fprintf(stdout, "FAIL\t%s [build failed]\n", a.Package.ImportPath)
 		// Tell the JSON converter that this was a failure, not a passing run.
 		err = errors.New("build failed")
@@ -59,6 +59,10 @@ in the last two paragraphs. If the named output is an existing directory or
 ends with a slash or backslash, then any resulting executables
 will be written to that directory.
 
+The -json flag prints the build output in JSON form instead of text.
+It is also accepted by 'go install'. See 'go help buildjson' for the
+encoding details.
+
 The build flags are shared by the build, clean, get, install, list, run,
 and test commands:
 
@@ -240,6 +244,8 @@ func init() {
 	CmdInstall.Run = runInstall
 
 	CmdBuild.Flag.StringVar(&cfg.BuildO, "o", "", "output file or directory")
+	CmdBuild.Flag.BoolVar(&cfg.BuildJSON, "json", false, "")
+	CmdInstall.Flag.BoolVar(&cfg.BuildJSON, "json", false, "")
 
 	AddBuildFlags(CmdBuild, DefaultBuildFlags)
 	AddBuildFlags(CmdInstall, DefaultBuildFlags)
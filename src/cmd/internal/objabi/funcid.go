@@ -27,6 +27,7 @@ var funcIDs = map[string]abi.FuncID{
 	"morestack":          abi.FuncID_morestack,
 	"mstart":             abi.FuncID_mstart,
 	"panicwrap":          abi.FuncID_panicwrap,
+	"runCleanups":        abi.FuncID_runCleanups,
 	"runfinq":            abi.FuncID_runfinq,
 	"sigpanic":           abi.FuncID_sigpanic,
 	"systemstack_switch": abi.FuncID_systemstack_switch,
@@ -2337,8 +2337,8 @@ var blockedLinknames = map[string][]string{
 	"runtime.coroswitch": {"iter"},
 	"runtime.newcoro":    {"iter"},
 	// weak references
-	"internal/weak.runtime_registerWeakPointer": {"internal/weak"},
-	"internal/weak.runtime_makeStrongFromWeak":  {"internal/weak"},
+	"weak.runtime_registerWeakPointer": {"weak"},
+	"weak.runtime_makeStrongFromWeak":  {"weak"},
 }
 
 // check if a linkname reference to symbol s from pkg is allowed
@@ -5,6 +5,7 @@
 package zstd
 
 import (
+	"encoding/binary"
 	"math/bits"
 )
 
@@ -128,3 +129,34 @@ func (rbr *reverseBitReader) fetch(b uint8) bool {
 func (rbr *reverseBitReader) makeError(msg string) error {
 	return rbr.r.makeError(int(rbr.off), msg)
 }
+
+// bitWriter writes a bit stream that is read in reverse
+// by a reverseBitReader. Bits are added starting with the low
+// order bits of each byte.
+type bitWriter struct {
+	out   []byte // bytes written so far
+	bits  uint64 // pending bits
+	nbits uint8  // number of pending bits
+}
+
+// addBits adds the low n bits of v to the stream, with n <= 32.
+func (bw *bitWriter) addBits(v uint32, n uint8) {
+	bw.bits |= uint64(v&(1<<n-1)) << bw.nbits
+	bw.nbits += n
+	if bw.nbits >= 32 {
+		bw.out = binary.LittleEndian.AppendUint32(bw.out, uint32(bw.bits))
+		bw.bits >>= 32
+		bw.nbits -= 32
+	}
+}
+
+// close adds the 1 bit that marks the start of a reverse bit stream
+// and writes out any pending bits.
+func (bw *bitWriter) close() {
+	bw.addBits(1, 1)
+	for bw.nbits > 0 {
+		bw.out = append(bw.out, byte(bw.bits))
+		bw.bits >>= 8
+		bw.nbits -= min(bw.nbits, 8)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// dictMagic is the magic number at the start of a formatted dictionary.
+const dictMagic = 0xec30a437
+
+// dict is a parsed zstd dictionary. RFC 8878 section 5.
+type dict struct {
+	// The dictionary ID. This is 0 for a raw content dictionary.
+	id uint32
+
+	// The content that is prepended to the window of each frame.
+	content []byte
+
+	// Initial Huffman table for literals,
+	// or huffmanTableBits == 0 if none.
+	huffmanTable     []uint16
+	huffmanTableBits int
+
+	// Initial sequence decode FSE tables, or nil if none.
+	seqTables    [3][]fseBaselineEntry
+	seqTableBits [3]uint8
+
+	// Initial repeated offsets.
+	repeatedOffsets [3]uint32
+}
+
+// parseDict parses a dictionary. A dictionary that does not start
+// with the dictionary magic number is treated as raw content.
+func parseDict(data []byte) (*dict, error) {
+	d := &dict{
+		repeatedOffsets: [3]uint32{1, 4, 8},
+	}
+	if len(data) < 8 || binary.LittleEndian.Uint32(data) != dictMagic {
+		d.content = data
+		return d, nil
+	}
+
+	d.id = binary.LittleEndian.Uint32(data[4:])
+	if d.id == 0 {
+		return nil, errors.New("zstd: invalid dictionary: zero dictionary ID")
+	}
+
+	// Use a scratch Reader to parse the entropy tables,
+	// so that we can reuse the code that reads them from blocks.
+	var r Reader
+	err := r.parseDictTables(d, data)
+	if err != nil {
+		return nil, fmt.Errorf("zstd: invalid dictionary: %w", err)
+	}
+	return d, nil
+}
+
+// parseDictTables parses the entropy tables, repeated offsets,
+// and content of a formatted dictionary into d. RFC 8878 section 5.
+func (r *Reader) parseDictTables(d *dict, data []byte) error {
+	off := 8
+
+	d.huffmanTable = make([]uint16, 1<<maxHuffmanBits)
+	tableBits, off, err := r.readHuff(data, off, d.huffmanTable)
+	if err != nil {
+		return err
+	}
+	d.huffmanTableBits = tableBits
+
+	// The FSE tables are stored in the order offsets,
+	// match lengths, literal lengths.
+	for _, kind := range [...]seqCode{seqOffset, seqMatch, seqLiteral} {
+		info := &seqCodeInfo[kind]
+		if cap(r.fseScratch) < 1<<info.maxBits {
+			r.fseScratch = make([]fseEntry, 1<<info.maxBits)
+		}
+		r.fseScratch = r.fseScratch[:1<<info.maxBits]
+
+		tableBits, roff, err := r.readFSE(data, off, info.maxSym, info.maxBits, r.fseScratch)
+		if err != nil {
+			return err
+		}
+		table := make([]fseBaselineEntry, 1<<tableBits)
+		if err := info.toBaseline(r, roff, r.fseScratch[:1<<tableBits], table); err != nil {
+			return err
+		}
+		d.seqTables[kind] = table
+		d.seqTableBits[kind] = uint8(tableBits)
+		off = roff
+	}
+
+	if off+12 > len(data) {
+		return r.makeEOFError(off)
+	}
+	d.content = data[off+12:]
+	for i := range d.repeatedOffsets {
+		v := binary.LittleEndian.Uint32(data[off:])
+		if v == 0 || v > uint32(len(d.content)) {
+			return r.makeError(off, "invalid repeated offset")
+		}
+		d.repeatedOffsets[i] = v
+		off += 4
+	}
+
+	return nil
+}
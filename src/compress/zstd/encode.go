@@ -0,0 +1,345 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// maxBlockSize is the maximum size of a block. RFC 3.1.1.2.3.
+const maxBlockSize = 128 << 10
+
+// predefinedDistributions are the predefined distributions
+// indexed by seqCode.
+var predefinedDistributions = [3][]int16{
+	seqLiteral: literalPredefinedDistribution,
+	seqOffset:  offsetPredefinedDistribution,
+	seqMatch:   matchPredefinedDistribution,
+}
+
+// blockEncoder compresses blocks.
+// Each block is compressed independently of the blocks before it,
+// other than referring to their data, so that blocks can be
+// compressed concurrently.
+type blockEncoder struct {
+	m    *matcher
+	huff huffEncoder
+
+	// Per seqCode scratch space for encoding sequences.
+	codes  [3][]uint8
+	counts [3][53]uint32
+	norm   [3][53]int16
+	fse    [3]fseEncoder
+	desc   []byte
+}
+
+func newBlockEncoder(level *encoderLevel) *blockEncoder {
+	return &blockEncoder{m: newMatcher(level)}
+}
+
+// appendBlock appends the block hist[start:], including the block
+// header, to dst. The data before start may be used for matches.
+// RFC 3.1.1.2.
+func (e *blockEncoder) appendBlock(dst, hist []byte, start int, last bool) []byte {
+	src := hist[start:]
+	header := uint32(len(src)) << 3
+	if last {
+		header |= 1
+	}
+
+	if len(src) > 1 && allSame(src) {
+		// RLE_Block.
+		header |= 1 << 1
+		return append(dst, byte(header), byte(header>>8), byte(header>>16), src[0])
+	}
+
+	if len(src) >= 16 {
+		pos := len(dst)
+		dst = append(dst, 0, 0, 0)
+		e.m.parse(hist, start)
+		dst = e.appendLiterals(dst, e.m.lits)
+		dst = e.appendSequences(dst, e.m.seqs)
+		if size := len(dst) - pos - 3; size < len(src) {
+			// Compressed_Block.
+			header = header&1 | 2<<1 | uint32(size)<<3
+			dst[pos] = byte(header)
+			dst[pos+1] = byte(header >> 8)
+			dst[pos+2] = byte(header >> 16)
+			return dst
+		}
+		dst = dst[:pos]
+	}
+
+	// Raw_Block.
+	dst = append(dst, byte(header), byte(header>>8), byte(header>>16))
+	return append(dst, src...)
+}
+
+// allSame reports whether all bytes of b are the same.
+func allSame(b []byte) bool {
+	for _, c := range b[1:] {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendLiteralsHeader appends the header of a raw or RLE
+// literals section of size n. RFC 3.1.1.3.1.1.
+func appendLiteralsHeader(dst []byte, typ byte, n int) []byte {
+	switch {
+	case n < 1<<5:
+		return append(dst, typ|byte(n)<<3)
+	case n < 1<<12:
+		return append(dst, typ|1<<2|byte(n)<<4, byte(n>>4))
+	default:
+		return append(dst, typ|3<<2|byte(n)<<4, byte(n>>4), byte(n>>12))
+	}
+}
+
+// appendLiterals appends the literals section. RFC 3.1.1.3.1.
+func (e *blockEncoder) appendLiterals(dst, lits []byte) []byte {
+	n := len(lits)
+	if n == 0 {
+		return appendLiteralsHeader(dst, 0, 0)
+	}
+
+	var counts [256]uint32
+	for _, c := range lits {
+		counts[c]++
+	}
+	if counts[lits[0]] == uint32(n) {
+		// RLE_Literals_Block.
+		return append(appendLiteralsHeader(dst, 1, n), lits[0])
+	}
+
+	if n >= 32 {
+		if out, ok := e.appendHuffLiterals(dst, lits, &counts); ok {
+			return out
+		}
+	}
+
+	// Raw_Literals_Block.
+	return append(appendLiteralsHeader(dst, 0, n), lits...)
+}
+
+// appendHuffLiterals appends a Huffman compressed literals section.
+// It reports false if that would not be smaller than the literals.
+func (e *blockEncoder) appendHuffLiterals(dst, lits []byte, counts *[256]uint32) ([]byte, bool) {
+	n := len(lits)
+	var sizeFormat, hdrSize, sizeBits int
+	switch {
+	case n < 256:
+		sizeFormat, hdrSize, sizeBits = 0, 3, 10
+	case n < 1<<10:
+		sizeFormat, hdrSize, sizeBits = 1, 3, 10
+	case n < 1<<14:
+		sizeFormat, hdrSize, sizeBits = 2, 4, 14
+	default:
+		sizeFormat, hdrSize, sizeBits = 3, 5, 18
+	}
+
+	e.huff.build(counts)
+
+	start := len(dst)
+	dst = append(dst, make([]byte, hdrSize)...)
+	dst, ok := e.huff.appendTable(dst)
+	if !ok {
+		return dst[:start], false
+	}
+
+	if sizeFormat == 0 {
+		dst = e.huff.appendStream(dst, lits)
+	} else {
+		// Four streams with a jump table. RFC 3.1.1.3.1.6.
+		jump := len(dst)
+		dst = append(dst, 0, 0, 0, 0, 0, 0)
+		segment := (n + 3) / 4
+		for i := range 4 {
+			streamStart := len(dst)
+			dst = e.huff.appendStream(dst, lits[min(i*segment, n):min((i+1)*segment, n)])
+			if i < 3 {
+				binary.LittleEndian.PutUint16(dst[jump+2*i:], uint16(len(dst)-streamStart))
+			}
+		}
+	}
+
+	compressedSize := len(dst) - start - hdrSize
+	if len(dst)-start >= n+len(appendLiteralsHeader(nil, 0, n)) {
+		return dst[:start], false
+	}
+
+	// Compressed_Literals_Block.
+	hdr := uint64(2) | uint64(sizeFormat)<<2 | uint64(n)<<4 | uint64(compressedSize)<<(4+sizeBits)
+	for i := range hdrSize {
+		dst[start+i] = byte(hdr >> (8 * i))
+	}
+	return dst, true
+}
+
+// llCode returns the literal length code for litLen.
+// RFC 3.1.1.3.2.1.1.
+func llCode(litLen uint32) uint8 {
+	if litLen < literalLengthOffset {
+		return uint8(litLen)
+	}
+	if litLen >= 64 {
+		return uint8(bits.Len32(litLen) + 18)
+	}
+	code := 0
+	for code+1 < len(literalLengthBase) && literalLengthBase[code+1]&0xffffff <= litLen {
+		code++
+	}
+	return uint8(code + literalLengthOffset)
+}
+
+// mlCode returns the match length code for matchLen.
+// RFC 3.1.1.3.2.1.1.
+func mlCode(matchLen uint32) uint8 {
+	if matchLen-3 < matchLengthOffset {
+		return uint8(matchLen - 3)
+	}
+	if matchLen-3 >= 128 {
+		return uint8(bits.Len32(matchLen-3) + 35)
+	}
+	code := 0
+	for code+1 < len(matchLengthBase) && matchLengthBase[code+1]&0xffffff <= matchLen {
+		code++
+	}
+	return uint8(code + matchLengthOffset)
+}
+
+// codeExtra returns the extra bits and number of extra bits to write
+// for value v with the sequence code code of kind.
+func codeExtra(kind seqCode, code uint8, v uint32) (uint32, uint8) {
+	switch kind {
+	case seqLiteral:
+		if code < literalLengthOffset {
+			return 0, 0
+		}
+		base := literalLengthBase[code-literalLengthOffset]
+		return v - base&0xffffff, uint8(base >> 24)
+	case seqMatch:
+		if code < matchLengthOffset {
+			return 0, 0
+		}
+		base := matchLengthBase[code-matchLengthOffset]
+		return v - base&0xffffff, uint8(base >> 24)
+	default:
+		return v - 1<<code, code
+	}
+}
+
+// appendSequences appends the sequences section. RFC 3.1.1.3.2.
+func (e *blockEncoder) appendSequences(dst []byte, seqs []seq) []byte {
+	n := len(seqs)
+	switch {
+	case n < 128:
+		dst = append(dst, byte(n))
+	case n < 0x7f00:
+		dst = append(dst, byte(n>>8)+128, byte(n))
+	default:
+		dst = append(dst, 255, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	if n == 0 {
+		return dst
+	}
+
+	for kind := range e.codes {
+		e.codes[kind] = e.codes[kind][:0]
+		clear(e.counts[kind][:])
+	}
+	for _, s := range seqs {
+		ll := llCode(s.litLen)
+		of := uint8(bits.Len32(s.offValue) - 1)
+		ml := mlCode(s.matchLen)
+		e.codes[seqLiteral] = append(e.codes[seqLiteral], ll)
+		e.codes[seqOffset] = append(e.codes[seqOffset], of)
+		e.codes[seqMatch] = append(e.codes[seqMatch], ml)
+		e.counts[seqLiteral][ll]++
+		e.counts[seqOffset][of]++
+		e.counts[seqMatch][ml]++
+	}
+
+	modePos := len(dst)
+	dst = append(dst, 0)
+	var encs [3]*fseEncoder
+	for _, kind := range [...]seqCode{seqLiteral, seqOffset, seqMatch} {
+		var mode byte
+		dst, mode, encs[kind] = e.appendTable(dst, kind, n)
+		dst[modePos] |= mode << (6 - 2*kind)
+	}
+
+	// Write the bit stream in the reverse of the order in which
+	// execSeqs reads it. RFC 3.1.1.3.2.2.
+	bw := bitWriter{out: dst}
+	var states [3]fseState
+	addExtra := func(i int) {
+		s := &seqs[i]
+		bw.addBits(codeExtra(seqLiteral, e.codes[seqLiteral][i], s.litLen))
+		bw.addBits(codeExtra(seqMatch, e.codes[seqMatch][i], s.matchLen))
+		bw.addBits(codeExtra(seqOffset, e.codes[seqOffset][i], s.offValue))
+	}
+	for kind, enc := range encs {
+		if enc != nil {
+			states[kind].init(enc, e.codes[kind][n-1])
+		}
+	}
+	addExtra(n - 1)
+	for i := n - 2; i >= 0; i-- {
+		for _, kind := range [...]seqCode{seqOffset, seqMatch, seqLiteral} {
+			if encs[kind] != nil {
+				states[kind].encode(&bw, e.codes[kind][i])
+			}
+		}
+		addExtra(i)
+	}
+	for _, kind := range [...]seqCode{seqMatch, seqOffset, seqLiteral} {
+		if encs[kind] != nil {
+			states[kind].flush(&bw)
+		}
+	}
+	bw.close()
+	return bw.out
+}
+
+// appendTable chooses how to encode the sequence codes of kind,
+// and appends the table description. It returns the
+// Compression_Mode and the FSE encoder, or nil for RLE_Mode.
+// RFC 3.1.1.3.2.1.
+func (e *blockEncoder) appendTable(dst []byte, kind seqCode, total int) ([]byte, byte, *fseEncoder) {
+	counts := e.counts[kind][:]
+	maxSym, distinct := 0, 0
+	for i, c := range counts {
+		if c > 0 {
+			maxSym = i
+			distinct++
+		}
+	}
+	if distinct == 1 {
+		// RLE_Mode.
+		return append(dst, byte(maxSym)), 1, nil
+	}
+
+	info := &seqCodeInfo[kind]
+	predefCost := fseCost(counts, predefinedDistributions[kind], uint8(info.predefTableBits))
+
+	tableBits := fseTableBits(total, maxSym, info.maxBits)
+	norm := e.norm[kind][:maxSym+1]
+	normalizeCounts(norm, counts[:maxSym+1], total, tableBits)
+	e.desc = appendNorm(e.desc[:0], norm, tableBits)
+	cost := fseCost(counts, norm, tableBits) + float64(8*len(e.desc))
+
+	if predefCost <= cost {
+		// Predefined_Mode.
+		return dst, 0, &predefinedEncoders()[kind]
+	}
+
+	// FSE_Compressed_Mode.
+	e.fse[kind].build(norm, tableBits)
+	return append(dst, e.desc...), 2, &e.fse[kind]
+}
@@ -5,34 +5,11 @@
 package zstd
 
 import (
+	"math/rand/v2"
 	"slices"
 	"testing"
 )
 
-// literalPredefinedDistribution is the predefined distribution table
-// for literal lengths. RFC 3.1.1.3.2.2.1.
-var literalPredefinedDistribution = []int16{
-	4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
-	-1, -1, -1, -1,
-}
-
-// offsetPredefinedDistribution is the predefined distribution table
-// for offsets. RFC 3.1.1.3.2.2.3.
-var offsetPredefinedDistribution = []int16{
-	1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
-	1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
-}
-
-// matchPredefinedDistribution is the predefined distribution table
-// for match lengths. RFC 3.1.1.3.2.2.2.
-var matchPredefinedDistribution = []int16{
-	1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
-	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
-	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
-	-1, -1, -1, -1, -1,
-}
-
 // TestPredefinedTables verifies that we can generate the predefined
 // literal/offset/match tables from the input data in RFC 8878.
 // This serves as a test of the predefined tables, and also of buildFSE
@@ -87,3 +64,39 @@ func TestPredefinedTables(t *testing.T) {
 		})
 	}
 }
+
+// TestNormRoundTrip verifies that readFSE reads the tables
+// written by appendNorm.
+func TestNormRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 1000; i++ {
+		counts := make([]uint32, 2+rng.IntN(52))
+		total := 0
+		for j := range counts {
+			if rng.IntN(3) > 0 {
+				counts[j] = uint32(rng.IntN(1000))
+			}
+			total += int(counts[j])
+		}
+		// The last symbol must be present.
+		counts[len(counts)-1] = 1 + uint32(rng.IntN(10))
+		total += int(counts[len(counts)-1])
+
+		tableBits := fseTableBits(total, len(counts)-1, 9)
+		norm := make([]int16, len(counts))
+		normalizeCounts(norm, counts, total, tableBits)
+		data := appendNorm(nil, norm, tableBits)
+		n := len(data)
+		data = append(data, make([]byte, 8)...)
+
+		var r Reader
+		table := make([]fseEntry, 1<<9)
+		gotBits, off, err := r.readFSE(data, 0, 52, 9, table)
+		if err != nil {
+			t.Fatalf("norm %v: %v", norm, err)
+		}
+		if gotBits != int(tableBits) || off != n {
+			t.Fatalf("norm %v: got table bits %d, offset %d; want %d, %d", norm, gotBits, off, tableBits, n)
+		}
+	}
+}
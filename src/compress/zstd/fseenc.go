@@ -0,0 +1,309 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// literalPredefinedDistribution is the predefined distribution table
+// for literal lengths. RFC 3.1.1.3.2.2.1.
+var literalPredefinedDistribution = []int16{
+	4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+	2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+	-1, -1, -1, -1,
+}
+
+// offsetPredefinedDistribution is the predefined distribution table
+// for offsets. RFC 3.1.1.3.2.2.3.
+var offsetPredefinedDistribution = []int16{
+	1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+}
+
+// matchPredefinedDistribution is the predefined distribution table
+// for match lengths. RFC 3.1.1.3.2.2.2.
+var matchPredefinedDistribution = []int16{
+	1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+	-1, -1, -1, -1, -1,
+}
+
+// fseSymbolTransform describes how to encode one symbol
+// using an FSE table.
+type fseSymbolTransform struct {
+	deltaNbBits    uint32 // used to compute the number of bits to write
+	deltaFindState int32  // offset of the symbol's states in stateTable
+}
+
+// fseEncoder is an FSE table used for encoding. It is the inverse
+// of the decoding table built by buildFSE. RFC 4.1.
+type fseEncoder struct {
+	tableBits  uint8
+	stateTable []uint16
+	symbols    []fseSymbolTransform
+}
+
+// build builds the encoding table from a list of probabilities,
+// using the same symbol spread as buildFSE.
+func (e *fseEncoder) build(norm []int16, tableBits uint8) {
+	tableSize := 1 << tableBits
+	highThreshold := tableSize - 1
+
+	var symbolTable [1 << 9]uint8
+	var cumul [257]int
+	for i, n := range norm {
+		if n == -1 {
+			cumul[i+1] = cumul[i] + 1
+			symbolTable[highThreshold] = uint8(i)
+			highThreshold--
+		} else {
+			cumul[i+1] = cumul[i] + int(n)
+		}
+	}
+
+	pos := 0
+	step := (tableSize >> 1) + (tableSize >> 3) + 3
+	mask := tableSize - 1
+	for i, n := range norm {
+		for j := 0; j < int(n); j++ {
+			symbolTable[pos] = uint8(i)
+			pos = (pos + step) & mask
+			for pos > highThreshold {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+
+	e.tableBits = tableBits
+	if cap(e.stateTable) < tableSize {
+		e.stateTable = make([]uint16, tableSize)
+	}
+	e.stateTable = e.stateTable[:tableSize]
+	for i, sym := range symbolTable[:tableSize] {
+		e.stateTable[cumul[sym]] = uint16(tableSize + i)
+		cumul[sym]++
+	}
+
+	if cap(e.symbols) < len(norm) {
+		e.symbols = make([]fseSymbolTransform, len(norm))
+	}
+	e.symbols = e.symbols[:len(norm)]
+	total := int32(0)
+	for i, n := range norm {
+		switch n {
+		case 0:
+			e.symbols[i] = fseSymbolTransform{}
+		case -1, 1:
+			e.symbols[i] = fseSymbolTransform{
+				deltaNbBits:    uint32(tableBits)<<16 - uint32(tableSize),
+				deltaFindState: total - 1,
+			}
+			total++
+		default:
+			maxBitsOut := uint32(tableBits) - uint32(bits.Len16(uint16(n-1))-1)
+			minStatePlus := uint32(n) << maxBitsOut
+			e.symbols[i] = fseSymbolTransform{
+				deltaNbBits:    maxBitsOut<<16 - minStatePlus,
+				deltaFindState: total - int32(n),
+			}
+			total += int32(n)
+		}
+	}
+}
+
+// fseState is the state of an FSE encoder.
+type fseState struct {
+	e     *fseEncoder
+	state uint32
+}
+
+// init sets the state to the initial state for the first symbol
+// encoded, which is the last symbol decoded. No bits are written.
+func (s *fseState) init(e *fseEncoder, sym uint8) {
+	st := &e.symbols[sym]
+	nbBitsOut := (st.deltaNbBits + 1<<15) >> 16
+	value := nbBitsOut<<16 - st.deltaNbBits
+	s.e = e
+	s.state = uint32(e.stateTable[int32(value>>nbBitsOut)+st.deltaFindState])
+}
+
+// encode writes the bits that the decoder reads to move
+// from the state for sym to the current state.
+func (s *fseState) encode(bw *bitWriter, sym uint8) {
+	st := &s.e.symbols[sym]
+	nbBitsOut := uint8((s.state + st.deltaNbBits) >> 16)
+	bw.addBits(s.state, nbBitsOut)
+	s.state = uint32(s.e.stateTable[int32(s.state>>nbBitsOut)+st.deltaFindState])
+}
+
+// flush writes the final state, which the decoder reads first.
+func (s *fseState) flush(bw *bitWriter) {
+	bw.addBits(s.state, s.e.tableBits)
+}
+
+// fseTableBits returns the accuracy log to use for an FSE table
+// describing total symbols with a largest symbol of maxSym.
+func fseTableBits(total, maxSym, maxBits int) uint8 {
+	tableBits := maxBits
+	if b := bits.Len(uint(total-1)) - 2; b < tableBits {
+		tableBits = b
+	}
+	if b := min(bits.Len(uint(total)), bits.Len(uint(maxSym))+1); b > tableBits {
+		tableBits = b
+	}
+	return uint8(max(5, min(tableBits, maxBits)))
+}
+
+// normalizeCounts sets norm to a list of probabilities for the symbol
+// counts in counts, summing to 1<<tableBits. Every symbol with a
+// nonzero count has a nonzero probability. Symbols that are very
+// rare get the special probability -1, meaning "less than 1".
+func normalizeCounts(norm []int16, counts []uint32, total int, tableBits uint8) {
+	tableSize := 1 << tableBits
+	lowThreshold := uint32(total >> tableBits)
+	remaining := tableSize
+	for i, c := range counts {
+		switch {
+		case c == 0:
+			norm[i] = 0
+		case c <= lowThreshold:
+			norm[i] = -1
+			remaining--
+		default:
+			p := int((uint64(c)<<tableBits + uint64(total)/2) / uint64(total))
+			p = max(p, 1)
+			norm[i] = int16(p)
+			remaining -= p
+		}
+	}
+
+	// Rounding may leave us with too many or too few slots.
+	// Adjust the largest probabilities, which are affected
+	// the least by a change of 1.
+	for remaining != 0 {
+		largest := 0
+		for i, n := range norm {
+			if n > norm[largest] {
+				largest = i
+			}
+		}
+		if remaining > 0 {
+			norm[largest] += int16(remaining)
+			remaining = 0
+		} else {
+			if norm[largest] <= 1 {
+				panic("zstd: FSE normalization failed")
+			}
+			norm[largest]--
+			remaining++
+		}
+	}
+}
+
+// appendNorm appends the description of an FSE table to dst.
+// This is the inverse of readFSE. RFC 4.1.1.
+func appendNorm(dst []byte, norm []int16, tableBits uint8) []byte {
+	var bitStream uint32
+	bitCount := uint(0)
+	flush := func() {
+		for bitCount >= 8 {
+			dst = append(dst, byte(bitStream))
+			bitStream >>= 8
+			bitCount -= 8
+		}
+	}
+
+	bitStream = uint32(tableBits - 5)
+	bitCount = 4
+
+	remaining := (1 << tableBits) + 1
+	threshold := 1 << tableBits
+	nbBits := uint(tableBits) + 1
+	prev0 := false
+	sym := 0
+	for remaining > 1 && sym < len(norm) {
+		if prev0 {
+			start := sym
+			for sym < len(norm) && norm[sym] == 0 {
+				sym++
+			}
+			for sym >= start+24 {
+				start += 24
+				bitStream |= 0xffff << bitCount
+				bitCount += 16
+				flush()
+			}
+			for sym >= start+3 {
+				start += 3
+				bitStream |= 3 << bitCount
+				bitCount += 2
+			}
+			bitStream |= uint32(sym-start) << bitCount
+			bitCount += 2
+			flush()
+		}
+
+		count := int(norm[sym])
+		sym++
+		max := (2*threshold - 1) - remaining
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+		count++
+		if count >= threshold {
+			count += max
+		}
+		bitStream |= uint32(count) << bitCount
+		bitCount += nbBits
+		if count < max {
+			bitCount--
+		}
+		prev0 = count == 1
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+		flush()
+	}
+
+	if bitCount > 0 {
+		dst = append(dst, byte(bitStream))
+	}
+	return dst
+}
+
+// fseCost returns the approximate number of bits required
+// to encode symbols with the given counts using norm.
+func fseCost(counts []uint32, norm []int16, tableBits uint8) float64 {
+	cost := 0.0
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		if i >= len(norm) || norm[i] == 0 {
+			return math.Inf(1)
+		}
+		p := float64(max(norm[i], 1))
+		cost += float64(c) * (float64(tableBits) - math.Log2(p))
+	}
+	return cost
+}
+
+// predefinedEncoders returns the FSE encoders for the predefined
+// literal length, offset, and match length distributions,
+// indexed by seqCode.
+var predefinedEncoders = sync.OnceValue(func() *[3]fseEncoder {
+	var encs [3]fseEncoder
+	encs[seqLiteral].build(literalPredefinedDistribution, 6)
+	encs[seqOffset].build(offsetPredefinedDistribution, 5)
+	encs[seqMatch].build(matchPredefinedDistribution, 6)
+	return &encs
+})
@@ -138,3 +138,22 @@ func FuzzReverse(f *testing.F) {
 		}
 	})
 }
+
+// Fuzz test to check that we can decompress what we compress.
+func FuzzWriter(f *testing.F) {
+	for _, test := range tests {
+		f.Add([]byte(test.uncompressed), uint8(DefaultCompression), false)
+	}
+	f.Add(bytes.Repeat([]byte("abcd"), 100000), uint8(BestCompression), true)
+
+	f.Fuzz(func(t *testing.T, b []byte, level uint8, checksum bool) {
+		opts := &WriterOptions{
+			Level:    int(level%BestCompression) + 1,
+			Checksum: checksum,
+		}
+		compressed := compress(t, b, opts)
+		if got := decompress(t, compressed, nil); !bytes.Equal(got, b) {
+			showDiffs(t, got, b)
+		}
+	})
+}
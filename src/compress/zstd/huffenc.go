@@ -0,0 +1,286 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"slices"
+)
+
+// huffEncoder is a Huffman code used to compress literals. RFC 4.2.
+type huffEncoder struct {
+	tableBits uint8
+	maxSym    int // the largest symbol with a code
+	codes     [256]uint16
+	nbits     [256]uint8
+
+	// Scratch space.
+	syms    []uint8
+	weights []uint32
+	parents []int32
+	reader  Reader // for verifying compressed weights
+	table   []uint16
+	wbuf    []byte
+}
+
+// build builds a Huffman code for symbols with the given counts.
+// There must be at least two different symbols.
+func (h *huffEncoder) build(counts *[256]uint32) {
+	h.syms = h.syms[:0]
+	h.maxSym = 0
+	for i, c := range counts {
+		h.nbits[i] = 0
+		if c > 0 {
+			h.syms = append(h.syms, uint8(i))
+			h.maxSym = i
+		}
+	}
+
+	// Sort by increasing count, so that the most frequent symbols
+	// are last and get the shortest codes.
+	slices.SortStableFunc(h.syms, func(a, b uint8) int {
+		ca, cb := counts[a], counts[b]
+		if ca < cb {
+			return -1
+		} else if ca > cb {
+			return +1
+		}
+		return 0
+	})
+
+	// Build the Huffman tree using two queues: leaves in sorted
+	// order, and internal nodes in order of creation, which is
+	// also sorted. Node i < n is a leaf, the rest are internal.
+	n := len(h.syms)
+	h.weights = h.weights[:0]
+	for _, s := range h.syms {
+		h.weights = append(h.weights, counts[s])
+	}
+	h.parents = append(h.parents[:0], make([]int32, 2*n-1)...)
+	leaf, inner := 0, n
+	pick := func() int {
+		if leaf < n && (inner >= len(h.weights) || h.weights[leaf] <= h.weights[inner]) {
+			leaf++
+			return leaf - 1
+		}
+		inner++
+		return inner - 1
+	}
+	for len(h.weights) < 2*n-1 {
+		a, b := pick(), pick()
+		h.parents[a] = int32(len(h.weights))
+		h.parents[b] = int32(len(h.weights))
+		h.weights = append(h.weights, h.weights[a]+h.weights[b])
+	}
+
+	// Compute depths, reusing weights. The root is the last node.
+	depths := h.weights
+	depths[2*n-2] = 0
+	for i := 2*n - 3; i >= 0; i-- {
+		depths[i] = depths[h.parents[i]] + 1
+	}
+	for i, s := range h.syms {
+		h.nbits[s] = uint8(min(depths[i], maxHuffmanBits))
+	}
+
+	// Codes longer than maxHuffmanBits were shortened above,
+	// which may leave the code over-subscribed. Kraft's sum is
+	// measured in units of 1<<-maxHuffmanBits.
+	const limit = maxHuffmanBits
+	kraft := 0
+	for _, s := range h.syms {
+		kraft += 1 << (limit - h.nbits[s])
+	}
+
+	// Lengthen the codes of the least frequent symbols until
+	// the code is no longer over-subscribed.
+	for kraft > 1<<limit {
+		for _, s := range h.syms {
+			if h.nbits[s] < limit {
+				h.nbits[s]++
+				kraft -= 1 << (limit - h.nbits[s])
+				break
+			}
+		}
+	}
+
+	// Shorten the codes of the most frequent symbols until the
+	// code is complete, as the weights must sum to a power of two.
+	for kraft < 1<<limit {
+		for i := n - 1; i >= 0; i-- {
+			s := h.syms[i]
+			if h.nbits[s] > 1 && kraft+1<<(limit-h.nbits[s]) <= 1<<limit {
+				kraft += 1 << (limit - h.nbits[s])
+				h.nbits[s]--
+				break
+			}
+		}
+	}
+
+	h.tableBits = 0
+	for _, s := range h.syms {
+		h.tableBits = max(h.tableBits, h.nbits[s])
+	}
+
+	// Assign codes as readHuff does: symbols with the longest
+	// codes come first, in symbol order.
+	var rankStart [maxHuffmanBits + 2]uint32
+	for s := 0; s <= h.maxSym; s++ {
+		if h.nbits[s] > 0 {
+			rankStart[h.weight(s)] += 1 << (h.weight(s) - 1)
+		}
+	}
+	next := uint32(0)
+	for w := 1; w <= int(h.tableBits); w++ {
+		cur := next
+		next += rankStart[w]
+		rankStart[w] = cur
+	}
+	for s := 0; s <= h.maxSym; s++ {
+		if h.nbits[s] > 0 {
+			w := h.weight(s)
+			h.codes[s] = uint16(rankStart[w] >> (w - 1))
+			rankStart[w] += 1 << (w - 1)
+		}
+	}
+}
+
+// weight returns the weight of symbol s. RFC 4.2.1.
+func (h *huffEncoder) weight(s int) uint8 {
+	if h.nbits[s] == 0 {
+		return 0
+	}
+	return h.tableBits + 1 - h.nbits[s]
+}
+
+// appendTable appends the Huffman tree description to dst.
+// It reports false if the description can't be represented.
+// RFC 4.2.1.
+func (h *huffEncoder) appendTable(dst []byte) ([]byte, bool) {
+	// The weight of the last symbol is implied.
+	count := h.maxSym
+
+	// Try compressing the weights with FSE. RFC 4.2.1.2.
+	if w, ok := h.compressWeights(); ok && (len(w) < (count+1)/2 || count > 128) {
+		dst = append(dst, byte(len(w)))
+		return append(dst, w...), true
+	}
+
+	if count > 128 {
+		return dst, false
+	}
+
+	dst = append(dst, byte(127+count))
+	for i := 0; i < count; i += 2 {
+		b := h.weight(i) << 4
+		if i+1 < count {
+			b |= h.weight(i + 1)
+		}
+		dst = append(dst, b)
+	}
+	return dst, true
+}
+
+// compressWeights returns the FSE compressed weights.
+// It reports false if they can't be compressed.
+func (h *huffEncoder) compressWeights() ([]byte, bool) {
+	count := h.maxSym
+	if count < 2 {
+		return nil, false
+	}
+
+	var wcounts [maxHuffmanBits + 1]uint32
+	maxWeight := 0
+	distinct := 0
+	for s := range count {
+		w := h.weight(s)
+		if wcounts[w] == 0 {
+			distinct++
+		}
+		wcounts[w]++
+		maxWeight = max(maxWeight, int(w))
+	}
+	if distinct < 2 {
+		return nil, false
+	}
+
+	tableBits := fseTableBits(count, maxWeight, 6)
+	var norm [maxHuffmanBits + 1]int16
+	normalizeCounts(norm[:maxWeight+1], wcounts[:maxWeight+1], count, tableBits)
+
+	var enc fseEncoder
+	enc.build(norm[:maxWeight+1], tableBits)
+
+	// This is the inverse of the loop in readHuff,
+	// which decodes with two interleaved states.
+	out := appendNorm(h.wbuf[:0], norm[:maxWeight+1], tableBits)
+	bw := bitWriter{out: out}
+	var state1, state2 fseState
+	i := count
+	if count&1 != 0 {
+		i--
+		state1.init(&enc, h.weight(i))
+		i--
+		state2.init(&enc, h.weight(i))
+		i--
+		state1.encode(&bw, h.weight(i))
+	} else {
+		i--
+		state2.init(&enc, h.weight(i))
+		i--
+		state1.init(&enc, h.weight(i))
+	}
+	for i > 0 {
+		i--
+		state2.encode(&bw, h.weight(i))
+		i--
+		state1.encode(&bw, h.weight(i))
+	}
+	state2.flush(&bw)
+	state1.flush(&bw)
+	bw.close()
+	h.wbuf = bw.out
+
+	if len(bw.out) >= 128 {
+		return nil, false
+	}
+
+	// The decoder stops when it runs out of bits, which means
+	// that a state that needs no bits can confuse it.
+	// Make sure that the weights decode as expected.
+	data := append([]byte{byte(len(bw.out))}, bw.out...)
+	if len(h.table) < 1<<maxHuffmanBits {
+		h.table = make([]uint16, 1<<maxHuffmanBits)
+	}
+	tableBits2, _, err := h.reader.readHuff(data, 0, h.table)
+	if err != nil || tableBits2 != int(h.tableBits) {
+		return nil, false
+	}
+	for s := 0; s <= h.maxSym; s++ {
+		if h.nbits[s] == 0 {
+			continue
+		}
+		shift := h.tableBits - h.nbits[s]
+		want := uint16(s)<<8 | uint16(h.nbits[s])
+		start := uint32(h.codes[s]) << shift
+		for j := range uint32(1) << shift {
+			if h.table[start+j] != want {
+				return nil, false
+			}
+		}
+	}
+
+	return bw.out, true
+}
+
+// appendStream appends the Huffman compressed form of lits to dst.
+func (h *huffEncoder) appendStream(dst []byte, lits []byte) []byte {
+	bw := bitWriter{out: dst}
+	for i := len(lits) - 1; i >= 0; i-- {
+		s := lits[i]
+		bw.addBits(uint32(h.codes[s]), h.nbits[s])
+	}
+	bw.close()
+	return bw.out
+}
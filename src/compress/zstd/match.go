@@ -0,0 +1,255 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// encoderLevel holds the parameters of a compression level.
+type encoderLevel struct {
+	lookback  int   // bytes before a block that are searched for matches
+	hashLog   uint8 // log2 of the hash table size
+	chainLog  uint8 // log2 of the hash chain table size, or 0 for none
+	depth     int   // maximum number of hash chain entries to check
+	lazy      int   // number of following positions to check for a longer match
+	skipShift uint8 // speed up on incompressible data; 0 for no skipping
+	nice      int   // stop searching when a match is this long
+}
+
+// encoderLevels is indexed by compression level.
+var encoderLevels = [...]encoderLevel{
+	1: {lookback: 64 << 10, hashLog: 15, skipShift: 5, nice: 32},
+	2: {lookback: 128 << 10, hashLog: 16, skipShift: 6, nice: 32},
+	3: {lookback: 256 << 10, hashLog: 16, chainLog: 19, depth: 4, lazy: 1, nice: 32},
+	4: {lookback: 512 << 10, hashLog: 17, chainLog: 20, depth: 8, lazy: 1, nice: 48},
+	5: {lookback: 1 << 20, hashLog: 17, chainLog: 20, depth: 16, lazy: 1, nice: 64},
+	6: {lookback: 1 << 20, hashLog: 18, chainLog: 21, depth: 32, lazy: 2, nice: 96},
+	7: {lookback: 2 << 20, hashLog: 18, chainLog: 21, depth: 64, lazy: 2, nice: 128},
+	8: {lookback: 2 << 20, hashLog: 18, chainLog: 22, depth: 128, lazy: 2, nice: 256},
+	9: {lookback: 4 << 20, hashLog: 19, chainLog: 22, depth: 256, lazy: 2, nice: 512},
+}
+
+// windowLog returns the log2 of the window size required by the level.
+// Matches may refer to any data in the lookback or the current block.
+func (l *encoderLevel) windowLog() uint8 {
+	return uint8(bits.Len(uint(l.lookback + maxBlockSize - 1)))
+}
+
+// minMatch is the shortest match that the encoder looks for.
+const minMatch = 4
+
+// seq is a sequence to encode. RFC 3.1.1.3.2.
+type seq struct {
+	litLen   uint32 // number of literals
+	matchLen uint32 // length of the match, at least 3
+	offValue uint32 // repeated offset 1 to 3, or offset + 3
+}
+
+// matcher finds matches in a block using hash chains.
+// The tables are rebuilt for each block, so that blocks
+// may be compressed independently.
+type matcher struct {
+	level *encoderLevel
+	table []int32 // hash to position + 1
+	chain []int32 // position to previous position + 1 with the same hash
+	next  int     // next position to add to the tables
+
+	// The offsets that the decoder will use for repeated offset
+	// codes. 0 means unknown: the blocks before this one may
+	// have changed them.
+	rep [3]uint32
+
+	seqs []seq
+	lits []byte
+}
+
+func newMatcher(level *encoderLevel) *matcher {
+	m := &matcher{
+		level: level,
+		table: make([]int32, 1<<level.hashLog),
+	}
+	if level.chainLog > 0 {
+		m.chain = make([]int32, 1<<level.chainLog)
+	}
+	return m
+}
+
+func hash4(u uint32, bits uint8) uint32 {
+	return (u * 2654435761) >> (32 - bits)
+}
+
+// insert adds the positions up to end to the tables.
+func (m *matcher) insert(hist []byte, end int) {
+	end = min(end, len(hist)-minMatch+1)
+	hashLog := m.level.hashLog
+	if m.chain == nil {
+		for p := m.next; p < end; p++ {
+			h := hash4(binary.LittleEndian.Uint32(hist[p:]), hashLog)
+			m.table[h] = int32(p + 1)
+		}
+	} else {
+		chainMask := len(m.chain) - 1
+		for p := m.next; p < end; p++ {
+			h := hash4(binary.LittleEndian.Uint32(hist[p:]), hashLog)
+			m.chain[p&chainMask] = m.table[h]
+			m.table[h] = int32(p + 1)
+		}
+	}
+	m.next = max(m.next, end)
+}
+
+// matchLen returns the length of the common prefix of a and b.
+func matchLen(a, b []byte) int {
+	n := 0
+	for len(a)-n >= 8 && len(b)-n >= 8 {
+		if x := binary.LittleEndian.Uint64(a[n:]) ^ binary.LittleEndian.Uint64(b[n:]); x != 0 {
+			return n + bits.TrailingZeros64(x)/8
+		}
+		n += 8
+	}
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// find returns the longest match at position i of hist, which must
+// be at least minPos. It returns a length of 0 if there is no match.
+func (m *matcher) find(hist []byte, i, minPos int) (length, offset int) {
+	m.insert(hist, i)
+	cur := hist[i:]
+
+	// Repeated offsets are cheap to encode, so try them first.
+	for _, r := range m.rep[:2] {
+		if r != 0 && i-int(r) >= minPos {
+			if n := matchLen(hist[i-int(r):], cur); n > length {
+				length, offset = n, int(r)
+			}
+		}
+	}
+
+	u := binary.LittleEndian.Uint32(cur)
+	cand := int(m.table[hash4(u, m.level.hashLog)]) - 1
+	chainMask := len(m.chain) - 1
+	for depth := max(m.level.depth, 1); depth > 0 && cand >= minPos; depth-- {
+		if length >= m.level.nice || length == len(cur) {
+			break
+		}
+		if (length < minMatch || hist[cand+length] == cur[length]) &&
+			binary.LittleEndian.Uint32(hist[cand:]) == u {
+			if n := matchLen(hist[cand:], cur); n > length {
+				length, offset = n, i-cand
+			}
+		}
+		if m.chain == nil {
+			break
+		}
+		next := int(m.chain[cand&chainMask]) - 1
+		if next >= cand {
+			// An entry overwritten by a later position.
+			break
+		}
+		cand = next
+	}
+
+	if length < minMatch {
+		return 0, 0
+	}
+	return length, offset
+}
+
+// parse finds the sequences and literals of the block hist[start:].
+// Matches may refer to the lookback before start.
+func (m *matcher) parse(hist []byte, start int) {
+	clear(m.table)
+	clear(m.chain)
+	minPos := max(0, start-m.level.lookback)
+	m.next = minPos
+	m.insert(hist, start)
+	m.rep = [3]uint32{}
+	m.seqs = m.seqs[:0]
+	m.lits = m.lits[:0]
+
+	end := len(hist)
+	litStart := start
+	i := start
+	for i+minMatch <= end {
+		length, offset := m.find(hist, i, minPos)
+		if length == 0 {
+			i++
+			if m.level.skipShift > 0 {
+				i += (i - litStart) >> m.level.skipShift
+			}
+			continue
+		}
+
+		// Check whether a following position has a longer match.
+		for k := 0; k < m.level.lazy && i+1+minMatch <= end; k++ {
+			length2, offset2 := m.find(hist, i+1, minPos)
+			if length2 <= length {
+				break
+			}
+			i++
+			length, offset = length2, offset2
+		}
+
+		// Extend the match backward into the literals.
+		for i > litStart && i-offset > minPos && hist[i-1] == hist[i-offset-1] {
+			i--
+			length++
+		}
+
+		m.lits = append(m.lits, hist[litStart:i]...)
+		m.addSeq(uint32(i-litStart), uint32(offset), uint32(length))
+		i += length
+		litStart = i
+	}
+	m.lits = append(m.lits, hist[litStart:end]...)
+}
+
+// addSeq adds a sequence, using a repeated offset code if possible,
+// and updates the repeated offsets as the decoder will. RFC 3.1.1.5.
+func (m *matcher) addSeq(litLen, offset, matchLen uint32) {
+	rep := &m.rep
+	offValue := offset + 3
+	if litLen > 0 {
+		switch offset {
+		case rep[0]:
+			offValue = 1
+		case rep[1]:
+			offValue = 2
+		case rep[2]:
+			offValue = 3
+		}
+	} else {
+		switch {
+		case offset == rep[1]:
+			offValue = 1
+		case offset == rep[2]:
+			offValue = 2
+		case rep[0] > 1 && offset == rep[0]-1:
+			offValue = 3
+		}
+	}
+	m.seqs = append(m.seqs, seq{litLen: litLen, matchLen: matchLen, offValue: offValue})
+
+	idx := offValue - 1
+	if litLen == 0 {
+		idx++
+	}
+	switch {
+	case offValue > 3:
+		rep[0], rep[1], rep[2] = offset, rep[0], rep[1]
+	case idx == 0:
+	case idx == 1:
+		rep[0], rep[1] = rep[1], rep[0]
+	case idx == 2:
+		rep[0], rep[1], rep[2] = rep[2], rep[0], rep[1]
+	case idx == 3:
+		rep[0], rep[1], rep[2] = rep[0]-1, rep[0], rep[1]
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compression levels for [WriterOptions].
+const (
+	BestSpeed          = 1
+	BestCompression    = 9
+	DefaultCompression = 3
+)
+
+// WriterOptions are options for a [Writer].
+type WriterOptions struct {
+	// Level is the compression level, from BestSpeed to
+	// BestCompression. Zero means DefaultCompression.
+	// Higher levels compress better but more slowly.
+	Level int
+
+	// Checksum, if true, appends a checksum of the
+	// uncompressed data to the frame.
+	Checksum bool
+
+	// Concurrency is the maximum number of blocks to compress
+	// concurrently. Zero or one means that blocks are compressed
+	// by the goroutine that calls Write. The compressed data
+	// does not depend on the concurrency.
+	Concurrency int
+
+	// Dict is a dictionary to compress with. It may be either
+	// raw content or a formatted dictionary as described in
+	// RFC 8878 section 5. Only the content of a formatted
+	// dictionary is used to find matches, but its ID is recorded
+	// in the frame header. Data compressed with a dictionary must
+	// be decompressed with the same dictionary; see [NewReaderDict].
+	Dict []byte
+}
+
+// A Writer compresses data written to it, writing a single zstd frame
+// to an underlying writer.
+type Writer struct {
+	w           io.Writer
+	level       *encoderLevel
+	checksum    bool
+	concurrency int
+	dict        *dict
+	err         error
+
+	// hist holds the dictionary content, followed by the data
+	// written so far. Only the data needed for matches is kept.
+	// The data from pos on has not yet been compressed.
+	hist []byte
+	pos  int
+
+	size    uint64 // total number of bytes written
+	started bool   // whether we have started compressing blocks
+	header  []byte // frame header not yet written
+	closing bool   // whether Close has been called
+	digest  xxhash64
+
+	// Blocks being compressed concurrently, in order.
+	jobs []*blockJob
+
+	enc      *blockEncoder // used when not concurrent
+	encoders sync.Pool     // used when concurrent
+	out      []byte
+}
+
+// blockJob is a block being compressed.
+type blockJob struct {
+	done chan struct{}
+	out  []byte
+}
+
+var errWriterClosed = errors.New("zstd: write to closed Writer")
+
+// NewWriter returns a new [Writer] compressing data to w.
+// If opts is nil, the default options are used.
+//
+// It is the caller's responsibility to call Close on the Writer
+// when done. Writes may be buffered and not flushed until Close.
+func NewWriter(w io.Writer, opts *WriterOptions) (*Writer, error) {
+	if opts == nil {
+		opts = new(WriterOptions)
+	}
+	level := opts.Level
+	if level == 0 {
+		level = DefaultCompression
+	}
+	if level < BestSpeed || level > BestCompression {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", opts.Level)
+	}
+	zw := &Writer{
+		level:       &encoderLevels[level],
+		checksum:    opts.Checksum,
+		concurrency: opts.Concurrency,
+	}
+	if opts.Dict != nil {
+		d, err := parseDict(opts.Dict)
+		if err != nil {
+			return nil, err
+		}
+		zw.dict = d
+	}
+	zw.encoders.New = func() any {
+		return newBlockEncoder(zw.level)
+	}
+	zw.Reset(w)
+	return zw, nil
+}
+
+// Reset discards the Writer's state and makes it equivalent to the
+// result of [NewWriter] with the same options, but writing to w instead.
+// This permits reusing a Writer rather than allocating a new one.
+func (z *Writer) Reset(w io.Writer) {
+	// Wait for blocks that are still being compressed,
+	// as they use hist.
+	for _, job := range z.jobs {
+		<-job.done
+	}
+	clear(z.jobs)
+	z.jobs = z.jobs[:0]
+
+	z.w = w
+	z.err = nil
+	z.hist = z.hist[:0]
+	if z.dict != nil {
+		z.hist = append(z.hist, z.dict.content...)
+	}
+	z.pos = len(z.hist)
+	z.size = 0
+	z.started = false
+	z.header = z.header[:0]
+	z.closing = false
+	z.digest.reset()
+}
+
+// Write writes a compressed form of p to the underlying [io.Writer].
+// The compressed bytes are not necessarily flushed until the
+// Writer is flushed or closed.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closing {
+		return 0, errWriterClosed
+	}
+	n := len(p)
+	z.size += uint64(n)
+	if z.checksum {
+		z.digest.update(p)
+	}
+	for len(p) > 0 {
+		if len(z.hist) == cap(z.hist) {
+			z.grow()
+		}
+		c := copy(z.hist[len(z.hist):cap(z.hist)], p)
+		z.hist = z.hist[:len(z.hist)+c]
+		p = p[c:]
+
+		// Keep at least one byte pending, so that Close
+		// always has a last block to write.
+		for len(z.hist)-z.pos > maxBlockSize {
+			z.compressBlock(z.pos+maxBlockSize, false)
+		}
+	}
+	return n, z.err
+}
+
+// grow makes room in hist, discarding data that can no longer
+// be used for matches.
+func (z *Writer) grow() {
+	pending := len(z.hist) - z.pos
+	keep := z.hist[max(0, z.pos-z.level.lookback):]
+	size := 2 * (z.level.lookback + 2*maxBlockSize)
+	if len(z.jobs) == 0 && cap(z.hist) >= size {
+		// No other goroutine is using hist, so reuse it.
+		z.hist = z.hist[:copy(z.hist, keep)]
+	} else {
+		z.hist = append(make([]byte, 0, size), keep...)
+	}
+	z.pos = len(z.hist) - pending
+}
+
+// compressBlock compresses the data in hist from pos to end.
+func (z *Writer) compressBlock(end int, last bool) {
+	hist := z.hist[:end]
+	start := z.pos
+	z.pos = end
+
+	// Decide on the frame header now, rather than when writing the
+	// first block, so that it does not depend on the concurrency.
+	if !z.started {
+		z.started = true
+		z.header = z.appendFrameHeader(z.header[:0])
+	}
+
+	if z.concurrency <= 1 {
+		if z.enc == nil {
+			z.enc = newBlockEncoder(z.level)
+		}
+		z.out = z.enc.appendBlock(z.out[:0], hist, start, last)
+		z.writeBlock(z.out)
+		return
+	}
+
+	if len(z.jobs) >= z.concurrency {
+		z.finishJob()
+	}
+	job := &blockJob{done: make(chan struct{})}
+	z.jobs = append(z.jobs, job)
+	go func() {
+		enc := z.encoders.Get().(*blockEncoder)
+		job.out = enc.appendBlock(nil, hist, start, last)
+		z.encoders.Put(enc)
+		close(job.done)
+	}()
+}
+
+// finishJob waits for the oldest block being compressed
+// and writes it out.
+func (z *Writer) finishJob() {
+	job := z.jobs[0]
+	z.jobs[0] = nil
+	z.jobs = z.jobs[1:]
+	<-job.done
+	z.writeBlock(job.out)
+}
+
+// writeBlock writes a compressed block,
+// preceded by the frame header if needed.
+func (z *Writer) writeBlock(b []byte) {
+	if z.err != nil {
+		return
+	}
+	if len(z.header) > 0 {
+		if _, z.err = z.w.Write(z.header); z.err != nil {
+			return
+		}
+		z.header = z.header[:0]
+	}
+	_, z.err = z.w.Write(b)
+}
+
+// appendFrameHeader appends the frame header. RFC 3.1.1.1.
+func (z *Writer) appendFrameHeader(dst []byte) []byte {
+	dst = binary.LittleEndian.AppendUint32(dst, 0xfd2fb528)
+
+	var descriptor byte
+	if z.checksum {
+		descriptor |= 1 << 2
+	}
+	if z.dict != nil && z.dict.id != 0 {
+		descriptor |= 3
+	}
+
+	// If Close has been called before compressing any blocks,
+	// we know the content size.
+	// A small frame doesn't need a window larger than the content.
+	windowLog := z.level.windowLog()
+	singleSegment := z.closing && z.size <= 1<<windowLog
+	fcsSize := 0
+	if z.closing {
+		switch {
+		case singleSegment && z.size < 256:
+			fcsSize = 1
+		case z.size < 256+1<<16:
+			fcsSize = 2
+		case z.size < 1<<32:
+			fcsSize = 4
+		default:
+			fcsSize = 8
+		}
+	}
+	switch fcsSize {
+	case 2:
+		descriptor |= 1 << 6
+	case 4:
+		descriptor |= 2 << 6
+	case 8:
+		descriptor |= 3 << 6
+	}
+	if singleSegment {
+		descriptor |= 1 << 5
+	}
+	dst = append(dst, descriptor)
+
+	if !singleSegment {
+		dst = append(dst, (windowLog-10)<<3)
+	}
+	if descriptor&3 != 0 {
+		dst = binary.LittleEndian.AppendUint32(dst, z.dict.id)
+	}
+	switch fcsSize {
+	case 1:
+		dst = append(dst, byte(z.size))
+	case 2:
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(z.size-256))
+	case 4:
+		dst = binary.LittleEndian.AppendUint32(dst, uint32(z.size))
+	case 8:
+		dst = binary.LittleEndian.AppendUint64(dst, z.size)
+	}
+	return dst
+}
+
+// Flush compresses any pending data and writes it to the underlying
+// writer. The data written so far can then be decompressed, although
+// the frame is not complete until the Writer is closed.
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closing {
+		return errWriterClosed
+	}
+	if z.pos < len(z.hist) {
+		z.compressBlock(len(z.hist), false)
+	}
+	for len(z.jobs) > 0 {
+		z.finishJob()
+	}
+	return z.err
+}
+
+// Close flushes any pending data and writes the end of the frame
+// to the underlying writer. It does not close the underlying writer.
+func (z *Writer) Close() error {
+	if z.closing {
+		return z.err
+	}
+	z.closing = true
+	if z.err != nil {
+		return z.err
+	}
+	z.compressBlock(len(z.hist), true)
+	for len(z.jobs) > 0 {
+		z.finishJob()
+	}
+	if z.err == nil && z.checksum {
+		_, z.err = z.w.Write(binary.LittleEndian.AppendUint32(nil, uint32(z.digest.digest())))
+	}
+	return z.err
+}
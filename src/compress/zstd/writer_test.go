@@ -0,0 +1,371 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"internal/race"
+	"io"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// writerInputs returns inputs for compression tests.
+func writerInputs(t testing.TB) map[string][]byte {
+	rng := rand.New(rand.NewPCG(1, 2))
+	random := make([]byte, 300<<10)
+	for i := range random {
+		random[i] = byte(rng.Uint32())
+	}
+	text := make([]byte, 200<<10)
+	for i := range text {
+		text[i] = "aaaabbbccd  \n"[rng.IntN(13)]
+	}
+	big := bigData(t)
+	if testing.Short() || race.Enabled {
+		// The whole input takes minutes to compress at every level
+		// under the race detector.
+		big = big[:1<<20]
+	}
+	inputs := map[string][]byte{
+		"empty":  {},
+		"byte":   {'x'},
+		"zeros":  make([]byte, 400<<10),
+		"random": random,
+		"text":   text,
+		"big":    big,
+		"mixed":  append(append(append([]byte{}, big[:200<<10]...), random[:100<<10]...), big[:200<<10]...),
+	}
+	for _, test := range tests {
+		inputs[test.name] = []byte(test.uncompressed)
+	}
+	return inputs
+}
+
+func compress(t testing.TB, data []byte, opts *WriterOptions) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decompress(t testing.TB, compressed, dict []byte) []byte {
+	r, err := NewReaderDict(bytes.NewReader(compressed), dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	levels := []int{BestSpeed, 2, DefaultCompression, 4, 5, 6, 7, 8, BestCompression}
+	if testing.Short() {
+		levels = []int{BestSpeed, DefaultCompression, BestCompression}
+	}
+	for name, data := range writerInputs(t) {
+		for _, level := range levels {
+			t.Run(fmt.Sprintf("%s/%d", name, level), func(t *testing.T) {
+				opts := &WriterOptions{Level: level, Checksum: level%2 == 0}
+				compressed := compress(t, data, opts)
+				if len(data) > 1000 {
+					t.Logf("compressed %d bytes to %d", len(data), len(compressed))
+				}
+				if got := decompress(t, compressed, nil); !bytes.Equal(got, data) {
+					showDiffs(t, got, data)
+				}
+
+				// Compressing again concurrently is slow, especially under
+				// the race detector, and the levels share the code that
+				// splits the input among goroutines, so check one level.
+				if (testing.Short() || race.Enabled) && level != DefaultCompression {
+					return
+				}
+				opts.Concurrency = 4
+				if concurrent := compress(t, data, opts); !bytes.Equal(concurrent, compressed) {
+					t.Error("concurrent compression produced different output")
+				}
+			})
+		}
+	}
+}
+
+func TestWriterCompressionRatio(t *testing.T) {
+	data := bigData(t)[:1<<20]
+	prev := len(data)
+	for _, level := range []int{BestSpeed, DefaultCompression, BestCompression} {
+		n := len(compress(t, data, &WriterOptions{Level: level}))
+		t.Logf("level %d: compressed %d bytes to %d", level, len(data), n)
+		if n >= prev {
+			t.Errorf("level %d: compressed size %d not smaller than %d", level, n, prev)
+		}
+		prev = n
+	}
+}
+
+func TestWriterZstd(t *testing.T) {
+	zstd := findZstd(t)
+	for name, data := range writerInputs(t) {
+		for _, opts := range []*WriterOptions{
+			nil,
+			{Level: BestSpeed},
+			{Level: BestCompression, Checksum: true},
+		} {
+			t.Run(name, func(t *testing.T) {
+				compressed := compress(t, data, opts)
+				cmd := exec.Command(zstd, "-d")
+				cmd.Stdin = bytes.NewReader(compressed)
+				var out bytes.Buffer
+				cmd.Stdout = &out
+				cmd.Stderr = os.Stderr
+				if err := cmd.Run(); err != nil {
+					t.Fatalf("zstd -d failed: %v", err)
+				}
+				if got := out.Bytes(); !bytes.Equal(got, data) {
+					showDiffs(t, got, data)
+				}
+			})
+		}
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	data := bigData(t)[:300<<10]
+	for _, concurrency := range []int{1, 3} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, &WriterOptions{Checksum: true, Concurrency: concurrency})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(data); i += 50 << 10 {
+			chunk := data[i:min(i+50<<10, len(data))]
+			if _, err := w.Write(chunk); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Everything written so far can be decompressed.
+			r := NewReader(bytes.NewReader(buf.Bytes()))
+			got := make([]byte, i+len(chunk))
+			if _, err := io.ReadFull(r, got); err != nil {
+				t.Fatalf("after flush at %d: %v", i, err)
+			}
+			if !bytes.Equal(got, data[:len(got)]) {
+				showDiffs(t, got, data[:len(got)])
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := decompress(t, buf.Bytes(), nil); !bytes.Equal(got, data) {
+			showDiffs(t, got, data)
+		}
+		if _, err := w.Write(data); err == nil {
+			t.Error("Write after Close succeeded")
+		}
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	data := bigData(t)[:200<<10]
+	var buf1, buf2 bytes.Buffer
+	w, err := NewWriter(&buf1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data[:100])
+	w.Reset(&buf2)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf1.Len() != 0 {
+		t.Errorf("wrote %d bytes before Reset, want 0", buf1.Len())
+	}
+	if got := decompress(t, buf2.Bytes(), nil); !bytes.Equal(got, data) {
+		showDiffs(t, got, data)
+	}
+}
+
+func TestWriterMultipleFrames(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, &WriterOptions{Checksum: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []byte
+	for i := range 3 {
+		s := fmt.Sprintf("frame %d: %s", i, bytes.Repeat([]byte("data "), i*100))
+		want = append(want, s...)
+		w.Reset(&buf)
+		io.WriteString(w, s)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := decompress(t, buf.Bytes(), nil); !bytes.Equal(got, want) {
+		showDiffs(t, got, want)
+	}
+}
+
+func TestWriterBadLevel(t *testing.T) {
+	for _, level := range []int{-1, BestCompression + 1} {
+		if _, err := NewWriter(io.Discard, &WriterOptions{Level: level}); err == nil {
+			t.Errorf("level %d: got nil error", level)
+		}
+	}
+}
+
+func TestRawDict(t *testing.T) {
+	data := bigData(t)
+	dict := data[:64<<10]
+	input := data[16<<10 : 48<<10]
+
+	compressed := compress(t, input, &WriterOptions{Dict: dict})
+	plain := compress(t, input, nil)
+	t.Logf("compressed %d bytes to %d with dictionary, %d without", len(input), len(compressed), len(plain))
+	if len(compressed) >= len(plain)/10 {
+		t.Errorf("dictionary did not help compression")
+	}
+	if got := decompress(t, compressed, dict); !bytes.Equal(got, input) {
+		showDiffs(t, got, input)
+	}
+}
+
+// trainDict returns a dictionary trained by the zstd program,
+// and some samples that were used to train it.
+func trainDict(t *testing.T) (dict []byte, samples [][]byte) {
+	zstd := findZstd(t)
+	dir := t.TempDir()
+	rng := rand.New(rand.NewPCG(3, 4))
+	words := []string{"alpha", "beta", "gamma", "delta", "zstd", "frame", "block", "dictionary", "offset", "literal"}
+	var args []string
+	for i := range 200 {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, `{"id": %d, "name": %q, "tags": [`, i, words[rng.IntN(len(words))])
+		for j := range 5 {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%q", words[rng.IntN(len(words))])
+		}
+		b.WriteString(`], "description": "a sample record used to train a dictionary"}`)
+		name := fmt.Sprintf("%s/sample%d.json", dir, i)
+		if err := os.WriteFile(name, b.Bytes(), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, name)
+		samples = append(samples, b.Bytes())
+	}
+	dictFile := dir + "/dict"
+	cmd := exec.Command(zstd, append([]string{"-q", "--train", "--maxdict=4096", "-o", dictFile}, args...)...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		t.Skipf("zstd --train failed: %v", err)
+	}
+	dict, err := os.ReadFile(dictFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dict, samples
+}
+
+func TestFormattedDict(t *testing.T) {
+	zstd := findZstd(t)
+	dict, samples := trainDict(t)
+	dictFile := t.TempDir() + "/dict"
+	if err := os.WriteFile(dictFile, dict, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, sample := range samples[:20] {
+		// Decompress data compressed by zstd.
+		cmd := exec.Command(zstd, "-z", "-D", dictFile)
+		cmd.Stdin = bytes.NewReader(sample)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := decompress(t, out, dict); !bytes.Equal(got, sample) {
+			t.Errorf("sample %d: decompressed zstd output incorrectly", i)
+			showDiffs(t, got, sample)
+		}
+
+		// Check that zstd decompresses our data.
+		compressed := compress(t, sample, &WriterOptions{Dict: dict, Checksum: true})
+		cmd = exec.Command(zstd, "-d", "-D", dictFile)
+		cmd.Stdin = bytes.NewReader(compressed)
+		out, err = cmd.Output()
+		if err != nil {
+			t.Fatalf("sample %d: zstd -d failed: %v", i, err)
+		}
+		if !bytes.Equal(out, sample) {
+			t.Errorf("sample %d: zstd decompressed our output incorrectly", i)
+			showDiffs(t, out, sample)
+		}
+		if got := decompress(t, compressed, dict); !bytes.Equal(got, sample) {
+			showDiffs(t, got, sample)
+		}
+	}
+
+	// A frame with a dictionary ID requires the dictionary.
+	compressed := compress(t, samples[0], &WriterOptions{Dict: dict})
+	for _, d := range [][]byte{nil, []byte("raw dictionary content")} {
+		r, err := NewReaderDict(bytes.NewReader(compressed), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(r); err == nil {
+			t.Errorf("decompressing with dictionary %q succeeded", d)
+		}
+	}
+}
+
+func TestBadDict(t *testing.T) {
+	dict := []byte{0x37, 0xa4, 0x30, 0xec, 1, 0, 0, 0, 0xff}
+	if _, err := NewReaderDict(bytes.NewReader(nil), dict); err == nil {
+		t.Error("NewReaderDict succeeded with bad dictionary")
+	}
+	if _, err := NewWriter(io.Discard, &WriterOptions{Dict: dict}); err == nil {
+		t.Error("NewWriter succeeded with bad dictionary")
+	}
+}
+
+func BenchmarkWriter(b *testing.B) {
+	data := bigData(b)
+	for _, level := range []int{BestSpeed, DefaultCompression, BestCompression} {
+		for _, concurrency := range []int{1, 4} {
+			b.Run(fmt.Sprintf("level=%d/concurrency=%d", level, concurrency), func(b *testing.B) {
+				w, err := NewWriter(io.Discard, &WriterOptions{Level: level, Concurrency: concurrency})
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(data)))
+				b.ReportAllocs()
+				for range b.N {
+					w.Reset(io.Discard)
+					w.Write(data)
+					w.Close()
+				}
+			})
+		}
+	}
+}
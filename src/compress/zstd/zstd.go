@@ -2,8 +2,13 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package zstd provides a decompressor for zstd streams,
-// described in RFC 8878. It does not support dictionaries.
+// Package zstd implements reading and writing of Zstandard compressed
+// streams, as described in RFC 8878.
+//
+// A stream is a sequence of frames. A [Reader] decompresses every frame
+// in the stream, skipping skippable frames. A [Writer] produces a single
+// frame. Both support dictionaries, which may be either raw content or
+// formatted dictionaries as described in RFC 8878 section 5.
 package zstd
 
 import (
@@ -82,6 +87,9 @@ type Reader struct {
 
 	// For checksum computation.
 	checksum xxhash64
+
+	// The dictionary, if any.
+	dict *dict
 }
 
 // NewReader creates a new Reader that decompresses data from the given reader.
@@ -91,8 +99,27 @@ func NewReader(input io.Reader) *Reader {
 	return r
 }
 
+// NewReaderDict is like [NewReader] but decompresses using a dictionary.
+// The dictionary may be either raw content or a formatted dictionary
+// as described in RFC 8878 section 5. A frame that names a dictionary
+// ID must match the ID of the formatted dictionary. A frame with no
+// dictionary ID is decompressed using the dictionary.
+//
+// The Reader retains dict, which must not be modified while the
+// Reader is in use.
+func NewReaderDict(input io.Reader, dict []byte) (*Reader, error) {
+	d, err := parseDict(dict)
+	if err != nil {
+		return nil, err
+	}
+	r := NewReader(input)
+	r.dict = d
+	return r, nil
+}
+
 // Reset discards the current state and starts reading a new stream from r.
 // This permits reusing a Reader rather than allocating a new one.
+// A Reader created by [NewReaderDict] continues to use its dictionary.
 func (r *Reader) Reset(input io.Reader) {
 	r.r = input
 
@@ -256,11 +283,18 @@ retry:
 
 	// Dictionary_ID. RFC 3.1.1.1.3.
 	if dictionaryIdSize != 0 {
-		dictionaryId := r.scratch[windowDescriptorSize : windowDescriptorSize+dictionaryIdSize]
-		// Allow only zero Dictionary ID.
-		for _, b := range dictionaryId {
-			if b != 0 {
-				return r.makeError(relativeOffset, "dictionaries are not supported")
+		var dictionaryId uint32
+		for i, b := range r.scratch[windowDescriptorSize : windowDescriptorSize+dictionaryIdSize] {
+			dictionaryId |= uint32(b) << (8 * i)
+		}
+		// A zero Dictionary ID means that no particular
+		// dictionary is required.
+		if dictionaryId != 0 {
+			if r.dict == nil {
+				return r.makeError(relativeOffset, "missing dictionary")
+			}
+			if dictionaryId != r.dict.id {
+				return r.makeError(relativeOffset, "wrong dictionary")
 			}
 		}
 	}
@@ -313,9 +347,43 @@ retry:
 	r.seqTables[1] = nil
 	r.seqTables[2] = nil
 
+	if r.dict != nil {
+		r.useDict()
+	}
+
 	return nil
 }
 
+// useDict prepares to read blocks from a frame using the dictionary.
+// RFC 8878 section 5.
+func (r *Reader) useDict() {
+	d := r.dict
+
+	r.repeatedOffset1 = d.repeatedOffsets[0]
+	r.repeatedOffset2 = d.repeatedOffsets[1]
+	r.repeatedOffset3 = d.repeatedOffsets[2]
+
+	if d.huffmanTableBits > 0 {
+		// Compressed literals overwrite r.huffmanTable,
+		// so copy the dictionary table.
+		if len(r.huffmanTable) < 1<<maxHuffmanBits {
+			r.huffmanTable = make([]uint16, 1<<maxHuffmanBits)
+		}
+		copy(r.huffmanTable, d.huffmanTable)
+		r.huffmanTableBits = d.huffmanTableBits
+	}
+
+	// The sequence tables are never modified in place,
+	// so we can refer to them directly.
+	r.seqTables = d.seqTables
+	r.seqTableBits = d.seqTableBits
+
+	// The content precedes the frame data in the window.
+	// Keep all of it, as matches may refer to any part of it.
+	r.window.reset(r.window.size + len(d.content))
+	r.window.save(d.content)
+}
+
 // skipFrame skips a skippable frame. RFC 3.1.2.
 func (r *Reader) skipFrame() error {
 	relativeOffset := 0
@@ -208,7 +208,7 @@ func zstdBigData(t testing.TB) []byte {
 	return zstdBigBytes
 }
 
-// Test decompressing a large file. We don't have a compressor,
+// Test decompressing a large file compressed by the zstd program,
 // so this test only runs on systems with zstd installed.
 func TestLarge(t *testing.T) {
 	if testing.Short() {
@@ -0,0 +1,282 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hkdf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+// hkdfTest is modeled after the Wycheproof HKDF test vector format.
+type hkdfTest struct {
+	tcID    int
+	comment string
+	hash    func() hash.Hash
+	ikm     string
+	salt    string
+	info    string
+	size    int
+	prk     string
+	okm     string
+	result  string // "valid" or "invalid"
+}
+
+var hkdfTests = []hkdfTest{
+	{
+		tcID:    1,
+		comment: "RFC 5869 Test Case 1",
+		hash:    sha256.New,
+		ikm:     "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+		salt:    "000102030405060708090a0b0c",
+		info:    "f0f1f2f3f4f5f6f7f8f9",
+		size:    42,
+		prk:     "077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5",
+		okm:     "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865",
+		result:  "valid",
+	},
+	{
+		tcID:    2,
+		comment: "RFC 5869 Test Case 2",
+		hash:    sha256.New,
+		ikm:     "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f404142434445464748494a4b4c4d4e4f",
+		salt:    "606162636465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9fa0a1a2a3a4a5a6a7a8a9aaabacadaeaf",
+		info:    "b0b1b2b3b4b5b6b7b8b9babbbcbdbebfc0c1c2c3c4c5c6c7c8c9cacbcccdcecfd0d1d2d3d4d5d6d7d8d9dadbdcdddedfe0e1e2e3e4e5e6e7e8e9eaebecedeeeff0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		size:    82,
+		prk:     "06a6b88c5853361a06104c9ceb35b45cef760014904671014a193f40c15fc244",
+		okm:     "b11e398dc80327a1c8e7f78c596a49344f012eda2d4efad8a050cc4c19afa97c59045a99cac7827271cb41c65e590e09da3275600c2f09b8367793a9aca3db71cc30c58179ec3e87c14c01d5c1f3434f1d87",
+		result:  "valid",
+	},
+	{
+		tcID:    3,
+		comment: "RFC 5869 Test Case 3",
+		hash:    sha256.New,
+		ikm:     "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+		salt:    "",
+		info:    "",
+		size:    42,
+		prk:     "19ef24a32c717b167f33a91d6f648bdf96596776afdb6377ac434c1c293ccb04",
+		okm:     "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8",
+		result:  "valid",
+	},
+	{
+		tcID:    4,
+		comment: "RFC 5869 Test Case 4",
+		hash:    sha1.New,
+		ikm:     "0b0b0b0b0b0b0b0b0b0b0b",
+		salt:    "000102030405060708090a0b0c",
+		info:    "f0f1f2f3f4f5f6f7f8f9",
+		size:    42,
+		prk:     "9b6c18c432a7bf8f0e71c8eb88f4b30baa2ba243",
+		okm:     "085a01ea1b10f36933068b56efa5ad81a4f14b822f5b091568a9cdd4f155fda2c22e422478d305f3f896",
+		result:  "valid",
+	},
+	{
+		tcID:    5,
+		comment: "RFC 5869 Test Case 5",
+		hash:    sha1.New,
+		ikm:     "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f404142434445464748494a4b4c4d4e4f",
+		salt:    "606162636465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9fa0a1a2a3a4a5a6a7a8a9aaabacadaeaf",
+		info:    "b0b1b2b3b4b5b6b7b8b9babbbcbdbebfc0c1c2c3c4c5c6c7c8c9cacbcccdcecfd0d1d2d3d4d5d6d7d8d9dadbdcdddedfe0e1e2e3e4e5e6e7e8e9eaebecedeeeff0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		size:    82,
+		prk:     "8adae09a2a307059478d309b26c4115a224cfaf6",
+		okm:     "0bd770a74d1160f7c9f12cd5912a06ebff6adcae899d92191fe4305673ba2ffe8fa3f1a4e5ad79f3f334b3b202b2173c486ea37ce3d397ed034c7f9dfeb15c5e927336d0441f4c4300e2cff0d0900b52d3b4",
+		result:  "valid",
+	},
+	{
+		tcID:    6,
+		comment: "RFC 5869 Test Case 6",
+		hash:    sha1.New,
+		ikm:     "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+		salt:    "",
+		info:    "",
+		size:    42,
+		prk:     "da8c8a73c7fa77288ec6f5e7c297786aa0d32d01",
+		okm:     "0ac1af7002b3d761d1e55298da9d0506b9ae52057220a306e07b6b87e8df21d0ea00033de03984d34918",
+		result:  "valid",
+	},
+	{
+		tcID:    7,
+		comment: "empty key material",
+		hash:    sha256.New,
+		ikm:     "",
+		salt:    "73616c74",
+		info:    "696e666f",
+		size:    32,
+		prk:     "379d7f7966f400cb6e3c0b2cca4bf8a2db03b8c81fef8020015b5a3103c30460",
+		okm:     "7aac7b8120501c2c8e1ee50e6cde135361e99ceb9d8d406ac528b9e9175614c0",
+		result:  "valid",
+	},
+	{
+		tcID:    8,
+		comment: "output size not a multiple of the hash size",
+		hash:    sha512.New,
+		ikm:     "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+		salt:    "73616c74",
+		info:    "696e666f",
+		size:    65,
+		prk:     "bc79aa0ef6a4f2f992dea7204d53ce637a56d5b497289994fecc5870071b7749522bfa6251de01c33a8096095288aaf7d12ef1132f7d05cb2eb9e49b4089857a",
+		okm:     "f68aca3a3afd2c6f291f1e9c481054553ce84a2353df2b62c2b90eafbe9ba2737b1745149c8cd4a1deee3afdbf30e8009c27eac5a56c1e63eb5c5336d4f739a019",
+		result:  "valid",
+	},
+	{
+		tcID:    9,
+		comment: "SHA-384",
+		hash:    sha512.New384,
+		ikm:     "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+		salt:    "000102030405060708090a0b0c",
+		info:    "f0f1f2f3f4f5f6f7f8f9",
+		size:    100,
+		prk:     "704b39990779ce1dc548052c7dc39f303570dd13fb39f7acc564680bef80e8dec70ee9a7e1f3e293ef68eceb072a5ade",
+		okm:     "9b5097a86038b805309076a44b3a9f38063e25b516dcbf369f394cfab43685f748b6457763e4f0204fc5d95d1da3e62587b22eb8943d0fab6bb631a2fe9df1a68c6ce5d56116a52005b3f122b88b39b7251fcd6c44d3ef25f20ed96802bf1b2c1d98bf74",
+		result:  "valid",
+	},
+	{
+		tcID:    10,
+		comment: "zero output size",
+		hash:    sha256.New,
+		ikm:     "736563726574",
+		salt:    "73616c74",
+		info:    "696e666f",
+		size:    0,
+		prk:     "98e5340f0f4f96d2b80c2a90da0d03cf46c35e9492918cc7af73d9a39efa5981",
+		okm:     "",
+		result:  "valid",
+	},
+	{
+		tcID:    11,
+		comment: "output size too large",
+		hash:    sha256.New,
+		ikm:     "736563726574",
+		salt:    "73616c74",
+		info:    "696e666f",
+		size:    255*32 + 1,
+		prk:     "98e5340f0f4f96d2b80c2a90da0d03cf46c35e9492918cc7af73d9a39efa5981",
+		result:  "invalid",
+	},
+	{
+		tcID:    12,
+		comment: "output size too large",
+		hash:    sha1.New,
+		ikm:     "736563726574",
+		salt:    "73616c74",
+		info:    "696e666f",
+		size:    255*20 + 1,
+		result:  "invalid",
+	},
+	{
+		tcID:    13,
+		comment: "negative output size",
+		hash:    sha256.New,
+		ikm:     "736563726574",
+		salt:    "73616c74",
+		info:    "696e666f",
+		size:    -1,
+		result:  "invalid",
+	},
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestHKDF(t *testing.T) {
+	for _, tt := range hkdfTests {
+		ikm := decodeHex(t, tt.ikm)
+		salt := decodeHex(t, tt.salt)
+		info := string(decodeHex(t, tt.info))
+
+		if tt.prk != "" {
+			prk, err := Extract(tt.hash, ikm, salt)
+			if err != nil {
+				t.Errorf("tcId %d (%s): Extract: %v", tt.tcID, tt.comment, err)
+			} else if got := hex.EncodeToString(prk); got != tt.prk {
+				t.Errorf("tcId %d (%s): Extract = %s, want %s", tt.tcID, tt.comment, got, tt.prk)
+			}
+
+			okm, err := Expand(tt.hash, prk, info, tt.size)
+			checkResult(t, tt, "Expand", okm, err)
+		}
+
+		okm, err := Key(tt.hash, ikm, salt, info, tt.size)
+		checkResult(t, tt, "Key", okm, err)
+	}
+}
+
+func checkResult(t *testing.T, tt hkdfTest, name string, okm []byte, err error) {
+	t.Helper()
+	switch tt.result {
+	case "valid":
+		if err != nil {
+			t.Errorf("tcId %d (%s): %s: unexpected error: %v", tt.tcID, tt.comment, name, err)
+		} else if got := hex.EncodeToString(okm); got != tt.okm {
+			t.Errorf("tcId %d (%s): %s = %s, want %s", tt.tcID, tt.comment, name, got, tt.okm)
+		}
+	case "invalid":
+		if err == nil {
+			t.Errorf("tcId %d (%s): %s succeeded, want error", tt.tcID, tt.comment, name)
+		}
+	default:
+		t.Fatalf("tcId %d: unknown result %q", tt.tcID, tt.result)
+	}
+}
+
+func TestMaxLength(t *testing.T) {
+	prk, err := Extract(sha256.New, []byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	okm, err := Expand(sha256.New, prk, "info", 255*sha256.Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(okm) != 255*sha256.Size {
+		t.Errorf("got %d bytes of output, want %d", len(okm), 255*sha256.Size)
+	}
+
+	// Shorter outputs must be prefixes of longer ones.
+	short, err := Expand(sha256.New, prk, "info", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(short, okm[:100]) {
+		t.Errorf("short output is not a prefix of the long output")
+	}
+}
+
+func TestNilSalt(t *testing.T) {
+	ikm := []byte("secret")
+	prk1, err := Extract(sha256.New, ikm, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prk2, err := Extract(sha256.New, ikm, make([]byte, sha256.Size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(prk1, prk2) {
+		t.Errorf("nil salt is not equivalent to a zero salt")
+	}
+}
+
+func BenchmarkHKDF(b *testing.B) {
+	secret := make([]byte, 32)
+	salt := make([]byte, 32)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Key(sha256.New, secret, salt, "info", 32); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -119,6 +119,27 @@ func TestRFC9180Vectors(t *testing.T) {
 			if !bytes.Equal(encap, expectedEncap) {
 				t.Errorf("unexpected encapsulated key, got: %x, want %x", encap, expectedEncap)
 			}
+
+			privKeyBytes := mustDecodeHex(t, setup["skRm"])
+			priv, err := ParseHPKEPrivateKey(uint16(kemID), privKeyBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recipient, err := SetupRecipient(
+				uint16(kemID),
+				uint16(kdfID),
+				uint16(aeadID),
+				priv,
+				info,
+				encap,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(recipient.sharedSecret, context.sharedSecret) {
+				t.Errorf("recipient shared secret mismatch, got: %x, want %x", recipient.sharedSecret, context.sharedSecret)
+			}
 			expectedSharedSecret := mustDecodeHex(t, setup["shared_secret"])
 			if !bytes.Equal(context.sharedSecret, expectedSharedSecret) {
 				t.Errorf("unexpected shared secret, got: %x, want %x", context.sharedSecret, expectedSharedSecret)
@@ -162,6 +183,15 @@ func TestRFC9180Vectors(t *testing.T) {
 					if !bytes.Equal(ciphertext, expectedCiphertext) {
 						t.Errorf("unexpected ciphertext: got %x want %x", ciphertext, expectedCiphertext)
 					}
+
+					recipient.seqNum = uint128{lo: uint64(seqNum)}
+					plaintext, err := recipient.Open(mustDecodeHex(t, enc["aad"]), expectedCiphertext)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if expectedPlaintext := mustDecodeHex(t, enc["pt"]); !bytes.Equal(plaintext, expectedPlaintext) {
+						t.Errorf("unexpected plaintext: got %x want %x", plaintext, expectedPlaintext)
+					}
 				})
 			}
 		})
@@ -0,0 +1,373 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mlkem
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha3"
+	"encoding/hex"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Run("768", func(t *testing.T) {
+		testRoundTrip(t, GenerateKey768, NewEncapsulationKey768, NewDecapsulationKey768)
+	})
+	t.Run("1024", func(t *testing.T) {
+		testRoundTrip(t, GenerateKey1024, NewEncapsulationKey1024, NewDecapsulationKey1024)
+	})
+}
+
+type encapsulationKey interface {
+	Bytes() []byte
+	Encapsulate() ([]byte, []byte)
+}
+
+type decapsulationKey[E encapsulationKey] interface {
+	Bytes() []byte
+	Decapsulate([]byte) ([]byte, error)
+	EncapsulationKey() E
+}
+
+func testRoundTrip[E encapsulationKey, D decapsulationKey[E]](
+	t *testing.T, generateKey func() (D, error),
+	newEncapsulationKey func([]byte) (E, error),
+	newDecapsulationKey func([]byte) (D, error)) {
+	dk, err := generateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	Ke, c := ek.Encapsulate()
+	Kd, err := dk.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd) {
+		t.Fail()
+	}
+
+	ek1, err := newEncapsulationKey(ek.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ek.Bytes(), ek1.Bytes()) {
+		t.Fail()
+	}
+	dk1, err := newDecapsulationKey(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dk.Bytes(), dk1.Bytes()) {
+		t.Fail()
+	}
+	Kd1, err := dk1.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd1) {
+		t.Fail()
+	}
+
+	dk2, err := generateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(dk.EncapsulationKey().Bytes(), dk2.EncapsulationKey().Bytes()) {
+		t.Fail()
+	}
+	if bytes.Equal(dk.Bytes(), dk2.Bytes()) {
+		t.Fail()
+	}
+
+	Ke1, c1 := ek.Encapsulate()
+	if bytes.Equal(c, c1) {
+		t.Fail()
+	}
+	if bytes.Equal(Ke, Ke1) {
+		t.Fail()
+	}
+}
+
+func TestBadLengths(t *testing.T) {
+	t.Run("768", func(t *testing.T) {
+		testBadLengths(t, GenerateKey768, NewEncapsulationKey768, NewDecapsulationKey768)
+	})
+	t.Run("1024", func(t *testing.T) {
+		testBadLengths(t, GenerateKey1024, NewEncapsulationKey1024, NewDecapsulationKey1024)
+	})
+}
+
+func testBadLengths[E encapsulationKey, D decapsulationKey[E]](
+	t *testing.T, generateKey func() (D, error),
+	newEncapsulationKey func([]byte) (E, error),
+	newDecapsulationKey func([]byte) (D, error)) {
+	dk, err := generateKey()
+	dkBytes := dk.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	ekBytes := dk.EncapsulationKey().Bytes()
+	_, c := ek.Encapsulate()
+
+	for i := 0; i < len(dkBytes)-1; i++ {
+		if _, err := newDecapsulationKey(dkBytes[:i]); err == nil {
+			t.Errorf("expected error for dk length %d", i)
+		}
+	}
+	dkLong := dkBytes
+	for i := 0; i < 100; i++ {
+		dkLong = append(dkLong, 0)
+		if _, err := newDecapsulationKey(dkLong); err == nil {
+			t.Errorf("expected error for dk length %d", len(dkLong))
+		}
+	}
+
+	for i := 0; i < len(ekBytes)-1; i++ {
+		if _, err := newEncapsulationKey(ekBytes[:i]); err == nil {
+			t.Errorf("expected error for ek length %d", i)
+		}
+	}
+	ekLong := ekBytes
+	for i := 0; i < 100; i++ {
+		ekLong = append(ekLong, 0)
+		if _, err := newEncapsulationKey(ekLong); err == nil {
+			t.Errorf("expected error for ek length %d", len(ekLong))
+		}
+	}
+
+	for i := 0; i < len(c)-1; i++ {
+		if _, err := dk.Decapsulate(c[:i]); err == nil {
+			t.Errorf("expected error for c length %d", i)
+		}
+	}
+	cLong := c
+	for i := 0; i < 100; i++ {
+		cLong = append(cLong, 0)
+		if _, err := dk.Decapsulate(cLong); err == nil {
+			t.Errorf("expected error for c length %d", len(cLong))
+		}
+	}
+}
+
+func TestUnreducedEncapsulationKey(t *testing.T) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey().Bytes()
+	// Set the first coefficient of t to q, which is not a reduced field element.
+	ek[0] = byte(q & 0xff)
+	ek[1] = ek[1]&0xf0 | byte(q>>8)
+	if _, err := NewEncapsulationKey768(ek); err == nil {
+		t.Error("expected error for unreduced encapsulation key")
+	}
+}
+
+// expandedBytes768 returns the FIPS 203 expanded encoding of dk, which is
+// not otherwise exposed by this package.
+func expandedBytes768(dk *DecapsulationKey768) []byte {
+	var b []byte
+	for i := range dk.s {
+		b = polyByteEncode(b, dk.s[i])
+	}
+	b = dk.EncapsulationKey().bytes(b)
+	b = append(b, dk.h[:]...)
+	b = append(b, dk.z[:]...)
+	return b
+}
+
+// expandedBytes1024 returns the FIPS 203 expanded encoding of dk, which is
+// not otherwise exposed by this package.
+func expandedBytes1024(dk *DecapsulationKey1024) []byte {
+	var b []byte
+	for i := range dk.s {
+		b = polyByteEncode(b, dk.s[i])
+	}
+	b = dk.EncapsulationKey().bytes(b)
+	b = append(b, dk.h[:]...)
+	b = append(b, dk.z[:]...)
+	return b
+}
+
+// TestAccumulated accumulates 10k vectors generated by the reference
+// implementation and checks the hash of the result, to avoid checking in 150MB
+// of test vectors. The vectors and their hashes match the accumulated ML-KEM
+// vectors of the C2SP CCTV project (https://c2sp.org/CCTV/ML-KEM).
+func TestAccumulated(t *testing.T) {
+	t.Run("768", func(t *testing.T) {
+		n := 10000
+		expected := "f959d18d3d1180121433bf0e05f11e7908cf9d03edc150b2b07cb90bef5bc1c1"
+		if testing.Short() {
+			n = 100
+			expected = "8d65b902f28edc683cebee2872962fd165a4d197c9e24ec74caa4470270df0b7"
+		}
+		testAccumulated(t, n, expected, CiphertextSize768,
+			func(d, z *[32]byte) ([]byte, []byte, func([]byte) ([]byte, []byte), func([]byte) []byte) {
+				dk := &DecapsulationKey768{}
+				kemKeyGen(dk, d, z)
+				ek := dk.EncapsulationKey()
+				encaps := func(m []byte) ([]byte, []byte) {
+					return kemEncaps(nil, ek, (*[messageSize]byte)(m))
+				}
+				decaps := func(c []byte) []byte {
+					return kemDecaps(dk, (*[CiphertextSize768]byte)(c))
+				}
+				return ek.Bytes(), expandedBytes768(dk), encaps, decaps
+			})
+	})
+	t.Run("1024", func(t *testing.T) {
+		n := 10000
+		expected := "e3bf82b013307b2e9d47dde791ff6dfc82e694e6382404abdb948b908b75bad5"
+		if testing.Short() {
+			n = 100
+			expected = "c3ffe9ebecfa479c142656cbfbc6417efa05b77e994fe538eef4daed166363df"
+		}
+		testAccumulated(t, n, expected, CiphertextSize1024,
+			func(d, z *[32]byte) ([]byte, []byte, func([]byte) ([]byte, []byte), func([]byte) []byte) {
+				dk := &DecapsulationKey1024{}
+				kemKeyGen1024(dk, d, z)
+				ek := dk.EncapsulationKey()
+				encaps := func(m []byte) ([]byte, []byte) {
+					return kemEncaps1024(nil, ek, (*[messageSize]byte)(m))
+				}
+				decaps := func(c []byte) []byte {
+					return kemDecaps1024(dk, (*[CiphertextSize1024]byte)(c))
+				}
+				return ek.Bytes(), expandedBytes1024(dk), encaps, decaps
+			})
+	})
+}
+
+func testAccumulated(t *testing.T, n int, expected string, ciphertextSize int,
+	keyGen func(d, z *[32]byte) (ek, dk []byte, encaps func(m []byte) (K, c []byte), decaps func(c []byte) []byte)) {
+	s := sha3.NewSHAKE128()
+	o := sha3.NewSHAKE128()
+	var d, z [32]byte
+	msg := make([]byte, messageSize)
+	ct1 := make([]byte, ciphertextSize)
+
+	for i := 0; i < n; i++ {
+		s.Read(d[:])
+		s.Read(z[:])
+		ek, dk, encaps, decaps := keyGen(&d, &z)
+		o.Write(ek)
+		o.Write(dk)
+
+		s.Read(msg)
+		k, ct := encaps(msg)
+		o.Write(ct)
+		o.Write(k)
+
+		if kk := decaps(ct); !bytes.Equal(kk, k) {
+			t.Errorf("k: got %x, expected %x", kk, k)
+		}
+
+		s.Read(ct1)
+		o.Write(decaps(ct1))
+	}
+
+	got := hex.EncodeToString(o.Sum(nil))
+	if got != expected {
+		t.Errorf("got %s, expected %s", got, expected)
+	}
+}
+
+var sink byte
+
+func BenchmarkKeyGen(b *testing.B) {
+	var dk DecapsulationKey768
+	var d, z [32]byte
+	rand.Read(d[:])
+	rand.Read(z[:])
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		kemKeyGen(&dk, &d, &z)
+		sink ^= dk.h[0]
+	}
+}
+
+func BenchmarkParseEncapsulationKey(b *testing.B) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		b.Fatal(err)
+	}
+	ekBytes := dk.EncapsulationKey().Bytes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ek, err := NewEncapsulationKey768(ekBytes)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink ^= ek.h[0]
+	}
+}
+
+func BenchmarkEncaps(b *testing.B) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		b.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	var m [messageSize]byte
+	rand.Read(m[:])
+	var c [CiphertextSize768]byte
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		K, c := kemEncaps(&c, ek, &m)
+		sink ^= c[0] ^ K[0]
+	}
+}
+
+func BenchmarkDecaps(b *testing.B) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, c := dk.EncapsulationKey().Encapsulate()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		K := kemDecaps(dk, (*[CiphertextSize768]byte)(c))
+		sink ^= K[0]
+	}
+}
+
+func BenchmarkRoundTrip(b *testing.B) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		b.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	ekBytes := ek.Bytes()
+	_, c := ek.Encapsulate()
+	b.Run("Alice", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dkS, err := GenerateKey768()
+			if err != nil {
+				b.Fatal(err)
+			}
+			ekS := dkS.EncapsulationKey().Bytes()
+			sink ^= ekS[0]
+
+			Ks, err := dk.Decapsulate(c)
+			if err != nil {
+				b.Fatal(err)
+			}
+			sink ^= Ks[0]
+		}
+	})
+	b.Run("Bob", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ek, err := NewEncapsulationKey768(ekBytes)
+			if err != nil {
+				b.Fatal(err)
+			}
+			Ks, cS := ek.Encapsulate()
+			sink ^= cS[0] ^ Ks[0]
+		}
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mlkem
+
+import (
+	"bytes"
+	"crypto/internal/mlkem"
+	"testing"
+)
+
+func TestSizes(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		got, want int
+	}{
+		{"SharedKeySize", SharedKeySize, mlkem.SharedKeySize},
+		{"SeedSize", SeedSize, mlkem.SeedSize},
+		{"CiphertextSize768", CiphertextSize768, mlkem.CiphertextSize768},
+		{"EncapsulationKeySize768", EncapsulationKeySize768, mlkem.EncapsulationKeySize768},
+		{"CiphertextSize1024", CiphertextSize1024, mlkem.CiphertextSize1024},
+		{"EncapsulationKeySize1024", EncapsulationKeySize1024, mlkem.EncapsulationKeySize1024},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRoundTrip768(t *testing.T) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dk.Bytes()) != SeedSize {
+		t.Errorf("len(dk.Bytes()) = %d, want %d", len(dk.Bytes()), SeedSize)
+	}
+	ekBytes := dk.EncapsulationKey().Bytes()
+	if len(ekBytes) != EncapsulationKeySize768 {
+		t.Errorf("len(ek.Bytes()) = %d, want %d", len(ekBytes), EncapsulationKeySize768)
+	}
+	ek, err := NewEncapsulationKey768(ekBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Ke, c := ek.Encapsulate()
+	if len(Ke) != SharedKeySize || len(c) != CiphertextSize768 {
+		t.Errorf("Encapsulate returned %d and %d bytes, want %d and %d",
+			len(Ke), len(c), SharedKeySize, CiphertextSize768)
+	}
+
+	dk1, err := NewDecapsulationKey768(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dk1.EncapsulationKey().Bytes(), ekBytes) {
+		t.Error("decapsulation key from seed has a different encapsulation key")
+	}
+	Kd, err := dk1.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd) {
+		t.Errorf("shared keys differ: %x != %x", Ke, Kd)
+	}
+}
+
+func TestRoundTrip1024(t *testing.T) {
+	dk, err := GenerateKey1024()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dk.Bytes()) != SeedSize {
+		t.Errorf("len(dk.Bytes()) = %d, want %d", len(dk.Bytes()), SeedSize)
+	}
+	ekBytes := dk.EncapsulationKey().Bytes()
+	if len(ekBytes) != EncapsulationKeySize1024 {
+		t.Errorf("len(ek.Bytes()) = %d, want %d", len(ekBytes), EncapsulationKeySize1024)
+	}
+	ek, err := NewEncapsulationKey1024(ekBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Ke, c := ek.Encapsulate()
+	if len(Ke) != SharedKeySize || len(c) != CiphertextSize1024 {
+		t.Errorf("Encapsulate returned %d and %d bytes, want %d and %d",
+			len(Ke), len(c), SharedKeySize, CiphertextSize1024)
+	}
+
+	dk1, err := NewDecapsulationKey1024(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dk1.EncapsulationKey().Bytes(), ekBytes) {
+		t.Error("decapsulation key from seed has a different encapsulation key")
+	}
+	Kd, err := dk1.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd) {
+		t.Errorf("shared keys differ: %x != %x", Ke, Kd)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2012 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pbkdf2_test
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+// pbkdf2Test is modeled after the Wycheproof PBKDF2 test vector format.
+type pbkdf2Test struct {
+	tcID       int
+	comment    string
+	hash       func() hash.Hash
+	password   string
+	salt       string
+	iterations int
+	dkLen      int
+	dk         string
+	result     string // "valid" or "invalid"
+}
+
+var pbkdf2Tests = []pbkdf2Test{
+	// Test vectors from RFC 6070.
+	{1, "RFC 6070", sha1.New, "password", "salt", 1, 20,
+		"0c60c80f961f0e71f3a9b524af6012062fe037a6", "valid"},
+	{2, "RFC 6070", sha1.New, "password", "salt", 2, 20,
+		"ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957", "valid"},
+	{3, "RFC 6070", sha1.New, "password", "salt", 4096, 20,
+		"4b007901b765489abead49d926f721d065a429c1", "valid"},
+	{4, "RFC 6070", sha1.New, "passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 25,
+		"3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038", "valid"},
+	{5, "RFC 6070", sha1.New, "pass\000word", "sa\000lt", 4096, 16,
+		"56fa6aa75548099dcc37d7f03425e0c3", "valid"},
+
+	// The same inputs with HMAC-SHA-256.
+	{6, "SHA-256", sha256.New, "password", "salt", 1, 32,
+		"120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b", "valid"},
+	{7, "SHA-256", sha256.New, "password", "salt", 2, 32,
+		"ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43", "valid"},
+	{8, "SHA-256", sha256.New, "password", "salt", 4096, 32,
+		"c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a", "valid"},
+	{9, "SHA-256", sha256.New, "passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 40,
+		"348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9", "valid"},
+	{10, "SHA-256", sha256.New, "pass\000word", "sa\000lt", 4096, 16,
+		"89b69d0516f829893c696226650a8687", "valid"},
+	{11, "empty password", sha256.New, "", "salt", 1, 32,
+		"f135c27993baf98773c5cdb40a5706ce6a345cde61b000a67858650cd6a324d7", "valid"},
+	{12, "empty salt", sha256.New, "password", "", 1, 32,
+		"c1232f10f62715fda06ae7c0a2037ca19b33cf103b727ba56d870c11f290a2ab", "valid"},
+	{13, "output spans three blocks", sha256.New, "password", "salt", 1, 65,
+		"120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b4dbf3a2f3dad3377264bb7b8e8330d4efc7451418617dabef683735361cdc18c22", "valid"},
+	{14, "SHA-512", sha512.New, "password", "salt", 1000, 64,
+		"afe6c5530785b6cc6b1c6453384731bd5ee432ee549fd42fb6695779ad8a1c5bf59de69c48f774efc4007d5298f9033c0241d5ab69305e7b64eceeb8d834cfec", "valid"},
+
+	{15, "zero key length", sha256.New, "password", "salt", 1, 0, "", "invalid"},
+	{16, "negative key length", sha256.New, "password", "salt", 1, -1, "", "invalid"},
+	{17, "zero iterations", sha256.New, "password", "salt", 0, 32, "", "invalid"},
+}
+
+func TestPBKDF2(t *testing.T) {
+	for _, tt := range pbkdf2Tests {
+		dk, err := pbkdf2.Key(tt.hash, tt.password, []byte(tt.salt), tt.iterations, tt.dkLen)
+		switch tt.result {
+		case "valid":
+			if err != nil {
+				t.Errorf("tcId %d (%s): unexpected error: %v", tt.tcID, tt.comment, err)
+			} else if got := hex.EncodeToString(dk); got != tt.dk {
+				t.Errorf("tcId %d (%s): got %s, want %s", tt.tcID, tt.comment, got, tt.dk)
+			}
+		case "invalid":
+			if err == nil {
+				t.Errorf("tcId %d (%s): succeeded, want error", tt.tcID, tt.comment)
+			}
+		default:
+			t.Fatalf("tcId %d: unknown result %q", tt.tcID, tt.result)
+		}
+	}
+}
+
+var sink uint8
+
+func benchmark(b *testing.B, h func() hash.Hash) {
+	var err error
+	password := make([]byte, h().Size())
+	salt := make([]byte, 8)
+	for i := 0; i < b.N; i++ {
+		password, err = pbkdf2.Key(h, string(password), salt, 4096, len(password))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	sink += password[0]
+}
+
+func BenchmarkHMACSHA1(b *testing.B) {
+	benchmark(b, sha1.New)
+}
+
+func BenchmarkHMACSHA256(b *testing.B) {
+	benchmark(b, sha256.New)
+}
@@ -0,0 +1,42 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build s390x && !purego
+
+package sha3
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestGenericPath checks that the generic code path, used when the KIMD and
+// KLMD instructions are unavailable, agrees with the CPACF implementation.
+func TestGenericPath(t *testing.T) {
+	if !useSHA3 {
+		t.Skipf("assembly implementation unavailable")
+	}
+	in := bytes.Repeat([]byte("abc"), 1000)
+	hash := func() []byte {
+		var out []byte
+		for _, h := range []interface {
+			Write([]byte) (int, error)
+			Sum([]byte) []byte
+		}{New224(), New256(), New384(), New512(), NewSHAKE128(), NewSHAKE256()} {
+			h.Write(in)
+			out = h.Sum(out)
+		}
+		s := NewSHAKE256()
+		s.Write(in)
+		xof := make([]byte, 1000)
+		s.Read(xof)
+		return append(out, xof...)
+	}
+	want := hash()
+	useSHA3 = false
+	defer func() { useSHA3 = true }()
+	if got := hash(); !bytes.Equal(got, want) {
+		t.Errorf("generic output %x, want %x", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sha3
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestKeccakF1600Generic checks that keccakF1600, which may be implemented
+// in assembly, agrees with the generic implementation.
+func TestKeccakF1600Generic(t *testing.T) {
+	var a, b [200]byte
+	for i := range a {
+		a[i] = byte(i * 7)
+	}
+	b = a
+	for i := 0; i < 100; i++ {
+		keccakF1600(&a)
+		keccakF1600Generic(&b)
+		if !bytes.Equal(a[:], b[:]) {
+			t.Fatalf("permutation %d: keccakF1600 = %x, keccakF1600Generic = %x", i, a, b)
+		}
+	}
+}
+
+func BenchmarkPermutationFunction(b *testing.B) {
+	b.SetBytes(int64(len(state{}.a)))
+	var a [200]byte
+	for i := 0; i < b.N; i++ {
+		keccakF1600(&a)
+	}
+}
+
+func BenchmarkPermutationFunctionGeneric(b *testing.B) {
+	b.SetBytes(int64(len(state{}.a)))
+	var a [200]byte
+	for i := 0; i < b.N; i++ {
+		keccakF1600Generic(&a)
+	}
+}
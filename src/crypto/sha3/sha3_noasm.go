@@ -0,0 +1,21 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build (!amd64 && !s390x) || purego
+
+package sha3
+
+func keccakF1600(a *[200]byte) {
+	keccakF1600Generic(a)
+}
+
+func (d *state) write(p []byte) (n int, err error) {
+	return d.writeGeneric(p)
+}
+func (d *state) read(out []byte) (n int, err error) {
+	return d.readGeneric(out)
+}
+func (d *state) sum(b []byte) []byte {
+	return d.sumGeneric(b)
+}
@@ -0,0 +1,367 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sha3_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha3"
+	"encoding"
+	"encoding/hex"
+	"hash"
+	"io"
+	"testing"
+)
+
+const testString = "brekeccakkeccak koax koax"
+
+var testDigests = map[string]func() hash.Hash{
+	"SHA3-224": func() hash.Hash { return sha3.New224() },
+	"SHA3-256": func() hash.Hash { return sha3.New256() },
+	"SHA3-384": func() hash.Hash { return sha3.New384() },
+	"SHA3-512": func() hash.Hash { return sha3.New512() },
+	"SHAKE128": func() hash.Hash { return sha3.NewSHAKE128() },
+	"SHAKE256": func() hash.Hash { return sha3.NewSHAKE256() },
+	"cSHAKE128": func() hash.Hash {
+		return sha3.NewCSHAKE128([]byte("function"), []byte("customization"))
+	},
+	"cSHAKE256": func() hash.Hash {
+		return sha3.NewCSHAKE256([]byte("function"), []byte("customization"))
+	},
+}
+
+var golden = []struct {
+	name string
+	in   string
+	out  string
+}{
+	{"SHA3-224", "", "6b4e03423667dbb73b6e15454f0eb1abd4597f9a1b078e3f5b5a6bc7"},
+	{"SHA3-256", "", "a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a"},
+	{"SHA3-384", "", "0c63a75b845e4f7d01107d852e4c2485c51a50aaaa94fc61995e71bbee983a2ac3713831264adb47fb6bd1e058d5f004"},
+	{"SHA3-512", "", "a69f73cca23a9ac5c8b567dc185a756e97c982164fe25859e0d1dcc1475c80a615b2123af1f5f94c11e3e9402c3ac558f500199d95b6d3e301758586281dcd26"},
+	{"SHA3-256", "abc", "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+	{"SHA3-512", "abc", "b751850b1a57168a5693cd924b6b096e08f621827444f70d884f5d0240d2712e10e116e9192af3c91a7ec57647e3934057340b4cf408d5a56592f8274eec53f0"},
+	{"SHA3-256", "abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq", "41c0dba2a9d6240849100376a8235e2c82e1b9998a999e21db32dd97496d3376"},
+	{"SHAKE128", "", "7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26"},
+	{"SHAKE256", "", "46b9dd2b0ba88d13233b3feb743eeb243fcd52ea62b81b82b50c27646ed5762fd75dc4ddd8c0f200cb05019d67b592f6fc821c49479ab48640292eacb3b7c4be"},
+}
+
+func TestGolden(t *testing.T) {
+	for _, g := range golden {
+		h := testDigests[g.name]()
+		h.Write([]byte(g.in))
+		if got := hex.EncodeToString(h.Sum(nil)); got != g.out {
+			t.Errorf("%s(%q) = %s, want %s", g.name, g.in, got, g.out)
+		}
+	}
+
+	if got := sha3.Sum256([]byte("abc")); hex.EncodeToString(got[:]) != golden[4].out {
+		t.Errorf("Sum256(%q) = %x, want %s", "abc", got, golden[4].out)
+	}
+	if got := sha3.SumSHAKE256(nil, 64); hex.EncodeToString(got) != golden[8].out {
+		t.Errorf("SumSHAKE256(nil, 64) = %x, want %s", got, golden[8].out)
+	}
+}
+
+// TestCSHAKE checks the cSHAKE samples from NIST SP 800-185.
+func TestCSHAKE(t *testing.T) {
+	data, _ := hex.DecodeString("00010203")
+	tests := []struct {
+		newXOF func(N, S []byte) *sha3.SHAKE
+		out    string
+	}{
+		{sha3.NewCSHAKE128, "c1c36925b6409a04f1b504fcbca9d82b4017277cb5ed2b2065fc1d3814d5aaf5"},
+		{sha3.NewCSHAKE256, "d008828e2b80ac9d2218ffee1d070c48b8e4c87bff32c9699d5b6896eee0edd164020e2be0560858d9c00c037e34a96937c561a74c412bb4c746469527281c8c"},
+	}
+	for _, tt := range tests {
+		c := tt.newXOF(nil, []byte("Email Signature"))
+		c.Write(data)
+		out := make([]byte, len(tt.out)/2)
+		c.Read(out)
+		if got := hex.EncodeToString(out); got != tt.out {
+			t.Errorf("got %s, want %s", got, tt.out)
+		}
+
+		c.Reset()
+		c.Write(data)
+		c.Read(out)
+		if got := hex.EncodeToString(out); got != tt.out {
+			t.Errorf("after Reset: got %s, want %s", got, tt.out)
+		}
+	}
+
+	if !bytes.Equal(sha3.NewCSHAKE128(nil, nil).Sum(nil), sha3.NewSHAKE128().Sum(nil)) {
+		t.Error("cSHAKE128 with empty N and S is not SHAKE128")
+	}
+	if !bytes.Equal(sha3.NewCSHAKE256(nil, nil).Sum(nil), sha3.NewSHAKE256().Sum(nil)) {
+		t.Error("cSHAKE256 with empty N and S is not SHAKE256")
+	}
+}
+
+// TestUnalignedWrite tests that writing data in an arbitrary pattern with
+// small input buffers produces the same result as a single write.
+func TestUnalignedWrite(t *testing.T) {
+	buf := sequentialBytes(0x10000)
+	for name, newHash := range testDigests {
+		d := newHash()
+		d.Write(buf)
+		want := d.Sum(nil)
+
+		d.Reset()
+		for i := 0; i < len(buf); {
+			// Cycle through offsets which make a 137 byte sequence.
+			// Because 137 is prime this sequence should exercise all corner cases.
+			offsets := [17]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 1}
+			for _, j := range offsets {
+				if v := len(buf) - i; v < j {
+					j = v
+				}
+				d.Write(buf[i : i+j])
+				i += j
+			}
+		}
+		if got := d.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("%s: unaligned writes = %x, want %x", name, got, want)
+		}
+	}
+}
+
+// TestSqueezing checks that squeezing the full output a single time produces
+// the same output as repeatedly squeezing the instance.
+func TestSqueezing(t *testing.T) {
+	for name, newHash := range testDigests {
+		xof, ok := newHash().(*sha3.SHAKE)
+		if !ok {
+			continue
+		}
+		xof.Write([]byte(testString))
+		want := make([]byte, 1000)
+		xof.Read(want)
+
+		xof.Reset()
+		xof.Write([]byte(testString))
+		var got []byte
+		one := make([]byte, 1)
+		for range want {
+			xof.Read(one)
+			got = append(got, one...)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: byte-at-a-time squeeze = %x, want %x", name, got, want)
+		}
+	}
+}
+
+func TestSumAfterRead(t *testing.T) {
+	h := sha3.NewSHAKE128()
+	h.Write([]byte(testString))
+	h.Read(make([]byte, 1))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Sum after Read did not panic")
+		}
+	}()
+	h.Sum(nil)
+}
+
+func TestWriteAfterRead(t *testing.T) {
+	h := sha3.NewSHAKE256()
+	h.Read(make([]byte, 1))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Write after Read did not panic")
+		}
+	}()
+	h.Write([]byte(testString))
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	for name, newHash := range testDigests {
+		for _, n := range []int{0, 1, 71, 72, 135, 136, 168, 169, 300} {
+			h := newHash()
+			h.Write(sequentialBytes(n))
+			state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+			if err != nil {
+				t.Fatalf("%s: MarshalBinary: %v", name, err)
+			}
+			appended, err := h.(encoding.BinaryAppender).AppendBinary([]byte("prefix"))
+			if err != nil {
+				t.Fatalf("%s: AppendBinary: %v", name, err)
+			}
+			if !bytes.Equal(appended[len("prefix"):], state) {
+				t.Errorf("%s: AppendBinary and MarshalBinary disagree", name)
+			}
+			h.Write([]byte(testString))
+			want := h.Sum(nil)
+
+			h2 := newHash()
+			if err := h2.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+				t.Fatalf("%s: UnmarshalBinary: %v", name, err)
+			}
+			h2.Write([]byte(testString))
+			if got := h2.Sum(nil); !bytes.Equal(got, want) {
+				t.Errorf("%s (%d bytes): restored state = %x, want %x", name, n, got, want)
+			}
+		}
+	}
+
+	// Also check a SHAKE instance that is already squeezing.
+	h := sha3.NewCSHAKE128([]byte("N"), []byte("S"))
+	h.Write([]byte(testString))
+	h.Read(make([]byte, 200))
+	state, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, 100)
+	h.Read(want)
+	h2 := sha3.NewCSHAKE128([]byte("N"), []byte("S"))
+	if err := h2.UnmarshalBinary(state); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 100)
+	h2.Read(got)
+	if !bytes.Equal(got, want) {
+		t.Errorf("restored squeezing state = %x, want %x", got, want)
+	}
+}
+
+func TestMarshalTypeMismatch(t *testing.T) {
+	state, err := sha3.New256().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sha3.New512().UnmarshalBinary(state); err == nil {
+		t.Error("SHA3-512 accepted a SHA3-256 state")
+	}
+	if err := sha3.NewSHAKE256().UnmarshalBinary(state); err == nil {
+		t.Error("SHAKE256 accepted a SHA3-256 state")
+	}
+	if err := sha3.New256().UnmarshalBinary(state[:len(state)-1]); err == nil {
+		t.Error("SHA3-256 accepted a truncated state")
+	}
+}
+
+func TestSizes(t *testing.T) {
+	tests := []struct {
+		h         hash.Hash
+		size      int
+		blockSize int
+	}{
+		{sha3.New224(), 28, 144},
+		{sha3.New256(), 32, 136},
+		{sha3.New384(), 48, 104},
+		{sha3.New512(), 64, 72},
+		{sha3.NewSHAKE128(), 32, 168},
+		{sha3.NewSHAKE256(), 64, 136},
+	}
+	for _, tt := range tests {
+		if got := tt.h.Size(); got != tt.size {
+			t.Errorf("%T Size() = %d, want %d", tt.h, got, tt.size)
+		}
+		if got := tt.h.BlockSize(); got != tt.blockSize {
+			t.Errorf("%T BlockSize() = %d, want %d", tt.h, got, tt.blockSize)
+		}
+		if got := len(tt.h.Sum(nil)); got != tt.size {
+			t.Errorf("%T Sum length = %d, want %d", tt.h, got, tt.size)
+		}
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	for _, h := range []crypto.Hash{crypto.SHA3_224, crypto.SHA3_256, crypto.SHA3_384, crypto.SHA3_512} {
+		if !h.Available() {
+			t.Errorf("%v is not available", h)
+			continue
+		}
+		if got := h.New().Size(); got != h.Size() {
+			t.Errorf("%v.New().Size() = %d, want %d", h, got, h.Size())
+		}
+	}
+}
+
+func TestAllocations(t *testing.T) {
+	in := []byte(testString)
+	out := make([]byte, 64)
+	if n := testing.AllocsPerRun(10, func() {
+		h := sha3.New256()
+		h.Write(in)
+		h.Sum(out[:0])
+	}); n > 0 {
+		t.Errorf("New256: allocs = %v, want 0", n)
+	}
+	if n := testing.AllocsPerRun(10, func() {
+		h := sha3.NewSHAKE128()
+		h.Write(in)
+		h.Read(out)
+	}); n > 0 {
+		t.Errorf("NewSHAKE128: allocs = %v, want 0", n)
+	}
+	if n := testing.AllocsPerRun(10, func() {
+		sha3.Sum512(in)
+		sha3.SumSHAKE256(in, 64)
+	}); n > 0 {
+		t.Errorf("Sum: allocs = %v, want 0", n)
+	}
+}
+
+var _ io.Reader = (*sha3.SHAKE)(nil)
+
+func sequentialBytes(size int) []byte {
+	result := make([]byte, size)
+	for i := range result {
+		result[i] = byte(i)
+	}
+	return result
+}
+
+func benchmarkHash(b *testing.B, h hash.Hash, size, num int) {
+	b.StopTimer()
+	h.Reset()
+	data := sequentialBytes(size)
+	b.SetBytes(int64(size * num))
+	b.StartTimer()
+
+	var state []byte
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < num; j++ {
+			h.Write(data)
+		}
+		state = h.Sum(state[:0])
+	}
+	b.StopTimer()
+	h.Reset()
+}
+
+func BenchmarkSha3_512_MTU(b *testing.B) { benchmarkHash(b, sha3.New512(), 1350, 1) }
+func BenchmarkSha3_384_MTU(b *testing.B) { benchmarkHash(b, sha3.New384(), 1350, 1) }
+func BenchmarkSha3_256_MTU(b *testing.B) { benchmarkHash(b, sha3.New256(), 1350, 1) }
+func BenchmarkSha3_224_MTU(b *testing.B) { benchmarkHash(b, sha3.New224(), 1350, 1) }
+
+func BenchmarkShake128_MTU(b *testing.B)  { benchmarkShake(b, sha3.NewSHAKE128(), 1350, 1) }
+func BenchmarkShake256_MTU(b *testing.B)  { benchmarkShake(b, sha3.NewSHAKE256(), 1350, 1) }
+func BenchmarkShake256_16x(b *testing.B)  { benchmarkShake(b, sha3.NewSHAKE256(), 16, 1024) }
+func BenchmarkShake256_1MiB(b *testing.B) { benchmarkShake(b, sha3.NewSHAKE256(), 1024, 1024) }
+
+func BenchmarkSha3_512_1MiB(b *testing.B) { benchmarkHash(b, sha3.New512(), 1024, 1024) }
+
+func benchmarkShake(b *testing.B, h *sha3.SHAKE, size, num int) {
+	b.StopTimer()
+	h.Reset()
+	data := sequentialBytes(size)
+	d := make([]byte, 32)
+	b.SetBytes(int64(size * num))
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		h.Reset()
+		for j := 0; j < num; j++ {
+			h.Write(data)
+		}
+		h.Read(d)
+	}
+}
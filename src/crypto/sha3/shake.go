@@ -0,0 +1,170 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sha3
+
+import (
+	"bytes"
+	"errors"
+	"internal/byteorder"
+	"math/bits"
+)
+
+// SHAKE is an instance of a SHAKE or cSHAKE extendable output function.
+//
+// It implements [io.Reader], to read an arbitrary amount of output, and
+// [hash.Hash]. When used as a plain hash.Hash, it produces minimum-length
+// outputs that provide full-strength generic security: 32 bytes for
+// SHAKE128 and 64 bytes for SHAKE256.
+type SHAKE struct {
+	s state
+
+	// initBlock is the cSHAKE specific initialization set of bytes. It is
+	// initialized by newCShake and stores the concatenation of N followed by
+	// S, encoded by the method specified in SP 800-185, Section 3.3. It is
+	// stored here in order for Reset to be able to put context into the
+	// initial state.
+	initBlock []byte
+}
+
+func bytepad(data []byte, rate int) []byte {
+	out := make([]byte, 0, 9+len(data)+rate-1)
+	out = append(out, leftEncode(uint64(rate))...)
+	out = append(out, data...)
+	if padlen := rate - len(out)%rate; padlen < rate {
+		out = append(out, make([]byte, padlen)...)
+	}
+	return out
+}
+
+func leftEncode(x uint64) []byte {
+	// Let n be the smallest positive integer for which 2^(8n) > x.
+	n := (bits.Len64(x) + 7) / 8
+	if n == 0 {
+		n = 1
+	}
+	// Return n || x with n as a byte and x an n bytes in big-endian order.
+	b := make([]byte, 9)
+	byteorder.BePutUint64(b[1:], x)
+	b = b[9-n-1:]
+	b[0] = byte(n)
+	return b
+}
+
+func newCShake(N, S []byte, rate, outputLen int) *SHAKE {
+	c := &SHAKE{s: state{rate: rate, outputLen: outputLen, dsbyte: dsbyteCShake}}
+	c.initBlock = make([]byte, 0, 9+len(N)+9+len(S)) // leftEncode returns max 9 bytes
+	c.initBlock = append(c.initBlock, leftEncode(uint64(len(N))*8)...)
+	c.initBlock = append(c.initBlock, N...)
+	c.initBlock = append(c.initBlock, leftEncode(uint64(len(S))*8)...)
+	c.initBlock = append(c.initBlock, S...)
+	c.Write(bytepad(c.initBlock, c.s.rate))
+	return c
+}
+
+// NewSHAKE128 creates a new SHAKE128 XOF.
+// Its generic security strength is 128 bits against all attacks if at
+// least 32 bytes of its output are used.
+func NewSHAKE128() *SHAKE {
+	return &SHAKE{s: state{rate: rateK256, outputLen: 32, dsbyte: dsbyteShake}}
+}
+
+// NewSHAKE256 creates a new SHAKE256 XOF.
+// Its generic security strength is 256 bits against all attacks if
+// at least 64 bytes of its output are used.
+func NewSHAKE256() *SHAKE {
+	return &SHAKE{s: state{rate: rateK512, outputLen: 64, dsbyte: dsbyteShake}}
+}
+
+// NewCSHAKE128 creates a new cSHAKE128 XOF.
+//
+// N is used to define functions based on cSHAKE, it can be empty when plain
+// cSHAKE is desired. S is a customization byte string used for domain
+// separation. When N and S are both empty, this is equivalent to NewSHAKE128.
+func NewCSHAKE128(N, S []byte) *SHAKE {
+	if len(N) == 0 && len(S) == 0 {
+		return NewSHAKE128()
+	}
+	return newCShake(N, S, rateK256, 32)
+}
+
+// NewCSHAKE256 creates a new cSHAKE256 XOF.
+//
+// N is used to define functions based on cSHAKE, it can be empty when plain
+// cSHAKE is desired. S is a customization byte string used for domain
+// separation. When N and S are both empty, this is equivalent to NewSHAKE256.
+func NewCSHAKE256(N, S []byte) *SHAKE {
+	if len(N) == 0 && len(S) == 0 {
+		return NewSHAKE256()
+	}
+	return newCShake(N, S, rateK512, 64)
+}
+
+// Write absorbs more data into the XOF's state.
+//
+// It panics if any output has already been read.
+func (s *SHAKE) Write(p []byte) (n int, err error) {
+	return s.s.write(p)
+}
+
+// Read squeezes more output from the XOF.
+//
+// Any call to Write after a call to Read will panic.
+func (s *SHAKE) Read(p []byte) (n int, err error) {
+	return s.s.read(p)
+}
+
+// Sum appends the minimum-length output that provides full-strength generic
+// security to b and returns the resulting slice. It does not change the
+// underlying state.
+//
+// It panics if any output has already been read.
+func (s *SHAKE) Sum(b []byte) []byte {
+	return s.s.sum(b)
+}
+
+// Reset resets the XOF to its initial state.
+func (s *SHAKE) Reset() {
+	s.s.reset()
+	if len(s.initBlock) != 0 {
+		s.Write(bytepad(s.initBlock, s.s.rate))
+	}
+}
+
+// Size returns the number of bytes Sum will produce.
+func (s *SHAKE) Size() int {
+	return s.s.size()
+}
+
+// BlockSize returns the rate of the XOF.
+func (s *SHAKE) BlockSize() int {
+	return s.s.blockSize()
+}
+
+// MarshalBinary implements [encoding.BinaryMarshaler].
+func (s *SHAKE) MarshalBinary() ([]byte, error) {
+	return s.AppendBinary(make([]byte, 0, marshaledSize+len(s.initBlock)))
+}
+
+// AppendBinary implements [encoding.BinaryAppender].
+func (s *SHAKE) AppendBinary(b []byte) ([]byte, error) {
+	b, err := s.s.appendBinary(b)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, s.initBlock...)
+	return b, nil
+}
+
+// UnmarshalBinary implements [encoding.BinaryUnmarshaler].
+func (s *SHAKE) UnmarshalBinary(b []byte) error {
+	if len(b) < marshaledSize {
+		return errors.New("crypto/sha3: invalid hash state")
+	}
+	if err := s.s.unmarshalBinary(b[:marshaledSize]); err != nil {
+		return err
+	}
+	s.initBlock = bytes.Clone(b[marshaledSize:])
+	return nil
+}
@@ -149,10 +149,10 @@ const (
 	CurveP521 CurveID = 25
 	X25519    CurveID = 29
 
-	// Experimental codepoint for X25519Kyber768Draft00, specified in
-	// draft-tls-westerbaan-xyber768d00-03. Not exported, as support might be
-	// removed in the future.
-	x25519Kyber768Draft00 CurveID = 0x6399 // X25519Kyber768Draft00
+	// X25519MLKEM768 is the hybrid post-quantum key exchange that combines
+	// X25519 and ML-KEM-768, specified in draft-kwiatkowski-tls-ecdhe-mlkem-02.
+	// It is only supported in TLS 1.3.
+	X25519MLKEM768 CurveID = 4588
 )
 
 // TLS 1.3 Key Share. See RFC 8446, Section 4.2.8.
@@ -290,8 +290,7 @@ type ConnectionState struct {
 	TLSUnique []byte
 
 	// ECHAccepted indicates if Encrypted Client Hello was offered by the client
-	// and accepted by the server. Currently, ECH is supported only on the
-	// client side.
+	// and accepted by the server.
 	ECHAccepted bool
 
 	// ekm is a closure exposed via ExportKeyingMaterial.
@@ -765,9 +764,9 @@ type Config struct {
 	// be used. The client will use the first preference as the type for
 	// its key share in TLS 1.3. This may change in the future.
 	//
-	// From Go 1.23, the default includes the X25519Kyber768Draft00 hybrid
+	// From Go 1.24, the default includes the [X25519MLKEM768] hybrid
 	// post-quantum key exchange. To disable it, set CurvePreferences explicitly
-	// or use the GODEBUG=tlskyber=0 environment variable.
+	// or use the GODEBUG=tlsmlkem=0 environment variable.
 	CurvePreferences []CurveID
 
 	// DynamicRecordSizingDisabled disables adaptive sizing of TLS records.
@@ -790,8 +789,7 @@ type Config struct {
 
 	// EncryptedClientHelloConfigList is a serialized ECHConfigList. If
 	// provided, clients will attempt to connect to servers using Encrypted
-	// Client Hello (ECH) using one of the provided ECHConfigs. Servers
-	// currently ignore this field.
+	// Client Hello (ECH) using one of the provided ECHConfigs.
 	//
 	// If the list contains no valid ECH configs, the handshake will fail
 	// and return an error.
@@ -823,6 +821,17 @@ type Config struct {
 	// when ECH is rejected, even if set, and InsecureSkipVerify is ignored.
 	EncryptedClientHelloRejectionVerify func(ConnectionState) error
 
+	// EncryptedClientHelloKeys are the ECH keys to use when a client
+	// attempts ECH.
+	//
+	// If a client attempts ECH, but it is rejected by the server, the server
+	// will send a list of configs to retry based on the set of
+	// EncryptedClientHelloKeys which have the SendAsRetry field set.
+	//
+	// On the server, if EncryptedClientHelloKeys is set, the server will
+	// ignore the EncryptedClientHelloConfigList field.
+	EncryptedClientHelloKeys []EncryptedClientHelloKey
+
 	// mutex protects sessionTicketKeys and autoSessionTicketKeys.
 	mutex sync.RWMutex
 	// sessionTicketKeys contains zero or more ticket keys. If set, it means
@@ -836,6 +845,27 @@ type Config struct {
 	autoSessionTicketKeys []ticketKey
 }
 
+// EncryptedClientHelloKey holds a private key that is associated
+// with a specific ECH config known to a client.
+type EncryptedClientHelloKey struct {
+	// Config should be a marshalled ECHConfig associated with PrivateKey. This
+	// must match the config provided to clients byte-for-byte. The config ID
+	// the client uses to select this key is taken from Config.
+	//
+	// The config should only specify the DHKEM(X25519, HKDF-SHA256) KEM ID
+	// (0x0020), the HKDF-SHA256 KDF ID (0x0001), and a subset of the
+	// following AEAD IDs: AES-128-GCM (0x0001), AES-256-GCM (0x0002),
+	// ChaCha20Poly1305 (0x0003).
+	Config []byte
+	// PrivateKey should be a marshalled private key. Currently, we expect
+	// this to be the output of [crypto/ecdh.PrivateKey.Bytes].
+	PrivateKey []byte
+	// SendAsRetry indicates if Config should be sent as part of the list of
+	// retry configs when ECH is requested by the client but rejected by the
+	// server.
+	SendAsRetry bool
+}
+
 const (
 	// ticketKeyLifetime is how long a ticket key remains valid and can be used to
 	// resume a client connection.
@@ -912,6 +942,7 @@ func (c *Config) Clone() *Config {
 		KeyLogWriter:                        c.KeyLogWriter,
 		EncryptedClientHelloConfigList:      c.EncryptedClientHelloConfigList,
 		EncryptedClientHelloRejectionVerify: c.EncryptedClientHelloRejectionVerify,
+		EncryptedClientHelloKeys:            c.EncryptedClientHelloKeys,
 		sessionTicketKeys:                   c.sessionTicketKeys,
 		autoSessionTicketKeys:               c.autoSessionTicketKeys,
 	}
@@ -1152,7 +1183,7 @@ func (c *Config) curvePreferences(version uint16) []CurveID {
 	}
 	if version < VersionTLS13 {
 		return slices.DeleteFunc(curvePreferences, func(c CurveID) bool {
-			return c == x25519Kyber768Draft00
+			return c == X25519MLKEM768
 		})
 	}
 	return curvePreferences
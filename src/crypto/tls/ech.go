@@ -5,8 +5,11 @@
 package tls
 
 import (
+	"bytes"
 	"crypto/internal/hpke"
 	"errors"
+	"fmt"
+	"slices"
 	"strings"
 
 	"golang.org/x/crypto/cryptobyte"
@@ -40,12 +43,83 @@ type echConfig struct {
 
 var errMalformedECHConfig = errors.New("tls: malformed ECHConfigList")
 
+// parseECHConfig parses a single draft-ietf-tls-esni-18 ECHConfig. If the
+// version of the config is not one we support, skip is true and the remainder
+// of ec is not populated.
+func parseECHConfig(enc []byte) (skip bool, ec echConfig, err error) {
+	s := cryptobyte.String(enc)
+	ec.raw = []byte(enc)
+	if !s.ReadUint16(&ec.Version) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	if !s.ReadUint16(&ec.Length) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	if len(ec.raw) < int(ec.Length)+4 {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	ec.raw = ec.raw[:ec.Length+4]
+	if ec.Version != extensionEncryptedClientHello {
+		return true, echConfig{}, nil
+	}
+	s = cryptobyte.String(ec.raw[4:])
+	if !s.ReadUint8(&ec.ConfigID) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	if !s.ReadUint16(&ec.KemID) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	if !s.ReadUint16LengthPrefixed((*cryptobyte.String)(&ec.PublicKey)) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	var cipherSuites cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&cipherSuites) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	for !cipherSuites.Empty() {
+		var c echCipher
+		if !cipherSuites.ReadUint16(&c.KDFID) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		if !cipherSuites.ReadUint16(&c.AEADID) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		ec.SymmetricCipherSuite = append(ec.SymmetricCipherSuite, c)
+	}
+	if !s.ReadUint8(&ec.MaxNameLength) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	var publicName cryptobyte.String
+	if !s.ReadUint8LengthPrefixed(&publicName) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	ec.PublicName = publicName
+	var extensions cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&extensions) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	for !extensions.Empty() {
+		var e echExtension
+		if !extensions.ReadUint16(&e.Type) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		if !extensions.ReadUint16LengthPrefixed((*cryptobyte.String)(&e.Data)) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		ec.Extensions = append(ec.Extensions, e)
+	}
+	if !s.Empty() {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+
+	return false, ec, nil
+}
+
 // parseECHConfigList parses a draft-ietf-tls-esni-18 ECHConfigList, returning a
 // slice of parsed ECHConfigs, in the same order they were parsed, or an error
 // if the list is malformed.
 func parseECHConfigList(data []byte) ([]echConfig, error) {
 	s := cryptobyte.String(data)
-	// Skip the length prefix
 	var length uint16
 	if !s.ReadUint16(&length) {
 		return nil, errMalformedECHConfig
@@ -55,69 +129,18 @@ func parseECHConfigList(data []byte) ([]echConfig, error) {
 	}
 	var configs []echConfig
 	for len(s) > 0 {
-		var ec echConfig
-		ec.raw = []byte(s)
-		if !s.ReadUint16(&ec.Version) {
-			return nil, errMalformedECHConfig
-		}
-		if !s.ReadUint16(&ec.Length) {
-			return nil, errMalformedECHConfig
-		}
-		if len(ec.raw) < int(ec.Length)+4 {
-			return nil, errMalformedECHConfig
-		}
-		ec.raw = ec.raw[:ec.Length+4]
-		if ec.Version != extensionEncryptedClientHello {
-			s.Skip(int(ec.Length))
-			continue
-		}
-		if !s.ReadUint8(&ec.ConfigID) {
+		if len(s) < 4 {
 			return nil, errMalformedECHConfig
 		}
-		if !s.ReadUint16(&ec.KemID) {
-			return nil, errMalformedECHConfig
+		configLen := uint16(s[2])<<8 | uint16(s[3])
+		skip, ec, err := parseECHConfig(s)
+		if err != nil {
+			return nil, err
 		}
-		if !s.ReadUint16LengthPrefixed((*cryptobyte.String)(&ec.PublicKey)) {
-			return nil, errMalformedECHConfig
+		s = s[configLen+4:]
+		if !skip {
+			configs = append(configs, ec)
 		}
-		var cipherSuites cryptobyte.String
-		if !s.ReadUint16LengthPrefixed(&cipherSuites) {
-			return nil, errMalformedECHConfig
-		}
-		for !cipherSuites.Empty() {
-			var c echCipher
-			if !cipherSuites.ReadUint16(&c.KDFID) {
-				return nil, errMalformedECHConfig
-			}
-			if !cipherSuites.ReadUint16(&c.AEADID) {
-				return nil, errMalformedECHConfig
-			}
-			ec.SymmetricCipherSuite = append(ec.SymmetricCipherSuite, c)
-		}
-		if !s.ReadUint8(&ec.MaxNameLength) {
-			return nil, errMalformedECHConfig
-		}
-		var publicName cryptobyte.String
-		if !s.ReadUint8LengthPrefixed(&publicName) {
-			return nil, errMalformedECHConfig
-		}
-		ec.PublicName = publicName
-		var extensions cryptobyte.String
-		if !s.ReadUint16LengthPrefixed(&extensions) {
-			return nil, errMalformedECHConfig
-		}
-		for !extensions.Empty() {
-			var e echExtension
-			if !extensions.ReadUint16(&e.Type) {
-				return nil, errMalformedECHConfig
-			}
-			if !extensions.ReadUint16LengthPrefixed((*cryptobyte.String)(&e.Data)) {
-				return nil, errMalformedECHConfig
-			}
-			ec.Extensions = append(ec.Extensions, e)
-		}
-
-		configs = append(configs, ec)
 	}
 	return configs, nil
 }
@@ -239,6 +262,318 @@ func computeAndUpdateOuterECHExtension(outer, inner *clientHelloMsg, ech *echCon
 	return nil
 }
 
+type echExtType uint8
+
+const (
+	innerECHExt echExtType = 1
+	outerECHExt echExtType = 0
+)
+
+var (
+	errMalformedECHExt = errors.New("tls: malformed encrypted_client_hello extension")
+	errInvalidECHExt   = errors.New("tls: client sent invalid encrypted_client_hello extension")
+)
+
+// parseECHExt parses the contents of a ClientHello encrypted_client_hello
+// extension. For an inner extension, all return values except echType are
+// zero.
+func parseECHExt(ext []byte) (echType echExtType, cs echCipher, configID uint8, encap []byte, payload []byte, err error) {
+	s := cryptobyte.String(ext)
+	var echInt uint8
+	if !s.ReadUint8(&echInt) {
+		return 0, echCipher{}, 0, nil, nil, errMalformedECHExt
+	}
+	echType = echExtType(echInt)
+	switch echType {
+	case innerECHExt:
+		if !s.Empty() {
+			return 0, echCipher{}, 0, nil, nil, errMalformedECHExt
+		}
+		return echType, echCipher{}, 0, nil, nil, nil
+	case outerECHExt:
+	default:
+		return 0, echCipher{}, 0, nil, nil, errInvalidECHExt
+	}
+	if !s.ReadUint16(&cs.KDFID) ||
+		!s.ReadUint16(&cs.AEADID) ||
+		!s.ReadUint8(&configID) ||
+		!readUint16LengthPrefixed(&s, &encap) ||
+		!readUint16LengthPrefixed(&s, &payload) ||
+		len(payload) == 0 || !s.Empty() {
+		return 0, echCipher{}, 0, nil, nil, errMalformedECHExt
+	}
+	// Clone encap and payload so that the caller can't accidentally modify
+	// the raw extension bytes through them.
+	return echType, cs, configID, bytes.Clone(encap), bytes.Clone(payload), nil
+}
+
+type rawExtension struct {
+	extType uint16
+	data    []byte
+}
+
+// extractRawExtensions returns the extensions of a parsed ClientHello, in the
+// order they appear on the wire, with their raw contents.
+func extractRawExtensions(hello *clientHelloMsg) ([]rawExtension, error) {
+	s := cryptobyte.String(hello.original)
+	var ignored cryptobyte.String
+	if !s.Skip(4+2+32) || // header, version, random
+		!s.ReadUint8LengthPrefixed(&ignored) || // session ID
+		!s.ReadUint16LengthPrefixed(&ignored) || // cipher suites
+		!s.ReadUint8LengthPrefixed(&ignored) { // compression methods
+		return nil, errors.New("tls: invalid outer client hello")
+	}
+	if s.Empty() {
+		return nil, nil
+	}
+	var extensions cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&extensions) {
+		return nil, errors.New("tls: invalid outer client hello")
+	}
+	var rawExts []rawExtension
+	for !extensions.Empty() {
+		var extension uint16
+		var extData cryptobyte.String
+		if !extensions.ReadUint16(&extension) ||
+			!extensions.ReadUint16LengthPrefixed(&extData) {
+			return nil, errors.New("tls: invalid outer client hello")
+		}
+		rawExts = append(rawExts, rawExtension{extension, extData})
+	}
+	return rawExts, nil
+}
+
+// decodeInnerClientHello reconstructs the ClientHelloInner from its encoded
+// form, as decrypted from the encrypted_client_hello extension of outer. See
+// draft-ietf-tls-esni-18, Section 5.1.
+//
+// The encoded form lacks the message header and the legacy_session_id, which
+// are copied from outer, and extensions listed in ech_outer_extensions are
+// copied from outer in the order they are referenced. The resulting bytes
+// should match the ClientHelloInner exactly as the client serialized it into
+// its transcript.
+func decodeInnerClientHello(outer *clientHelloMsg, encoded []byte) (*clientHelloMsg, error) {
+	innerReader := cryptobyte.String(encoded)
+	var versionAndRandom, sessionID, cipherSuites, compressionMethods []byte
+	var extensions cryptobyte.String
+	if !innerReader.ReadBytes(&versionAndRandom, 2+32) ||
+		!readUint8LengthPrefixed(&innerReader, &sessionID) ||
+		len(sessionID) != 0 ||
+		!readUint16LengthPrefixed(&innerReader, &cipherSuites) ||
+		!readUint8LengthPrefixed(&innerReader, &compressionMethods) ||
+		!innerReader.ReadUint16LengthPrefixed(&extensions) {
+		return nil, errInvalidECHExt
+	}
+
+	// The padding after the encoded ClientHelloInner must be all zeroes.
+	for _, p := range innerReader {
+		if p != 0 {
+			return nil, errInvalidECHExt
+		}
+	}
+
+	rawOuterExts, err := extractRawExtensions(outer)
+	if err != nil {
+		return nil, err
+	}
+
+	recon := cryptobyte.NewBuilder(nil)
+	recon.AddUint8(typeClientHello)
+	recon.AddUint24LengthPrefixed(func(recon *cryptobyte.Builder) {
+		recon.AddBytes(versionAndRandom)
+		recon.AddUint8LengthPrefixed(func(recon *cryptobyte.Builder) {
+			recon.AddBytes(outer.sessionId)
+		})
+		recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+			recon.AddBytes(cipherSuites)
+		})
+		recon.AddUint8LengthPrefixed(func(recon *cryptobyte.Builder) {
+			recon.AddBytes(compressionMethods)
+		})
+		recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+			// Each extension referenced by ech_outer_extensions must appear
+			// in the outer hello after the previously referenced one.
+			var next int
+			for !extensions.Empty() {
+				var extension uint16
+				var extData cryptobyte.String
+				if !extensions.ReadUint16(&extension) ||
+					!extensions.ReadUint16LengthPrefixed(&extData) {
+					recon.SetError(errInvalidECHExt)
+					return
+				}
+				if extension != extensionECHOuterExtensions {
+					recon.AddUint16(extension)
+					recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+						recon.AddBytes(extData)
+					})
+					continue
+				}
+				var outerExts cryptobyte.String
+				if !extData.ReadUint8LengthPrefixed(&outerExts) ||
+					outerExts.Empty() || !extData.Empty() {
+					recon.SetError(errInvalidECHExt)
+					return
+				}
+				for !outerExts.Empty() {
+					var extType uint16
+					if !outerExts.ReadUint16(&extType) ||
+						extType == extensionEncryptedClientHello {
+						recon.SetError(errInvalidECHExt)
+						return
+					}
+					i := slices.IndexFunc(rawOuterExts[next:], func(e rawExtension) bool {
+						return e.extType == extType
+					})
+					if i == -1 {
+						recon.SetError(errInvalidECHExt)
+						return
+					}
+					ext := rawOuterExts[next+i]
+					next += i + 1
+					recon.AddUint16(ext.extType)
+					recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+						recon.AddBytes(ext.data)
+					})
+				}
+			}
+		})
+	})
+
+	reconBytes, err := recon.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	inner := &clientHelloMsg{}
+	if !inner.unmarshal(reconBytes) {
+		return nil, errInvalidECHExt
+	}
+
+	if !bytes.Equal(inner.encryptedClientHello, []byte{uint8(innerECHExt)}) {
+		return nil, errInvalidECHExt
+	}
+	if len(inner.supportedVersions) == 0 || slices.ContainsFunc(inner.supportedVersions, func(v uint16) bool {
+		return v < VersionTLS13
+	}) {
+		return nil, errors.New("tls: client sent encrypted_client_hello extension and offered incompatible versions")
+	}
+
+	return inner, nil
+}
+
+// decryptECHPayload opens the encrypted ClientHelloInner payload, using the
+// serialized outer hello, with the payload replaced by zeroes, as the AAD.
+func decryptECHPayload(context *hpke.Recipient, hello, payload []byte) ([]byte, error) {
+	outerAAD := bytes.Replace(hello[4:], payload, make([]byte, len(payload)), 1)
+	return context.Open(outerAAD, payload)
+}
+
+// echServerContext holds the server-side state of an accepted ECH handshake.
+type echServerContext struct {
+	hpkeContext *hpke.Recipient
+	configID    uint8
+	ciphersuite echCipher
+
+	// inner is set if the ClientHello carried an inner encrypted_client_hello
+	// extension, meaning a client-facing server already decrypted it and
+	// forwarded the ClientHelloInner to us. All other fields are unset.
+	inner bool
+}
+
+// processECHClientHello attempts to decrypt the ClientHelloInner carried by
+// outer with one of echKeys. If it succeeds it returns the ClientHelloInner and
+// the ECH state to use for the rest of the handshake. If none of the keys can
+// decrypt the payload, ECH is rejected and the ClientHelloOuter is returned
+// with a nil context, to continue the handshake with the public name.
+func (c *Conn) processECHClientHello(outer *clientHelloMsg, echKeys []EncryptedClientHelloKey) (*clientHelloMsg, *echServerContext, error) {
+	echType, echCiphersuite, configID, encap, payload, err := parseECHExt(outer.encryptedClientHello)
+	if err != nil {
+		if errors.Is(err, errInvalidECHExt) {
+			c.sendAlert(alertIllegalParameter)
+		} else {
+			c.sendAlert(alertDecodeError)
+		}
+		return nil, nil, err
+	}
+
+	if echType == innerECHExt {
+		return outer, &echServerContext{inner: true}, nil
+	}
+
+	for _, echKey := range echKeys {
+		skip, config, err := parseECHConfig(echKey.Config)
+		if err != nil || skip {
+			c.sendAlert(alertInternalError)
+			if err == nil {
+				err = errors.New("unsupported version")
+			}
+			return nil, nil, fmt.Errorf("tls: invalid EncryptedClientHelloKeys Config: %v", err)
+		}
+		if config.ConfigID != configID {
+			continue
+		}
+		echPriv, err := hpke.ParseHPKEPrivateKey(config.KemID, echKey.PrivateKey)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return nil, nil, fmt.Errorf("tls: invalid EncryptedClientHelloKeys PrivateKey: %v", err)
+		}
+		if !slices.Contains(config.SymmetricCipherSuite, echCiphersuite) {
+			continue
+		}
+		info := append([]byte("tls ech\x00"), echKey.Config...)
+		hpkeContext, err := hpke.SetupRecipient(config.KemID, echCiphersuite.KDFID, echCiphersuite.AEADID, echPriv, info, encap)
+		if err != nil {
+			// Attempt the next trial decryption.
+			continue
+		}
+		encodedInner, err := decryptECHPayload(hpkeContext, outer.original, payload)
+		if err != nil {
+			// Attempt the next trial decryption.
+			continue
+		}
+
+		// NOTE: we don't enforce that the outer server_name matches the
+		// config's public_name. This is only a MAY in the specification, and
+		// the client already had to know the config to encrypt the payload.
+
+		inner, err := decodeInnerClientHello(outer, encodedInner)
+		if err != nil {
+			c.sendAlert(alertIllegalParameter)
+			return nil, nil, err
+		}
+
+		c.echAccepted = true
+
+		return inner, &echServerContext{
+			hpkeContext: hpkeContext,
+			configID:    configID,
+			ciphersuite: echCiphersuite,
+		}, nil
+	}
+
+	return outer, nil, nil
+}
+
+// buildRetryConfigList returns the ECHConfigList of the keys marked with
+// SendAsRetry, or nil if there are none.
+func buildRetryConfigList(keys []EncryptedClientHelloKey) ([]byte, error) {
+	var atLeastOneRetryConfig bool
+	var retryBuilder cryptobyte.Builder
+	retryBuilder.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, k := range keys {
+			if !k.SendAsRetry {
+				continue
+			}
+			atLeastOneRetryConfig = true
+			b.AddBytes(k.Config)
+		}
+	})
+	if !atLeastOneRetryConfig {
+		return nil, nil
+	}
+	return retryBuilder.Bytes()
+}
+
 // validDNSName is a rather rudimentary check for the validity of a DNS name.
 // This is used to check if the public_name in a ECHConfig is valid when we are
 // picking a config. This can be somewhat lax because even if we pick a
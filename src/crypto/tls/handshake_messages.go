@@ -632,6 +632,15 @@ func (m *clientHelloMsg) unmarshal(data []byte) bool {
 			if !extData.CopyBytes(m.quicTransportParameters) {
 				return false
 			}
+		case extensionEncryptedClientHello:
+			// draft-ietf-tls-esni-18, Section 5
+			if extData.Empty() {
+				return false
+			}
+			m.encryptedClientHello = make([]byte, len(extData))
+			if !extData.CopyBytes(m.encryptedClientHello) {
+				return false
+			}
 		case extensionPreSharedKey:
 			// RFC 8446, Section 4.2.11
 			if !extensions.Empty() {
@@ -26,6 +26,8 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/cryptobyte"
 )
 
 func testClientHello(t *testing.T, serverConfig *Config, m handshakeMessage) {
@@ -54,7 +56,7 @@ func testClientHelloFailure(t *testing.T, serverConfig *Config, m handshakeMessa
 	}()
 	ctx := context.Background()
 	conn := Server(s, serverConfig)
-	ch, err := conn.readClientHello(ctx)
+	ch, _, err := conn.readClientHello(ctx)
 	if conn.vers == VersionTLS13 {
 		hs := serverHandshakeStateTLS13{
 			c:           conn,
@@ -1518,7 +1520,7 @@ func TestSNIGivenOnFailure(t *testing.T) {
 	}()
 	conn := Server(s, serverConfig)
 	ctx := context.Background()
-	ch, err := conn.readClientHello(ctx)
+	ch, _, err := conn.readClientHello(ctx)
 	hs := serverHandshakeState{
 		c:           conn,
 		ctx:         ctx,
@@ -2116,3 +2118,151 @@ func TestHandshakeContextHierarchy(t *testing.T) {
 		t.Errorf("Unexpected client error: %v", err)
 	}
 }
+
+func marshalTestECHConfig(id uint8, pubKey []byte, publicName string, maxNameLen uint8) []byte {
+	builder := cryptobyte.NewBuilder(nil)
+	builder.AddUint16(extensionEncryptedClientHello)
+	builder.AddUint16LengthPrefixed(func(builder *cryptobyte.Builder) {
+		builder.AddUint8(id)
+		builder.AddUint16(0x0020) // DHKEM(X25519, HKDF-SHA256)
+		builder.AddUint16LengthPrefixed(func(builder *cryptobyte.Builder) {
+			builder.AddBytes(pubKey)
+		})
+		builder.AddUint16LengthPrefixed(func(builder *cryptobyte.Builder) {
+			builder.AddUint16(0x0001) // HKDF-SHA256
+			builder.AddUint16(0x0001) // AES-128-GCM
+		})
+		builder.AddUint8(maxNameLen)
+		builder.AddUint8LengthPrefixed(func(builder *cryptobyte.Builder) {
+			builder.AddBytes([]byte(publicName))
+		})
+		builder.AddUint16(0) // extensions
+	})
+	return builder.BytesOrPanic()
+}
+
+func TestHandshakeServerECH(t *testing.T) {
+	newKey := func(t *testing.T, id uint8, sendAsRetry bool) (EncryptedClientHelloKey, []byte) {
+		k, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := marshalTestECHConfig(id, k.PublicKey().Bytes(), "public.example", 32)
+		builder := cryptobyte.NewBuilder(nil)
+		builder.AddUint16LengthPrefixed(func(builder *cryptobyte.Builder) {
+			builder.AddBytes(config)
+		})
+		return EncryptedClientHelloKey{
+			Config:      config,
+			PrivateKey:  k.Bytes(),
+			SendAsRetry: sendAsRetry,
+		}, builder.BytesOrPanic()
+	}
+
+	run := func(t *testing.T, clientConfig, serverConfig *Config) (serverState, clientState ConnectionState, serverErr, clientErr error) {
+		c, s := localPipe(t)
+		done := make(chan error, 1)
+		go func() {
+			srv := Server(s, serverConfig)
+			err := srv.Handshake()
+			serverState = srv.ConnectionState()
+			s.Close()
+			done <- err
+		}()
+		cli := Client(c, clientConfig)
+		clientErr = cli.Handshake()
+		clientState = cli.ConnectionState()
+		c.Close()
+		serverErr = <-done
+		return
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		echKey, echConfigList := newKey(t, 42, true)
+		clientConfig, serverConfig := testConfig.Clone(), testConfig.Clone()
+		clientConfig.ServerName = "secret.example"
+		clientConfig.MinVersion = VersionTLS13
+		clientConfig.EncryptedClientHelloConfigList = echConfigList
+		serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{echKey}
+
+		var serverName string
+		serverConfig.GetConfigForClient = func(chi *ClientHelloInfo) (*Config, error) {
+			serverName = chi.ServerName
+			return nil, nil
+		}
+
+		ss, cs, serverErr, clientErr := run(t, clientConfig, serverConfig)
+		if serverErr != nil || clientErr != nil {
+			t.Fatalf("handshake failed: server: %v, client: %v", serverErr, clientErr)
+		}
+		if !ss.ECHAccepted || !cs.ECHAccepted {
+			t.Errorf("ECH not accepted: server %v, client %v", ss.ECHAccepted, cs.ECHAccepted)
+		}
+		if serverName != "secret.example" || ss.ServerName != "secret.example" {
+			t.Errorf("server saw server name %q, %q; want %q", serverName, ss.ServerName, "secret.example")
+		}
+	})
+
+	t.Run("accepted after HelloRetryRequest", func(t *testing.T) {
+		echKey, echConfigList := newKey(t, 42, true)
+		clientConfig, serverConfig := testConfig.Clone(), testConfig.Clone()
+		clientConfig.ServerName = "secret.example"
+		clientConfig.MinVersion = VersionTLS13
+		clientConfig.EncryptedClientHelloConfigList = echConfigList
+		clientConfig.CurvePreferences = []CurveID{X25519, CurveP256}
+		serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{echKey}
+		serverConfig.CurvePreferences = []CurveID{CurveP256}
+
+		ss, cs, serverErr, clientErr := run(t, clientConfig, serverConfig)
+		if serverErr != nil || clientErr != nil {
+			t.Fatalf("handshake failed: server: %v, client: %v", serverErr, clientErr)
+		}
+		if !ss.testingOnlyDidHRR {
+			t.Errorf("expected HelloRetryRequest")
+		}
+		if !ss.ECHAccepted || !cs.ECHAccepted {
+			t.Errorf("ECH not accepted: server %v, client %v", ss.ECHAccepted, cs.ECHAccepted)
+		}
+		if ss.ServerName != "secret.example" {
+			t.Errorf("server saw server name %q, want %q", ss.ServerName, "secret.example")
+		}
+	})
+
+	t.Run("rejected with retry configs", func(t *testing.T) {
+		_, staleConfigList := newKey(t, 42, false)
+		echKey, echConfigList := newKey(t, 43, true)
+		otherKey, _ := newKey(t, 44, false)
+		clientConfig, serverConfig := testConfig.Clone(), testConfig.Clone()
+		clientConfig.ServerName = "secret.example"
+		clientConfig.MinVersion = VersionTLS13
+		clientConfig.EncryptedClientHelloConfigList = staleConfigList
+		// The test certificates aren't valid for the public name.
+		clientConfig.EncryptedClientHelloRejectionVerify = func(ConnectionState) error {
+			return nil
+		}
+		serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{echKey, otherKey}
+
+		ss, _, _, clientErr := run(t, clientConfig, serverConfig)
+		var echErr *ECHRejectionError
+		if !errors.As(clientErr, &echErr) {
+			t.Fatalf("unexpected client error: got %v, want ECHRejectionError", clientErr)
+		}
+		if !bytes.Equal(echErr.RetryConfigList, echConfigList) {
+			t.Errorf("unexpected retry configs: got %x, want %x", echErr.RetryConfigList, echConfigList)
+		}
+		if ss.ECHAccepted {
+			t.Errorf("server accepted ECH with an unknown config")
+		}
+
+		// Retrying with the new configs should succeed.
+		clientConfig.EncryptedClientHelloConfigList = echErr.RetryConfigList
+		ss, cs, serverErr, clientErr := run(t, clientConfig, serverConfig)
+		if serverErr != nil || clientErr != nil {
+			t.Fatalf("handshake failed: server: %v, client: %v", serverErr, clientErr)
+		}
+		if !ss.ECHAccepted || !cs.ECHAccepted {
+			t.Errorf("ECH not accepted: server %v, client %v", ss.ECHAccepted, cs.ECHAccepted)
+		}
+	})
+
+}
@@ -6,16 +6,15 @@ package tls
 
 import (
 	"crypto/ecdh"
+	"crypto/hkdf"
 	"crypto/hmac"
-	"crypto/internal/mlkem768"
+	"crypto/mlkem"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
 
 	"golang.org/x/crypto/cryptobyte"
-	"golang.org/x/crypto/hkdf"
-	"golang.org/x/crypto/sha3"
 )
 
 // This file contains the functions necessary to compute the TLS 1.3 key
@@ -60,9 +59,8 @@ func (c *cipherSuiteTLS13) expandLabel(secret []byte, label string, context []by
 		// significantly more confusing to users.
 		panic(fmt.Errorf("failed to construct HKDF label: %s", err))
 	}
-	out := make([]byte, length)
-	n, err := hkdf.Expand(c.hash.New, secret, hkdfLabelBytes).Read(out)
-	if err != nil || n != length {
+	out, err := hkdf.Expand(c.hash.New, secret, string(hkdfLabelBytes), length)
+	if err != nil {
 		panic("tls: HKDF-Expand-Label invocation failed unexpectedly")
 	}
 	return out
@@ -81,7 +79,11 @@ func (c *cipherSuiteTLS13) extract(newSecret, currentSecret []byte) []byte {
 	if newSecret == nil {
 		newSecret = make([]byte, c.hash.Size())
 	}
-	return hkdf.Extract(c.hash.New, newSecret, currentSecret)
+	prk, err := hkdf.Extract(c.hash.New, newSecret, currentSecret)
+	if err != nil {
+		panic("tls: HKDF-Extract invocation failed unexpectedly")
+	}
+	return prk
 }
 
 // nextTrafficSecret generates the next traffic secret, given the current one,
@@ -122,38 +124,7 @@ func (c *cipherSuiteTLS13) exportKeyingMaterial(masterSecret []byte, transcript
 type keySharePrivateKeys struct {
 	curveID CurveID
 	ecdhe   *ecdh.PrivateKey
-	kyber   *mlkem768.DecapsulationKey
-}
-
-// kyberDecapsulate implements decapsulation according to Kyber Round 3.
-func kyberDecapsulate(dk *mlkem768.DecapsulationKey, c []byte) ([]byte, error) {
-	K, err := mlkem768.Decapsulate(dk, c)
-	if err != nil {
-		return nil, err
-	}
-	return kyberSharedSecret(K, c), nil
-}
-
-// kyberEncapsulate implements encapsulation according to Kyber Round 3.
-func kyberEncapsulate(ek []byte) (c, ss []byte, err error) {
-	c, ss, err = mlkem768.Encapsulate(ek)
-	if err != nil {
-		return nil, nil, err
-	}
-	return c, kyberSharedSecret(ss, c), nil
-}
-
-func kyberSharedSecret(K, c []byte) []byte {
-	// Package mlkem768 implements ML-KEM, which compared to Kyber removed a
-	// final hashing step. Compute SHAKE-256(K || SHA3-256(c), 32) to match Kyber.
-	// See https://words.filippo.io/mlkem768/#bonus-track-using-a-ml-kem-implementation-as-kyber-v3.
-	h := sha3.NewShake256()
-	h.Write(K)
-	ch := sha3.Sum256(c)
-	h.Write(ch[:])
-	out := make([]byte, 32)
-	h.Read(out)
-	return out
+	mlkem   *mlkem.DecapsulationKey768
 }
 
 const x25519PublicKeySize = 32
@@ -10,6 +10,10 @@
 // type (bool, int8, uint8, int16, float32, complex64, ...)
 // or an array or struct containing only fixed-size values.
 //
+// [Marshal] and [Unmarshal] translate structs that also contain
+// variable-length values, such as length-prefixed strings and slices,
+// varints and optional fields, as described by struct tags.
+//
 // The varint functions encode and decode single integer values using
 // a variable-length encoding; smaller values require fewer bytes.
 // For a specification, see
@@ -77,6 +77,43 @@ func ExampleRead_multi() {
 	// 61374
 }
 
+func ExampleMarshal() {
+	type Record struct {
+		Kind  uint8
+		Flags *uint16 `binary:"be"`
+		Name  string  `binary:"len=uint8"`
+		Sizes []int64 `binary:"len=uvarint,varint"`
+	}
+
+	b, err := binary.Marshal(binary.LittleEndian, Record{Kind: 1, Name: "go", Sizes: []int64{-1, 150}})
+	if err != nil {
+		fmt.Println("binary.Marshal failed:", err)
+	}
+	fmt.Printf("% x\n", b)
+	// Output: 01 00 02 67 6f 02 01 ac 02
+}
+
+func ExampleUnmarshal() {
+	var data struct {
+		Kind  uint8
+		Flags *uint16 `binary:"be"`
+		Name  string  `binary:"len=uint8"`
+		Sizes []int64 `binary:"len=uvarint,varint"`
+	}
+	b := []byte{0x01, 0x01, 0xbe, 0xef, 0x02, 0x67, 0x6f, 0x02, 0x01, 0xac, 0x02}
+
+	if _, err := binary.Unmarshal(b, binary.LittleEndian, &data); err != nil {
+		fmt.Println("binary.Unmarshal failed:", err)
+	}
+	fmt.Println(data.Kind, *data.Flags, data.Name, data.Sizes)
+
+	_, err := binary.Unmarshal(b[:10], binary.LittleEndian, &data)
+	fmt.Println(err)
+	// Output:
+	// 1 48879 go [-1 150]
+	// binary: field Sizes[1] at offset 9: unexpected EOF
+}
+
 func ExampleByteOrder_put() {
 	b := make([]byte, 4)
 	binary.LittleEndian.PutUint16(b[0:], 0x03e8)
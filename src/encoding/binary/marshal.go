@@ -0,0 +1,904 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package binary
+
+import (
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// Marshal returns the binary encoding of v, which must be a struct or a
+// pointer to a struct. Multi-byte values are encoded using the given byte
+// order unless a field's tag says otherwise.
+//
+// Unlike [Write], Marshal accepts variable-length data. The fields of the
+// struct are encoded in declaration order, without padding or alignment:
+//
+//   - Booleans, fixed-size integers, floating-point and complex numbers
+//     are encoded as by [Write].
+//   - Arrays are encoded element by element and structs field by field.
+//   - Strings and slices are encoded as their length followed by their
+//     bytes or elements. The encoding of the length must be set with the
+//     len option described below.
+//   - Pointers are encoded as a presence byte, 0 for nil and 1 otherwise,
+//     followed by the encoding of the pointed-to value if the pointer is
+//     not nil. They can be used for optional fields.
+//   - int and uint values, whose size depends on the platform, must use
+//     the varint option.
+//
+// Fields named _ are padding, as in [Write]: they are encoded as zeros and
+// must have a fixed size. All other fields must be exported.
+// Channel, function, map and interface types are not supported.
+//
+// The encoding of each field can be changed with a comma-separated list
+// of options in the field's "binary" struct tag:
+//
+//   - "-": the field is ignored.
+//   - "be" or "le": multi-byte values, including lengths, use big-endian
+//     or little-endian byte order instead of the order passed to Marshal.
+//   - "varint": integers are encoded as by [AppendVarint] or
+//     [AppendUvarint], depending on their signedness.
+//   - "len=T": lengths of strings and slices are encoded as T, which is
+//     one of uint8, uint16, uint32, uint64 or uvarint.
+//
+// The options apply to the field's value, including the elements of arrays
+// and slices and the values of pointers. The fields of a nested struct use
+// their own tags, but inherit the byte order of the enclosing field.
+//
+// For example, this struct encodes as a big-endian 16-bit type, an optional
+// 32-bit identifier, and a string and a list of varints each preceded by a
+// one-byte length:
+//
+//	type Message struct {
+//		Type   uint16  `binary:"be"`
+//		ID     *uint32 `binary:"be"`
+//		Name   string  `binary:"len=uint8"`
+//		Values []int64 `binary:"len=uint8,varint"`
+//	}
+//
+// Errors encountered while encoding a field are reported as a
+// [*FieldError]. This includes a pointer or slice that refers back to a
+// value containing it, which would otherwise be encoded forever.
+func Marshal(order ByteOrder, v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.New("binary.Marshal: invalid type " + typeString(v))
+	}
+	sc, err := cachedStructCodec(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	e := &marshalState{order: order}
+	if err := sc.encode(e, rv); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Unmarshal decodes the binary encoding in data into the struct pointed to
+// by v, using the given byte order unless a field's tag says otherwise.
+// See [Marshal] for the encoding of each field.
+//
+// Unmarshal returns the number of bytes consumed from data. Data following
+// the encoding of v is not an error. Decoded strings and slices do not
+// share memory with data; an empty slice is decoded as nil.
+//
+// Errors encountered while decoding a field, including truncated data,
+// are reported as a [*FieldError]. If data ends before the encoding of v
+// does, the error wraps [io.ErrUnexpectedEOF].
+func Unmarshal(data []byte, order ByteOrder, v any) (int, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return 0, errors.New("binary.Unmarshal: invalid type " + typeString(v))
+	}
+	rv = rv.Elem()
+	sc, err := cachedStructCodec(rv.Type())
+	if err != nil {
+		return 0, err
+	}
+	d := &unmarshalState{order: order, data: data}
+	if err := sc.decode(d, rv); err != nil {
+		return d.off, err
+	}
+	return d.off, nil
+}
+
+func typeString(v any) string {
+	if v == nil {
+		return "nil"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+// A FieldError describes a failure to marshal or unmarshal a struct field.
+type FieldError struct {
+	// Field is the path to the field from the top-level struct,
+	// such as "Header.Items[2].Name".
+	Field string
+	// Offset is the byte offset in the encoded data of the value that
+	// could not be marshaled or unmarshaled.
+	Offset int
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return "binary: field " + shortPath(e.Field) + " at offset " + strconv.Itoa(e.Offset) + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// shortPath elides the middle of a long field path, such as the path into
+// a linked list, so that an error message stays readable.
+func shortPath(path string) string {
+	const keep = 32
+	if len(path) <= 2*keep {
+		return path
+	}
+	// Cut at field boundaries. This package may not import strings.
+	head, tail := path[:keep], path[len(path)-keep:]
+	for i := len(head) - 1; i > 0; i-- {
+		if head[i] == '.' {
+			head = head[:i]
+			break
+		}
+	}
+	for i := 0; i < len(tail); i++ {
+		if tail[i] == '.' {
+			tail = tail[i+1:]
+			break
+		}
+	}
+	return head + "..." + tail
+}
+
+// fieldError returns err annotated with the path element elem, which is
+// either a field name or an index such as "[2]".
+func fieldError(err error, elem string) error {
+	fe, ok := err.(*FieldError)
+	if !ok {
+		return err
+	}
+	switch {
+	case fe.Field == "":
+		fe.Field = elem
+	case fe.Field[0] == '[':
+		fe.Field = elem + fe.Field
+	default:
+		fe.Field = elem + "." + fe.Field
+	}
+	return fe
+}
+
+type marshalState struct {
+	order ByteOrder
+	buf   []byte
+
+	// depth is the number of pointers and slices being encoded, and
+	// seen holds those beyond the first startDetectingCyclesAfter.
+	depth int
+	seen  map[visit]struct{}
+}
+
+// Only pointers and slices can make an encoding recurse without bound,
+// since arrays and structs have a fixed layout. Encoded messages rarely
+// nest that many of them, so the first startDetectingCyclesAfter are not
+// recorded in marshalState.seen: a cycle keeps recursing past the limit
+// and is detected the second time it reaches a recorded value.
+const startDetectingCyclesAfter = 1000
+
+var errCycle = errors.New("value contains a reference to itself")
+
+// A visit is the key of a pointer or slice in marshalState.seen.
+// A *T and the *U of the first field of the T have the same address,
+// as do a []T and a shorter slice of it held by one of its elements,
+// so the type and length are part of the key.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+func visitOf(v reflect.Value) visit {
+	k := visit{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		k.len = v.Len()
+	}
+	return k
+}
+
+// enter records that the encoding of the pointer or slice v has begun.
+// If v is already being encoded, it returns a *FieldError and the caller
+// must not call exit.
+func (e *marshalState) enter(v reflect.Value) error {
+	if e.depth < startDetectingCyclesAfter {
+		e.depth++
+		return nil
+	}
+	k := visitOf(v)
+	if _, ok := e.seen[k]; ok {
+		return e.error(errCycle)
+	}
+	if e.seen == nil {
+		e.seen = make(map[visit]struct{})
+	}
+	e.seen[k] = struct{}{}
+	e.depth++
+	return nil
+}
+
+// exit records that the encoding of the pointer or slice v has ended.
+func (e *marshalState) exit(v reflect.Value) {
+	e.depth--
+	if e.depth >= startDetectingCyclesAfter {
+		delete(e.seen, visitOf(v))
+	}
+}
+
+// grow extends e.buf by n bytes and returns them.
+func (e *marshalState) grow(n int) []byte {
+	var pos []byte
+	e.buf, pos = ensure(e.buf, n)
+	return pos
+}
+
+func (e *marshalState) error(err error) error {
+	return &FieldError{Offset: len(e.buf), Err: err}
+}
+
+type unmarshalState struct {
+	order ByteOrder
+	data  []byte
+	off   int
+}
+
+// next returns the next n bytes of d.data, or nil if there are fewer.
+func (d *unmarshalState) next(n int) []byte {
+	if n < 0 || n > len(d.data)-d.off {
+		return nil
+	}
+	b := d.data[d.off : d.off+n : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *unmarshalState) error(off int, err error) error {
+	return &FieldError{Offset: off, Err: err}
+}
+
+// A codec marshals and unmarshals values of a particular type with
+// particular options. Both functions return a *FieldError, without a field
+// path, on failure.
+type codec struct {
+	encode func(e *marshalState, v reflect.Value) error
+	decode func(d *unmarshalState, v reflect.Value) error
+}
+
+// A structCodec marshals and unmarshals the fields of a struct type.
+type structCodec struct {
+	fields []structField
+	err    error // error for an unsupported type
+}
+
+type structField struct {
+	name  string
+	index int
+	codec codec
+}
+
+func (sc *structCodec) encode(e *marshalState, v reflect.Value) error {
+	for i := range sc.fields {
+		f := &sc.fields[i]
+		if err := f.codec.encode(e, v.Field(f.index)); err != nil {
+			return fieldError(err, f.name)
+		}
+	}
+	return nil
+}
+
+func (sc *structCodec) decode(d *unmarshalState, v reflect.Value) error {
+	for i := range sc.fields {
+		f := &sc.fields[i]
+		if err := f.codec.decode(d, v.Field(f.index)); err != nil {
+			return fieldError(err, f.name)
+		}
+	}
+	return nil
+}
+
+var structCodecs sync.Map // map[reflect.Type]*structCodec
+
+// cachedStructCodec returns the codec for the struct type t, building it
+// on first use.
+func cachedStructCodec(t reflect.Type) (*structCodec, error) {
+	if sc, ok := structCodecs.Load(t); ok {
+		return sc.(*structCodec), sc.(*structCodec).err
+	}
+	b := &codecBuilder{structs: make(map[reflect.Type]*structCodec)}
+	sc, _ := structCodecs.LoadOrStore(t, b.structCodec(t))
+	return sc.(*structCodec), sc.(*structCodec).err
+}
+
+// lenEncoding is the encoding of the length of a string or slice.
+type lenEncoding int
+
+const (
+	lenNone lenEncoding = iota
+	lenUint8
+	lenUint16
+	lenUint32
+	lenUint64
+	lenUvarint
+)
+
+var lenEncodings = map[string]lenEncoding{
+	"uint8":   lenUint8,
+	"uint16":  lenUint16,
+	"uint32":  lenUint32,
+	"uint64":  lenUint64,
+	"uvarint": lenUvarint,
+}
+
+// fieldOptions holds the options in a "binary" struct tag.
+type fieldOptions struct {
+	order  ByteOrder // nil to inherit the enclosing order
+	varint bool
+	len    lenEncoding
+}
+
+func parseTag(tag string) (fieldOptions, error) {
+	var o fieldOptions
+	if tag == "" {
+		return o, nil
+	}
+	for start, i := 0, 0; i <= len(tag); i++ {
+		if i < len(tag) && tag[i] != ',' {
+			continue
+		}
+		opt := tag[start:i]
+		start = i + 1
+		switch opt {
+		case "be":
+			o.order = BigEndian
+		case "le":
+			o.order = LittleEndian
+		case "varint":
+			o.varint = true
+		default:
+			if len(opt) < 4 || opt[:4] != "len=" {
+				return o, errors.New("unknown option " + strconv.Quote(opt))
+			}
+			name := opt[4:]
+			if o.len = lenEncodings[name]; o.len == lenNone {
+				return o, errors.New("invalid length encoding " + strconv.Quote(name))
+			}
+		}
+	}
+	return o, nil
+}
+
+// codecBuilder builds the codecs for a struct type and the struct types
+// it refers to, which may refer back to it.
+type codecBuilder struct {
+	structs map[reflect.Type]*structCodec
+}
+
+func (b *codecBuilder) structCodec(t reflect.Type) *structCodec {
+	if sc := b.structs[t]; sc != nil {
+		return sc
+	}
+	sc := new(structCodec)
+	b.structs[t] = sc
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("binary")
+		if tag == "-" {
+			continue
+		}
+		fail := func(err error) *structCodec {
+			if _, ok := err.(*typeError); !ok {
+				err = &typeError{t.String() + "." + sf.Name + ": " + err.Error()}
+			}
+			sc.err = err
+			sc.fields = nil
+			return sc
+		}
+		if sf.Name == "_" {
+			size := sizeof(sf.Type)
+			if size < 0 {
+				return fail(errors.New("padding of variable size"))
+			}
+			sc.fields = append(sc.fields, structField{sf.Name, i, paddingCodec(size)})
+			continue
+		}
+		if !sf.IsExported() {
+			return fail(errors.New("unexported field"))
+		}
+		o, err := parseTag(tag)
+		if err != nil {
+			return fail(err)
+		}
+		if err := checkOptions(sf.Type, o); err != nil {
+			return fail(err)
+		}
+		c, err := b.codec(sf.Type, o)
+		if err != nil {
+			return fail(err)
+		}
+		if o.order != nil {
+			c = orderCodec(c, o.order)
+		}
+		sc.fields = append(sc.fields, structField{sf.Name, i, c})
+	}
+	return sc
+}
+
+// A typeError reports a struct field that cannot be marshaled or
+// unmarshaled because of its type or tag.
+type typeError struct {
+	msg string
+}
+
+func (e *typeError) Error() string { return "binary: cannot encode field " + e.msg }
+
+// checkOptions reports whether the options o apply to the type t.
+func checkOptions(t reflect.Type, o fieldOptions) error {
+	variable := false
+	for {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Array:
+			t = t.Elem()
+			continue
+		case reflect.Slice:
+			variable = true
+			t = t.Elem()
+			continue
+		case reflect.String:
+			variable = true
+		}
+		break
+	}
+	if o.len != lenNone && !variable {
+		return errors.New("len option on type without strings or slices")
+	}
+	if o.varint && !isInt(t.Kind()) && !isUint(t.Kind()) {
+		return errors.New("varint option on non-integer type " + t.String())
+	}
+	return nil
+}
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUint(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uint64
+}
+
+// codec returns the codec for values of type t with the options o.
+func (b *codecBuilder) codec(t reflect.Type, o fieldOptions) (codec, error) {
+	if !o.varint && isFixed(t) {
+		return fixedCodec(sizeof(t)), nil
+	}
+	switch k := t.Kind(); {
+	case isInt(k) || isUint(k):
+		if !o.varint {
+			return codec{}, errors.New("type " + t.String() + " requires the varint option")
+		}
+		return varintCodec(isInt(k)), nil
+
+	case k == reflect.String:
+		if o.len == lenNone {
+			return codec{}, errors.New("string requires the len option")
+		}
+		return stringCodec(o.len), nil
+
+	case k == reflect.Slice:
+		if o.len == lenNone {
+			return codec{}, errors.New("slice requires the len option")
+		}
+		if !o.varint && isFixed(t.Elem()) {
+			return fixedSliceCodec(o.len, sizeof(t.Elem())), nil
+		}
+		elem, err := b.codec(t.Elem(), o)
+		if err != nil {
+			return codec{}, err
+		}
+		return sliceCodec(o.len, elem), nil
+
+	case k == reflect.Array:
+		elem, err := b.codec(t.Elem(), o)
+		if err != nil {
+			return codec{}, err
+		}
+		return arrayCodec(elem), nil
+
+	case k == reflect.Pointer:
+		elem, err := b.codec(t.Elem(), o)
+		if err != nil {
+			return codec{}, err
+		}
+		return pointerCodec(elem), nil
+
+	case k == reflect.Struct:
+		sc := b.structCodec(t)
+		if sc.err != nil {
+			return codec{}, sc.err
+		}
+		return codec{sc.encode, sc.decode}, nil
+	}
+	return codec{}, errors.New("unsupported type " + t.String())
+}
+
+// isFixed reports whether values of type t have a fixed size and can be
+// encoded by the encoder and decoder used by [Write] and [Read]: they
+// contain no tagged or unexported fields.
+func isFixed(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return isFixed(t.Elem())
+	case reflect.Struct:
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if f.Name != "_" && (!f.IsExported() || f.Tag.Get("binary") != "" || !isFixed(f.Type)) {
+				return false
+			}
+			if f.Name == "_" && sizeof(f.Type) < 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return sizeof(t) >= 0
+}
+
+func orderCodec(c codec, order ByteOrder) codec {
+	return codec{
+		encode: func(e *marshalState, v reflect.Value) error {
+			saved := e.order
+			e.order = order
+			err := c.encode(e, v)
+			e.order = saved
+			return err
+		},
+		decode: func(d *unmarshalState, v reflect.Value) error {
+			saved := d.order
+			d.order = order
+			err := c.decode(d, v)
+			d.order = saved
+			return err
+		},
+	}
+}
+
+func paddingCodec(size int) codec {
+	return codec{
+		encode: func(e *marshalState, v reflect.Value) error {
+			clear(e.grow(size))
+			return nil
+		},
+		decode: func(d *unmarshalState, v reflect.Value) error {
+			off := d.off
+			if d.next(size) == nil {
+				return d.error(off, io.ErrUnexpectedEOF)
+			}
+			return nil
+		},
+	}
+}
+
+// fixedCodec returns a codec for fixed-size values, which uses the same
+// encoding as [Write] and [Read].
+func fixedCodec(size int) codec {
+	return codec{
+		encode: func(e *marshalState, v reflect.Value) error {
+			enc := &encoder{order: e.order, buf: e.grow(size)}
+			enc.value(v)
+			return nil
+		},
+		decode: func(d *unmarshalState, v reflect.Value) error {
+			off := d.off
+			buf := d.next(size)
+			if buf == nil {
+				return d.error(off, io.ErrUnexpectedEOF)
+			}
+			dec := &decoder{order: d.order, buf: buf}
+			dec.value(v)
+			return nil
+		},
+	}
+}
+
+var errValueOverflow = errors.New("value overflows field type")
+
+func varintCodec(signed bool) codec {
+	return codec{
+		encode: func(e *marshalState, v reflect.Value) error {
+			if signed {
+				e.buf = AppendVarint(e.buf, v.Int())
+			} else {
+				e.buf = AppendUvarint(e.buf, v.Uint())
+			}
+			return nil
+		},
+		decode: func(d *unmarshalState, v reflect.Value) error {
+			off := d.off
+			if signed {
+				x, n := Varint(d.data[off:])
+				if n <= 0 {
+					return d.error(off, varintError(n))
+				}
+				if v.OverflowInt(x) {
+					return d.error(off, errValueOverflow)
+				}
+				v.SetInt(x)
+				d.off += n
+			} else {
+				x, n := Uvarint(d.data[off:])
+				if n <= 0 {
+					return d.error(off, varintError(n))
+				}
+				if v.OverflowUint(x) {
+					return d.error(off, errValueOverflow)
+				}
+				v.SetUint(x)
+				d.off += n
+			}
+			return nil
+		},
+	}
+}
+
+// varintError returns the error for the result n <= 0 of [Varint] or
+// [Uvarint].
+func varintError(n int) error {
+	if n == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return errOverflow
+}
+
+var lenMax = [...]uint64{
+	lenUint8:   math.MaxUint8,
+	lenUint16:  math.MaxUint16,
+	lenUint32:  math.MaxUint32,
+	lenUint64:  math.MaxUint64,
+	lenUvarint: math.MaxUint64,
+}
+
+// encodeLen appends the length n using the encoding l.
+func (e *marshalState) encodeLen(l lenEncoding, n int) error {
+	if uint64(n) > lenMax[l] {
+		return e.error(errors.New("length " + strconv.Itoa(n) + " overflows the length encoding"))
+	}
+	switch l {
+	case lenUint8:
+		e.buf = append(e.buf, uint8(n))
+	case lenUint16:
+		e.order.PutUint16(e.grow(2), uint16(n))
+	case lenUint32:
+		e.order.PutUint32(e.grow(4), uint32(n))
+	case lenUint64:
+		e.order.PutUint64(e.grow(8), uint64(n))
+	case lenUvarint:
+		e.buf = AppendUvarint(e.buf, uint64(n))
+	}
+	return nil
+}
+
+var errLenTooLarge = errors.New("length exceeds the remaining data")
+
+// decodeLen decodes a length using the encoding l and checks that at
+// least n*size bytes remain, to avoid allocating more memory than the
+// input can justify.
+func (d *unmarshalState) decodeLen(l lenEncoding, size int) (int, error) {
+	off := d.off
+	var n uint64
+	switch l {
+	case lenUint8:
+		if b := d.next(1); b != nil {
+			n = uint64(b[0])
+		} else {
+			return 0, d.error(off, io.ErrUnexpectedEOF)
+		}
+	case lenUint16:
+		if b := d.next(2); b != nil {
+			n = uint64(d.order.Uint16(b))
+		} else {
+			return 0, d.error(off, io.ErrUnexpectedEOF)
+		}
+	case lenUint32:
+		if b := d.next(4); b != nil {
+			n = uint64(d.order.Uint32(b))
+		} else {
+			return 0, d.error(off, io.ErrUnexpectedEOF)
+		}
+	case lenUint64:
+		if b := d.next(8); b != nil {
+			n = d.order.Uint64(b)
+		} else {
+			return 0, d.error(off, io.ErrUnexpectedEOF)
+		}
+	case lenUvarint:
+		var w int
+		n, w = Uvarint(d.data[off:])
+		if w <= 0 {
+			return 0, d.error(off, varintError(w))
+		}
+		d.off += w
+	}
+	// Elements of zero size still count as one byte for this check.
+	size = max(size, 1)
+	if n > uint64(len(d.data)-d.off)/uint64(size) {
+		return 0, d.error(off, errLenTooLarge)
+	}
+	return int(n), nil
+}
+
+func stringCodec(l lenEncoding) codec {
+	return codec{
+		encode: func(e *marshalState, v reflect.Value) error {
+			s := v.String()
+			if err := e.encodeLen(l, len(s)); err != nil {
+				return err
+			}
+			e.buf = append(e.buf, s...)
+			return nil
+		},
+		decode: func(d *unmarshalState, v reflect.Value) error {
+			n, err := d.decodeLen(l, 1)
+			if err != nil {
+				return err
+			}
+			v.SetString(string(d.next(n)))
+			return nil
+		},
+	}
+}
+
+// fixedSliceCodec returns a codec for slices of fixed-size elements,
+// which uses the fast paths of [Write] and [Read] when possible.
+func fixedSliceCodec(l lenEncoding, size int) codec {
+	return codec{
+		encode: func(e *marshalState, v reflect.Value) error {
+			n := v.Len()
+			if err := e.encodeLen(l, n); err != nil {
+				return err
+			}
+			buf := e.grow(n * size)
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				copy(buf, v.Bytes())
+				return nil
+			}
+			data := v.Interface()
+			if m, _ := intDataSize(data); m != 0 {
+				encodeFast(buf, e.order, data)
+				return nil
+			}
+			enc := &encoder{order: e.order, buf: buf}
+			enc.value(v)
+			return nil
+		},
+		decode: func(d *unmarshalState, v reflect.Value) error {
+			n, err := d.decodeLen(l, size)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				v.SetZero()
+				return nil
+			}
+			s := reflect.MakeSlice(v.Type(), n, n)
+			buf := d.next(n * size)
+			if !decodeFast(buf, d.order, s.Interface()) {
+				dec := &decoder{order: d.order, buf: buf}
+				dec.value(s)
+			}
+			v.Set(s)
+			return nil
+		},
+	}
+}
+
+func sliceCodec(l lenEncoding, elem codec) codec {
+	return codec{
+		encode: func(e *marshalState, v reflect.Value) error {
+			n := v.Len()
+			if err := e.encodeLen(l, n); err != nil {
+				return err
+			}
+			if err := e.enter(v); err != nil {
+				return err
+			}
+			for i := range n {
+				if err := elem.encode(e, v.Index(i)); err != nil {
+					e.exit(v)
+					return fieldError(err, "["+strconv.Itoa(i)+"]")
+				}
+			}
+			e.exit(v)
+			return nil
+		},
+		decode: func(d *unmarshalState, v reflect.Value) error {
+			n, err := d.decodeLen(l, 1)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				v.SetZero()
+				return nil
+			}
+			s := reflect.MakeSlice(v.Type(), n, n)
+			for i := range n {
+				if err := elem.decode(d, s.Index(i)); err != nil {
+					return fieldError(err, "["+strconv.Itoa(i)+"]")
+				}
+			}
+			v.Set(s)
+			return nil
+		},
+	}
+}
+
+func arrayCodec(elem codec) codec {
+	return codec{
+		encode: func(e *marshalState, v reflect.Value) error {
+			for i := range v.Len() {
+				if err := elem.encode(e, v.Index(i)); err != nil {
+					return fieldError(err, "["+strconv.Itoa(i)+"]")
+				}
+			}
+			return nil
+		},
+		decode: func(d *unmarshalState, v reflect.Value) error {
+			for i := range v.Len() {
+				if err := elem.decode(d, v.Index(i)); err != nil {
+					return fieldError(err, "["+strconv.Itoa(i)+"]")
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func pointerCodec(elem codec) codec {
+	return codec{
+		encode: func(e *marshalState, v reflect.Value) error {
+			if v.IsNil() {
+				e.buf = append(e.buf, 0)
+				return nil
+			}
+			e.buf = append(e.buf, 1)
+			if err := e.enter(v); err != nil {
+				return err
+			}
+			err := elem.encode(e, v.Elem())
+			e.exit(v)
+			return err
+		},
+		decode: func(d *unmarshalState, v reflect.Value) error {
+			off := d.off
+			b := d.next(1)
+			if b == nil {
+				return d.error(off, io.ErrUnexpectedEOF)
+			}
+			if b[0] == 0 {
+				v.SetZero()
+				return nil
+			}
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			return elem.decode(d, v.Elem())
+		},
+	}
+}
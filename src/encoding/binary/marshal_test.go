@@ -0,0 +1,344 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Point struct {
+	X, Y int16
+}
+
+type Item struct {
+	Name  string `binary:"len=uint8"`
+	Count uint   `binary:"varint"`
+}
+
+type Message struct {
+	Type    uint16    `binary:"be"`
+	Flags   uint32    // in the order passed to Marshal
+	_       [2]byte   // padding
+	ID      *uint32   `binary:"le"`
+	Missing *uint32   `binary:"le"`
+	Name    string    `binary:"len=uvarint"`
+	Data    []byte    `binary:"len=uint16,be"`
+	Deltas  []int64   `binary:"len=uint8,varint"`
+	Words   []uint16  `binary:"len=uint8"`
+	Points  [2]Point  `binary:"be"`
+	Items   []Item    `binary:"len=uint8"`
+	Tags    [2]string `binary:"len=uint8"`
+	Ignored string    `binary:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	id := uint32(0x01020304)
+	m := Message{
+		Type:    0x0a0b,
+		Flags:   0x11223344,
+		ID:      &id,
+		Name:    "gopher",
+		Data:    []byte{0xde, 0xad},
+		Deltas:  []int64{1, -1, 300},
+		Words:   []uint16{0x0102},
+		Points:  [2]Point{{1, 2}, {-1, -2}},
+		Items:   []Item{{"a", 1}, {"bc", 1000}},
+		Tags:    [2]string{"x", ""},
+		Ignored: "ignored",
+	}
+	want := []byte{
+		0x0a, 0x0b, // Type
+		0x44, 0x33, 0x22, 0x11, // Flags
+		0x00, 0x00, // _
+		0x01, 0x04, 0x03, 0x02, 0x01, // ID
+		0x00,                               // Missing
+		0x06, 'g', 'o', 'p', 'h', 'e', 'r', // Name
+		0x00, 0x02, 0xde, 0xad, // Data
+		0x03, 0x02, 0x01, 0xd8, 0x04, // Deltas
+		0x01, 0x02, 0x01, // Words
+		0x00, 0x01, 0x00, 0x02, 0xff, 0xff, 0xff, 0xfe, // Points
+		0x02, 0x01, 'a', 0x01, 0x02, 'b', 'c', 0xe8, 0x07, // Items
+		0x01, 'x', 0x00, // Tags
+	}
+
+	for _, v := range []any{m, &m} {
+		got, err := Marshal(LittleEndian, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Marshal(%T):\ngot  % x\nwant % x", v, got, want)
+		}
+	}
+
+	var m2 Message
+	data := append(want, 0xff) // trailing data is not consumed
+	n, err := Unmarshal(data, LittleEndian, &m2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(want) {
+		t.Errorf("Unmarshal consumed %d bytes, want %d", n, len(want))
+	}
+	m.Ignored = ""
+	if !reflect.DeepEqual(m2, m) {
+		t.Errorf("Unmarshal:\ngot  %+v\nwant %+v", m2, m)
+	}
+}
+
+func TestMarshalFixed(t *testing.T) {
+	// Structs without tags and with only fixed-size fields encode as by Write.
+	for _, order := range []ByteOrder{LittleEndian, BigEndian} {
+		got, err := Marshal(order, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := Append(nil, order, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Marshal(%v):\ngot  % x\nwant % x", order, got, want)
+		}
+		var s2 Struct
+		if _, err := Unmarshal(got, order, &s2); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(s2, s) {
+			t.Errorf("Unmarshal(%v):\ngot  %+v\nwant %+v", order, s2, s)
+		}
+	}
+}
+
+type List struct {
+	Value int32 `binary:"varint"`
+	Next  *List
+}
+
+func TestMarshalRecursive(t *testing.T) {
+	l := List{1, &List{-2, &List{3, nil}}}
+	data, err := Marshal(BigEndian, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x02, 0x01, 0x03, 0x01, 0x06, 0x00}
+	if !bytes.Equal(data, want) {
+		t.Errorf("Marshal:\ngot  % x\nwant % x", data, want)
+	}
+	var l2 List
+	if _, err := Unmarshal(data, BigEndian, &l2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(l2, l) {
+		t.Errorf("Unmarshal = %+v, want %+v", l2, l)
+	}
+}
+
+type Tree struct {
+	Children []Tree `binary:"len=uint8"`
+}
+
+func TestMarshalCycle(t *testing.T) {
+	ring := &List{Value: 1}
+	ring.Next = &List{Value: 2, Next: ring}
+	loop := Tree{Children: make([]Tree, 1)}
+	loop.Children[0].Children = loop.Children
+
+	tests := []struct {
+		name   string
+		v      any
+		prefix string
+	}{
+		{"Pointer", ring, "Next.Next.Next"},
+		{"Slice", loop, "Children[0].Children[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Marshal(BigEndian, tt.v)
+			var fe *FieldError
+			if !errors.As(err, &fe) || !errors.Is(err, errCycle) {
+				t.Fatalf("Marshal error = %v, want a *FieldError for a cycle", err)
+			}
+			if !strings.HasPrefix(fe.Field, tt.prefix) || fe.Offset == 0 {
+				t.Errorf("Marshal error for field %.40s... at offset %d, want field %s...", fe.Field, fe.Offset, tt.prefix)
+			}
+			if len(err.Error()) > 160 {
+				t.Errorf("Marshal error is %d bytes long: %v", len(err.Error()), err)
+			}
+		})
+	}
+}
+
+func TestMarshalSharedValues(t *testing.T) {
+	// Siblings deep in a tree may hold the same slice, and a list
+	// may hold the same node more than once if it is not its own successor.
+	leaf := []Tree{{}}
+	tree := Tree{Children: []Tree{{Children: leaf}, {Children: leaf}}}
+	for range startDetectingCyclesAfter + 10 {
+		tree = Tree{Children: []Tree{tree}}
+	}
+	if _, err := Marshal(BigEndian, tree); err != nil {
+		t.Errorf("Marshal of a tree sharing a slice: %v", err)
+	}
+
+	type pair struct {
+		A, B *List
+	}
+	shared := &List{Value: 1}
+	list := shared
+	for i := range startDetectingCyclesAfter + 10 {
+		list = &List{Value: int32(i), Next: list}
+	}
+	b, err := Marshal(BigEndian, pair{list, shared})
+	if err != nil {
+		t.Fatalf("Marshal of a list sharing its tail: %v", err)
+	}
+	var got pair
+	if _, err := Unmarshal(b, BigEndian, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got.B.Value != 1 {
+		t.Errorf("Unmarshal B.Value = %d, want 1", got.B.Value)
+	}
+}
+
+func TestFieldErrorShortPath(t *testing.T) {
+	for _, tt := range []struct {
+		path, want string
+	}{
+		{"Header.Items[2].Name", "Header.Items[2].Name"},
+		{strings.Repeat("Next.", 40) + "Value", "Next.Next.Next.Next.Next.Next...Next.Next.Next.Next.Next.Value"},
+		{strings.Repeat("Children[0].", 10) + "Name", "Children[0].Children[0]...Children[0].Children[0].Name"},
+	} {
+		if got := shortPath(tt.path); got != tt.want {
+			t.Errorf("shortPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	type small struct {
+		A uint8 `binary:"varint"`
+	}
+	type nested struct {
+		Items []Item `binary:"len=uint8"`
+		B     uint32
+	}
+	tests := []struct {
+		name   string
+		data   []byte
+		v      any
+		field  string
+		offset int
+		err    error
+	}{
+		{"Truncated", []byte{1, 2, 3}, new(Point), "Y", 2, io.ErrUnexpectedEOF},
+		{"Empty", nil, new(Point), "X", 0, io.ErrUnexpectedEOF},
+		{"Nested", []byte{2, 1, 'a', 1, 5, 'b'}, new(nested), "Items[1].Name", 4, errLenTooLarge},
+		{"NestedVarint", []byte{1, 1, 'a', 0x80}, new(nested), "Items[0].Count", 3, io.ErrUnexpectedEOF},
+		{"AfterSlice", []byte{0, 1, 2}, new(nested), "B", 1, io.ErrUnexpectedEOF},
+		{"Overflow", []byte{0x80, 0x02}, new(small), "A", 0, errValueOverflow},
+		{"VarintOverflow", bytes.Repeat([]byte{0xff}, 11), new(small), "A", 0, errOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Unmarshal(tt.data, LittleEndian, tt.v)
+			var fe *FieldError
+			if !errors.As(err, &fe) {
+				t.Fatalf("Unmarshal error = %v, want a *FieldError", err)
+			}
+			if fe.Field != tt.field || fe.Offset != tt.offset || !errors.Is(err, tt.err) {
+				t.Errorf("Unmarshal error = %v, want field %s at offset %d: %v", err, tt.field, tt.offset, tt.err)
+			}
+		})
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	type lenOverflow struct {
+		A uint8
+		S []byte `binary:"len=uint8"`
+	}
+	_, err := Marshal(BigEndian, lenOverflow{S: make([]byte, 256)})
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "S" || fe.Offset != 1 {
+		t.Errorf("Marshal error = %v, want a *FieldError for S at offset 1", err)
+	}
+
+	tests := []struct {
+		v   any
+		err string
+	}{
+		{nil, "invalid type nil"},
+		{1, "invalid type int"},
+		{[]Point{}, "invalid type []binary.Point"},
+		{(*Point)(nil), "invalid type *binary.Point"},
+		{struct{ A int }{}, "requires the varint option"},
+		{struct{ A string }{}, "requires the len option"},
+		{struct{ A []int32 }{}, "requires the len option"},
+		{struct{ a int32 }{}, "unexported field"},
+		{struct{ _ []byte }{}, "padding of variable size"},
+		{struct{ A map[int]int }{}, "unsupported type"},
+		{struct{ A any }{}, "unsupported type"},
+		{struct {
+			A float32 `binary:"varint"`
+		}{}, "varint option on non-integer type"},
+		{struct {
+			A int32 `binary:"len=uint8"`
+		}{}, "len option on type without strings or slices"},
+		{struct {
+			A string `binary:"len=int8"`
+		}{}, `invalid length encoding "int8"`},
+		{struct {
+			A int32 `binary:"big"`
+		}{}, `unknown option "big"`},
+		{struct{ A struct{ B string } }{}, "struct { B string }.B: string requires the len option"},
+	}
+	for _, tt := range tests {
+		_, err := Marshal(BigEndian, tt.v)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Marshal(%#v) error = %v, want %q", tt.v, err, tt.err)
+		}
+		if tt.v == nil || reflect.TypeOf(tt.v).Kind() != reflect.Struct {
+			continue
+		}
+		p := reflect.New(reflect.TypeOf(tt.v)).Interface()
+		if _, err := Unmarshal(nil, BigEndian, p); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Unmarshal(%T) error = %v, want %q", p, err, tt.err)
+		}
+	}
+
+	for _, v := range []any{nil, Point{}, (*Point)(nil), new(int)} {
+		if _, err := Unmarshal(nil, BigEndian, v); err == nil {
+			t.Errorf("Unmarshal(%T) succeeded", v)
+		}
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	id := uint32(7)
+	m := Message{ID: &id, Name: "gopher", Data: make([]byte, 100), Words: make([]uint16, 100), Items: []Item{{"a", 1}, {"b", 2}}}
+	data, _ := Marshal(LittleEndian, m)
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		Marshal(LittleEndian, m)
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	id := uint32(7)
+	m := Message{ID: &id, Name: "gopher", Data: make([]byte, 100), Words: make([]uint16, 100), Items: []Item{{"a", 1}, {"b", 2}}}
+	data, _ := Marshal(LittleEndian, m)
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		var m2 Message
+		Unmarshal(data, LittleEndian, &m2)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// A Decoder reads CSV records into Go structs.
+//
+// The first record of the input is a header that names the columns.
+// Each column is stored in the struct field of the same name,
+// as described for [Encoder]; columns without a matching field are
+// ignored, and fields without a matching column are left unchanged.
+//
+// Fields that implement [encoding.TextUnmarshaler] are set by calling
+// UnmarshalText. Otherwise, strings, booleans, integers, and
+// floating-point numbers are parsed as by the strconv package.
+// Pointer fields are allocated as needed. An empty field sets the
+// struct field to its zero value.
+type Decoder struct {
+	r      *Reader
+	header []string
+	typ    reflect.Type
+	cols   []*field // field for each column, or nil
+}
+
+// NewDecoder returns a new Decoder that reads records from r.
+// The Reader's fields control the format of the input.
+func NewDecoder(r *Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Header returns the column names of the input,
+// reading the header record if it has not been read yet.
+func (d *Decoder) Header() ([]string, error) {
+	if d.header != nil {
+		return d.header, nil
+	}
+	record, err := d.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	header := slices.Clone(record)
+	for i, name := range header {
+		if slices.Contains(header[:i], name) {
+			line, col := d.r.FieldPos(i)
+			return nil, &ParseError{StartLine: line, Line: line, Column: col, Err: fmt.Errorf("duplicate column %q", name)}
+		}
+	}
+	d.header = header
+	return header, nil
+}
+
+// Decode reads the next record and stores it in the struct pointed to by v.
+// If there are no more records, Decode returns [io.EOF].
+//
+// If a field cannot be parsed, Decode returns a [*ParseError]
+// with the position of the field, as reported by [Reader.FieldPos].
+func (d *Decoder) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("csv: Decode of %T, want non-nil pointer to struct", v)
+	}
+	rv = rv.Elem()
+	header, err := d.Header()
+	if err != nil {
+		return err
+	}
+	if rv.Type() != d.typ {
+		fields, err := cachedFields(rv.Type())
+		if err != nil {
+			return err
+		}
+		d.cols = make([]*field, len(header))
+		for i, name := range header {
+			for j := range fields {
+				if fields[j].name == name {
+					d.cols[i] = &fields[j]
+					break
+				}
+			}
+		}
+		d.typ = rv.Type()
+	}
+
+	record, err := d.r.Read()
+	if err != nil {
+		return err
+	}
+	for i, s := range record {
+		if i >= len(d.cols) || d.cols[i] == nil {
+			continue
+		}
+		f := d.cols[i]
+		fv, ok := fieldByIndex(rv, f.index, s != "")
+		if !ok {
+			// Empty fields do not allocate nil embedded structs.
+			continue
+		}
+		if err := parseValue(fv, s); err != nil {
+			startLine, _ := d.r.FieldPos(0)
+			line, col := d.r.FieldPos(i)
+			return &ParseError{
+				StartLine: startLine,
+				Line:      line,
+				Column:    col,
+				Err:       fmt.Errorf("cannot decode column %q into %s: %w", f.name, f.typ, err),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// An Encoder writes Go structs as CSV records.
+//
+// Each exported field of the struct is a column, named by the field's
+// "csv" struct tag or, if the tag is empty, by the field name.
+// A field with the tag "-" is ignored, and the fields of untagged
+// embedded structs are treated as if they were fields of the outer struct.
+//
+// Field values that implement [encoding.TextMarshaler] are written as
+// the text returned by MarshalText. Otherwise, strings, booleans,
+// integers, and floating-point numbers are written as by the strconv
+// package. Nil pointers are written as empty fields.
+type Encoder struct {
+	w      *Writer
+	typ    reflect.Type
+	fields []field
+	record []string
+}
+
+// NewEncoder returns a new Encoder that writes records to w.
+// The Writer's fields control the format of the output.
+func NewEncoder(w *Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the record for v, which must be a struct or
+// a pointer to a struct. Before the first record, Encode writes
+// a header record with the column names of v's type.
+// All values passed to Encode must have the same type.
+//
+// Like [Writer.Write], Encode buffers its output;
+// the client must call [Writer.Flush] on the underlying Writer.
+func (e *Encoder) Encode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("csv: Encode of %T, want struct or pointer to struct", v)
+	}
+	if !rv.CanAddr() {
+		// Make a copy so that pointer methods such as MarshalText can be used.
+		p := reflect.New(rv.Type()).Elem()
+		p.Set(rv)
+		rv = p
+	}
+	if e.typ == nil {
+		fields, err := cachedFields(rv.Type())
+		if err != nil {
+			return err
+		}
+		header := make([]string, len(fields))
+		for i, f := range fields {
+			header[i] = f.name
+		}
+		if err := e.w.Write(header); err != nil {
+			return err
+		}
+		e.typ, e.fields = rv.Type(), fields
+		e.record = make([]string, len(fields))
+	} else if rv.Type() != e.typ {
+		return fmt.Errorf("csv: Encode of %s after %s", rv.Type(), e.typ)
+	}
+
+	for i, f := range e.fields {
+		e.record[i] = ""
+		fv, ok := fieldByIndex(rv, f.index, false)
+		if !ok {
+			continue
+		}
+		s, err := formatValue(fv)
+		if err != nil {
+			return fmt.Errorf("csv: cannot encode column %q of %s: %w", f.name, e.typ, err)
+		}
+		e.record[i] = s
+	}
+	return e.w.Write(e.record)
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// level implements encoding.TextMarshaler with a pointer receiver.
+type level int
+
+func (l *level) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("*", int(*l))), nil
+}
+
+func (l *level) UnmarshalText(b []byte) error {
+	if strings.Trim(string(b), "*") != "" {
+		return errors.New("invalid level")
+	}
+	*l = level(len(b))
+	return nil
+}
+
+type Audit struct {
+	Updated time.Time `csv:"updated"`
+	By      string    `csv:"by"`
+}
+
+type Record struct {
+	Name    string   `csv:"name"`
+	Age     int      `csv:"age"`
+	Score   float64  `csv:"score"`
+	Active  bool     `csv:"active"`
+	Count   *uint16  `csv:"count"`
+	Level   level    `csv:"level"`
+	Ignored string   `csv:"-"`
+	Plain   string   // named by the field name
+	private string   // unexported fields are ignored
+	Nested  struct{} `csv:"-"`
+	*Audit
+}
+
+func TestEncodeDecode(t *testing.T) {
+	count := uint16(7)
+	records := []Record{{
+		Name:   "Ann, \"the\" first",
+		Age:    37,
+		Score:  12.5,
+		Active: true,
+		Count:  &count,
+		Level:  3,
+		Plain:  "x",
+		Audit:  &Audit{time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), "ops"},
+	}, {
+		Name: "Bob",
+		Age:  -1,
+	}}
+	const want = `name,age,score,active,count,level,Plain,updated,by
+"Ann, ""the"" first",37,12.5,true,7,***,x,2024-05-01T12:00:00Z,ops
+Bob,-1,0,false,,,,,
+`
+
+	var b strings.Builder
+	w := NewWriter(&b)
+	enc := NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != want {
+		t.Fatalf("Encode output:\ngot  %q\nwant %q", got, want)
+	}
+
+	dec := NewDecoder(NewReader(strings.NewReader(want)))
+	for i := range records {
+		var r Record
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("Decode #%d: %v", i, err)
+		}
+		if !reflect.DeepEqual(r, records[i]) {
+			t.Errorf("Decode #%d:\ngot  %+v\nwant %+v", i, r, records[i])
+		}
+	}
+	var r Record
+	if err := dec.Decode(&r); err != io.EOF {
+		t.Errorf("Decode at end = %v, want io.EOF", err)
+	}
+	if h, err := dec.Header(); err != nil || len(h) != 9 || h[0] != "name" {
+		t.Errorf("Header() = %q, %v", h, err)
+	}
+}
+
+func TestDecodeColumns(t *testing.T) {
+	type row struct {
+		A string `csv:"a"`
+		B int    `csv:"b"`
+		C string `csv:"c"`
+	}
+	const input = "b,extra,a\n1,x,one\n,y,two\n"
+	dec := NewDecoder(NewReader(strings.NewReader(input)))
+	want := []row{{"one", 1, "keep"}, {"two", 0, "keep"}}
+	for i, w := range want {
+		r := row{B: 99, C: "keep"}
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if r != w {
+			t.Errorf("Decode #%d = %+v, want %+v", i, r, w)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	type row struct {
+		Name string `csv:"name"`
+		Age  int    `csv:"age"`
+	}
+	tests := []struct {
+		name  string
+		input string
+		err   string
+		is    error
+	}{{
+		name:  "Syntax",
+		input: "name,age\nann,37\nbob,x\n",
+		err:   `parse error on line 3, column 5: cannot decode column "age" into int: strconv.ParseInt: parsing "x": invalid syntax`,
+		is:    strconv.ErrSyntax,
+	}, {
+		name:  "MultiLineRecord",
+		input: "name,age\n\"b\nob\",300000000000000000000\n",
+		err:   `record on line 2; parse error on line 3, column 5: cannot decode column "age" into int: strconv.ParseInt: parsing "300000000000000000000": value out of range`,
+		is:    strconv.ErrRange,
+	}, {
+		name:  "FieldCount",
+		input: "name,age\nann\n",
+		err:   "record on line 2: wrong number of fields",
+		is:    ErrFieldCount,
+	}, {
+		name:  "DuplicateColumn",
+		input: "name,age,name\nann,1,bob\n",
+		err:   `parse error on line 1, column 10: duplicate column "name"`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(NewReader(strings.NewReader(tt.input)))
+			var err error
+			for err == nil {
+				var r row
+				err = dec.Decode(&r)
+			}
+			if err.Error() != tt.err {
+				t.Errorf("Decode error:\ngot  %v\nwant %v", err, tt.err)
+			}
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Errorf("Decode error %T is not a *ParseError", err)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("Decode error does not wrap %v", tt.is)
+			}
+		})
+	}
+
+	dec := NewDecoder(NewReader(strings.NewReader("name\n")))
+	for _, v := range []any{row{}, (*row)(nil), new(int)} {
+		if err := dec.Decode(v); err == nil {
+			t.Errorf("Decode(%T) succeeded", v)
+		}
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	type row struct {
+		A int
+	}
+	type other struct {
+		B int
+	}
+	type unsupported struct {
+		C []int
+	}
+	type embedded struct {
+		A int
+	}
+	type duplicate struct {
+		X int `csv:"A"`
+		embedded
+		A string `csv:"A"`
+	}
+
+	enc := NewEncoder(NewWriter(io.Discard))
+	if err := enc.Encode(row{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(other{1}); err == nil {
+		t.Errorf("Encode of a different type succeeded")
+	}
+	for _, v := range []any{1, (*row)(nil), unsupported{}, duplicate{}} {
+		if err := NewEncoder(NewWriter(io.Discard)).Encode(v); err == nil {
+			t.Errorf("Encode(%#v) succeeded", v)
+		}
+	}
+}
@@ -129,3 +129,65 @@ func ExampleWriter_WriteAll() {
 	// Ken,Thompson,ken
 	// Robert,Griesemer,gri
 }
+
+func ExampleEncoder() {
+	type User struct {
+		FirstName string `csv:"first_name"`
+		LastName  string `csv:"last_name"`
+		Username  string `csv:"username"`
+		Admin     bool   `csv:"admin"`
+	}
+	users := []User{
+		{"Rob", "Pike", "rob", true},
+		{"Ken", "Thompson", "ken", false},
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	w.Quoting = csv.QuoteAll
+	enc := csv.NewEncoder(w)
+	for _, u := range users {
+		if err := enc.Encode(u); err != nil {
+			log.Fatal(err)
+		}
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// "first_name","last_name","username","admin"
+	// "Rob","Pike","rob","true"
+	// "Ken","Thompson","ken","false"
+}
+
+func ExampleDecoder() {
+	in := `username;first_name;last_name
+rob;'Robert ''Rob''';Pike
+ken;'Ken';'Thompson'
+`
+	type User struct {
+		FirstName string `csv:"first_name"`
+		LastName  string `csv:"last_name"`
+		Username  string `csv:"username"`
+	}
+
+	r := csv.NewReader(strings.NewReader(in))
+	r.Comma = ';'
+	r.Quote = '\''
+	dec := csv.NewDecoder(r)
+	for {
+		var u User
+		err := dec.Decode(&u)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%+v\n", u)
+	}
+	// Output:
+	// {FirstName:Robert 'Rob' LastName:Pike Username:rob}
+	// {FirstName:Ken LastName:Thompson Username:ken}
+}
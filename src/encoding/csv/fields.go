@@ -0,0 +1,227 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// A field is a struct field mapped to a CSV column.
+type field struct {
+	name  string       // column name
+	index []int        // index sequence for reflect.Value.FieldByIndex
+	typ   reflect.Type // type of the field
+}
+
+var fieldCache sync.Map // map[reflect.Type]fieldsResult
+
+type fieldsResult struct {
+	fields []field
+	err    error
+}
+
+// cachedFields is like typeFields but uses a cache to avoid
+// repeated work.
+func cachedFields(t reflect.Type) ([]field, error) {
+	if r, ok := fieldCache.Load(t); ok {
+		return r.(fieldsResult).fields, r.(fieldsResult).err
+	}
+	fields, err := typeFields(t)
+	r, _ := fieldCache.LoadOrStore(t, fieldsResult{fields, err})
+	return r.(fieldsResult).fields, r.(fieldsResult).err
+}
+
+// typeFields returns the columns of the struct type t.
+//
+// Each exported field is a column named by its "csv" struct tag,
+// or by the field name if the tag is empty. Fields tagged "-" are ignored.
+// The fields of untagged embedded structs are promoted,
+// following the Go visibility rules for embedded fields:
+// of several fields with the same name, the shallowest one is used,
+// and it is an error if there is more than one at that depth.
+func typeFields(t reflect.Type) ([]field, error) {
+	var all []field
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("csv")
+			if tag == "-" {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer && ft.Name() == "" {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct && !isText(ft) {
+				if !sf.IsExported() && sf.Type.Kind() == reflect.Pointer {
+					// A nil pointer to an unexported struct cannot be allocated.
+					continue
+				}
+				walk(ft, append(index[:len(index):len(index)], i))
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			all = append(all, field{
+				name:  name,
+				index: append(index[:len(index):len(index)], i),
+				typ:   sf.Type,
+			})
+		}
+	}
+	walk(t, nil)
+
+	// Resolve conflicts between fields of the same name,
+	// keeping the order of the remaining fields.
+	var fields []field
+	for i, f := range all {
+		dominant := true
+		for j, g := range all {
+			if i == j || f.name != g.name {
+				continue
+			}
+			if len(g.index) < len(f.index) || len(g.index) == len(f.index) && j < i {
+				dominant = false
+				break
+			}
+			if len(g.index) == len(f.index) {
+				return nil, fmt.Errorf("csv: duplicate column %q in type %s", f.name, t)
+			}
+		}
+		if dominant {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// isText reports whether values of type t are represented
+// as text through encoding.TextMarshaler or encoding.TextUnmarshaler.
+func isText(t reflect.Type) bool {
+	return t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) ||
+		reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// fieldByIndex returns the field of the struct v with the given index sequence.
+// If alloc is true, nil embedded pointers are allocated;
+// otherwise fieldByIndex reports false if it encounters one.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// formatValue returns the text of the field value v.
+// Nil pointers are written as empty fields.
+func formatValue(v reflect.Value) (string, error) {
+	for {
+		if v.Type().Implements(textMarshalerType) {
+			if v.Kind() == reflect.Pointer && v.IsNil() {
+				return "", nil
+			}
+			b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+			return string(b), err
+		}
+		if v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+			b, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+			return string(b), err
+		}
+		if v.Kind() != reflect.Pointer {
+			break
+		}
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// parseValue sets the field value v from the text s.
+// An empty field sets v to its zero value.
+func parseValue(v reflect.Value, s string) error {
+	if s == "" {
+		v.SetZero()
+		return nil
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -93,7 +93,10 @@ var (
 	ErrTrailingComma = errors.New("extra delimiter at end of line")
 )
 
-var errInvalidDelim = errors.New("csv: invalid field or comment delimiter")
+var (
+	errInvalidDelim   = errors.New("csv: invalid field or comment delimiter")
+	errInvalidQuoting = errors.New("csv: invalid quoting mode")
+)
 
 func validDelim(r rune) bool {
 	return r != 0 && r != '"' && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
@@ -115,6 +118,14 @@ type Reader struct {
 	// or the Unicode replacement character (0xFFFD).
 	Comma rune
 
+	// Quote is the character that encloses quoted-fields.
+	// It is set to the double quote ('"') by NewReader.
+	// Within a quoted-field, two Quote characters stand for a single one.
+	// Quote must be a valid rune and must not be \r, \n,
+	// or the Unicode replacement character (0xFFFD).
+	// It must also not be equal to Comma or Comment.
+	Quote rune
+
 	// Comment, if not 0, is the comment character. Lines beginning with the
 	// Comment character without preceding whitespace are ignored.
 	// With leading whitespace the Comment character becomes part of the
@@ -181,6 +192,7 @@ type Reader struct {
 func NewReader(r io.Reader) *Reader {
 	return &Reader{
 		Comma: ',',
+		Quote: '"',
 		r:     bufio.NewReader(r),
 	}
 }
@@ -295,9 +307,16 @@ func nextRune(b []byte) rune {
 }
 
 func (r *Reader) readRecord(dst []string) ([]string, error) {
+	quote := r.Quote
+	if quote == 0 {
+		quote = '"'
+	}
 	if r.Comma == r.Comment || !validDelim(r.Comma) || (r.Comment != 0 && !validDelim(r.Comment)) {
 		return nil, errInvalidDelim
 	}
+	if quote == r.Comma || quote == r.Comment || (quote != '"' && !validDelim(quote)) {
+		return nil, errInvalidDelim
+	}
 
 	// Read line (automatically skipping past empty lines and any comments).
 	var line []byte
@@ -320,7 +339,7 @@ func (r *Reader) readRecord(dst []string) ([]string, error) {
 
 	// Parse each field in the record.
 	var err error
-	const quoteLen = len(`"`)
+	quoteLen := utf8.RuneLen(quote)
 	commaLen := utf8.RuneLen(r.Comma)
 	recLine := r.numLine // Starting line for record
 	r.recordBuffer = r.recordBuffer[:0]
@@ -340,7 +359,7 @@ parseField:
 			line = line[i:]
 			pos.col += i
 		}
-		if len(line) == 0 || line[0] != '"' {
+		if len(line) == 0 || nextRune(line) != quote {
 			// Non-quoted string field
 			i := bytes.IndexRune(line, r.Comma)
 			field := line
@@ -351,7 +370,7 @@ parseField:
 			}
 			// Check to make sure a quote does not appear in field.
 			if !r.LazyQuotes {
-				if j := bytes.IndexByte(field, '"'); j >= 0 {
+				if j := bytes.IndexRune(field, quote); j >= 0 {
 					col := pos.col + j
 					err = &ParseError{StartLine: recLine, Line: r.numLine, Column: col, Err: ErrBareQuote}
 					break parseField
@@ -372,16 +391,16 @@ parseField:
 			line = line[quoteLen:]
 			pos.col += quoteLen
 			for {
-				i := bytes.IndexByte(line, '"')
+				i := bytes.IndexRune(line, quote)
 				if i >= 0 {
 					// Hit next quote.
 					r.recordBuffer = append(r.recordBuffer, line[:i]...)
 					line = line[i+quoteLen:]
 					pos.col += i + quoteLen
 					switch rn := nextRune(line); {
-					case rn == '"':
+					case rn == quote:
 						// `""` sequence (append quote).
-						r.recordBuffer = append(r.recordBuffer, '"')
+						r.recordBuffer = utf8.AppendRune(r.recordBuffer, quote)
 						line = line[quoteLen:]
 						pos.col += quoteLen
 					case rn == r.Comma:
@@ -398,7 +417,7 @@ parseField:
 						break parseField
 					case r.LazyQuotes:
 						// `"` sequence (bare quote).
-						r.recordBuffer = append(r.recordBuffer, '"')
+						r.recordBuffer = utf8.AppendRune(r.recordBuffer, quote)
 					default:
 						// `"*` sequence (invalid non-escaped quote).
 						err = &ParseError{StartLine: recLine, Line: r.numLine, Column: pos.col - quoteLen, Err: ErrQuote}
@@ -24,6 +24,7 @@ type readTest struct {
 
 	// These fields are copied into the Reader
 	Comma              rune
+	Quote              rune
 	Comment            rune
 	UseFieldsPerRecord bool // false (default) means FieldsPerRecord is -1
 	FieldsPerRecord    int
@@ -402,6 +403,39 @@ field"`,
 	Comma:   'X',
 	Comment: 'X',
 	Errors:  []error{errInvalidDelim},
+}, {
+	Name:   "SingleQuote",
+	Input:  "§'a,b',§'c''d',§\"e\"\n",
+	Output: [][]string{{"a,b", "c'd", `"e"`}},
+	Quote:  '\'',
+}, {
+	Name:   "SingleQuoteMultiLine",
+	Input:  "§'a\nb',§c\n",
+	Output: [][]string{{"a\nb", "c"}},
+	Quote:  '\'',
+}, {
+	Name:   "MultiByteQuote",
+	Input:  "§ʼa,bʼ,§ʼʼʼcʼ\n",
+	Output: [][]string{{"a,b", "ʼc"}},
+	Quote:  'ʼ',
+}, {
+	Name:   "BadSingleQuote",
+	Input:  "§a∑'b\n",
+	Errors: []error{&ParseError{Err: ErrBareQuote}},
+	Quote:  '\'',
+}, {
+	Name:   "BadQuoteComma",
+	Quote:  ',',
+	Errors: []error{errInvalidDelim},
+}, {
+	Name:    "BadQuoteComment",
+	Quote:   '#',
+	Comment: '#',
+	Errors:  []error{errInvalidDelim},
+}, {
+	Name:   "BadQuote",
+	Quote:  '\n',
+	Errors: []error{errInvalidDelim},
 }}
 
 func TestRead(t *testing.T) {
@@ -412,6 +446,9 @@ func TestRead(t *testing.T) {
 		if tt.Comma != 0 {
 			r.Comma = tt.Comma
 		}
+		if tt.Quote != 0 {
+			r.Quote = tt.Quote
+		}
 		r.Comment = tt.Comment
 		if tt.UseFieldsPerRecord {
 			r.FieldsPerRecord = tt.FieldsPerRecord
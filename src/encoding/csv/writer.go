@@ -24,17 +24,35 @@ import (
 // If [Writer.UseCRLF] is true,
 // the Writer ends each output line with \r\n instead of \n.
 //
+// [Writer.Quoting] selects which fields are enclosed in quotes.
+//
 // The writes of individual records are buffered.
 // After all data has been written, the client should call the
 // [Writer.Flush] method to guarantee all data has been forwarded to
 // the underlying [io.Writer].  Any errors that occurred should
 // be checked by calling the [Writer.Error] method.
 type Writer struct {
-	Comma   rune // Field delimiter (set to ',' by NewWriter)
-	UseCRLF bool // True to use \r\n as the line terminator
+	Comma   rune      // Field delimiter (set to ',' by NewWriter)
+	UseCRLF bool      // True to use \r\n as the line terminator
+	Quoting QuoteMode // Quoting policy (QuoteMinimal by default)
 	w       *bufio.Writer
 }
 
+// A QuoteMode selects which fields a [Writer] encloses in quotes.
+type QuoteMode int
+
+const (
+	// QuoteMinimal quotes only the fields that require it:
+	// fields containing the delimiter, a quote, or a line break,
+	// fields beginning with white space, and the field `\.`.
+	QuoteMinimal QuoteMode = iota
+
+	// QuoteAll quotes every field, including empty ones.
+	// This also makes a record with a single empty field
+	// distinguishable from a blank line, which Reader skips.
+	QuoteAll
+)
+
 // NewWriter returns a new Writer that writes to w.
 func NewWriter(w io.Writer) *Writer {
 	return &Writer{
@@ -51,6 +69,9 @@ func (w *Writer) Write(record []string) error {
 	if !validDelim(w.Comma) {
 		return errInvalidDelim
 	}
+	if w.Quoting < QuoteMinimal || w.Quoting > QuoteAll {
+		return errInvalidQuoting
+	}
 
 	for n, field := range record {
 		if n > 0 {
@@ -158,6 +179,9 @@ func (w *Writer) WriteAll(records [][]string) error {
 // of Microsoft Excel and Google Drive.
 // For Postgres, quote the data terminating string `\.`.
 func (w *Writer) fieldNeedsQuotes(field string) bool {
+	if w.Quoting == QuoteAll {
+		return true
+	}
 	if field == "" {
 		return false
 	}
@@ -17,6 +17,7 @@ var writeTests = []struct {
 	Error   error
 	UseCRLF bool
 	Comma   rune
+	Quoting QuoteMode
 }{
 	{Input: [][]string{{"abc"}}, Output: "abc\n"},
 	{Input: [][]string{{"abc"}}, Output: "abc\r\n", UseCRLF: true},
@@ -47,6 +48,10 @@ var writeTests = []struct {
 	{Input: [][]string{{"a", "a", ""}}, Output: "a|a|\n", Comma: '|'},
 	{Input: [][]string{{",", ",", ""}}, Output: ",|,|\n", Comma: '|'},
 	{Input: [][]string{{"foo"}}, Comma: '"', Error: errInvalidDelim},
+	{Input: [][]string{{"a", "", `b"c`}}, Output: `"a","","b""c"` + "\n", Quoting: QuoteAll},
+	{Input: [][]string{{""}}, Output: `""` + "\n", Quoting: QuoteAll},
+	{Input: [][]string{{"a"}, {"b\nc"}}, Output: "\"a\"\r\n\"b\r\nc\"\r\n", Quoting: QuoteAll, UseCRLF: true},
+	{Input: [][]string{{"a"}}, Quoting: QuoteAll + 1, Error: errInvalidQuoting},
 }
 
 func TestWrite(t *testing.T) {
@@ -54,6 +59,7 @@ func TestWrite(t *testing.T) {
 		b := &strings.Builder{}
 		f := NewWriter(b)
 		f.UseCRLF = tt.UseCRLF
+		f.Quoting = tt.Quoting
 		if tt.Comma != 0 {
 			f.Comma = tt.Comma
 		}
@@ -14,6 +14,7 @@ import (
 	"math"
 	"math/bits"
 	"reflect"
+	"strings"
 )
 
 var (
@@ -1152,6 +1153,7 @@ func (dec *Decoder) compileDec(remoteId typeId, ut *userTypeInfo) (engine *decEn
 	engine = new(decEngine)
 	engine.instr = make([]decInstr, len(wireStruct.Field))
 	seen := make(map[reflect.Type]*decOp)
+	var aliased map[int]bool // local fields already matched by an alias
 	// Loop over the fields of the wire type.
 	for fieldnum := 0; fieldnum < len(wireStruct.Field); fieldnum++ {
 		wireField := wireStruct.Field[fieldnum]
@@ -1159,8 +1161,25 @@ func (dec *Decoder) compileDec(remoteId typeId, ut *userTypeInfo) (engine *decEn
 			errorf("empty name for remote field of type %s", wireStruct.Name)
 		}
 		ovfl := overflow(wireField.Name)
-		// Find the field of the local type with the same name.
+		// Find the field of the local type with the same name,
+		// or failing that, with the name as an alias.
 		localField, present := srt.FieldByName(wireField.Name)
+		if !present {
+			localField, present = fieldByAlias(srt, wireField.Name)
+			// A local field receives at most one wire field: the one with
+			// its own name if the wire type has it, or else the first one
+			// matching an alias. Any others are ignored.
+			if present {
+				if wireStruct.hasField(localField.Name) || aliased[localField.Index[0]] {
+					present = false
+				} else {
+					if aliased == nil {
+						aliased = make(map[int]bool)
+					}
+					aliased[localField.Index[0]] = true
+				}
+			}
+		}
 		// TODO(r): anonymous names
 		if !present || !isExported(wireField.Name) {
 			op := dec.decIgnoreOpFor(wireField.Id, make(map[typeId]*decOp))
@@ -1177,6 +1196,24 @@ func (dec *Decoder) compileDec(remoteId typeId, ut *userTypeInfo) (engine *decEn
 	return
 }
 
+// fieldByAlias returns the first exported field of the struct type t whose
+// "gob" struct tag lists name as an alias. Only the fields declared in t
+// are considered, not those promoted from embedded structs.
+func fieldByAlias(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		for opt := range strings.SplitSeq(f.Tag.Get("gob"), ",") {
+			if alias, ok := strings.CutPrefix(opt, "alias="); ok && alias == name {
+				return f, true
+			}
+		}
+	}
+	return reflect.StructField{}, false
+}
+
 // getDecEnginePtr returns the engine for the specified type.
 func (dec *Decoder) getDecEnginePtr(remoteId typeId, ut *userTypeInfo) (enginePtr **decEngine, err error) {
 	rt := ut.user
@@ -89,6 +89,22 @@ not initialize them first, so if the destination is a compound value such as a
 map, struct, or slice, the decoded values will be merged elementwise into the
 existing variables.
 
+A field that has been renamed can continue to receive values sent under its
+old name by listing that name in the field's gob tag:
+
+	type T struct {
+		FullName string `gob:"alias=Name"`
+	}
+
+A field whose name matches the transmitted name exactly takes precedence over
+an alias; a field may carry several comma-separated aliases. A field receives
+at most one transmitted value: if the transmitted type has fields under both
+the current name and an alias, or under several aliases, the one with the
+current name, or else the first one transmitted, is decoded and the others are
+ignored. Aliases apply only to the fields declared in the struct being decoded
+into, not to fields promoted from embedded structs; the fields of nested
+struct values use the aliases of their own types.
+
 Functions and channels will not be sent in a gob. Attempting to encode such a value
 at the top level will fail. A struct field of chan or func type is treated exactly
 like an unexported field and is ignored.
@@ -101,6 +117,12 @@ Gob can decode a value of any type implementing the [GobDecoder] or
 [encoding.BinaryUnmarshaler] interfaces by calling the corresponding method,
 again in that order of preference.
 
+A stream can also be decoded without the Go types that produced it.
+[Decoder.DecodeAny] returns each value as a tree of basic values, [*Struct],
+[MapEntry], [*Interface] and [*Opaque] values built from the type descriptions
+in the stream, and [Dump] prints a stream's types and values in a readable
+form.
+
 # Encoding Details
 
 This section documents the encoding, details that are not important for most
@@ -6,9 +6,6 @@
 
 package main
 
-// Need to compile package gob with debug.go to build this program.
-// See comments in debug.go for how to do this.
-
 import (
 	"encoding/gob"
 	"fmt"
@@ -26,5 +23,8 @@ func main() {
 		}
 		defer file.Close()
 	}
-	gob.Debug(file)
+	if err := gob.Dump(os.Stdout, file); err != nil {
+		fmt.Fprintf(os.Stderr, "dump: %s\n", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,402 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A Struct is a struct value decoded by [Decoder.DecodeAny].
+type Struct struct {
+	Type   string  // name of the struct type in the stream
+	Fields []Field // fields transmitted in the stream, in order
+}
+
+// A Field is a field of a [Struct].
+type Field struct {
+	Name  string
+	Value any
+}
+
+// Field returns the value of the field with the given name, or nil
+// if there is no such field. Fields with zero values are not transmitted
+// and so are not present in s.
+func (s *Struct) Field(name string) any {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+// A MapEntry is a key and value of a map decoded by [Decoder.DecodeAny].
+type MapEntry struct {
+	Key   any
+	Value any
+}
+
+// An Interface is a non-nil interface value decoded by [Decoder.DecodeAny].
+type Interface struct {
+	Name  string // name under which the concrete type was registered
+	Value any
+}
+
+// An Opaque is a value decoded by [Decoder.DecodeAny] whose type implements
+// [GobEncoder], [encoding.BinaryMarshaler] or [encoding.TextMarshaler],
+// and whose encoding is therefore known only to that type.
+type Opaque struct {
+	Type string // name of the type in the stream
+	Data []byte // data returned by the marshaling method
+}
+
+// DecodeAny reads the next value from the input stream and returns it as
+// a tree of generic values, using only the type information in the stream.
+// Unlike [Decoder.Decode], it needs neither a Go type for the value nor
+// registration of the concrete types of interface values.
+// Values are represented as follows:
+//
+//   - booleans as bool;
+//   - signed and unsigned integers as int64 and uint64;
+//   - floating-point and complex numbers as float64 and complex128;
+//   - strings as string, and byte slices as []byte;
+//   - arrays and slices as []any;
+//   - maps as []MapEntry, in stream order;
+//   - structs as *[Struct];
+//   - interface values as *[Interface], or nil if the interface is nil;
+//   - values of types implementing [GobEncoder], [encoding.BinaryMarshaler]
+//     or [encoding.TextMarshaler] as *[Opaque].
+//
+// If the input is at EOF, DecodeAny returns [io.EOF].
+// Values read by DecodeAny can be interleaved with values read by
+// [Decoder.Decode] on the same stream.
+func (dec *Decoder) DecodeAny() (any, error) {
+	dec.mutex.Lock()
+	defer dec.mutex.Unlock()
+
+	dec.buf.Reset() // In case data lingers from previous invocation.
+	dec.err = nil
+	id := dec.decodeTypeSequence(false)
+	if dec.err != nil {
+		return nil, dec.err
+	}
+	v := dec.decodeAnyValue(id)
+	return v, dec.err
+}
+
+// decodeAnyValue decodes the top-level value of type id in dec.buf, which
+// is a struct or a singleton.
+func (dec *Decoder) decodeAnyValue(id typeId) (v any) {
+	defer catchError(&dec.err)
+	state := dec.newDecoderState(&dec.buf)
+	defer dec.freeDecoderState(state)
+	if wire := dec.wireType[id]; wire != nil && wire.StructT != nil {
+		return dec.decodeAnyStruct(state, wire.StructT, 0)
+	}
+	if state.decodeUint() != 0 {
+		errorf("decode: corrupted data: non-zero delta for singleton")
+	}
+	return dec.decodeAny(state, id, 0)
+}
+
+// decodeAny decodes a value of type id. Depth is the nesting depth of the
+// value, which is limited to protect against malicious input.
+func (dec *Decoder) decodeAny(state *decoderState, id typeId, depth int) any {
+	if depth > maxIgnoreNestingDepth {
+		error_(errors.New("invalid nesting depth"))
+	}
+	depth++
+	switch id {
+	case tBool:
+		return state.decodeUint() != 0
+	case tInt:
+		return state.decodeInt()
+	case tUint:
+		return state.decodeUint()
+	case tFloat:
+		return float64FromBits(state.decodeUint())
+	case tComplex:
+		real := float64FromBits(state.decodeUint())
+		imag := float64FromBits(state.decodeUint())
+		return complex(real, imag)
+	case tBytes:
+		return slices.Clone(dec.decodeAnyBytes(state))
+	case tString:
+		return string(dec.decodeAnyBytes(state))
+	case tInterface:
+		return dec.decodeAnyInterface(state, depth)
+	}
+	wire := dec.wireType[id]
+	switch {
+	case wire == nil:
+		errorf("bad data: undefined type %s", id.string())
+	case wire.StructT != nil:
+		return dec.decodeAnyStruct(state, wire.StructT, depth)
+	case wire.ArrayT != nil:
+		if n := state.decodeUint(); n != uint64(wire.ArrayT.Len) {
+			errorf("length mismatch in decodeArray")
+		}
+		if wire.ArrayT.Len > state.b.Len() {
+			errorf("decoding array or slice: length exceeds input size (%d elements)", wire.ArrayT.Len)
+		}
+		return dec.decodeAnyElems(state, wire.ArrayT.Elem, wire.ArrayT.Len, depth)
+	case wire.SliceT != nil:
+		n, ok := state.getLength()
+		if !ok {
+			errorf("decoding array or slice: length exceeds input size (%d elements)", n)
+		}
+		return dec.decodeAnyElems(state, wire.SliceT.Elem, n, depth)
+	case wire.MapT != nil:
+		n, ok := state.getLength()
+		if !ok {
+			errorf("decoding map: length exceeds input size (%d elements)", n)
+		}
+		m := make([]MapEntry, n)
+		for i := range m {
+			m[i].Key = dec.decodeAny(state, wire.MapT.Key, depth)
+			m[i].Value = dec.decodeAny(state, wire.MapT.Elem, depth)
+		}
+		return m
+	case wire.GobEncoderT != nil, wire.BinaryMarshalerT != nil, wire.TextMarshalerT != nil:
+		return &Opaque{Type: wire.string(), Data: slices.Clone(dec.decodeAnyBytes(state))}
+	}
+	errorf("bad data: can't decode type %s", wire.string())
+	return nil
+}
+
+// decodeAnyBytes decodes a length-prefixed byte sequence. The result
+// aliases the decoder's buffer.
+func (dec *Decoder) decodeAnyBytes(state *decoderState) []byte {
+	n, ok := state.getLength()
+	if !ok {
+		errorf("invalid byte sequence length %d: exceeds input size %d", n, state.b.Len())
+	}
+	b := state.b.Bytes()[:n]
+	state.b.Drop(n)
+	return b
+}
+
+func (dec *Decoder) decodeAnyElems(state *decoderState, elem typeId, n, depth int) []any {
+	s := make([]any, n)
+	for i := range s {
+		s[i] = dec.decodeAny(state, elem, depth)
+	}
+	return s
+}
+
+func (dec *Decoder) decodeAnyStruct(state *decoderState, st *structType, depth int) *Struct {
+	s := &Struct{Type: st.Name}
+	fieldnum := -1
+	for state.b.Len() > 0 {
+		delta := int(state.decodeUint())
+		if delta < 0 {
+			errorf("decode: corrupted data: negative delta")
+		}
+		if delta == 0 { // struct terminator is zero delta fieldnum
+			break
+		}
+		if fieldnum >= len(st.Field)-delta { // subtract to compare without overflow
+			error_(errRange)
+		}
+		fieldnum += delta
+		f := st.Field[fieldnum]
+		s.Fields = append(s.Fields, Field{f.Name, dec.decodeAny(state, f.Id, depth)})
+	}
+	return s
+}
+
+// decodeAnyInterface decodes an interface value. See decodeInterface.
+func (dec *Decoder) decodeAnyInterface(state *decoderState, depth int) any {
+	name := dec.decodeAnyBytes(state)
+	if len(name) == 0 {
+		return nil
+	}
+	if len(name) > 1024 {
+		errorf("name too long (%d bytes): %.20q...", len(name), name)
+	}
+	i := &Interface{Name: string(name)}
+	id := dec.decodeTypeSequence(true)
+	if id < 0 {
+		error_(dec.err)
+	}
+	// Byte count of value is next; we don't need it.
+	state.decodeUint()
+	if wire := dec.wireType[id]; wire != nil && wire.StructT != nil {
+		i.Value = dec.decodeAnyStruct(state, wire.StructT, depth)
+	} else {
+		if state.decodeUint() != 0 {
+			errorf("decode: corrupted data: non-zero delta for singleton")
+		}
+		i.Value = dec.decodeAny(state, id, depth)
+	}
+	return i
+}
+
+// Dump writes a human-readable description of the gob stream read from r
+// to w. For each value in the stream, it writes the definitions of the
+// struct and marshaled types received before the value, followed by the
+// value itself as decoded by [Decoder.DecodeAny].
+//
+// Types are described using the names of the predefined gob types, such
+// as int and float, and the type names recorded in the stream.
+//
+// Dump returns nil when it reaches the end of r.
+func Dump(w io.Writer, r io.Reader) error {
+	d := &dumper{dec: NewDecoder(r), printed: make(map[typeId]bool)}
+	for {
+		v, err := d.dec.DecodeAny()
+		if err == io.EOF {
+			return nil
+		}
+		d.types()
+		if err == nil {
+			d.value(v, 0)
+			d.b.WriteByte('\n')
+		}
+		if _, werr := io.WriteString(w, d.b.String()); werr != nil {
+			return werr
+		}
+		d.b.Reset()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type dumper struct {
+	dec     *Decoder
+	printed map[typeId]bool // types whose definitions have been written
+	b       strings.Builder
+}
+
+// types writes the definitions of the types received since the last call.
+func (d *dumper) types() {
+	var ids []typeId
+	for id := range d.dec.wireType {
+		if !d.printed[id] {
+			d.printed[id] = true
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		wire := d.dec.wireType[id]
+		switch {
+		case wire.StructT != nil:
+			d.b.WriteString("type " + d.typeName(id) + " struct {\n")
+			for _, f := range wire.StructT.Field {
+				d.b.WriteString("\t" + f.Name + " " + d.typeName(f.Id) + "\n")
+			}
+			d.b.WriteString("}\n")
+		case wire.GobEncoderT != nil:
+			d.b.WriteString("type " + wire.string() + " GobEncoder\n")
+		case wire.BinaryMarshalerT != nil:
+			d.b.WriteString("type " + wire.string() + " BinaryMarshaler\n")
+		case wire.TextMarshalerT != nil:
+			d.b.WriteString("type " + wire.string() + " TextMarshaler\n")
+		}
+	}
+}
+
+// typeName returns the name of the type id, spelling out array, slice and
+// map types.
+func (d *dumper) typeName(id typeId) string {
+	if id < firstUserId {
+		if t := builtinIdToType(id); t != nil {
+			return t.name()
+		}
+	}
+	wire := d.dec.wireType[id]
+	switch {
+	case wire == nil:
+		return id.string()
+	case wire.ArrayT != nil:
+		return "[" + strconv.Itoa(wire.ArrayT.Len) + "]" + d.typeName(wire.ArrayT.Elem)
+	case wire.SliceT != nil:
+		return "[]" + d.typeName(wire.SliceT.Elem)
+	case wire.MapT != nil:
+		return "map[" + d.typeName(wire.MapT.Key) + "]" + d.typeName(wire.MapT.Elem)
+	case wire.StructT != nil && wire.StructT.Name == "":
+		return "struct"
+	}
+	return wire.string()
+}
+
+func (d *dumper) indent(depth int) {
+	for range depth {
+		d.b.WriteByte('\t')
+	}
+}
+
+// value writes v, starting at the current position and indenting nested
+// lines by depth tabs.
+func (d *dumper) value(v any, depth int) {
+	switch v := v.(type) {
+	case nil:
+		d.b.WriteString("nil")
+	case string:
+		d.b.WriteString(strconv.Quote(v))
+	case []byte:
+		fmt.Fprintf(&d.b, "[]byte(%q)", v)
+	case *Struct:
+		name := v.Type
+		if name == "" {
+			name = "struct"
+		}
+		if len(v.Fields) == 0 {
+			d.b.WriteString(name + "{}")
+			return
+		}
+		d.b.WriteString(name + "{\n")
+		for _, f := range v.Fields {
+			d.indent(depth + 1)
+			d.b.WriteString(f.Name + ": ")
+			d.value(f.Value, depth+1)
+			d.b.WriteByte('\n')
+		}
+		d.indent(depth)
+		d.b.WriteByte('}')
+	case []any:
+		if len(v) == 0 {
+			d.b.WriteString("[]")
+			return
+		}
+		d.b.WriteString("[\n")
+		for _, e := range v {
+			d.indent(depth + 1)
+			d.value(e, depth+1)
+			d.b.WriteByte('\n')
+		}
+		d.indent(depth)
+		d.b.WriteByte(']')
+	case []MapEntry:
+		if len(v) == 0 {
+			d.b.WriteString("map[]")
+			return
+		}
+		d.b.WriteString("map[\n")
+		for _, e := range v {
+			d.indent(depth + 1)
+			d.value(e.Key, depth+1)
+			d.b.WriteString(": ")
+			d.value(e.Value, depth+1)
+			d.b.WriteByte('\n')
+		}
+		d.indent(depth)
+		d.b.WriteByte(']')
+	case *Interface:
+		d.b.WriteString("(" + v.Name + ") ")
+		d.value(v.Value, depth)
+	case *Opaque:
+		fmt.Fprintf(&d.b, "%s(%x)", v.Type, v.Data)
+	default:
+		fmt.Fprint(&d.b, v)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gob
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type genericInner struct {
+	A int
+	B []string
+}
+
+type genericOuter struct {
+	Name    string
+	Inner   genericInner
+	Ptr     *genericInner
+	Bytes   []byte
+	Array   [2]uint8
+	Map     map[string]float64
+	Complex complex128
+	Flag    bool
+	Iface   any
+	Nil     any
+	Time    time.Time
+	Zero    int
+}
+
+func TestDecodeAny(t *testing.T) {
+	Register(genericInner{})
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tmData, err := tm.GobEncode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := genericOuter{
+		Name:    "outer",
+		Inner:   genericInner{A: -3, B: []string{"x", "y"}},
+		Ptr:     &genericInner{A: 7},
+		Bytes:   []byte("raw"),
+		Array:   [2]uint8{1, 2},
+		Map:     map[string]float64{"pi": 3.25},
+		Complex: 1 + 2i,
+		Flag:    true,
+		Iface:   genericInner{A: 1},
+		Time:    tm,
+	}
+	want := &Struct{Type: "genericOuter", Fields: []Field{
+		{"Name", "outer"},
+		{"Inner", &Struct{Type: "genericInner", Fields: []Field{
+			{"A", int64(-3)},
+			{"B", []any{"x", "y"}},
+		}}},
+		{"Ptr", &Struct{Type: "genericInner", Fields: []Field{{"A", int64(7)}}}},
+		{"Bytes", []byte("raw")},
+		{"Array", []any{uint64(1), uint64(2)}},
+		{"Map", []MapEntry{{"pi", 3.25}}},
+		{"Complex", 1 + 2i},
+		{"Flag", true},
+		{"Iface", &Interface{
+			Name:  "encoding/gob.genericInner",
+			Value: &Struct{Type: "genericInner", Fields: []Field{{"A", int64(1)}}},
+		}},
+		{"Time", &Opaque{Type: "Time", Data: tmData}},
+	}}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, v := range []any{in, in, 42, []string{"a"}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewDecoder(&buf)
+	for i := range 2 {
+		got, err := dec.DecodeAny()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("DecodeAny #%d:\ngot  %#v\nwant %#v", i, got, want)
+		}
+	}
+	// DecodeAny and Decode can be mixed.
+	var n int
+	if err := dec.Decode(&n); err != nil || n != 42 {
+		t.Errorf("Decode = %d, %v; want 42, nil", n, err)
+	}
+	got, err := dec.DecodeAny()
+	if err != nil || !reflect.DeepEqual(got, []any{"a"}) {
+		t.Errorf("DecodeAny = %#v, %v; want [a], nil", got, err)
+	}
+	if got, err := dec.DecodeAny(); err != io.EOF {
+		t.Errorf("DecodeAny at EOF = %#v, %v; want io.EOF", got, err)
+	}
+	if f := want.Field("Name"); f != "outer" {
+		t.Errorf("Field(Name) = %v", f)
+	}
+	if f := want.Field("Zero"); f != nil {
+		t.Errorf("Field(Zero) = %v, want nil", f)
+	}
+}
+
+func TestDecodeAnyRecursive(t *testing.T) {
+	type Tree struct {
+		Value    int
+		Children []*Tree
+	}
+	tree := &Tree{1, []*Tree{{2, nil}, {3, []*Tree{{4, nil}}}}}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(tree); err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewDecoder(&buf).DecodeAny()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := func(v int64, children ...any) *Struct {
+		s := &Struct{Type: "Tree", Fields: []Field{{"Value", v}}}
+		if len(children) > 0 {
+			s.Fields = append(s.Fields, Field{"Children", children})
+		}
+		return s
+	}
+	want := node(1, node(2), node(3, node(4)))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeAny:\ngot  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestDecodeAnyCorrupt(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(genericInner{A: 1, B: []string{"abc"}}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	for i := range data {
+		for _, b := range []byte{0x00, 0x7f, 0xff} {
+			corrupt := bytes.Clone(data)
+			corrupt[i] = b
+			// Errors are expected; panics are not.
+			NewDecoder(bytes.NewReader(corrupt)).DecodeAny()
+		}
+		NewDecoder(bytes.NewReader(data[:i])).DecodeAny()
+	}
+}
+
+func TestDump(t *testing.T) {
+	type Point struct {
+		X, Y int
+	}
+	type Shape struct {
+		Name   string
+		Points []Point
+		Attrs  map[string]any
+		Data   []byte
+	}
+	RegisterName("dump.Point", Point{})
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	s := Shape{
+		Name:   "line",
+		Points: []Point{{1, 2}, {3, 0}},
+		Attrs:  map[string]any{"at": Point{X: -1}},
+		Data:   []byte{0xff},
+	}
+	for _, v := range []any{s, Shape{}, 3.5} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const want = `type Shape struct {
+	Name string
+	Points []Point
+	Attrs map[string]interface
+	Data bytes
+}
+type Point struct {
+	X int
+	Y int
+}
+Shape{
+	Name: "line"
+	Points: [
+		Point{
+			X: 1
+			Y: 2
+		}
+		Point{
+			X: 3
+		}
+	]
+	Attrs: map[
+		"at": (dump.Point) Point{
+			X: -1
+		}
+	]
+	Data: []byte("\xff")
+}
+Shape{}
+3.5
+`
+	var out strings.Builder
+	if err := Dump(&out, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != want {
+		t.Errorf("Dump:\n%s\nwant:\n%s", got, want)
+	}
+
+	out.Reset()
+	err := Dump(&out, strings.NewReader("\x05\xff\x81\x03\x01"))
+	if err == nil {
+		t.Errorf("Dump of corrupt data succeeded")
+	}
+}
+
+func TestFieldAlias(t *testing.T) {
+	type Old struct {
+		Name  string
+		Count int
+		Other string
+	}
+	type New struct {
+		FullName string `gob:"alias=Name"`
+		Total    int    `gob:"alias=Num,alias=Count"`
+		Other    string `gob:"alias=Name"` // exact names take precedence
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(Old{"gopher", 3, "x"}); err != nil {
+		t.Fatal(err)
+	}
+	var got New
+	if err := NewDecoder(&buf).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (New{"gopher", 3, "x"}); got != want {
+		t.Errorf("Decode = %+v, want %+v", got, want)
+	}
+
+	// An alias must still have a compatible type.
+	type Bad struct {
+		N string `gob:"alias=Count"`
+	}
+	buf.Reset()
+	if err := NewEncoder(&buf).Encode(Old{Count: 1}); err != nil {
+		t.Fatal(err)
+	}
+	var bad Bad
+	if err := NewDecoder(&buf).Decode(&bad); err == nil {
+		t.Errorf("Decode into incompatible alias succeeded")
+	}
+}
+
+func TestFieldAliasConflict(t *testing.T) {
+	// The wire type carries both the old and new names of a field,
+	// and two aliases of another field.
+	type Both struct {
+		Name     string
+		FullName string
+		Count    int
+		Num      int
+	}
+	type New struct {
+		FullName string `gob:"alias=Name"`
+		Total    int    `gob:"alias=Num,alias=Count"`
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(Both{"old", "new", 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	var got New
+	if err := NewDecoder(&buf).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	// FullName is decoded from the field with its own name, and
+	// Total from the first transmitted field matching an alias.
+	if want := (New{"new", 1}); got != want {
+		t.Errorf("Decode = %+v, want %+v", got, want)
+	}
+
+	// Nested structs use their own aliases.
+	type Old struct {
+		Name  string
+		Count int
+	}
+	type Outer struct {
+		Inner Old
+	}
+	type NewOuter struct {
+		Inner New
+	}
+	buf.Reset()
+	if err := NewEncoder(&buf).Encode(Outer{Old{"old", 3}}); err != nil {
+		t.Fatal(err)
+	}
+	var nested NewOuter
+	if err := NewDecoder(&buf).Decode(&nested); err != nil {
+		t.Fatal(err)
+	}
+	if want := (NewOuter{New{"old", 3}}); nested != want {
+		t.Errorf("Decode = %+v, want %+v", nested, want)
+	}
+}
@@ -437,6 +437,16 @@ func (s *structType) safeString(seen map[typeId]bool) string {
 
 func (s *structType) string() string { return s.safeString(make(map[typeId]bool)) }
 
+// hasField reports whether s has a field with the given name.
+func (s *structType) hasField(name string) bool {
+	for _, f := range s.Field {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func newStructType(name string) *structType {
 	s := &structType{CommonType{Name: name}, nil}
 	// For historical reasons we set the id here rather than init.
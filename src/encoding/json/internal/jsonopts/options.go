@@ -0,0 +1,147 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jsonopts implements the options shared by the
+// encoding/json/jsontext and encoding/json/v2 packages.
+package jsonopts
+
+// NotForPublicUse is a marker type that prevents packages outside
+// the standard library from implementing [Options].
+type NotForPublicUse struct{}
+
+// Options is the set of options that configure encoding and decoding.
+// It is aliased as jsontext.Options and json.Options.
+type Options interface {
+	JSONOptions(NotForPublicUse)
+}
+
+// Flags is a set of boolean options.
+type Flags uint64
+
+const (
+	// Options declared by jsontext.
+	AllowDuplicateNames Flags = 1 << iota
+	AllowInvalidUTF8
+	EscapeForHTML
+	EscapeForJS
+	Multiline
+	SpaceAfterColon
+	SpaceAfterComma
+
+	// Options declared by json.
+	Deterministic
+	FormatDurationAsNano
+	FormatNilMapAsNull
+	FormatNilSliceAsNull
+	MatchCaseInsensitiveNames
+	OmitZeroStructFields
+	RejectUnknownMembers
+	StringifyNumbers
+)
+
+// SyntacticFlags are the flags that affect the JSON text
+// produced by an Encoder or accepted by a Decoder.
+const SyntacticFlags = AllowDuplicateNames | AllowInvalidUTF8 |
+	EscapeForHTML | EscapeForJS |
+	Multiline | SpaceAfterColon | SpaceAfterComma
+
+// Bools is an option that sets the flags in Mask to the
+// corresponding values in Value.
+type Bools struct {
+	Mask  Flags
+	Value Flags
+}
+
+// Set returns a Bools option that sets the flags in mask to v.
+func Set(mask Flags, v bool) Bools {
+	if v {
+		return Bools{Mask: mask, Value: mask}
+	}
+	return Bools{Mask: mask}
+}
+
+// Indent is an option that sets the indentation string.
+type Indent string
+
+// IndentPrefix is an option that sets the indentation prefix.
+type IndentPrefix string
+
+// Marshalers is an option that holds a *json.Marshalers.
+type Marshalers struct{ V any }
+
+// Unmarshalers is an option that holds a *json.Unmarshalers.
+type Unmarshalers struct{ V any }
+
+func (Bools) JSONOptions(NotForPublicUse)        {}
+func (Indent) JSONOptions(NotForPublicUse)       {}
+func (IndentPrefix) JSONOptions(NotForPublicUse) {}
+func (Marshalers) JSONOptions(NotForPublicUse)   {}
+func (Unmarshalers) JSONOptions(NotForPublicUse) {}
+func (*Struct) JSONOptions(NotForPublicUse)      {}
+
+// Struct is the combination of a set of options.
+// The zero value is the set of default options.
+type Struct struct {
+	Flags Flags // values of boolean options
+
+	Indent       string // empty means a tab when Multiline is set
+	IndentPrefix string
+
+	Marshalers   any // *json.Marshalers or nil
+	Unmarshalers any // *json.Unmarshalers or nil
+}
+
+// Join updates s with the given options.
+// Later options take precedence over earlier ones.
+func (s *Struct) Join(opts ...Options) {
+	for _, opt := range opts {
+		switch opt := opt.(type) {
+		case nil:
+		case Bools:
+			s.Flags = s.Flags&^opt.Mask | opt.Value&opt.Mask
+		case Indent:
+			s.Indent = string(opt)
+			s.Flags |= Multiline
+		case IndentPrefix:
+			s.IndentPrefix = string(opt)
+			s.Flags |= Multiline
+		case Marshalers:
+			s.Marshalers = opt.V
+		case Unmarshalers:
+			s.Unmarshalers = opt.V
+		case *Struct:
+			*s = *opt
+		default:
+			panic("jsonopts: unknown option type")
+		}
+	}
+}
+
+// Get reports whether any of the flags in f are set.
+func (s *Struct) Get(f Flags) bool {
+	return s.Flags&f != 0
+}
+
+// JoinSemantic is like Join, but leaves the options
+// that affect the syntax of the JSON text unchanged.
+func (s *Struct) JoinSemantic(opts ...Options) {
+	prev := *s
+	s.Join(opts...)
+	s.Flags = s.Flags&^SyntacticFlags | prev.Flags&SyntacticFlags
+	s.Indent, s.IndentPrefix = prev.Indent, prev.IndentPrefix
+}
+
+// EncoderOptions and DecoderOptions return a pointer to the options of
+// a *jsontext.Encoder or *jsontext.Decoder. They are set by package
+// jsontext so that package json can apply semantic options
+// to an existing Encoder or Decoder.
+var (
+	EncoderOptions func(enc any) *Struct
+	DecoderOptions func(dec any) *Struct
+)
+
+// EncoderSeenPointers returns a pointer to the set of pointers, maps,
+// and slices that package json is marshaling with a *jsontext.Encoder,
+// which it uses to detect cycles. It is set by package jsontext.
+var EncoderSeenPointers func(enc any) *map[any]struct{}
@@ -0,0 +1,497 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"bytes"
+	"io"
+
+	"encoding/json/internal/jsonopts"
+)
+
+// Decoder is a streaming decoder for raw JSON tokens and values.
+// It is used to read a stream of top-level JSON values,
+// each separated by optional whitespace characters.
+//
+// [Decoder.ReadToken] and [Decoder.ReadValue] calls may be interleaved.
+// For example, the following JSON value:
+//
+//	{"name":"value","array":[null,false,true,3.14159],"object":{"k":"v"}}
+//
+// can be parsed with the following calls (ignoring errors for brevity):
+//
+//	d.ReadToken() // {
+//	d.ReadToken() // "name"
+//	d.ReadToken() // "value"
+//	d.ReadValue() // "array"
+//	d.ReadToken() // [
+//	d.ReadToken() // null
+//	d.ReadToken() // false
+//	d.ReadValue() // true
+//	d.ReadToken() // 3.14159
+//	d.ReadToken() // ]
+//	d.ReadValue() // "object"
+//	d.ReadValue() // {"k":"v"}
+//	d.ReadToken() // }
+//
+// The above is one of many possible sequence of calls and
+// may not represent the most sensible method to call for any given token/value.
+// For example, it is probably more common to call [Decoder.ReadToken] to obtain a
+// string token for object names.
+//
+// Tokens and values returned by the Decoder refer to its internal
+// buffer and are only valid until the next call to a method of the Decoder.
+type Decoder struct {
+	s decoderState
+}
+
+// decodeBuffer is the input buffer of a Decoder.
+type decodeBuffer struct {
+	// buf holds the input that has been read but not discarded.
+	// The most recently read token or value is buf[prevStart:prevEnd],
+	// and the unread input is buf[prevEnd:].
+	buf       []byte
+	prevStart int
+	prevEnd   int
+
+	// baseOffset is the absolute input offset of buf[0].
+	baseOffset int64
+
+	rd  io.Reader // nil if buf holds the entire input
+	eof bool      // whether rd has reported io.EOF
+}
+
+type decoderState struct {
+	state
+	decodeBuffer
+	opts jsonopts.Struct
+
+	// valueNamespaces is used by ReadValue to check
+	// for duplicate names within a value.
+	valueNamespaces namespaceStack
+}
+
+// NewDecoder constructs a new streaming decoder reading from r.
+//
+// If r is a [bytes.Buffer], then the decoder parses directly from the buffer
+// without first copying the contents to an intermediate buffer.
+// Additional writes to the buffer must not occur while the decoder is in use.
+func NewDecoder(r io.Reader, opts ...Options) *Decoder {
+	d := new(Decoder)
+	d.Reset(r, opts...)
+	return d
+}
+
+// Reset resets a decoder such that it is reading afresh from r and
+// configured with the provided options. Reset must not be called on
+// a Decoder passed to the [encoding/json/v2.UnmarshalerFrom.UnmarshalJSONFrom]
+// method or the [encoding/json/v2.UnmarshalFromFunc] function.
+func (d *Decoder) Reset(r io.Reader, opts ...Options) {
+	switch {
+	case d == nil:
+		panic("jsontext: invalid nil Decoder")
+	case r == nil:
+		panic("jsontext: invalid nil io.Reader")
+	}
+	if bb, ok := r.(*bytes.Buffer); ok {
+		d.s.reset(bb.Bytes(), nil, opts...)
+		bb.Next(bb.Len())
+		return
+	}
+	d.s.reset(nil, r, opts...)
+}
+
+func (d *decoderState) reset(b []byte, r io.Reader, opts ...Options) {
+	d.state.reset()
+	if b == nil && d.rd != nil {
+		// Reuse the buffer, unless it was borrowed from the caller.
+		b = d.buf[:0]
+	}
+	d.decodeBuffer = decodeBuffer{buf: b, rd: r}
+	d.opts = jsonopts.Struct{}
+	d.opts.Join(opts...)
+}
+
+// Options returns the options used to construct the decoder and
+// may additionally contain semantic options passed to a
+// [encoding/json/v2.UnmarshalDecode] call.
+func (d *Decoder) Options() Options {
+	opts := d.s.opts
+	return &opts
+}
+
+// fetch reads more input into the buffer, discarding any input
+// before prevEnd, which must equal prevStart.
+// It returns io.EOF at the end of the input.
+func (d *decodeBuffer) fetch() error {
+	if d.rd == nil || d.eof {
+		d.eof = true
+		return io.EOF
+	}
+
+	// Discard input that has already been consumed.
+	if d.prevEnd > 0 {
+		n := copy(d.buf, d.buf[d.prevEnd:])
+		d.buf = d.buf[:n]
+		d.baseOffset += int64(d.prevEnd)
+		d.prevStart, d.prevEnd = 0, 0
+	}
+
+	// Grow the buffer geometrically so that restarting the
+	// consumption of a long token has amortized linear cost.
+	if len(d.buf) == cap(d.buf) {
+		b := make([]byte, len(d.buf), max(2*cap(d.buf), 4<<10))
+		copy(b, d.buf)
+		d.buf = b
+	}
+
+	for range 100 {
+		n, err := d.rd.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.buf = d.buf[:len(d.buf)+n]
+		if n > 0 {
+			return nil // report any error on the next call
+		}
+		if err != nil {
+			if err == io.EOF {
+				d.eof = true
+			}
+			return err
+		}
+	}
+	return io.ErrNoProgress
+}
+
+// newSyntacticError returns a SyntacticError for an error that
+// occurred at offset pos relative to prevEnd.
+func (d *decoderState) newSyntacticError(pos int, err error) error {
+	return &SyntacticError{
+		ByteOffset:  d.baseOffset + int64(d.prevEnd+pos),
+		JSONPointer: d.stackPointer(),
+		Err:         err,
+	}
+}
+
+// consumeFetch calls consume on the unread input starting at pos,
+// fetching more input as needed, and returns the length of the
+// element that consume found.
+func (d *decoderState) consumeFetch(pos int, consume func([]byte) (int, error)) (int, error) {
+	for {
+		b := d.buf[d.prevEnd+pos:]
+		n, err := consume(b)
+		// An element that ends in a digit may be a number
+		// that continues in the input that has not been read yet.
+		incomplete := err == io.ErrUnexpectedEOF ||
+			err == nil && n == len(b) && n > 0 && '0' <= b[n-1] && b[n-1] <= '9'
+		if !incomplete || d.eof {
+			if err != nil {
+				return 0, d.newSyntacticError(pos+n, err)
+			}
+			return n, nil
+		}
+		if err := d.fetch(); err != nil && err != io.EOF {
+			return 0, err
+		}
+	}
+}
+
+// skipToToken skips whitespace and any delimiter preceding the next token
+// and returns its offset relative to prevEnd.
+// It returns io.EOF if the input ends between top-level values.
+func (d *decoderState) skipToToken() (int, error) {
+	pos, err := d.skipWhitespace(0)
+	if err != nil {
+		return 0, err
+	}
+	c := d.buf[d.prevEnd+pos]
+	delim := d.tokens.needDelim(kindOf(c))
+	if delim == 0 {
+		return pos, nil
+	}
+	if c != delim {
+		var where string
+		switch e := d.tokens.Last(); {
+		case delim == ':':
+			where = "after object name (expecting ':')"
+		case e.isObject():
+			where = "after object value (expecting ',' or '}')"
+		default:
+			where = "after array element (expecting ',' or ']')"
+		}
+		return 0, d.newSyntacticError(pos, newInvalidCharacterError(d.buf[d.prevEnd+pos:], where))
+	}
+	pos, err = d.skipWhitespace(pos + 1)
+	if err == io.EOF {
+		err = d.newSyntacticError(pos, io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if c := d.buf[d.prevEnd+pos]; delim == ',' && (c == '}' || c == ']') {
+		return 0, d.newSyntacticError(pos, errTrailingComma)
+	}
+	return pos, nil
+}
+
+// skipWhitespace skips whitespace starting at pos relative to prevEnd,
+// fetching more input as needed, and returns the offset of the
+// following byte.
+func (d *decoderState) skipWhitespace(pos int) (int, error) {
+	for {
+		b := d.buf[d.prevEnd:]
+		pos += consumeWhitespace(b[pos:])
+		if pos < len(b) {
+			return pos, nil
+		}
+		switch err := d.fetch(); {
+		case err == io.EOF && d.tokens.Depth() > 1:
+			return 0, d.newSyntacticError(pos, io.ErrUnexpectedEOF)
+		case err != nil:
+			return 0, err
+		}
+	}
+}
+
+// commit records that the element at pos with length n has been read.
+func (d *decoderState) commit(pos, n int) {
+	d.prevStart = d.prevEnd + pos
+	d.prevEnd = d.prevStart + n
+}
+
+// PeekKind retrieves the next token kind, but does not advance the read offset.
+//
+// It returns 0 if an error occurs. Any such error is cached until
+// the next read call and it is the caller's responsibility to eventually
+// follow up a PeekKind call with a read call.
+func (d *Decoder) PeekKind() Kind {
+	d.s.prevStart = d.s.prevEnd
+	pos, err := d.s.skipToToken()
+	if err != nil {
+		return invalidKind
+	}
+	return kindOf(d.s.buf[d.s.prevEnd+pos])
+}
+
+// SkipValue is semantically equivalent to calling [Decoder.ReadValue] and discarding
+// the result except that memory is not wasted trying to hold the entire result.
+func (d *Decoder) SkipValue() error {
+	_, err := d.s.ReadValue()
+	return err
+}
+
+// ReadToken reads the next [Token], advancing the read offset.
+// The returned token is only valid until the next Peek, Read, or Skip call.
+// It returns [io.EOF] if there are no more tokens.
+func (d *Decoder) ReadToken() (Token, error) {
+	return d.s.ReadToken()
+}
+
+func (d *decoderState) ReadToken() (Token, error) {
+	d.prevStart = d.prevEnd
+	pos, err := d.skipToToken()
+	if err != nil {
+		return Token{}, err
+	}
+
+	switch k := kindOf(d.buf[d.prevEnd+pos]); k {
+	case 'n', 'f', 't':
+		if d.tokens.Last().needObjectName() {
+			return Token{}, d.newSyntacticError(pos, ErrNonStringName)
+		}
+		lit := k.literal()
+		n, err := d.consumeFetch(pos, func(b []byte) (int, error) { return consumeLiteral(b, lit) })
+		if err != nil {
+			return Token{}, err
+		}
+		d.tokens.increment()
+		d.commit(pos, n)
+		return Token{kind: k}, nil
+
+	case '"':
+		allowInvalid := d.opts.Get(jsonopts.AllowInvalidUTF8)
+		var verbatim bool
+		n, err := d.consumeFetch(pos, func(b []byte) (n int, err error) {
+			n, verbatim, err = consumeString(b, allowInvalid)
+			return n, err
+		})
+		if err != nil {
+			return Token{}, err
+		}
+		if d.tokens.Last().needObjectName() {
+			if err := d.readName(pos, d.buf[d.prevEnd+pos:][:n], verbatim); err != nil {
+				return Token{}, err
+			}
+		}
+		d.tokens.increment()
+		d.commit(pos, n)
+		return Token{raw: &d.decodeBuffer, num: uint64(d.baseOffset + int64(d.prevStart))}, nil
+
+	case '0':
+		if d.tokens.Last().needObjectName() {
+			return Token{}, d.newSyntacticError(pos, ErrNonStringName)
+		}
+		n, err := d.consumeFetch(pos, consumeNumber)
+		if err != nil {
+			return Token{}, err
+		}
+		d.tokens.increment()
+		d.commit(pos, n)
+		return Token{raw: &d.decodeBuffer, num: uint64(d.baseOffset + int64(d.prevStart))}, nil
+
+	case '{', '[':
+		var err error
+		if k == '{' {
+			err = d.tokens.pushObject()
+		} else {
+			err = d.tokens.pushArray()
+		}
+		if err != nil {
+			return Token{}, d.newSyntacticError(pos, err)
+		}
+		d.names.push()
+		if k == '{' && !d.opts.Get(jsonopts.AllowDuplicateNames) {
+			d.namespaces.push()
+		}
+		d.commit(pos, 1)
+		return Token{kind: k}, nil
+
+	case '}', ']':
+		var err error
+		if k == '}' {
+			err = d.tokens.popObject()
+		} else {
+			err = d.tokens.popArray()
+		}
+		if err != nil {
+			return Token{}, d.newSyntacticError(pos, err)
+		}
+		d.names.pop()
+		if k == '}' && !d.opts.Get(jsonopts.AllowDuplicateNames) {
+			d.namespaces.pop()
+		}
+		d.commit(pos, 1)
+		return Token{kind: k}, nil
+
+	default:
+		b := d.buf[d.prevEnd+pos:]
+		return Token{}, d.newSyntacticError(pos, newInvalidCharacterError(b, "at start of value"))
+	}
+}
+
+// readName records the quoted object member name at pos
+// and checks that it is not a duplicate.
+func (d *decoderState) readName(pos int, quoted []byte, verbatim bool) error {
+	buf := d.names.setBuffer()
+	if verbatim {
+		buf = append(buf, quoted[1:len(quoted)-1]...)
+	} else {
+		buf = appendUnquote(buf, quoted)
+	}
+	start := len(d.names.setBuffer())
+	d.names.setEnd(buf)
+	if !d.opts.Get(jsonopts.AllowDuplicateNames) && !d.namespaces.last().insert(buf[start:]) {
+		d.tokens.increment() // so that the error points to the name
+		err := d.newSyntacticError(pos, ErrDuplicateName)
+		d.tokens[len(d.tokens)-1]--
+		return err
+	}
+	return nil
+}
+
+// ReadValue returns the next raw JSON value, advancing the read offset.
+// The value is stripped of any leading or trailing whitespace and
+// contains the exact bytes of the input, which may contain invalid UTF-8
+// if [AllowInvalidUTF8] is specified.
+//
+// The returned value is only valid until the next Peek, Read, or Skip call and
+// may not be mutated while the Decoder remains in use.
+// If the decoder is currently at the end token for an object or array,
+// then it reports a [SyntacticError] and the internal state remains unchanged.
+// It returns [io.EOF] if there are no more values.
+func (d *Decoder) ReadValue() (Value, error) {
+	return d.s.ReadValue()
+}
+
+func (d *decoderState) ReadValue() (Value, error) {
+	d.prevStart = d.prevEnd
+	pos, err := d.skipToToken()
+	if err != nil {
+		return nil, err
+	}
+
+	b := d.buf[d.prevEnd+pos:]
+	k := kindOf(b[0])
+	switch {
+	case k == '}' || k == ']':
+		return nil, d.newSyntacticError(pos, newInvalidCharacterError(b, "at start of value"))
+	case k != '"' && d.tokens.Last().needObjectName():
+		return nil, d.newSyntacticError(pos, ErrNonStringName)
+	case d.tokens.Depth() > maxNestingDepth:
+		return nil, d.newSyntacticError(pos, errMaxDepth)
+	}
+
+	flags := flagsOf(&d.opts)
+	n, err := d.consumeFetch(pos, func(b []byte) (int, error) {
+		return consumeValue(b, flags, &d.valueNamespaces, d.tokens.Depth())
+	})
+	if err != nil {
+		return nil, err
+	}
+	b = d.buf[d.prevEnd+pos:][:n]
+	if k == '"' && d.tokens.Last().needObjectName() {
+		n, verbatim, _ := consumeString(b, true)
+		if err := d.readName(pos, b[:n], verbatim); err != nil {
+			return nil, err
+		}
+	}
+	d.tokens.increment()
+	d.commit(pos, n)
+	return Value(b), nil
+}
+
+// InputOffset returns the current input byte offset. It gives the location
+// of the next byte immediately after the most recently returned token or value.
+// The number of bytes actually read from the underlying [io.Reader] may be more
+// than this offset due to internal buffering effects.
+func (d *Decoder) InputOffset() int64 {
+	return d.s.baseOffset + int64(d.s.prevEnd)
+}
+
+// UnreadBuffer returns the data remaining in the unread buffer,
+// which may contain zero or more bytes.
+// The returned buffer must not be mutated while Decoder continues to be used.
+// The buffer contents are valid until the next Peek, Read, or Skip call.
+func (d *Decoder) UnreadBuffer() []byte {
+	return d.s.buf[d.s.prevEnd:]
+}
+
+// StackDepth returns the depth of the state machine for read JSON data.
+// Each level on the stack represents a nested JSON object or array.
+// It is incremented whenever an [ObjectStart] or [ArrayStart] token is encountered
+// and decremented whenever an [ObjectEnd] or [ArrayEnd] token is encountered.
+// The depth is zero-indexed, where zero represents the top-level JSON value.
+func (d *Decoder) StackDepth() int {
+	return d.s.tokens.Depth() - 1
+}
+
+// StackIndex returns information about the specified stack level.
+// It must be a number between 0 and [Decoder.StackDepth], inclusive.
+// For each level, it reports the kind:
+//
+//   - 0 for a level of zero,
+//   - '{' for a level representing a JSON object, and
+//   - '[' for a level representing a JSON array.
+//
+// It also reports the length of that JSON object or array.
+// Each name and value in a JSON object is counted separately,
+// so the effective number of members would be half the length.
+// A complete JSON object must have an even length.
+func (d *Decoder) StackIndex(i int) (Kind, int64) {
+	return d.s.stackIndex(i)
+}
+
+// StackPointer returns a JSON Pointer (RFC 6901) to the most recently read value.
+func (d *Decoder) StackPointer() Pointer {
+	return d.s.stackPointer()
+}
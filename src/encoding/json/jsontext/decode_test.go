@@ -0,0 +1,208 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"bytes"
+	"errors"
+	"internal/testenv"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// readTokens reads all tokens from d, returning their string forms.
+func readTokens(d *Decoder) ([]string, error) {
+	var got []string
+	for {
+		tok, err := d.ReadToken()
+		if err == io.EOF {
+			return got, nil
+		}
+		if err != nil {
+			return got, err
+		}
+		got = append(got, tok.Kind().String()+":"+tok.String())
+	}
+}
+
+func TestDecoderTokens(t *testing.T) {
+	const in = ` {"name" : "value", "array":[null, false ,true,-3.14e+5, "é😀"], "object": {}} 5 ""`
+	want := []string{
+		"{:{", "string:name", "string:value", "string:array",
+		"[:[", "null:null", "false:false", "true:true", "number:-3.14e+5", "string:é\U0001f600", "]:]",
+		"string:object", "{:{", "}:}", "}:}",
+		"number:5", "string:",
+	}
+	readers := map[string]func() io.Reader{
+		"Bytes":    func() io.Reader { return bytes.NewBufferString(in) },
+		"Reader":   func() io.Reader { return strings.NewReader(in) },
+		"OneByte":  func() io.Reader { return iotest.OneByteReader(strings.NewReader(in)) },
+		"DataErr":  func() io.Reader { return iotest.DataErrReader(strings.NewReader(in)) },
+		"HalfRead": func() io.Reader { return iotest.HalfReader(strings.NewReader(in)) },
+	}
+	for name, r := range readers {
+		got, err := readTokens(NewDecoder(r()))
+		if err != nil {
+			t.Errorf("%s: ReadToken error: %v", name, err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: tokens mismatch:\ngot  %q\nwant %q", name, got, want)
+		}
+	}
+}
+
+func TestDecoderReadValue(t *testing.T) {
+	const in = `{"a":[1, 2], "b" : {"c":"d"}} [ ] 123`
+	d := NewDecoder(iotest.OneByteReader(strings.NewReader(in)))
+	if _, err := d.ReadToken(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for d.PeekKind() != '}' {
+		v, err := d.ReadValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(v))
+	}
+	if _, err := d.ReadToken(); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		v, err := d.ReadValue()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(v))
+	}
+	want := []string{`"a"`, `[1, 2]`, `"b"`, `{"c":"d"}`, `[ ]`, `123`}
+	if !slices.Equal(got, want) {
+		t.Errorf("values mismatch:\ngot  %q\nwant %q", got, want)
+	}
+	if off := d.InputOffset(); off != int64(len(in)) {
+		t.Errorf("InputOffset = %d, want %d", off, len(in))
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		in      string
+		opts    []Options
+		wantErr error
+		wantPtr Pointer
+		wantOff int64
+	}{
+		{in: `{"a":1,"a":2}`, wantErr: ErrDuplicateName, wantPtr: "/a", wantOff: 7},
+		{in: `{"a":1,"a":2}`, opts: []Options{AllowDuplicateNames(true)}},
+		{in: `{1:2}`, wantErr: ErrNonStringName, wantOff: 1},
+		{in: `[1,]`, wantErr: errTrailingComma, wantPtr: "/0", wantOff: 3},
+		{in: `{"a" 1}`, wantPtr: "/a", wantOff: 5},
+		{in: `[1 2]`, wantPtr: "/0", wantOff: 3},
+		{in: `[}`, wantErr: errMismatchDelim, wantOff: 1},
+		{in: `{"a":[1,{"b":x}]}`, wantPtr: "/a/1/b", wantOff: 13},
+		{in: `[1,2`, wantErr: io.ErrUnexpectedEOF, wantPtr: "/1", wantOff: 4},
+		{in: "\"\xff\"", wantErr: errInvalidUTF8, wantOff: 1},
+		{in: "\"\xff\"", opts: []Options{AllowInvalidUTF8(true)}},
+		{in: `"\ud800"`, wantErr: errInvalidSurrogate, wantOff: 1},
+		{in: strings.Repeat("[", maxNestingDepth+1), wantErr: errMaxDepth, wantPtr: Pointer(strings.Repeat("/0", maxNestingDepth-1)), wantOff: maxNestingDepth},
+	}
+	for _, tt := range tests {
+		_, err := readTokens(NewDecoder(strings.NewReader(tt.in), tt.opts...))
+		if tt.wantOff == 0 {
+			if err != nil {
+				t.Errorf("%.20q: unexpected error: %v", tt.in, err)
+			}
+			if !Value(tt.in).IsValid(tt.opts...) {
+				t.Errorf("%.20q: IsValid = false, want true", tt.in)
+			}
+			continue
+		}
+		var serr *SyntacticError
+		if !errors.As(err, &serr) {
+			t.Errorf("%.20q: error = %v, want SyntacticError", tt.in, err)
+			continue
+		}
+		if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("%.20q: error = %v, want %v", tt.in, serr.Err, tt.wantErr)
+		}
+		if serr.JSONPointer != tt.wantPtr || serr.ByteOffset != tt.wantOff {
+			t.Errorf("%.20q: error at (%.20q, %d), want (%.20q, %d)", tt.in, serr.JSONPointer, serr.ByteOffset, tt.wantPtr, tt.wantOff)
+		}
+		if Value(tt.in).IsValid(tt.opts...) {
+			t.Errorf("%.20q: IsValid = true, want false", tt.in)
+		}
+	}
+}
+
+func TestDecoderStack(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`{"a":[0,{"b~/c":true}]}`))
+	var ptrs []Pointer
+	for {
+		_, err := d.ReadToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ptrs = append(ptrs, d.StackPointer())
+		if d.StackDepth() == 3 {
+			if k, n := d.StackIndex(2); k != '[' || n != 2 {
+				t.Errorf("StackIndex(2) = (%v, %d), want ([, 2)", k, n)
+			}
+		}
+	}
+	want := []Pointer{"", "/a", "/a", "/a/0", "/a/1", "/a/1/b~0~1c", "/a/1/b~0~1c", "/a/1", "/a", ""}
+	if !slices.Equal(ptrs, want) {
+		t.Errorf("pointers mismatch:\ngot  %q\nwant %q", ptrs, want)
+	}
+}
+
+func TestTokenVoided(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`["a","b"]`))
+	d.ReadToken()
+	tok, _ := d.ReadToken()
+	clone := tok.Clone()
+	d.ReadToken()
+	if got := clone.String(); got != "a" {
+		t.Errorf("Clone().String() = %q, want %q", got, "a")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("String on voided token did not panic")
+		}
+	}()
+	_ = tok.String()
+}
+
+func TestDecoderReadTokenAllocs(t *testing.T) {
+	testenv.SkipIfOptimizationOff(t)
+	const in = `{"name":"gopher","n":[1,-2.5,3e10],"ok":true,"none":null}` + "\n"
+	var r strings.Reader
+	d := NewDecoder(&r)
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Reset(in)
+		d.Reset(&r)
+		for {
+			tok, err := d.ReadToken()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = tok.Kind()
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("ReadToken allocated %v times per document, want 0", allocs)
+	}
+}
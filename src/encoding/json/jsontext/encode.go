@@ -0,0 +1,424 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strconv"
+
+	"encoding/json/internal/jsonopts"
+)
+
+// Encoder is a streaming encoder from raw JSON tokens and values.
+// It is used to write a stream of top-level JSON values,
+// each terminated with a newline character.
+//
+// [Encoder.WriteToken] and [Encoder.WriteValue] calls may be interleaved.
+// For example, the following JSON value:
+//
+//	{"name":"value","array":[null,false,true,3.14159],"object":{"k":"v"}}
+//
+// can be composed with the following calls (ignoring errors for brevity):
+//
+//	e.WriteToken(ObjectStart)        // {
+//	e.WriteToken(String("name"))     // "name"
+//	e.WriteToken(String("value"))    // "value"
+//	e.WriteValue(Value(`"array"`))   // "array"
+//	e.WriteToken(ArrayStart)         // [
+//	e.WriteToken(Null)               // null
+//	e.WriteToken(False)              // false
+//	e.WriteValue(Value("true"))      // true
+//	e.WriteToken(Float(3.14159))     // 3.14159
+//	e.WriteToken(ArrayEnd)           // ]
+//	e.WriteValue(Value(`"object"`))  // "object"
+//	e.WriteValue(Value(`{"k":"v"}`)) // {"k":"v"}
+//	e.WriteToken(ObjectEnd)          // }
+//
+// The above is one of many possible sequence of calls and
+// may not represent the most sensible method to call for any given token/value.
+// For example, it is probably more common to call [Encoder.WriteToken] with a string
+// for object names.
+type Encoder struct {
+	s encoderState
+}
+
+type encoderState struct {
+	state
+
+	// buf holds output that has not yet been written to wr.
+	// baseOffset is the absolute output offset of buf[0].
+	buf        []byte
+	baseOffset int64
+
+	wr io.Writer // nil if the output is only accumulated in buf
+
+	opts            jsonopts.Struct
+	escape          escapeFlags
+	multiline       bool
+	indent          string
+	reformatStrings bool // whether to requote all strings, as for Value.Format
+
+	// tokenizer splits values passed to WriteValue into tokens.
+	tokenizer *decoderState
+	scratch   []byte
+
+	// seenPointers is used by package json to detect cycles.
+	seenPointers map[any]struct{}
+}
+
+// flushThreshold is the buffer size beyond which
+// the Encoder writes to the underlying io.Writer.
+const flushThreshold = 8 << 10
+
+// NewEncoder constructs a new streaming encoder writing to w
+// configured with the provided options.
+// It flushes the internal buffer when the buffer is sufficiently full or
+// when a top-level value has been written.
+func NewEncoder(w io.Writer, opts ...Options) *Encoder {
+	e := new(Encoder)
+	e.Reset(w, opts...)
+	return e
+}
+
+// Reset resets an encoder such that it is writing afresh to w and
+// configured with the provided options. Reset must not be called on
+// a Encoder passed to the [encoding/json/v2.MarshalerTo.MarshalJSONTo] method
+// or the [encoding/json/v2.MarshalToFunc] function.
+func (e *Encoder) Reset(w io.Writer, opts ...Options) {
+	switch {
+	case e == nil:
+		panic("jsontext: invalid nil Encoder")
+	case w == nil:
+		panic("jsontext: invalid nil io.Writer")
+	}
+	e.s.reset(nil, w, opts...)
+}
+
+func (e *encoderState) reset(b []byte, w io.Writer, opts ...Options) {
+	e.state.reset()
+	if b == nil {
+		b = e.buf[:0]
+	}
+	e.buf = b
+	e.baseOffset = 0
+	e.wr = w
+	e.opts = jsonopts.Struct{}
+	e.opts.Join(opts...)
+	e.escape = escapeFlags{
+		allowInvalidUTF8: e.opts.Get(jsonopts.AllowInvalidUTF8),
+		escapeForHTML:    e.opts.Get(jsonopts.EscapeForHTML),
+		escapeForJS:      e.opts.Get(jsonopts.EscapeForJS),
+	}
+	e.multiline = e.opts.Get(jsonopts.Multiline)
+	e.indent = e.opts.Indent
+	if e.indent == "" {
+		e.indent = "\t"
+	}
+	e.reformatStrings = false
+	clear(e.seenPointers)
+}
+
+// Options returns the options used to construct the encoder and
+// may additionally contain semantic options passed to a
+// [encoding/json/v2.MarshalEncode] call.
+func (e *Encoder) Options() Options {
+	opts := e.s.opts
+	return &opts
+}
+
+// flush writes the buffered output to the underlying io.Writer.
+func (e *encoderState) flush() error {
+	if e.wr == nil || len(e.buf) == 0 {
+		return nil
+	}
+	n, err := e.wr.Write(e.buf)
+	e.baseOffset += int64(n)
+	e.buf = e.buf[:copy(e.buf, e.buf[n:])]
+	if err == nil && len(e.buf) > 0 {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// newSyntacticError returns a SyntacticError for an error
+// that occurred at the current output offset plus pos.
+func (e *encoderState) newSyntacticError(pos int, err error) error {
+	return &SyntacticError{
+		ByteOffset:  e.baseOffset + int64(len(e.buf)+pos),
+		JSONPointer: e.stackPointer(),
+		Err:         err,
+	}
+}
+
+// appendDelim appends the delimiter and whitespace that precede the
+// next token of kind next, according to the current state.
+func (e *encoderState) appendDelim(b []byte, next Kind) []byte {
+	if e.tokens.Depth() == 1 {
+		return b // top-level values are separated by a newline
+	}
+	switch e.tokens.needDelim(next) {
+	case ':':
+		b = append(b, ':')
+		if e.multiline || e.opts.Get(jsonopts.SpaceAfterColon) {
+			b = append(b, ' ')
+		}
+		return b
+	case ',':
+		b = append(b, ',')
+		if !e.multiline && e.opts.Get(jsonopts.SpaceAfterComma) {
+			b = append(b, ' ')
+		}
+	}
+	if e.multiline {
+		depth := e.tokens.Depth() - 1
+		if next == '}' || next == ']' {
+			if e.tokens.Last().Length() == 0 {
+				return b // empty object or array
+			}
+			depth--
+		}
+		b = append(b, '\n')
+		b = append(b, e.opts.IndentPrefix...)
+		for range depth {
+			b = append(b, e.indent...)
+		}
+	}
+	return b
+}
+
+var errInvalidToken = errors.New("invalid jsontext.Token")
+
+// WriteToken writes the next token and advances the internal write offset.
+//
+// The provided token kind must be consistent with the JSON grammar.
+// For example, it is an error to provide a number when the encoder
+// is expecting an object name (which is always a string), or
+// to provide an end object delimiter when the encoder is finishing an array.
+// If the provided token is invalid, then it reports a [SyntacticError] and
+// the internal state remains unchanged. The offset reported
+// in [SyntacticError] will be relative to the [Encoder.OutputOffset].
+func (e *Encoder) WriteToken(t Token) error {
+	return e.s.WriteToken(t)
+}
+
+func (e *encoderState) WriteToken(t Token) error {
+	k := t.Kind()
+	b := e.appendDelim(e.buf, k)
+
+	var err error
+	switch k {
+	case 'n', 'f', 't':
+		if e.tokens.Last().needObjectName() {
+			return e.newSyntacticError(0, ErrNonStringName)
+		}
+		b = append(b, k.literal()...)
+		e.tokens.increment()
+
+	case '"':
+		n := len(b)
+		if b, err = e.appendStringToken(b, t); err != nil {
+			return e.newSyntacticError(0, err)
+		}
+		if e.tokens.Last().needObjectName() {
+			if err := e.writeName(b[n:]); err != nil {
+				return err
+			}
+		}
+		e.tokens.increment()
+
+	case '0':
+		if e.tokens.Last().needObjectName() {
+			return e.newSyntacticError(0, ErrNonStringName)
+		}
+		if b, err = e.appendNumberToken(b, t); err != nil {
+			return e.newSyntacticError(0, err)
+		}
+		e.tokens.increment()
+
+	case '{', '[':
+		if k == '{' {
+			err = e.tokens.pushObject()
+		} else {
+			err = e.tokens.pushArray()
+		}
+		if err != nil {
+			return e.newSyntacticError(0, err)
+		}
+		e.names.push()
+		if k == '{' && !e.opts.Get(jsonopts.AllowDuplicateNames) {
+			e.namespaces.push()
+		}
+		b = append(b, byte(k))
+
+	case '}', ']':
+		if k == '}' {
+			err = e.tokens.popObject()
+		} else {
+			err = e.tokens.popArray()
+		}
+		if err != nil {
+			return e.newSyntacticError(0, err)
+		}
+		e.names.pop()
+		if k == '}' && !e.opts.Get(jsonopts.AllowDuplicateNames) {
+			e.namespaces.pop()
+		}
+		b = append(b, byte(k))
+
+	default:
+		return e.newSyntacticError(0, errInvalidToken)
+	}
+
+	e.buf = b
+	if e.tokens.Depth() == 1 {
+		// Terminate each top-level value with a newline and flush it.
+		e.buf = append(e.buf, '\n')
+		return e.flush()
+	}
+	if len(e.buf) > flushThreshold {
+		return e.flush()
+	}
+	return nil
+}
+
+// appendStringToken appends the JSON string for t to b.
+func (e *encoderState) appendStringToken(b []byte, t Token) ([]byte, error) {
+	if t.raw == nil {
+		return appendQuote(b, t.str, e.escape)
+	}
+	rb := t.rawBytes()
+	_, verbatim, err := consumeString(rb, e.escape.allowInvalidUTF8)
+	if err != nil {
+		return b, err
+	}
+	if (verbatim || !e.reformatStrings) && !e.escape.escapeForHTML && !e.escape.escapeForJS {
+		return append(b, rb...), nil
+	}
+	e.scratch = appendUnquote(e.scratch[:0], rb)
+	return appendQuote(b, e.scratch, escapeFlags{
+		allowInvalidUTF8: true, // already validated above
+		escapeForHTML:    e.escape.escapeForHTML,
+		escapeForJS:      e.escape.escapeForJS,
+	})
+}
+
+// appendNumberToken appends the JSON number for t to b.
+func (e *encoderState) appendNumberToken(b []byte, t Token) ([]byte, error) {
+	if t.raw != nil {
+		return append(b, t.rawBytes()...), nil
+	}
+	switch t.numFormat {
+	case 'f':
+		return appendFloat(b, math.Float64frombits(t.num), 64), nil
+	case 'i':
+		return strconv.AppendInt(b, int64(t.num), 10), nil
+	case 'u':
+		return strconv.AppendUint(b, t.num, 10), nil
+	}
+	if n, err := consumeNumber([]byte(t.str)); err != nil || n < len(t.str) {
+		return b, errors.New("invalid number " + quoteRune(t.str))
+	}
+	return append(b, t.str...), nil
+}
+
+// writeName records the quoted object member name
+// and checks that it is not a duplicate.
+func (e *encoderState) writeName(quoted []byte) error {
+	buf := e.names.setBuffer()
+	start := len(buf)
+	buf = appendUnquote(buf, quoted)
+	e.names.setEnd(buf)
+	if !e.opts.Get(jsonopts.AllowDuplicateNames) && !e.namespaces.last().insert(buf[start:]) {
+		e.tokens.increment() // so that the error points to the name
+		err := e.newSyntacticError(0, ErrDuplicateName)
+		e.tokens[len(e.tokens)-1]--
+		return err
+	}
+	return nil
+}
+
+// WriteValue writes the next raw value and advances the internal write offset.
+// The Encoder does not simply copy the provided value verbatim, but
+// parses it to ensure that it is syntactically valid and reformats it
+// according to how the Encoder is configured to format whitespace and strings.
+//
+// The provided value kind must be consistent with the JSON grammar
+// (see examples on [Encoder.WriteToken]). If the provided value is invalid,
+// then it reports a [SyntacticError] and the internal state remains unchanged.
+// The offset reported in [SyntacticError] will be relative to the
+// [Encoder.OutputOffset] plus the offset into v of any encountered syntax error.
+func (e *Encoder) WriteValue(v Value) error {
+	return e.s.WriteValue(v)
+}
+
+func (e *encoderState) WriteValue(v Value) error {
+	pos, err := checkValue(v, flagsOf(&e.opts), e.tokens.Depth())
+	if err != nil {
+		return e.newSyntacticError(pos, err)
+	}
+	if k := kindOf(v[pos]); k != '"' && e.tokens.Last().needObjectName() {
+		return e.newSyntacticError(pos, ErrNonStringName)
+	}
+
+	// The value is valid, so only the first token can fail to be
+	// written (as a duplicate name), leaving the state unchanged.
+	if e.tokenizer == nil {
+		e.tokenizer = new(decoderState)
+	}
+	d := e.tokenizer
+	d.reset(v[pos:], nil, AllowDuplicateNames(true), AllowInvalidUTF8(true))
+	for {
+		t, err := d.ReadToken()
+		if err != nil {
+			return err
+		}
+		if err := e.WriteToken(t); err != nil {
+			return err
+		}
+		if d.tokens.Depth() == 1 {
+			return nil
+		}
+	}
+}
+
+// OutputOffset returns the current output byte offset. It gives the location
+// of the next byte immediately after the most recently written token or value.
+// The number of bytes actually written to the underlying [io.Writer] may be less
+// than this offset due to internal buffering effects.
+func (e *Encoder) OutputOffset() int64 {
+	return e.s.baseOffset + int64(len(e.s.buf))
+}
+
+// StackDepth returns the depth of the state machine for written JSON data.
+// Each level on the stack represents a nested JSON object or array.
+// It is incremented whenever an [ObjectStart] or [ArrayStart] token is encountered
+// and decremented whenever an [ObjectEnd] or [ArrayEnd] token is encountered.
+// The depth is zero-indexed, where zero represents the top-level JSON value.
+func (e *Encoder) StackDepth() int {
+	return e.s.tokens.Depth() - 1
+}
+
+// StackIndex returns information about the specified stack level.
+// It must be a number between 0 and [Encoder.StackDepth], inclusive.
+// For each level, it reports the kind:
+//
+//   - 0 for a level of zero,
+//   - '{' for a level representing a JSON object, and
+//   - '[' for a level representing a JSON array.
+//
+// It also reports the length of that JSON object or array.
+// Each name and value in a JSON object is counted separately,
+// so the effective number of members would be half the length.
+// A complete JSON object must have an even length.
+func (e *Encoder) StackIndex(i int) (Kind, int64) {
+	return e.s.stackIndex(i)
+}
+
+// StackPointer returns a JSON Pointer (RFC 6901) to the most recently written value.
+func (e *Encoder) StackPointer() Pointer {
+	return e.s.stackPointer()
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"bytes"
+	"errors"
+	"internal/testenv"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEncoderTokens(t *testing.T) {
+	tokens := []Token{
+		ObjectStart,
+		String("name"), String("<v\u2028>"),
+		String("array"), ArrayStart, Null, False, True, Float(3.5), Int(-1), Uint(math.MaxUint64), Float(math.Inf(1)), ArrayEnd,
+		String("empty"), ObjectStart, ObjectEnd,
+		ObjectEnd,
+		Int(5),
+	}
+	tests := []struct {
+		opts []Options
+		want string
+	}{{
+		want: "{\"name\":\"<v\u2028>\",\"array\":[null,false,true,3.5,-1,18446744073709551615,\"Infinity\"],\"empty\":{}}\n5\n",
+	}, {
+		opts: []Options{EscapeForHTML(true), EscapeForJS(true), SpaceAfterColon(true), SpaceAfterComma(true)},
+		want: `{"name": "\u003cv\u2028\u003e", "array": [null, false, true, 3.5, -1, 18446744073709551615, "Infinity"], "empty": {}}` + "\n5\n",
+	}, {
+		opts: []Options{WithIndent("  "), WithIndentPrefix("\t")},
+		want: "{\n\t  \"name\": \"<v\u2028>\",\n\t  \"array\": [\n\t    null,\n\t    false,\n\t    true,\n\t    3.5,\n\t    -1,\n\t    18446744073709551615,\n\t    \"Infinity\"\n\t  ],\n\t  \"empty\": {}\n\t}\n5\n",
+	}}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf, tt.opts...)
+		for _, tok := range tokens {
+			if err := e.WriteToken(tok); err != nil {
+				t.Fatalf("WriteToken(%v) error: %v", tok, err)
+			}
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("output mismatch:\ngot  %q\nwant %q", got, tt.want)
+		}
+		if off := e.OutputOffset(); off != int64(buf.Len()) {
+			t.Errorf("OutputOffset = %d, want %d", off, buf.Len())
+		}
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	check := func(err, want error) {
+		t.Helper()
+		var serr *SyntacticError
+		if !errors.As(err, &serr) || want != nil && !errors.Is(err, want) {
+			t.Errorf("error = %v, want %v", err, want)
+		}
+	}
+	e.WriteToken(ObjectStart)
+	check(e.WriteToken(Int(1)), ErrNonStringName)
+	check(e.WriteValue(Value("[]")), ErrNonStringName)
+	check(e.WriteToken(ArrayEnd), errMismatchDelim)
+	if err := e.WriteToken(String("a")); err != nil {
+		t.Fatal(err)
+	}
+	check(e.WriteToken(ObjectEnd), errMissingValue)
+	if err := e.WriteValue(Value(` {"x" : [ 1 ] } `)); err != nil {
+		t.Fatal(err)
+	}
+	check(e.WriteValue(Value(`"a"`)), ErrDuplicateName)
+	check(e.WriteToken(String("\xff")), errInvalidUTF8)
+	check(e.WriteValue(Value(`"b" "c"`)), nil)
+	if err := e.WriteToken(ObjectEnd); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), `{"a":{"x":[1]}}`+"\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	const in = `{"a":[1,2.50,"A😀",{}],"b":{"c":null}}`
+	d := NewDecoder(strings.NewReader(in))
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	for {
+		tok, err := d.ReadToken()
+		if err != nil {
+			break
+		}
+		if err := e.WriteToken(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.TrimSuffix(buf.String(), "\n"); got != in {
+		t.Errorf("round trip:\ngot  %s\nwant %s", got, in)
+	}
+}
+
+func TestAppendQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{in: "", want: `""`},
+		{in: "hello", want: `"hello"`},
+		{in: "\"\\/\b\f\n\r\t\x00\x1f", want: `"\"\\/\b\f\n\r\t\u0000\u001f"`},
+		{in: "<&>\u2028", want: "\"<&>\u2028\""},
+		{in: "a\xffb", want: "\"a�b\"", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := AppendQuote(nil, tt.in)
+		if string(got) != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("AppendQuote(%q) = (%s, %v), want (%s, error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		unq, err := AppendUnquote(nil, got)
+		if string(unq) != tt.in || err != nil {
+			t.Errorf("AppendUnquote(%s) = (%q, %v), want %q", got, unq, err, tt.in)
+		}
+	}
+	if _, err := AppendUnquote(nil, `"a" `); err == nil {
+		t.Error("AppendUnquote with trailing data succeeded")
+	}
+}
+
+func TestEncoderWriteTokenAllocs(t *testing.T) {
+	testenv.SkipIfOptimizationOff(t)
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	tokens := []Token{
+		ObjectStart,
+		String("name"), String("gopher"),
+		String("n"), ArrayStart, Int(1), Uint(2), Float(-2.5), ArrayEnd,
+		String("ok"), True,
+		String("none"), Null,
+		ObjectEnd,
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		e.Reset(&buf)
+		for _, tok := range tokens {
+			if err := e.WriteToken(tok); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("WriteToken allocated %v times per document, want 0", allocs)
+	}
+}
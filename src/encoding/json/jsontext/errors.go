@@ -0,0 +1,102 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+const errorPrefix = "jsontext: "
+
+var (
+	// ErrDuplicateName indicates that a JSON token could not be
+	// encoded or decoded because it results in a duplicate JSON object name.
+	// This error is directly wrapped within a [SyntacticError] when produced.
+	//
+	// The name of a duplicate JSON object member can be extracted as:
+	//
+	//	err := ...
+	//	var serr *jsontext.SyntacticError
+	//	if errors.As(err, &serr) && serr.Err == jsontext.ErrDuplicateName {
+	//		ptr := serr.JSONPointer // JSON pointer to duplicate name
+	//		name := ptr.LastToken() // duplicate name itself
+	//		...
+	//	}
+	//
+	// This error is only returned if [AllowDuplicateNames] is false.
+	ErrDuplicateName = errors.New("duplicate object member name")
+
+	// ErrNonStringName indicates that a JSON token could not be
+	// encoded or decoded because it is not a string,
+	// as required for JSON object names according to RFC 8259, section 4.
+	// This error is directly wrapped within a [SyntacticError] when produced.
+	ErrNonStringName = errors.New("object member name must be a string")
+
+	errInvalidUTF8   = errors.New("invalid UTF-8")
+	errMissingValue  = errors.New("missing value after object name")
+	errMismatchDelim = errors.New("mismatching structural token for object or array")
+	errMaxDepth      = errors.New("exceeded max depth")
+	errTrailingComma = errors.New("invalid character after ',' (expecting value)")
+)
+
+// SyntacticError is a description of a syntactic error that occurred when
+// encoding or decoding JSON according to the grammar.
+//
+// The contents of this error as produced by this package may change over time.
+type SyntacticError struct {
+	// ByteOffset indicates that an error occurred after this byte offset.
+	ByteOffset int64
+	// JSONPointer indicates that an error occurred within this JSON value
+	// as indicated using the JSON Pointer notation (see RFC 6901).
+	JSONPointer Pointer
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *SyntacticError) Error() string {
+	s := errorPrefix + e.Err.Error()
+	if e.JSONPointer != "" {
+		s += " within " + strconv.Quote(string(e.JSONPointer))
+	}
+	if e.ByteOffset > 0 {
+		s += " after offset " + strconv.FormatInt(e.ByteOffset, 10)
+	}
+	return s
+}
+
+func (e *SyntacticError) Unwrap() error {
+	return e.Err
+}
+
+// newInvalidCharacterError returns an error for the invalid character
+// at the start of prefix, found in the given context (e.g., "within string").
+func newInvalidCharacterError(prefix []byte, where string) error {
+	if len(prefix) == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	what := quoteRune(prefix)
+	return errors.New("invalid character " + what + " " + where)
+}
+
+// quoteRune quotes the first rune in the input.
+func quoteRune[Bytes ~[]byte | ~string](b Bytes) string {
+	r, n := utf8.DecodeRuneInString(string(truncateMaxUTF8(b)))
+	if r == utf8.RuneError && n == 1 {
+		return `'\x` + strconv.FormatUint(uint64(b[0]), 16) + `'`
+	}
+	return strconv.QuoteRune(r)
+}
+
+// truncateMaxUTF8 truncates b such it contains at least one rune.
+func truncateMaxUTF8[Bytes ~[]byte | ~string](b Bytes) Bytes {
+	if len(b) > utf8.UTFMax {
+		return b[:utf8.UTFMax]
+	}
+	return b
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import "encoding/json/internal/jsonopts"
+
+// Options configures [NewEncoder], [Encoder.Reset], [NewDecoder],
+// and [Decoder.Reset] with specific features.
+// Each function takes in a variadic list of options, where properties
+// set in later options override the value of previously set properties.
+//
+// There is a single Options type, which is also aliased as
+// encoding/json/v2.Options, so that options declared by either package
+// may be passed to functions of the other. Options that do not apply
+// to a particular function are ignored.
+//
+// Options cannot be implemented outside the standard library.
+type Options = jsonopts.Options
+
+func init() {
+	jsonopts.EncoderOptions = func(e any) *jsonopts.Struct { return &e.(*Encoder).s.opts }
+	jsonopts.DecoderOptions = func(d any) *jsonopts.Struct { return &d.(*Decoder).s.opts }
+	jsonopts.EncoderSeenPointers = func(e any) *map[any]struct{} { return &e.(*Encoder).s.seenPointers }
+}
+
+// AllowDuplicateNames specifies that JSON objects may contain
+// duplicate member names. Disabling the duplicate name check may provide
+// performance benefits, but breaks compliance with RFC 7493, section 2.3.
+// The input or output will still be compliant with RFC 8259,
+// which leaves the handling of duplicate names as unspecified behavior.
+//
+// This affects either encoding or decoding.
+func AllowDuplicateNames(v bool) Options {
+	return jsonopts.Set(jsonopts.AllowDuplicateNames, v)
+}
+
+// AllowInvalidUTF8 specifies that JSON strings may contain invalid UTF-8,
+// which will be mangled as the Unicode replacement character, U+FFFD.
+// This causes the encoder or decoder to break compliance with
+// RFC 7493, section 2.1, and RFC 8259, section 8.1.
+//
+// This affects either encoding or decoding.
+func AllowInvalidUTF8(v bool) Options {
+	return jsonopts.Set(jsonopts.AllowInvalidUTF8, v)
+}
+
+// EscapeForHTML specifies that '<', '>', and '&' characters within JSON
+// strings should be escaped as a hexadecimal Unicode codepoint
+// (e.g., \u003c) so that the output is safe to embed within HTML.
+//
+// This only affects encoding and is ignored when decoding.
+func EscapeForHTML(v bool) Options {
+	return jsonopts.Set(jsonopts.EscapeForHTML, v)
+}
+
+// EscapeForJS specifies that U+2028 and U+2029 characters within JSON
+// strings should be escaped as a hexadecimal Unicode codepoint
+// (e.g., \u2028) so that the output is valid to embed within JavaScript.
+// See RFC 8259, section 12.
+//
+// This only affects encoding and is ignored when decoding.
+func EscapeForJS(v bool) Options {
+	return jsonopts.Set(jsonopts.EscapeForJS, v)
+}
+
+// Multiline specifies that the JSON output should expand to multiple lines,
+// where every JSON object member or JSON array element appears on
+// a new, indented line according to the nesting depth.
+// If an indent is not otherwise specified, then a tab is used.
+//
+// This only affects encoding and is ignored when decoding.
+func Multiline(v bool) Options {
+	return jsonopts.Set(jsonopts.Multiline, v)
+}
+
+// SpaceAfterColon specifies that the JSON output should emit a space
+// character after each colon separator following a JSON object name.
+// It is implied by Multiline.
+//
+// This only affects encoding and is ignored when decoding.
+func SpaceAfterColon(v bool) Options {
+	return jsonopts.Set(jsonopts.SpaceAfterColon, v)
+}
+
+// SpaceAfterComma specifies that the JSON output should emit a space
+// character after each comma separator following a JSON object value
+// or array element. It has no effect if Multiline is set.
+//
+// This only affects encoding and is ignored when decoding.
+func SpaceAfterComma(v bool) Options {
+	return jsonopts.Set(jsonopts.SpaceAfterComma, v)
+}
+
+// WithIndent specifies that the encoder should emit multiline output
+// where each element in a JSON object or array begins on a new, indented
+// line beginning with the indent prefix (see [WithIndentPrefix])
+// followed by one or more copies of indent according to the nesting depth.
+// The indent must only be composed of space or tab characters.
+//
+// This only affects encoding and is ignored when decoding.
+func WithIndent(indent string) Options {
+	if s := trimLeftSpaceTab(indent); len(s) > 0 {
+		panic("jsontext: invalid character " + quoteRune(s) + " in indent")
+	}
+	return jsonopts.Indent(indent)
+}
+
+// WithIndentPrefix specifies that the encoder should emit multiline output
+// where each element in a JSON object or array begins on a new, indented
+// line beginning with the indent prefix followed by one or more
+// copies of indent (see [WithIndent]) according to the nesting depth.
+// The prefix must only be composed of space or tab characters.
+//
+// This only affects encoding and is ignored when decoding.
+func WithIndentPrefix(prefix string) Options {
+	if s := trimLeftSpaceTab(prefix); len(s) > 0 {
+		panic("jsontext: invalid character " + quoteRune(s) + " in indent prefix")
+	}
+	return jsonopts.IndentPrefix(prefix)
+}
+
+func trimLeftSpaceTab(s string) string {
+	for i, r := range s {
+		switch r {
+		case ' ', '\t':
+		default:
+			return s[i:]
+		}
+	}
+	return ""
+}
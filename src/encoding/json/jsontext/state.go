@@ -0,0 +1,393 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"iter"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxNestingDepth is the maximum number of nested JSON objects and arrays.
+const maxNestingDepth = 10000
+
+// state tracks the position within a stream of JSON tokens.
+// It is shared by the Encoder and Decoder.
+type state struct {
+	// tokens validates the grammar of the token stream.
+	tokens stateMachine
+
+	// names records the most recent object member name at each depth,
+	// for use in JSON pointers.
+	names nameStack
+
+	// namespaces records the member names of each open object,
+	// to detect duplicates. It is only used if duplicate names
+	// are not allowed.
+	namespaces namespaceStack
+}
+
+func (s *state) reset() {
+	s.tokens.reset()
+	s.names.reset()
+	s.namespaces.reset()
+}
+
+// appendStackPointer appends a JSON pointer (RFC 6901) to the most
+// recently processed token to b.
+func (s *state) appendStackPointer(b []byte) []byte {
+	for i := 1; i < s.tokens.Depth(); i++ {
+		e := s.tokens[i]
+		if e.Length() == 0 {
+			break
+		}
+		b = append(b, '/')
+		if e.isObject() {
+			b = appendEscapePointerName(b, s.names.name(i))
+		} else {
+			b = strconv.AppendInt(b, e.Length()-1, 10)
+		}
+	}
+	return b
+}
+
+// stackPointer returns a JSON pointer to the most recently processed token.
+func (s *state) stackPointer() Pointer {
+	return Pointer(s.appendStackPointer(nil))
+}
+
+// stackIndex returns the kind and length of the value at depth i.
+func (s *state) stackIndex(i int) (Kind, int64) {
+	switch e := s.tokens[i]; {
+	case i == 0:
+		return 0, e.Length()
+	case e.isObject():
+		return '{', e.Length()
+	default:
+		return '[', e.Length()
+	}
+}
+
+// stateEntry encodes the kind and number of tokens
+// in a JSON object, a JSON array, or the top level.
+type stateEntry uint64
+
+const (
+	stateTypeMask   stateEntry = 1 << 63
+	stateTypeObject stateEntry = 1 << 63
+	stateCountMask  stateEntry = 1<<63 - 1
+)
+
+func (e stateEntry) isObject() bool { return e&stateTypeMask == stateTypeObject }
+func (e stateEntry) isArray() bool  { return e&stateTypeMask != stateTypeObject }
+
+// Length returns the number of tokens at this level.
+// For objects, each name and each value counts as one token.
+func (e stateEntry) Length() int64 { return int64(e & stateCountMask) }
+
+// needObjectName reports whether the next token must be an object name.
+func (e stateEntry) needObjectName() bool { return e.isObject() && e.Length()%2 == 0 }
+
+// needObjectValue reports whether the next token must be an object value.
+func (e stateEntry) needObjectValue() bool { return e.isObject() && e.Length()%2 == 1 }
+
+// stateMachine is a stack of JSON objects and arrays.
+// The first entry represents the top level, which is treated
+// as an array of whitespace-separated values.
+type stateMachine []stateEntry
+
+func (m *stateMachine) reset() {
+	if *m == nil {
+		*m = make(stateMachine, 0, 8)
+	}
+	*m = append((*m)[:0], 0)
+}
+
+// Depth returns the current nesting depth, where the top level is 1.
+func (m stateMachine) Depth() int { return len(m) }
+
+// Last returns the entry for the current level.
+func (m stateMachine) Last() stateEntry { return m[len(m)-1] }
+
+func (m stateMachine) increment() { m[len(m)-1]++ }
+
+// appendValue records a value, which is a name if the current
+// level is an object that expects one.
+func (m stateMachine) appendValue(isString bool) error {
+	if m.Last().needObjectName() && !isString {
+		return ErrNonStringName
+	}
+	m.increment()
+	return nil
+}
+
+func (m *stateMachine) push(t stateEntry) error {
+	if m.Last().needObjectName() {
+		return ErrNonStringName
+	}
+	if len(*m) > maxNestingDepth {
+		return errMaxDepth
+	}
+	m.increment()
+	*m = append(*m, t)
+	return nil
+}
+
+func (m *stateMachine) pushObject() error { return m.push(stateTypeObject) }
+func (m *stateMachine) pushArray() error  { return m.push(0) }
+
+func (m *stateMachine) pop(wantObject bool) error {
+	e := m.Last()
+	switch {
+	case len(*m) == 1 || e.isObject() != wantObject:
+		return errMismatchDelim
+	case e.needObjectValue():
+		return errMissingValue
+	}
+	*m = (*m)[:len(*m)-1]
+	return nil
+}
+
+func (m *stateMachine) popObject() error { return m.pop(true) }
+func (m *stateMachine) popArray() error  { return m.pop(false) }
+
+// needDelim reports the delimiter that must precede the next token of
+// the given kind, or 0 if none. It does not report the whitespace that
+// separates top-level values.
+func (m stateMachine) needDelim(next Kind) byte {
+	e := m.Last()
+	switch {
+	case len(m) == 1 || e.Length() == 0:
+		return 0
+	case e.needObjectValue():
+		return ':'
+	case next == '}' || next == ']':
+		return 0
+	default:
+		return ','
+	}
+}
+
+// nameStack records the unquoted name of the current object member
+// at each depth. Array levels have an empty name.
+type nameStack struct {
+	ends []int // ends[i-1] is the end offset in buf of the name at depth i
+	buf  []byte
+}
+
+func (ns *nameStack) reset() {
+	ns.ends = ns.ends[:0]
+	ns.buf = ns.buf[:0]
+}
+
+func (ns *nameStack) push() {
+	ns.ends = append(ns.ends, len(ns.buf))
+}
+
+func (ns *nameStack) pop() {
+	ns.ends = ns.ends[:len(ns.ends)-1]
+	ns.buf = ns.buf[:ns.start(len(ns.ends)+1)]
+}
+
+// start returns the start offset in buf of the name at depth i.
+func (ns *nameStack) start(i int) int {
+	if i <= 1 {
+		return 0
+	}
+	return ns.ends[i-2]
+}
+
+// name returns the name at depth i.
+func (ns *nameStack) name(i int) []byte {
+	return ns.buf[ns.start(i):ns.ends[i-1]]
+}
+
+// setBuffer truncates buf to the start of the current name and returns it,
+// so that the caller can append the new name. The caller must call setEnd.
+func (ns *nameStack) setBuffer() []byte {
+	return ns.buf[:ns.start(len(ns.ends))]
+}
+
+func (ns *nameStack) setEnd(buf []byte) {
+	ns.buf = buf
+	ns.ends[len(ns.ends)-1] = len(buf)
+}
+
+// namespaceStack is a stack of object namespaces.
+// Namespaces are reused to avoid allocations.
+type namespaceStack struct {
+	stack []namespace
+	depth int
+}
+
+func (nss *namespaceStack) reset() {
+	nss.depth = 0
+}
+
+func (nss *namespaceStack) push() {
+	if nss.depth == len(nss.stack) {
+		nss.stack = append(nss.stack, namespace{})
+	}
+	nss.stack[nss.depth].reset()
+	nss.depth++
+}
+
+func (nss *namespaceStack) pop() {
+	nss.depth--
+}
+
+func (nss *namespaceStack) last() *namespace {
+	return &nss.stack[nss.depth-1]
+}
+
+// namespace is the set of unquoted member names of a JSON object.
+type namespace struct {
+	ends  []int
+	names []byte
+	set   map[string]struct{} // built once there are many names
+}
+
+// namespaceMapThreshold is the number of names beyond which a namespace
+// uses a map rather than a linear search.
+const namespaceMapThreshold = 16
+
+func (ns *namespace) reset() {
+	ns.ends = ns.ends[:0]
+	ns.names = ns.names[:0]
+	if len(ns.set) > 1<<10 {
+		ns.set = nil // avoid retaining very large maps
+	} else {
+		clear(ns.set)
+	}
+}
+
+// insert inserts an unquoted name and reports whether it was not
+// already present.
+func (ns *namespace) insert(name []byte) bool {
+	if len(ns.ends) < namespaceMapThreshold {
+		start := 0
+		for _, end := range ns.ends {
+			if string(ns.names[start:end]) == string(name) {
+				return false
+			}
+			start = end
+		}
+	} else {
+		if len(ns.set) == 0 {
+			if ns.set == nil {
+				ns.set = make(map[string]struct{})
+			}
+			start := 0
+			for _, end := range ns.ends {
+				ns.set[string(ns.names[start:end])] = struct{}{}
+				start = end
+			}
+		}
+		if _, ok := ns.set[string(name)]; ok {
+			return false
+		}
+		ns.set[string(name)] = struct{}{}
+	}
+	ns.names = append(ns.names, name...)
+	ns.ends = append(ns.ends, len(ns.names))
+	return true
+}
+
+// Pointer is a JSON Pointer (RFC 6901) that references a particular JSON value
+// relative to the root of the top-level JSON value.
+//
+// A Pointer is a slash-separated list of tokens, where each token is
+// either a JSON object name or an index to a JSON array element
+// encoded as a base-10 integer value.
+// It is impossible to distinguish between an array index and an object name
+// (that happens to be an base-10 encoded integer) without also knowing
+// the structure of the top-level JSON value that the pointer refers to.
+//
+// There is exactly one representation of a pointer to a particular value,
+// so comparability of Pointer values is equivalent to checking whether
+// they both point to the exact same value.
+type Pointer string
+
+// IsValid reports whether p is a valid JSON Pointer according to RFC 6901.
+// Note that the concatenation of two valid pointers produces a valid pointer.
+func (p Pointer) IsValid() bool {
+	for i, r := range p {
+		switch {
+		case r == '~' && (i+1 == len(p) || (p[i+1] != '0' && p[i+1] != '1')):
+			return false // invalid escape
+		case r == utf8.RuneError && !strings.HasPrefix(string(p[i:]), string(utf8.RuneError)):
+			return false // invalid UTF-8
+		}
+	}
+	return len(p) == 0 || p[0] == '/'
+}
+
+// Contains reports whether the JSON value that p points to
+// is equal to or contains the JSON value that pc points to.
+func (p Pointer) Contains(pc Pointer) bool {
+	// Invariant: len(p) <= len(pc) if p.Contains(pc)
+	suffix, ok := strings.CutPrefix(string(pc), string(p))
+	return ok && (suffix == "" || suffix[0] == '/')
+}
+
+// Parent strips off the last token and returns the remaining pointer.
+// The parent of an empty p is an empty string.
+func (p Pointer) Parent() Pointer {
+	return p[:max(strings.LastIndexByte(string(p), '/'), 0)]
+}
+
+// LastToken returns the last token in the pointer.
+// The last token of an empty p is an empty string.
+func (p Pointer) LastToken() string {
+	last := p[max(strings.LastIndexByte(string(p), '/'), 0):]
+	return unescapePointerToken(strings.TrimPrefix(string(last), "/"))
+}
+
+// AppendToken appends a token to the end of p and returns the full pointer.
+func (p Pointer) AppendToken(tok string) Pointer {
+	return Pointer(appendEscapePointerName([]byte(p+"/"), tok))
+}
+
+// Tokens returns an iterator over the reference tokens in the JSON pointer,
+// starting from the first token until the last token (unless stopped early).
+func (p Pointer) Tokens() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for len(p) > 0 {
+			p = Pointer(strings.TrimPrefix(string(p), "/"))
+			i := min(uint(strings.IndexByte(string(p), '/')), uint(len(p)))
+			if !yield(unescapePointerToken(string(p)[:i])) {
+				return
+			}
+			p = p[i:]
+		}
+	}
+}
+
+func unescapePointerToken(token string) string {
+	if strings.Contains(token, "~") {
+		// Per RFC 6901, section 4, unescape "~1" as "/" before "~0" as "~".
+		token = strings.ReplaceAll(token, "~1", "/")
+		token = strings.ReplaceAll(token, "~0", "~")
+	}
+	return token
+}
+
+// appendEscapePointerName appends the escaped name to b,
+// replacing invalid UTF-8 with the Unicode replacement character.
+func appendEscapePointerName[Bytes ~[]byte | ~string](b []byte, name Bytes) []byte {
+	for _, r := range string(name) {
+		// Per RFC 6901, section 3, escape '~' and '/' characters.
+		switch r {
+		case '~':
+			b = append(b, "~0"...)
+		case '/':
+			b = append(b, "~1"...)
+		default:
+			b = utf8.AppendRune(b, r)
+		}
+	}
+	return b
+}
@@ -0,0 +1,389 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// nonComparable prevents a struct from being compared with ==.
+type nonComparable [0]func()
+
+// Token represents a lexical JSON token, which may be one of the following:
+//   - a JSON literal (i.e., null, true, or false)
+//   - a JSON string (e.g., "hello, world!")
+//   - a JSON number (e.g., 123.456)
+//   - a start or end delimiter for a JSON object (i.e., { or } )
+//   - a start or end delimiter for a JSON array (i.e., [ or ] )
+//
+// A Token cannot represent entire array or object values, while a [Value] can.
+// There is no Token to represent commas and colons since
+// these structural tokens can be inferred from the surrounding context.
+//
+// A Token returned by [Decoder.ReadToken] refers to the Decoder's
+// internal buffer and is only valid until the next call to a method
+// of the Decoder. Using it afterwards panics. Call [Token.Clone]
+// to obtain a Token that remains valid.
+type Token struct {
+	_ nonComparable
+
+	// If raw is non-nil, the token is the most recent token read
+	// by a Decoder, and num holds its absolute input offset,
+	// which is used to detect whether the token is still valid.
+	raw *decodeBuffer
+
+	// Otherwise, kind is the kind of the token and
+	// str and num hold its value as follows:
+	//
+	//	kind '"':                   str is the unquoted string
+	//	kind '0', numFormat 0:      str is the JSON number text
+	//	kind '0', numFormat 'f':    num is a float64 in IEEE 754 format
+	//	kind '0', numFormat 'i':    num is an int64
+	//	kind '0', numFormat 'u':    num is a uint64
+	str       string
+	num       uint64
+	kind      Kind
+	numFormat byte
+}
+
+var (
+	Null  Token = Token{kind: 'n'}
+	False Token = Token{kind: 'f'}
+	True  Token = Token{kind: 't'}
+
+	ObjectStart Token = Token{kind: '{'}
+	ObjectEnd   Token = Token{kind: '}'}
+	ArrayStart  Token = Token{kind: '['}
+	ArrayEnd    Token = Token{kind: ']'}
+)
+
+// Bool constructs a Token representing a JSON boolean.
+func Bool(b bool) Token {
+	if b {
+		return True
+	}
+	return False
+}
+
+// String constructs a Token representing a JSON string.
+// The provided string should contain valid UTF-8, otherwise invalid characters
+// may be mangled as the Unicode replacement character.
+func String(s string) Token {
+	return Token{kind: '"', str: s}
+}
+
+// Float constructs a Token representing a JSON number.
+// The values NaN, +Inf, and -Inf will be represented
+// as a JSON string with the values "NaN", "Infinity", and "-Infinity".
+func Float(n float64) Token {
+	switch {
+	case math.IsNaN(n):
+		return String("NaN")
+	case math.IsInf(n, +1):
+		return String("Infinity")
+	case math.IsInf(n, -1):
+		return String("-Infinity")
+	}
+	return Token{kind: '0', numFormat: 'f', num: math.Float64bits(n)}
+}
+
+// Int constructs a Token representing a JSON number from an int64.
+func Int(n int64) Token {
+	return Token{kind: '0', numFormat: 'i', num: uint64(n)}
+}
+
+// Uint constructs a Token representing a JSON number from a uint64.
+func Uint(n uint64) Token {
+	return Token{kind: '0', numFormat: 'u', num: n}
+}
+
+// rawBytes returns the raw JSON text of a Decoder token,
+// panicking if the token is no longer valid.
+func (t Token) rawBytes() []byte {
+	if uint64(t.raw.prevStart)+uint64(t.raw.baseOffset) != t.num {
+		panic("invalid jsontext.Token; it has been voided by a subsequent jsontext.Decoder call")
+	}
+	return t.raw.buf[t.raw.prevStart:t.raw.prevEnd]
+}
+
+// Clone makes a copy of the Token such that its value remains valid
+// even after a subsequent [Decoder.ReadToken] call.
+func (t Token) Clone() Token {
+	if t.raw == nil {
+		return t
+	}
+	switch b := t.rawBytes(); kindOf(b[0]) {
+	case '"':
+		return String(string(appendUnquote(nil, b)))
+	case '0':
+		return Token{kind: '0', str: string(b)}
+	default:
+		return Token{kind: kindOf(b[0])}
+	}
+}
+
+// Bool returns the value for a JSON boolean.
+// It panics if the token kind is not a JSON boolean.
+func (t Token) Bool() bool {
+	switch t.Kind() {
+	case 't':
+		return true
+	case 'f':
+		return false
+	}
+	panic("invalid JSON token kind: " + t.Kind().String())
+}
+
+// String returns the unescaped string value for a JSON string.
+// For other JSON kinds, this returns the raw JSON representation.
+func (t Token) String() string {
+	if t.raw != nil {
+		b := t.rawBytes()
+		if b[0] == '"' {
+			s := b[1 : len(b)-1]
+			if !containsEscapeOrInvalid(s) {
+				return string(s)
+			}
+			return string(appendUnquote(nil, b))
+		}
+		return string(b)
+	}
+	switch t.kind {
+	case 0:
+		return "<invalid jsontext.Token>"
+	case '"':
+		return t.str
+	case '0':
+		return string(t.appendNumber(nil))
+	default:
+		return t.kind.literal()
+	}
+}
+
+func containsEscapeOrInvalid(s []byte) bool {
+	for _, c := range s {
+		if c == '\\' || c >= utf8.RuneSelf {
+			return c == '\\' || !utf8.Valid(s)
+		}
+	}
+	return false
+}
+
+// appendNumber appends the JSON text of a number token.
+func (t Token) appendNumber(b []byte) []byte {
+	if t.raw != nil {
+		return append(b, t.rawBytes()...)
+	}
+	switch t.numFormat {
+	case 'f':
+		return appendFloat(b, math.Float64frombits(t.num), 64)
+	case 'i':
+		return strconv.AppendInt(b, int64(t.num), 10)
+	case 'u':
+		return strconv.AppendUint(b, t.num, 10)
+	default:
+		return append(b, t.str...)
+	}
+}
+
+// Float returns the floating-point value for a JSON number.
+// It returns a NaN, +Inf, or -Inf value for any JSON string
+// with the values "NaN", "Infinity", or "-Infinity".
+// It panics for all other cases.
+func (t Token) Float() float64 {
+	switch t.Kind() {
+	case '0':
+	case '"':
+		switch t.String() {
+		case "NaN":
+			return math.NaN()
+		case "Infinity":
+			return math.Inf(+1)
+		case "-Infinity":
+			return math.Inf(-1)
+		}
+		fallthrough
+	default:
+		panic("invalid JSON token kind: " + t.Kind().String())
+	}
+	if t.raw == nil {
+		switch t.numFormat {
+		case 'f':
+			return math.Float64frombits(t.num)
+		case 'i':
+			return float64(int64(t.num))
+		case 'u':
+			return float64(t.num)
+		}
+	}
+	return parseFloat(t.appendNumber(nil))
+}
+
+// Int returns the signed integer value for a JSON number.
+// The fractional component of any number is ignored (truncation toward zero).
+// Any number beyond the representation of an int64 will be saturated
+// to the closest representable value.
+// It panics if the token kind is not a JSON number.
+func (t Token) Int() int64 {
+	if t.Kind() != '0' {
+		panic("invalid JSON token kind: " + t.Kind().String())
+	}
+	if t.raw == nil {
+		switch t.numFormat {
+		case 'i':
+			return int64(t.num)
+		case 'u':
+			return int64(min(t.num, math.MaxInt64))
+		}
+	}
+	var f float64
+	if t.raw == nil && t.numFormat == 'f' {
+		f = math.Float64frombits(t.num)
+	} else {
+		b := t.appendNumber(nil)
+		if n, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+			return n
+		}
+		f = parseFloat(b)
+	}
+	switch {
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(f)
+}
+
+// Uint returns the unsigned integer value for a JSON number.
+// The fractional component of any number is ignored (truncation toward zero).
+// Any number beyond the representation of an uint64 will be saturated
+// to the closest representable value.
+// It panics if the token kind is not a JSON number.
+func (t Token) Uint() uint64 {
+	if t.Kind() != '0' {
+		panic("invalid JSON token kind: " + t.Kind().String())
+	}
+	if t.raw == nil {
+		switch t.numFormat {
+		case 'i':
+			return uint64(max(int64(t.num), 0))
+		case 'u':
+			return t.num
+		}
+	}
+	var f float64
+	if t.raw == nil && t.numFormat == 'f' {
+		f = math.Float64frombits(t.num)
+	} else {
+		b := t.appendNumber(nil)
+		if n, err := strconv.ParseUint(string(b), 10, 64); err == nil {
+			return n
+		}
+		f = parseFloat(b)
+	}
+	switch {
+	case f >= math.MaxUint64:
+		return math.MaxUint64
+	case f <= 0:
+		return 0
+	}
+	return uint64(f)
+}
+
+// parseFloat parses a valid JSON number, saturating values
+// beyond the range of a float64.
+func parseFloat(b []byte) float64 {
+	f, _ := strconv.ParseFloat(string(b), 64)
+	switch {
+	case math.IsInf(f, +1):
+		return math.MaxFloat64
+	case math.IsInf(f, -1):
+		return -math.MaxFloat64
+	}
+	return f
+}
+
+// Kind returns the token kind.
+func (t Token) Kind() Kind {
+	if t.raw != nil {
+		return kindOf(t.rawBytes()[0])
+	}
+	return t.kind
+}
+
+// Kind represents each possible JSON token kind with a single byte,
+// which is conveniently the first byte of that kind's grammar
+// with the restriction that numbers always be represented with '0':
+//
+//   - 'n': null
+//   - 'f': false
+//   - 't': true
+//   - '"': string
+//   - '0': number
+//   - '{': object start
+//   - '}': object end
+//   - '[': array start
+//   - ']': array end
+//
+// An invalid kind is usually represented using 0,
+// but may be non-zero due to invalid JSON data.
+type Kind byte
+
+const invalidKind Kind = 0
+
+// String prints the kind in a humanly readable fashion.
+func (k Kind) String() string {
+	switch k {
+	case 'n':
+		return "null"
+	case 'f':
+		return "false"
+	case 't':
+		return "true"
+	case '"':
+		return "string"
+	case '0':
+		return "number"
+	case '{':
+		return "{"
+	case '}':
+		return "}"
+	case '[':
+		return "["
+	case ']':
+		return "]"
+	default:
+		return "<invalid jsontext.Kind: " + quoteRune(string(k)) + ">"
+	}
+}
+
+// literal returns the JSON text of a literal or delimiter kind.
+func (k Kind) literal() string {
+	switch k {
+	case 'n':
+		return "null"
+	case 'f':
+		return "false"
+	case 't':
+		return "true"
+	default:
+		return string(k)
+	}
+}
+
+// kindOf returns the kind of the JSON value that starts with c.
+func kindOf(c byte) Kind {
+	switch c {
+	case 'n', 'f', 't', '"', '{', '}', '[', ']':
+		return Kind(c)
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return '0'
+	default:
+		return invalidKind
+	}
+}
@@ -0,0 +1,470 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"unicode/utf16"
+
+	"encoding/json/internal/jsonopts"
+)
+
+// Value represents a single raw JSON value, which may be one of the following:
+//   - a JSON literal (i.e., null, true, or false)
+//   - a JSON string (e.g., "hello, world!")
+//   - a JSON number (e.g., 123.456)
+//   - an entire JSON object (e.g., {"fizz":"buzz"} )
+//   - an entire JSON array (e.g., [1,2,3] )
+//
+// Value can represent entire array or object values, while [Token] cannot.
+// Value may contain leading and/or trailing whitespace.
+type Value []byte
+
+// Clone returns a copy of v.
+func (v Value) Clone() Value {
+	return bytes.Clone(v)
+}
+
+// String returns the string formatting of v.
+func (v Value) String() string {
+	if v == nil {
+		return "null"
+	}
+	return string(v)
+}
+
+// IsValid reports whether the raw JSON value is syntactically valid
+// according to the specified options.
+//
+// By default (if no options are specified), it validates according to RFC 7493.
+// It verifies whether the input is properly encoded as UTF-8,
+// that escape sequences within strings decode to valid Unicode codepoints, and
+// that all names in each object are unique.
+// It does not verify whether numbers are representable within the limits
+// of any common numeric type (e.g., float64, int64, or uint64).
+//
+// Relevant options include:
+//   - [AllowDuplicateNames]
+//   - [AllowInvalidUTF8]
+//
+// All other options are ignored.
+func (v Value) IsValid(opts ...Options) bool {
+	var o jsonopts.Struct
+	o.Join(opts...)
+	_, err := checkValue(v, flagsOf(&o), 1)
+	return err == nil
+}
+
+// checkValue checks that v holds exactly one valid JSON value at the
+// given depth with optional surrounding whitespace, and returns the
+// offset of the value. On error, it returns the offset of the error.
+func checkValue(v []byte, flags valueFlags, depth int) (int, error) {
+	pos := consumeWhitespace(v)
+	var nss namespaceStack
+	n, err := consumeValue(v[pos:], flags, &nss, depth)
+	if err != nil {
+		return pos + n, err
+	}
+	end := pos + n
+	end += consumeWhitespace(v[end:])
+	if end < len(v) {
+		return end, newInvalidCharacterError(v[end:], "after top-level value")
+	}
+	return pos, nil
+}
+
+// Format formats the raw JSON value in place.
+//
+// By default (if no options are specified), it validates according to RFC 7493
+// and produces the minimal JSON representation, where
+// all whitespace is elided and JSON strings use the shortest encoding.
+//
+// Relevant options include:
+//   - [AllowDuplicateNames]
+//   - [AllowInvalidUTF8]
+//   - [EscapeForHTML]
+//   - [EscapeForJS]
+//   - [Multiline]
+//   - [SpaceAfterColon]
+//   - [SpaceAfterComma]
+//   - [WithIndent]
+//   - [WithIndentPrefix]
+//
+// All other options are ignored.
+//
+// It is guaranteed to succeed if the value is valid according to the same options.
+// If the value is already formatted, then the buffer is not mutated.
+func (v *Value) Format(opts ...Options) error {
+	return v.format(opts, true)
+}
+
+// format reformats v with an Encoder, optionally requoting all strings.
+func (v *Value) format(opts []Options, reformatStrings bool) error {
+	var e encoderState
+	e.reset(nil, nil, opts...)
+	e.reformatStrings = reformatStrings
+	if err := e.WriteValue(*v); err != nil {
+		return err
+	}
+	out := e.buf[:len(e.buf)-1] // trim the newline after the top-level value
+	if !bytes.Equal(*v, out) {
+		*v = append((*v)[:0], out...)
+	}
+	return nil
+}
+
+// Compact removes all whitespace from the raw JSON value.
+//
+// It does not reformat JSON strings to use any other representation.
+// It is guaranteed to succeed if the value is valid.
+// If the value is already compacted, then the buffer is not mutated.
+func (v *Value) Compact(opts ...Options) error {
+	return v.format(append(opts, Multiline(false), SpaceAfterColon(false), SpaceAfterComma(false)), false)
+}
+
+// Indent reformats the whitespace in the raw JSON value so that each element
+// in a JSON object or array begins on a indented line according to the nesting.
+//
+// It does not reformat JSON strings to use any other representation.
+// It is guaranteed to succeed if the value is valid.
+// If the value is already indented properly, then the buffer is not mutated.
+//
+// The [WithIndent] and [WithIndentPrefix] options may be used
+// to configure the indentation.
+func (v *Value) Indent(opts ...Options) error {
+	return v.format(append([]Options{Multiline(true)}, opts...), false)
+}
+
+// Canonicalize canonicalizes the raw JSON value according to the
+// JSON Canonicalization Scheme (JCS) as defined by RFC 8785
+// where it produces a stable representation of a JSON value.
+//
+// JSON strings are formatted to use their minimal representation,
+// JSON numbers are formatted as double precision numbers according
+// to some stable serialization algorithm.
+// JSON object members are sorted in ascending order by name.
+// All whitespace is removed.
+//
+// The output stability is dependent on the stability of the application data
+// (see RFC 8785, Appendix E). It cannot produce stable output from
+// fundamentally unstable input. For example, if the JSON value
+// contains ephemeral data (e.g., a frequently changing timestamp),
+// then the value is still unstable regardless of whether this is called.
+//
+// Note that JCS treats all JSON numbers as IEEE 754 double precision numbers.
+// Any numbers with precision beyond what is representable by that form
+// will lose their precision when canonicalized. For example, integer values
+// beyond ±2⁵³ will lose their precision.
+//
+// It is guaranteed to succeed if the value is valid.
+// If the value is already canonicalized, then the buffer is not mutated.
+func (v *Value) Canonicalize(opts ...Options) error {
+	var o jsonopts.Struct
+	o.Join(opts...)
+	flags := flagsOf(&o)
+	pos, err := checkValue(*v, flags, 1)
+	if err != nil {
+		return &SyntacticError{ByteOffset: int64(pos), Err: err}
+	}
+	out, _, err := appendCanonical(nil, (*v)[pos:])
+	if err != nil {
+		return &SyntacticError{Err: err}
+	}
+	if !bytes.Equal(*v, out) {
+		*v = append((*v)[:0], out...)
+	}
+	return nil
+}
+
+// appendCanonical appends the canonical form of the valid JSON value at
+// the start of src to dst, and returns the number of bytes consumed.
+func appendCanonical(dst, src []byte) ([]byte, int, error) {
+	var err error
+	switch kindOf(src[0]) {
+	case 'n', 'f', 't':
+		lit := kindOf(src[0]).literal()
+		return append(dst, lit...), len(lit), nil
+	case '"':
+		n, _, _ := consumeString(src, true)
+		dst, _ = appendQuote(dst, appendUnquote(nil, src[:n]), escapeFlags{allowInvalidUTF8: true})
+		return dst, n, nil
+	case '0':
+		n, _ := consumeNumber(src)
+		f, err := strconv.ParseFloat(string(src[:n]), 64)
+		if err != nil {
+			return dst, n, errors.New("number " + string(src[:n]) + " is not representable as a float64")
+		}
+		if f == 0 {
+			f = 0 // normalize -0 to 0
+		}
+		return appendFloat(dst, f, 64), n, nil
+	case '[':
+		dst = append(dst, '[')
+		n := 1
+		for i := 0; ; i++ {
+			n += consumeWhitespace(src[n:])
+			if src[n] == ']' {
+				return append(dst, ']'), n + 1, nil
+			}
+			if i > 0 {
+				n++ // ','
+				n += consumeWhitespace(src[n:])
+				dst = append(dst, ',')
+			}
+			var m int
+			dst, m, err = appendCanonical(dst, src[n:])
+			if err != nil {
+				return dst, n, err
+			}
+			n += m
+		}
+	default: // '{'
+		type member struct {
+			name  []uint16
+			value []byte // canonical name and value
+		}
+		var members []member
+		n := 1
+		for i := 0; ; i++ {
+			n += consumeWhitespace(src[n:])
+			if src[n] == '}' {
+				n++
+				break
+			}
+			if i > 0 {
+				n++ // ','
+				n += consumeWhitespace(src[n:])
+			}
+			var m member
+			var k int
+			m.value, k, _ = appendCanonical(nil, src[n:])
+			m.name = utf16.Encode([]rune(string(appendUnquote(nil, src[n:n+k]))))
+			n += k
+			n += consumeWhitespace(src[n:])
+			n++ // ':'
+			n += consumeWhitespace(src[n:])
+			m.value = append(m.value, ':')
+			m.value, k, err = appendCanonical(m.value, src[n:])
+			if err != nil {
+				return dst, n, err
+			}
+			n += k
+			members = append(members, m)
+		}
+		// RFC 8785, section 3.2.3 sorts names by their UTF-16 code units.
+		slices.SortFunc(members, func(x, y member) int {
+			return slices.Compare(x.name, y.name)
+		})
+		dst = append(dst, '{')
+		for i, m := range members {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, m.value...)
+		}
+		return append(dst, '}'), n, nil
+	}
+}
+
+// MarshalJSON returns v as the JSON encoding of v.
+// It returns the stored value as the raw JSON output without any validation.
+// If v is nil, then this returns a JSON null.
+func (v Value) MarshalJSON() ([]byte, error) {
+	// NOTE: This matches the behavior of v1 json.RawMessage.MarshalJSON.
+	if v == nil {
+		return []byte("null"), nil
+	}
+	return v, nil
+}
+
+// UnmarshalJSON sets v as the JSON encoding of b.
+// It stores a copy of the provided raw JSON input without any validation.
+func (v *Value) UnmarshalJSON(b []byte) error {
+	// NOTE: This matches the behavior of v1 json.RawMessage.UnmarshalJSON.
+	if v == nil {
+		return errors.New("jsontext.Value: UnmarshalJSON on nil pointer")
+	}
+	*v = append((*v)[:0], b...)
+	return nil
+}
+
+// Kind returns the starting token kind.
+// For a valid value, this will never include '}' or ']'.
+func (v Value) Kind() Kind {
+	if v := v[consumeWhitespace(v):]; len(v) > 0 {
+		return kindOf(v[0])
+	}
+	return invalidKind
+}
+
+// valueFlags are the options that affect the validation of values.
+type valueFlags struct {
+	allowInvalidUTF8    bool
+	allowDuplicateNames bool
+}
+
+func flagsOf(o *jsonopts.Struct) valueFlags {
+	return valueFlags{
+		allowInvalidUTF8:    o.Get(jsonopts.AllowInvalidUTF8),
+		allowDuplicateNames: o.Get(jsonopts.AllowDuplicateNames),
+	}
+}
+
+// consumeValue consumes a JSON value at the start of b, which is
+// at the given depth. It uses nss to check for duplicate names.
+// On error, it returns the offset at which the error occurred.
+func consumeValue(b []byte, flags valueFlags, nss *namespaceStack, depth int) (int, error) {
+	if len(b) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	switch kindOf(b[0]) {
+	case 'n':
+		return consumeLiteral(b, "null")
+	case 'f':
+		return consumeLiteral(b, "false")
+	case 't':
+		return consumeLiteral(b, "true")
+	case '"':
+		n, _, err := consumeString(b, flags.allowInvalidUTF8)
+		return n, err
+	case '0':
+		return consumeNumber(b)
+	case '{':
+		return consumeObject(b, flags, nss, depth)
+	case '[':
+		return consumeArray(b, flags, nss, depth)
+	default:
+		return 0, newInvalidCharacterError(b, "at start of value")
+	}
+}
+
+// consumeObject consumes a JSON object at the start of b.
+func consumeObject(b []byte, flags valueFlags, nss *namespaceStack, depth int) (n int, err error) {
+	if depth > maxNestingDepth {
+		return 0, errMaxDepth
+	}
+	if !flags.allowDuplicateNames {
+		nss.push()
+		defer nss.pop()
+	}
+	n = 1
+	n += consumeWhitespace(b[n:])
+	if n == len(b) {
+		return n, io.ErrUnexpectedEOF
+	}
+	if b[n] == '}' {
+		return n + 1, nil
+	}
+	var scratch []byte
+	for {
+		// Parse the name.
+		if b[n] != '"' {
+			if k := kindOf(b[n]); k != invalidKind && k != '}' && k != ']' {
+				return n, ErrNonStringName
+			}
+			return n, newInvalidCharacterError(b[n:], "at start of string (expecting '\"')")
+		}
+		m, verbatim, err := consumeString(b[n:], flags.allowInvalidUTF8)
+		if err != nil {
+			return n + m, err
+		}
+		if !flags.allowDuplicateNames {
+			quoted := b[n : n+m]
+			if !verbatim {
+				scratch = appendUnquote(scratch[:0], quoted)
+				quoted = scratch
+			} else {
+				quoted = quoted[1 : len(quoted)-1]
+			}
+			if !nss.last().insert(quoted) {
+				return n, ErrDuplicateName
+			}
+		}
+		n += m
+
+		// Parse the colon and value.
+		n += consumeWhitespace(b[n:])
+		if n == len(b) {
+			return n, io.ErrUnexpectedEOF
+		}
+		if b[n] != ':' {
+			return n, newInvalidCharacterError(b[n:], "after object name (expecting ':')")
+		}
+		n++
+		n += consumeWhitespace(b[n:])
+		m, err = consumeValue(b[n:], flags, nss, depth+1)
+		if err != nil {
+			return n + m, err
+		}
+		n += m
+
+		// Parse the comma or closing brace.
+		n += consumeWhitespace(b[n:])
+		if n == len(b) {
+			return n, io.ErrUnexpectedEOF
+		}
+		switch b[n] {
+		case ',':
+			n++
+			n += consumeWhitespace(b[n:])
+			if n == len(b) {
+				return n, io.ErrUnexpectedEOF
+			}
+			if b[n] == '}' {
+				return n, errTrailingComma
+			}
+		case '}':
+			return n + 1, nil
+		default:
+			return n, newInvalidCharacterError(b[n:], "after object value (expecting ',' or '}')")
+		}
+	}
+}
+
+// consumeArray consumes a JSON array at the start of b.
+func consumeArray(b []byte, flags valueFlags, nss *namespaceStack, depth int) (n int, err error) {
+	if depth > maxNestingDepth {
+		return 0, errMaxDepth
+	}
+	n = 1
+	n += consumeWhitespace(b[n:])
+	if n == len(b) {
+		return n, io.ErrUnexpectedEOF
+	}
+	if b[n] == ']' {
+		return n + 1, nil
+	}
+	for {
+		m, err := consumeValue(b[n:], flags, nss, depth+1)
+		if err != nil {
+			return n + m, err
+		}
+		n += m
+
+		n += consumeWhitespace(b[n:])
+		if n == len(b) {
+			return n, io.ErrUnexpectedEOF
+		}
+		switch b[n] {
+		case ',':
+			n++
+			n += consumeWhitespace(b[n:])
+			if n == len(b) {
+				return n, io.ErrUnexpectedEOF
+			}
+			if b[n] == ']' {
+				return n, errTrailingComma
+			}
+		case ']':
+			return n + 1, nil
+		default:
+			return n, newInvalidCharacterError(b[n:], "after array element (expecting ',' or ']')")
+		}
+	}
+}
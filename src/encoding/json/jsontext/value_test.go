@@ -0,0 +1,103 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValueMethods(t *testing.T) {
+	tests := []struct {
+		in           string
+		wantKind     Kind
+		wantValid    bool
+		wantCompact  string
+		wantIndent   string
+		wantCanon    string
+		wantCanonErr bool
+	}{{
+		in:          " null ",
+		wantKind:    'n',
+		wantValid:   true,
+		wantCompact: "null",
+		wantIndent:  "null",
+		wantCanon:   "null",
+	}, {
+		in:          `"\u0041\/"`,
+		wantKind:    '"',
+		wantValid:   true,
+		wantCompact: `"\u0041\/"`,
+		wantIndent:  `"\u0041\/"`,
+		wantCanon:   `"A/"`,
+	}, {
+		in:          "-0.0e0",
+		wantKind:    '0',
+		wantValid:   true,
+		wantCompact: "-0.0e0",
+		wantIndent:  "-0.0e0",
+		wantCanon:   "0",
+	}, {
+		in:           "1e1000",
+		wantKind:     '0',
+		wantValid:    true,
+		wantCompact:  "1e1000",
+		wantIndent:   "1e1000",
+		wantCanonErr: true,
+	}, {
+		in:          ` { "b" : [ 1 , 1E2 ] , "a" : { } , "\u20ac" : 1, "\ud83d\ude00" : 2 } `,
+		wantKind:    '{',
+		wantValid:   true,
+		wantCompact: `{"b":[1,1E2],"a":{},"\u20ac":1,"\ud83d\ude00":2}`,
+		wantIndent:  "{\n\t\"b\": [\n\t\t1,\n\t\t1E2\n\t],\n\t\"a\": {},\n\t\"\\u20ac\": 1,\n\t\"\\ud83d\\ude00\": 2\n}",
+		wantCanon:   "{\"a\":{},\"b\":[1,100],\"\u20ac\":1,\"\U0001f600\":2}",
+	}, {
+		in:       `{"a":1,"a":2}`,
+		wantKind: '{',
+	}, {
+		in:       `[1] [2]`,
+		wantKind: '[',
+	}, {
+		in: ``,
+	}}
+	for _, tt := range tests {
+		v := Value(tt.in)
+		if got := v.Kind(); got != tt.wantKind {
+			t.Errorf("Value(%q).Kind() = %v, want %v", tt.in, got, tt.wantKind)
+		}
+		if got := v.IsValid(); got != tt.wantValid {
+			t.Errorf("Value(%q).IsValid() = %v, want %v", tt.in, got, tt.wantValid)
+		}
+		for _, m := range []struct {
+			name    string
+			fn      func(*Value, ...Options) error
+			want    string
+			wantErr bool
+		}{
+			{"Compact", (*Value).Compact, tt.wantCompact, !tt.wantValid},
+			{"Indent", (*Value).Indent, tt.wantIndent, !tt.wantValid},
+			{"Canonicalize", (*Value).Canonicalize, tt.wantCanon, !tt.wantValid || tt.wantCanonErr},
+		} {
+			v := slices.Clone(Value(tt.in))
+			err := m.fn(&v)
+			if (err != nil) != m.wantErr {
+				t.Errorf("Value(%q).%s error = %v, want error %v", tt.in, m.name, err, m.wantErr)
+			}
+			if err == nil && string(v) != m.want {
+				t.Errorf("Value(%q).%s:\ngot  %s\nwant %s", tt.in, m.name, v, m.want)
+			}
+		}
+	}
+}
+
+func TestValueFormat(t *testing.T) {
+	v := Value(` { "a\u0041" : "<\u00e9>" } `)
+	if err := v.Format(EscapeForHTML(true), SpaceAfterColon(true)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(v), `{"aA": "\u003cé\u003e"}`; got != want {
+		t.Errorf("Format:\ngot  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// This file implements parsing and formatting of the individual
+// lexical elements of JSON (RFC 8259).
+//
+// The consume functions parse an element at the start of b and return
+// its length. They return io.ErrUnexpectedEOF if b ends before the
+// element is complete. Since a number may always be followed by more
+// digits, callers reading from a stream must also treat a number that
+// extends to the end of b as possibly incomplete.
+
+// consumeWhitespace returns the number of leading JSON whitespace bytes in b.
+func consumeWhitespace(b []byte) (n int) {
+	for n < len(b) && (b[n] == ' ' || b[n] == '\t' || b[n] == '\r' || b[n] == '\n') {
+		n++
+	}
+	return n
+}
+
+// consumeLiteral consumes the JSON literal lit ("null", "false", or "true").
+func consumeLiteral(b []byte, lit string) (int, error) {
+	for i := 0; i < len(lit); i++ {
+		if i >= len(b) {
+			return i, io.ErrUnexpectedEOF
+		}
+		if b[i] != lit[i] {
+			return i, newInvalidCharacterError(b[i:], "within literal "+lit+" (expecting "+strconv.QuoteRune(rune(lit[i]))+")")
+		}
+	}
+	return len(lit), nil
+}
+
+// consumeNumber consumes a JSON number.
+func consumeNumber(b []byte) (n int, err error) {
+	isDigit := func(c byte) bool { return '0' <= c && c <= '9' }
+	digits := func() {
+		for n < len(b) && isDigit(b[n]) {
+			n++
+		}
+	}
+	expectDigit := func() error {
+		if n >= len(b) {
+			return io.ErrUnexpectedEOF
+		}
+		if !isDigit(b[n]) {
+			return newInvalidCharacterError(b[n:], "in number (expecting digit)")
+		}
+		return nil
+	}
+
+	if n < len(b) && b[n] == '-' {
+		n++
+	}
+	if err := expectDigit(); err != nil {
+		return n, err
+	}
+	if b[n] == '0' {
+		n++
+	} else {
+		digits()
+	}
+	if n < len(b) && b[n] == '.' {
+		n++
+		if err := expectDigit(); err != nil {
+			return n, err
+		}
+		digits()
+	}
+	if n < len(b) && (b[n] == 'e' || b[n] == 'E') {
+		n++
+		if n < len(b) && (b[n] == '-' || b[n] == '+') {
+			n++
+		}
+		if err := expectDigit(); err != nil {
+			return n, err
+		}
+		digits()
+	}
+	return n, nil
+}
+
+// consumeString consumes a JSON string. It reports whether the string
+// is verbatim, meaning that it contains no escape sequences or invalid
+// UTF-8, so that its unquoted value is b[1:n-1].
+func consumeString(b []byte, allowInvalidUTF8 bool) (n int, verbatim bool, err error) {
+	if len(b) == 0 {
+		return 0, false, io.ErrUnexpectedEOF
+	}
+	if b[0] != '"' {
+		return 0, false, newInvalidCharacterError(b, "at start of string (expecting '\"')")
+	}
+	n, verbatim = 1, true
+	for n < len(b) {
+		switch c := b[n]; {
+		case c == '"':
+			return n + 1, verbatim, nil
+		case c == '\\':
+			verbatim = false
+			m, err := consumeEscape(b[n:], allowInvalidUTF8)
+			if err != nil {
+				return n + m, false, err
+			}
+			n += m
+		case c < ' ':
+			return n, false, newInvalidCharacterError(b[n:], "within string (expecting non-control character)")
+		case c < utf8.RuneSelf:
+			n++
+		default:
+			r, m := utf8.DecodeRune(b[n:])
+			if r == utf8.RuneError && m == 1 {
+				if !utf8.FullRune(b[n:]) {
+					return n, false, io.ErrUnexpectedEOF
+				}
+				if !allowInvalidUTF8 {
+					return n, false, errInvalidUTF8
+				}
+				verbatim = false
+			}
+			n += m
+		}
+	}
+	return n, false, io.ErrUnexpectedEOF
+}
+
+// consumeEscape consumes an escape sequence within a JSON string,
+// including a following low surrogate if the sequence is a high surrogate.
+func consumeEscape(b []byte, allowInvalidUTF8 bool) (int, error) {
+	if len(b) < 2 {
+		return len(b), io.ErrUnexpectedEOF
+	}
+	switch b[1] {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		return 2, nil
+	case 'u':
+		r, err := parseHex4(b[2:])
+		if err != nil {
+			return 2, err
+		}
+		if !utf16.IsSurrogate(r) {
+			return 6, nil
+		}
+		if r >= 0xdc00 {
+			// Unpaired low surrogate.
+			if !allowInvalidUTF8 {
+				return 0, errInvalidSurrogate
+			}
+			return 6, nil
+		}
+		if len(b) < 7 || b[6] == '\\' && len(b) < 8 {
+			return len(b), io.ErrUnexpectedEOF
+		}
+		if b[6] == '\\' && b[7] == 'u' {
+			r2, err := parseHex4(b[8:])
+			if err != nil {
+				return 8, err
+			}
+			if utf16.DecodeRune(r, r2) != utf8.RuneError {
+				return 12, nil
+			}
+		}
+		if !allowInvalidUTF8 {
+			return 0, errInvalidSurrogate
+		}
+		return 6, nil
+	default:
+		return 1, newInvalidCharacterError(b[1:], "in string escape code")
+	}
+}
+
+var errInvalidSurrogate = errors.New("invalid surrogate pair in string")
+
+// parseHex4 parses four hexadecimal digits.
+func parseHex4(b []byte) (rune, error) {
+	var r rune
+	for i := 0; i < 4; i++ {
+		if i >= len(b) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		c := b[i]
+		switch {
+		case '0' <= c && c <= '9':
+			c = c - '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, newInvalidCharacterError(b[i:], "in hexadecimal character escape code")
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, nil
+}
+
+// appendUnquote appends the unquoted value of the valid JSON string
+// src to dst. Invalid UTF-8 and unpaired surrogates are replaced
+// with the Unicode replacement character.
+func appendUnquote(dst, src []byte) []byte {
+	src = src[1 : len(src)-1]
+	for i := 0; i < len(src); {
+		switch c := src[i]; {
+		case c == '\\':
+			switch src[i+1] {
+			case 'b':
+				dst = append(dst, '\b')
+			case 'f':
+				dst = append(dst, '\f')
+			case 'n':
+				dst = append(dst, '\n')
+			case 'r':
+				dst = append(dst, '\r')
+			case 't':
+				dst = append(dst, '\t')
+			case 'u':
+				r, _ := parseHex4(src[i+2:])
+				i += 6
+				if utf16.IsSurrogate(r) {
+					r2 := rune(utf8.RuneError)
+					if i+6 <= len(src) && src[i] == '\\' && src[i+1] == 'u' {
+						r2, _ = parseHex4(src[i+2:])
+					}
+					if r = utf16.DecodeRune(r, r2); r != utf8.RuneError {
+						i += 6
+					}
+				}
+				dst = utf8.AppendRune(dst, r)
+				continue
+			default:
+				dst = append(dst, src[i+1])
+			}
+			i += 2
+		case c < utf8.RuneSelf:
+			j := i + 1
+			for j < len(src) && src[j] < utf8.RuneSelf && src[j] != '\\' {
+				j++
+			}
+			dst = append(dst, src[i:j]...)
+			i = j
+		default:
+			r, n := utf8.DecodeRune(src[i:])
+			dst = utf8.AppendRune(dst, r)
+			i += n
+		}
+	}
+	return dst
+}
+
+// escapeFlags are the options that affect how strings are quoted.
+type escapeFlags struct {
+	allowInvalidUTF8 bool
+	escapeForHTML    bool
+	escapeForJS      bool
+}
+
+// appendQuote appends src as a quoted JSON string to dst,
+// escaping only the characters that must be escaped.
+// Invalid UTF-8 is replaced with the Unicode replacement character,
+// and reported as an error unless allowed.
+func appendQuote[Bytes ~[]byte | ~string](dst []byte, src Bytes, flags escapeFlags) ([]byte, error) {
+	const hex = "0123456789abcdef"
+	var err error
+	dst = append(dst, '"')
+	for i := 0; i < len(src); {
+		c := src[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c < ' ':
+				switch c {
+				case '\b':
+					dst = append(dst, '\\', 'b')
+				case '\f':
+					dst = append(dst, '\\', 'f')
+				case '\n':
+					dst = append(dst, '\\', 'n')
+				case '\r':
+					dst = append(dst, '\\', 'r')
+				case '\t':
+					dst = append(dst, '\\', 't')
+				default:
+					dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+				}
+			case flags.escapeForHTML && (c == '<' || c == '>' || c == '&'):
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			default:
+				dst = append(dst, c)
+			}
+			i++
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(string(truncateMaxUTF8(src[i:])))
+		switch {
+		case r == utf8.RuneError && n == 1:
+			if !flags.allowInvalidUTF8 && err == nil {
+				err = errInvalidUTF8
+			}
+			dst = utf8.AppendRune(dst, utf8.RuneError)
+		case flags.escapeForJS && (r == 0x2028 || r == 0x2029):
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[r&0xf])
+		default:
+			dst = append(dst, src[i:i+n]...)
+		}
+		i += n
+	}
+	dst = append(dst, '"')
+	return dst, err
+}
+
+// appendFloat appends f formatted as a JSON number, using the same
+// format as ECMAScript's Number.prototype.toString for finite values.
+func appendFloat(dst []byte, f float64, bits int) []byte {
+	if bits == 32 {
+		f = float64(float32(f))
+	}
+	abs := math.Abs(f)
+	fmt := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			fmt = 'e'
+		}
+	}
+	dst = strconv.AppendFloat(dst, f, fmt, -1, bits)
+	if fmt == 'e' {
+		// Clean up e-09 to e-9.
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst
+}
+
+// AppendQuote appends a double-quoted JSON string literal representing src
+// to dst and returns the extended buffer.
+// It uses the minimal string representation per RFC 8785, section 3.2.2.2.
+// Invalid UTF-8 bytes are replaced with the Unicode replacement character
+// and an error is returned at the end indicating the presence of invalid UTF-8.
+func AppendQuote[Bytes ~[]byte | ~string](dst []byte, src Bytes) ([]byte, error) {
+	return appendQuote(dst, src, escapeFlags{})
+}
+
+// AppendUnquote appends the decoded interpretation of src as a
+// double-quoted JSON string literal to dst and returns the extended buffer.
+// The input src must be a JSON string without any surrounding whitespace.
+// Invalid UTF-8 bytes are replaced with the Unicode replacement character
+// and an error is returned at the end indicating the presence of invalid UTF-8.
+// Any trailing bytes after the JSON string literal results in an error.
+func AppendUnquote[Bytes ~[]byte | ~string](dst []byte, src Bytes) ([]byte, error) {
+	b := []byte(src)
+	n, _, err := consumeString(b, true)
+	if err != nil {
+		return dst, err
+	}
+	if n < len(b) {
+		return dst, newInvalidCharacterError(b[n:], "after string value")
+	}
+	dst = appendUnquote(dst, b[:n])
+	if _, _, err := consumeString(b, false); err != nil {
+		return dst, err
+	}
+	return dst, nil
+}
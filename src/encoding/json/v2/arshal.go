@@ -0,0 +1,468 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+
+	"encoding/json/internal/jsonopts"
+	"encoding/json/jsontext"
+)
+
+// Marshal serializes a Go value as a []byte according to the provided
+// marshal and encode options (while ignoring unmarshal or decode options).
+// It does not terminate the output with a newline.
+//
+// Type-specific marshal functions and methods take precedence
+// over the default representation of a value.
+// Functions or methods that operate on *T are only called when encoding
+// a value of type T (by taking its address) or a non-nil value of *T.
+// Marshal ensures that a value is always addressable
+// (by boxing it on the heap if necessary) so that
+// these functions and methods can be consistently called. For performance,
+// it is recommended that Marshal be passed a non-nil pointer to the value.
+//
+// The input value is encoded as JSON according the following rules:
+//
+//   - If any type-specific functions in a [WithMarshalers] option match
+//     the value type, then those functions are called to encode the value.
+//     If all applicable functions return [SkipFunc],
+//     then the value is encoded according to subsequent rules.
+//
+//   - If the value type implements [MarshalerTo],
+//     then the MarshalJSONTo method is called to encode the value.
+//
+//   - If the value type implements [Marshaler],
+//     then the MarshalJSON method is called to encode the value.
+//
+//   - If the value type implements [encoding.TextMarshaler],
+//     then the MarshalText method is called to encode the value and
+//     subsequently encode its result as a JSON string.
+//
+//   - Otherwise, the value is encoded according to the value's type
+//     as described in detail below.
+//
+// Most Go types have a default JSON representation.
+// Certain types support specialized formatting according to
+// a format flag optionally specified in the Go struct tag
+// for the struct field that contains the current value
+// (see the “JSON Representation of Go structs” section for more details).
+//
+// The representation of each type is as follows:
+//
+//   - A Go boolean is encoded as a JSON boolean (e.g., true or false).
+//     It does not support any custom format flags.
+//
+//   - A Go string is encoded as a JSON string.
+//     It does not support any custom format flags.
+//
+//   - A Go []byte or [N]byte is encoded as a JSON string containing
+//     the binary value encoded using RFC 4648.
+//     If the format is "base64" or unspecified, then this uses RFC 4648, section 4.
+//     If the format is "base64url", then this uses RFC 4648, section 5.
+//     If the format is "base32", then this uses RFC 4648, section 6.
+//     If the format is "base32hex", then this uses RFC 4648, section 7.
+//     If the format is "base16" or "hex", then this uses RFC 4648, section 8.
+//     If the format is "array", then the bytes value is encoded as a JSON array
+//     where each byte is recursively JSON-encoded as each JSON array element.
+//
+//   - A Go integer is encoded as a JSON number without fractions or exponents.
+//     If [StringifyNumbers] is specified or encoding a JSON object name,
+//     then the JSON number is encoded within a JSON string.
+//     It does not support any custom format flags.
+//
+//   - A Go float is encoded as a JSON number.
+//     If [StringifyNumbers] is specified or encoding a JSON object name,
+//     then the JSON number is encoded within a JSON string.
+//     If the format is "nonfinite", then NaN, +Inf, and -Inf are encoded as
+//     the JSON strings "NaN", "Infinity", and "-Infinity", respectively.
+//     Otherwise, the presence of non-finite numbers results in a [SemanticError].
+//
+//   - A Go map is encoded as a JSON object, where each Go map key and value
+//     is recursively encoded as a name and value pair in the JSON object.
+//     The Go map key must encode as a JSON string, otherwise this results
+//     in a [SemanticError]. The Go map is traversed in a non-deterministic order.
+//     For deterministic encoding, consider using the [Deterministic] option.
+//     If the format is "emitnull", then a nil map is encoded as a JSON null.
+//     If the format is "emitempty", then a nil map is encoded as an empty JSON object,
+//     regardless of whether [FormatNilMapAsNull] is specified.
+//     Otherwise by default, a nil map is encoded as an empty JSON object.
+//
+//   - A Go struct is encoded as a JSON object.
+//     See the “JSON Representation of Go structs” section
+//     in the package-level documentation for more details.
+//
+//   - A Go slice is encoded as a JSON array, where each Go slice element
+//     is recursively JSON-encoded as the elements of the JSON array.
+//     If the format is "emitnull", then a nil slice is encoded as a JSON null.
+//     If the format is "emitempty", then a nil slice is encoded as an empty JSON array,
+//     regardless of whether [FormatNilSliceAsNull] is specified.
+//     Otherwise by default, a nil slice is encoded as an empty JSON array.
+//
+//   - A Go array is encoded as a JSON array, where each Go array element
+//     is recursively JSON-encoded as the elements of the JSON array.
+//     The JSON array length is always identical to the Go array length.
+//     It does not support any custom format flags.
+//
+//   - A Go pointer is encoded as a JSON null if nil, otherwise it is
+//     the recursively JSON-encoded representation of the underlying value.
+//     Format flags are forwarded to the encoding of the underlying value.
+//
+//   - A Go interface is encoded as a JSON null if nil, otherwise it is
+//     the recursively JSON-encoded representation of the underlying value.
+//     It does not support any custom format flags.
+//
+//   - A Go [time.Time] is encoded as a JSON string containing the timestamp
+//     formatted in RFC 3339 with nanosecond precision.
+//     If the format matches one of the format constants declared
+//     in the time package (e.g., RFC1123), then that format is used.
+//     If the format is "unix", "unixmilli", "unixmicro", or "unixnano",
+//     then the timestamp is encoded as a JSON number of the number of seconds
+//     (or milliseconds, microseconds, or nanoseconds) since the Unix epoch.
+//     Otherwise, the format is used as-is with [time.Time.Format] if non-empty.
+//
+//   - A Go [time.Duration] is encoded as a JSON string containing the duration
+//     formatted according to [time.Duration.String].
+//     If the format is "sec", "milli", "micro", or "nano",
+//     then the duration is encoded as a JSON number of the number of seconds
+//     (or milliseconds, microseconds, or nanoseconds) in the duration.
+//     If [FormatDurationAsNano] is specified and there is no format,
+//     then the duration is encoded as a JSON number of nanoseconds.
+//
+//   - All other Go types (e.g., complex numbers, channels, and functions)
+//     have no default representation and result in a [SemanticError].
+//
+// JSON cannot represent cyclic data structures and Marshal does not handle them.
+// Passing cyclic structures will result in an error.
+func Marshal(in any, opts ...Options) (out []byte, err error) {
+	var buf bytes.Buffer
+	enc := jsontext.NewEncoder(&buf, opts...)
+	if err := marshalEncode(enc, in); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// MarshalWrite serializes a Go value into an [io.Writer] according to the provided
+// marshal and encode options (while ignoring unmarshal or decode options).
+// It does not terminate the output with a newline.
+// See [Marshal] for details about the conversion of a Go value into JSON.
+func MarshalWrite(out io.Writer, in any, opts ...Options) error {
+	b, err := Marshal(in, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(b)
+	return err
+}
+
+// MarshalEncode serializes a Go value into an [jsontext.Encoder] according to
+// the provided marshal options (while ignoring unmarshal, encode, or decode options).
+// Any marshal-relevant options already specified on the [jsontext.Encoder]
+// take lower precedence than the set of options provided by the caller.
+// Unlike [Marshal] and [MarshalWrite], encode options are ignored because
+// they must have already been specified on the provided [jsontext.Encoder].
+//
+// See [Marshal] for details about the conversion of a Go value into JSON.
+func MarshalEncode(out *jsontext.Encoder, in any, opts ...Options) error {
+	if len(opts) == 0 {
+		return marshalEncode(out, in)
+	}
+	o := jsonopts.EncoderOptions(out)
+	prev := *o
+	defer func() { *o = prev }()
+	o.JoinSemantic(opts...)
+	return marshalEncode(out, in)
+}
+
+func marshalEncode(enc *jsontext.Encoder, in any) error {
+	v := reflect.ValueOf(in)
+	if !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return enc.WriteToken(jsontext.Null)
+	}
+	// Shallow copy non-pointer values so that they are addressable,
+	// allowing calls to methods with pointer receivers.
+	if v.Kind() != reflect.Pointer {
+		v2 := reflect.New(v.Type())
+		v2.Elem().Set(v)
+		v = v2.Elem()
+	}
+	return marshalValue(enc, v, fieldOpts{})
+}
+
+// Unmarshal decodes a []byte input into a Go value according to the provided
+// unmarshal and decode options (while ignoring marshal or encode options).
+// The input must be a single JSON value with optional whitespace interspersed.
+// The output must be a non-nil pointer.
+//
+// Type-specific unmarshal functions and methods take precedence
+// over the default representation of a value.
+// Functions or methods that operate on *T are only called when decoding
+// a value of type T (by taking its address) or a non-nil value of *T.
+// Unmarshal ensures that a value is always addressable
+// (by boxing it on the heap if necessary) so that
+// these functions and methods can be consistently called.
+//
+// The input is decoded into the output according the following rules:
+//
+//   - If any type-specific functions in a [WithUnmarshalers] option match
+//     the value type, then those functions are called to decode the JSON
+//     value. If all applicable functions return [SkipFunc],
+//     then the input is decoded according to subsequent rules.
+//
+//   - If the value type implements [UnmarshalerFrom],
+//     then the UnmarshalJSONFrom method is called to decode the JSON value.
+//
+//   - If the value type implements [Unmarshaler],
+//     then the UnmarshalJSON method is called to decode the JSON value.
+//
+//   - If the value type implements [encoding.TextUnmarshaler],
+//     then the input is decoded as a JSON string and
+//     the UnmarshalText method is called with the decoded string value.
+//     This fails with a [SemanticError] if the input is not a JSON string.
+//
+//   - Otherwise, the JSON value is decoded according to the value's type
+//     as described in detail below.
+//
+// Most Go types have a default JSON representation.
+// Certain types support specialized formatting according to
+// a format flag optionally specified in the Go struct tag
+// for the struct field that contains the current value
+// (see the “JSON Representation of Go structs” section for more details).
+// A JSON null may be decoded into every supported Go value where
+// it is equivalent to storing the zero value of the Go value.
+// If the input JSON kind is not handled by the current Go value type,
+// then this fails with a [SemanticError]. Unless otherwise specified,
+// the decoded value replaces any pre-existing value.
+//
+// The representation of each type is as follows:
+//
+//   - A Go boolean is decoded from a JSON boolean (e.g., true or false).
+//     It does not support any custom format flags.
+//
+//   - A Go string is decoded from a JSON string.
+//     It does not support any custom format flags.
+//
+//   - A Go []byte or [N]byte is decoded from a JSON string
+//     containing the binary value encoded using RFC 4648.
+//     See the marshal representation for the supported format flags.
+//     If the format is "array", then the Go slice or array is decoded from a
+//     JSON array where each JSON element is recursively decoded for each byte.
+//     When decoding into a non-nil []byte, the slice length is reset to zero
+//     and the decoded input is appended to it.
+//     When decoding into a [N]byte, the input must decode to exactly N bytes,
+//     otherwise it fails with a [SemanticError].
+//
+//   - A Go integer is decoded from a JSON number.
+//     It must be decoded from a JSON string containing a JSON number
+//     if [StringifyNumbers] is specified or decoding a JSON object name.
+//     It fails with a [SemanticError] if the JSON number
+//     has a fractional or exponent component.
+//     It also fails if it overflows the representation of the Go integer type.
+//     It does not support any custom format flags.
+//
+//   - A Go float is decoded from a JSON number.
+//     It must be decoded from a JSON string containing a JSON number
+//     if [StringifyNumbers] is specified or decoding a JSON object name.
+//     It fails if it overflows the representation of the Go float type.
+//     If the format is "nonfinite", then the JSON strings
+//     "NaN", "Infinity", and "-Infinity" are decoded as NaN, +Inf, and -Inf.
+//     Otherwise, the presence of such strings results in a [SemanticError].
+//
+//   - A Go map is decoded from a JSON object,
+//     where each JSON object name and value pair is recursively decoded
+//     as the Go map key and value. Maps are not cleared.
+//     If the Go map is nil, then a new map is allocated to decode into.
+//     If the decoded key matches an existing Go map entry, the entry value
+//     is replaced with the decoded value.
+//
+//   - A Go struct is decoded from a JSON object.
+//     See the “JSON Representation of Go structs” section
+//     in the package-level documentation for more details.
+//
+//   - A Go slice is decoded from a JSON array, where each JSON element
+//     is recursively decoded and appended to the Go slice.
+//     Before appending into a Go slice, a new slice is allocated if it is nil,
+//     otherwise the slice length is reset to zero.
+//
+//   - A Go array is decoded from a JSON array, where each JSON array element
+//     is recursively decoded as each corresponding Go array element.
+//     It fails with a [SemanticError] if the JSON array does not contain
+//     the exact same number of elements as the Go array.
+//
+//   - A Go pointer is decoded based on the JSON kind and underlying Go type.
+//     If the input is a JSON null, then this stores a nil pointer.
+//     Otherwise, it allocates a new underlying value if the pointer is nil,
+//     and recursively JSON decodes into the underlying value.
+//     Format flags are forwarded to the decoding of the underlying type.
+//
+//   - A Go interface is decoded based on the JSON kind and underlying Go type.
+//     If the input is a JSON null, then this stores a nil interface value.
+//     Otherwise, if the interface holds a non-nil pointer,
+//     the JSON value is recursively decoded into the pointed-at value.
+//     Otherwise, if the interface is empty, then a new value is decoded
+//     as a nil, bool, string, float64, map[string]any, or []any
+//     for a JSON null, boolean, string, number, object, or array, respectively.
+//     Otherwise, this fails with a [SemanticError].
+//
+//   - A Go [time.Time] and [time.Duration] are decoded from the
+//     representation described for [Marshal] according to the format.
+//
+//   - All other Go types (e.g., complex numbers, channels, and functions)
+//     have no default representation and result in a [SemanticError].
+//
+// In general, unmarshaling follows merge semantics (similar to RFC 7396)
+// where the decoded Go value replaces the destination value
+// for any JSON kind other than an object.
+// For JSON objects, the input object is merged into the destination value
+// where matching object members recursively apply merge semantics.
+func Unmarshal(in []byte, out any, opts ...Options) error {
+	dec := jsontext.NewDecoder(bytes.NewBuffer(in), opts...)
+	return unmarshalFull(dec, out)
+}
+
+// UnmarshalRead deserializes a Go value from an [io.Reader] according to the
+// provided unmarshal and decode options (while ignoring marshal or encode options).
+// The input must be a single JSON value with optional whitespace interspersed.
+// It consumes the entirety of [io.Reader] until [io.EOF] is encountered,
+// without reporting an error for EOF. The output must be a non-nil pointer.
+// See [Unmarshal] for details about the conversion of JSON into a Go value.
+func UnmarshalRead(in io.Reader, out any, opts ...Options) error {
+	dec := jsontext.NewDecoder(in, opts...)
+	return unmarshalFull(dec, out)
+}
+
+var errTrailingData = errors.New("unexpected data after top-level value")
+
+func unmarshalFull(dec *jsontext.Decoder, out any) error {
+	switch err := unmarshalDecode(dec, out); err {
+	case nil:
+	case io.EOF:
+		return io.ErrUnexpectedEOF
+	default:
+		return err
+	}
+	off := dec.InputOffset()
+	switch _, err := dec.ReadValue(); err {
+	case io.EOF:
+		return nil
+	case nil:
+		return &jsontext.SyntacticError{ByteOffset: off, Err: errTrailingData}
+	default:
+		return err
+	}
+}
+
+// UnmarshalDecode deserializes a Go value from a [jsontext.Decoder] according to
+// the provided unmarshal options (while ignoring marshal, encode, or decode options).
+// Any unmarshal options already specified on the [jsontext.Decoder]
+// take lower precedence than the set of options provided by the caller.
+// Unlike [Unmarshal] and [UnmarshalRead], decode options are ignored because
+// they must have already been specified on the provided [jsontext.Decoder].
+//
+// The input may be a stream of one or more JSON values,
+// where this only unmarshals the next JSON value in the stream.
+// The output must be a non-nil pointer.
+// See [Unmarshal] for details about the conversion of JSON into a Go value.
+func UnmarshalDecode(in *jsontext.Decoder, out any, opts ...Options) error {
+	if len(opts) == 0 {
+		return unmarshalDecode(in, out)
+	}
+	o := jsonopts.DecoderOptions(in)
+	prev := *o
+	defer func() { *o = prev }()
+	o.JoinSemantic(opts...)
+	return unmarshalDecode(in, out)
+}
+
+var errNonNilReference = errors.New("value must be passed as a non-nil pointer reference")
+
+func unmarshalDecode(dec *jsontext.Decoder, out any) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return &SemanticError{action: "unmarshal", GoType: reflect.TypeOf(out), Err: errNonNilReference}
+	}
+	return unmarshalValue(dec, v.Elem(), fieldOpts{})
+}
+
+// fieldOpts are the options for a value specified by
+// the tag of the struct field that contains it.
+type fieldOpts struct {
+	format    string // the format flag, if any
+	stringify bool   // whether numbers are encoded as JSON strings
+}
+
+// arshaler marshals and unmarshals values of a particular type.
+// The value passed to unmarshal is always addressable.
+type arshaler struct {
+	marshal   func(*jsontext.Encoder, reflect.Value, fieldOpts) error
+	unmarshal func(*jsontext.Decoder, reflect.Value, fieldOpts) error
+}
+
+var arshalerCache sync.Map // map[reflect.Type]*arshaler
+
+// lookupArshaler returns the arshaler for type t,
+// ignoring any type-specific functions provided as options.
+func lookupArshaler(t reflect.Type) *arshaler {
+	if a, ok := arshalerCache.Load(t); ok {
+		return a.(*arshaler)
+	}
+	a := makeDefaultArshaler(t)
+	a = makeMethodArshaler(t, a)
+	a = makeTimeArshaler(t, a)
+	v, _ := arshalerCache.LoadOrStore(t, a)
+	return v.(*arshaler)
+}
+
+// marshalValue marshals v, which must be valid.
+func marshalValue(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+	if m, _ := jsonopts.EncoderOptions(enc).Marshalers.(*Marshalers); m != nil {
+		if ok, err := m.marshal(enc, v); ok {
+			return err
+		}
+	}
+	return lookupArshaler(v.Type()).marshal(enc, v, fo)
+}
+
+// unmarshalValue unmarshals into v, which must be addressable.
+func unmarshalValue(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+	if u, _ := jsonopts.DecoderOptions(dec).Unmarshalers.(*Unmarshalers); u != nil {
+		if ok, err := u.unmarshal(dec, v); ok {
+			return err
+		}
+	}
+	return lookupArshaler(v.Type()).unmarshal(dec, v, fo)
+}
+
+// stackPosition is the position of an Encoder or Decoder within
+// the JSON value being processed. It is used to verify that
+// user-provided functions and methods process exactly one JSON value.
+type stackPosition struct {
+	depth  int
+	length int64
+}
+
+func encoderPosition(enc *jsontext.Encoder) stackPosition {
+	depth := enc.StackDepth()
+	_, length := enc.StackIndex(depth)
+	return stackPosition{depth, length}
+}
+
+func decoderPosition(dec *jsontext.Decoder) stackPosition {
+	depth := dec.StackDepth()
+	_, length := dec.StackIndex(depth)
+	return stackPosition{depth, length}
+}
+
+// oneValueAfter reports whether p is exactly one JSON value after prev.
+func (p stackPosition) oneValueAfter(prev stackPosition) bool {
+	return p.depth == prev.depth && p.length == prev.length+1
+}
+
+var errNotOneValue = errors.New("must read or write exactly one JSON value")
@@ -0,0 +1,1091 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"encoding/json/internal/jsonopts"
+	"encoding/json/jsontext"
+)
+
+var (
+	errUnsupportedType = errors.New("unsupported type")
+	errInvalidFormat   = errors.New("invalid format flag")
+	errNonFinite       = errors.New("unsupported non-finite float")
+	errCycle           = errors.New("encountered a cycle")
+)
+
+// startDetectingCyclesAfter is the [jsontext.Encoder.StackDepth] beyond which
+// pointers, maps, and slices are recorded in the encoder's set of values
+// being marshaled. Any cycle eventually reaches this depth, at which point
+// its values are recorded on the next trip around it.
+const startDetectingCyclesAfter = 1000
+
+// typedPointer is a key in the set of values being marshaled,
+// which is kept in the encoder state so that it is shared by
+// nested calls to MarshalEncode and reset along with the Encoder.
+// Only values on the path from the top-level value are in the set,
+// so a value referenced twice by its siblings is not a cycle.
+// An embedded struct shares its address with the struct embedding it
+// and a sub-slice shares its address with the whole slice,
+// so the type and length are part of the key.
+type typedPointer struct {
+	typ reflect.Type
+	ptr any // an unsafe.Pointer, without depending on package unsafe
+	len int
+}
+
+func typedPointerOf(v reflect.Value) typedPointer {
+	p := typedPointer{typ: v.Type(), ptr: v.UnsafePointer()}
+	if v.Kind() == reflect.Slice {
+		p.len = v.Len()
+	}
+	return p
+}
+
+// visitPointer records that v, a pointer, map, or slice, is being marshaled
+// by enc, reporting errCycle if it already is. If it succeeds, the caller
+// must call leavePointer when it has marshaled v.
+func visitPointer(enc *jsontext.Encoder, v reflect.Value) error {
+	m := jsonopts.EncoderSeenPointers(enc)
+	p := typedPointerOf(v)
+	if _, ok := (*m)[p]; ok {
+		return errCycle
+	}
+	if *m == nil {
+		*m = make(map[any]struct{})
+	}
+	(*m)[p] = struct{}{}
+	return nil
+}
+
+func leavePointer(enc *jsontext.Encoder, v reflect.Value) {
+	delete(*jsonopts.EncoderSeenPointers(enc), typedPointerOf(v))
+}
+
+// makeDefaultArshaler returns the arshaler for the default
+// representation of type t.
+func makeDefaultArshaler(t reflect.Type) *arshaler {
+	var a *arshaler
+	switch t.Kind() {
+	case reflect.Bool:
+		a = makeBoolArshaler(t)
+	case reflect.String:
+		a = makeStringArshaler(t)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		a = makeIntArshaler(t)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		a = makeUintArshaler(t)
+	case reflect.Float32, reflect.Float64:
+		a = makeFloatArshaler(t)
+	case reflect.Map:
+		a = makeMapArshaler(t)
+	case reflect.Struct:
+		a = makeStructArshaler(t)
+	case reflect.Slice:
+		a = makeSliceArshaler(t)
+	case reflect.Array:
+		a = makeArrayArshaler(t)
+	case reflect.Pointer:
+		a = makePointerArshaler(t)
+	case reflect.Interface:
+		a = makeInterfaceArshaler(t)
+	default:
+		a = makeInvalidArshaler(t, errUnsupportedType)
+	}
+
+	// A JSON null unmarshals as the zero value for all default representations.
+	unmarshal := a.unmarshal
+	a.unmarshal = func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+		if dec.PeekKind() == 'n' {
+			if _, err := dec.ReadToken(); err != nil {
+				return err
+			}
+			v.SetZero()
+			return nil
+		}
+		return unmarshal(dec, v, fo)
+	}
+	return a
+}
+
+func makeInvalidArshaler(t reflect.Type, err error) *arshaler {
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			return newMarshalError(enc, t, err)
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			return newUnmarshalError(dec, dec.PeekKind(), t, err)
+		},
+	}
+}
+
+// readToken reads the next token, which must be of the given kind.
+func readToken(dec *jsontext.Decoder, t reflect.Type, want jsontext.Kind) (jsontext.Token, error) {
+	if k := dec.PeekKind(); k != want && k != 0 {
+		err := newUnmarshalError(dec, k, t, nil)
+		return jsontext.Token{}, err
+	}
+	return dec.ReadToken()
+}
+
+func makeBoolArshaler(t reflect.Type) *arshaler {
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newMarshalError(enc, t, errInvalidFormat)
+			}
+			return enc.WriteToken(jsontext.Bool(v.Bool()))
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			switch k := dec.PeekKind(); k {
+			case 't', 'f':
+				tok, err := dec.ReadToken()
+				if err != nil {
+					return err
+				}
+				v.SetBool(tok.Bool())
+				return nil
+			default:
+				_, err := readToken(dec, t, 't')
+				return err
+			}
+		},
+	}
+}
+
+func makeStringArshaler(t reflect.Type) *arshaler {
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newMarshalError(enc, t, errInvalidFormat)
+			}
+			if err := enc.WriteToken(jsontext.String(v.String())); err != nil {
+				return newMarshalError(enc, t, err)
+			}
+			return nil
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			tok, err := readToken(dec, t, '"')
+			if err != nil {
+				return err
+			}
+			v.SetString(tok.String())
+			return nil
+		},
+	}
+}
+
+// stringifyNumbers reports whether numbers are represented as JSON strings.
+func stringifyNumbers(o *jsonopts.Struct, fo fieldOpts) bool {
+	return fo.stringify || o.Get(jsonopts.StringifyNumbers)
+}
+
+// writeNumber writes the JSON number text num, optionally quoted.
+func writeNumber(enc *jsontext.Encoder, num []byte, quoted bool) error {
+	if quoted {
+		return enc.WriteToken(jsontext.String(string(num)))
+	}
+	return enc.WriteValue(num)
+}
+
+// readNumber reads a JSON number, optionally quoted,
+// and returns its text.
+func readNumber(dec *jsontext.Decoder, t reflect.Type, quoted bool) (string, jsontext.Kind, error) {
+	want := jsontext.Kind('0')
+	if quoted {
+		want = '"'
+	}
+	tok, err := readToken(dec, t, want)
+	if err != nil {
+		return "", want, err
+	}
+	s := tok.String()
+	if quoted {
+		if v := jsontext.Value(s); !v.IsValid() || v.Kind() != '0' || strings.TrimSpace(s) != s {
+			return "", want, newUnmarshalError(dec, want, t, errors.New("invalid JSON number "+strconv.Quote(s)))
+		}
+	}
+	return s, want, nil
+}
+
+func makeIntArshaler(t reflect.Type) *arshaler {
+	bits := t.Bits()
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newMarshalError(enc, t, errInvalidFormat)
+			}
+			quoted := stringifyNumbers(jsonopts.EncoderOptions(enc), fo)
+			if !quoted {
+				return enc.WriteToken(jsontext.Int(v.Int()))
+			}
+			return writeNumber(enc, strconv.AppendInt(nil, v.Int(), 10), true)
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			s, k, err := readNumber(dec, t, stringifyNumbers(jsonopts.DecoderOptions(dec), fo))
+			if err != nil {
+				return err
+			}
+			n, err := strconv.ParseInt(s, 10, bits)
+			if err != nil {
+				return newUnmarshalError(dec, k, t, errors.New("cannot parse "+strconv.Quote(s)+" as a signed integer"))
+			}
+			v.SetInt(n)
+			return nil
+		},
+	}
+}
+
+func makeUintArshaler(t reflect.Type) *arshaler {
+	bits := t.Bits()
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newMarshalError(enc, t, errInvalidFormat)
+			}
+			quoted := stringifyNumbers(jsonopts.EncoderOptions(enc), fo)
+			if !quoted {
+				return enc.WriteToken(jsontext.Uint(v.Uint()))
+			}
+			return writeNumber(enc, strconv.AppendUint(nil, v.Uint(), 10), true)
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			s, k, err := readNumber(dec, t, stringifyNumbers(jsonopts.DecoderOptions(dec), fo))
+			if err != nil {
+				return err
+			}
+			n, err := strconv.ParseUint(s, 10, bits)
+			if err != nil {
+				return newUnmarshalError(dec, k, t, errors.New("cannot parse "+strconv.Quote(s)+" as an unsigned integer"))
+			}
+			v.SetUint(n)
+			return nil
+		},
+	}
+}
+
+func makeFloatArshaler(t reflect.Type) *arshaler {
+	bits := t.Bits()
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			f := v.Float()
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				if fo.format != "nonfinite" {
+					return newMarshalError(enc, t, errNonFinite)
+				}
+				return enc.WriteToken(jsontext.Float(f))
+			}
+			if fo.format != "" && fo.format != "nonfinite" {
+				return newMarshalError(enc, t, errInvalidFormat)
+			}
+			quoted := stringifyNumbers(jsonopts.EncoderOptions(enc), fo)
+			if bits == 64 && !quoted {
+				return enc.WriteToken(jsontext.Float(f))
+			}
+			return writeNumber(enc, appendFloat(nil, f, bits), quoted)
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" && fo.format != "nonfinite" {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			quoted := stringifyNumbers(jsonopts.DecoderOptions(dec), fo)
+			if fo.format == "nonfinite" && dec.PeekKind() == '"' {
+				tok, err := dec.ReadToken()
+				if err != nil {
+					return err
+				}
+				switch s := tok.String(); s {
+				case "NaN":
+					v.SetFloat(math.NaN())
+				case "Infinity":
+					v.SetFloat(math.Inf(+1))
+				case "-Infinity":
+					v.SetFloat(math.Inf(-1))
+				default:
+					if !quoted {
+						return newUnmarshalError(dec, '"', t, errors.New("invalid non-finite float "+strconv.Quote(s)))
+					}
+					f, err := strconv.ParseFloat(s, bits)
+					if err != nil || !jsontext.Value(s).IsValid() {
+						return newUnmarshalError(dec, '"', t, errors.New("cannot parse "+strconv.Quote(s)+" as a float"))
+					}
+					v.SetFloat(f)
+				}
+				return nil
+			}
+			s, k, err := readNumber(dec, t, quoted)
+			if err != nil {
+				return err
+			}
+			f, err := strconv.ParseFloat(s, bits)
+			if err != nil {
+				return newUnmarshalError(dec, k, t, errors.New("cannot parse "+strconv.Quote(s)+" as a float"))
+			}
+			v.SetFloat(f)
+			return nil
+		},
+	}
+}
+
+// appendFloat appends f formatted as a JSON number, using the same
+// format as ECMAScript's Number.prototype.toString.
+func appendFloat(dst []byte, f float64, bits int) []byte {
+	if bits == 32 {
+		f = float64(float32(f))
+	}
+	abs := math.Abs(f)
+	fmt := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			fmt = 'e'
+		}
+	}
+	dst = strconv.AppendFloat(dst, f, fmt, -1, bits)
+	if fmt == 'e' {
+		// Clean up e-09 to e-9.
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst
+}
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// mapKeyKind reports how keys of type t are represented as JSON object names:
+// 's' for strings, 'i' and 'u' for integers, 't' for text marshalers, or 0.
+func mapKeyKind(t reflect.Type) byte {
+	switch {
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return 't'
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return 's'
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return 'i'
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return 'u'
+	}
+	return 0
+}
+
+func makeMapArshaler(t reflect.Type) *arshaler {
+	kt, vt := t.Key(), t.Elem()
+	keyKind := mapKeyKind(kt)
+	if keyKind == 0 {
+		return makeInvalidArshaler(t, errors.New("unsupported map key type "+kt.String()))
+	}
+
+	// appendKey appends the JSON object name for the map key k.
+	appendKey := func(b []byte, k reflect.Value) ([]byte, error) {
+		switch keyKind {
+		case 's':
+			return append(b, k.String()...), nil
+		case 'i':
+			return strconv.AppendInt(b, k.Int(), 10), nil
+		case 'u':
+			return strconv.AppendUint(b, k.Uint(), 10), nil
+		default:
+			tm, ok := k.Interface().(encoding.TextMarshaler)
+			if !ok {
+				return b, errors.New("map key type " + kt.String() + " does not implement encoding.TextMarshaler")
+			}
+			if k.Kind() == reflect.Pointer && k.IsNil() {
+				return b, nil
+			}
+			s, err := tm.MarshalText()
+			return append(b, s...), err
+		}
+	}
+
+	// setKey sets k to the map key for the JSON object name s.
+	setKey := func(k reflect.Value, s string) error {
+		switch keyKind {
+		case 's':
+			k.SetString(s)
+		case 'i':
+			n, err := strconv.ParseInt(s, 10, kt.Bits())
+			if err != nil {
+				return errors.New("cannot parse " + strconv.Quote(s) + " as a signed integer")
+			}
+			k.SetInt(n)
+		case 'u':
+			n, err := strconv.ParseUint(s, 10, kt.Bits())
+			if err != nil {
+				return errors.New("cannot parse " + strconv.Quote(s) + " as an unsigned integer")
+			}
+			k.SetUint(n)
+		default:
+			if kt.Kind() == reflect.Pointer {
+				k.Set(reflect.New(kt.Elem()))
+				k = k.Elem()
+			}
+			tu, ok := k.Addr().Interface().(encoding.TextUnmarshaler)
+			if !ok {
+				return errors.New("map key type " + kt.String() + " does not implement encoding.TextUnmarshaler")
+			}
+			return tu.UnmarshalText([]byte(s))
+		}
+		return nil
+	}
+
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			o := jsonopts.EncoderOptions(enc)
+			if v.IsNil() {
+				switch fo.format {
+				case "emitnull":
+					return enc.WriteToken(jsontext.Null)
+				case "emitempty":
+				case "":
+					if o.Get(jsonopts.FormatNilMapAsNull) {
+						return enc.WriteToken(jsontext.Null)
+					}
+				default:
+					return newMarshalError(enc, t, errInvalidFormat)
+				}
+			} else if fo.format != "" && fo.format != "emitnull" && fo.format != "emitempty" {
+				return newMarshalError(enc, t, errInvalidFormat)
+			}
+			if enc.StackDepth() > startDetectingCyclesAfter && !v.IsNil() {
+				if err := visitPointer(enc, v); err != nil {
+					return newMarshalError(enc, t, err)
+				}
+				defer leavePointer(enc, v)
+			}
+
+			type entry struct {
+				name string
+				val  reflect.Value
+			}
+			entries := make([]entry, 0, v.Len())
+			var name []byte
+			for iter := v.MapRange(); iter.Next(); {
+				var err error
+				if name, err = appendKey(name[:0], iter.Key()); err != nil {
+					return newMarshalError(enc, kt, err)
+				}
+				entries = append(entries, entry{string(name), iter.Value()})
+			}
+			if o.Get(jsonopts.Deterministic) {
+				slices.SortFunc(entries, func(x, y entry) int { return strings.Compare(x.name, y.name) })
+			}
+
+			if err := enc.WriteToken(jsontext.ObjectStart); err != nil {
+				return err
+			}
+			// Map values are not addressable, so copy each one
+			// to allow calling methods with pointer receivers.
+			val := reflect.New(vt).Elem()
+			for _, e := range entries {
+				if err := enc.WriteToken(jsontext.String(e.name)); err != nil {
+					return newMarshalError(enc, kt, err)
+				}
+				val.Set(e.val)
+				if err := marshalValue(enc, val, fieldOpts{}); err != nil {
+					return err
+				}
+			}
+			return enc.WriteToken(jsontext.ObjectEnd)
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" && fo.format != "emitnull" && fo.format != "emitempty" {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			if _, err := readToken(dec, t, '{'); err != nil {
+				return err
+			}
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(t))
+			}
+			key := reflect.New(kt).Elem()
+			val := reflect.New(vt).Elem()
+			for dec.PeekKind() != '}' {
+				tok, err := dec.ReadToken()
+				if err != nil {
+					return err
+				}
+				key.SetZero()
+				if err := setKey(key, tok.String()); err != nil {
+					return newUnmarshalError(dec, '"', kt, err)
+				}
+				val.SetZero()
+				if err := unmarshalValue(dec, val, fieldOpts{}); err != nil {
+					return err
+				}
+				v.SetMapIndex(key, val)
+			}
+			_, err := dec.ReadToken()
+			return err
+		},
+	}
+}
+
+// bytesEncoding returns the encoding functions for the given format
+// for a byte slice or array.
+func bytesEncoding(format string) (encode func([]byte) string, decode func(string) ([]byte, error), ok bool) {
+	switch format {
+	case "", "base64":
+		return base64.StdEncoding.EncodeToString, base64.StdEncoding.DecodeString, true
+	case "base64url":
+		return base64.URLEncoding.EncodeToString, base64.URLEncoding.DecodeString, true
+	case "base32":
+		return base32.StdEncoding.EncodeToString, base32.StdEncoding.DecodeString, true
+	case "base32hex":
+		return base32.HexEncoding.EncodeToString, base32.HexEncoding.DecodeString, true
+	case "base16", "hex":
+		return hex.EncodeToString, hex.DecodeString, true
+	}
+	return nil, nil, false
+}
+
+// isBytes reports whether t is a byte slice or array that
+// uses the binary representation by default.
+func isBytes(t reflect.Type) bool {
+	et := t.Elem()
+	return et.Kind() == reflect.Uint8 &&
+		!et.Implements(marshalerType) && !reflect.PointerTo(et).Implements(marshalerType) &&
+		!et.Implements(marshalerToType) && !reflect.PointerTo(et).Implements(marshalerToType) &&
+		!et.Implements(textMarshalerType) && !reflect.PointerTo(et).Implements(textMarshalerType)
+}
+
+func makeSliceArshaler(t reflect.Type) *arshaler {
+	elems := &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			o := jsonopts.EncoderOptions(enc)
+			if v.IsNil() {
+				switch fo.format {
+				case "emitnull":
+					return enc.WriteToken(jsontext.Null)
+				case "emitempty":
+				case "":
+					if o.Get(jsonopts.FormatNilSliceAsNull) {
+						return enc.WriteToken(jsontext.Null)
+					}
+				default:
+					if !(fo.format == "array" && isBytes(t)) {
+						return newMarshalError(enc, t, errInvalidFormat)
+					}
+				}
+			}
+			if enc.StackDepth() > startDetectingCyclesAfter && v.Len() > 0 {
+				if err := visitPointer(enc, v); err != nil {
+					return newMarshalError(enc, t, err)
+				}
+				defer leavePointer(enc, v)
+			}
+			if err := enc.WriteToken(jsontext.ArrayStart); err != nil {
+				return err
+			}
+			for i := range v.Len() {
+				if err := marshalValue(enc, v.Index(i), fieldOpts{}); err != nil {
+					return err
+				}
+			}
+			return enc.WriteToken(jsontext.ArrayEnd)
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if _, err := readToken(dec, t, '['); err != nil {
+				return err
+			}
+			n := 0
+			for dec.PeekKind() != ']' {
+				if n == v.Cap() {
+					v.Grow(1)
+				}
+				v.SetLen(n + 1)
+				elem := v.Index(n)
+				elem.SetZero()
+				if err := unmarshalValue(dec, elem, fieldOpts{}); err != nil {
+					v.SetLen(n)
+					return err
+				}
+				n++
+			}
+			if _, err := dec.ReadToken(); err != nil {
+				return err
+			}
+			if v.IsNil() {
+				v.Set(reflect.MakeSlice(t, 0, 0))
+			}
+			v.SetLen(n)
+			return nil
+		},
+	}
+	if !isBytes(t) {
+		return elems
+	}
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format == "array" {
+				return elems.marshal(enc, v, fo)
+			}
+			if v.IsNil() && (fo.format == "emitnull" || jsonopts.EncoderOptions(enc).Get(jsonopts.FormatNilSliceAsNull)) {
+				return enc.WriteToken(jsontext.Null)
+			}
+			format := fo.format
+			if format == "emitnull" || format == "emitempty" {
+				format = ""
+			}
+			encode, _, ok := bytesEncoding(format)
+			if !ok {
+				return newMarshalError(enc, t, errInvalidFormat)
+			}
+			return enc.WriteToken(jsontext.String(encode(v.Bytes())))
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format == "array" {
+				return elems.unmarshal(dec, v, fo)
+			}
+			format := fo.format
+			if format == "emitnull" || format == "emitempty" {
+				format = ""
+			}
+			_, decode, ok := bytesEncoding(format)
+			if !ok {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			tok, err := readToken(dec, t, '"')
+			if err != nil {
+				return err
+			}
+			b, err := decode(tok.String())
+			if err != nil {
+				return newUnmarshalError(dec, '"', t, err)
+			}
+			if v.IsNil() {
+				v.Set(reflect.MakeSlice(t, 0, len(b)))
+			}
+			v.SetBytes(append(v.Bytes()[:0], b...))
+			return nil
+		},
+	}
+}
+
+func makeArrayArshaler(t reflect.Type) *arshaler {
+	n := t.Len()
+	elems := &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" && !(fo.format == "array" && isBytes(t)) {
+				return newMarshalError(enc, t, errInvalidFormat)
+			}
+			if err := enc.WriteToken(jsontext.ArrayStart); err != nil {
+				return err
+			}
+			for i := range n {
+				if err := marshalValue(enc, v.Index(i), fieldOpts{}); err != nil {
+					return err
+				}
+			}
+			return enc.WriteToken(jsontext.ArrayEnd)
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" && !(fo.format == "array" && isBytes(t)) {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			if _, err := readToken(dec, t, '['); err != nil {
+				return err
+			}
+			i := 0
+			for dec.PeekKind() != ']' {
+				if i >= n {
+					return newUnmarshalError(dec, '[', t, errors.New("too many array elements"))
+				}
+				elem := v.Index(i)
+				elem.SetZero()
+				if err := unmarshalValue(dec, elem, fieldOpts{}); err != nil {
+					return err
+				}
+				i++
+			}
+			if _, err := dec.ReadToken(); err != nil {
+				return err
+			}
+			if i < n {
+				return newUnmarshalError(dec, '[', t, errors.New("too few array elements"))
+			}
+			return nil
+		},
+	}
+	if !isBytes(t) {
+		return elems
+	}
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format == "array" {
+				return elems.marshal(enc, v, fo)
+			}
+			encode, _, ok := bytesEncoding(fo.format)
+			if !ok {
+				return newMarshalError(enc, t, errInvalidFormat)
+			}
+			var b []byte
+			if v.CanAddr() {
+				b = v.Bytes()
+			} else {
+				b = make([]byte, n)
+				reflect.Copy(reflect.ValueOf(b), v)
+			}
+			return enc.WriteToken(jsontext.String(encode(b)))
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format == "array" {
+				return elems.unmarshal(dec, v, fo)
+			}
+			_, decode, ok := bytesEncoding(fo.format)
+			if !ok {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			tok, err := readToken(dec, t, '"')
+			if err != nil {
+				return err
+			}
+			b, err := decode(tok.String())
+			if err != nil {
+				return newUnmarshalError(dec, '"', t, err)
+			}
+			if len(b) != n {
+				return newUnmarshalError(dec, '"', t, errors.New("decoded length of "+strconv.Itoa(len(b))+" mismatches array length of "+strconv.Itoa(n)))
+			}
+			copy(v.Bytes(), b)
+			return nil
+		},
+	}
+}
+
+func makePointerArshaler(t reflect.Type) *arshaler {
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			if v.IsNil() {
+				return enc.WriteToken(jsontext.Null)
+			}
+			if enc.StackDepth() > startDetectingCyclesAfter {
+				if err := visitPointer(enc, v); err != nil {
+					return newMarshalError(enc, t, err)
+				}
+				defer leavePointer(enc, v)
+			}
+			return marshalValue(enc, v.Elem(), fo)
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if v.IsNil() {
+				v.Set(reflect.New(t.Elem()))
+			}
+			return unmarshalValue(dec, v.Elem(), fo)
+		},
+	}
+}
+
+var errNonEmptyInterface = errors.New("cannot derive concrete type for non-empty interface")
+
+func makeInterfaceArshaler(t reflect.Type) *arshaler {
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newMarshalError(enc, t, errInvalidFormat)
+			}
+			if v.IsNil() {
+				return enc.WriteToken(jsontext.Null)
+			}
+			return marshalValue(enc, v.Elem(), fieldOpts{})
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			if e := v.Elem(); e.IsValid() && e.Kind() == reflect.Pointer && !e.IsNil() {
+				return unmarshalValue(dec, e, fieldOpts{})
+			}
+			if t.NumMethod() > 0 {
+				return newUnmarshalError(dec, dec.PeekKind(), t, errNonEmptyInterface)
+			}
+			val, err := unmarshalAny(dec)
+			if err != nil {
+				return err
+			}
+			if val == nil {
+				v.SetZero()
+			} else {
+				v.Set(reflect.ValueOf(val))
+			}
+			return nil
+		},
+	}
+}
+
+var anyType = reflect.TypeFor[any]()
+
+// unmarshalAny unmarshals the next JSON value as a
+// nil, bool, string, float64, map[string]any, or []any.
+func unmarshalAny(dec *jsontext.Decoder) (any, error) {
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return nil, err
+	}
+	switch k := tok.Kind(); k {
+	case 'n':
+		return nil, nil
+	case 't', 'f':
+		return tok.Bool(), nil
+	case '"':
+		return tok.String(), nil
+	case '0':
+		s := tok.String()
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, newUnmarshalError(dec, k, anyType, errors.New("cannot parse "+strconv.Quote(s)+" as a float64"))
+		}
+		return f, nil
+	case '{':
+		m := make(map[string]any)
+		for dec.PeekKind() != '}' {
+			tok, err := dec.ReadToken()
+			if err != nil {
+				return nil, err
+			}
+			name := tok.String()
+			if m[name], err = unmarshalAny(dec); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := dec.ReadToken(); err != nil {
+			return nil, err
+		}
+		return m, nil
+	default: // '['
+		s := []any{}
+		for dec.PeekKind() != ']' {
+			val, err := unmarshalAny(dec)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, val)
+		}
+		if _, err := dec.ReadToken(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+}
+
+// isEmptyValue reports whether v is an empty string, slice, map, or array,
+// or a nil pointer or interface, which encode as an empty JSON value
+// by default.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// writeObjectMembers writes the members of the JSON object obj
+// to the JSON object currently being written by enc.
+func writeObjectMembers(enc *jsontext.Encoder, obj jsontext.Value) error {
+	if len(bytes.TrimSpace(obj)) == 0 {
+		return nil
+	}
+	dec := jsontext.NewDecoder(bytes.NewReader(obj))
+	if k := dec.PeekKind(); k != '{' {
+		return errors.New("inlined raw value must be a JSON object")
+	}
+	dec.ReadToken()
+	for dec.PeekKind() != '}' {
+		name, err := dec.ReadValue()
+		if err != nil {
+			return err
+		}
+		if err := enc.WriteValue(name); err != nil {
+			return err
+		}
+		val, err := dec.ReadValue()
+		if err != nil {
+			return err
+		}
+		if err := enc.WriteValue(val); err != nil {
+			return err
+		}
+	}
+	_, err := dec.ReadToken()
+	return err
+}
+
+func makeStructArshaler(t reflect.Type) *arshaler {
+	fields, err := makeStructFields(t)
+	if err != nil {
+		return makeInvalidArshaler(t, err)
+	}
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newMarshalError(enc, t, errInvalidFormat)
+			}
+			o := jsonopts.EncoderOptions(enc)
+			if err := enc.WriteToken(jsontext.ObjectStart); err != nil {
+				return err
+			}
+			omitzero := o.Get(jsonopts.OmitZeroStructFields)
+			for i := range fields.flattened {
+				f := &fields.flattened[i]
+				fv, ok := fieldByIndex(v, f.index, false)
+				if !ok {
+					continue
+				}
+				if (f.omitzero || omitzero) && f.isZero(fv) {
+					continue
+				}
+				if f.omitempty && isEmptyValue(fv) {
+					continue
+				}
+				if err := enc.WriteToken(jsontext.String(f.name)); err != nil {
+					return err
+				}
+				if err := marshalValue(enc, fv, f.fo); err != nil {
+					return err
+				}
+			}
+			if f := fields.inlined; f != nil {
+				if fv, ok := fieldByIndex(v, f.index, false); ok {
+					if err := marshalInlined(enc, fv); err != nil {
+						return newMarshalError(enc, f.typ, err)
+					}
+				}
+			}
+			return enc.WriteToken(jsontext.ObjectEnd)
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if fo.format != "" {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			o := jsonopts.DecoderOptions(dec)
+			if _, err := readToken(dec, t, '{'); err != nil {
+				return err
+			}
+			matchCaseInsensitive := o.Get(jsonopts.MatchCaseInsensitiveNames)
+			for dec.PeekKind() != '}' {
+				tok, err := dec.ReadToken()
+				if err != nil {
+					return err
+				}
+				name := tok.String()
+				if f := fields.lookup(name, matchCaseInsensitive); f != nil {
+					fv, _ := fieldByIndex(v, f.index, true)
+					if err := unmarshalValue(dec, fv, f.fo); err != nil {
+						return err
+					}
+					continue
+				}
+				switch {
+				case o.Get(jsonopts.RejectUnknownMembers):
+					return newUnmarshalError(dec, '"', t, ErrUnknownName)
+				case fields.inlined != nil:
+					fv, _ := fieldByIndex(v, fields.inlined.index, true)
+					if err := unmarshalInlined(dec, fv, name); err != nil {
+						return err
+					}
+				default:
+					if err := dec.SkipValue(); err != nil {
+						return err
+					}
+				}
+			}
+			_, err := dec.ReadToken()
+			return err
+		},
+	}
+}
+
+// marshalInlined writes the members held by the inlined fallback field v,
+// which is either a jsontext.Value or a map with string keys.
+func marshalInlined(enc *jsontext.Encoder, v reflect.Value) error {
+	if v.Type() == jsontextValueType {
+		return writeObjectMembers(enc, v.Interface().(jsontext.Value))
+	}
+	keys := make([]string, 0, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		keys = append(keys, iter.Key().String())
+	}
+	if jsonopts.EncoderOptions(enc).Get(jsonopts.Deterministic) {
+		slices.Sort(keys)
+	}
+	val := reflect.New(v.Type().Elem()).Elem()
+	key := reflect.New(v.Type().Key()).Elem()
+	for _, k := range keys {
+		if err := enc.WriteToken(jsontext.String(k)); err != nil {
+			return err
+		}
+		key.SetString(k)
+		val.Set(v.MapIndex(key))
+		if err := marshalValue(enc, val, fieldOpts{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalInlined stores the value of the unknown member name
+// in the inlined fallback field v.
+func unmarshalInlined(dec *jsontext.Decoder, v reflect.Value, name string) error {
+	if v.Type() == jsontextValueType {
+		val, err := dec.ReadValue()
+		if err != nil {
+			return err
+		}
+		b := v.Interface().(jsontext.Value)
+		if b = bytes.TrimSpace(b); len(b) == 0 {
+			b = append(b, '{')
+		} else if b = bytes.TrimSpace(b[:len(b)-1]); b[len(b)-1] != '{' {
+			b = append(b, ',')
+		}
+		b, _ = jsontext.AppendQuote(b, name)
+		b = append(b, ':')
+		b = append(b, val...)
+		b = append(b, '}')
+		v.SetBytes(b)
+		return nil
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	val := reflect.New(v.Type().Elem()).Elem()
+	if err := unmarshalValue(dec, val, fieldOpts{}); err != nil {
+		return err
+	}
+	key := reflect.New(v.Type().Key()).Elem()
+	key.SetString(name)
+	v.SetMapIndex(key, val)
+	return nil
+}
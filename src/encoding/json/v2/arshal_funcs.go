@@ -0,0 +1,310 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"errors"
+	"reflect"
+
+	"encoding/json/jsontext"
+)
+
+// SkipFunc may be returned by [MarshalToFunc] and [UnmarshalFromFunc] functions.
+//
+// Any function that returns SkipFunc must not cause observable side effects
+// on the provided [jsontext.Encoder] or [jsontext.Decoder].
+// For example, it is permissible to call [jsontext.Decoder.PeekKind],
+// but not permissible to call [jsontext.Decoder.ReadToken] or
+// [jsontext.Encoder.WriteToken] since such methods mutate the state.
+var SkipFunc = errors.New("json: skip function")
+
+var errSkipMutation = errors.New("must not read or write any tokens when skipping")
+
+// Marshalers is a list of functions that may override the marshal behavior
+// of specific types. Populate [WithMarshalers] to use it with
+// [Marshal], [MarshalWrite], or [MarshalEncode].
+// A nil *Marshalers is equivalent to an empty list.
+// There are no exported fields or methods on Marshalers.
+type Marshalers struct {
+	fncs []typedFunc[jsontext.Encoder]
+}
+
+// Unmarshalers is a list of functions that may override the unmarshal behavior
+// of specific types. Populate [WithUnmarshalers] to use it with
+// [Unmarshal], [UnmarshalRead], or [UnmarshalDecode].
+// A nil *Unmarshalers is equivalent to an empty list.
+// There are no exported fields or methods on Unmarshalers.
+type Unmarshalers struct {
+	fncs []typedFunc[jsontext.Decoder]
+}
+
+// typedFunc is a marshal or unmarshal function for values of type typ.
+type typedFunc[Coder any] struct {
+	typ reflect.Type
+	fnc func(*Coder, reflect.Value) error // may return SkipFunc
+}
+
+// JoinMarshalers constructs a flattened list of marshal functions.
+// If multiple functions in the list are applicable for a value of a given type,
+// then those earlier in the list take precedence over those that come later.
+// If a function returns [SkipFunc], then the next applicable function is called,
+// otherwise the default marshaling behavior is used.
+//
+// For example:
+//
+//	m1 := JoinMarshalers(f1, f2)
+//	m2 := JoinMarshalers(f0, m1, f3)     // equivalent to m3
+//	m3 := JoinMarshalers(f0, f1, f2, f3) // equivalent to m2
+func JoinMarshalers(ms ...*Marshalers) *Marshalers {
+	var fncs []typedFunc[jsontext.Encoder]
+	for _, m := range ms {
+		if m != nil {
+			fncs = append(fncs, m.fncs...)
+		}
+	}
+	return &Marshalers{fncs: fncs}
+}
+
+// JoinUnmarshalers constructs a flattened list of unmarshal functions.
+// If multiple functions in the list are applicable for a value of a given type,
+// then those earlier in the list take precedence over those that come later.
+// If a function returns [SkipFunc], then the next applicable function is called,
+// otherwise the default unmarshaling behavior is used.
+//
+// For example:
+//
+//	u1 := JoinUnmarshalers(f1, f2)
+//	u2 := JoinUnmarshalers(f0, u1, f3)     // equivalent to u3
+//	u3 := JoinUnmarshalers(f0, f1, f2, f3) // equivalent to u2
+func JoinUnmarshalers(us ...*Unmarshalers) *Unmarshalers {
+	var fncs []typedFunc[jsontext.Decoder]
+	for _, u := range us {
+		if u != nil {
+			fncs = append(fncs, u.fncs...)
+		}
+	}
+	return &Unmarshalers{fncs: fncs}
+}
+
+// MarshalFunc constructs a type-specific marshaler that
+// specifies how to marshal values of type T.
+// T can be any type except a named pointer.
+// The function is always provided with a non-nil pointer value
+// if T is an interface or pointer type.
+//
+// The function must marshal exactly one JSON value.
+// The value of T must not be retained outside the function call.
+// It may return [SkipFunc] such that marshaling can move on to
+// the next marshal function. However, no mutable method calls may
+// be called on the [jsontext.Encoder] if [SkipFunc] is returned.
+func MarshalFunc[T any](fn func(T) ([]byte, error)) *Marshalers {
+	t := reflect.TypeFor[T]()
+	return &Marshalers{fncs: []typedFunc[jsontext.Encoder]{{
+		typ: t,
+		fnc: func(enc *jsontext.Encoder, v reflect.Value) error {
+			b, err := fn(v.Interface().(T))
+			if err != nil {
+				if err == SkipFunc {
+					return SkipFunc
+				}
+				return newMarshalError(enc, t, err)
+			}
+			if err := enc.WriteValue(b); err != nil {
+				return &SemanticError{
+					action:      "marshal",
+					ByteOffset:  enc.OutputOffset(),
+					JSONPointer: enc.StackPointer(),
+					GoType:      t,
+					Err:         errors.New("invalid output from MarshalFunc: " + err.Error()),
+				}
+			}
+			return nil
+		},
+	}}}
+}
+
+// MarshalToFunc constructs a type-specific marshaler that
+// specifies how to marshal values of type T.
+// T can be any type except a named pointer.
+// The function is always provided with a non-nil pointer value
+// if T is an interface or pointer type.
+//
+// The function must marshal exactly one JSON value by calling write methods
+// on the provided encoder. It may return [SkipFunc] such that marshaling can
+// move on to the next marshal function. However, no mutable method calls may
+// be called on the [jsontext.Encoder] if [SkipFunc] is returned.
+// The pointer to [jsontext.Encoder] and the value of T
+// must not be retained outside the function call.
+func MarshalToFunc[T any](fn func(*jsontext.Encoder, T) error) *Marshalers {
+	t := reflect.TypeFor[T]()
+	return &Marshalers{fncs: []typedFunc[jsontext.Encoder]{{
+		typ: t,
+		fnc: func(enc *jsontext.Encoder, v reflect.Value) error {
+			prev := encoderPosition(enc)
+			prevOffset := enc.OutputOffset()
+			err := fn(enc, v.Interface().(T))
+			if err == SkipFunc {
+				if encoderPosition(enc) != prev || enc.OutputOffset() != prevOffset {
+					return newMarshalError(enc, t, errSkipMutation)
+				}
+				return SkipFunc
+			}
+			if err != nil {
+				return newMarshalError(enc, t, err)
+			}
+			if !encoderPosition(enc).oneValueAfter(prev) {
+				return newMarshalError(enc, t, errNotOneValue)
+			}
+			return nil
+		},
+	}}}
+}
+
+// UnmarshalFunc constructs a type-specific unmarshaler that
+// specifies how to unmarshal values of type T.
+// T must be an unnamed pointer or an interface type.
+// The function is always provided with a non-nil pointer value.
+//
+// The function must unmarshal exactly one JSON value.
+// The input []byte must not be mutated.
+// The input []byte and value T must not be retained outside the function call.
+// It may not return [SkipFunc].
+func UnmarshalFunc[T any](fn func([]byte, T) error) *Unmarshalers {
+	t := reflect.TypeFor[T]()
+	checkUnmarshalFuncType(t)
+	return &Unmarshalers{fncs: []typedFunc[jsontext.Decoder]{{
+		typ: t,
+		fnc: func(dec *jsontext.Decoder, v reflect.Value) error {
+			val, err := dec.ReadValue()
+			if err != nil {
+				return err
+			}
+			if err := fn(val, v.Interface().(T)); err != nil {
+				if err == SkipFunc {
+					err = errors.New("unmarshal function of type func([]byte, T) error cannot be skipped")
+				}
+				return newUnmarshalError(dec, val.Kind(), t, err)
+			}
+			return nil
+		},
+	}}}
+}
+
+// UnmarshalFromFunc constructs a type-specific unmarshaler that
+// specifies how to unmarshal values of type T.
+// T must be an unnamed pointer or an interface type.
+// The function is always provided with a non-nil pointer value.
+//
+// The function must unmarshal exactly one JSON value by calling read methods
+// on the provided decoder. It may return [SkipFunc] such that unmarshaling can
+// move on to the next unmarshal function. However, no mutable method calls may
+// be called on the [jsontext.Decoder] if [SkipFunc] is returned.
+// The pointer to [jsontext.Decoder] and the value of T
+// must not be retained outside the function call.
+func UnmarshalFromFunc[T any](fn func(*jsontext.Decoder, T) error) *Unmarshalers {
+	t := reflect.TypeFor[T]()
+	checkUnmarshalFuncType(t)
+	return &Unmarshalers{fncs: []typedFunc[jsontext.Decoder]{{
+		typ: t,
+		fnc: func(dec *jsontext.Decoder, v reflect.Value) error {
+			k := dec.PeekKind()
+			prev := decoderPosition(dec)
+			prevOffset := dec.InputOffset()
+			err := fn(dec, v.Interface().(T))
+			if err == SkipFunc {
+				if decoderPosition(dec) != prev || dec.InputOffset() != prevOffset {
+					return newUnmarshalError(dec, k, t, errSkipMutation)
+				}
+				return SkipFunc
+			}
+			if err != nil {
+				return newUnmarshalError(dec, k, t, err)
+			}
+			if !decoderPosition(dec).oneValueAfter(prev) {
+				return newUnmarshalError(dec, k, t, errNotOneValue)
+			}
+			return nil
+		},
+	}}}
+}
+
+func checkUnmarshalFuncType(t reflect.Type) {
+	if !(t.Kind() == reflect.Pointer && t.Name() == "") && t.Kind() != reflect.Interface {
+		panic("json: invalid type " + t.String() + " for unmarshal function; must be an unnamed pointer or an interface")
+	}
+}
+
+// matchMarshal returns the argument to pass to a marshal function
+// for values of type typ, given the value v.
+// It reports false if the function does not apply to v.
+// Nil pointers and interfaces never match and are marshaled as a JSON null.
+func matchMarshal(typ reflect.Type, v reflect.Value) (reflect.Value, bool) {
+	t := v.Type()
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+	}
+	switch {
+	case t == typ:
+		return v, true
+	case t.Kind() == reflect.Interface:
+		// Interface values only match functions for the exact type.
+	case typ.Kind() == reflect.Interface && t.Implements(typ):
+		return v, true
+	case v.CanAddr() && (reflect.PointerTo(t) == typ ||
+		typ.Kind() == reflect.Interface && reflect.PointerTo(t).Implements(typ)):
+		return v.Addr(), true
+	}
+	return reflect.Value{}, false
+}
+
+// matchUnmarshal returns the argument to pass to an unmarshal function
+// for values of type typ, given the addressable value v.
+// It reports false if the function does not apply to v.
+func matchUnmarshal(typ reflect.Type, v reflect.Value) (reflect.Value, bool) {
+	t := v.Type()
+	switch {
+	case t == typ && t.Kind() == reflect.Interface:
+		return v, !v.IsNil()
+	case t.Kind() == reflect.Interface:
+		// Interface values only match functions for the exact type.
+	case reflect.PointerTo(t) == typ ||
+		typ.Kind() == reflect.Interface && reflect.PointerTo(t).Implements(typ):
+		return v.Addr(), true
+	}
+	return reflect.Value{}, false
+}
+
+// marshal calls the first applicable function for v
+// that does not return SkipFunc, and reports whether one was called.
+func (m *Marshalers) marshal(enc *jsontext.Encoder, v reflect.Value) (bool, error) {
+	for _, f := range m.fncs {
+		arg, ok := matchMarshal(f.typ, v)
+		if !ok {
+			continue
+		}
+		if err := f.fnc(enc, arg); err != SkipFunc {
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+// unmarshal calls the first applicable function for v
+// that does not return SkipFunc, and reports whether one was called.
+func (u *Unmarshalers) unmarshal(dec *jsontext.Decoder, v reflect.Value) (bool, error) {
+	for _, f := range u.fncs {
+		arg, ok := matchUnmarshal(f.typ, v)
+		if !ok {
+			continue
+		}
+		if err := f.fnc(dec, arg); err != SkipFunc {
+			return true, err
+		}
+	}
+	return false, nil
+}
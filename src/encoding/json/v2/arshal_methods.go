@@ -0,0 +1,202 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"encoding"
+	"errors"
+	"reflect"
+
+	"encoding/json/jsontext"
+)
+
+// Marshaler is implemented by types that can marshal themselves.
+// It is recommended that types implement [MarshalerTo] unless the implementation
+// is trying to avoid a hard dependency on the "jsontext" package.
+//
+// It is recommended that implementations return a buffer that is safe
+// for the caller to retain and potentially mutate.
+type Marshaler interface {
+	MarshalJSON() ([]byte, error)
+}
+
+// MarshalerTo is implemented by types that can marshal themselves.
+// It is recommended that types implement MarshalerTo instead of [Marshaler]
+// since this is both more performant and flexible.
+// If a type implements both Marshaler and MarshalerTo,
+// then MarshalerTo takes precedence. In such a case, both implementations
+// should aim to have equivalent behavior for the default marshal options.
+//
+// The implementation must write only one JSON value to the Encoder and
+// must not retain the pointer to [jsontext.Encoder].
+type MarshalerTo interface {
+	MarshalJSONTo(*jsontext.Encoder) error
+}
+
+// Unmarshaler is implemented by types that can unmarshal themselves.
+// It is recommended that types implement [UnmarshalerFrom] unless the implementation
+// is trying to avoid a hard dependency on the "jsontext" package.
+//
+// The input can be assumed to be a valid encoding of a JSON value
+// if called from unmarshal functionality in this package.
+// UnmarshalJSON must copy the JSON data if it is retained after returning.
+// It is recommended that UnmarshalJSON implement merge semantics when
+// unmarshaling into a pre-populated value.
+type Unmarshaler interface {
+	UnmarshalJSON([]byte) error
+}
+
+// UnmarshalerFrom is implemented by types that can unmarshal themselves.
+// It is recommended that types implement UnmarshalerFrom instead of [Unmarshaler]
+// since this is both more performant and flexible.
+// If a type implements both Unmarshaler and UnmarshalerFrom,
+// then UnmarshalerFrom takes precedence. In such a case, both implementations
+// should aim to have equivalent behavior for the default unmarshal options.
+//
+// The implementation must read only one JSON value from the Decoder.
+// It is recommended that UnmarshalJSONFrom implement merge semantics when
+// unmarshaling into a pre-populated value.
+//
+// Implementations must not retain the pointer to [jsontext.Decoder].
+type UnmarshalerFrom interface {
+	UnmarshalJSONFrom(*jsontext.Decoder) error
+}
+
+var (
+	marshalerType       = reflect.TypeFor[Marshaler]()
+	marshalerToType     = reflect.TypeFor[MarshalerTo]()
+	unmarshalerType     = reflect.TypeFor[Unmarshaler]()
+	unmarshalerFromType = reflect.TypeFor[UnmarshalerFrom]()
+)
+
+// methodReceiver returns the receiver of the methods of iface for v,
+// which is either v itself or its address.
+// It reports false if neither implements iface.
+func methodReceiver(v reflect.Value, iface reflect.Type) (reflect.Value, bool) {
+	t := v.Type()
+	switch {
+	case t.Implements(iface):
+		return v, true
+	case v.CanAddr() && reflect.PointerTo(t).Implements(iface):
+		return v.Addr(), true
+	}
+	return reflect.Value{}, false
+}
+
+// makeMethodArshaler returns an arshaler that calls the
+// JSON and text marshaling methods of t, if any,
+// and otherwise uses fallback.
+//
+// Pointer and interface types always use fallback,
+// which dereferences them, so that methods are called
+// on the underlying non-nil value.
+func makeMethodArshaler(t reflect.Type, fallback *arshaler) *arshaler {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return fallback
+	}
+	pt := reflect.PointerTo(t)
+	implements := func(iface reflect.Type) bool {
+		return t.Implements(iface) || pt.Implements(iface)
+	}
+	if !implements(marshalerToType) && !implements(marshalerType) && !implements(textMarshalerType) &&
+		!implements(unmarshalerFromType) && !implements(unmarshalerType) && !implements(textUnmarshalerType) {
+		return fallback
+	}
+
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			if rv, ok := methodReceiver(v, marshalerToType); ok {
+				prev := encoderPosition(enc)
+				if err := rv.Interface().(MarshalerTo).MarshalJSONTo(enc); err != nil {
+					return newMarshalError(enc, t, err)
+				}
+				if !encoderPosition(enc).oneValueAfter(prev) {
+					return newMarshalError(enc, t, errNotOneValue)
+				}
+				return nil
+			}
+			if rv, ok := methodReceiver(v, marshalerType); ok {
+				b, err := rv.Interface().(Marshaler).MarshalJSON()
+				if err != nil {
+					return newMarshalError(enc, t, err)
+				}
+				if err := enc.WriteValue(b); err != nil {
+					return &SemanticError{
+						action:      "marshal",
+						ByteOffset:  enc.OutputOffset(),
+						JSONPointer: enc.StackPointer(),
+						GoType:      t,
+						Err:         errors.New("invalid output from MarshalJSON: " + err.Error()),
+					}
+				}
+				return nil
+			}
+			if rv, ok := methodReceiver(v, textMarshalerType); ok {
+				b, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+				if err != nil {
+					return newMarshalError(enc, t, err)
+				}
+				if err := enc.WriteToken(jsontext.String(string(b))); err != nil {
+					return newMarshalError(enc, t, err)
+				}
+				return nil
+			}
+			return fallback.marshal(enc, v, fo)
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if rv, ok := methodReceiver(v, unmarshalerFromType); ok {
+				k := dec.PeekKind()
+				prev := decoderPosition(dec)
+				if err := rv.Interface().(UnmarshalerFrom).UnmarshalJSONFrom(dec); err != nil {
+					return newUnmarshalError(dec, k, t, err)
+				}
+				if !decoderPosition(dec).oneValueAfter(prev) {
+					return newUnmarshalError(dec, k, t, errNotOneValue)
+				}
+				return nil
+			}
+			if rv, ok := methodReceiver(v, unmarshalerType); ok {
+				val, err := dec.ReadValue()
+				if err != nil {
+					return err
+				}
+				if err := rv.Interface().(Unmarshaler).UnmarshalJSON(val); err != nil {
+					return newUnmarshalError(dec, val.Kind(), t, err)
+				}
+				return nil
+			}
+			if rv, ok := methodReceiver(v, textUnmarshalerType); ok {
+				switch k := dec.PeekKind(); k {
+				case 'n':
+					if _, err := dec.ReadToken(); err != nil {
+						return err
+					}
+					v.SetZero()
+					return nil
+				case '"':
+					tok, err := dec.ReadToken()
+					if err != nil {
+						return err
+					}
+					if err := rv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(tok.String())); err != nil {
+						return newUnmarshalError(dec, k, t, err)
+					}
+					return nil
+				default:
+					if k == 0 {
+						_, err := dec.ReadToken()
+						return err
+					}
+					if err := dec.SkipValue(); err != nil {
+						return err
+					}
+					return newUnmarshalError(dec, k, t, errors.New("UnmarshalText requires a JSON string"))
+				}
+			}
+			return fallback.unmarshal(dec, v, fo)
+		},
+	}
+}
@@ -0,0 +1,577 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"encoding/json/jsontext"
+)
+
+type (
+	structAll struct {
+		Bool    bool
+		String  string
+		Bytes   []byte
+		Int     int64
+		Uint    uint8
+		Float   float64
+		Map     map[string]int
+		Slice   []string
+		Array   [2]int
+		Pointer *structAll
+		Iface   any
+	}
+	structTags struct {
+		Renamed   int            `json:"renamed"`
+		OmitZero  int            `json:",omitzero"`
+		OmitEmpty string         `json:",omitempty"`
+		Ignored   int            `json:"-"`
+		Quoted    int            `json:",string"`
+		Hex       []byte         `json:",format:hex"`
+		Unix      time.Time      `json:",format:unix"`
+		Seconds   time.Duration  `json:",format:sec"`
+		NilMap    map[string]int `json:",format:emitnull"`
+		Folded    int            `json:"folded_name,case:ignore"`
+		unexport  int
+	}
+	structEmbedded struct {
+		structInner
+		*StructPointer
+		Outer int
+	}
+	structInner struct {
+		Inner int
+		Outer int // shadowed by structEmbedded.Outer
+	}
+	StructPointer struct {
+		Ptr int
+	}
+	structConflict struct {
+		structConflictA
+		structConflictB
+	}
+	structConflictA struct{ X, Y int }
+	structConflictB struct {
+		X int
+		Y int `json:"Y"`
+	}
+	structUnknownMap struct {
+		A       int
+		Unknown map[string]any `json:",unknown"`
+	}
+	structUnknownValue struct {
+		A       int
+		Unknown jsontext.Value `json:",unknown"`
+	}
+	zeroer       struct{ N int }
+	structIsZero struct {
+		Z zeroer `json:",omitzero"`
+	}
+
+	valueMarshaler   struct{ s string }
+	pointerMarshaler struct{ s string }
+	textMarshaler    struct{ s string }
+	toMarshaler      struct{ n int }
+	badMarshaler     struct{}
+)
+
+func (z zeroer) IsZero() bool { return z.N < 0 }
+
+func (m valueMarshaler) MarshalJSON() ([]byte, error) { return []byte(`"value:` + m.s + `"`), nil }
+
+func (m *pointerMarshaler) MarshalJSON() ([]byte, error) { return []byte(`"pointer:` + m.s + `"`), nil }
+func (m *pointerMarshaler) UnmarshalJSON(b []byte) error {
+	m.s = string(b)
+	return nil
+}
+
+func (m textMarshaler) MarshalText() ([]byte, error) { return []byte("text:" + m.s), nil }
+func (m *textMarshaler) UnmarshalText(b []byte) error {
+	m.s = strings.TrimPrefix(string(b), "text:")
+	return nil
+}
+
+func (m toMarshaler) MarshalJSONTo(enc *jsontext.Encoder) error {
+	return enc.WriteToken(jsontext.Int(int64(m.n) * 10))
+}
+func (m *toMarshaler) UnmarshalJSONFrom(dec *jsontext.Decoder) error {
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	m.n = int(tok.Int() / 10)
+	return nil
+}
+
+func (badMarshaler) MarshalJSON() ([]byte, error) { return []byte(`{`), nil }
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		opts []Options
+		want string
+	}{
+		{name: "Nil", in: nil, want: `null`},
+		{name: "Bool", in: true, want: `true`},
+		{name: "String", in: "hello\u2028<>", want: "\"hello\u2028<>\""},
+		{name: "Int", in: int8(-128), want: `-128`},
+		{name: "Uint", in: uint64(math.MaxUint64), want: `18446744073709551615`},
+		{name: "Float64", in: 3.14, want: `3.14`},
+		{name: "Float32", in: float32(3.14), want: `3.14`},
+		{name: "FloatExponent", in: 1e21, want: `1e+21`},
+		{name: "StringifyNumbers", in: []any{1, 2.5}, opts: []Options{StringifyNumbers(true)}, want: `["1","2.5"]`},
+		{name: "Bytes", in: []byte("hello"), want: `"aGVsbG8="`},
+		{name: "ByteArray", in: [2]byte{1, 2}, want: `"AQI="`},
+		{name: "NilSlice", in: []int(nil), want: `[]`},
+		{name: "NilSliceAsNull", in: []int(nil), opts: []Options{FormatNilSliceAsNull(true)}, want: `null`},
+		{name: "NilMap", in: map[string]int(nil), want: `{}`},
+		{name: "NilMapAsNull", in: map[string]int(nil), opts: []Options{FormatNilMapAsNull(true)}, want: `null`},
+		{name: "MapSorted", in: map[int]string{3: "c", 1: "a", 2: "b"}, opts: []Options{Deterministic(true)}, want: `{"1":"a","2":"b","3":"c"}`},
+		{name: "MapTextKey", in: map[netip.Addr]int{netip.MustParseAddr("::1"): 1}, want: `{"::1":1}`},
+		{name: "Array", in: [3]int{1, 2, 3}, want: `[1,2,3]`},
+		{name: "Pointer", in: &[]int{1}, want: `[1]`},
+		{name: "NilPointer", in: (*int)(nil), want: `null`},
+		{name: "Interface", in: []any{nil, "a", 1}, want: `[null,"a",1]`},
+		{name: "Time", in: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC), want: `"2024-01-02T03:04:05.000000006Z"`},
+		{name: "Duration", in: 90 * time.Second, want: `"1m30s"`},
+		{name: "DurationAsNano", in: time.Microsecond, opts: []Options{FormatDurationAsNano(true)}, want: `1000`},
+		{
+			name: "StructAll",
+			in:   structAll{Bool: true, String: "s", Bytes: []byte{0}, Int: -1, Uint: 1, Float: 0.5, Map: map[string]int{"k": 1}, Slice: []string{"x"}, Array: [2]int{1, 2}, Iface: false},
+			want: `{"Bool":true,"String":"s","Bytes":"AA==","Int":-1,"Uint":1,"Float":0.5,"Map":{"k":1},"Slice":["x"],"Array":[1,2],"Pointer":null,"Iface":false}`,
+		},
+		{
+			name: "StructTags",
+			in: structTags{
+				Renamed: 1, Ignored: 2, Quoted: 3, Hex: []byte{0xab},
+				Unix:    time.Unix(1, 500_000_000),
+				Seconds: 1500 * time.Millisecond,
+				Folded:  4,
+			},
+			want: `{"renamed":1,"Quoted":"3","Hex":"ab","Unix":1.5,"Seconds":1.5,"NilMap":null,"folded_name":4}`,
+		},
+		{name: "OmitZeroStructFields", in: structAll{Int: 1}, opts: []Options{OmitZeroStructFields(true)}, want: `{"Int":1}`},
+		{name: "OmitZeroMethod", in: structIsZero{}, want: `{"Z":{"N":0}}`},
+		{name: "OmitZeroMethodZero", in: structIsZero{zeroer{-1}}, want: `{}`},
+		{name: "Embedded", in: structEmbedded{structInner{1, 2}, &StructPointer{3}, 4}, want: `{"Inner":1,"Ptr":3,"Outer":4}`},
+		{name: "EmbeddedNilPointer", in: structEmbedded{Outer: 4}, want: `{"Inner":0,"Outer":4}`},
+		{name: "Conflict", in: structConflict{structConflictA{1, 2}, structConflictB{3, 4}}, want: `{"Y":4}`},
+		{name: "UnknownMap", in: structUnknownMap{A: 1, Unknown: map[string]any{"b": 2}}, want: `{"A":1,"b":2}`},
+		{name: "UnknownValue", in: structUnknownValue{A: 1, Unknown: jsontext.Value(` {"b" : 2} `)}, want: `{"A":1,"b":2}`},
+		{name: "ValueMarshaler", in: valueMarshaler{"x"}, want: `"value:x"`},
+		{name: "PointerMarshaler", in: pointerMarshaler{"x"}, want: `"pointer:x"`},
+		{name: "PointerMarshalerInMap", in: map[string]pointerMarshaler{"k": {"x"}}, want: `{"k":"pointer:x"}`},
+		{name: "TextMarshaler", in: textMarshaler{"x"}, want: `"text:x"`},
+		{name: "MarshalerTo", in: []toMarshaler{{1}, {2}}, want: `[10,20]`},
+		{name: "RawValue", in: jsontext.Value(`{"a" : 1}`), want: `{"a":1}`},
+		{name: "Multiline", in: []int{1}, opts: []Options{jsontext.Multiline(true), jsontext.WithIndent("  ")}, want: "[\n  1\n]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.in, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal:\ngot  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		opts []Options
+		into any // pointer to a value to unmarshal into
+		want any // expected value pointed at by into
+	}{
+		{name: "Bool", in: `true`, into: new(bool), want: true},
+		{name: "String", in: `"\u00e9"`, into: new(string), want: "\u00e9"},
+		{name: "Int", in: `-5`, into: new(int), want: -5},
+		{name: "Uint", in: `255`, into: new(uint8), want: uint8(255)},
+		{name: "Float", in: `1.5e3`, into: new(float64), want: 1500.0},
+		{name: "StringifyNumbers", in: `"12"`, opts: []Options{StringifyNumbers(true)}, into: new(int), want: 12},
+		{name: "Bytes", in: `"aGVsbG8="`, into: new([]byte), want: []byte("hello")},
+		{name: "Slice", in: `[1,2]`, into: &[]int{9, 9, 9}, want: []int{1, 2}},
+		{name: "EmptySlice", in: `[]`, into: new([]int), want: []int{}},
+		{name: "Array", in: `[1,2]`, into: new([2]int), want: [2]int{1, 2}},
+		{name: "MapMerge", in: `{"b":2}`, into: &map[string]int{"a": 1}, want: map[string]int{"a": 1, "b": 2}},
+		{name: "MapIntKey", in: `{"-1":"x"}`, into: new(map[int]string), want: map[int]string{-1: "x"}},
+		{name: "Pointer", in: `5`, into: new(*int), want: func() *int { n := 5; return &n }()},
+		{name: "NullPointer", in: `null`, into: func() **int { n := 5; p := &n; return &p }(), want: (*int)(nil)},
+		{name: "NullSlice", in: `null`, into: &[]int{1}, want: []int(nil)},
+		{
+			name: "Any",
+			in:   `{"a":[null,true,"s",1.5,{}]}`,
+			into: new(any),
+			want: map[string]any{"a": []any{nil, true, "s", 1.5, map[string]any{}}},
+		},
+		{name: "InterfacePointer", in: `3`, into: func() *any { var v any = new(int); return &v }(), want: func() any { n := 3; return &n }()},
+		{name: "Time", in: `"2024-01-02T03:04:05Z"`, into: new(time.Time), want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{name: "Duration", in: `"1m30s"`, into: new(time.Duration), want: 90 * time.Second},
+		{
+			name: "StructTags",
+			in:   `{"renamed":1,"Quoted":"3","Hex":"ab","Unix":-1.5,"Seconds":0.25,"FOLDED-NAME":4,"Ignored":5}`,
+			into: new(structTags),
+			want: structTags{Renamed: 1, Quoted: 3, Hex: []byte{0xab}, Unix: time.Unix(-2, 500_000_000).UTC(), Seconds: 250 * time.Millisecond, Folded: 4},
+		},
+		{
+			name: "CaseInsensitive",
+			in:   `{"BOOL":true}`,
+			opts: []Options{MatchCaseInsensitiveNames(true)},
+			into: new(structAll),
+			want: structAll{Bool: true},
+		},
+		{name: "CaseSensitive", in: `{"BOOL":true}`, into: new(structAll), want: structAll{}},
+		{name: "StructMerge", in: `{"Int":2}`, into: &structAll{Int: 1, String: "s"}, want: structAll{Int: 2, String: "s"}},
+		{
+			name: "EmbeddedAlloc",
+			in:   `{"Inner":1,"Ptr":2,"Outer":3}`,
+			into: new(structEmbedded),
+			want: structEmbedded{structInner{Inner: 1}, &StructPointer{2}, 3},
+		},
+		{
+			name: "UnknownMap",
+			in:   `{"A":1,"b":[2]}`,
+			into: new(structUnknownMap),
+			want: structUnknownMap{A: 1, Unknown: map[string]any{"b": []any{2.0}}},
+		},
+		{
+			name: "UnknownValue",
+			in:   `{"b":2,"A":1,"c":3}`,
+			into: new(structUnknownValue),
+			want: structUnknownValue{A: 1, Unknown: jsontext.Value(`{"b":2,"c":3}`)},
+		},
+		{name: "PointerUnmarshaler", in: `"x"`, into: new(pointerMarshaler), want: pointerMarshaler{`"x"`}},
+		{name: "TextUnmarshaler", in: `"text:x"`, into: new(textMarshaler), want: textMarshaler{"x"}},
+		{name: "TextUnmarshalerKey", in: `{"::1":1}`, into: new(map[netip.Addr]int), want: map[netip.Addr]int{netip.MustParseAddr("::1"): 1}},
+		{name: "UnmarshalerFrom", in: `[10,20]`, into: new([]toMarshaler), want: []toMarshaler{{1}, {2}}},
+		{name: "RawValue", in: ` [1, 2] `, into: new(jsontext.Value), want: jsontext.Value(`[1, 2]`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Unmarshal([]byte(tt.in), tt.into, tt.opts...); err != nil {
+				t.Fatalf("Unmarshal error: %v", err)
+			}
+			if got := reflect.ValueOf(tt.into).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal:\ngot  %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      any
+		opts    []Options
+		wantErr error
+		wantPtr jsontext.Pointer
+	}{
+		{name: "Chan", in: make(chan int)},
+		{name: "NaN", in: math.NaN(), wantErr: errNonFinite},
+		{name: "NestedNaN", in: map[string]map[string]float64{"k": {"x": math.Inf(1)}}, wantErr: errNonFinite, wantPtr: "/k/x"},
+		{name: "MapKey", in: map[[2]int]int{{}: 1}},
+		{name: "InvalidFormat", in: struct {
+			B bool `json:",format:hex"`
+		}{}, wantErr: errInvalidFormat, wantPtr: "/B"},
+		{name: "BadMarshaler", in: badMarshaler{}},
+		{name: "BadTag", in: struct {
+			A int `json:",bogus"`
+		}{}},
+		{name: "TimeYear", in: time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Marshal(tt.in, tt.opts...)
+			var serr *SemanticError
+			if !errors.As(err, &serr) {
+				t.Fatalf("Marshal error = %v, want SemanticError", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Marshal error = %v, want %v", err, tt.wantErr)
+			}
+			if serr.JSONPointer != tt.wantPtr {
+				t.Errorf("JSONPointer = %q, want %q", serr.JSONPointer, tt.wantPtr)
+			}
+		})
+	}
+}
+
+type recursivePointer struct {
+	P *recursivePointer
+}
+
+// recursiveMarshaler marshals itself by calling MarshalEncode,
+// which continues to use the encoder's record of the values being marshaled.
+type recursiveMarshaler struct {
+	M *recursiveMarshaler
+}
+
+func (m *recursiveMarshaler) MarshalJSONTo(enc *jsontext.Encoder) error {
+	return MarshalEncode(enc, []any{m.M})
+}
+
+func TestMarshalCycle(t *testing.T) {
+	p := new(recursivePointer)
+	p.P = p
+	m := map[string]any{}
+	m["k"] = m
+	s := make([]any, 1)
+	s[0] = s
+	mm := new(recursiveMarshaler)
+	mm.M = mm
+
+	for _, in := range []any{p, m, s, mm} {
+		_, err := Marshal(in)
+		var serr *SemanticError
+		if !errors.As(err, &serr) || !errors.Is(err, errCycle) {
+			t.Errorf("Marshal(%T) error = %v, want a SemanticError for a cycle", in, err)
+			continue
+		}
+		if len(serr.JSONPointer) < startDetectingCyclesAfter {
+			t.Errorf("Marshal(%T) error JSONPointer is %d bytes, want the full pointer", in, len(serr.JSONPointer))
+		}
+		if len(err.Error()) > 300 {
+			t.Errorf("Marshal(%T) error is %d bytes long: %.200s...", in, len(err.Error()), err)
+		}
+	}
+
+	// A value may appear on several paths if it is not its own ancestor,
+	// whether it is referenced by siblings or is a prefix of a slice
+	// containing it.
+	shared := &recursivePointer{}
+	prefix := []any{"x", nil}
+	prefix[1] = prefix[:1]
+	var nested any = []any{shared, shared, prefix}
+	for range startDetectingCyclesAfter {
+		nested = map[string]any{"k": nested}
+	}
+	if _, err := Marshal(nested); err != nil {
+		t.Errorf("Marshal of shared values: %.200s", err)
+	}
+
+	// An Encoder may be reused after it reports a cycle.
+	var buf bytes.Buffer
+	enc := jsontext.NewEncoder(&buf)
+	if err := MarshalEncode(enc, p); !errors.Is(err, errCycle) {
+		t.Fatalf("MarshalEncode error = %v, want a cycle", err)
+	}
+	enc.Reset(&buf)
+	if err := MarshalEncode(enc, []*recursivePointer{p.P.P.P}); !errors.Is(err, errCycle) {
+		t.Errorf("MarshalEncode after Reset error = %.200v, want a cycle", err)
+	}
+	enc.Reset(&buf)
+	if err := MarshalEncode(enc, nested); err != nil {
+		t.Errorf("MarshalEncode after Reset: %.200s", err)
+	}
+}
+
+func TestTruncatePointer(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"", 10, ""},
+		{"/a/b", 10, "/a/b"},
+		{"/aa/bb/cc/dd/ee/ff", 10, "/aa/…/ff"},
+		{"/" + strings.Repeat("x", 20), 10, "/" + strings.Repeat("x", 20)},
+	} {
+		if got := truncatePointer(tt.in, tt.n); got != tt.want {
+			t.Errorf("truncatePointer(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		opts     []Options
+		into     any
+		wantErr  error
+		wantPtr  jsontext.Pointer
+		wantKind jsontext.Kind
+	}{
+		{name: "KindMismatch", in: `{"Int":"1"}`, into: new(structAll), wantPtr: "/Int", wantKind: '"'},
+		{name: "Overflow", in: `256`, into: new(uint8), wantKind: '0'},
+		{name: "Fraction", in: `1.5`, into: new(int), wantKind: '0'},
+		{name: "ArrayLength", in: `[1,2,3]`, into: new([2]int), wantPtr: "/1", wantKind: '['},
+		{name: "ByteArrayLength", in: `"AQ=="`, into: new([2]byte), wantKind: '"'},
+		{name: "NonEmptyInterface", in: `1`, into: new(error), wantKind: '0'},
+		{name: "UnknownName", in: `{"Bogus":1}`, opts: []Options{RejectUnknownMembers(true)}, into: new(structAll), wantErr: ErrUnknownName, wantPtr: "/Bogus", wantKind: '"'},
+		{name: "DurationPrecision", in: `{"Seconds":0.0000000001}`, into: new(structTags), wantErr: errPrecision, wantPtr: "/Seconds", wantKind: '0'},
+		{name: "NonPointer", in: `1`, into: 0, wantErr: errNonNilReference},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Unmarshal([]byte(tt.in), tt.into, tt.opts...)
+			var serr *SemanticError
+			if !errors.As(err, &serr) {
+				t.Fatalf("Unmarshal error = %v, want SemanticError", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Unmarshal error = %v, want %v", err, tt.wantErr)
+			}
+			if serr.JSONPointer != tt.wantPtr || serr.JSONKind != tt.wantKind {
+				t.Errorf("error at (%q, %v), want (%q, %v)", serr.JSONPointer, serr.JSONKind, tt.wantPtr, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestUnmarshalSyntacticErrors(t *testing.T) {
+	for _, in := range []string{`[1,2`, `{"a":1,"a":2}`, "\"\xff\"", `1 2`} {
+		var v any
+		err := Unmarshal([]byte(in), &v)
+		var serr *jsontext.SyntacticError
+		if !errors.As(err, &serr) {
+			t.Errorf("Unmarshal(%q) error = %v, want SyntacticError", in, err)
+		}
+	}
+	var v any
+	if err := Unmarshal(nil, &v); err != io.ErrUnexpectedEOF {
+		t.Errorf("Unmarshal(nil) error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestMarshalers(t *testing.T) {
+	m := JoinMarshalers(
+		MarshalFunc(func(b bool) ([]byte, error) {
+			if b {
+				return []byte(`"yes"`), nil
+			}
+			return nil, SkipFunc
+		}),
+		MarshalToFunc(func(enc *jsontext.Encoder, s fmtStringer) error {
+			return enc.WriteToken(jsontext.String(s.String()))
+		}),
+	)
+	got, err := Marshal([]any{true, false, netip.MustParseAddr("1.2.3.4")}, WithMarshalers(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `["yes",false,"1.2.3.4"]`; string(got) != want {
+		t.Errorf("Marshal = %s, want %s", got, want)
+	}
+
+	bad := MarshalToFunc(func(enc *jsontext.Encoder, n int) error {
+		enc.WriteToken(jsontext.Int(1))
+		return enc.WriteToken(jsontext.Int(2))
+	})
+	if _, err := Marshal([]int{0}, WithMarshalers(bad)); !errors.Is(err, errNotOneValue) {
+		t.Errorf("Marshal with two values error = %v, want %v", err, errNotOneValue)
+	}
+}
+
+type fmtStringer interface{ String() string }
+
+func TestUnmarshalers(t *testing.T) {
+	u := JoinUnmarshalers(
+		UnmarshalFromFunc(func(dec *jsontext.Decoder, n *int) error {
+			if dec.PeekKind() != '"' {
+				return SkipFunc
+			}
+			tok, err := dec.ReadToken()
+			if err != nil {
+				return err
+			}
+			*n = len(tok.String())
+			return nil
+		}),
+		UnmarshalFunc(func(b []byte, s *string) error {
+			*s = strings.ToUpper(string(b))
+			return nil
+		}),
+	)
+	var got struct {
+		A, B int
+		S    string
+	}
+	if err := Unmarshal([]byte(`{"A":"abc","B":7,"S":"x"}`), &got, WithUnmarshalers(u)); err != nil {
+		t.Fatal(err)
+	}
+	if got.A != 3 || got.B != 7 || got.S != `"X"` {
+		t.Errorf("Unmarshal = %+v, want {A:3 B:7 S:\"X\"}", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("UnmarshalFunc with non-pointer type did not panic")
+		}
+	}()
+	UnmarshalFunc(func([]byte, int) error { return nil })
+}
+
+func TestMarshalEncodeOptions(t *testing.T) {
+	var buf bytes.Buffer
+	enc := jsontext.NewEncoder(&buf, Deterministic(true))
+	in := map[string]int{"b": 2, "a": 1}
+	if err := MarshalEncode(enc, in, StringifyNumbers(true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarshalEncode(enc, in); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "{\"a\":\"1\",\"b\":\"2\"}\n{\"a\":1,\"b\":2}\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestUnmarshalDecodeStream(t *testing.T) {
+	dec := jsontext.NewDecoder(strings.NewReader(`1 "two" [3]`))
+	var got []any
+	for {
+		var v any
+		if err := UnmarshalDecode(dec, &v); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	want := []any{1.0, "two", []any{3.0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+}
+
+func TestRoundTripFloat(t *testing.T) {
+	for _, f := range []float64{0, -0.5, 1e-7, 123456789, math.MaxFloat64, math.SmallestNonzeroFloat64} {
+		b, err := Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got float64
+		if err := Unmarshal(b, &got); err != nil || got != f {
+			t.Errorf("round trip of %v via %s = %v, %v", f, b, got, err)
+		}
+	}
+	in := struct {
+		F float64 `json:",format:nonfinite"`
+	}{math.Inf(-1)}
+	b, err := Marshal(in)
+	if err != nil || string(b) != `{"F":"-Infinity"}` {
+		t.Fatalf("Marshal = %s, %v", b, err)
+	}
+	in.F = 0
+	if err := Unmarshal(b, &in); err != nil || !math.IsInf(in.F, -1) {
+		t.Errorf("Unmarshal = %v, %v", in.F, err)
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"encoding/json/internal/jsonopts"
+	"encoding/json/jsontext"
+)
+
+var (
+	timeDurationType = reflect.TypeFor[time.Duration]()
+	timeTimeType     = reflect.TypeFor[time.Time]()
+)
+
+// timeLayouts maps the names of the layout constants
+// in the time package to their values.
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"StampMicro":  time.StampMicro,
+	"StampNano":   time.StampNano,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+}
+
+// durationUnit returns the number of nanoseconds in the unit of
+// a numeric duration format, or zero if format is not such a name.
+func durationUnit(format string) uint64 {
+	switch format {
+	case "sec":
+		return 1e9
+	case "milli":
+		return 1e6
+	case "micro":
+		return 1e3
+	case "nano":
+		return 1
+	}
+	return 0
+}
+
+// unixUnit returns the number of nanoseconds in the unit of
+// a numeric time format, or zero if format is not such a name.
+func unixUnit(format string) uint64 {
+	switch format {
+	case "unix":
+		return 1e9
+	case "unixmilli":
+		return 1e6
+	case "unixmicro":
+		return 1e3
+	case "unixnano":
+		return 1
+	}
+	return 0
+}
+
+// makeTimeArshaler returns an arshaler for time.Time or time.Duration
+// if t is either type, and otherwise returns fallback.
+func makeTimeArshaler(t reflect.Type, fallback *arshaler) *arshaler {
+	switch t {
+	case timeDurationType:
+		return makeDurationArshaler(t)
+	case timeTimeType:
+		return makeTimeTimeArshaler(t)
+	}
+	return fallback
+}
+
+func makeDurationArshaler(t reflect.Type) *arshaler {
+	// unitOf returns the unit of the numeric representation, if any,
+	// and reports whether the format is valid.
+	unitOf := func(format string, o *jsonopts.Struct) (uint64, bool) {
+		if format == "" {
+			if o.Get(jsonopts.FormatDurationAsNano) {
+				return 1, true
+			}
+			return 0, true
+		}
+		unit := durationUnit(format)
+		return unit, unit != 0
+	}
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			d := time.Duration(v.Int())
+			unit, ok := unitOf(fo.format, jsonopts.EncoderOptions(enc))
+			switch {
+			case !ok:
+				return newMarshalError(enc, t, errInvalidFormat)
+			case unit == 0:
+				return enc.WriteToken(jsontext.String(d.String()))
+			case unit == 1:
+				return enc.WriteToken(jsontext.Int(int64(d)))
+			}
+			abs := uint64(d)
+			if d < 0 {
+				abs = -abs
+			}
+			return enc.WriteValue(appendDecimal(nil, d < 0, abs/unit, abs%unit, unit))
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			unit, ok := unitOf(fo.format, jsonopts.DecoderOptions(dec))
+			if !ok {
+				return newUnmarshalError(dec, 0, t, errInvalidFormat)
+			}
+			if dec.PeekKind() == 'n' {
+				if _, err := dec.ReadToken(); err != nil {
+					return err
+				}
+				v.SetZero()
+				return nil
+			}
+			if unit == 0 {
+				tok, err := readToken(dec, t, '"')
+				if err != nil {
+					return err
+				}
+				d, err := time.ParseDuration(tok.String())
+				if err != nil {
+					return newUnmarshalError(dec, '"', t, err)
+				}
+				v.SetInt(int64(d))
+				return nil
+			}
+			tok, err := readToken(dec, t, '0')
+			if err != nil {
+				return err
+			}
+			neg, whole, frac, err := parseDecimal(tok.String(), unit)
+			if err != nil {
+				return newUnmarshalError(dec, '0', t, err)
+			}
+			limit := uint64(math.MaxInt64)
+			if neg {
+				limit++
+			}
+			if whole > (limit-frac)/unit {
+				return newUnmarshalError(dec, '0', t, strconv.ErrRange)
+			}
+			abs := whole*unit + frac
+			if neg {
+				abs = -abs
+			}
+			v.SetInt(int64(abs))
+			return nil
+		},
+	}
+}
+
+func makeTimeTimeArshaler(t reflect.Type) *arshaler {
+	return &arshaler{
+		marshal: func(enc *jsontext.Encoder, v reflect.Value, fo fieldOpts) error {
+			tt := v.Interface().(time.Time)
+			if unit := unixUnit(fo.format); unit != 0 {
+				sec, nsec := tt.Unix(), uint64(tt.Nanosecond())
+				neg := sec < 0
+				abs := uint64(sec)
+				if neg {
+					abs = -abs
+					if nsec > 0 {
+						abs--
+						nsec = 1e9 - nsec
+					}
+				}
+				whole := abs*(1e9/unit) + nsec/unit
+				return enc.WriteValue(appendDecimal(nil, neg, whole, nsec%unit, unit))
+			}
+			layout := time.RFC3339Nano
+			if fo.format != "" {
+				layout = fo.format
+				if l, ok := timeLayouts[fo.format]; ok {
+					layout = l
+				}
+			}
+			if fo.format == "" {
+				// RFC 3339 only permits years within [0, 9999].
+				if y := tt.Year(); y < 0 || y > 9999 {
+					return newMarshalError(enc, t, errors.New("year outside of range [0,9999]"))
+				}
+			}
+			return enc.WriteToken(jsontext.String(tt.Format(layout)))
+		},
+		unmarshal: func(dec *jsontext.Decoder, v reflect.Value, fo fieldOpts) error {
+			if dec.PeekKind() == 'n' {
+				if _, err := dec.ReadToken(); err != nil {
+					return err
+				}
+				v.SetZero()
+				return nil
+			}
+			if unit := unixUnit(fo.format); unit != 0 {
+				tok, err := readToken(dec, t, '0')
+				if err != nil {
+					return err
+				}
+				neg, whole, frac, err := parseDecimal(tok.String(), unit)
+				if err != nil {
+					return newUnmarshalError(dec, '0', t, err)
+				}
+				perSec := 1e9 / unit
+				if whole/perSec > math.MaxInt64 {
+					return newUnmarshalError(dec, '0', t, strconv.ErrRange)
+				}
+				sec := int64(whole / perSec)
+				nsec := int64(whole%perSec*unit + frac)
+				if neg {
+					sec, nsec = -sec, -nsec
+				}
+				v.Set(reflect.ValueOf(time.Unix(sec, nsec).UTC()))
+				return nil
+			}
+			layout := time.RFC3339
+			if fo.format != "" {
+				layout = fo.format
+				if l, ok := timeLayouts[fo.format]; ok {
+					layout = l
+				}
+			}
+			tok, err := readToken(dec, t, '"')
+			if err != nil {
+				return err
+			}
+			tt, err := time.Parse(layout, tok.String())
+			if err != nil {
+				return newUnmarshalError(dec, '"', t, err)
+			}
+			v.Set(reflect.ValueOf(tt))
+			return nil
+		},
+	}
+}
+
+// appendDecimal appends the decimal number whole.frac, where frac
+// is a fraction of unit, which must be a power of ten.
+// Trailing zeros of the fraction are omitted.
+func appendDecimal(b []byte, neg bool, whole, frac, unit uint64) []byte {
+	if neg && (whole != 0 || frac != 0) {
+		b = append(b, '-')
+	}
+	b = strconv.AppendUint(b, whole, 10)
+	if frac > 0 {
+		b = append(b, '.')
+		for unit /= 10; unit > 0 && frac > 0; unit /= 10 {
+			b = append(b, byte('0'+frac/unit))
+			frac %= unit
+		}
+	}
+	return b
+}
+
+var errPrecision = errors.New("fractional value exceeds the precision of the unit")
+
+// parseDecimal parses the JSON number s as a sign, a whole number,
+// and a fraction of unit, which must be a power of ten.
+// Numbers with an exponent or more precision than unit are rejected.
+func parseDecimal(s string, unit uint64) (neg bool, whole, frac uint64, err error) {
+	if strings.ContainsAny(s, "eE") {
+		return false, 0, 0, errors.New("cannot parse " + strconv.Quote(s) + " with an exponent")
+	}
+	s, neg = strings.CutPrefix(s, "-")
+	ws, fs, _ := strings.Cut(s, ".")
+	if whole, err = strconv.ParseUint(ws, 10, 64); err != nil {
+		return false, 0, 0, strconv.ErrRange
+	}
+	for i := 0; i < len(fs); i++ {
+		unit /= 10
+		if unit == 0 {
+			if fs[i] != '0' {
+				return false, 0, 0, errPrecision
+			}
+			continue
+		}
+		frac += uint64(fs[i]-'0') * unit
+	}
+	return neg, whole, frac, nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"encoding/json/jsontext"
+)
+
+const errorPrefix = "json: "
+
+// ErrUnknownName indicates that a JSON object member could not be
+// unmarshaled because the name is not known to the target Go struct.
+// This error is directly wrapped within a [SemanticError] when produced.
+//
+// The name of an unknown JSON object member can be extracted as:
+//
+//	err := ...
+//	var serr *json.SemanticError
+//	if errors.As(err, &serr) && serr.Err == json.ErrUnknownName {
+//		ptr := serr.JSONPointer // JSON pointer to unknown name
+//		name := ptr.LastToken() // unknown name itself
+//		...
+//	}
+//
+// This error is only returned if [RejectUnknownMembers] is true.
+var ErrUnknownName = errors.New("unknown object member name")
+
+// SemanticError describes an error determining the meaning
+// of JSON data as Go data or vice-versa.
+//
+// The contents of this error as produced by this package may change over time.
+type SemanticError struct {
+	action string // either "marshal" or "unmarshal"
+
+	// ByteOffset indicates that an error occurred after this byte offset.
+	ByteOffset int64
+	// JSONPointer indicates that an error occurred within this JSON value
+	// as indicated using the JSON Pointer notation (see RFC 6901).
+	JSONPointer jsontext.Pointer
+
+	// JSONKind is the JSON kind that could not be handled.
+	JSONKind jsontext.Kind // may be zero if unknown
+	// GoType is the Go type that could not be handled.
+	GoType reflect.Type // may be nil if unknown
+
+	// Err is the underlying error.
+	Err error // may be nil
+}
+
+func (e *SemanticError) Error() string {
+	var b []byte
+	b = append(b, errorPrefix...)
+	b = append(b, "cannot "+e.action...)
+
+	// Format the JSON kind and Go type.
+	switch {
+	case e.action == "marshal" && e.GoType != nil:
+		b = append(b, " from Go "+e.GoType.String()...)
+	case e.action == "unmarshal":
+		if e.JSONKind != 0 {
+			b = append(b, " JSON "+kindName(e.JSONKind)...)
+		}
+		if e.GoType != nil {
+			b = append(b, " into Go "+e.GoType.String()...)
+		}
+	}
+
+	// Format where.
+	switch {
+	case e.JSONPointer != "":
+		b = append(b, " within "...)
+		b = strconv.AppendQuote(b, truncatePointer(string(e.JSONPointer), 100))
+		fallthrough
+	case e.ByteOffset > 0:
+		b = append(b, " after offset "...)
+		b = strconv.AppendInt(b, e.ByteOffset, 10)
+	}
+
+	// Format underlying error.
+	if e.Err != nil {
+		b = append(b, ": "...)
+		b = append(b, e.Err.Error()...)
+	}
+	return string(b)
+}
+
+func (e *SemanticError) Unwrap() error {
+	return e.Err
+}
+
+// truncatePointer shortens the JSON pointer p to about n bytes by
+// replacing reference tokens in its middle with "…".
+func truncatePointer(p string, n int) string {
+	if len(p) <= n {
+		return p
+	}
+	i := strings.LastIndexByte(p[:n/2], '/')
+	j := len(p) - n/2
+	if k := strings.IndexByte(p[j:], '/'); k >= 0 {
+		j += k
+	}
+	if i <= 0 || i >= j {
+		return p
+	}
+	return p[:i] + "/…" + p[j:]
+}
+
+// kindName returns a human-readable name for a JSON kind.
+func kindName(k jsontext.Kind) string {
+	switch k {
+	case 'n', 'f', 't', '"', '0':
+		return k.String()
+	case '{', '}':
+		return "object"
+	case '[', ']':
+		return "array"
+	default:
+		return k.String()
+	}
+}
+
+// newMarshalError returns a SemanticError for an error that occurred
+// while marshaling a value of type t with enc.
+func newMarshalError(enc *jsontext.Encoder, t reflect.Type, err error) error {
+	if isSyntacticOrIOError(err) {
+		return err
+	}
+	return &SemanticError{
+		action:      "marshal",
+		ByteOffset:  enc.OutputOffset(),
+		JSONPointer: enc.StackPointer(),
+		GoType:      t,
+		Err:         err,
+	}
+}
+
+// newUnmarshalError returns a SemanticError for an error that occurred
+// while unmarshaling a JSON value of kind k into a value of type t with dec.
+func newUnmarshalError(dec *jsontext.Decoder, k jsontext.Kind, t reflect.Type, err error) error {
+	if isSyntacticOrIOError(err) {
+		return err
+	}
+	return &SemanticError{
+		action:      "unmarshal",
+		ByteOffset:  dec.InputOffset(),
+		JSONPointer: dec.StackPointer(),
+		JSONKind:    k,
+		GoType:      t,
+		Err:         err,
+	}
+}
+
+// isSyntacticOrIOError reports whether err should be reported as is,
+// rather than being wrapped in a SemanticError.
+func isSyntacticOrIOError(err error) bool {
+	switch err.(type) {
+	case *SemanticError, *jsontext.SyntacticError:
+		return true
+	}
+	return err == io.ErrUnexpectedEOF
+}
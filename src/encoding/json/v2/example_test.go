@@ -0,0 +1,150 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json_test
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/netip"
+	"time"
+
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+)
+
+// If a type implements [encoding.TextMarshaler] and/or [encoding.TextUnmarshaler],
+// then the MarshalText and UnmarshalText methods are used to encode/decode
+// the value to/from a JSON string.
+func Example_textMarshal() {
+	// Round-trip marshal and unmarshal a hostname map where the netip.Addr type
+	// implements both encoding.TextMarshaler and encoding.TextUnmarshaler.
+	want := map[netip.Addr]string{
+		netip.MustParseAddr("192.168.0.100"): "carbonite",
+		netip.MustParseAddr("192.168.0.101"): "obsidian",
+		netip.MustParseAddr("192.168.0.102"): "diamond",
+	}
+	b, err := json.Marshal(&want, json.Deterministic(true))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var got map[netip.Addr]string
+	if err := json.Unmarshal(b, &got); err != nil {
+		log.Fatal(err)
+	}
+
+	// Print the serialized JSON object.
+	(*jsontext.Value)(&b).Indent() // indent for readability
+	fmt.Println(string(b))
+
+	// Output:
+	// {
+	// 	"192.168.0.100": "carbonite",
+	// 	"192.168.0.101": "obsidian",
+	// 	"192.168.0.102": "diamond"
+	// }
+}
+
+// The "format" tag option can be used to alter the formatting of certain types.
+func Example_formatFlags() {
+	value := struct {
+		BytesBase64    []byte         `json:",format:base64"`
+		BytesHex       [8]byte        `json:",format:hex"`
+		BytesArray     []byte         `json:",format:array"`
+		FloatNonFinite float64        `json:",format:nonfinite"`
+		MapEmitNull    map[string]any `json:",format:emitnull"`
+		SliceEmitNull  []any          `json:",format:emitnull"`
+		TimeDateOnly   time.Time      `json:",format:DateOnly"`
+		TimeUnixSec    time.Time      `json:",format:unix"`
+		DurationSecs   time.Duration  `json:",format:sec"`
+		DurationNanos  time.Duration  `json:",format:nano"`
+	}{
+		BytesBase64:    []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+		BytesHex:       [8]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+		BytesArray:     []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+		FloatNonFinite: math.NaN(),
+		MapEmitNull:    nil,
+		SliceEmitNull:  nil,
+		TimeDateOnly:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeUnixSec:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		DurationSecs:   12*time.Hour + 34*time.Minute + 56*time.Second + 7*time.Millisecond + 8*time.Microsecond + 9*time.Nanosecond,
+		DurationNanos:  12*time.Hour + 34*time.Minute + 56*time.Second + 7*time.Millisecond + 8*time.Microsecond + 9*time.Nanosecond,
+	}
+
+	b, err := json.Marshal(&value)
+	if err != nil {
+		log.Fatal(err)
+	}
+	(*jsontext.Value)(&b).Indent() // indent for readability
+	fmt.Println(string(b))
+
+	// Output:
+	// {
+	// 	"BytesBase64": "ASNFZ4mrze8=",
+	// 	"BytesHex": "0123456789abcdef",
+	// 	"BytesArray": [
+	// 		1,
+	// 		35,
+	// 		69,
+	// 		103,
+	// 		137,
+	// 		171,
+	// 		205,
+	// 		239
+	// 	],
+	// 	"FloatNonFinite": "NaN",
+	// 	"MapEmitNull": null,
+	// 	"SliceEmitNull": null,
+	// 	"TimeDateOnly": "2000-01-01",
+	// 	"TimeUnixSec": 946684800,
+	// 	"DurationSecs": 45296.007008009,
+	// 	"DurationNanos": 45296007008009
+	// }
+}
+
+// Unmarshal can be configured to reject unknown members of a JSON object,
+// and reports where within the input the problem occurred.
+func Example_rejectUnknownMembers() {
+	type Config struct {
+		Name    string
+		Timeout time.Duration
+	}
+	input := []byte(`{"Name":"server","Timeout":"5s","Timout":"10s"}`)
+
+	var config Config
+	err := json.Unmarshal(input, &config, json.RejectUnknownMembers(true))
+	var serr *json.SemanticError
+	if errors.As(err, &serr) && serr.Err == json.ErrUnknownName {
+		fmt.Printf("unknown member %q at offset %d\n", serr.JSONPointer.LastToken(), serr.ByteOffset)
+	}
+
+	// Output:
+	// unknown member "Timout" at offset 40
+}
+
+// Type-specific marshal functions can override the representation
+// of types that the caller does not control.
+func ExampleMarshalToFunc() {
+	marshalers := json.MarshalToFunc(func(enc *jsontext.Encoder, addr netip.Addr) error {
+		if !addr.Is4() {
+			return json.SkipFunc // use the default representation
+		}
+		b := addr.As4()
+		return json.MarshalEncode(enc, b, json.WithMarshalers(nil))
+	})
+	addrs := []netip.Addr{
+		netip.MustParseAddr("127.0.0.1"),
+		netip.MustParseAddr("::1"),
+	}
+	b, err := json.Marshal(addrs, json.WithMarshalers(marshalers))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(b))
+
+	// Output:
+	// ["fwAAAQ==","::1"]
+}
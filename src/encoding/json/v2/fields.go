@@ -0,0 +1,285 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"cmp"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"encoding/json/jsontext"
+)
+
+var jsontextValueType = reflect.TypeFor[jsontext.Value]()
+
+// structField is a Go struct field that is represented
+// as a JSON object member.
+type structField struct {
+	index []int        // index sequence for reflect.Value.FieldByIndex
+	typ   reflect.Type // type of the field
+	name  string       // JSON object member name
+	fo    fieldOpts    // options forwarded to the field value
+
+	tagged     bool // whether the name was specified in the tag
+	omitzero   bool // whether the omitzero option was specified
+	omitempty  bool // whether the omitempty option was specified
+	caseIgnore bool // whether the case:ignore option was specified
+	caseStrict bool // whether the case:strict option was specified
+
+	isZero func(reflect.Value) bool // reports whether the field value is zero
+}
+
+// structFields are the JSON object members of a Go struct type.
+type structFields struct {
+	flattened    []structField    // in the order of the Go struct fields
+	byName       map[string]int   // index into flattened by exact name
+	byFoldedName map[string][]int // indexes into flattened by folded name
+
+	// inlined is the fallback field for unknown members, if any.
+	// Its type is either jsontext.Value or a map with string keys.
+	inlined *structField
+}
+
+// foldName returns the name used for case-insensitive matching,
+// which ignores case and any underscores or dashes.
+func foldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// lookup returns the field for the JSON object member name, or nil.
+func (fs *structFields) lookup(name string, matchCaseInsensitive bool) *structField {
+	if i, ok := fs.byName[name]; ok {
+		return &fs.flattened[i]
+	}
+	for _, i := range fs.byFoldedName[foldName(name)] {
+		f := &fs.flattened[i]
+		if f.caseIgnore || (matchCaseInsensitive && !f.caseStrict) {
+			return f
+		}
+	}
+	return nil
+}
+
+// makeStructFields computes the JSON object members of the struct type root.
+//
+// Following the rules of Go for embedded fields, fields of untagged
+// embedded structs (and fields tagged with the inline option) are promoted.
+// If multiple fields have the same name, the shallowest one is used.
+// If there are multiple at the same depth, a single tagged one is used,
+// otherwise all of them are ignored.
+func makeStructFields(root reflect.Type) (*structFields, error) {
+	type queueEntry struct {
+		typ   reflect.Type
+		index []int
+	}
+	var all []structField
+	var inlined []structField
+	visited := map[reflect.Type]bool{}
+	queue := []queueEntry{{root, nil}}
+	for len(queue) > 0 {
+		// Process one depth at a time, in breadth-first order.
+		// A type at the same depth may be visited more than once,
+		// in which case its fields conflict with each other.
+		var next []queueEntry
+		for _, qe := range queue {
+			if visited[qe.typ] {
+				continue
+			}
+			for i := range qe.typ.NumField() {
+				sf := qe.typ.Field(i)
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				index := append(slices.Clip(qe.index), i)
+				f, inline, err := parseFieldOptions(sf, tag)
+				if err != nil {
+					return nil, err
+				}
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer && ft.Name() == "" {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous && !f.tagged && ft.Kind() == reflect.Struct {
+					inline = true
+				}
+				if inline {
+					switch {
+					case ft.Kind() == reflect.Struct:
+						if !sf.IsExported() && sf.Type.Kind() == reflect.Pointer {
+							// A nil pointer to an unexported struct cannot be allocated.
+							continue
+						}
+						next = append(next, queueEntry{ft, index})
+					case sf.Type == jsontextValueType,
+						sf.Type.Kind() == reflect.Map && sf.Type.Key().Kind() == reflect.String:
+						if !sf.IsExported() {
+							return nil, errors.New("inlined Go struct field " + sf.Name + " must be exported")
+						}
+						f.index, f.typ = index, sf.Type
+						inlined = append(inlined, f)
+					default:
+						return nil, errors.New("inlined Go struct field " + sf.Name + " of type " + sf.Type.String() + " must be a Go struct, Go map of string key, or jsontext.Value")
+					}
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+				f.index, f.typ = index, sf.Type
+				f.isZero = makeIsZero(sf.Type)
+				all = append(all, f)
+			}
+		}
+		for _, qe := range queue {
+			visited[qe.typ] = true
+		}
+		queue = next
+	}
+
+	// Resolve conflicts between fields of the same name.
+	// Since fields were added in breadth-first order,
+	// the shallowest fields for each name come first.
+	byName := map[string][]structField{}
+	for _, f := range all {
+		byName[f.name] = append(byName[f.name], f)
+	}
+	var flattened []structField
+	for _, fs := range byName {
+		depth := len(fs[0].index)
+		n := 0
+		for n < len(fs) && len(fs[n].index) == depth {
+			n++
+		}
+		fs = fs[:n]
+		if len(fs) > 1 {
+			fs = slices.DeleteFunc(fs, func(f structField) bool { return !f.tagged })
+		}
+		if len(fs) == 1 {
+			flattened = append(flattened, fs[0])
+		}
+	}
+	slices.SortFunc(flattened, func(x, y structField) int {
+		return slices.Compare(x.index, y.index)
+	})
+
+	fs := &structFields{
+		flattened:    flattened,
+		byName:       make(map[string]int, len(flattened)),
+		byFoldedName: make(map[string][]int),
+	}
+	for i, f := range flattened {
+		fs.byName[f.name] = i
+		folded := foldName(f.name)
+		fs.byFoldedName[folded] = append(fs.byFoldedName[folded], i)
+	}
+	if len(inlined) > 0 {
+		slices.SortStableFunc(inlined, func(x, y structField) int {
+			return cmp.Compare(len(x.index), len(y.index))
+		})
+		if len(inlined) > 1 && len(inlined[0].index) == len(inlined[1].index) {
+			return nil, errors.New("multiple inlined Go struct fields at the same depth")
+		}
+		fs.inlined = &inlined[0]
+	}
+	return fs, nil
+}
+
+// parseFieldOptions parses the json tag of the struct field sf.
+// It reports whether the field is inlined.
+func parseFieldOptions(sf reflect.StructField, tag string) (f structField, inline bool, err error) {
+	name, opts, _ := strings.Cut(tag, ",")
+	f.name = sf.Name
+	if name != "" {
+		if !utf8.ValidString(name) {
+			return f, false, errors.New("Go struct field " + sf.Name + " has JSON object name with invalid UTF-8")
+		}
+		f.name = name
+		f.tagged = true
+	}
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		switch {
+		case opt == "omitzero":
+			f.omitzero = true
+		case opt == "omitempty":
+			f.omitempty = true
+		case opt == "string":
+			f.fo.stringify = true
+		case opt == "inline", opt == "unknown":
+			inline = true
+		case opt == "case:ignore":
+			f.caseIgnore = true
+		case opt == "case:strict":
+			f.caseStrict = true
+		case strings.HasPrefix(opt, "format:"):
+			f.fo.format = strings.TrimPrefix(opt, "format:")
+		default:
+			return f, false, errors.New("Go struct field " + sf.Name + " has invalid tag option " + opt)
+		}
+	}
+	if f.caseIgnore && f.caseStrict {
+		return f, false, errors.New("Go struct field " + sf.Name + " cannot have both case:ignore and case:strict")
+	}
+	return f, inline, nil
+}
+
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeFor[isZeroer]()
+
+// makeIsZero returns a function that reports whether a value of type t is zero,
+// according to its IsZero method if it has one.
+func makeIsZero(t reflect.Type) func(reflect.Value) bool {
+	switch {
+	case t.Kind() == reflect.Interface:
+		return reflect.Value.IsZero
+	case t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if t.Kind() == reflect.Pointer && v.IsNil() {
+				return true
+			}
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case reflect.PointerTo(t).Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if !v.CanAddr() {
+				return v.IsZero()
+			}
+			return v.Addr().Interface().(isZeroer).IsZero()
+		}
+	}
+	return reflect.Value.IsZero
+}
+
+// fieldByIndex returns the field of the struct v with the given index sequence.
+// If alloc is true, nil embedded pointers are allocated;
+// otherwise fieldByIndex reports false if it encounters one.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
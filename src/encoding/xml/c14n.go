@@ -0,0 +1,380 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// A CanonicalEncoder writes XML tokens in the canonical form defined by
+// Exclusive XML Canonicalization Version 1.0
+// (https://www.w3.org/TR/xml-exc-c14n/), as used by XML signatures.
+//
+// The names in the tokens must hold the name space prefix used in the
+// document, not the name space URL, in their Space field, as returned by
+// [Decoder.RawToken], or by [Decoder.Token] with [Decoder.PreservePrefixes] set.
+// Name space declarations are taken from the xmlns attributes of start
+// elements and rewritten so that each element declares only the prefixes
+// that it or its attributes use and that an enclosing element has not
+// already declared.
+//
+// Tokens are canonicalized as they are written, so documents of any size
+// can be processed in a single pass. The XML declaration, directives such as
+// the document type declaration, and character data outside the document
+// element are omitted. Empty elements are written as a start and end tag pair.
+type CanonicalEncoder struct {
+	// WithComments selects the variant of the algorithm that preserves
+	// comments. By default, comments are omitted.
+	WithComments bool
+
+	// InclusivePrefixes lists name space prefixes that are declared
+	// wherever they are in scope and not already declared, as in
+	// inclusive canonicalization, rather than only where they are used.
+	// The prefix "#default" denotes the default name space.
+	// It corresponds to the InclusiveNamespaces PrefixList parameter
+	// of the algorithm.
+	InclusivePrefixes []string
+
+	w         *bufio.Writer
+	context   []c14nNS   // name space declarations of the context
+	stack     []c14nElem // open elements
+	afterRoot bool       // the document element has been closed
+	err       error
+}
+
+// A c14nNS is a name space declaration.
+type c14nNS struct {
+	prefix string
+	url    string
+}
+
+// A c14nElem is an element open in a CanonicalEncoder.
+type c14nElem struct {
+	name     Name
+	declared []c14nNS // declarations in the input
+	rendered []c14nNS // declarations in the output
+}
+
+// A c14nAttr is an attribute along with its name space URL.
+type c14nAttr struct {
+	Attr
+	url string
+}
+
+// NewCanonicalEncoder returns a new canonical encoder that writes to w.
+func NewCanonicalEncoder(w io.Writer) *CanonicalEncoder {
+	return &CanonicalEncoder{w: bufio.NewWriter(w)}
+}
+
+// DeclarePrefix declares prefix as bound to url for the tokens that follow,
+// as if by an enclosing element that is not part of the output.
+// It provides the name space context when canonicalizing a subtree of
+// a document. An empty prefix declares the default name space.
+// DeclarePrefix returns an error if an element is open.
+func (c *CanonicalEncoder) DeclarePrefix(prefix, url string) error {
+	if len(c.stack) > 0 {
+		return fmt.Errorf("xml: DeclarePrefix inside element <%s>", qualifiedName(c.stack[len(c.stack)-1].name))
+	}
+	c.context = append(c.context, c14nNS{prefix, url})
+	return nil
+}
+
+// EncodeToken writes the canonical form of the given XML token to the stream.
+// It returns an error if [StartElement] and [EndElement] tokens are not
+// properly matched or if a name uses an undeclared name space prefix.
+//
+// EncodeToken does not call [CanonicalEncoder.Flush].
+func (c *CanonicalEncoder) EncodeToken(t Token) error {
+	if c.err != nil {
+		return c.err
+	}
+	switch t := t.(type) {
+	case StartElement:
+		c.err = c.writeStart(t)
+	case EndElement:
+		c.err = c.writeEnd(t)
+	case CharData:
+		// Character data outside the document element is
+		// at most white space, which is not part of the canonical form.
+		if len(c.stack) > 0 {
+			c.escape(string(t), false)
+		}
+	case Comment:
+		if c.WithComments {
+			c.beginMisc()
+			c.w.WriteString("<!--")
+			c.w.Write(t)
+			c.w.WriteString("-->")
+			c.endMisc()
+		}
+	case ProcInst:
+		if t.Target == "xml" {
+			// The XML declaration is not part of the canonical form.
+			break
+		}
+		if !isNameString(t.Target) {
+			return fmt.Errorf("xml: EncodeToken of ProcInst with invalid Target")
+		}
+		c.beginMisc()
+		c.w.WriteString("<?")
+		c.w.WriteString(t.Target)
+		if len(t.Inst) > 0 {
+			c.w.WriteByte(' ')
+			c.w.Write(t.Inst)
+		}
+		c.w.WriteString("?>")
+		c.endMisc()
+	case Directive:
+		// Directives are not part of the canonical form.
+	default:
+		return fmt.Errorf("xml: EncodeToken of invalid token type")
+	}
+	if c.err == nil {
+		_, c.err = c.w.Write(nil)
+	}
+	return c.err
+}
+
+// beginMisc and endMisc separate comments and processing instructions
+// outside the document element from the document element with line feeds.
+func (c *CanonicalEncoder) beginMisc() {
+	if len(c.stack) == 0 && c.afterRoot {
+		c.w.WriteByte('\n')
+	}
+}
+
+func (c *CanonicalEncoder) endMisc() {
+	if len(c.stack) == 0 && !c.afterRoot {
+		c.w.WriteByte('\n')
+	}
+}
+
+func (c *CanonicalEncoder) writeStart(start StartElement) error {
+	if start.Name.Local == "" {
+		return fmt.Errorf("xml: start tag with no name")
+	}
+	e := c14nElem{name: start.Name}
+	var attrs []c14nAttr
+	for _, a := range start.Attr {
+		switch {
+		case a.Name.Space == xmlnsPrefix:
+			e.declared = append(e.declared, c14nNS{a.Name.Local, a.Value})
+		case a.Name.Space == "" && a.Name.Local == xmlnsPrefix:
+			e.declared = append(e.declared, c14nNS{"", a.Value})
+		case a.Name.Local != "":
+			attrs = append(attrs, c14nAttr{Attr: a})
+		}
+	}
+	c.stack = append(c.stack, e)
+
+	// Render the declarations of the prefixes visibly used by the element
+	// and its attributes, unless an output ancestor already did.
+	var ns []c14nNS
+	render := func(prefix string) {
+		if slices.ContainsFunc(ns, func(n c14nNS) bool { return n.prefix == prefix }) {
+			return
+		}
+		url, _ := c.lookup(prefix)
+		if r, ok := c.rendered(prefix); ok && r == url || !ok && prefix == "" && url == "" {
+			return
+		}
+		ns = append(ns, c14nNS{prefix, url})
+	}
+	if _, ok := c.lookup(start.Name.Space); !ok {
+		return fmt.Errorf("xml: start tag <%s> uses undeclared name space prefix %q", qualifiedName(start.Name), start.Name.Space)
+	}
+	render(start.Name.Space)
+	for i := range attrs {
+		a := &attrs[i]
+		switch a.Name.Space {
+		case "":
+		case xmlPrefix:
+			a.url = xmlURL
+		default:
+			url, ok := c.lookup(a.Name.Space)
+			if !ok {
+				return fmt.Errorf("xml: attribute %s uses undeclared name space prefix %q", qualifiedName(a.Name), a.Name.Space)
+			}
+			a.url = url
+			render(a.Name.Space)
+		}
+	}
+	for _, prefix := range c.InclusivePrefixes {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		if _, ok := c.lookup(prefix); ok && prefix != xmlPrefix {
+			render(prefix)
+		}
+	}
+	c.stack[len(c.stack)-1].rendered = ns
+
+	slices.SortFunc(ns, func(a, b c14nNS) int {
+		return cmp.Compare(a.prefix, b.prefix)
+	})
+	slices.SortFunc(attrs, func(a, b c14nAttr) int {
+		if n := cmp.Compare(a.url, b.url); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.Name.Local, b.Name.Local)
+	})
+
+	c.w.WriteByte('<')
+	c.w.WriteString(qualifiedName(start.Name))
+	for _, n := range ns {
+		c.w.WriteString(" xmlns")
+		if n.prefix != "" {
+			c.w.WriteByte(':')
+			c.w.WriteString(n.prefix)
+		}
+		c.w.WriteString(`="`)
+		c.escape(n.url, true)
+		c.w.WriteByte('"')
+	}
+	for _, a := range attrs {
+		c.w.WriteByte(' ')
+		c.w.WriteString(qualifiedName(a.Name))
+		c.w.WriteString(`="`)
+		c.escape(a.Value, true)
+		c.w.WriteByte('"')
+	}
+	c.w.WriteByte('>')
+	return nil
+}
+
+func (c *CanonicalEncoder) writeEnd(end EndElement) error {
+	if end.Name.Local == "" {
+		return fmt.Errorf("xml: end tag with no name")
+	}
+	if len(c.stack) == 0 {
+		return fmt.Errorf("xml: end tag </%s> without start tag", qualifiedName(end.Name))
+	}
+	if top := c.stack[len(c.stack)-1].name; top != end.Name {
+		return fmt.Errorf("xml: end tag </%s> does not match start tag <%s>", qualifiedName(end.Name), qualifiedName(top))
+	}
+	c.stack = c.stack[:len(c.stack)-1]
+	if len(c.stack) == 0 {
+		c.afterRoot = true
+	}
+	c.w.WriteString("</")
+	c.w.WriteString(qualifiedName(end.Name))
+	c.w.WriteByte('>')
+	return nil
+}
+
+// lookup returns the URL that prefix is bound to in the input
+// and reports whether it is bound.
+func (c *CanonicalEncoder) lookup(prefix string) (string, bool) {
+	if prefix == xmlPrefix {
+		return xmlURL, true
+	}
+	for i := len(c.stack) - 1; i >= 0; i-- {
+		if url, ok := findNS(c.stack[i].declared, prefix); ok {
+			return url, true
+		}
+	}
+	if url, ok := findNS(c.context, prefix); ok {
+		return url, true
+	}
+	// The default name space is empty unless declared.
+	return "", prefix == ""
+}
+
+// rendered returns the URL that prefix is bound to in the output
+// by the ancestors of the current element and reports whether it is bound.
+func (c *CanonicalEncoder) rendered(prefix string) (string, bool) {
+	for i := len(c.stack) - 2; i >= 0; i-- {
+		if url, ok := findNS(c.stack[i].rendered, prefix); ok {
+			return url, true
+		}
+	}
+	return "", false
+}
+
+// findNS returns the URL of the last declaration of prefix in ns.
+func findNS(ns []c14nNS, prefix string) (string, bool) {
+	for i := len(ns) - 1; i >= 0; i-- {
+		if ns[i].prefix == prefix {
+			return ns[i].url, true
+		}
+	}
+	return "", false
+}
+
+// qualifiedName returns the name as written in the document.
+func qualifiedName(name Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// escape writes s with the characters escaped that the canonical form
+// requires to be escaped in text or, if attr is set, in attribute values.
+func (c *CanonicalEncoder) escape(s string, attr bool) {
+	last := 0
+	for i := 0; i < len(s); i++ {
+		var esc string
+		switch s[i] {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			if attr {
+				continue
+			}
+			esc = "&gt;"
+		case '"':
+			if !attr {
+				continue
+			}
+			esc = "&quot;"
+		case '\t':
+			if !attr {
+				continue
+			}
+			esc = "&#x9;"
+		case '\n':
+			if !attr {
+				continue
+			}
+			esc = "&#xA;"
+		case '\r':
+			esc = "&#xD;"
+		default:
+			continue
+		}
+		c.w.WriteString(s[last:i])
+		c.w.WriteString(esc)
+		last = i + 1
+	}
+	c.w.WriteString(s[last:])
+}
+
+// Flush flushes any buffered XML to the underlying writer.
+func (c *CanonicalEncoder) Flush() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.w.Flush()
+}
+
+// Close flushes any buffered XML to the underlying writer and returns
+// an error if the written XML is incomplete (e.g. by containing unclosed
+// elements).
+func (c *CanonicalEncoder) Close() error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	if len(c.stack) > 0 {
+		return fmt.Errorf("xml: unclosed tag <%s>", qualifiedName(c.stack[len(c.stack)-1].name))
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+var canonicalTests = []struct {
+	name         string
+	in           string
+	out          string
+	withComments bool
+	inclusive    []string
+	context      []c14nNS
+}{{
+	name: "Prolog",
+	in:   "<?xml version=\"1.0\"?>\n<!DOCTYPE doc>\n<!-- c1 -->\n<?pi data?>\n<doc a=\"1\"  b='2'/>\n<!-- c2 -->\n",
+	out:  "<?pi data?>\n<doc a=\"1\" b=\"2\"></doc>",
+}, {
+	name:         "PrologWithComments",
+	in:           "<?xml version=\"1.0\"?>\n<!DOCTYPE doc>\n<!-- c1 -->\n<?pi data?>\n<doc a=\"1\"  b='2'/>\n<!-- c2 -->\n",
+	out:          "<!-- c1 -->\n<?pi data?>\n<doc a=\"1\" b=\"2\"></doc>\n<!-- c2 -->",
+	withComments: true,
+}, {
+	name: "CommentsInside",
+	in:   "<a> <!-- x --> <b/></a>",
+	out:  "<a>  <b></b></a>",
+}, {
+	name: "Escaping",
+	in:   "<a t=\"&#9;&#10;&#13;&quot;&lt;&gt;&amp;'\">&lt;&gt;&amp;&#13;\"'</a>",
+	out:  "<a t=\"&#x9;&#xA;&#xD;&quot;&lt;>&amp;'\">&lt;&gt;&amp;&#xD;\"'</a>",
+}, {
+	name: "CDATA",
+	in:   "<a><![CDATA[x<y]]></a>",
+	out:  "<a>x&lt;y</a>",
+}, {
+	name: "Sorting",
+	in:   `<doc xmlns:b="http://b" xmlns:a="http://z" b:attr="1" a:attr="2" attr="3" xmlns="http://d"/>`,
+	out:  `<doc xmlns="http://d" xmlns:a="http://z" xmlns:b="http://b" attr="3" b:attr="1" a:attr="2"></doc>`,
+}, {
+	name: "UnusedPrefix",
+	in:   `<a xmlns:x="u"><b><x:c/></b></a>`,
+	out:  `<a><b><x:c xmlns:x="u"></x:c></b></a>`,
+}, {
+	name: "RedundantDeclaration",
+	in:   `<x:a xmlns:x="u"><x:b xmlns:x="u"/><x:c xmlns:x="v"/></x:a>`,
+	out:  `<x:a xmlns:x="u"><x:b></x:b><x:c xmlns:x="v"></x:c></x:a>`,
+}, {
+	name: "SiblingsDeclareAgain",
+	in:   `<a xmlns:x="u"><x:b/><x:c/></a>`,
+	out:  `<a><x:b xmlns:x="u"></x:b><x:c xmlns:x="u"></x:c></a>`,
+}, {
+	name: "DefaultReset",
+	in:   `<a xmlns="u"><b xmlns=""><c/></b></a>`,
+	out:  `<a xmlns="u"><b xmlns=""><c></c></b></a>`,
+}, {
+	name: "DefaultEmpty",
+	in:   `<a><b xmlns=""/></a>`,
+	out:  `<a><b></b></a>`,
+}, {
+	name: "DefaultUnderPrefix",
+	in:   `<x:a xmlns:x="u" xmlns="d"><x:b><c/></x:b></x:a>`,
+	out:  `<x:a xmlns:x="u"><x:b><c xmlns="d"></c></x:b></x:a>`,
+}, {
+	name: "XMLAttributes",
+	in:   `<a xml:lang="en" xml:space="preserve"/>`,
+	out:  `<a xml:lang="en" xml:space="preserve"></a>`,
+}, {
+	// Example from section 2.2 of the Exclusive XML Canonicalization
+	// recommendation, applied to the whole document.
+	name: "Spec",
+	in: `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org">` +
+		`<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">` +
+		`<n3:stuff xmlns:n3="ftp://example.org"/>` +
+		`</n1:elem2></n0:local>`,
+	out: `<n0:local xmlns:n0="foo:bar">` +
+		`<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">` +
+		`<n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>` +
+		`</n1:elem2></n0:local>`,
+}, {
+	// The same example, applied to the subtree of elem2.
+	name: "SpecSubtree",
+	in: `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">` +
+		`<n3:stuff xmlns:n3="ftp://example.org"/>` +
+		`</n1:elem2>`,
+	out: `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">` +
+		`<n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>` +
+		`</n1:elem2>`,
+	context: []c14nNS{{"n0", "foo:bar"}, {"n3", "ftp://example.org"}},
+}, {
+	name: "ContextPrefix",
+	in:   `<x:a/>`,
+	out:  `<x:a xmlns:x="u"></x:a>`,
+	context: []c14nNS{
+		{"x", "u"},
+		{"y", "v"},
+	},
+}, {
+	name:      "InclusivePrefixes",
+	in:        `<a xmlns:x="u" xmlns:y="v" xmlns="d"><b/></a>`,
+	out:       `<a xmlns="d" xmlns:x="u"><b></b></a>`,
+	inclusive: []string{"x", "z"},
+}, {
+	name:      "InclusiveDefault",
+	in:        `<x:a xmlns:x="u" xmlns="d"><x:b/></x:a>`,
+	out:       `<x:a xmlns="d" xmlns:x="u"><x:b></x:b></x:a>`,
+	inclusive: []string{"#default"},
+}}
+
+func TestCanonicalEncoder(t *testing.T) {
+	for _, tt := range canonicalTests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tt.in))
+			d.PreservePrefixes = true
+			var b strings.Builder
+			c := NewCanonicalEncoder(&b)
+			c.WithComments = tt.withComments
+			c.InclusivePrefixes = tt.inclusive
+			for _, ns := range tt.context {
+				if err := c.DeclarePrefix(ns.prefix, ns.url); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for {
+				tok, err := d.Token()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := c.EncodeToken(tok); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.out {
+				t.Errorf("canonical form:\nhave %s\nwant %s", got, tt.out)
+			}
+		})
+	}
+}
+
+func TestCanonicalEncoderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		toks []Token
+		err  string
+	}{{
+		name: "UndeclaredElementPrefix",
+		toks: []Token{StartElement{Name: Name{"x", "a"}}},
+		err:  `xml: start tag <x:a> uses undeclared name space prefix "x"`,
+	}, {
+		name: "UndeclaredAttrPrefix",
+		toks: []Token{StartElement{Name: Name{"", "a"}, Attr: []Attr{{Name{"y", "b"}, "1"}}}},
+		err:  `xml: attribute y:b uses undeclared name space prefix "y"`,
+	}, {
+		name: "Mismatch",
+		toks: []Token{StartElement{Name: Name{"", "a"}}, EndElement{Name{"", "b"}}},
+		err:  `xml: end tag </b> does not match start tag <a>`,
+	}, {
+		name: "NoStart",
+		toks: []Token{EndElement{Name{"", "a"}}},
+		err:  `xml: end tag </a> without start tag`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCanonicalEncoder(io.Discard)
+			var err error
+			for _, tok := range tt.toks {
+				if err = c.EncodeToken(tok); err != nil {
+					break
+				}
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("EncodeToken error = %v, want %s", err, tt.err)
+			}
+			if err := c.EncodeToken(CharData("x")); err == nil || err.Error() != tt.err {
+				t.Errorf("EncodeToken after error = %v, want %s", err, tt.err)
+			}
+		})
+	}
+
+	c := NewCanonicalEncoder(io.Discard)
+	if err := c.EncodeToken(StartElement{Name: Name{"", "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeclarePrefix("x", "u"); err == nil {
+		t.Errorf("DeclarePrefix inside element succeeded")
+	}
+	if err := c.Close(); err == nil || err.Error() != "xml: unclosed tag <a>" {
+		t.Errorf("Close error = %v, want unclosed tag", err)
+	}
+}
@@ -7,7 +7,9 @@ package xml_test
 import (
 	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 )
 
 func ExampleMarshalIndent() {
@@ -149,3 +151,37 @@ func ExampleUnmarshal() {
 	// Groups: [Friends Squash]
 	// Address: {Hanga Roa Easter Island}
 }
+
+// This example canonicalizes a document with exclusive XML canonicalization,
+// as is done before computing an XML signature.
+func ExampleCanonicalEncoder() {
+	const doc = `<?xml version="1.0"?>
+<p:doc xmlns:p="urn:p" xmlns:unused="urn:u" b='2' a="1">
+  <p:empty/>
+</p:doc>`
+
+	d := xml.NewDecoder(strings.NewReader(doc))
+	d.PreservePrefixes = true
+	c := xml.NewCanonicalEncoder(os.Stdout)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+		if err := c.EncodeToken(tok); err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+	}
+	if err := c.Close(); err != nil {
+		fmt.Printf("error: %v\n", err)
+	}
+	// Output:
+	// <p:doc xmlns:p="urn:p" a="1" b="2">
+	//   <p:empty></p:empty>
+	// </p:doc>
+}
@@ -160,6 +160,44 @@ func (enc *Encoder) Indent(prefix, indent string) {
 	enc.p.indent = indent
 }
 
+// DeclarePrefix declares prefix as the name space prefix for url.
+// The declaration is written as an xmlns:prefix attribute of the next
+// start element, or as an xmlns attribute if prefix is empty,
+// and remains in scope until the matching end element.
+//
+// Within that scope, elements and attributes in the name space url
+// are written using the declared prefix instead of a name space
+// declaration of their own. An empty prefix declares the default
+// name space, which applies only to element names.
+// Once any prefix has been declared, the Encoder also tracks the default
+// name space of the elements it writes, so that nested elements in the
+// same name space omit the xmlns attribute and elements in no name space
+// are written with xmlns="" when needed.
+//
+// DeclarePrefix returns an error if prefix is not a valid name space prefix
+// or cannot be bound to url.
+func (enc *Encoder) DeclarePrefix(prefix, url string) error {
+	p := &enc.p
+	switch {
+	case prefix != "" && (!isNameString(prefix) || strings.Contains(prefix, ":")):
+		return fmt.Errorf("xml: invalid name space prefix %q", prefix)
+	case len(prefix) >= 3 && strings.EqualFold(prefix[:3], "xml"):
+		return fmt.Errorf("xml: name space prefix %q is reserved", prefix)
+	case prefix != "" && url == "":
+		return fmt.Errorf("xml: name space prefix %q cannot be declared empty", prefix)
+	case url == xmlURL:
+		return fmt.Errorf("xml: name space %s cannot be declared", url)
+	}
+	for _, b := range p.nsPending {
+		if b.prefix == prefix {
+			return fmt.Errorf("xml: name space prefix %q already declared", prefix)
+		}
+	}
+	p.nsPending = append(p.nsPending, nsBinding{prefix: prefix, url: url})
+	p.nsAware = true
+	return nil
+}
+
 // Encode writes the XML encoding of v to the stream.
 //
 // See the documentation for [Marshal] for details about the conversion
@@ -330,12 +368,61 @@ type printer struct {
 	tags       []Name
 	closed     bool
 	err        error
+
+	// Name space prefixes declared with Encoder.DeclarePrefix.
+	nsAware    bool        // DeclarePrefix has been called
+	nsPending  []nsBinding // declarations for the next start element
+	nsBindings []nsBinding // declarations in scope, innermost last
+}
+
+// An nsBinding binds a name space prefix to a URL
+// for the element at the given depth of the tag stack.
+type nsBinding struct {
+	prefix string
+	url    string
+	depth  int
+}
+
+// nsPrefix returns the prefix bound to url in the current scope
+// and reports whether there is one.
+// The default name space is only considered for element names.
+func (p *printer) nsPrefix(url string, isElementName bool) (string, bool) {
+	for i := len(p.nsBindings) - 1; i >= 0; i-- {
+		b := p.nsBindings[i]
+		if b.url != url || (b.prefix == "" && !isElementName) {
+			continue
+		}
+		if p.nsLookup(b.prefix) == i {
+			// Not shadowed by a later declaration of the same prefix.
+			return b.prefix, true
+		}
+	}
+	return "", false
+}
+
+// nsLookup returns the index in p.nsBindings of the innermost
+// declaration of prefix, or -1 if there is none.
+func (p *printer) nsLookup(prefix string) int {
+	for i := len(p.nsBindings) - 1; i >= 0; i-- {
+		if p.nsBindings[i].prefix == prefix {
+			return i
+		}
+	}
+	return -1
+}
+
+// prefixTaken reports whether prefix is already in use in the current scope.
+func (p *printer) prefixTaken(prefix string) bool {
+	return p.attrNS[prefix] != "" || p.nsLookup(prefix) >= 0
 }
 
 // createAttrPrefix finds the name space prefix attribute to use for the given name space,
 // defining a new prefix if necessary. It returns the prefix.
 func (p *printer) createAttrPrefix(url string) string {
-	if prefix := p.attrPrefix[url]; prefix != "" {
+	if prefix, ok := p.nsPrefix(url, false); ok {
+		return prefix
+	}
+	if prefix := p.attrPrefix[url]; prefix != "" && p.nsLookup(prefix) < 0 {
 		return prefix
 	}
 
@@ -369,10 +456,10 @@ func (p *printer) createAttrPrefix(url string) string {
 	if len(prefix) >= 3 && strings.EqualFold(prefix[:3], "xml") {
 		prefix = "_" + prefix
 	}
-	if p.attrNS[prefix] != "" {
+	if p.prefixTaken(prefix) {
 		// Name is taken. Find a better one.
 		for p.seq++; ; p.seq++ {
-			if id := prefix + "_" + strconv.Itoa(p.seq); p.attrNS[id] == "" {
+			if id := prefix + "_" + strconv.Itoa(p.seq); !p.prefixTaken(id) {
 				prefix = id
 				break
 			}
@@ -723,15 +810,48 @@ func (p *printer) writeStart(start *StartElement) error {
 
 	p.tags = append(p.tags, start.Name)
 	p.markPrefix()
+	depth := len(p.tags)
+	pending := p.nsPending
+	p.nsPending = nil
+	for _, b := range pending {
+		b.depth = depth
+		p.nsBindings = append(p.nsBindings, b)
+	}
 
 	p.writeIndent(1)
 	p.WriteByte('<')
+	prefix, bound := p.nsPrefix(start.Name.Space, true)
+	if prefix != "" {
+		p.WriteString(prefix)
+		p.WriteByte(':')
+	}
 	p.WriteString(start.Name.Local)
 
-	if start.Name.Space != "" {
+	for _, b := range pending {
+		p.WriteString(" xmlns")
+		if b.prefix != "" {
+			p.WriteByte(':')
+			p.WriteString(b.prefix)
+		}
+		p.WriteString(`="`)
+		p.EscapeString(b.url)
+		p.WriteByte('"')
+	}
+
+	// Unless the element's name space is bound to a prefix,
+	// it becomes the default name space.
+	wroteDefault := false
+	if !bound && (start.Name.Space != "" || p.nsLookup("") >= 0) {
+		if i := p.nsLookup(""); i >= 0 && p.nsBindings[i].depth == depth {
+			return fmt.Errorf("xml: start tag <%s> in name space %q conflicts with declared default name space %q", start.Name.Local, start.Name.Space, p.nsBindings[i].url)
+		}
 		p.WriteString(` xmlns="`)
 		p.EscapeString(start.Name.Space)
 		p.WriteByte('"')
+		if p.nsAware {
+			p.nsBindings = append(p.nsBindings, nsBinding{url: start.Name.Space, depth: depth})
+			wroteDefault = true
+		}
 	}
 
 	// Attributes
@@ -740,6 +860,9 @@ func (p *printer) writeStart(start *StartElement) error {
 		if name.Local == "" {
 			continue
 		}
+		if wroteDefault && name.Space == "" && name.Local == xmlnsPrefix {
+			continue
+		}
 		p.WriteByte(' ')
 		if name.Space != "" {
 			p.WriteString(p.createAttrPrefix(name.Space))
@@ -767,11 +890,19 @@ func (p *printer) writeEnd(name Name) error {
 		}
 		return fmt.Errorf("xml: end tag </%s> in namespace %s does not match start tag <%s> in namespace %s", name.Local, name.Space, top.Local, top.Space)
 	}
+	prefix, _ := p.nsPrefix(name.Space, true)
 	p.tags = p.tags[:len(p.tags)-1]
+	for len(p.nsBindings) > 0 && p.nsBindings[len(p.nsBindings)-1].depth > len(p.tags) {
+		p.nsBindings = p.nsBindings[:len(p.nsBindings)-1]
+	}
 
 	p.writeIndent(-1)
 	p.WriteByte('<')
 	p.WriteByte('/')
+	if prefix != "" {
+		p.WriteString(prefix)
+		p.WriteByte(':')
+	}
 	p.WriteString(name.Local)
 	p.WriteByte('>')
 	p.popPrefix()
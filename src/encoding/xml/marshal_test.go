@@ -2589,3 +2589,188 @@ func TestClose(t *testing.T) {
 		})
 	}
 }
+
+// A declare in a token list is a call to Encoder.DeclarePrefix.
+type declare struct {
+	prefix, url string
+}
+
+var declarePrefixTests = []struct {
+	desc string
+	toks []Token
+	want string
+	err  string
+}{{
+	desc: "prefixed names",
+	toks: []Token{
+		declare{"s", "urn:s"},
+		StartElement{Name{"urn:s", "a"}, []Attr{{Name{"urn:s", "x"}, "1"}}},
+		StartElement{Name{"urn:s", "b"}, nil},
+		EndElement{Name{"urn:s", "b"}},
+		EndElement{Name{"urn:s", "a"}},
+	},
+	want: `<s:a xmlns:s="urn:s" s:x="1"><s:b></s:b></s:a>`,
+}, {
+	desc: "default name space",
+	toks: []Token{
+		declare{"", "urn:d"},
+		StartElement{Name{"urn:d", "a"}, nil},
+		StartElement{Name{"", "b"}, nil},
+		EndElement{Name{"", "b"}},
+		StartElement{Name{"urn:d", "c"}, nil},
+		EndElement{Name{"urn:d", "c"}},
+		EndElement{Name{"urn:d", "a"}},
+	},
+	want: `<a xmlns="urn:d"><b xmlns=""></b><c></c></a>`,
+}, {
+	desc: "implicit default name space",
+	toks: []Token{
+		declare{"s", "urn:s"},
+		StartElement{Name{"urn:d", "a"}, nil},
+		StartElement{Name{"urn:d", "b"}, nil},
+		StartElement{Name{"", "c"}, nil},
+		EndElement{Name{"", "c"}},
+		EndElement{Name{"urn:d", "b"}},
+		StartElement{Name{"urn:s", "e"}, nil},
+		EndElement{Name{"urn:s", "e"}},
+		EndElement{Name{"urn:d", "a"}},
+	},
+	want: `<a xmlns:s="urn:s" xmlns="urn:d"><b><c xmlns=""></c></b><s:e></s:e></a>`,
+}, {
+	desc: "scope ends with element",
+	toks: []Token{
+		declare{"s", "urn:s"},
+		StartElement{Name{"", "a"}, nil},
+		StartElement{Name{"urn:s", "b"}, nil},
+		EndElement{Name{"urn:s", "b"}},
+		EndElement{Name{"", "a"}},
+		StartElement{Name{"urn:s", "c"}, nil},
+		EndElement{Name{"urn:s", "c"}},
+	},
+	want: `<a xmlns:s="urn:s"><s:b></s:b></a><c xmlns="urn:s"></c>`,
+}, {
+	desc: "shadowed prefix",
+	toks: []Token{
+		declare{"s", "urn:s"},
+		StartElement{Name{"urn:s", "a"}, nil},
+		declare{"s", "urn:t"},
+		StartElement{Name{"urn:s", "b"}, []Attr{{Name{"urn:t", "x"}, "1"}}},
+		EndElement{Name{"urn:s", "b"}},
+		EndElement{Name{"urn:s", "a"}},
+	},
+	want: `<s:a xmlns:s="urn:s"><b xmlns:s="urn:t" xmlns="urn:s" s:x="1"></b></s:a>`,
+}, {
+	desc: "generated prefix avoids declared prefix",
+	toks: []Token{
+		declare{"space", "urn:other"},
+		StartElement{Name{"", "a"}, []Attr{{Name{"http://example.com/space", "x"}, "1"}}},
+		EndElement{Name{"", "a"}},
+	},
+	want: `<a xmlns:space="urn:other" xmlns:space_1="http://example.com/space" space_1:x="1"></a>`,
+}, {
+	desc: "conflicting default name space",
+	toks: []Token{
+		declare{"", "urn:d"},
+		StartElement{Name{"urn:e", "a"}, nil},
+	},
+	err: `xml: start tag <a> in name space "urn:e" conflicts with declared default name space "urn:d"`,
+}, {
+	desc: "reserved prefix",
+	toks: []Token{
+		declare{"xmlns", "urn:s"},
+	},
+	err: `xml: name space prefix "xmlns" is reserved`,
+}, {
+	desc: "invalid prefix",
+	toks: []Token{
+		declare{"a:b", "urn:s"},
+	},
+	err: `xml: invalid name space prefix "a:b"`,
+}, {
+	desc: "empty url",
+	toks: []Token{
+		declare{"s", ""},
+	},
+	err: `xml: name space prefix "s" cannot be declared empty`,
+}, {
+	desc: "xml name space",
+	toks: []Token{
+		declare{"x", xmlURL},
+	},
+	err: `xml: name space http://www.w3.org/XML/1998/namespace cannot be declared`,
+}, {
+	desc: "duplicate declaration",
+	toks: []Token{
+		declare{"s", "urn:s"},
+		declare{"s", "urn:t"},
+	},
+	err: `xml: name space prefix "s" already declared`,
+}}
+
+func TestEncoderDeclarePrefix(t *testing.T) {
+	for _, tt := range declarePrefixTests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var out strings.Builder
+			enc := NewEncoder(&out)
+			var err error
+			for _, tok := range tt.toks {
+				if d, ok := tok.(declare); ok {
+					err = enc.DeclarePrefix(d.prefix, d.url)
+				} else {
+					err = enc.EncodeToken(tok)
+				}
+				if err != nil {
+					break
+				}
+			}
+			if err != nil {
+				if tt.err == "" {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if err.Error() != tt.err {
+					t.Fatalf("error mismatch; got %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if tt.err != "" {
+				t.Fatalf("expected error %v; got none", tt.err)
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("\ngot  %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeDeclaredPrefix(t *testing.T) {
+	type item struct {
+		XMLName Name   `xml:"urn:s root"`
+		ID      int    `xml:"urn:s id,attr"`
+		Lang    string `xml:"urn:t lang,attr"`
+		Item    string `xml:"urn:s item"`
+		Note    string `xml:"note"`
+	}
+	var out strings.Builder
+	enc := NewEncoder(&out)
+	if err := enc.DeclarePrefix("s", "urn:s"); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(item{ID: 1, Lang: "en", Item: "x", Note: "y"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `<s:root xmlns:s="urn:s" s:id="1" xmlns:_="urn:t" _:lang="en"><s:item>x</s:item><note>y</note></s:root>`
+	if got := out.String(); got != want {
+		t.Errorf("\ngot  %v\nwant %v", got, want)
+	}
+
+	var v item
+	if err := Unmarshal([]byte(want), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.ID != 1 || v.Lang != "en" || v.Item != "x" || v.Note != "y" {
+		t.Errorf("Unmarshal = %+v", v)
+	}
+}
@@ -153,6 +153,18 @@ func (d *Decoder) DecodeElement(v any, start *StartElement) error {
 	if val.IsNil() {
 		return errors.New("nil pointer passed to Unmarshal")
 	}
+	if d.PreservePrefixes && start != nil {
+		// The start element was returned by Token with its prefixes
+		// preserved; its declarations are still in scope.
+		s := start.Copy()
+		d.translate(&s.Name, true)
+		for i := range s.Attr {
+			d.translate(&s.Attr[i].Name, false)
+		}
+		start = &s
+	}
+	d.decodeDepth++
+	defer func() { d.decodeDepth-- }()
 	return d.unmarshal(val.Elem(), start, 0)
 }
 
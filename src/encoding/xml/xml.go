@@ -197,6 +197,17 @@ type Decoder struct {
 	// the attribute xmlns="DefaultSpace".
 	DefaultSpace string
 
+	// PreservePrefixes, if true, causes Token to leave the name space
+	// prefix used in the input, rather than the name space URL,
+	// in the Space field of element and attribute names,
+	// as RawToken does. Token still tracks name space declarations
+	// and verifies that start and end elements match.
+	// This is useful for processing that must reproduce the prefixes
+	// of the input, such as canonicalization with [CanonicalEncoder].
+	// Decode and DecodeElement always translate prefixes to URLs,
+	// including those of the start element passed to DecodeElement.
+	PreservePrefixes bool
+
 	r              io.ByteReader
 	t              TokenReader
 	buf            bytes.Buffer
@@ -213,6 +224,7 @@ type Decoder struct {
 	linestart      int64
 	offset         int64
 	unmarshalDepth int
+	decodeDepth    int
 }
 
 // NewDecoder creates a new XML parser reading from r.
@@ -271,6 +283,7 @@ func NewTokenDecoder(t TokenReader) *Decoder {
 // set to the URL identifying its name space when known.
 // If Token encounters an unrecognized name space prefix,
 // it uses the prefix as the Space rather than report an error.
+// If [Decoder.PreservePrefixes] is set, Space holds the prefix instead.
 func (d *Decoder) Token() (Token, error) {
 	var t Token
 	var err error
@@ -318,9 +331,11 @@ func (d *Decoder) Token() (Token, error) {
 		}
 
 		d.pushElement(t1.Name)
-		d.translate(&t1.Name, true)
-		for i := range t1.Attr {
-			d.translate(&t1.Attr[i].Name, false)
+		if d.translatePrefixes() {
+			d.translate(&t1.Name, true)
+			for i := range t1.Attr {
+				d.translate(&t1.Attr[i].Name, false)
+			}
 		}
 		t = t1
 
@@ -339,6 +354,12 @@ const (
 	xmlPrefix   = "xml"
 )
 
+// translatePrefixes reports whether Token should translate
+// name space prefixes to URLs.
+func (d *Decoder) translatePrefixes() bool {
+	return !d.PreservePrefixes || d.decodeDepth > 0
+}
+
 // Apply name space translation to name n.
 // The default name space (for Space=="")
 // applies only to element names, not to attribute names.
@@ -501,7 +522,9 @@ func (d *Decoder) popElement(t *EndElement) bool {
 		return false
 	}
 
-	d.translate(&t.Name, true)
+	if d.translatePrefixes() {
+		d.translate(&t.Name, true)
+	}
 
 	// Pop stack until a Start or EOF is on the top, undoing the
 	// translations that were associated with the element we just closed.
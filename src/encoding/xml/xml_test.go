@@ -1510,3 +1510,79 @@ func TestHTMLAutoClose(t *testing.T) {
 		}
 	}
 }
+
+func TestPreservePrefixes(t *testing.T) {
+	const input = `<x:a xmlns:x="u" xmlns="d" x:k="1"><b xml:lang="en"/></x:a>`
+	want := []Token{
+		StartElement{Name{"x", "a"}, []Attr{
+			{Name{"xmlns", "x"}, "u"},
+			{Name{"", "xmlns"}, "d"},
+			{Name{"x", "k"}, "1"},
+		}},
+		StartElement{Name{"", "b"}, []Attr{{Name{"xml", "lang"}, "en"}}},
+		EndElement{Name{"", "b"}},
+		EndElement{Name{"x", "a"}},
+	}
+	d := NewDecoder(strings.NewReader(input))
+	d.PreservePrefixes = true
+	var got []Token
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, CopyToken(tok))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens:\nhave %#v\nwant %#v", got, want)
+	}
+
+	d = NewDecoder(strings.NewReader(`<x:a xmlns:x="u" xmlns:y="u"></y:a>`))
+	d.PreservePrefixes = true
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Token(); err == nil {
+		t.Errorf("mismatched prefixes: expected error")
+	}
+
+	// Decode still matches names by name space URL.
+	var v struct {
+		XMLName Name   `xml:"u a"`
+		K       string `xml:"u k,attr"`
+		B       string `xml:"d b"`
+	}
+	d = NewDecoder(strings.NewReader(`<x:a xmlns:x="u" xmlns="d" x:k="1"><b>text</b></x:a>`))
+	d.PreservePrefixes = true
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.XMLName != (Name{"u", "a"}) || v.K != "1" || v.B != "text" {
+		t.Errorf("Decode = %+v", v)
+	}
+
+	v = struct {
+		XMLName Name   `xml:"u a"`
+		K       string `xml:"u k,attr"`
+		B       string `xml:"d b"`
+	}{}
+	d = NewDecoder(strings.NewReader(`<x:a xmlns:x="u" xmlns="d" x:k="1"><b>text</b></x:a>`))
+	d.PreservePrefixes = true
+	tok, err := d.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := tok.(StartElement)
+	if err := d.DecodeElement(&v, &start); err != nil {
+		t.Fatal(err)
+	}
+	if v.XMLName != (Name{"u", "a"}) || v.K != "1" || v.B != "text" {
+		t.Errorf("DecodeElement = %+v", v)
+	}
+	if start.Name != (Name{"x", "a"}) {
+		t.Errorf("DecodeElement modified start element: %v", start.Name)
+	}
+}
@@ -3,11 +3,11 @@ module std
 go 1.24
 
 require (
-	golang.org/x/crypto v0.25.1-0.20240722173533-bb80217080b0
-	golang.org/x/net v0.27.1-0.20240722181819-765c7e89b3bd
+	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.33.0
 )
 
 require (
-	golang.org/x/sys v0.23.0 // indirect
-	golang.org/x/text v0.16.1-0.20240716160804-ae0cf96bbcd9 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
 )
@@ -57,6 +57,7 @@ var depsRules = `
 	  internal/nettrace,
 	  internal/platform,
 	  internal/profilerecord,
+	  internal/synctest,
 	  internal/syslist,
 	  internal/trace/traceviewer/format,
 	  log/internal,
@@ -93,7 +94,7 @@ var depsRules = `
 	< internal/race
 	< internal/msan
 	< internal/asan
-	< internal/weak
+	< weak
 	< sync
 	< internal/bisect
 	< internal/godebug
@@ -255,6 +256,14 @@ var depsRules = `
 	< encoding/ascii85, encoding/csv, encoding/gob, encoding/hex,
 	  encoding/json, encoding/pem, encoding/xml, mime;
 
+	bytes, encoding, reflect, strings, unicode/utf16
+	< encoding/json/internal/jsonopts
+	< encoding/json/jsontext;
+
+	encoding/base32, encoding/base64, encoding/hex, encoding/json/jsontext,
+	sync, time
+	< encoding/json/v2;
+
 	# hashes
 	io
 	< hash
@@ -266,7 +275,7 @@ var depsRules = `
 
 	# compression
 	FMT, encoding/binary, hash/adler32, hash/crc32, sort
-	< compress/bzip2, compress/flate, compress/lzw, internal/zstd
+	< compress/bzip2, compress/flate, compress/lzw, compress/zstd
 	< archive/zip, compress/gzip, compress/zlib;
 
 	# templates
@@ -291,7 +300,7 @@ var depsRules = `
 	< index/suffixarray;
 
 	# executable parsing
-	FMT, encoding/binary, compress/zlib, internal/saferio, internal/zstd, sort
+	FMT, encoding/binary, compress/zlib, internal/saferio, compress/zstd, sort
 	< runtime/debug
 	< debug/dwarf
 	< debug/elf, debug/gosym, debug/macho, debug/pe, debug/plan9obj, internal/xcoff
@@ -353,7 +362,8 @@ var depsRules = `
 	< image, image/color/palette
 	< image/internal/imageutil
 	< image/draw
-	< image/gif, image/jpeg, image/png;
+	< image/internal/riff, image/internal/vp8, image/internal/vp8l
+	< image/bmp, image/gif, image/jpeg, image/png, image/webp;
 
 	# cgo, delayed as long as possible.
 	# If you add a dependency on CGO, you must add the package
@@ -474,20 +484,23 @@ var depsRules = `
 	crypto/boring, crypto/internal/edwards25519/field
 	< crypto/ecdh;
 
-	# Unfortunately, stuck with reflect via encoding/binary.
-	encoding/binary, crypto/boring < golang.org/x/crypto/sha3;
+	crypto/subtle < crypto/sha3;
+
+	crypto/hmac < crypto/hkdf, crypto/pbkdf2;
 
 	crypto/aes,
 	crypto/des,
 	crypto/ecdh,
+	crypto/hkdf,
 	crypto/hmac,
 	crypto/internal/edwards25519,
 	crypto/md5,
+	crypto/pbkdf2,
 	crypto/rc4,
 	crypto/sha1,
 	crypto/sha256,
-	crypto/sha512,
-	golang.org/x/crypto/sha3
+	crypto/sha3,
+	crypto/sha512
 	< CRYPTO;
 
 	CGO, fmt, net !< CRYPTO;
@@ -496,7 +509,8 @@ var depsRules = `
 	CRYPTO, FMT, math/big
 	< crypto/internal/boring/bbig
 	< crypto/rand
-	< crypto/internal/mlkem768
+	< crypto/internal/mlkem
+	< crypto/mlkem
 	< crypto/ed25519
 	< encoding/asn1
 	< golang.org/x/crypto/cryptobyte/asn1
@@ -515,7 +529,6 @@ var depsRules = `
 	< golang.org/x/crypto/chacha20
 	< golang.org/x/crypto/internal/poly1305
 	< golang.org/x/crypto/chacha20poly1305
-	< golang.org/x/crypto/hkdf
 	< crypto/internal/hpke
 	< crypto/x509/internal/macos
 	< crypto/x509/pkix;
@@ -537,6 +550,9 @@ var depsRules = `
 	crypto/tls
 	< net/smtp;
 
+	crypto/tls
+	< net/quic;
+
 	crypto/rand
 	< hash/maphash; # for purego implementation
 
@@ -558,6 +574,7 @@ var depsRules = `
 	< net/http/httptrace;
 
 	compress/gzip,
+	compress/zlib,
 	golang.org/x/net/http/httpguts,
 	golang.org/x/net/http/httpproxy,
 	golang.org/x/net/http2/hpack,
@@ -584,6 +601,9 @@ var depsRules = `
 	< net/http/cgi
 	< net/http/fcgi;
 
+	net/http, net/quic
+	< net/http/http3;
+
 	# Profiling
 	FMT, compress/gzip, encoding/binary, sort, text/tabwriter
 	< runtime/pprof;
@@ -617,6 +637,9 @@ var depsRules = `
 	log/slog, testing
 	< testing/slogtest;
 
+	internal/synctest
+	< testing/synctest;
+
 	FMT, crypto/sha256, encoding/json, go/ast, go/parser, go/token,
 	internal/godebug, math/rand, encoding/hex, crypto/sha256
 	< internal/fuzz;
@@ -46,3 +46,50 @@ func ExampleDrawer_floydSteinberg() {
 		}
 	}
 }
+
+func ExampleScaler() {
+	// A 1000x750 image with a horizontal gradient.
+	src := image.NewRGBA(image.Rect(0, 0, 1000, 750))
+	for y := 0; y < 750; y++ {
+		for x := 0; x < 1000; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: uint8(x * 255 / 999), A: 0xff})
+		}
+	}
+
+	// Scale it down to a 200x150 thumbnail.
+	dst := image.NewRGBA(image.Rect(0, 0, 200, 150))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	fmt.Println(dst.Bounds())
+	fmt.Println(dst.RGBAAt(0, 0).R < dst.RGBAAt(100, 75).R, dst.RGBAAt(100, 75).R < dst.RGBAAt(199, 149).R)
+	// Output:
+	// (0,0)-(200,150)
+	// true true
+}
+
+func ExampleTransformer() {
+	src := &image.Gray{
+		Pix: []uint8{
+			1, 2, 3,
+			4, 5, 6,
+		},
+		Stride: 3,
+		Rect:   image.Rect(0, 0, 3, 2),
+	}
+
+	// Rotate src by 90 degrees clockwise: the point (x, y) maps to (2-y, x).
+	dst := image.NewGray(image.Rect(0, 0, 2, 3))
+	m := draw.Aff3{
+		0, -1, 2,
+		1, 0, 0,
+	}
+	draw.NearestNeighbor.Transform(dst, m, src, src.Bounds(), draw.Src, nil)
+
+	for y := 0; y < 3; y++ {
+		fmt.Println(dst.Pix[y*dst.Stride : (y+1)*dst.Stride])
+	}
+	// Output:
+	// [4 1]
+	// [5 2]
+	// [6 3]
+}
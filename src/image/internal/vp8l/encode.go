@@ -0,0 +1,667 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vp8l
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"slices"
+)
+
+// This file implements the encoder. It uses the color-indexing transform for
+// images with at most 256 colors and the subtract-green and predictor
+// transforms otherwise. The pixels are then compressed with LZ77 backward
+// references and a single group of Huffman codes.
+
+const (
+	// maxDimension is the largest width or height of a VP8L image.
+	maxDimension = 1 << 14
+	// maxLength is the longest LZ77 backward reference.
+	maxLength = 4096
+	// maxDistance is the farthest LZ77 backward reference that can be
+	// coded as a plain distance.
+	maxDistance = 1<<20 - int32(len(distanceMapTable))
+	// minLength is the shortest LZ77 backward reference that is used.
+	minLength = 3
+	// maxChain is the number of hash chain candidates examined per pixel.
+	maxChain = 32
+	// predictorBits is the log-2 size of the predictor transform's tiles.
+	predictorBits = 4
+
+	hashBits = 16
+)
+
+// encoder holds the bit-stream for a VP8L image being encoded.
+type encoder struct {
+	buf   []byte
+	bits  uint64
+	nBits uint32
+}
+
+// write writes the low n bits of u to the encoder's bit-stream.
+func (e *encoder) write(u uint32, n uint32) {
+	e.bits |= uint64(u) << e.nBits
+	e.nBits += n
+	for e.nBits >= 8 {
+		e.buf = append(e.buf, uint8(e.bits))
+		e.bits >>= 8
+		e.nBits -= 8
+	}
+}
+
+// flush pads the bit-stream to a whole number of bytes.
+func (e *encoder) flush() {
+	if e.nBits > 0 {
+		e.buf = append(e.buf, uint8(e.bits))
+		e.bits, e.nBits = 0, 0
+	}
+}
+
+// Encode writes the image m to w in the VP8L lossless format. The result is
+// the raw VP8L bit-stream, without a RIFF container.
+func Encode(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+	width, height := int32(b.Dx()), int32(b.Dy())
+	if width <= 0 || height <= 0 || width > maxDimension || height > maxDimension {
+		return errors.New("vp8l: invalid image size: " + b.String())
+	}
+	pix := nrgbaPix(m)
+
+	hasAlpha := uint32(0)
+	for p := 3; p < len(pix); p += 4 {
+		if pix[p] != 0xff {
+			hasAlpha = 1
+			break
+		}
+	}
+
+	e := &encoder{buf: make([]byte, 0, len(pix)/4)}
+	e.write(0x2f, 8)
+	e.write(uint32(width-1), 14)
+	e.write(uint32(height-1), 14)
+	e.write(hasAlpha, 1)
+	e.write(0, 3) // Version.
+
+	if palette, ok := findPalette(pix); ok {
+		pix, width = e.writeColorIndexing(pix, width, height, palette)
+	} else {
+		e.write(1, 1)
+		e.write(transformTypeSubtractGreen, 2)
+		subtractGreen(pix)
+		pix = e.writePredictor(pix, width, height)
+	}
+	e.write(0, 1) // No more transforms.
+
+	e.writePix(pix, width, height, true)
+	e.flush()
+	_, err := w.Write(e.buf)
+	return err
+}
+
+// nrgbaPix returns the pixels of m as non-alpha-premultiplied RGBA bytes,
+// with no padding between rows.
+func nrgbaPix(m image.Image) []byte {
+	b := m.Bounds()
+	dx, dy := b.Dx(), b.Dy()
+	pix := make([]byte, 4*dx*dy)
+	if n, ok := m.(*image.NRGBA); ok {
+		for y := 0; y < dy; y++ {
+			i := n.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(pix[4*dx*y:4*dx*(y+1)], n.Pix[i:i+4*dx])
+		}
+		return pix
+	}
+	p := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			pix[p+0] = c.R
+			pix[p+1] = c.G
+			pix[p+2] = c.B
+			pix[p+3] = c.A
+			p += 4
+		}
+	}
+	return pix
+}
+
+// findPalette returns the distinct colors of pix, in increasing order, if
+// there are at most 256 of them.
+func findPalette(pix []byte) ([]uint32, bool) {
+	seen := make(map[uint32]bool)
+	var palette []uint32
+	for p := 0; p < len(pix); p += 4 {
+		c := uint32(pix[p+0])<<24 | uint32(pix[p+1])<<16 | uint32(pix[p+2])<<8 | uint32(pix[p+3])
+		if seen[c] {
+			continue
+		}
+		if len(palette) == 256 {
+			return nil, false
+		}
+		seen[c] = true
+		palette = append(palette, c)
+	}
+	slices.Sort(palette)
+	return palette, true
+}
+
+// writeColorIndexing writes a color-indexing transform for the given palette
+// and returns the transformed pixels and their width.
+func (e *encoder) writeColorIndexing(pix []byte, w, h int32, palette []uint32) ([]byte, int32) {
+	e.write(1, 1)
+	e.write(transformTypeColorIndexing, 2)
+	e.write(uint32(len(palette)-1), 8)
+
+	// The palette is itself delta-coded.
+	pp := make([]byte, 4*len(palette))
+	index := make(map[uint32]uint32, len(palette))
+	prev := uint32(0)
+	for i, c := range palette {
+		index[c] = uint32(i)
+		for j := 0; j < 4; j++ {
+			pp[4*i+j] = uint8(c>>(24-8*j)) - uint8(prev>>(24-8*j))
+		}
+		prev = c
+	}
+	e.writePix(pp, int32(len(palette)), 1, false)
+
+	bits := uint32(0)
+	switch {
+	case len(palette) <= 2:
+		bits = 3
+	case len(palette) <= 4:
+		bits = 2
+	case len(palette) <= 16:
+		bits = 1
+	}
+	newW := nTiles(w, bits)
+	dst := make([]byte, 4*newW*h)
+	bitsPerPixel, xMask := uint32(8)>>bits, int32(1)<<bits-1
+	p := 0
+	for y := int32(0); y < h; y++ {
+		for x := int32(0); x < w; x++ {
+			c := uint32(pix[p+0])<<24 | uint32(pix[p+1])<<16 | uint32(pix[p+2])<<8 | uint32(pix[p+3])
+			d := 4 * (y*newW + x>>bits)
+			dst[d+1] |= uint8(index[c] << (bitsPerPixel * uint32(x&xMask)))
+			p += 4
+		}
+	}
+	return dst, newW
+}
+
+// subtractGreen applies the subtract-green transform to pix.
+func subtractGreen(pix []byte) {
+	for p := 0; p < len(pix); p += 4 {
+		green := pix[p+1]
+		pix[p+0] -= green
+		pix[p+2] -= green
+	}
+}
+
+// predict returns the prediction for the pixel at offset p, with the pixel
+// above it at offset top, using the given predictor mode. It mirrors the
+// predictions made by inversePredictor for pixels that are neither in the
+// first row nor in the first column.
+func predict(pix []byte, p, top int32, mode uint8) [4]uint8 {
+	var q [4]uint8
+	for i := int32(0); i < 4; i++ {
+		l, t := pix[p-4+i], pix[top+i]
+		tl, tr := pix[top-4+i], pix[top+4+i]
+		switch mode {
+		case 0:
+			if i == 3 {
+				q[i] = 0xff
+			}
+		case 1:
+			q[i] = l
+		case 2:
+			q[i] = t
+		case 3:
+			q[i] = tr
+		case 4:
+			q[i] = tl
+		case 5:
+			q[i] = avg2(avg2(l, tr), t)
+		case 6:
+			q[i] = avg2(l, tl)
+		case 7:
+			q[i] = avg2(l, t)
+		case 8:
+			q[i] = avg2(tl, t)
+		case 9:
+			q[i] = avg2(t, tr)
+		case 10:
+			q[i] = avg2(avg2(l, tl), avg2(t, tr))
+		case 12:
+			q[i] = clampAddSubtractFull(l, t, tl)
+		case 13:
+			q[i] = clampAddSubtractHalf(avg2(l, t), tl)
+		}
+	}
+	if mode == 11 {
+		var lDist, tDist int32
+		for i := int32(0); i < 4; i++ {
+			c := int32(pix[top-4+i])
+			lDist += abs(c - int32(pix[top+i]))
+			tDist += abs(c - int32(pix[p-4+i]))
+		}
+		src := top
+		if lDist < tDist {
+			src = p - 4
+		}
+		copy(q[:], pix[src:src+4])
+	}
+	return q
+}
+
+// writePredictor writes a predictor transform for pix, choosing the mode of
+// each tile that minimizes the residuals, and returns the residuals.
+func (e *encoder) writePredictor(pix []byte, w, h int32) []byte {
+	tilesPerRow, tilesPerColumn := nTiles(w, predictorBits), nTiles(h, predictorBits)
+	modes := make([]byte, 4*tilesPerRow*tilesPerColumn)
+	for ty := int32(0); ty < tilesPerColumn; ty++ {
+		for tx := int32(0); tx < tilesPerRow; tx++ {
+			x0, y0 := tx<<predictorBits, ty<<predictorBits
+			x1, y1 := min(x0+1<<predictorBits, w), min(y0+1<<predictorBits, h)
+			// The first row and column use fixed predictors.
+			x0, y0 = max(x0, 1), max(y0, 1)
+			best, bestCost := uint8(0), int32(-1)
+			for mode := uint8(0); mode < 14; mode++ {
+				cost := int32(0)
+				for y := y0; y < y1; y++ {
+					for x := x0; x < x1; x++ {
+						p := 4 * (y*w + x)
+						q := predict(pix, p, p-4*w, mode)
+						for i := int32(0); i < 4; i++ {
+							cost += abs(int32(int8(pix[p+i] - q[i])))
+						}
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[4*(ty*tilesPerRow+tx)+1] = best
+		}
+	}
+
+	e.write(1, 1)
+	e.write(transformTypePredictor, 2)
+	e.write(predictorBits-2, 3)
+	e.writePix(modes, tilesPerRow, tilesPerColumn, false)
+
+	res := make([]byte, len(pix))
+	for y := int32(0); y < h; y++ {
+		for x := int32(0); x < w; x++ {
+			p := 4 * (y*w + x)
+			var q [4]uint8
+			switch {
+			case x == 0 && y == 0:
+				q[3] = 0xff
+			case y == 0:
+				copy(q[:], pix[p-4:p])
+			case x == 0:
+				copy(q[:], pix[p-4*w:p-4*w+4])
+			default:
+				q = predict(pix, p, p-4*w, modes[4*((y>>predictorBits)*tilesPerRow+x>>predictorBits)+1])
+			}
+			for i := int32(0); i < 4; i++ {
+				res[p+i] = pix[p+i] - q[i]
+			}
+		}
+	}
+	return res
+}
+
+// A symbol is either a literal pixel or an LZ77 backward reference.
+type symbol struct {
+	// length is the number of pixels copied, or zero for a literal.
+	length uint32
+	// value is the distance code for a backward reference, or the
+	// pixel's offset in pix for a literal.
+	value uint32
+}
+
+// findSymbols splits pix into literals and LZ77 backward references.
+func findSymbols(pix []byte, w int32) []symbol {
+	n := int32(len(pix) / 4)
+	argb := make([]uint32, n)
+	for i := range argb {
+		p := 4 * i
+		argb[i] = uint32(pix[p+3])<<24 | uint32(pix[p+0])<<16 | uint32(pix[p+1])<<8 | uint32(pix[p+2])
+	}
+
+	// distCodes maps the distances that have a short two-dimensional
+	// code to that code.
+	distCodes := make(map[int32]uint32, len(distanceMapTable))
+	for code := uint32(len(distanceMapTable)); code >= 1; code-- {
+		distCodes[distanceMap(w, code)] = code
+	}
+
+	var head [1 << hashBits]int32
+	for i := range head {
+		head[i] = -1
+	}
+	chain := make([]int32, n)
+	hash := func(i int32) uint32 {
+		return (argb[i]*colorCacheMultiplier ^ argb[i+1]) * colorCacheMultiplier >> (32 - hashBits)
+	}
+	insert := func(i int32) {
+		if i+1 < n {
+			h := hash(i)
+			chain[i] = head[h]
+			head[h] = i
+		}
+	}
+	matchLength := func(i, j int32) int32 {
+		l, end := int32(0), min(n-i, maxLength)
+		for l < end && argb[i+l] == argb[j+l] {
+			l++
+		}
+		return l
+	}
+
+	var symbols []symbol
+	for i := int32(0); i < n; {
+		bestLength, bestDist := int32(0), int32(0)
+		// Try the pixels to the left and above first, as they have the
+		// shortest distance codes.
+		for _, d := range [2]int32{1, w} {
+			if d <= i {
+				if l := matchLength(i, i-d); l > bestLength {
+					bestLength, bestDist = l, d
+				}
+			}
+		}
+		if i+1 < n {
+			for j, k := head[hash(i)], 0; j >= 0 && k < maxChain && i-j <= maxDistance; j, k = chain[j], k+1 {
+				if l := matchLength(i, j); l > bestLength {
+					bestLength, bestDist = l, i-j
+				}
+			}
+		}
+		if bestLength < minLength {
+			symbols = append(symbols, symbol{value: uint32(4 * i)})
+			insert(i)
+			i++
+			continue
+		}
+		code, ok := distCodes[bestDist]
+		if !ok {
+			code = uint32(bestDist) + uint32(len(distanceMapTable))
+		}
+		symbols = append(symbols, symbol{length: uint32(bestLength), value: code})
+		for end := i + bestLength; i < end; i++ {
+			insert(i)
+		}
+	}
+	return symbols
+}
+
+// prefixCode returns the prefix symbol and the extra bits that code the LZ77
+// parameter v, the inverse of lz77Param.
+func prefixCode(v uint32) (sym, nExtra, extra uint32) {
+	n := v - 1
+	if n < 4 {
+		return n, 0, 0
+	}
+	hb := uint32(31)
+	for n>>hb == 0 {
+		hb--
+	}
+	nExtra = hb - 1
+	return 2*hb + (n>>nExtra)&1, nExtra, n & (1<<nExtra - 1)
+}
+
+// writePix writes pixel data, the inverse of decodePix. It uses no color
+// cache and, at the top level, no meta Huffman codes.
+func (e *encoder) writePix(pix []byte, w, h int32, topLevel bool) {
+	e.write(0, 1) // No color cache.
+	if topLevel {
+		e.write(0, 1) // No meta Huffman codes.
+	}
+
+	symbols := findSymbols(pix, w)
+	var hist [nHuff][]uint32
+	for i, n := range alphabetSizes {
+		hist[i] = make([]uint32, n)
+	}
+	for _, s := range symbols {
+		if s.length == 0 {
+			hist[huffGreen][pix[s.value+1]]++
+			hist[huffRed][pix[s.value+0]]++
+			hist[huffBlue][pix[s.value+2]]++
+			hist[huffAlpha][pix[s.value+3]]++
+			continue
+		}
+		sym, _, _ := prefixCode(s.length)
+		hist[huffGreen][nLiteralCodes+sym]++
+		sym, _, _ = prefixCode(s.value)
+		hist[huffDistance][sym]++
+	}
+	var codes [nHuff]huffmanCode
+	for i := range codes {
+		codes[i] = e.writeHuffmanCode(hist[i])
+	}
+
+	for _, s := range symbols {
+		if s.length == 0 {
+			codes[huffGreen].write(e, uint32(pix[s.value+1]))
+			codes[huffRed].write(e, uint32(pix[s.value+0]))
+			codes[huffBlue].write(e, uint32(pix[s.value+2]))
+			codes[huffAlpha].write(e, uint32(pix[s.value+3]))
+			continue
+		}
+		sym, nExtra, extra := prefixCode(s.length)
+		codes[huffGreen].write(e, nLiteralCodes+sym)
+		e.write(extra, nExtra)
+		sym, nExtra, extra = prefixCode(s.value)
+		codes[huffDistance].write(e, sym)
+		e.write(extra, nExtra)
+	}
+}
+
+// huffmanCode is a canonical Huffman code used by the encoder.
+type huffmanCode struct {
+	// codes holds the bit-reversed code of each symbol, in the order
+	// that the decoder reads the bits.
+	codes []uint32
+	// lengths holds the number of bits written for each symbol. They are
+	// all zero for a code with a single symbol.
+	lengths []uint32
+}
+
+func (c *huffmanCode) write(e *encoder, sym uint32) {
+	e.write(c.codes[sym], c.lengths[sym])
+}
+
+// newHuffmanCode returns the code with the given code lengths.
+func newHuffmanCode(lengths []uint32) huffmanCode {
+	c := huffmanCode{
+		codes:   make([]uint32, len(lengths)),
+		lengths: make([]uint32, len(lengths)),
+	}
+	nSymbols := 0
+	for _, l := range lengths {
+		if l != 0 {
+			nSymbols++
+		}
+	}
+	if nSymbols < 2 {
+		return c
+	}
+	codes, _ := codeLengthsToCodes(lengths)
+	for i, l := range lengths {
+		for j := uint32(0); j < l; j++ {
+			c.codes[i] |= (codes[i] >> j & 1) << (l - 1 - j)
+		}
+	}
+	copy(c.lengths, lengths)
+	return c
+}
+
+// writeHuffmanCode writes a Huffman code for the histogram, the inverse of
+// decodeHuffmanTree, and returns the code.
+func (e *encoder) writeHuffmanCode(hist []uint32) huffmanCode {
+	lengths := huffmanCodeLengths(hist, 15)
+	nSymbols, lastSymbol := 0, uint32(0)
+	for i, l := range lengths {
+		if l != 0 {
+			nSymbols++
+			lastSymbol = uint32(i)
+		}
+	}
+	if nSymbols <= 1 && lastSymbol < 256 {
+		// A simple code with a single symbol, which takes no bits.
+		e.write(1, 1)
+		e.write(0, 1)
+		if lastSymbol < 2 {
+			e.write(0, 1)
+			e.write(lastSymbol, 1)
+		} else {
+			e.write(1, 1)
+			e.write(lastSymbol, 8)
+		}
+		return newHuffmanCode(lengths)
+	}
+
+	// Run-length encode the code lengths, as in decodeCodeLengths.
+	type run struct{ code, extra uint32 }
+	var runs []run
+	prev := uint32(8)
+	for i := 0; i < len(lengths); {
+		l, n := lengths[i], 1
+		for i+n < len(lengths) && lengths[i+n] == l {
+			n++
+		}
+		i += n
+		if l == 0 {
+			for n >= 11 {
+				r := min(n, 138)
+				runs = append(runs, run{18, uint32(r - 11)})
+				n -= r
+			}
+			if n >= 3 {
+				runs = append(runs, run{17, uint32(n - 3)})
+				n = 0
+			}
+		} else {
+			if l != prev {
+				runs = append(runs, run{l, 0})
+				n--
+				prev = l
+			}
+			for n >= 3 {
+				r := min(n, 6)
+				runs = append(runs, run{16, uint32(r - 3)})
+				n -= r
+			}
+		}
+		for ; n > 0; n-- {
+			runs = append(runs, run{l, 0})
+		}
+	}
+
+	var clHist [len(codeLengthCodeOrder)]uint32
+	for _, r := range runs {
+		clHist[r.code]++
+	}
+	clLengths := huffmanCodeLengths(clHist[:], 7)
+	nCodes := 4
+	for i, c := range codeLengthCodeOrder {
+		if clLengths[c] != 0 {
+			nCodes = max(nCodes, i+1)
+		}
+	}
+	e.write(0, 1)
+	e.write(uint32(nCodes-4), 4)
+	for _, c := range codeLengthCodeOrder[:nCodes] {
+		e.write(clLengths[c], 3)
+	}
+	e.write(0, 1) // Code lengths for all symbols follow.
+	clCode := newHuffmanCode(clLengths)
+	for _, r := range runs {
+		clCode.write(e, r.code)
+		if r.code >= repeatsCodeLength {
+			e.write(r.extra, uint32(repeatBits[r.code-repeatsCodeLength]))
+		}
+	}
+	return newHuffmanCode(lengths)
+}
+
+// huffmanCodeLengths returns the code lengths of a Huffman code for the
+// histogram, with no code longer than maxCodeLength bits. If the histogram
+// has fewer than two non-zero entries, the length of the used symbol, or of
+// symbol 0 if none is used, is 1.
+func huffmanCodeLengths(hist []uint32, maxCodeLength uint32) []uint32 {
+	lengths := make([]uint32, len(hist))
+	type node struct {
+		weight uint64
+		symbol int32 // -1 for internal nodes
+		left   int32
+		right  int32
+	}
+	var leaves []node
+	for i, n := range hist {
+		if n != 0 {
+			leaves = append(leaves, node{weight: uint64(n), symbol: int32(i)})
+		}
+	}
+	switch len(leaves) {
+	case 0:
+		lengths[0] = 1
+		return lengths
+	case 1:
+		lengths[leaves[0].symbol] = 1
+		return lengths
+	}
+
+	for {
+		slices.SortStableFunc(leaves, func(a, b node) int {
+			if a.weight < b.weight {
+				return -1
+			}
+			if a.weight > b.weight {
+				return +1
+			}
+			return 0
+		})
+		// Build the tree with two queues: the sorted leaves and the
+		// internal nodes, which are created in increasing weight order.
+		nodes := slices.Clone(leaves)
+		li, ni := 0, len(leaves)
+		pop := func() int32 {
+			if li < len(leaves) && (ni == len(nodes) || nodes[li].weight <= nodes[ni].weight) {
+				li++
+				return int32(li - 1)
+			}
+			ni++
+			return int32(ni - 1)
+		}
+		for len(nodes) < 2*len(leaves)-1 {
+			a, b := pop(), pop()
+			nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, symbol: -1, left: a, right: b})
+		}
+		depth := make([]uint32, len(nodes))
+		maxDepth := uint32(0)
+		for i := len(nodes) - 1; i >= len(leaves); i-- {
+			d := depth[i] + 1
+			depth[nodes[i].left], depth[nodes[i].right] = d, d
+			maxDepth = max(maxDepth, d)
+		}
+		if maxDepth <= maxCodeLength {
+			for i, l := range leaves {
+				lengths[l.symbol] = depth[i]
+			}
+			return lengths
+		}
+		// Flatten the histogram and try again.
+		for i := range leaves {
+			leaves[i].weight = (leaves[i].weight + 1) / 2
+		}
+	}
+}
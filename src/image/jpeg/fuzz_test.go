@@ -47,7 +47,14 @@ func FuzzDecode(f *testing.F) {
 		}
 		for q := 1; q <= 100; q++ {
 			var w bytes.Buffer
-			err := Encode(&w, img, &Options{Quality: q})
+			o := &Options{
+				Quality:         q,
+				Subsampling:     Subsampling(q % 3),
+				Progressive:     q%4 == 1,
+				OptimizeHuffman: q%4 == 2,
+				RestartInterval: q % 5,
+			}
+			err := Encode(&w, img, o)
 			if err != nil {
 				t.Errorf("failed to encode valid image: %s", err)
 				continue
@@ -61,6 +61,7 @@ const (
 	// but in practice, their use is described at
 	// https://www.sno.phy.queensu.ca/~phil/exiftool/TagNames/JPEG.html
 	app0Marker  = 0xe0
+	app1Marker  = 0xe1
 	app14Marker = 0xee
 	app15Marker = 0xef
 )
@@ -140,6 +141,11 @@ type decoder struct {
 	adobeTransform      uint8
 	eobRun              uint16 // End-of-Band run, specified in section G.1.2.2.
 
+	// keepSegments is whether to record the APPn and COM segments in
+	// segments instead of skipping them.
+	keepSegments bool
+	segments     []Segment
+
 	comp       [maxComponents]component
 	progCoeffs [maxComponents][]block // Saved state between progressive-mode scans.
 	huff       [maxTc + 1][maxTh + 1]huffman
@@ -597,12 +603,21 @@ func (d *decoder) decode(r io.Reader, configOnly bool) (image.Image, error) {
 			return nil, FormatError("short segment length")
 		}
 
+		if d.keepSegments && (app0Marker <= marker && marker <= app15Marker || marker == comMarker) {
+			data := make([]byte, n)
+			if err := d.readFull(data); err != nil {
+				return nil, err
+			}
+			d.segments = append(d.segments, Segment{Marker: marker, Data: data})
+			continue
+		}
+
 		switch marker {
 		case sof0Marker, sof1Marker, sof2Marker:
 			d.baseline = marker == sof0Marker
 			d.progressive = marker == sof2Marker
 			err = d.processSOF(n)
-			if configOnly && d.jfif {
+			if configOnly && d.jfif && !d.keepSegments {
 				return nil, err
 			}
 		case dhtMarker:
@@ -84,6 +84,56 @@ func TestDecodeProgressive(t *testing.T) {
 	}
 }
 
+// TestDecodeProgressiveRestart tests decoding progressive images with a
+// restart interval, which counts blocks rather than MCUs in their
+// non-interleaved scans. The progressive images were transcoded from the
+// baseline ones with libjpeg, as by "jpegtran -progressive -restart N",
+// so both decode to exactly the same pixel data.
+func TestDecodeProgressiveRestart(t *testing.T) {
+	testCases := []struct {
+		baseline, progressive string
+	}{
+		{"../testdata/video-001.q50.420.jpeg", "../testdata/video-001.q50.420.progressive.restart.jpeg"},
+		{"../testdata/video-005.gray.q50.jpeg", "../testdata/video-005.gray.q50.progressive.restart.jpeg"},
+	}
+	for _, tc := range testCases {
+		m0, err := decodeFile(tc.baseline)
+		if err != nil {
+			t.Errorf("%s: %v", tc.baseline, err)
+			continue
+		}
+		m1, err := decodeFile(tc.progressive)
+		if err != nil {
+			t.Errorf("%s: %v", tc.progressive, err)
+			continue
+		}
+		if m0.Bounds() != m1.Bounds() {
+			t.Errorf("%s: bounds differ: %v and %v", tc.progressive, m0.Bounds(), m1.Bounds())
+			continue
+		}
+		switch m0 := m0.(type) {
+		case *image.YCbCr:
+			m1 := m1.(*image.YCbCr)
+			if err := check(m0.Bounds(), m0.Y, m1.Y, m0.YStride, m1.YStride); err != nil {
+				t.Errorf("%s (Y): %v", tc.progressive, err)
+			}
+			if err := check(m0.Bounds(), m0.Cb, m1.Cb, m0.CStride, m1.CStride); err != nil {
+				t.Errorf("%s (Cb): %v", tc.progressive, err)
+			}
+			if err := check(m0.Bounds(), m0.Cr, m1.Cr, m0.CStride, m1.CStride); err != nil {
+				t.Errorf("%s (Cr): %v", tc.progressive, err)
+			}
+		case *image.Gray:
+			m1 := m1.(*image.Gray)
+			if err := check(m0.Bounds(), m0.Pix, m1.Pix, m0.Stride, m1.Stride); err != nil {
+				t.Errorf("%s: %v", tc.progressive, err)
+			}
+		default:
+			t.Errorf("%s: unexpected image type %T", tc.progressive, m0)
+		}
+	}
+}
+
 func decodeFile(filename string) (image.Image, error) {
 	f, err := os.Open(filename)
 	if err != nil {
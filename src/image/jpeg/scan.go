@@ -168,7 +168,32 @@ func (d *decoder) processSOS(n int) error {
 		// blocks: the third block in the first row has (bx, by) = (2, 0).
 		bx, by     int
 		blockCount int
+		// nBlocks is the number of blocks decoded in a non-interleaved scan.
+		nBlocks int
 	)
+	restart := func() error {
+		// For well-formed input, the RST[0-7] restart marker follows
+		// immediately. For corrupt input, call findRST to try to
+		// resynchronize.
+		if err := d.readFull(d.tmp[:2]); err != nil {
+			return err
+		} else if d.tmp[0] != 0xff || d.tmp[1] != expectedRST {
+			if err := d.findRST(expectedRST); err != nil {
+				return err
+			}
+		}
+		expectedRST++
+		if expectedRST == rst7Marker+1 {
+			expectedRST = rst0Marker
+		}
+		// Reset the Huffman decoder.
+		d.bits = bits{}
+		// Reset the DC components, as per section F.2.1.3.1.
+		dc = [maxComponents]int32{}
+		// Reset the progressive decoder state, as per section G.1.2.2.
+		d.eobRun = 0
+		return nil
+	}
 	for my := 0; my < myy; my++ {
 		for mx := 0; mx < mxx; mx++ {
 			for i := 0; i < nComp; i++ {
@@ -212,6 +237,14 @@ func (d *decoder) processSOS(n int) error {
 						if bx*8 >= d.width || by*8 >= d.height {
 							continue
 						}
+						// In a non-interleaved scan, the restart interval
+						// counts blocks rather than MCUs, as per section A.2.2.
+						if d.ri > 0 && nBlocks > 0 && nBlocks%d.ri == 0 {
+							if err := restart(); err != nil {
+								return err
+							}
+						}
+						nBlocks++
 					}
 
 					// Load the previous partially decoded coefficients, if applicable.
@@ -304,27 +337,10 @@ func (d *decoder) processSOS(n int) error {
 				} // for j
 			} // for i
 			mcu++
-			if d.ri > 0 && mcu%d.ri == 0 && mcu < mxx*myy {
-				// For well-formed input, the RST[0-7] restart marker follows
-				// immediately. For corrupt input, call findRST to try to
-				// resynchronize.
-				if err := d.readFull(d.tmp[:2]); err != nil {
+			if nComp != 1 && d.ri > 0 && mcu%d.ri == 0 && mcu < mxx*myy {
+				if err := restart(); err != nil {
 					return err
-				} else if d.tmp[0] != 0xff || d.tmp[1] != expectedRST {
-					if err := d.findRST(expectedRST); err != nil {
-						return err
-					}
-				}
-				expectedRST++
-				if expectedRST == rst7Marker+1 {
-					expectedRST = rst0Marker
 				}
-				// Reset the Huffman decoder.
-				d.bits = bits{}
-				// Reset the DC components, as per section F.2.1.3.1.
-				dc = [maxComponents]int32{}
-				// Reset the progressive decoder state, as per section G.1.2.2.
-				d.eobRun = 0
 			}
 		} // for mx
 	} // for my
@@ -0,0 +1,95 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jpeg
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// A Segment is an application-specific (APPn) or comment (COM) segment of
+// a JPEG image. Such segments hold metadata that does not affect the
+// decoded pixels, such as EXIF data (in APP1) or an ICC color profile
+// (in APP2).
+type Segment struct {
+	// Marker identifies the segment: 0xe0 through 0xef for APP0 through
+	// APP15, or 0xfe for COM.
+	Marker byte
+	// Data is the content of the segment, excluding the marker and the
+	// segment length.
+	Data []byte
+}
+
+// ReadSegments reads a JPEG image from r and returns its APPn and COM
+// segments, in order, without decoding the image data. Segments that
+// follow the first scan of the image are not returned.
+func ReadSegments(r io.Reader) ([]Segment, error) {
+	d := decoder{keepSegments: true}
+	if _, err := d.decode(r, true); err != nil {
+		return nil, err
+	}
+	if d.nComp == 0 {
+		return nil, FormatError("missing SOF marker")
+	}
+	return d.segments, nil
+}
+
+// EXIFOrientation returns the orientation recorded in the EXIF data of an
+// image, given its segments. The orientation ranges from 1 to 8, as defined
+// by the EXIF Orientation tag; 1 means that the image is stored upright.
+// EXIFOrientation returns 0 if there is no EXIF data or it has no valid
+// orientation.
+//
+// The decoder does not apply the orientation to the image.
+func EXIFOrientation(segs []Segment) int {
+	for _, s := range segs {
+		if s.Marker != app1Marker || len(s.Data) < 6 || string(s.Data[:6]) != "Exif\x00\x00" {
+			continue
+		}
+		return tiffOrientation(s.Data[6:])
+	}
+	return 0
+}
+
+// tiffOrientation returns the value of the Orientation tag in the first
+// image file directory of the TIFF-structured data b, or 0.
+func tiffOrientation(b []byte) int {
+	if len(b) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(b[:4]) {
+	case "II\x2a\x00":
+		order = binary.LittleEndian
+	case "MM\x00\x2a":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := order.Uint32(b[4:])
+	if ifd > uint32(len(b)-2) {
+		return 0
+	}
+	n := int(order.Uint16(b[ifd:]))
+	entries := b[ifd+2:]
+	for i := 0; i < n && 12*i+12 <= len(entries); i++ {
+		e := entries[12*i : 12*i+12]
+		const (
+			tagOrientation = 0x0112
+			typeShort      = 3
+		)
+		if order.Uint16(e[0:]) != tagOrientation {
+			continue
+		}
+		if order.Uint16(e[2:]) != typeShort || order.Uint32(e[4:]) != 1 {
+			return 0
+		}
+		if o := int(order.Uint16(e[8:])); 1 <= o && o <= 8 {
+			return o
+		}
+		return 0
+	}
+	return 0
+}
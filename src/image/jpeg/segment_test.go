@@ -0,0 +1,116 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// exif returns the content of an APP1 segment whose EXIF data holds the
+// given orientation, in big- or little-endian byte order.
+func exif(orientation uint16, bigEndian bool) []byte {
+	b := []byte("Exif\x00\x00")
+	var order binary.AppendByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+		b = append(b, "MM\x00\x2a"...)
+	} else {
+		b = append(b, "II\x2a\x00"...)
+	}
+	b = order.AppendUint32(b, 8) // Offset of the first IFD.
+	b = order.AppendUint16(b, 2) // Number of entries.
+	// ImageDescription, ASCII, 4 bytes.
+	b = order.AppendUint16(b, 0x010e)
+	b = order.AppendUint16(b, 2)
+	b = order.AppendUint32(b, 4)
+	b = append(b, "abc\x00"...)
+	// Orientation, SHORT, 1 value.
+	b = order.AppendUint16(b, 0x0112)
+	b = order.AppendUint16(b, 3)
+	b = order.AppendUint32(b, 1)
+	b = order.AppendUint16(b, orientation)
+	b = order.AppendUint16(b, 0)
+	b = order.AppendUint32(b, 0) // No next IFD.
+	return b
+}
+
+func TestEXIFOrientation(t *testing.T) {
+	for _, bigEndian := range []bool{false, true} {
+		for o := uint16(0); o <= 9; o++ {
+			want := int(o)
+			if o < 1 || o > 8 {
+				want = 0
+			}
+			segs := []Segment{
+				{Marker: 0xe0, Data: []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")},
+				{Marker: 0xe1, Data: exif(o, bigEndian)},
+			}
+			if got := EXIFOrientation(segs); got != want {
+				t.Errorf("bigEndian=%t: EXIFOrientation with orientation %d = %d, want %d", bigEndian, o, got, want)
+			}
+		}
+	}
+	for _, data := range []string{
+		"",
+		"Exif\x00\x00",
+		"Exif\x00\x00II\x2a\x00\xff\xff\xff\xff",
+		"Exif\x00\x00II\x2a\x00\x08\x00\x00\x00\xff\xff",
+		"http://ns.adobe.com/xap/1.0/\x00",
+	} {
+		if got := EXIFOrientation([]Segment{{Marker: 0xe1, Data: []byte(data)}}); got != 0 {
+			t.Errorf("EXIFOrientation(%q) = %d, want 0", data, got)
+		}
+	}
+}
+
+func TestSegments(t *testing.T) {
+	segs := []Segment{
+		{Marker: 0xe1, Data: exif(6, true)},
+		{Marker: 0xe2, Data: []byte("ICC_PROFILE\x00\x01\x01profile")},
+		{Marker: 0xfe, Data: []byte("a comment")},
+		{Marker: 0xef, Data: []byte{}},
+	}
+	m := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for _, progressive := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, m, &Options{Segments: segs, Progressive: progressive}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadSegments(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, segs) {
+			t.Errorf("ReadSegments = %q, want %q", got, segs)
+		}
+		if o := EXIFOrientation(got); o != 6 {
+			t.Errorf("EXIFOrientation = %d, want 6", o)
+		}
+		if _, err := Decode(&buf); err != nil {
+			t.Errorf("Decode: %v", err)
+		}
+	}
+
+	// The test images have a JFIF segment.
+	b, err := os.ReadFile("../testdata/video-001.progressive.jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSegments(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == 0 || got[0].Marker != 0xe0 || string(got[0].Data[:5]) != "JFIF\x00" {
+		t.Errorf("ReadSegments = %q, want a JFIF segment first", got)
+	}
+	if _, err := ReadSegments(bytes.NewReader(b[:100])); err == nil {
+		t.Errorf("ReadSegments of truncated image succeeded")
+	}
+}
@@ -96,7 +96,8 @@ type huffmanSpec struct {
 }
 
 // theHuffmanSpec is the Huffman encoding specifications.
-// This encoder uses the same Huffman encoding for all images.
+// This encoder uses the same Huffman encoding for all images, unless the
+// Huffman encoding is optimized.
 var theHuffmanSpec = [nHuffIndex]huffmanSpec{
 	// Luminance DC.
 	{
@@ -199,6 +200,97 @@ func init() {
 	}
 }
 
+// optimalHuffmanSpec returns a Huffman encoding for symbols that occur with
+// the given frequencies, with no codeword longer than 16 bits. It follows the
+// procedure of section K.2, which reserves the all-ones codeword.
+func optimalHuffmanSpec(freq *[256]int) huffmanSpec {
+	var (
+		f        [257]int
+		codeSize [257]int
+		others   [257]int
+	)
+	copy(f[:], freq[:])
+	if f == [257]int{} {
+		// Every table needs at least one codeword.
+		f[0] = 1
+	}
+	f[256] = 1 // The reserved codeword.
+	for i := range others {
+		others[i] = -1
+	}
+	for {
+		// Find the two least frequent symbols, c1 and c2, preferring
+		// larger symbol values in case of ties.
+		c1, c2 := -1, -1
+		for i, n := range f {
+			if n == 0 {
+				continue
+			}
+			if c1 < 0 || n <= f[c1] {
+				c2, c1 = c1, i
+			} else if c2 < 0 || n <= f[c2] {
+				c2 = i
+			}
+		}
+		if c2 < 0 {
+			break
+		}
+		// Merge the two trees.
+		f[c1] += f[c2]
+		f[c2] = 0
+		codeSize[c1]++
+		for others[c1] >= 0 {
+			c1 = others[c1]
+			codeSize[c1]++
+		}
+		others[c1] = c2
+		codeSize[c2]++
+		for others[c2] >= 0 {
+			c2 = others[c2]
+			codeSize[c2]++
+		}
+	}
+
+	var bits [33]int
+	for _, n := range codeSize {
+		if n > 0 {
+			bits[n]++
+		}
+	}
+	// Limit the codeword lengths to 16 bits, as per Figure K.3.
+	for i := 32; i > 16; i-- {
+		for bits[i] > 0 {
+			j := i - 2
+			for bits[j] == 0 {
+				j--
+			}
+			bits[i] -= 2
+			bits[i-1]++
+			bits[j+1] += 2
+			bits[j]--
+		}
+	}
+	// Remove the reserved codeword, which is one of the longest.
+	i := 16
+	for bits[i] == 0 {
+		i--
+	}
+	bits[i]--
+
+	var s huffmanSpec
+	for i := range s.count {
+		s.count[i] = uint8(bits[i+1])
+	}
+	for n := 1; n <= 32; n++ {
+		for v := 0; v < 256; v++ {
+			if codeSize[v] == n {
+				s.value = append(s.value, uint8(v))
+			}
+		}
+	}
+	return s
+}
+
 // writer is a buffered writer.
 type writer interface {
 	Flush() error
@@ -218,6 +310,40 @@ type encoder struct {
 	bits, nBits uint32
 	// quant is the scaled quantization tables, in zig-zag order.
 	quant [nQuantIndex][blockSize]byte
+	// huffSpec and huffLUT are the Huffman encodings in use.
+	huffSpec [nHuffIndex]huffmanSpec
+	huffLUT  [nHuffIndex]huffmanLUT
+	// counts, if non-nil, accumulates the frequency of each Huffman-coded
+	// symbol. While counting, no entropy-coded data is written.
+	counts *[nHuffIndex][256]int
+
+	// nComponent is the number of color components, 1 or 3.
+	nComponent int
+	// h and v are the luma sampling factors. Chroma is never upsampled,
+	// so its sampling factors are both 1.
+	h, v int
+	// mcusX and mcusY are the image dimensions in MCUs (minimum coded units).
+	mcusX, mcusY int
+	// ri is the restart interval, in MCUs, or zero for no restarts.
+	ri int
+	// nMCU is the number of MCUs encoded so far in the current scan, and
+	// nRST is the number of restart markers written in it.
+	nMCU, nRST int
+	// prevDC are the DC components of the previous blocks, which are
+	// delta-encoded.
+	prevDC [3]int32
+	// eobRun is the number of pending End-Of-Band runs in a progressive AC
+	// scan, specified in section G.1.2.2.
+	eobRun int32
+	// coeffs holds the quantized blocks of each component for progressive
+	// encoding, in zig-zag order.
+	coeffs [3][]block
+
+	// Scratch buffers to hold the YCbCr values of an MCU.
+	// mcu holds the quantized blocks, in zig-zag order: the h*v luma blocks
+	// followed by the Cb and Cr blocks. cb and cr are in natural order.
+	mcu    [6]block
+	cb, cr [4]block
 }
 
 func (e *encoder) flush() {
@@ -244,6 +370,9 @@ func (e *encoder) writeByte(b byte) {
 // emit emits the least significant nBits bits of bits to the bit-stream.
 // The precondition is bits < 1<<nBits && nBits <= 16.
 func (e *encoder) emit(bits, nBits uint32) {
+	if e.counts != nil {
+		return
+	}
 	nBits += e.nBits
 	bits <<= 32 - nBits
 	bits |= e.bits
@@ -261,7 +390,11 @@ func (e *encoder) emit(bits, nBits uint32) {
 
 // emitHuff emits the given value with the given Huffman encoder.
 func (e *encoder) emitHuff(h huffIndex, value int32) {
-	x := theHuffmanLUT[h][value]
+	if e.counts != nil {
+		e.counts[h][value]++
+		return
+	}
+	x := e.huffLUT[h][value]
 	e.emit(x&(1<<24-1), x>>24)
 }
 
@@ -284,6 +417,56 @@ func (e *encoder) emitHuffRLE(h huffIndex, runLength, value int32) {
 	}
 }
 
+// emitEOBRun emits the pending End-Of-Band run, if any, with the given
+// Huffman encoder.
+func (e *encoder) emitEOBRun(h huffIndex) {
+	if e.eobRun == 0 {
+		return
+	}
+	nBits := uint32(bitCount[e.eobRun>>8])
+	if nBits > 0 {
+		nBits += 8
+	} else {
+		nBits = uint32(bitCount[e.eobRun])
+	}
+	nBits--
+	e.emitHuff(h, int32(nBits)<<4)
+	if nBits > 0 {
+		e.emit(uint32(e.eobRun)&(1<<nBits-1), nBits)
+	}
+	e.eobRun = 0
+}
+
+// padBits pads the last byte of entropy-coded data with 1's.
+func (e *encoder) padBits() {
+	e.emit(0x7f, 7)
+	e.bits, e.nBits = 0, 0
+}
+
+// startScan resets the state that is carried between the MCUs of a scan.
+func (e *encoder) startScan() {
+	e.nMCU, e.nRST = 0, 0
+	e.prevDC = [3]int32{}
+	e.eobRun = 0
+}
+
+// nextMCU is called before each MCU of a scan, and writes a restart marker
+// once every restart interval. h is the Huffman encoder of any pending
+// End-Of-Band run.
+func (e *encoder) nextMCU(h huffIndex) {
+	if e.ri > 0 && e.nMCU > 0 && e.nMCU%e.ri == 0 {
+		e.emitEOBRun(h)
+		if e.counts == nil {
+			e.padBits()
+			e.writeByte(0xff)
+			e.writeByte(rst0Marker + uint8(e.nRST&7))
+		}
+		e.nRST++
+		e.prevDC = [3]int32{}
+	}
+	e.nMCU++
+}
+
 // writeMarkerHeader writes the header for a marker with the given length.
 func (e *encoder) writeMarkerHeader(marker uint8, markerlen int) {
 	e.buf[0] = 0xff
@@ -293,6 +476,14 @@ func (e *encoder) writeMarkerHeader(marker uint8, markerlen int) {
 	e.write(e.buf[:4])
 }
 
+// writeSegments writes the APPn and COM segments.
+func (e *encoder) writeSegments(segs []Segment) {
+	for _, s := range segs {
+		e.writeMarkerHeader(s.Marker, 2+len(s.Data))
+		e.write(s.Data)
+	}
+}
+
 // writeDQT writes the Define Quantization Table marker.
 func (e *encoder) writeDQT() {
 	const markerlen = 2 + int(nQuantIndex)*(1+blockSize)
@@ -303,63 +494,84 @@ func (e *encoder) writeDQT() {
 	}
 }
 
-// writeSOF0 writes the Start Of Frame (Baseline Sequential) marker.
-func (e *encoder) writeSOF0(size image.Point, nComponent int) {
-	markerlen := 8 + 3*nComponent
-	e.writeMarkerHeader(sof0Marker, markerlen)
+// writeSOF writes the Start Of Frame marker, which is either sof0Marker
+// (Baseline Sequential) or sof2Marker (Progressive).
+func (e *encoder) writeSOF(marker uint8, size image.Point) {
+	markerlen := 8 + 3*e.nComponent
+	e.writeMarkerHeader(marker, markerlen)
 	e.buf[0] = 8 // 8-bit color.
 	e.buf[1] = uint8(size.Y >> 8)
 	e.buf[2] = uint8(size.Y & 0xff)
 	e.buf[3] = uint8(size.X >> 8)
 	e.buf[4] = uint8(size.X & 0xff)
-	e.buf[5] = uint8(nComponent)
-	if nComponent == 1 {
-		e.buf[6] = 1
-		// No subsampling for grayscale image.
-		e.buf[7] = 0x11
-		e.buf[8] = 0x00
-	} else {
-		for i := 0; i < nComponent; i++ {
-			e.buf[3*i+6] = uint8(i + 1)
-			// We use 4:2:0 chroma subsampling.
-			e.buf[3*i+7] = "\x22\x11\x11"[i]
-			e.buf[3*i+8] = "\x00\x01\x01"[i]
+	e.buf[5] = uint8(e.nComponent)
+	for i := 0; i < e.nComponent; i++ {
+		e.buf[3*i+6] = uint8(i + 1)
+		// Chroma is subsampled relative to luma, if at all.
+		e.buf[3*i+7] = 0x11
+		e.buf[3*i+8] = 0x01
+		if i == 0 {
+			e.buf[3*i+7] = uint8(e.h<<4 | e.v)
+			e.buf[3*i+8] = 0x00
 		}
 	}
-	e.write(e.buf[:3*(nComponent-1)+9])
+	e.write(e.buf[:3*(e.nComponent-1)+9])
 }
 
-// writeDHT writes the Define Huffman Table marker.
-func (e *encoder) writeDHT(nComponent int) {
+// writeDHT writes the Define Huffman Table marker for the given encoders.
+func (e *encoder) writeDHT(hs ...huffIndex) {
 	markerlen := 2
-	specs := theHuffmanSpec[:]
-	if nComponent == 1 {
-		// Drop the Chrominance tables.
-		specs = specs[:2]
-	}
-	for _, s := range specs {
-		markerlen += 1 + 16 + len(s.value)
+	for _, h := range hs {
+		markerlen += 1 + 16 + len(e.huffSpec[h].value)
 	}
 	e.writeMarkerHeader(dhtMarker, markerlen)
-	for i, s := range specs {
-		e.writeByte("\x00\x10\x01\x11"[i])
+	for _, h := range hs {
+		s := &e.huffSpec[h]
+		e.writeByte("\x00\x10\x01\x11"[h])
 		e.write(s.count[:])
 		e.write(s.value)
 	}
 }
 
-// writeBlock writes a block of pixel data using the given quantization table,
-// returning the post-quantized DC value of the DCT-transformed block. b is in
-// natural (not zig-zag) order.
-func (e *encoder) writeBlock(b *block, q quantIndex, prevDC int32) int32 {
+// writeDRI writes the Define Restart Interval marker.
+func (e *encoder) writeDRI() {
+	e.writeMarkerHeader(driMarker, 4)
+	e.buf[0] = uint8(e.ri >> 8)
+	e.buf[1] = uint8(e.ri & 0xff)
+	e.write(e.buf[:2])
+}
+
+// optimizeHuffman replaces the Huffman encodings that were used while
+// counting symbols with ones optimized for the counted frequencies.
+func (e *encoder) optimizeHuffman(counts *[nHuffIndex][256]int, hs ...huffIndex) {
+	for _, h := range hs {
+		e.huffSpec[h] = optimalHuffmanSpec(&counts[h])
+		e.huffLUT[h].init(e.huffSpec[h])
+	}
+}
+
+// quantize applies the forward DCT to b, which is in natural order, and
+// replaces it with its quantized coefficients in zig-zag order.
+func (e *encoder) quantize(b *block, q quantIndex) {
 	fdct(b)
+	var z block
+	for zig := 0; zig < blockSize; zig++ {
+		z[zig] = div(b[unzig[zig]], 8*int32(e.quant[q][zig]))
+	}
+	*b = z
+}
+
+// writeBlock writes a quantized block of the given component, in zig-zag
+// order, as part of a sequential scan.
+func (e *encoder) writeBlock(b *block, comp int) {
+	q := quantIndex(min(comp, 1))
 	// Emit the DC delta.
-	dc := div(b[0], 8*int32(e.quant[q][0]))
-	e.emitHuffRLE(huffIndex(2*q+0), 0, dc-prevDC)
+	e.emitHuffRLE(huffIndex(2*q+0), 0, b[0]-e.prevDC[comp])
+	e.prevDC[comp] = b[0]
 	// Emit the AC components.
 	h, runLength := huffIndex(2*q+1), int32(0)
 	for zig := 1; zig < blockSize; zig++ {
-		ac := div(b[unzig[zig]], 8*int32(e.quant[q][zig]))
+		ac := b[zig]
 		if ac == 0 {
 			runLength++
 		} else {
@@ -374,7 +586,33 @@ func (e *encoder) writeBlock(b *block, q quantIndex, prevDC int32) int32 {
 	if runLength > 0 {
 		e.emitHuff(h, 0x00)
 	}
-	return dc
+}
+
+// writeACBand writes the coefficients ss through se of a quantized block,
+// in zig-zag order, as part of a progressive AC scan, specified in section
+// G.1.2.2. A band of zeroes is coded as part of an End-Of-Band run.
+func (e *encoder) writeACBand(b *block, h huffIndex, ss, se int) {
+	runLength := int32(0)
+	for zig := ss; zig <= se; zig++ {
+		ac := b[zig]
+		if ac == 0 {
+			runLength++
+			continue
+		}
+		e.emitEOBRun(h)
+		for runLength > 15 {
+			e.emitHuff(h, 0xf0)
+			runLength -= 16
+		}
+		e.emitHuffRLE(h, runLength, ac)
+		runLength = 0
+	}
+	if runLength > 0 {
+		e.eobRun++
+		if e.eobRun == 0x7fff {
+			e.emitEOBRun(h)
+		}
+	}
 }
 
 // toYCbCr converts the 8x8 region of m whose top-left corner is p to its
@@ -472,104 +710,317 @@ func scale(dst *block, src *[4]block) {
 	}
 }
 
-// sosHeaderY is the SOS marker "\xff\xda" followed by 8 bytes:
-//   - the marker length "\x00\x08",
-//   - the number of components "\x01",
-//   - component 1 uses DC table 0 and AC table 0 "\x01\x00",
-//   - the bytes "\x00\x3f\x00". Section B.2.3 of the spec says that for
-//     sequential DCTs, those bytes (8-bit Ss, 8-bit Se, 4-bit Ah, 4-bit Al)
-//     should be 0x00, 0x3f, 0x00<<4 | 0x00.
-var sosHeaderY = []byte{
-	0xff, 0xda, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3f, 0x00,
-}
-
-// sosHeaderYCbCr is the SOS marker "\xff\xda" followed by 12 bytes:
-//   - the marker length "\x00\x0c",
-//   - the number of components "\x03",
-//   - component 1 uses DC table 0 and AC table 0 "\x01\x00",
-//   - component 2 uses DC table 1 and AC table 1 "\x02\x11",
-//   - component 3 uses DC table 1 and AC table 1 "\x03\x11",
-//   - the bytes "\x00\x3f\x00". Section B.2.3 of the spec says that for
-//     sequential DCTs, those bytes (8-bit Ss, 8-bit Se, 4-bit Ah, 4-bit Al)
-//     should be 0x00, 0x3f, 0x00<<4 | 0x00.
-var sosHeaderYCbCr = []byte{
-	0xff, 0xda, 0x00, 0x0c, 0x03, 0x01, 0x00, 0x02,
-	0x11, 0x03, 0x11, 0x00, 0x3f, 0x00,
+// scaleH scales the 16x8 region represented by the 2 src blocks to the 8x8
+// dst block.
+func scaleH(dst *block, src *[4]block) {
+	for i := 0; i < 2; i++ {
+		dstOff := i << 2
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 4; x++ {
+				j := 8*y + 2*x
+				sum := src[i][j] + src[i][j+1]
+				dst[8*y+x+dstOff] = (sum + 1) >> 1
+			}
+		}
+	}
 }
 
-// writeSOS writes the StartOfScan marker.
-func (e *encoder) writeSOS(m image.Image) {
-	switch m.(type) {
-	case *image.Gray:
-		e.write(sosHeaderY)
-	default:
-		e.write(sosHeaderYCbCr)
-	}
-	var (
-		// Scratch buffers to hold the YCbCr values.
-		// The blocks are in natural (not zig-zag) order.
-		b      block
-		cb, cr [4]block
-		// DC components are delta-encoded.
-		prevDCY, prevDCCb, prevDCCr int32
-	)
+// readMCU stores the quantized blocks of the MCU at (mx, my) in e.mcu.
+func (e *encoder) readMCU(m image.Image, mx, my int) {
 	bounds := m.Bounds()
+	x, y := bounds.Min.X+8*e.h*mx, bounds.Min.Y+8*e.v*my
 	switch m := m.(type) {
 	// TODO(wathiede): switch on m.ColorModel() instead of type.
 	case *image.Gray:
-		for y := bounds.Min.Y; y < bounds.Max.Y; y += 8 {
-			for x := bounds.Min.X; x < bounds.Max.X; x += 8 {
-				p := image.Pt(x, y)
-				grayToY(m, p, &b)
-				prevDCY = e.writeBlock(&b, 0, prevDCY)
+		grayToY(m, image.Pt(x, y), &e.mcu[0])
+		e.quantize(&e.mcu[0], quantIndexLuminance)
+		return
+	}
+	rgba, _ := m.(*image.RGBA)
+	ycbcr, _ := m.(*image.YCbCr)
+	n := e.h * e.v
+	for i := 0; i < n; i++ {
+		p := image.Pt(x+8*(i%e.h), y+8*(i/e.h))
+		if rgba != nil {
+			rgbaToYCbCr(rgba, p, &e.mcu[i], &e.cb[i], &e.cr[i])
+		} else if ycbcr != nil {
+			yCbCrToYCbCr(ycbcr, p, &e.mcu[i], &e.cb[i], &e.cr[i])
+		} else {
+			toYCbCr(m, p, &e.mcu[i], &e.cb[i], &e.cr[i])
+		}
+		e.quantize(&e.mcu[i], quantIndexLuminance)
+	}
+	switch n {
+	case 1:
+		e.mcu[n], e.mcu[n+1] = e.cb[0], e.cr[0]
+	case 2:
+		scaleH(&e.mcu[n], &e.cb)
+		scaleH(&e.mcu[n+1], &e.cr)
+	case 4:
+		scale(&e.mcu[n], &e.cb)
+		scale(&e.mcu[n+1], &e.cr)
+	}
+	e.quantize(&e.mcu[n], quantIndexChrominance)
+	e.quantize(&e.mcu[n+1], quantIndexChrominance)
+}
+
+// mcuComponent returns the component of the i'th block of an MCU.
+func (e *encoder) mcuComponent(i int) int {
+	return max(0, i-e.h*e.v+1)
+}
+
+// writeSequential writes the image data of a sequential (baseline) scan,
+// including all components.
+func (e *encoder) writeSequential(m image.Image) {
+	e.startScan()
+	n := 1
+	if e.nComponent == 3 {
+		n = e.h*e.v + 2
+	}
+	for my := 0; my < e.mcusY; my++ {
+		for mx := 0; mx < e.mcusX; mx++ {
+			e.nextMCU(0)
+			e.readMCU(m, mx, my)
+			for i := 0; i < n; i++ {
+				e.writeBlock(&e.mcu[i], e.mcuComponent(i))
 			}
 		}
-	default:
-		rgba, _ := m.(*image.RGBA)
-		ycbcr, _ := m.(*image.YCbCr)
-		for y := bounds.Min.Y; y < bounds.Max.Y; y += 16 {
-			for x := bounds.Min.X; x < bounds.Max.X; x += 16 {
-				for i := 0; i < 4; i++ {
-					xOff := (i & 1) * 8
-					yOff := (i & 2) * 4
-					p := image.Pt(x+xOff, y+yOff)
-					if rgba != nil {
-						rgbaToYCbCr(rgba, p, &b, &cb[i], &cr[i])
-					} else if ycbcr != nil {
-						yCbCrToYCbCr(ycbcr, p, &b, &cb[i], &cr[i])
-					} else {
-						toYCbCr(m, p, &b, &cb[i], &cr[i])
+	}
+}
+
+// readCoefficients stores the quantized blocks of every component in
+// e.coeffs, for progressive encoding.
+func (e *encoder) readCoefficients(m image.Image) {
+	n := 1
+	if e.nComponent == 3 {
+		n = e.h*e.v + 2
+	}
+	e.coeffs[0] = make([]block, e.mcusX*e.h*e.mcusY*e.v)
+	for c := 1; c < e.nComponent; c++ {
+		e.coeffs[c] = make([]block, e.mcusX*e.mcusY)
+	}
+	for my := 0; my < e.mcusY; my++ {
+		for mx := 0; mx < e.mcusX; mx++ {
+			e.readMCU(m, mx, my)
+			for i := 0; i < n; i++ {
+				c := e.mcuComponent(i)
+				if c == 0 {
+					bx, by := mx*e.h+i%e.h, my*e.v+i/e.h
+					e.coeffs[0][by*e.mcusX*e.h+bx] = e.mcu[i]
+				} else {
+					e.coeffs[c][my*e.mcusX+mx] = e.mcu[i]
+				}
+			}
+		}
+	}
+}
+
+// writeProgressiveDC writes the image data of the first scan of a
+// progressive image, which holds the DC coefficients of all components.
+func (e *encoder) writeProgressiveDC() {
+	e.startScan()
+	for my := 0; my < e.mcusY; my++ {
+		for mx := 0; mx < e.mcusX; mx++ {
+			e.nextMCU(0)
+			for c := 0; c < e.nComponent; c++ {
+				h, v := 1, 1
+				if c == 0 {
+					h, v = e.h, e.v
+				}
+				for j := 0; j < v; j++ {
+					for i := 0; i < h; i++ {
+						b := &e.coeffs[c][((my*v+j)*e.mcusX+mx)*h+i]
+						e.emitHuffRLE(huffIndex(2*min(c, 1)), 0, b[0]-e.prevDC[c])
+						e.prevDC[c] = b[0]
 					}
-					prevDCY = e.writeBlock(&b, 0, prevDCY)
 				}
-				scale(&b, &cb)
-				prevDCCb = e.writeBlock(&b, 1, prevDCCb)
-				scale(&b, &cr)
-				prevDCCr = e.writeBlock(&b, 1, prevDCCr)
 			}
 		}
 	}
-	// Pad the last byte with 1's.
-	e.emit(0x7f, 7)
+}
+
+// writeProgressiveAC writes the image data of a progressive scan that holds
+// the AC coefficients ss through se of component comp. Such scans are not
+// interleaved, so they only cover the blocks that overlap the image, and the
+// restart interval counts blocks instead of MCUs.
+func (e *encoder) writeProgressiveAC(size image.Point, comp, ss, se int) {
+	e.startScan()
+	h, v, stride := 1, 1, e.mcusX
+	if comp == 0 {
+		h, v, stride = e.h, e.v, e.mcusX*e.h
+	}
+	// The dimensions of the component, in pixels and then in blocks, as
+	// specified in section A.1.1.
+	bx := ((size.X*h+e.h-1)/e.h + 7) / 8
+	by := ((size.Y*v+e.v-1)/e.v + 7) / 8
+	hi := huffIndex(2*min(comp, 1) + 1)
+	for y := 0; y < by; y++ {
+		for x := 0; x < bx; x++ {
+			e.nextMCU(hi)
+			e.writeACBand(&e.coeffs[comp][y*stride+x], hi, ss, se)
+		}
+	}
+	e.emitEOBRun(hi)
+}
+
+// writeSOSHeader writes the Start Of Scan marker for a scan of the given
+// components and spectral selection. Component 0 (Y) uses DC table 0 and AC
+// table 0, and the others (Cb and Cr) use DC table 1 and AC table 1. Section
+// B.2.3 of the spec says that for sequential DCTs, Ss, Se, Ah and Al should
+// be 0, 63, 0 and 0. This encoder does not use successive approximation, so
+// Ah and Al are always 0.
+func (e *encoder) writeSOSHeader(comps []int, ss, se int) {
+	e.writeMarkerHeader(sosMarker, 6+2*len(comps))
+	e.writeByte(uint8(len(comps)))
+	for _, c := range comps {
+		e.writeByte(uint8(c + 1))
+		e.writeByte("\x00\x11\x11"[c])
+	}
+	e.writeByte(uint8(ss))
+	e.writeByte(uint8(se))
+	e.writeByte(0x00)
+}
+
+// progressiveScans are the scans of a progressive image, after the DC scan.
+// Each is a component and a range of AC coefficients.
+var progressiveScans = [...]struct{ comp, ss, se int }{
+	{0, 1, 5},
+	{2, 1, 63},
+	{1, 1, 63},
+	{0, 6, 63},
+}
+
+// writeProgressive writes the scans of a progressive image. Each scan is
+// preceded by the Huffman tables optimized for it.
+func (e *encoder) writeProgressive(m image.Image) {
+	var counts [nHuffIndex][256]int
+	e.readCoefficients(m)
+
+	comps := []int{0, 1, 2}[:e.nComponent]
+	dcTables := []huffIndex{huffIndexLuminanceDC, huffIndexChrominanceDC}[:min(e.nComponent, 2)]
+	e.counts = &counts
+	e.writeProgressiveDC()
+	e.counts = nil
+	e.optimizeHuffman(&counts, dcTables...)
+	e.writeDHT(dcTables...)
+	e.writeSOSHeader(comps, 0, 0)
+	e.writeProgressiveDC()
+	e.padBits()
+
+	size := m.Bounds().Size()
+	for _, s := range progressiveScans {
+		if s.comp >= e.nComponent {
+			continue
+		}
+		counts = [nHuffIndex][256]int{}
+		h := huffIndex(2*min(s.comp, 1) + 1)
+		e.counts = &counts
+		e.writeProgressiveAC(size, s.comp, s.ss, s.se)
+		e.counts = nil
+		e.optimizeHuffman(&counts, h)
+		e.writeDHT(h)
+		e.writeSOSHeader(comps[s.comp:s.comp+1], s.ss, s.se)
+		e.writeProgressiveAC(size, s.comp, s.ss, s.se)
+		e.padBits()
+	}
 }
 
 // DefaultQuality is the default quality encoding parameter.
 const DefaultQuality = 75
 
+// Subsampling is a chroma subsampling ratio, which determines the
+// resolution of the color (Cb and Cr) components of an encoded image
+// relative to its luma (Y) component.
+type Subsampling int
+
+const (
+	Subsampling420 Subsampling = iota // Half horizontal and half vertical resolution.
+	Subsampling422                    // Half horizontal resolution.
+	Subsampling444                    // Full resolution.
+)
+
 // Options are the encoding parameters.
 // Quality ranges from 1 to 100 inclusive, higher is better.
 type Options struct {
 	Quality int
+
+	// Subsampling is the chroma subsampling ratio of color images.
+	// The zero value is Subsampling420. Grayscale images have no chroma.
+	Subsampling Subsampling
+
+	// Progressive selects progressive rather than baseline encoding.
+	// A progressive image is written as a series of scans of increasing
+	// detail, and is typically slightly smaller. Progressive images always
+	// use optimized Huffman tables.
+	Progressive bool
+
+	// OptimizeHuffman selects Huffman tables computed for the image,
+	// rather than the typical tables given in section K.3 of the JPEG
+	// specification. This makes the output smaller, at the cost of encoding
+	// the image twice.
+	OptimizeHuffman bool
+
+	// RestartInterval is the number of MCUs (minimum coded units) between
+	// restart markers, which let a decoder recover from corrupted data.
+	// An MCU covers a 16x16, 16x8 or 8x8 pixel block, depending on the
+	// chroma subsampling. Zero means no restart markers. RestartInterval
+	// must be less than 65536.
+	RestartInterval int
+
+	// Segments are written after the Start Of Image marker, in order.
+	// Each segment's Marker must be an APPn or COM marker.
+	// To preserve the metadata of an image, such as its EXIF data and
+	// its ICC color profile, pass the segments returned by [ReadSegments].
+	Segments []Segment
 }
 
-// Encode writes the Image m to w in JPEG 4:2:0 baseline format with the given
-// options. Default parameters are used if a nil *[Options] is passed.
+// Encode writes the Image m to w in JPEG format with the given options.
+// Default parameters, which select baseline encoding with 4:2:0 chroma
+// subsampling, are used if a nil *[Options] is passed.
 func Encode(w io.Writer, m image.Image, o *Options) error {
 	b := m.Bounds()
 	if b.Dx() >= 1<<16 || b.Dy() >= 1<<16 {
 		return errors.New("jpeg: image is too large to encode")
 	}
+	var opts Options
+	if o != nil {
+		opts = *o
+	}
+	if opts.RestartInterval < 0 || opts.RestartInterval >= 1<<16 {
+		return errors.New("jpeg: invalid restart interval")
+	}
+	for _, s := range opts.Segments {
+		if !(app0Marker <= s.Marker && s.Marker <= app15Marker || s.Marker == comMarker) {
+			return errors.New("jpeg: invalid segment marker")
+		}
+		if len(s.Data) > 0xffff-2 {
+			return errors.New("jpeg: segment is too large")
+		}
+	}
 	var e encoder
+	// Compute number of components based on input image type.
+	e.nComponent, e.h, e.v = 3, 2, 2
+	switch opts.Subsampling {
+	case Subsampling420:
+	case Subsampling422:
+		e.v = 1
+	case Subsampling444:
+		e.h, e.v = 1, 1
+	default:
+		return errors.New("jpeg: invalid chroma subsampling")
+	}
+	switch m.(type) {
+	// TODO(wathiede): switch on m.ColorModel() instead of type.
+	case *image.Gray:
+		// No subsampling for grayscale image.
+		e.nComponent, e.h, e.v = 1, 1, 1
+	}
+	e.mcusX = (b.Dx() + 8*e.h - 1) / (8 * e.h)
+	e.mcusY = (b.Dy() + 8*e.v - 1) / (8 * e.v)
+	e.ri = opts.RestartInterval
+	e.huffSpec = theHuffmanSpec
+	e.huffLUT = theHuffmanLUT
+
 	if ww, ok := w.(writer); ok {
 		e.w = ww
 	} else {
@@ -605,25 +1056,54 @@ func Encode(w io.Writer, m image.Image, o *Options) error {
 			e.quant[i][j] = uint8(x)
 		}
 	}
-	// Compute number of components based on input image type.
-	nComponent := 3
-	switch m.(type) {
-	// TODO(wathiede): switch on m.ColorModel() instead of type.
-	case *image.Gray:
-		nComponent = 1
+	// The Huffman tables used by the image.
+	tables := []huffIndex{
+		huffIndexLuminanceDC,
+		huffIndexLuminanceAC,
+		huffIndexChrominanceDC,
+		huffIndexChrominanceAC,
+	}
+	if e.nComponent == 1 {
+		// Drop the Chrominance tables.
+		tables = tables[:2]
 	}
 	// Write the Start Of Image marker.
 	e.buf[0] = 0xff
 	e.buf[1] = 0xd8
 	e.write(e.buf[:2])
+	// Write the application segments.
+	e.writeSegments(opts.Segments)
 	// Write the quantization tables.
 	e.writeDQT()
-	// Write the image dimensions.
-	e.writeSOF0(b.Size(), nComponent)
-	// Write the Huffman tables.
-	e.writeDHT(nComponent)
-	// Write the image data.
-	e.writeSOS(m)
+	if opts.Progressive {
+		// Write the image dimensions.
+		e.writeSOF(sof2Marker, b.Size())
+		if e.ri > 0 {
+			e.writeDRI()
+		}
+		// Write the Huffman tables and the image data, scan by scan.
+		e.writeProgressive(m)
+	} else {
+		// Write the image dimensions.
+		e.writeSOF(sof0Marker, b.Size())
+		if opts.OptimizeHuffman {
+			var counts [nHuffIndex][256]int
+			e.counts = &counts
+			e.writeSequential(m)
+			e.counts = nil
+			e.optimizeHuffman(&counts, tables...)
+		}
+		// Write the Huffman tables.
+		e.writeDHT(tables...)
+		if e.ri > 0 {
+			e.writeDRI()
+		}
+		// Write the image data.
+		comps := []int{0, 1, 2}[:e.nComponent]
+		e.writeSOSHeader(comps, 0, blockSize-1)
+		e.writeSequential(m)
+		e.padBits()
+	}
 	// Write the End Of Image marker.
 	e.buf[0] = 0xff
 	e.buf[1] = 0xd9
@@ -243,6 +243,110 @@ func TestEncodeYCbCr(t *testing.T) {
 	}
 }
 
+// TestEncodeOptions tests that the encoding options change how an image is
+// coded, but not the decoded pixels, except for the chroma subsampling.
+func TestEncodeOptions(t *testing.T) {
+	m0, err := readPng("../testdata/video-001.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray := image.NewGray(image.Rect(0, 0, 45, 37))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * i)
+	}
+	tiny := image.NewRGBA(image.Rect(0, 0, 1, 2))
+	tiny.SetRGBA(0, 0, color.RGBA{0x80, 0x40, 0x20, 0xff})
+	tiny.SetRGBA(0, 1, color.RGBA{0x80, 0x40, 0x20, 0xff})
+	for _, m := range []image.Image{m0, gray, tiny} {
+		for _, sub := range []Subsampling{Subsampling420, Subsampling422, Subsampling444} {
+			var want image.Image
+			var baselineLen int
+			for _, o := range []Options{
+				{Quality: 90},
+				{Quality: 90, OptimizeHuffman: true},
+				{Quality: 90, RestartInterval: 1},
+				{Quality: 90, RestartInterval: 7, OptimizeHuffman: true},
+				{Quality: 90, Progressive: true},
+				{Quality: 90, Progressive: true, RestartInterval: 3},
+			} {
+				o.Subsampling = sub
+				name := fmt.Sprintf("%T %v %+v", m, m.Bounds(), o)
+				var buf bytes.Buffer
+				if err := Encode(&buf, m, &o); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				n := buf.Len()
+				m1, err := Decode(&buf)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if want == nil {
+					want, baselineLen = m1, n
+					if got := averageDelta(m, m1); got > 4<<8 {
+						t.Errorf("%s: average delta too high: %d", name, got)
+					}
+					if ycbcr, ok := m1.(*image.YCbCr); ok {
+						ratio := [...]image.YCbCrSubsampleRatio{
+							image.YCbCrSubsampleRatio420,
+							image.YCbCrSubsampleRatio422,
+							image.YCbCrSubsampleRatio444,
+						}[sub]
+						if ycbcr.SubsampleRatio != ratio {
+							t.Errorf("%s: decoded subsample ratio is %v, want %v", name, ycbcr.SubsampleRatio, ratio)
+						}
+					}
+					continue
+				}
+				if d := averageDelta(want, m1); d != 0 {
+					t.Errorf("%s: decoded image differs from baseline", name)
+				}
+				if o.OptimizeHuffman && o.RestartInterval == 0 && n > baselineLen {
+					t.Errorf("%s: optimized size %d is larger than baseline size %d", name, n, baselineLen)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeInvalidOptions(t *testing.T) {
+	m := image.NewGray(image.Rect(0, 0, 8, 8))
+	for _, o := range []Options{
+		{Subsampling: -1},
+		{Subsampling: Subsampling444 + 1},
+		{RestartInterval: -1},
+		{RestartInterval: 1 << 16},
+		{Segments: []Segment{{Marker: 0xdb}}},
+		{Segments: []Segment{{Marker: 0xe1, Data: make([]byte, 0xffff)}}},
+	} {
+		if err := Encode(io.Discard, m, &o); err == nil {
+			t.Errorf("Encode with %+v succeeded", o)
+		}
+	}
+}
+
+func TestOptimalHuffmanSpec(t *testing.T) {
+	// Frequencies that follow the Fibonacci sequence need codewords of
+	// more than 16 bits, so they must be limited.
+	var freq [256]int
+	a, b := 1, 1
+	for i := 0; i < 30; i++ {
+		freq[i] = a
+		a, b = b, a+b
+	}
+	s := optimalHuffmanSpec(&freq)
+	if len(s.value) != 30 {
+		t.Fatalf("got %d values, want 30", len(s.value))
+	}
+	// Check that the code is complete, except for the reserved codeword.
+	var kraft int
+	for i, n := range s.count {
+		kraft += int(n) << (15 - i)
+	}
+	if kraft != 1<<16-1 {
+		t.Errorf("Kraft sum is %d/65536, want 65535/65536", kraft)
+	}
+}
+
 func BenchmarkEncodeRGBA(b *testing.B) {
 	img := image.NewRGBA(image.Rect(0, 0, 640, 480))
 	bo := img.Bounds()
@@ -0,0 +1,42 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+
+	"image/internal/vp8l"
+)
+
+// Encode writes the Image m to w in lossless WEBP format.
+// Any Image may be encoded, but its width and height must each be
+// between 1 and 16384 pixels.
+func Encode(w io.Writer, m image.Image) error {
+	var buf bytes.Buffer
+	if err := vp8l.Encode(&buf, m); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	// Chunk data is padded to an even length.
+	pad := len(data) & 1
+
+	header := make([]byte, 0, 20)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(4+8+len(data)+pad))
+	header = append(header, fccWEBP[:]...)
+	header = append(header, fccVP8L[:]...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(data)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if pad != 0 {
+		data = append(data, 0)
+	}
+	_, err := w.Write(data)
+	return err
+}
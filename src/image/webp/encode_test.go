@@ -0,0 +1,175 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math/rand/v2"
+	"os"
+	"testing"
+)
+
+// diff returns an error describing the first pixel where m0 and m1 differ,
+// after converting both to non-alpha-premultiplied color.
+func diff(m0, m1 image.Image) error {
+	b0, b1 := m0.Bounds(), m1.Bounds()
+	if !b0.Size().Eq(b1.Size()) {
+		return fmt.Errorf("dimensions differ: %v vs %v", b0, b1)
+	}
+	dx := b1.Min.X - b0.Min.X
+	dy := b1.Min.Y - b0.Min.Y
+	for y := b0.Min.Y; y < b0.Max.Y; y++ {
+		for x := b0.Min.X; x < b0.Max.X; x++ {
+			c0 := color.NRGBAModel.Convert(m0.At(x, y))
+			c1 := color.NRGBAModel.Convert(m1.At(x+dx, y+dy))
+			if c0 != c1 {
+				return fmt.Errorf("colors differ at (%d, %d): %v vs %v", x, y, c0, c1)
+			}
+		}
+	}
+	return nil
+}
+
+func encodeDecode(m image.Image) (image.Image, error) {
+	var b bytes.Buffer
+	if err := Encode(&b, m); err != nil {
+		return nil, err
+	}
+	if b.Len()%2 != 0 {
+		return nil, fmt.Errorf("encoded length %d is odd", b.Len())
+	}
+	return Decode(&b)
+}
+
+func TestEncodeTestdata(t *testing.T) {
+	for _, name := range []string{
+		"blue-purple-pink",
+		"gopher-doc.1bpp",
+		"gopher-doc.8bpp",
+		"tux",
+		"video-001",
+		"yellow_rose",
+	} {
+		f, err := os.Open("../testdata/" + name + ".png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		m0, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m1, err := encodeDecode(m0)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if err := diff(m0, m1); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestEncodeSizes(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, nColors := range []int{1, 2, 3, 4, 5, 16, 17, 256, 257, 1 << 20} {
+		palette := make([]color.NRGBA, nColors)
+		for i := range palette {
+			v := r.Uint32()
+			palette[i] = color.NRGBA{uint8(v), uint8(v >> 8), uint8(v >> 16), uint8(v >> 24)}
+		}
+		for _, size := range []image.Point{{1, 1}, {1, 9}, {9, 1}, {17, 3}, {33, 31}, {100, 60}} {
+			m0 := image.NewNRGBA(image.Rect(3, -2, 3+size.X, -2+size.Y))
+			for y := m0.Rect.Min.Y; y < m0.Rect.Max.Y; y++ {
+				for x := m0.Rect.Min.X; x < m0.Rect.Max.X; x++ {
+					// Mix runs, gradients and noise.
+					i := r.IntN(nColors)
+					if x%7 < 3 {
+						i = (x + y) % nColors
+					}
+					m0.SetNRGBA(x, y, palette[i])
+				}
+			}
+			m1, err := encodeDecode(m0)
+			if err != nil {
+				t.Errorf("%d colors, %v: %v", nColors, size, err)
+				continue
+			}
+			if err := diff(m0, m1); err != nil {
+				t.Errorf("%d colors, %v: %v", nColors, size, err)
+			}
+		}
+	}
+}
+
+func TestEncodeImageTypes(t *testing.T) {
+	rect := image.Rect(0, 0, 40, 30)
+	gray := image.NewGray(rect)
+	rgba := image.NewRGBA(rect)
+	rgba64 := image.NewRGBA64(rect)
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			gray.SetGray(x, y, color.Gray{uint8(x * y)})
+			rgba.SetRGBA(x, y, color.RGBA{uint8(x), uint8(y), uint8(x * y), 0xff})
+			rgba64.SetRGBA64(x, y, color.RGBA64{uint16(x * 999), uint16(y * 999), 0, 0xffff})
+		}
+	}
+	for _, m0 := range []image.Image{gray, rgba, rgba64, rgba.SubImage(image.Rect(5, 5, 6, 6))} {
+		m1, err := encodeDecode(m0)
+		if err != nil {
+			t.Errorf("%T: %v", m0, err)
+			continue
+		}
+		if err := diff(m0, m1); err != nil {
+			t.Errorf("%T: %v", m0, err)
+		}
+	}
+}
+
+func TestEncodeInvalidSize(t *testing.T) {
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 0, 0),
+		image.Rect(0, 0, 10, 0),
+		image.Rect(0, 0, 16385, 1),
+	} {
+		if err := Encode(io.Discard, image.NewGray(r)); err == nil {
+			t.Errorf("Encode of %v image succeeded", r)
+		}
+	}
+}
+
+func TestEncodeDecodeRegistered(t *testing.T) {
+	var b bytes.Buffer
+	if err := Encode(&b, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+	cfg, format, err := image.DecodeConfig(&b)
+	if err != nil || format != "webp" || cfg.Width != 3 || cfg.Height != 2 {
+		t.Errorf("DecodeConfig = %+v, %q, %v", cfg, format, err)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	f, err := os.Open("../testdata/yellow_rose.png")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	m, err := png.Decode(f)
+	if err != nil {
+		b.Fatal(err)
+	}
+	s := m.Bounds().Size()
+	b.SetBytes(int64(s.X * s.Y * 4))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Encode(io.Discard, m)
+	}
+}
@@ -56,6 +56,7 @@ const (
 	FuncID_mstart
 	FuncID_panicwrap
 	FuncID_rt0_go
+	FuncID_runCleanups
 	FuncID_runfinq
 	FuncID_runtime_main
 	FuncID_sigpanic
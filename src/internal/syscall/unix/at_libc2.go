@@ -23,6 +23,14 @@ func Fstatat(dirfd int, path string, stat *syscall.Stat_t, flags int) error {
 	return fstatat(dirfd, path, stat, flags)
 }
 
+func Readlinkat(dirfd int, path string, buf []byte) (int, error) {
+	return readlinkat(dirfd, path, buf)
+}
+
+func Mkdirat(dirfd int, path string, mode uint32) error {
+	return mkdirat(dirfd, path, mode)
+}
+
 //go:linkname unlinkat syscall.unlinkat
 func unlinkat(dirfd int, path string, flags int) error
 
@@ -31,3 +39,9 @@ func openat(dirfd int, path string, flags int, perm uint32) (int, error)
 
 //go:linkname fstatat syscall.fstatat
 func fstatat(dirfd int, path string, stat *syscall.Stat_t, flags int) error
+
+//go:linkname readlinkat syscall.readlinkat
+func readlinkat(dirfd int, path string, buf []byte) (int, error)
+
+//go:linkname mkdirat syscall.mkdirat
+func mkdirat(dirfd int, path string, mode uint32) error
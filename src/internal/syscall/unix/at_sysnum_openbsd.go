@@ -9,6 +9,8 @@ import "syscall"
 const unlinkatTrap uintptr = syscall.SYS_UNLINKAT
 const openatTrap uintptr = syscall.SYS_OPENAT
 const fstatatTrap uintptr = syscall.SYS_FSTATAT
+const readlinkatTrap uintptr = syscall.SYS_READLINKAT
+const mkdiratTrap uintptr = syscall.SYS_MKDIRAT
 
 const (
 	AT_EACCESS          = 0x1
@@ -42,6 +42,8 @@ const (
 	http1Mode  = testMode("h1")     // HTTP/1.1
 	https1Mode = testMode("https1") // HTTPS/1.1
 	http2Mode  = testMode("h2")     // HTTP/2
+
+	http2UnencryptedMode = testMode("h2unencrypted") // HTTP/2 over TCP with prior knowledge
 )
 
 type testNotParallelOpt struct{}
@@ -149,7 +151,7 @@ func optWithServerLog(lg *log.Logger) func(*httptest.Server) {
 //	func(*httptest.Server) // run before starting the server
 //	func(*http.Transport)
 func newClientServerTest(t testing.TB, mode testMode, h Handler, opts ...any) *clientServerTest {
-	if mode == http2Mode {
+	if mode == http2Mode || mode == http2UnencryptedMode {
 		CondSkipHTTP2(t)
 	}
 	cst := &clientServerTest{
@@ -184,6 +186,9 @@ func newClientServerTest(t testing.TB, mode testMode, h Handler, opts ...any) *c
 		ExportHttp2ConfigureServer(cst.ts.Config, nil)
 		cst.ts.TLS = cst.ts.Config.TLSConfig
 		cst.ts.StartTLS()
+	case http2UnencryptedMode:
+		cst.ts.EnableHTTP2 = true
+		cst.ts.Start()
 	default:
 		t.Fatalf("unknown test mode %v", mode)
 	}
@@ -214,7 +219,7 @@ func (w testLogWriter) Write(b []byte) (int, error) {
 
 // Testing the newClientServerTest helper itself.
 func TestNewClientServerTest(t *testing.T) {
-	run(t, testNewClientServerTest, []testMode{http1Mode, https1Mode, http2Mode})
+	run(t, testNewClientServerTest, []testMode{http1Mode, https1Mode, http2Mode, http2UnencryptedMode})
 }
 func testNewClientServerTest(t *testing.T, mode testMode) {
 	var got struct {
@@ -244,6 +249,9 @@ func testNewClientServerTest(t *testing.T, mode testMode) {
 	case http2Mode:
 		wantProto = "HTTP/2.0"
 		wantTLS = true
+	case http2UnencryptedMode:
+		wantProto = "HTTP/2.0"
+		wantTLS = false
 	}
 	if got.proto != wantProto {
 		t.Errorf("req.Proto = %q, want %q", got.proto, wantProto)
@@ -253,6 +261,107 @@ func testNewClientServerTest(t *testing.T, mode testMode) {
 	}
 }
 
+func TestServerProtocols(t *testing.T) { run(t, testServerProtocols, []testMode{http2UnencryptedMode}) }
+func testServerProtocols(t *testing.T, mode testMode) {
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.Proto, body)
+	}))
+	for _, tt := range []struct {
+		protos func(*Protocols)
+		want   string
+	}{
+		{func(p *Protocols) { p.SetHTTP1(true) }, "HTTP/1.1 body"},
+		{func(p *Protocols) { p.SetUnencryptedHTTP2(true) }, "HTTP/2.0 body"},
+	} {
+		tr := &Transport{Protocols: &Protocols{}}
+		tt.protos(tr.Protocols)
+		defer tr.CloseIdleConnections()
+		for range 2 {
+			res, err := (&Client{Transport: tr}).Post(cst.ts.URL, "text/plain", strings.NewReader("body"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			if string(got) != tt.want {
+				t.Errorf("Transport.Protocols = %v: got response %q, want %q", tr.Protocols, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestServerUnencryptedHTTP2Disabled(t *testing.T) {
+	run(t, testServerUnencryptedHTTP2Disabled, []testMode{http1Mode})
+}
+func testServerUnencryptedHTTP2Disabled(t *testing.T, mode testMode) {
+	CondSkipHTTP2(t)
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {}), optQuietLog)
+	tr := &Transport{Protocols: &Protocols{}}
+	tr.Protocols.SetUnencryptedHTTP2(true)
+	defer tr.CloseIdleConnections()
+	if res, err := (&Client{Transport: tr}).Get(cst.ts.URL); err == nil {
+		res.Body.Close()
+		t.Fatalf("unencrypted HTTP/2 request to HTTP/1 server succeeded")
+	}
+}
+
+func TestTransportProtocolsHTTP1(t *testing.T) {
+	run(t, testTransportProtocolsHTTP1, []testMode{http2Mode})
+}
+func testTransportProtocolsHTTP1(t *testing.T, mode testMode) {
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	tr := &Transport{
+		TLSClientConfig: cst.tr.TLSClientConfig.Clone(),
+		Protocols:       &Protocols{},
+	}
+	tr.Protocols.SetHTTP1(true)
+	tr.Protocols.SetHTTP2(false)
+	defer tr.CloseIdleConnections()
+	if got, want := getProto(t, tr, cst.ts.URL), "HTTP/1.1"; got != want {
+		t.Errorf("Transport.Protocols = {HTTP1}: got %q, want %q", got, want)
+	}
+}
+
+func TestServerProtocolsHTTP2Only(t *testing.T) {
+	run(t, testServerProtocolsHTTP2Only, []testMode{http2Mode})
+}
+func testServerProtocolsHTTP2Only(t *testing.T, mode testMode) {
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.Proto)
+	}), func(ts *httptest.Server) {
+		ts.Config.Protocols = &Protocols{}
+		ts.Config.Protocols.SetHTTP2(true)
+	}, optQuietLog)
+	if got, want := getProto(t, cst.tr, cst.ts.URL), "HTTP/2.0"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	tr := &Transport{TLSClientConfig: cst.tr.TLSClientConfig.Clone()}
+	tr.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	defer tr.CloseIdleConnections()
+	if res, err := (&Client{Transport: tr}).Get(cst.ts.URL); err == nil {
+		res.Body.Close()
+		t.Errorf("HTTP/1 request to HTTP/2-only server succeeded")
+	}
+}
+
+func getProto(t *testing.T, rt RoundTripper, url string) string {
+	t.Helper()
+	res, err := (&Client{Transport: rt}).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	got, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(got)
+}
+
 func TestChunkedResponseHeaders(t *testing.T) { run(t, testChunkedResponseHeaders) }
 func testChunkedResponseHeaders(t *testing.T, mode testMode) {
 	log.SetOutput(io.Discard) // is noisy otherwise
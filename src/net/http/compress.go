@@ -0,0 +1,297 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"io"
+	"net/http/internal/ascii"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// CompressHandler returns a [Handler] that runs h and encodes its
+// responses with a content coding chosen from the request's
+// Accept-Encoding header, taking quality values into account.
+// The codings available are gzip, deflate, and those added with
+// [RegisterContentCoding].
+//
+// The first bytes of each response are buffered to decide whether
+// to encode it. A response is sent unencoded if:
+//   - the request method is HEAD or the request has a Range header;
+//   - the status code is 206 (Partial Content), or does not permit a body;
+//   - the response already has a Content-Encoding or Content-Range header,
+//     or a Cache-Control header with the no-transform directive;
+//   - the Content-Type, or the type detected by [DetectContentType] if
+//     none is set, denotes data that is normally already compressed,
+//     such as most images, audio, video, and archives;
+//   - the body is shorter than 512 bytes and was not flushed.
+//
+// When the response is encoded, CompressHandler removes its
+// Content-Length and Accept-Ranges headers and converts a strong ETag
+// into a weak one, because the encoded bytes differ from those the
+// headers describe.
+// In all cases it adds "Accept-Encoding" to the Vary header.
+//
+// The [ResponseWriter] passed to h supports flushing with [Flusher]
+// and [ResponseController]; flushing sends any buffered data and
+// flushes the encoder. Other [ResponseController] methods are
+// passed through to the underlying ResponseWriter. It implements
+// [io.ReaderFrom], using the underlying ResponseWriter's ReadFrom
+// method only for the part of a response that is not encoded.
+func CompressHandler(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		addVary(w.Header(), "Accept-Encoding")
+		if r.Method == "HEAD" || r.Header.Get("Range") != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		c := registeredCodings.Load().negotiate(r.Header.Values("Accept-Encoding"))
+		if c == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{rw: w, coding: c}
+		h.ServeHTTP(cw, r)
+		cw.close()
+	})
+}
+
+// addVary adds name to the Vary header in h, unless it is already listed.
+func addVary(h Header, name string) {
+	for _, v := range h.Values("Vary") {
+		for field := range strings.SplitSeq(v, ",") {
+			field = textproto.TrimString(field)
+			if field == "*" || ascii.EqualFold(field, name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
+
+// compressWriter is the ResponseWriter used by CompressHandler.
+type compressWriter struct {
+	rw     ResponseWriter
+	coding *ContentCoding
+
+	status  int            // status code passed to WriteHeader, or 0
+	buf     []byte         // body bytes written before decided
+	decided bool           // whether the header has been sent
+	zw      io.WriteCloser // encoder, or nil if the response is not encoded
+}
+
+func (cw *compressWriter) Header() Header {
+	return cw.rw.Header()
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided {
+		// Let the underlying ResponseWriter report the superfluous call.
+		cw.rw.WriteHeader(code)
+		return
+	}
+	if code >= 100 && code <= 199 && code != StatusSwitchingProtocols {
+		// Informational headers are sent immediately.
+		cw.rw.WriteHeader(code)
+		return
+	}
+	if cw.status != 0 {
+		return
+	}
+	cw.status = code
+	if !bodyAllowedForStatus(code) || code == StatusPartialContent {
+		cw.decide(true)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = StatusOK
+		}
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < sniffLen {
+			return len(p), nil
+		}
+		if err := cw.decide(false); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.zw != nil {
+		return cw.zw.Write(p)
+	}
+	return cw.rw.Write(p)
+}
+
+// ReadFrom copies src to the response. Once the response is known
+// not to be encoded, it uses the underlying ResponseWriter's ReadFrom
+// method, which may send a file without copying it.
+func (cw *compressWriter) ReadFrom(src io.Reader) (n int64, err error) {
+	if !cw.decided {
+		// Buffer enough of the body to decide whether to encode it.
+		n, err = io.CopyN(writerOnly{cw}, src, int64(sniffLen-len(cw.buf)))
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	var m int64
+	switch rf, ok := cw.rw.(io.ReaderFrom); {
+	case cw.zw != nil:
+		m, err = io.Copy(cw.zw, src)
+	case ok:
+		m, err = rf.ReadFrom(src)
+	default:
+		m, err = io.Copy(writerOnly{cw.rw}, src)
+	}
+	return n + m, err
+}
+
+// FlushError sends any buffered data to the client,
+// flushing the encoder if the response is encoded.
+func (cw *compressWriter) FlushError() error {
+	if !cw.decided {
+		if err := cw.decide(false); err != nil {
+			return err
+		}
+	}
+	if f, ok := cw.zw.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return NewResponseController(cw.rw).Flush()
+}
+
+func (cw *compressWriter) Flush() {
+	cw.FlushError()
+}
+
+func (cw *compressWriter) Unwrap() ResponseWriter {
+	return cw.rw
+}
+
+// close finishes the response after the handler returns.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 {
+			// Nothing was written. Leave it to the underlying
+			// ResponseWriter, which may have been hijacked.
+			return
+		}
+		cw.decide(true)
+	}
+	if cw.zw != nil {
+		cw.zw.Close()
+	}
+}
+
+// decide chooses whether to encode the response, sends the header,
+// and writes any buffered data. If final is true, the handler has
+// finished and the buffered data is the entire body.
+func (cw *compressWriter) decide(final bool) error {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = StatusOK
+	}
+	if cw.shouldEncode(final) {
+		zw, err := cw.coding.NewWriter(cw.rw)
+		if err == nil {
+			cw.zw = zw
+			h := cw.rw.Header()
+			h.Set("Content-Encoding", cw.coding.Name)
+			h.Del("Content-Length")
+			h.Del("Accept-Ranges")
+			if etag := h.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("Etag", "W/"+etag)
+			}
+		}
+	}
+	cw.rw.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.zw != nil {
+		_, err = cw.zw.Write(buf)
+	} else {
+		_, err = cw.rw.Write(buf)
+	}
+	return err
+}
+
+// shouldEncode reports whether the response should be encoded.
+// It sets the Content-Type header if it needs to sniff it.
+func (cw *compressWriter) shouldEncode(final bool) bool {
+	if !bodyAllowedForStatus(cw.status) || cw.status == StatusPartialContent {
+		return false
+	}
+	if final && len(cw.buf) < sniffLen {
+		return false
+	}
+	h := cw.rw.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && n < sniffLen {
+			return false
+		}
+	}
+	for _, v := range h.Values("Cache-Control") {
+		for directive := range strings.SplitSeq(v, ",") {
+			if ascii.EqualFold(textproto.TrimString(directive), "no-transform") {
+				return false
+			}
+		}
+	}
+	ctype, haveType := h["Content-Type"]
+	if !haveType {
+		if len(cw.buf) == 0 {
+			// Nothing to sniff the type from.
+			return false
+		}
+		// Sniff the type now, since the server would otherwise
+		// sniff the encoded data.
+		ct := DetectContentType(cw.buf)
+		if isCompressedContentType(ct) {
+			return false
+		}
+		h.Set("Content-Type", ct)
+		return true
+	}
+	return len(ctype) == 0 || !isCompressedContentType(ctype[0])
+}
+
+// isCompressedContentType reports whether the media type ct
+// normally holds data that is already compressed.
+func isCompressedContentType(ct string) bool {
+	mt, _, _ := strings.Cut(ct, ";")
+	mt, ok := ascii.ToLower(textproto.TrimString(mt))
+	if !ok {
+		return false
+	}
+	switch mt {
+	case "image/svg+xml", "image/bmp", "image/x-icon":
+		return false
+	case "application/gzip", "application/x-gzip",
+		"application/zip", "application/zstd",
+		"application/x-bzip2", "application/x-xz",
+		"application/x-7z-compressed",
+		"application/vnd.rar", "application/x-rar-compressed",
+		"font/woff", "font/woff2":
+		return true
+	}
+	return strings.HasPrefix(mt, "image/") ||
+		strings.HasPrefix(mt, "audio/") ||
+		strings.HasPrefix(mt, "video/")
+}
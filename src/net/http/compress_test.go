@@ -0,0 +1,506 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	. "net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiateContentCoding(t *testing.T) {
+	for _, test := range []struct {
+		accept []string
+		want   string
+	}{
+		{nil, ""},
+		{[]string{""}, ""},
+		{[]string{"gzip"}, "gzip"},
+		{[]string{"GZIP"}, "gzip"},
+		{[]string{"deflate"}, "deflate"},
+		{[]string{"br"}, ""},
+		{[]string{"gzip, deflate"}, "gzip"},
+		{[]string{"deflate, gzip"}, "gzip"},
+		{[]string{"gzip;q=0.5, deflate"}, "deflate"},
+		{[]string{"gzip; q=0.5", "deflate;q=0.8"}, "deflate"},
+		{[]string{"gzip;q=0"}, ""},
+		{[]string{"gzip;q=0.5, identity"}, ""},
+		{[]string{"gzip, identity;q=0.5"}, "gzip"},
+		{[]string{"gzip;q=1.0, identity"}, "gzip"},
+		{[]string{"*"}, "gzip"},
+		{[]string{"*;q=0.5, gzip;q=0.1"}, "deflate"},
+		{[]string{"*, gzip;q=0"}, "deflate"},
+		{[]string{"identity"}, ""},
+		{[]string{"gzip;q=2"}, ""},
+		{[]string{"gzip;q=abc"}, ""},
+		{[]string{"gzip;q=0.12345"}, ""},
+		{[]string{" , gzip ,"}, "gzip"},
+	} {
+		if got := ExportNegotiateContentCoding(test.accept...); got != test.want {
+			t.Errorf("negotiate(%q) = %q, want %q", test.accept, got, test.want)
+		}
+	}
+}
+
+var compressibleBody = strings.Repeat("Hello, world! ", 100)
+
+func TestCompressHandler(t *testing.T) {
+	pngBody := "\x89PNG\x0D\x0A\x1A\x0A" + compressibleBody
+	for _, test := range []struct {
+		name       string
+		method     string
+		reqHeader  Header
+		handler    func(w ResponseWriter, r *Request)
+		wantCoding string
+		wantHeader Header // nil values must be absent
+	}{{
+		name:       "gzip",
+		reqHeader:  Header{"Accept-Encoding": {"gzip"}},
+		wantCoding: "gzip",
+		wantHeader: Header{
+			"Content-Type":   {"text/plain; charset=utf-8"},
+			"Content-Length": nil,
+			"Vary":           {"Accept-Encoding"},
+		},
+	}, {
+		name:       "deflate",
+		reqHeader:  Header{"Accept-Encoding": {"gzip;q=0.1, deflate"}},
+		wantCoding: "deflate",
+	}, {
+		name:       "no accept-encoding",
+		wantCoding: "",
+		wantHeader: Header{"Vary": {"Accept-Encoding"}},
+	}, {
+		name:      "existing vary",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.Header().Set("Vary", "Origin, accept-encoding")
+			io.WriteString(w, compressibleBody)
+		},
+		wantCoding: "gzip",
+		wantHeader: Header{"Vary": {"Origin, accept-encoding"}},
+	}, {
+		name:      "short",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			io.WriteString(w, "short")
+		},
+		wantCoding: "",
+	}, {
+		name:      "already compressed type",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.Header().Set("Content-Type", "video/mp4")
+			io.WriteString(w, compressibleBody)
+		},
+		wantCoding: "",
+	}, {
+		name:      "sniffed compressed type",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			io.WriteString(w, pngBody)
+		},
+		wantCoding: "",
+		wantHeader: Header{"Content-Type": nil},
+	}, {
+		name:      "svg",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.Header().Set("Content-Type", "image/svg+xml")
+			io.WriteString(w, compressibleBody)
+		},
+		wantCoding: "gzip",
+	}, {
+		name:      "content-encoding set",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.Header().Set("Content-Encoding", "x-custom")
+			io.WriteString(w, compressibleBody)
+		},
+		wantCoding: "x-custom",
+	}, {
+		name:      "no-transform",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.Header().Set("Cache-Control", "public, no-transform")
+			io.WriteString(w, compressibleBody)
+		},
+		wantCoding: "",
+	}, {
+		name:       "range request",
+		reqHeader:  Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-"}},
+		wantCoding: "",
+	}, {
+		name:      "partial content",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.Header().Set("Content-Range", "bytes 0-1399/2000")
+			w.WriteHeader(StatusPartialContent)
+			io.WriteString(w, compressibleBody)
+		},
+		wantCoding: "",
+	}, {
+		name:      "strong etag",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.Header().Set("ETag", `"abc"`)
+			w.Header().Set("Content-Length", "1400")
+			io.WriteString(w, compressibleBody)
+		},
+		wantCoding: "gzip",
+		wantHeader: Header{"Etag": {`W/"abc"`}, "Content-Length": nil},
+	}, {
+		name:      "accept-ranges",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.Header().Set("Accept-Ranges", "bytes")
+			io.WriteString(w, compressibleBody)
+		},
+		wantCoding: "gzip",
+		wantHeader: Header{"Accept-Ranges": nil},
+	}, {
+		name:      "accept-ranges unencoded",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.Header().Set("Accept-Ranges", "bytes")
+			io.WriteString(w, "short")
+		},
+		wantCoding: "",
+		wantHeader: Header{"Accept-Ranges": {"bytes"}},
+	}, {
+		name:      "weak etag",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.Header().Set("ETag", `W/"abc"`)
+			io.WriteString(w, compressibleBody)
+		},
+		wantCoding: "gzip",
+		wantHeader: Header{"Etag": {`W/"abc"`}},
+	}, {
+		name:      "small content-length",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.Header().Set("Content-Length", "100")
+			io.WriteString(w, strings.Repeat("a", 100))
+		},
+		wantCoding: "",
+		wantHeader: Header{"Content-Length": {"100"}},
+	}, {
+		name:      "not found",
+		reqHeader: Header{"Accept-Encoding": {"gzip"}},
+		handler: func(w ResponseWriter, r *Request) {
+			w.WriteHeader(StatusNotFound)
+			io.WriteString(w, compressibleBody)
+		},
+		wantCoding: "gzip",
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			h := test.handler
+			wantBody := compressibleBody
+			if h == nil {
+				h = func(w ResponseWriter, r *Request) {
+					io.WriteString(w, compressibleBody)
+				}
+			}
+			var gotBody []byte
+			h2 := func(w ResponseWriter, r *Request) {
+				var buf bytes.Buffer
+				h(&bodyRecorder{w, &buf}, r)
+				gotBody = buf.Bytes()
+			}
+			method := test.method
+			if method == "" {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, "/", nil)
+			req.Header = test.reqHeader
+			if req.Header == nil {
+				req.Header = Header{}
+			}
+			rec := httptest.NewRecorder()
+			CompressHandler(HandlerFunc(h2)).ServeHTTP(rec, req)
+			res := rec.Result()
+
+			if got := res.Header.Get("Content-Encoding"); got != test.wantCoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, test.wantCoding)
+			}
+			for k, want := range test.wantHeader {
+				got, ok := res.Header[k]
+				if want == nil {
+					if ok {
+						t.Errorf("%v = %q, want absent", k, got)
+					}
+				} else if !slicesEqual(got, want) {
+					t.Errorf("%v = %q, want %q", k, got, want)
+				}
+			}
+
+			var body io.Reader = res.Body
+			var err error
+			switch test.wantCoding {
+			case "gzip":
+				body, err = gzip.NewReader(body)
+			case "deflate":
+				body, err = zlib.NewReader(body)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, gotBody) {
+				t.Errorf("decoded body is %v bytes, handler wrote %v bytes", len(got), len(gotBody))
+			}
+			if test.handler == nil && string(got) != wantBody {
+				t.Errorf("body = %q, want %q", got, wantBody)
+			}
+		})
+	}
+}
+
+// bodyRecorder is a ResponseWriter that records the body it is given.
+type bodyRecorder struct {
+	ResponseWriter
+	buf *bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *bodyRecorder) Unwrap() ResponseWriter {
+	return w.ResponseWriter
+}
+
+func slicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readFromRecorder is a ResponseWriter that counts calls to ReadFrom.
+type readFromRecorder struct {
+	*httptest.ResponseRecorder
+	calls int
+}
+
+func (w *readFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	w.calls++
+	return io.Copy(w.ResponseRecorder, src)
+}
+
+func TestCompressHandlerServeContent(t *testing.T) {
+	pngBody := "\x89PNG\x0D\x0A\x1A\x0A" + compressibleBody
+	for _, test := range []struct {
+		name         string
+		body         string
+		wantCoding   string
+		wantHeader   Header // nil values must be absent
+		wantReadFrom bool
+	}{{
+		name:       "encoded",
+		body:       compressibleBody,
+		wantCoding: "gzip",
+		wantHeader: Header{"Accept-Ranges": nil, "Content-Length": nil},
+	}, {
+		name:         "unencoded",
+		body:         pngBody,
+		wantCoding:   "",
+		wantHeader:   Header{"Accept-Ranges": {"bytes"}, "Content-Length": {strconv.Itoa(len(pngBody))}},
+		wantReadFrom: true,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+				if _, ok := w.(io.ReaderFrom); !ok {
+					t.Errorf("ResponseWriter does not implement io.ReaderFrom")
+				}
+				ServeContent(w, r, "", time.Time{}, strings.NewReader(test.body))
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := &readFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+			h.ServeHTTP(rec, req)
+			res := rec.Result()
+
+			if got := res.Header.Get("Content-Encoding"); got != test.wantCoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, test.wantCoding)
+			}
+			for k, want := range test.wantHeader {
+				got, ok := res.Header[k]
+				if want == nil {
+					if ok {
+						t.Errorf("%v = %q, want absent", k, got)
+					}
+				} else if !slicesEqual(got, want) {
+					t.Errorf("%v = %q, want %q", k, got, want)
+				}
+			}
+			if got := rec.calls > 0; got != test.wantReadFrom {
+				t.Errorf("underlying ReadFrom called %v times, want called = %v", rec.calls, test.wantReadFrom)
+			}
+
+			var body io.Reader = res.Body
+			if test.wantCoding == "gzip" {
+				var err error
+				if body, err = gzip.NewReader(body); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.body {
+				t.Errorf("body is %v bytes, want %v", len(got), len(test.body))
+			}
+		})
+	}
+}
+
+func TestCompressHandlerFlush(t *testing.T) { run(t, testCompressHandlerFlush) }
+func testCompressHandlerFlush(t *testing.T, mode testMode) {
+	release := make(chan struct{})
+	cst := newClientServerTest(t, mode, CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: first\n\n")
+		if err := NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+		<-release
+		io.WriteString(w, "data: second\n\n")
+	})))
+	defer close(release)
+
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if !res.Uncompressed {
+		t.Errorf("response was not compressed")
+	}
+	buf := make([]byte, len("data: first\n\n"))
+	if _, err := io.ReadFull(res.Body, buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), "data: first\n\n"; got != want {
+		t.Errorf("first event = %q, want %q", got, want)
+	}
+}
+
+func TestCompressHandlerTransport(t *testing.T) { run(t, testCompressHandlerTransport) }
+func testCompressHandlerTransport(t *testing.T, mode testMode) {
+	cst := newClientServerTest(t, mode, CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, compressibleBody)
+	})))
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Uncompressed {
+		t.Errorf("response was not compressed")
+	}
+	if string(got) != compressibleBody {
+		t.Errorf("body = %q, want %q", got, compressibleBody)
+	}
+}
+
+// TestTransportRegisteredContentCoding tests that the Transport requests
+// and decodes content codings added with RegisterContentCoding.
+func TestTransportRegisteredContentCoding(t *testing.T) {
+	run(t, testTransportRegisteredContentCoding, testNotParallel)
+}
+func testTransportRegisteredContentCoding(t *testing.T, mode testMode) {
+	RegisterTestContentCoding(t, ContentCoding{
+		Name: "x-flate",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return flate.NewReader(r), nil
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, flate.DefaultCompression)
+		},
+	})
+	compress := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, compressibleBody)
+	}))
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if got, want := r.Header.Get("Accept-Encoding"), "gzip, x-flate"; got != want {
+			t.Errorf("Accept-Encoding = %q, want %q", got, want)
+		}
+		// Prefer the registered coding.
+		r.Header.Set("Accept-Encoding", "gzip;q=0.5, x-flate")
+		compress.ServeHTTP(w, r)
+	}))
+	for _, explicit := range []bool{false, true} {
+		req, _ := NewRequest("GET", cst.ts.URL, nil)
+		if explicit {
+			req.Header.Set("Accept-Encoding", "gzip, x-flate")
+		}
+		res, err := cst.c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if explicit {
+			// The caller asked for compression, so the
+			// response must not be decoded.
+			if res.Uncompressed {
+				t.Errorf("explicit Accept-Encoding: response was decoded")
+			}
+			if ce := res.Header.Get("Content-Encoding"); ce != "x-flate" {
+				t.Errorf("explicit Accept-Encoding: Content-Encoding = %q, want x-flate", ce)
+			}
+			continue
+		}
+		if !res.Uncompressed {
+			t.Errorf("response was not decoded")
+		}
+		if ce := res.Header.Get("Content-Encoding"); ce != "" {
+			t.Errorf("Content-Encoding = %q, want none", ce)
+		}
+		if string(got) != compressibleBody {
+			t.Errorf("body = %q, want %q", got, compressibleBody)
+		}
+	}
+}
+
+func TestRegisterContentCodingPanics(t *testing.T) {
+	for _, name := range []string{"", "gzip", "GZIP", "identity", "bad name"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterContentCoding(%q) did not panic", name)
+				}
+			}()
+			RegisterTestContentCoding(t, ContentCoding{Name: name})
+		}()
+	}
+}
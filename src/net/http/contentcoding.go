@@ -0,0 +1,290 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http/internal/ascii"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// A ContentCoding describes a content coding that may appear in the
+// Content-Encoding and Accept-Encoding headers, as described in
+// RFC 9110, Section 8.4.1.
+//
+// The "gzip" and "deflate" codings are built in. Other codings may be
+// added with [RegisterContentCoding].
+type ContentCoding struct {
+	// Name is the coding name, such as "gzip" or "zstd".
+	// Names are compared case-insensitively.
+	Name string
+
+	// NewReader returns a reader that decodes data read from r.
+	// Closing the returned reader must not close r.
+	//
+	// If NewReader is nil, the Transport does not request or
+	// decode responses using this coding.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+
+	// NewWriter returns a writer that encodes data written to it
+	// and writes the result to w. Closing the returned writer must
+	// flush any buffered data to w but must not close w.
+	// If the returned writer has a Flush method with the signature
+	// Flush() error, it is called when the response is flushed.
+	//
+	// If NewWriter is nil, [CompressHandler] does not encode
+	// responses using this coding.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+// contentCodings is an immutable snapshot of the registered content codings.
+type contentCodings struct {
+	list []*ContentCoding // in registration order, built-in codings first
+
+	// acceptEncoding is the Accept-Encoding value sent by the Transport,
+	// and accepted holds the codings named in it.
+	acceptEncoding string
+	accepted       []*ContentCoding
+}
+
+var (
+	contentCodingsMu  sync.Mutex // guards updates to registeredCodings
+	registeredCodings atomic.Pointer[contentCodings]
+)
+
+func init() {
+	for _, c := range []ContentCoding{{
+		Name: "gzip",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+	}, {
+		// The "deflate" coding is the zlib format (RFC 1950),
+		// not a raw DEFLATE stream.
+		Name: "deflate",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return zlib.NewReader(r)
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zlib.NewWriter(w), nil
+		},
+	}} {
+		RegisterContentCoding(c)
+	}
+}
+
+// RegisterContentCoding registers a content coding for use by
+// [Transport] and [CompressHandler].
+//
+// A [Transport] that does not have DisableCompression set advertises
+// "gzip" and every registered coding with a non-nil NewReader in the
+// Accept-Encoding header of requests that do not already set one, and
+// transparently decodes responses that use one of those codings.
+// The built-in "deflate" coding is not advertised, because servers
+// disagree about whether it denotes a zlib or a raw DEFLATE stream.
+//
+// [CompressHandler] may use any registered coding with a non-nil NewWriter.
+// When a client accepts several codings with the same preference,
+// codings registered earlier are preferred; the built-in codings
+// are registered first.
+//
+// RegisterContentCoding panics if the name is empty, is not a valid
+// HTTP token, is "identity", or is already registered.
+// It is intended to be called from init functions.
+func RegisterContentCoding(c ContentCoding) {
+	if !httpguts.ValidHeaderFieldName(c.Name) {
+		panic("http: invalid content coding name " + strconv.Quote(c.Name))
+	}
+	if ascii.EqualFold(c.Name, "identity") {
+		panic("http: cannot register the identity content coding")
+	}
+	contentCodingsMu.Lock()
+	defer contentCodingsMu.Unlock()
+
+	old := registeredCodings.Load()
+	cc := &contentCodings{}
+	if old != nil {
+		for _, o := range old.list {
+			if ascii.EqualFold(o.Name, c.Name) {
+				panic("http: content coding " + strconv.Quote(c.Name) + " already registered")
+			}
+		}
+		cc.list = append(cc.list, old.list...)
+	}
+	c.Name, _ = ascii.ToLower(c.Name) // tokens are ASCII
+	cc.list = append(cc.list, &c)
+
+	var names []string
+	for _, c := range cc.list {
+		if c.NewReader == nil || c.Name == "deflate" {
+			continue
+		}
+		names = append(names, c.Name)
+		cc.accepted = append(cc.accepted, c)
+	}
+	cc.acceptEncoding = strings.Join(names, ", ")
+	registeredCodings.Store(cc)
+}
+
+// lookupAccepted returns the coding that the Transport advertised
+// with the given Content-Encoding value, or nil if there is none.
+// Only a single coding is decoded; a response with several stacked
+// codings is left as is.
+func (cc *contentCodings) lookupAccepted(contentEncoding string) *ContentCoding {
+	contentEncoding = textproto.TrimString(contentEncoding)
+	for _, c := range cc.accepted {
+		if ascii.EqualFold(c.Name, contentEncoding) {
+			return c
+		}
+	}
+	return nil
+}
+
+// onlyGzip reports whether gzip is the only coding the Transport advertises.
+// The HTTP/2 transport handles that case itself.
+func (cc *contentCodings) onlyGzip() bool {
+	return len(cc.accepted) == 1 && cc.accepted[0].Name == "gzip"
+}
+
+// negotiate returns the registered coding with a non-nil NewWriter that
+// is preferred by the given Accept-Encoding header values, or nil if the
+// response should not be encoded. RFC 9110, Section 12.5.3.
+func (cc *contentCodings) negotiate(acceptEncoding []string) *ContentCoding {
+	if len(acceptEncoding) == 0 {
+		return nil
+	}
+	type pref struct {
+		name string
+		q    float64
+	}
+	var prefs []pref
+	for _, v := range acceptEncoding {
+		for elem := range strings.SplitSeq(v, ",") {
+			name, params, _ := strings.Cut(elem, ";")
+			name = textproto.TrimString(name)
+			if name == "" {
+				continue
+			}
+			q, ok := parseQValue(params)
+			if !ok {
+				continue
+			}
+			prefs = append(prefs, pref{name, q})
+		}
+	}
+	// qvalue returns the weight of the named coding,
+	// or 0 if it is not listed.
+	qvalue := func(name string) float64 {
+		q := 0.0
+		for _, p := range prefs {
+			if ascii.EqualFold(p.name, name) {
+				return p.q
+			}
+			if p.name == "*" {
+				q = p.q
+			}
+		}
+		return q
+	}
+
+	// The identity coding is always acceptable, but only as a
+	// fallback unless the client gave it a weight.
+	identityQ := qvalue("identity")
+	var (
+		best  *ContentCoding
+		bestQ float64
+	)
+	for _, c := range cc.list {
+		if c.NewWriter == nil {
+			continue
+		}
+		if q := qvalue(c.Name); q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	if best == nil || bestQ < identityQ {
+		return nil
+	}
+	return best
+}
+
+// parseQValue parses the parameters following a coding name in an
+// Accept-Encoding element and returns its weight.
+// A missing weight is 1. It reports false if the weight is malformed.
+func parseQValue(params string) (q float64, ok bool) {
+	q = 1
+	for params != "" {
+		var p string
+		p, params, _ = strings.Cut(params, ";")
+		k, v, _ := strings.Cut(p, "=")
+		if !ascii.EqualFold(textproto.TrimString(k), "q") {
+			continue
+		}
+		v = textproto.TrimString(v)
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 || len(v) > 5 {
+			return 0, false
+		}
+		q = f
+	}
+	return q, true
+}
+
+// decodingReader wraps a response body so it can lazily
+// create a decoding reader on the first call to Read.
+type decodingReader struct {
+	_         incomparable
+	body      io.ReadCloser // underlying response body
+	newReader func(io.Reader) (io.ReadCloser, error)
+	zr        io.ReadCloser // lazily-initialized decoding reader
+	zerr      error         // any error from newReader; sticky
+}
+
+func (dr *decodingReader) Read(p []byte) (n int, err error) {
+	if dr.zr == nil {
+		if dr.zerr == nil {
+			zr, err := dr.newReader(dr.body)
+			if err != nil {
+				dr.zerr = err
+			} else {
+				dr.zr = zr
+			}
+		}
+		if dr.zerr != nil {
+			return 0, dr.zerr
+		}
+	}
+
+	if es, ok := dr.body.(*bodyEOFSignal); ok {
+		es.mu.Lock()
+		if es.closed {
+			err = errReadOnClosedResBody
+		}
+		es.mu.Unlock()
+	}
+
+	if err != nil {
+		return 0, err
+	}
+	return dr.zr.Read(p)
+}
+
+func (dr *decodingReader) Close() error {
+	if dr.zr != nil {
+		dr.zr.Close()
+	}
+	return dr.body.Close()
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"io"
+	. "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// csrfRequest returns a request to https://example.com with the given
+// method, path, Sec-Fetch-Site and Origin headers.
+func csrfRequest(method, path, secFetchSite, origin string) *Request {
+	req := httptest.NewRequest(method, "https://example.com"+path, nil)
+	if secFetchSite != "" {
+		req.Header.Set("Sec-Fetch-Site", secFetchSite)
+	}
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	return req
+}
+
+func TestCrossOriginProtectionCheck(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		method       string
+		secFetchSite string
+		origin       string
+		allowed      bool
+	}{
+		{"same-origin", "POST", "same-origin", "", true},
+		{"none", "POST", "none", "", true},
+		{"cross-site", "POST", "cross-site", "", false},
+		{"same-site", "POST", "same-site", "", false},
+		{"cross-site with matching origin", "POST", "cross-site", "https://example.com", false},
+		{"no headers", "POST", "", "", true},
+		{"origin matches host", "POST", "", "https://example.com", true},
+		{"origin matches host, http", "POST", "", "http://example.com", true},
+		{"origin mismatch", "POST", "", "https://attacker.example", false},
+		{"origin mismatch port", "POST", "", "https://example.com:8443", false},
+		{"origin null", "POST", "", "null", false},
+		{"PUT cross-site", "PUT", "cross-site", "", false},
+		{"DELETE cross-site", "DELETE", "cross-site", "", false},
+		{"PATCH origin mismatch", "PATCH", "", "https://attacker.example", false},
+		{"GET cross-site", "GET", "cross-site", "https://attacker.example", true},
+		{"HEAD cross-site", "HEAD", "cross-site", "https://attacker.example", true},
+		{"OPTIONS cross-site", "OPTIONS", "cross-site", "https://attacker.example", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var c CrossOriginProtection // zero value is usable
+			req := csrfRequest(test.method, "/", test.secFetchSite, test.origin)
+			err := c.Check(req)
+			if allowed := err == nil; allowed != test.allowed {
+				t.Errorf("Check = %v, want allowed = %v", err, test.allowed)
+			}
+		})
+	}
+}
+
+func TestCrossOriginProtectionTrustedOrigin(t *testing.T) {
+	c := NewCrossOriginProtection()
+	if err := c.AddTrustedOrigin("https://trusted.example"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddTrustedOrigin("http://localhost:8080"); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		secFetchSite string
+		origin       string
+		allowed      bool
+	}{
+		{"cross-site", "https://trusted.example", true},
+		{"", "https://trusted.example", true},
+		{"cross-site", "http://localhost:8080", true},
+		{"cross-site", "http://trusted.example", false},
+		{"cross-site", "https://trusted.example:443", false},
+		{"cross-site", "https://untrusted.example", false},
+		{"cross-site", "", false},
+	} {
+		req := csrfRequest("POST", "/", test.secFetchSite, test.origin)
+		err := c.Check(req)
+		if allowed := err == nil; allowed != test.allowed {
+			t.Errorf("Sec-Fetch-Site %q, Origin %q: Check = %v, want allowed = %v",
+				test.secFetchSite, test.origin, err, test.allowed)
+		}
+	}
+}
+
+func TestCrossOriginProtectionAddTrustedOriginErrors(t *testing.T) {
+	for _, origin := range []string{
+		"",
+		"example.com",
+		"https://",
+		"https://example.com/",
+		"https://example.com/path",
+		"https://example.com?query",
+		"https://example.com#fragment",
+		"https://user@example.com",
+		"https://example.com:port",
+		"mailto:user@example.com",
+	} {
+		if err := NewCrossOriginProtection().AddTrustedOrigin(origin); err == nil {
+			t.Errorf("AddTrustedOrigin(%q) = nil, want error", origin)
+		}
+	}
+}
+
+func TestCrossOriginProtectionBypassPattern(t *testing.T) {
+	c := NewCrossOriginProtection()
+	c.AddInsecureBypassPattern("/bypass/")
+	c.AddInsecureBypassPattern("/only/{foo}")
+	c.AddInsecureBypassPattern("POST /post-only")
+	c.AddInsecureBypassPattern("other.example/host")
+	for _, test := range []struct {
+		method  string
+		path    string
+		allowed bool
+	}{
+		{"POST", "/bypass/", true},
+		{"POST", "/bypass/sub/path", true},
+		{"POST", "/only/x", true},
+		{"POST", "/only/x/y", false},
+		{"POST", "/post-only", true},
+		{"PUT", "/post-only", false},
+		{"POST", "/host", false}, // pattern is for a different host
+		{"POST", "/other", false},
+		// Requests that ServeMux would redirect are not bypassed.
+		{"POST", "/bypass", false},
+		{"POST", "/foo/../bypass/", false},
+		{"POST", "//bypass/", false},
+	} {
+		req := csrfRequest(test.method, test.path, "cross-site", "https://attacker.example")
+		err := c.Check(req)
+		if allowed := err == nil; allowed != test.allowed {
+			t.Errorf("%v %v: Check = %v, want allowed = %v", test.method, test.path, err, test.allowed)
+		}
+	}
+
+	req := csrfRequest("POST", "/host", "cross-site", "")
+	req.Host = "other.example"
+	if err := c.Check(req); err != nil {
+		t.Errorf("POST other.example/host: Check = %v, want allowed", err)
+	}
+}
+
+func TestCrossOriginProtectionBypassPatternInvalid(t *testing.T) {
+	c := NewCrossOriginProtection()
+	c.AddInsecureBypassPattern("/a/{x}")
+	for _, pattern := range []string{
+		"",
+		"/{",
+		"/a/{y}", // conflicts with /a/{x}
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("AddInsecureBypassPattern(%q) did not panic", pattern)
+				}
+			}()
+			c.AddInsecureBypassPattern(pattern)
+		}()
+	}
+}
+
+func TestCrossOriginProtectionHandler(t *testing.T) {
+	c := NewCrossOriginProtection()
+	h := c.Handler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "ok")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, csrfRequest("POST", "/", "same-origin", ""))
+	if rec.Code != StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("same-origin request: got %v %q, want 200 %q", rec.Code, rec.Body, "ok")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, csrfRequest("POST", "/", "cross-site", ""))
+	if rec.Code != StatusForbidden {
+		t.Errorf("cross-site request: got status %v, want %v", rec.Code, StatusForbidden)
+	}
+	if strings.Contains(rec.Body.String(), "ok") {
+		t.Errorf("cross-site request reached the handler")
+	}
+
+	c.SetDenyHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if err := c.Check(r); err == nil {
+			t.Errorf("deny handler: Check = nil, want error")
+		}
+		w.WriteHeader(StatusTeapot)
+	}))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, csrfRequest("POST", "/", "cross-site", ""))
+	if rec.Code != StatusTeapot {
+		t.Errorf("cross-site request with deny handler: got status %v, want %v", rec.Code, StatusTeapot)
+	}
+
+	c.SetDenyHandler(nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, csrfRequest("POST", "/", "cross-site", ""))
+	if rec.Code != StatusForbidden {
+		t.Errorf("cross-site request after clearing deny handler: got status %v, want %v", rec.Code, StatusForbidden)
+	}
+}
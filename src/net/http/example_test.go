@@ -5,11 +5,13 @@
 package http_test
 
 import (
+	"compress/zstd"
 	"context"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/signal"
 )
@@ -193,3 +195,59 @@ func ExampleNotFoundHandler() {
 
 	log.Fatal(http.ListenAndServe(":8080", mux))
 }
+
+func ExampleRegisterContentCoding() {
+	// Add zstd to the content codings that Transport requests
+	// and decodes, and that CompressHandler may use.
+	http.RegisterContentCoding(http.ContentCoding{
+		Name: "zstd",
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(zstd.NewReader(r)), nil
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w, nil)
+		},
+	})
+
+	http.Handle("/", http.CompressHandler(http.FileServer(http.Dir("/usr/share/doc"))))
+	log.Fatal(http.ListenAndServe(":8080", nil))
+}
+
+func ExampleBuildPath() {
+	const pattern = "GET /users/{name}/files/{path...}"
+	path, err := http.BuildPath(pattern, map[string]string{
+		"name": "Zoë",
+		"path": "notes/2024 plans.txt",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(path)
+
+	// A ServeMux routes the path back to the pattern.
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest("GET", path, nil)
+	if m, ok := mux.Match(req); ok {
+		fmt.Println(m.Pattern)
+		fmt.Println(m.Values["name"], m.Values["path"])
+	}
+	// Output:
+	// /users/Zo%C3%AB/files/notes/2024%20plans.txt
+	// GET /users/{name}/files/{path...}
+	// Zoë notes/2024 plans.txt
+}
+
+func ExampleServeMux_Patterns() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("POST /items/", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/", http.NotFoundHandler())
+	for pattern := range mux.Patterns() {
+		fmt.Println(pattern)
+	}
+	// Output:
+	// GET /items/{id}
+	// POST /items/
+	// /
+}
@@ -341,3 +341,22 @@ func SetRSTAvoidanceDelay(t *testing.T, d time.Duration) {
 	})
 	rstAvoidanceDelay = d
 }
+
+// ExportNegotiateContentCoding returns the name of the content coding
+// that CompressHandler would choose for the given Accept-Encoding values.
+func ExportNegotiateContentCoding(acceptEncoding ...string) string {
+	if c := registeredCodings.Load().negotiate(acceptEncoding); c != nil {
+		return c.Name
+	}
+	return ""
+}
+
+// RegisterTestContentCoding registers c for the duration of the test.
+// Tests using it must not run in parallel with other tests.
+func RegisterTestContentCoding(t testing.TB, c ContentCoding) {
+	old := registeredCodings.Load()
+	RegisterContentCoding(c)
+	t.Cleanup(func() {
+		registeredCodings.Store(old)
+	})
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http3
+
+import (
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultAltSvcMaxAge is the lifetime of an alternative service
+// with no "ma" parameter (RFC 7838, Section 3.1).
+const defaultAltSvcMaxAge = 24 * time.Hour
+
+// parseAltSvc parses an Alt-Svc header field value (RFC 7838, Section 3).
+// It returns the alt-authority and lifetime of the first alternative
+// using HTTP/3, or reports clear if the value is "clear".
+func parseAltSvc(v string) (authority string, maxAge time.Duration, clear, ok bool) {
+	if textproto.TrimString(v) == "clear" {
+		return "", 0, true, false
+	}
+	for _, alt := range splitUnquoted(v, ',') {
+		params := splitUnquoted(alt, ';')
+		proto, value, found := strings.Cut(params[0], "=")
+		if !found || textproto.TrimString(proto) != NextProto {
+			continue
+		}
+		authority, ok := unquote(textproto.TrimString(value))
+		if !ok {
+			continue
+		}
+		maxAge := defaultAltSvcMaxAge
+		for _, p := range params[1:] {
+			k, v, _ := strings.Cut(p, "=")
+			if textproto.TrimString(k) != "ma" {
+				continue
+			}
+			v, _ = unquote(textproto.TrimString(v))
+			if n, err := strconv.ParseUint(v, 10, 32); err == nil {
+				maxAge = time.Duration(n) * time.Second
+			}
+		}
+		return authority, maxAge, false, true
+	}
+	return "", 0, false, false
+}
+
+// splitUnquoted splits s at each sep which is not within a quoted string.
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped := false, false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote returns the contents of a quoted-string (RFC 9110, Section 5.6.4).
+// Other values are returned unchanged.
+func unquote(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '"' {
+		return s, !strings.Contains(s, `"`)
+	}
+	if s[len(s)-1] != '"' {
+		return "", false
+	}
+	s = s[1 : len(s)-1]
+	if !strings.Contains(s, `\`) {
+		return s, true
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), true
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http3
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAltSvc(t *testing.T) {
+	for _, test := range []struct {
+		v         string
+		authority string
+		maxAge    time.Duration
+		clear     bool
+		ok        bool
+	}{
+		{v: `h3=":443"`, authority: ":443", maxAge: defaultAltSvcMaxAge, ok: true},
+		{v: `h3=":8443"; ma=3600`, authority: ":8443", maxAge: time.Hour, ok: true},
+		{v: `h2=":443", h3="alt.example.com:443";ma=60;persist=1`, authority: "alt.example.com:443", maxAge: time.Minute, ok: true},
+		{v: `h3-29=":443"; ma=60, h3=":444"`, authority: ":444", maxAge: defaultAltSvcMaxAge, ok: true},
+		{v: `h3=":443"; ma="120"`, authority: ":443", maxAge: 2 * time.Minute, ok: true},
+		{v: `h2="a,b;c:443", h3=":1"`, authority: ":1", maxAge: defaultAltSvcMaxAge, ok: true},
+		{v: ` clear `, clear: true},
+		{v: `h2=":443"`},
+		{v: `h3=":443`},
+		{v: ``},
+	} {
+		authority, maxAge, clear, ok := parseAltSvc(test.v)
+		if authority != test.authority || maxAge != test.maxAge || clear != test.clear || ok != test.ok {
+			t.Errorf("parseAltSvc(%q) = %q, %v, %v, %v; want %q, %v, %v, %v", test.v,
+				authority, maxAge, clear, ok,
+				test.authority, test.maxAge, test.clear, test.ok)
+		}
+	}
+}
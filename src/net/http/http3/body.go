@@ -0,0 +1,310 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http3
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/internal/ascii"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// connectionHeaders are connection-specific header fields,
+// which may not appear in HTTP/3 messages (RFC 9114, Section 4.2).
+var connectionHeaders = map[string]bool{
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// appendHeaderFields appends the fields of h to fields, with lowercase names.
+// Connection-specific fields are omitted, as are fields whose names are in
+// skip.
+func appendHeaderFields(fields []field, h http.Header, skip map[string]bool) []field {
+	for k, vv := range h {
+		if connectionHeaders[k] || skip[k] {
+			continue
+		}
+		name, ok := ascii.ToLower(k)
+		if !ok || !httpguts.ValidHeaderFieldName(k) {
+			continue
+		}
+		if name == "te" {
+			// Only "trailers" is permitted (RFC 9114, Section 4.2).
+			for _, v := range vv {
+				if ascii.EqualFold(v, "trailers") {
+					fields = append(fields, field{"te", "trailers"})
+					break
+				}
+			}
+			continue
+		}
+		for _, v := range vv {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				continue
+			}
+			fields = append(fields, field{name, v})
+		}
+	}
+	return fields
+}
+
+// A headerDecoder converts decoded field lines to pseudo-header fields
+// and an http.Header, checking that the message is well formed
+// (RFC 9114, Section 4.1.2).
+type headerDecoder struct {
+	pseudo     map[string]string
+	header     http.Header
+	trailers   bool // pseudo-header fields are not allowed
+	sawRegular bool
+}
+
+func newHeaderDecoder(trailers bool) *headerDecoder {
+	return &headerDecoder{
+		pseudo:   make(map[string]string),
+		header:   make(http.Header),
+		trailers: trailers,
+	}
+}
+
+var errMalformed = &streamError{ErrCodeMessageError, "malformed message"}
+
+func (d *headerDecoder) field(f field) error {
+	if strings.HasPrefix(f.name, ":") {
+		if d.trailers || d.sawRegular {
+			return errMalformed
+		}
+		if _, dup := d.pseudo[f.name]; dup {
+			return errMalformed
+		}
+		d.pseudo[f.name] = f.value
+		return nil
+	}
+	d.sawRegular = true
+	if !httpguts.ValidHeaderFieldName(f.name) || !httpguts.ValidHeaderFieldValue(f.value) {
+		return errMalformed
+	}
+	for i := 0; i < len(f.name); i++ {
+		if 'A' <= f.name[i] && f.name[i] <= 'Z' {
+			return errMalformed
+		}
+	}
+	k := http.CanonicalHeaderKey(f.name)
+	if connectionHeaders[k] {
+		return errMalformed
+	}
+	if k == "Te" && f.value != "trailers" {
+		return errMalformed
+	}
+	d.header[k] = append(d.header[k], f.value)
+	return nil
+}
+
+// decode decodes an encoded field section.
+func (d *headerDecoder) decode(b []byte) error {
+	if err := parseFieldSection(b, d.field); err != nil {
+		return err
+	}
+	// Multiple Cookie fields are combined (RFC 9114, Section 4.2.1).
+	if c := d.header["Cookie"]; len(c) > 1 {
+		d.header["Cookie"] = []string{strings.Join(c, "; ")}
+	}
+	return nil
+}
+
+// parseContentLength returns the value of a Content-Length header,
+// or -1 if there is none.
+func parseContentLength(h http.Header) (int64, error) {
+	vv := h["Content-Length"]
+	if len(vv) == 0 {
+		return -1, nil
+	}
+	for _, v := range vv[1:] {
+		if v != vv[0] {
+			return 0, errMalformed
+		}
+	}
+	n, err := strconv.ParseUint(vv[0], 10, 63)
+	if err != nil {
+		return 0, errMalformed
+	}
+	return int64(n), nil
+}
+
+// A bodyReader reads a message body from the DATA frames of a stream.
+type bodyReader struct {
+	st *stream
+
+	mu      sync.Mutex
+	closed  bool
+	err     error
+	remain  int64        // bytes remaining of the declared Content-Length, or -1
+	trailer *http.Header // trailers are added to this header, if non-nil
+	inData  bool         // reading the payload of a DATA frame
+	maxTrl  int64        // maximum size of the trailer section
+
+	// onEOF is called when the body has been read to completion.
+	onEOF func()
+	// onClose is called when the body is closed before it has been
+	// read to completion.
+	onClose func()
+	// mapErr, if non-nil, converts errors other than io.EOF
+	// before they are returned by Read.
+	mapErr func(error) error
+	// errClosed is returned by Read after Close.
+	errClosed error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	closed, err := b.closed, b.err
+	b.mu.Unlock()
+	if closed {
+		return 0, b.errClosed
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := b.read(p)
+	if err != nil && err != io.EOF && b.mapErr != nil {
+		err = b.mapErr(err)
+	}
+	if err != nil {
+		b.mu.Lock()
+		b.err = err
+		b.mu.Unlock()
+		if err == io.EOF && b.onEOF != nil {
+			b.onEOF()
+		}
+	}
+	return n, err
+}
+
+func (b *bodyReader) read(p []byte) (int, error) {
+	st := b.st
+	for !b.inData || st.remaining == 0 {
+		b.inData = false
+		ftype, _, err := st.readFrameHeader()
+		if err == io.EOF {
+			if b.remain > 0 {
+				return 0, errors.New("http3: message body shorter than Content-Length")
+			}
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case ftype == frameTypeData:
+			b.inData = true
+		case ftype == frameTypeHeaders:
+			if err := b.readTrailers(); err != nil {
+				return 0, err
+			}
+			// Trailers end the message.
+			if _, _, err := st.readFrameHeader(); err != io.EOF {
+				if err == nil {
+					err = &connError{ErrCodeFrameUnexpected, "frame after trailers"}
+				}
+				return 0, err
+			}
+			if b.remain > 0 {
+				return 0, errors.New("http3: message body shorter than Content-Length")
+			}
+			return 0, io.EOF
+		case ftype == frameTypeSettings || ftype == frameTypeGoaway ||
+			ftype == frameTypeCancelPush || ftype == frameTypeMaxPushID ||
+			reservedHTTP2FrameType(ftype):
+			return 0, &connError{ErrCodeFrameUnexpected, "unexpected frame on request stream"}
+		}
+		// Unknown frame types are skipped by the next readFrameHeader.
+	}
+	if int64(len(p)) > st.remaining {
+		p = p[:st.remaining]
+	}
+	n, err := st.r.Read(p)
+	st.remaining -= int64(n)
+	if b.remain >= 0 {
+		if int64(n) > b.remain {
+			return 0, &streamError{ErrCodeMessageError, "message body longer than Content-Length"}
+		}
+		b.remain -= int64(n)
+	}
+	if err == io.EOF {
+		err = st.frameErr(err)
+	}
+	return n, err
+}
+
+func (b *bodyReader) readTrailers() error {
+	p, err := b.st.readFramePayload(b.maxTrl)
+	if err != nil {
+		return err
+	}
+	d := newHeaderDecoder(true)
+	if err := d.decode(p); err != nil {
+		return err
+	}
+	if b.trailer != nil && len(d.header) > 0 {
+		if *b.trailer == nil {
+			*b.trailer = make(http.Header)
+		}
+		for k, vv := range d.header {
+			(*b.trailer)[k] = vv
+		}
+	}
+	return nil
+}
+
+func (b *bodyReader) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if b.err != io.EOF && b.onClose != nil {
+		b.onClose()
+	}
+	return nil
+}
+
+// A bodyWriter writes a message body as DATA frames.
+type bodyWriter struct {
+	st *stream
+}
+
+func (w bodyWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := w.st.writeFrame(frameTypeData, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// trailerFields returns the trailer fields of a message with header h and
+// trailers trailer. Trailers may be declared in advance in trailer,
+// or set after the header is sent using keys with http.TrailerPrefix.
+func trailerFields(h, trailer http.Header) []field {
+	var fields []field
+	for k, vv := range trailer {
+		fields = appendHeaderFields(fields, http.Header{k: vv}, nil)
+	}
+	for k, vv := range h {
+		if name, ok := strings.CutPrefix(k, http.TrailerPrefix); ok {
+			fields = appendHeaderFields(fields, http.Header{http.CanonicalHeaderKey(name): vv}, nil)
+		}
+	}
+	return fields
+}
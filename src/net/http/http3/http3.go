@@ -0,0 +1,463 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package http3 implements HTTP/3, as specified in RFC 9114,
+// on top of the QUIC transport provided by [net/quic].
+//
+// A [Server] serves HTTP/3 requests to an [net/http.Handler],
+// and a [Transport] is an [net/http.RoundTripper] which makes HTTP/3 requests.
+// Header compression uses QPACK (RFC 9204) without a dynamic table.
+//
+// Clients typically discover that a server supports HTTP/3 through an
+// Alt-Svc header (RFC 7838) in an HTTP/1 or HTTP/2 response.
+// [Server.AltSvcHandler] adds this header to responses, and a [Transport]
+// with a Fallback RoundTripper switches to HTTP/3 for an origin once the
+// header has been seen.
+package http3
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"net/quic"
+)
+
+// NextProto is the ALPN protocol identifier for HTTP/3.
+const NextProto = "h3"
+
+// HTTP/3 frame types (RFC 9114, Section 7.2).
+const (
+	frameTypeData        = 0x00
+	frameTypeHeaders     = 0x01
+	frameTypeCancelPush  = 0x03
+	frameTypeSettings    = 0x04
+	frameTypePushPromise = 0x05
+	frameTypeGoaway      = 0x07
+	frameTypeMaxPushID   = 0x0d
+)
+
+// reservedHTTP2FrameType reports whether t is an HTTP/2 frame type
+// which has no HTTP/3 equivalent, and must be treated as a connection
+// error (RFC 9114, Section 7.2.8).
+func reservedHTTP2FrameType(t uint64) bool {
+	switch t {
+	case 0x02, 0x06, 0x08, 0x09:
+		return true
+	}
+	return false
+}
+
+// Unidirectional stream types (RFC 9114, Section 6.2; RFC 9204, Section 4.2).
+const (
+	streamTypeControl      = 0x00
+	streamTypePush         = 0x01
+	streamTypeQPACKEncoder = 0x02
+	streamTypeQPACKDecoder = 0x03
+)
+
+// Settings identifiers (RFC 9114, Section 7.2.4.1; RFC 9204, Section 5).
+const (
+	settingsQPACKMaxTableCapacity = 0x01
+	settingsMaxFieldSectionSize   = 0x06
+	settingsQPACKBlockedStreams   = 0x07
+)
+
+// An ErrCode is an HTTP/3 error code (RFC 9114, Section 8.1).
+// It is used as the application error code of QUIC connection
+// and stream errors.
+type ErrCode uint64
+
+const (
+	ErrCodeNoError              ErrCode = 0x100
+	ErrCodeGeneralProtocolError ErrCode = 0x101
+	ErrCodeInternalError        ErrCode = 0x102
+	ErrCodeStreamCreationError  ErrCode = 0x103
+	ErrCodeClosedCriticalStream ErrCode = 0x104
+	ErrCodeFrameUnexpected      ErrCode = 0x105
+	ErrCodeFrameError           ErrCode = 0x106
+	ErrCodeExcessiveLoad        ErrCode = 0x107
+	ErrCodeIDError              ErrCode = 0x108
+	ErrCodeSettingsError        ErrCode = 0x109
+	ErrCodeMissingSettings      ErrCode = 0x10a
+	ErrCodeRequestRejected      ErrCode = 0x10b
+	ErrCodeRequestCancelled     ErrCode = 0x10c
+	ErrCodeRequestIncomplete    ErrCode = 0x10d
+	ErrCodeMessageError         ErrCode = 0x10e
+	ErrCodeConnectError         ErrCode = 0x10f
+	ErrCodeVersionFallback      ErrCode = 0x110
+
+	// QPACK error codes (RFC 9204, Section 6).
+	ErrCodeQPACKDecompressionFailed ErrCode = 0x200
+	ErrCodeQPACKEncoderStreamError  ErrCode = 0x201
+	ErrCodeQPACKDecoderStreamError  ErrCode = 0x202
+)
+
+var errCodeName = map[ErrCode]string{
+	ErrCodeNoError:                  "H3_NO_ERROR",
+	ErrCodeGeneralProtocolError:     "H3_GENERAL_PROTOCOL_ERROR",
+	ErrCodeInternalError:            "H3_INTERNAL_ERROR",
+	ErrCodeStreamCreationError:      "H3_STREAM_CREATION_ERROR",
+	ErrCodeClosedCriticalStream:     "H3_CLOSED_CRITICAL_STREAM",
+	ErrCodeFrameUnexpected:          "H3_FRAME_UNEXPECTED",
+	ErrCodeFrameError:               "H3_FRAME_ERROR",
+	ErrCodeExcessiveLoad:            "H3_EXCESSIVE_LOAD",
+	ErrCodeIDError:                  "H3_ID_ERROR",
+	ErrCodeSettingsError:            "H3_SETTINGS_ERROR",
+	ErrCodeMissingSettings:          "H3_MISSING_SETTINGS",
+	ErrCodeRequestRejected:          "H3_REQUEST_REJECTED",
+	ErrCodeRequestCancelled:         "H3_REQUEST_CANCELLED",
+	ErrCodeRequestIncomplete:        "H3_REQUEST_INCOMPLETE",
+	ErrCodeMessageError:             "H3_MESSAGE_ERROR",
+	ErrCodeConnectError:             "H3_CONNECT_ERROR",
+	ErrCodeVersionFallback:          "H3_VERSION_FALLBACK",
+	ErrCodeQPACKDecompressionFailed: "QPACK_DECOMPRESSION_FAILED",
+	ErrCodeQPACKEncoderStreamError:  "QPACK_ENCODER_STREAM_ERROR",
+	ErrCodeQPACKDecoderStreamError:  "QPACK_DECODER_STREAM_ERROR",
+}
+
+func (e ErrCode) String() string {
+	if s, ok := errCodeName[e]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown error code 0x%x", uint64(e))
+}
+
+func (e ErrCode) Error() string {
+	return "http3: " + e.String()
+}
+
+// A connError is an error which closes the connection.
+type connError struct {
+	code   ErrCode
+	reason string
+}
+
+func (e *connError) Error() string {
+	return fmt.Sprintf("http3: connection error: %v: %v", e.code, e.reason)
+}
+
+// A streamError is an error which resets a single request stream.
+type streamError struct {
+	code   ErrCode
+	reason string
+}
+
+func (e *streamError) Error() string {
+	return fmt.Sprintf("http3: stream error: %v: %v", e.code, e.reason)
+}
+
+// errorCode returns the error code to close a stream or connection with
+// after err.
+func errorCode(err error) ErrCode {
+	var ce *connError
+	var se *streamError
+	switch {
+	case errors.As(err, &ce):
+		return ce.code
+	case errors.As(err, &se):
+		return se.code
+	}
+	return ErrCodeInternalError
+}
+
+// abortConn closes the QUIC connection after err.
+func abortConn(qc *quic.Conn, err error) {
+	var ce *connError
+	if errors.As(err, &ce) {
+		qc.Abort(&quic.ApplicationError{Code: uint64(ce.code), Reason: ce.reason})
+		return
+	}
+	qc.Abort(&quic.ApplicationError{Code: uint64(ErrCodeInternalError)})
+}
+
+// maxVarint is the largest value representable as a variable-length integer.
+const maxVarint = 1<<62 - 1
+
+// appendVarint appends v as a QUIC variable-length integer
+// (RFC 9000, Section 16).
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return append(b, 0x40|byte(v>>8), byte(v))
+	case v < 1<<30:
+		return append(b, 0x80|byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+	return append(b, 0xc0|byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+		byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// consumeVarint parses a variable-length integer from the start of b,
+// returning the value and the number of bytes consumed,
+// or a negative length if b does not contain a complete value.
+func consumeVarint(b []byte) (v uint64, n int) {
+	if len(b) == 0 {
+		return 0, -1
+	}
+	n = 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, -1
+	}
+	v = uint64(b[0] & 0x3f)
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n
+}
+
+// readVarint reads a variable-length integer from r.
+// It returns io.EOF only if r is at EOF before the first byte.
+func readVarint(r io.ByteReader) (uint64, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	n := 1 << (c >> 6)
+	v := uint64(c & 0x3f)
+	for range n - 1 {
+		c, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// A stream reads and writes HTTP/3 frames on a QUIC stream.
+type stream struct {
+	qs *quic.Stream
+	r  *bufio.Reader
+
+	// remaining is the number of unread payload bytes in the current
+	// frame, or -1 if a frame header is expected next.
+	remaining int64
+}
+
+func newStream(qs *quic.Stream) *stream {
+	return &stream{
+		qs:        qs,
+		r:         bufio.NewReader(qs),
+		remaining: -1,
+	}
+}
+
+// readFrameHeader reads the header of the next frame.
+// It returns io.EOF if the stream ends cleanly before a frame begins.
+func (st *stream) readFrameHeader() (ftype uint64, length int64, err error) {
+	if st.remaining > 0 {
+		if _, err := st.r.Discard(int(st.remaining)); err != nil {
+			return 0, 0, st.frameErr(err)
+		}
+	}
+	st.remaining = -1
+	ftype, err = readVarint(st.r)
+	if err != nil {
+		if err == io.EOF {
+			return 0, 0, io.EOF
+		}
+		return 0, 0, st.frameErr(err)
+	}
+	n, err := readVarint(st.r)
+	if err != nil {
+		return 0, 0, st.frameErr(err)
+	}
+	if n > maxVarint {
+		return 0, 0, &connError{ErrCodeFrameError, "invalid frame length"}
+	}
+	st.remaining = int64(n)
+	return ftype, int64(n), nil
+}
+
+// readFramePayload reads the rest of the current frame's payload.
+// It returns an error if the payload is longer than max.
+func (st *stream) readFramePayload(max int64) ([]byte, error) {
+	if st.remaining > max {
+		return nil, &connError{ErrCodeExcessiveLoad, "frame too large"}
+	}
+	b := make([]byte, st.remaining)
+	if _, err := io.ReadFull(st.r, b); err != nil {
+		return nil, st.frameErr(err)
+	}
+	st.remaining = -1
+	return b, nil
+}
+
+// frameErr converts an error encountered while reading a frame.
+// A stream which ends in the middle of a frame is malformed
+// (RFC 9114, Section 7.1).
+func (st *stream) frameErr(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &connError{ErrCodeFrameError, "stream ended mid-frame"}
+	}
+	return err
+}
+
+// writeFrame writes a complete frame.
+func (st *stream) writeFrame(ftype uint64, payload []byte) error {
+	b := make([]byte, 0, 16+len(payload))
+	b = appendVarint(b, ftype)
+	b = appendVarint(b, uint64(len(payload)))
+	b = append(b, payload...)
+	_, err := st.qs.Write(b)
+	return err
+}
+
+// settings are the HTTP/3 settings sent by a peer.
+type settings struct {
+	maxFieldSectionSize int64 // -1 for unlimited
+}
+
+// appendSettingsFrame appends a SETTINGS frame advertising the given
+// maximum field section size. The QPACK dynamic table is always disabled.
+func appendSettingsFrame(b []byte, maxFieldSectionSize int64) []byte {
+	var p []byte
+	p = appendVarint(p, settingsMaxFieldSectionSize)
+	p = appendVarint(p, uint64(maxFieldSectionSize))
+	b = appendVarint(b, frameTypeSettings)
+	b = appendVarint(b, uint64(len(p)))
+	return append(b, p...)
+}
+
+// parseSettings parses the payload of a SETTINGS frame.
+func parseSettings(p []byte) (settings, error) {
+	s := settings{maxFieldSectionSize: -1}
+	seen := make(map[uint64]bool)
+	for len(p) > 0 {
+		id, n := consumeVarint(p)
+		if n < 0 {
+			return s, &connError{ErrCodeFrameError, "malformed SETTINGS frame"}
+		}
+		p = p[n:]
+		v, n := consumeVarint(p)
+		if n < 0 {
+			return s, &connError{ErrCodeFrameError, "malformed SETTINGS frame"}
+		}
+		p = p[n:]
+		if seen[id] {
+			return s, &connError{ErrCodeSettingsError, "duplicate setting"}
+		}
+		seen[id] = true
+		switch {
+		case id == settingsMaxFieldSectionSize:
+			if v <= 1<<62 {
+				s.maxFieldSectionSize = int64(v)
+			}
+		case id <= 0x05 && id != settingsQPACKMaxTableCapacity:
+			// Identifiers of HTTP/2 settings are reserved
+			// (RFC 9114, Section 7.2.4.1).
+			return s, &connError{ErrCodeSettingsError, "reserved setting"}
+		}
+		// Other settings, including the QPACK dynamic table settings,
+		// need no action: we never use the dynamic table.
+	}
+	return s, nil
+}
+
+// readControlStream reads frames from a peer's control stream, after the
+// stream type. It calls goaway for each GOAWAY frame received.
+// The first frame must be SETTINGS.
+//
+// readControlStream returns only on error. The peer may not close
+// its control stream, so its end is an error as well.
+func readControlStream(st *stream, gotSettings func(settings), goaway func(id uint64) error) error {
+	ftype, _, err := st.readFrameHeader()
+	if err != nil {
+		return controlStreamErr(err)
+	}
+	if ftype != frameTypeSettings {
+		return &connError{ErrCodeMissingSettings, "first control frame is not SETTINGS"}
+	}
+	p, err := st.readFramePayload(1 << 16)
+	if err != nil {
+		return controlStreamErr(err)
+	}
+	s, err := parseSettings(p)
+	if err != nil {
+		return err
+	}
+	gotSettings(s)
+	for {
+		ftype, _, err := st.readFrameHeader()
+		if err != nil {
+			return controlStreamErr(err)
+		}
+		switch {
+		case ftype == frameTypeSettings:
+			return &connError{ErrCodeFrameUnexpected, "duplicate SETTINGS frame"}
+		case ftype == frameTypeData || ftype == frameTypeHeaders || ftype == frameTypePushPromise || reservedHTTP2FrameType(ftype):
+			return &connError{ErrCodeFrameUnexpected, "unexpected frame on control stream"}
+		case ftype == frameTypeGoaway:
+			p, err := st.readFramePayload(8)
+			if err != nil {
+				return controlStreamErr(err)
+			}
+			id, n := consumeVarint(p)
+			if n != len(p) {
+				return &connError{ErrCodeFrameError, "malformed GOAWAY frame"}
+			}
+			if err := goaway(id); err != nil {
+				return err
+			}
+		}
+		// Other frames (CANCEL_PUSH, MAX_PUSH_ID, and unknown types)
+		// are ignored. We never use server push.
+	}
+}
+
+func controlStreamErr(err error) error {
+	if err == io.EOF {
+		return &connError{ErrCodeClosedCriticalStream, "control stream closed"}
+	}
+	return err
+}
+
+// openControlStream opens the local control stream and sends SETTINGS.
+func openControlStream(qc *quic.Conn, maxFieldSectionSize int64) (*quic.Stream, error) {
+	qs, err := qc.NewSendOnlyStream(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	b := appendVarint(nil, streamTypeControl)
+	b = appendSettingsFrame(b, maxFieldSectionSize)
+	if _, err := qs.Write(b); err != nil {
+		return nil, err
+	}
+	return qs, nil
+}
+
+// handleUniStream handles a unidirectional stream opened by the peer.
+// Control streams are passed to control. Push streams are rejected, and
+// QPACK streams are read and discarded (we do not use the dynamic table,
+// so the peer's encoder may only set a capacity of zero).
+func handleUniStream(qs *quic.Stream, control func(*stream) error) error {
+	st := newStream(qs)
+	t, err := readVarint(st.r)
+	if err != nil {
+		qs.CloseRead()
+		return nil
+	}
+	switch t {
+	case streamTypeControl:
+		return control(st)
+	case streamTypeQPACKEncoder, streamTypeQPACKDecoder:
+		_, err := io.Copy(io.Discard, st.r)
+		if err == nil {
+			return &connError{ErrCodeClosedCriticalStream, "QPACK stream closed"}
+		}
+		return nil
+	case streamTypePush:
+		return &connError{ErrCodeIDError, "unexpected push stream"}
+	}
+	// Unknown stream types must be ignored (RFC 9114, Section 6.2).
+	qs.StopSending(uint64(ErrCodeStreamCreationError))
+	return nil
+}
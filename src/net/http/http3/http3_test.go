@@ -0,0 +1,575 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http3
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/internal/testcert"
+	"net/quic"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testServerTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	cert, err := tls.X509KeyPair(testcert.LocalhostCert, testcert.LocalhostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{NextProto},
+		MinVersion:   tls.VersionTLS13,
+	}
+}
+
+func testClientTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(testcert.LocalhostCert) {
+		t.Fatal("failed to parse test certificate")
+	}
+	return &tls.Config{RootCAs: pool}
+}
+
+// newTestServer starts an HTTP/3 server on the loopback interface,
+// returning the server and its "https://host:port" URL.
+// Each opt is applied to the server before it starts serving.
+func newTestServer(t *testing.T, h http.Handler, opts ...func(*Server)) (*Server, string) {
+	t.Helper()
+	e, err := quic.Listen("udp", "127.0.0.1:0", &quic.Config{TLSConfig: testServerTLSConfig(t)})
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	s := &Server{Handler: h}
+	for _, opt := range opts {
+		opt(s)
+	}
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		s.Serve(e)
+	}()
+	t.Cleanup(func() {
+		s.Close()
+		<-served
+	})
+	return s, "https://" + e.LocalAddr().String()
+}
+
+func newTestTransport(t *testing.T) *Transport {
+	tr := &Transport{TLSClientConfig: testClientTLSConfig(t)}
+	t.Cleanup(tr.CloseIdleConnections)
+	return tr
+}
+
+func testClient(t *testing.T) *http.Client {
+	return &http.Client{Transport: newTestTransport(t)}
+}
+
+func TestGet(t *testing.T) {
+	_, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Proto != "HTTP/3.0" || r.ProtoMajor != 3 {
+			t.Errorf("server: Proto = %q, %v; want HTTP/3.0, 3", r.Proto, r.ProtoMajor)
+		}
+		if r.TLS == nil || r.TLS.NegotiatedProtocol != NextProto {
+			t.Errorf("server: TLS = %+v, want negotiated protocol %q", r.TLS, NextProto)
+		}
+		if got, want := r.Header.Get("X-Request"), "foo"; got != want {
+			t.Errorf("server: X-Request = %q, want %q", got, want)
+		}
+		if got, want := r.URL.Path, "/path"; got != want {
+			t.Errorf("server: path = %q, want %q", got, want)
+		}
+		if got, want := r.URL.Query().Get("q"), "1"; got != want {
+			t.Errorf("server: query q = %q, want %q", got, want)
+		}
+		w.Header().Set("X-Response", "bar")
+		io.WriteString(w, "<html>hello</html>")
+	}))
+	req, _ := http.NewRequest("GET", url+"/path?q=1", nil)
+	req.Header.Set("X-Request", "foo")
+	resp, err := testClient(t).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || resp.Proto != "HTTP/3.0" {
+		t.Errorf("response: %v %v, want HTTP/3.0 200", resp.Proto, resp.Status)
+	}
+	if got, want := string(body), "<html>hello</html>"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+	for k, want := range map[string]string{
+		"X-Response":     "bar",
+		"Content-Length": "18",
+		"Content-Type":   "text/html; charset=utf-8",
+	} {
+		if got := resp.Header.Get(k); got != want {
+			t.Errorf("response header %v = %q, want %q", k, got, want)
+		}
+	}
+	if resp.ContentLength != 18 {
+		t.Errorf("ContentLength = %v, want 18", resp.ContentLength)
+	}
+	if resp.Header.Get("Date") == "" {
+		t.Errorf("response has no Date header")
+	}
+}
+
+func TestStatusCodes(t *testing.T) {
+	_, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/404":
+			http.NotFound(w, r)
+		case "/204":
+			w.WriteHeader(http.StatusNoContent)
+			if _, err := w.Write([]byte("x")); err != http.ErrBodyNotAllowed {
+				t.Errorf("Write after 204 = %v, want ErrBodyNotAllowed", err)
+			}
+		case "/103":
+			w.Header().Set("Link", "</style.css>; rel=preload")
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, "created")
+		}
+	}))
+	c := testClient(t)
+	for _, test := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/404", 404, "404 page not found\n"},
+		{"/204", 204, ""},
+		{"/103", 201, "created"},
+	} {
+		resp, err := c.Get(url + test.path)
+		if err != nil {
+			t.Fatalf("%v: %v", test.path, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%v: %v", test.path, err)
+		}
+		if resp.StatusCode != test.status || string(body) != test.body {
+			t.Errorf("%v: got %v %q, want %v %q", test.path, resp.StatusCode, body, test.status, test.body)
+		}
+	}
+}
+
+func TestHead(t *testing.T) {
+	_, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello, world")
+	}))
+	resp, err := testClient(t).Head(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ContentLength != 12 {
+		t.Errorf("ContentLength = %v, want 12", resp.ContentLength)
+	}
+	if body, _ := io.ReadAll(resp.Body); len(body) != 0 {
+		t.Errorf("HEAD response body = %q, want empty", body)
+	}
+}
+
+func TestPostBodyAndTrailers(t *testing.T) {
+	_, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("server: reading body: %v", err)
+		}
+		if got, want := r.Trailer.Get("X-Request-Trailer"), "req"; got != want {
+			t.Errorf("server: request trailer = %q, want %q", got, want)
+		}
+		w.Header().Set("Trailer", "X-Declared")
+		w.Write(bytes.ToUpper(body))
+		w.Header().Set("X-Declared", "declared")
+		w.Header().Set(http.TrailerPrefix+"X-Undeclared", "undeclared")
+	}))
+	req, _ := http.NewRequest("POST", url, io.NopCloser(strings.NewReader("request body")))
+	req.Trailer = http.Header{"X-Request-Trailer": nil}
+	bodyDone := make(chan struct{})
+	req.Body = readerFunc(func(p []byte) (int, error) {
+		select {
+		case <-bodyDone:
+			return 0, io.EOF
+		default:
+		}
+		close(bodyDone)
+		req.Trailer.Set("X-Request-Trailer", "req")
+		return copy(p, "request body"), nil
+	})
+	resp, err := testClient(t).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "REQUEST BODY"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+	for k, want := range map[string]string{
+		"X-Declared":   "declared",
+		"X-Undeclared": "undeclared",
+	} {
+		if got := resp.Trailer.Get(k); got != want {
+			t.Errorf("trailer %v = %q, want %q", k, got, want)
+		}
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+func (f readerFunc) Close() error               { return nil }
+
+func TestLargeBodies(t *testing.T) {
+	const size = 4 << 20
+	_, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := io.Copy(w, r.Body)
+		if err != nil || n != size {
+			t.Errorf("server: copied %v, %v; want %v, nil", n, err, size)
+		}
+	}))
+	data := bytes.Repeat([]byte("0123456789abcdef"), size/16)
+	resp, err := testClient(t).Post(url, "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("echoed %v bytes, want the %v bytes sent", len(got), len(data))
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	_, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	}))
+	c := testClient(t)
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path := fmt.Sprintf("/%v", i)
+			resp, err := c.Get(url + path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil || string(body) != path {
+				t.Errorf("GET %v: body %q, %v", path, body, err)
+			}
+		}()
+	}
+	wg.Wait()
+	tr := c.Transport.(*Transport)
+	tr.mu.Lock()
+	n := len(tr.conns)
+	tr.mu.Unlock()
+	if n != 1 {
+		t.Errorf("transport has %v connections, want 1", n)
+	}
+}
+
+func TestRequestCancel(t *testing.T) {
+	handlerDone := make(chan error, 1)
+	_, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := w.Write(buf); err != nil {
+				handlerDone <- err
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	resp, err := testClient(t).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadFull(resp.Body, make([]byte, 4096)); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := io.Copy(io.Discard, resp.Body); !errors.Is(err, context.Canceled) {
+		t.Errorf("reading body after cancel: %v, want context.Canceled", err)
+	}
+	select {
+	case <-handlerDone:
+	case <-time.After(10 * time.Second):
+		t.Errorf("handler still writing after request was canceled")
+	}
+}
+
+func TestFullDuplex(t *testing.T) {
+	_, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if err := rc.EnableFullDuplex(); err != nil {
+			t.Errorf("EnableFullDuplex: %v", err)
+		}
+		if err := rc.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+			t.Errorf("SetReadDeadline: %v", err)
+		}
+		w.WriteHeader(200)
+		rc.Flush()
+		buf := make([]byte, 16)
+		for {
+			n, err := r.Body.Read(buf)
+			if n > 0 {
+				w.Write(bytes.ToUpper(buf[:n]))
+				if err := rc.Flush(); err != nil {
+					t.Errorf("Flush: %v", err)
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}))
+	pr, pw := io.Pipe()
+	req, _ := http.NewRequest("POST", url, pr)
+	resp, err := testClient(t).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	buf := make([]byte, 16)
+	for _, s := range []string{"one", "two", "three"} {
+		io.WriteString(pw, s)
+		n, err := io.ReadAtLeast(resp.Body, buf, len(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(buf[:n]), strings.ToUpper(s); got != want {
+			t.Errorf("read %q, want %q", got, want)
+		}
+	}
+	pw.Close()
+	if rest, err := io.ReadAll(resp.Body); err != nil || len(rest) != 0 {
+		t.Errorf("after request body closed: read %q, %v; want EOF", rest, err)
+	}
+}
+
+func TestResponseBodyCloseEarly(t *testing.T) {
+	_, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 1<<20))
+	}))
+	c := testClient(t)
+	for range 3 {
+		resp, err := c.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if _, err := resp.Body.Read(make([]byte, 1)); err != errResponseBodyClosed {
+			t.Errorf("Read after Close = %v, want %v", err, errResponseBodyClosed)
+		}
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	_, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic(http.ErrAbortHandler)
+		}
+	}))
+	c := testClient(t)
+	if resp, err := c.Get(url + "/panic"); err == nil {
+		resp.Body.Close()
+		t.Errorf("request to panicking handler succeeded")
+	}
+	// The connection remains usable.
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestRequestHeaderTooLarge(t *testing.T) {
+	_, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), func(s *Server) {
+		s.MaxHeaderBytes = 1024
+	})
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 2048))
+	resp, err := testClient(t).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("status = %v, want %v", resp.StatusCode, http.StatusRequestHeaderFieldsTooLarge)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s, url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		io.WriteString(w, "done")
+	}))
+	c := testClient(t)
+	result := make(chan error, 1)
+	go func() {
+		resp, err := c.Get(url + "/slow")
+		if err == nil {
+			var body []byte
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err == nil && string(body) != "done" {
+				err = fmt.Errorf("body %q, want %q", body, "done")
+			}
+		}
+		result <- err
+	}()
+	<-started
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- s.Shutdown(context.Background())
+	}()
+	select {
+	case err := <-shutdownDone:
+		t.Fatalf("Shutdown returned with a request in progress: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-result; err != nil {
+		t.Errorf("request in progress during Shutdown: %v", err)
+	}
+	if err := <-shutdownDone; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
+
+func TestServeAfterClose(t *testing.T) {
+	s := &Server{}
+	s.Close()
+	e, err := quic.Listen("udp", "127.0.0.1:0", &quic.Config{TLSConfig: testServerTLSConfig(t)})
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	if err := s.Serve(e); err != http.ErrServerClosed {
+		t.Errorf("Serve after Close = %v, want ErrServerClosed", err)
+	}
+}
+
+// newAltSvcServers starts an HTTP/3 server, and an HTTP/2 server which
+// advertises it. It returns the HTTP/2 server.
+func newAltSvcServers(t *testing.T, h http.Handler) *httptest.Server {
+	s3, _ := newTestServer(t, h)
+	ts := httptest.NewUnstartedServer(s3.AltSvcHandler(h))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestAltSvcUpgrade(t *testing.T) {
+	ts := newAltSvcServers(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	tr := newTestTransport(t)
+	tr.Fallback = ts.Client().Transport
+	c := &http.Client{Transport: tr}
+	for _, want := range []string{"HTTP/2.0", "HTTP/3.0", "HTTP/3.0"} {
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != want || resp.Proto != want {
+			t.Errorf("request made with %v, server saw %v; want %v", resp.Proto, string(body), want)
+		}
+		if want == "HTTP/2.0" && !strings.HasPrefix(resp.Header.Get("Alt-Svc"), `h3=":`) {
+			t.Errorf("HTTP/2 response Alt-Svc = %q, want h3 alternative", resp.Header.Get("Alt-Svc"))
+		}
+		if want == "HTTP/3.0" && resp.Header.Get("Alt-Svc") != "" {
+			t.Errorf("HTTP/3 response has Alt-Svc %q, want none", resp.Header.Get("Alt-Svc"))
+		}
+	}
+}
+
+func TestAltSvcUnreachable(t *testing.T) {
+	// Find a UDP port with nothing listening on it.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+	pc.Close()
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%v"`, port))
+		io.WriteString(w, r.Proto)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	tr := newTestTransport(t)
+	tr.QUICConfig = &quic.Config{HandshakeTimeout: 100 * time.Millisecond}
+	tr.Fallback = ts.Client().Transport
+	c := &http.Client{Transport: tr}
+	for range 2 {
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.Proto != "HTTP/2.0" {
+			t.Errorf("request made with %v, want fallback to HTTP/2.0", resp.Proto)
+		}
+	}
+}
+
+func TestTransportNoFallbackScheme(t *testing.T) {
+	tr := &Transport{}
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if _, err := tr.RoundTrip(req); err == nil {
+		t.Errorf("RoundTrip with http scheme and no fallback succeeded")
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http3
+
+import "golang.org/x/net/http2/hpack"
+
+// This file implements QPACK field compression (RFC 9204) using only the
+// static table. We advertise a dynamic table capacity of zero, so peers
+// may not reference the dynamic table, and we never insert into theirs.
+
+// A field is a header or trailer field line.
+type field struct {
+	name, value string
+}
+
+var (
+	errQPACK      = &connError{ErrCodeQPACKDecompressionFailed, "malformed field section"}
+	errDynamicRef = &connError{ErrCodeQPACKDecompressionFailed, "reference to dynamic table"}
+)
+
+// appendFieldSection appends an encoded field section to b
+// (RFC 9204, Section 4.5).
+func appendFieldSection(b []byte, fields []field) []byte {
+	// Required Insert Count and Delta Base are both zero.
+	b = append(b, 0, 0)
+	for _, f := range fields {
+		b = appendFieldLine(b, f)
+	}
+	return b
+}
+
+func appendFieldLine(b []byte, f field) []byte {
+	idx, nameOnly, ok := staticIndex(f.name, f.value)
+	switch {
+	case ok && !nameOnly:
+		// Indexed Field Line, static table (RFC 9204, Section 4.5.2).
+		return appendPrefixedInt(b, 0b1100_0000, 6, uint64(idx))
+	case ok:
+		// Literal Field Line with Name Reference, static table
+		// (RFC 9204, Section 4.5.4).
+		b = appendPrefixedInt(b, 0b0101_0000, 4, uint64(idx))
+		return appendPrefixedString(b, 0, 7, f.value)
+	}
+	// Literal Field Line with Literal Name (RFC 9204, Section 4.5.6).
+	b = appendPrefixedString(b, 0b0010_0000, 3, f.name)
+	return appendPrefixedString(b, 0, 7, f.value)
+}
+
+// appendPrefixedInt appends an integer with an n-bit prefix
+// (RFC 7541, Section 5.1). first holds the bits preceding the prefix.
+func appendPrefixedInt(b []byte, first byte, n uint, v uint64) []byte {
+	max := uint64(1)<<n - 1
+	if v < max {
+		return append(b, first|byte(v))
+	}
+	b = append(b, first|byte(max))
+	v -= max
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// appendPrefixedString appends a string literal whose length has an n-bit
+// prefix (RFC 7541, Section 5.2). The string is Huffman encoded if that
+// makes it shorter; the Huffman flag is the bit preceding the prefix.
+func appendPrefixedString(b []byte, first byte, n uint, s string) []byte {
+	if l := hpack.HuffmanEncodeLength(s); l < uint64(len(s)) {
+		b = appendPrefixedInt(b, first|1<<n, n, l)
+		return hpack.AppendHuffmanString(b, s)
+	}
+	b = appendPrefixedInt(b, first, n, uint64(len(s)))
+	return append(b, s...)
+}
+
+// consumePrefixedInt parses an integer with an n-bit prefix from b.
+// It returns the integer and the number of bytes consumed,
+// or a negative length if b does not contain a valid integer.
+func consumePrefixedInt(b []byte, n uint) (v uint64, l int) {
+	if len(b) == 0 {
+		return 0, -1
+	}
+	max := uint64(1)<<n - 1
+	v = uint64(b[0]) & max
+	if v < max {
+		return v, 1
+	}
+	var shift uint
+	for i := 1; i < len(b); i++ {
+		c := b[i]
+		if shift > 56 {
+			return 0, -1
+		}
+		v += uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v, i + 1
+		}
+		shift += 7
+	}
+	return 0, -1
+}
+
+// consumePrefixedString parses a string literal with an n-bit length
+// prefix from b. The Huffman flag is the bit preceding the prefix.
+func consumePrefixedString(b []byte, n uint) (s string, l int, err error) {
+	if len(b) == 0 {
+		return "", 0, errQPACK
+	}
+	huff := b[0]&(1<<n) != 0
+	size, l := consumePrefixedInt(b, n)
+	if l < 0 || uint64(len(b)-l) < size {
+		return "", 0, errQPACK
+	}
+	data := b[l : l+int(size)]
+	l += int(size)
+	if !huff {
+		return string(data), l, nil
+	}
+	s, err = hpack.HuffmanDecodeToString(data)
+	if err != nil {
+		return "", 0, errQPACK
+	}
+	return s, l, nil
+}
+
+// parseFieldSection decodes an encoded field section, calling f for each
+// field line. It returns an error if the section refers to the dynamic table.
+func parseFieldSection(b []byte, f func(field) error) error {
+	// Required Insert Count.
+	ric, n := consumePrefixedInt(b, 8)
+	if n < 0 {
+		return errQPACK
+	}
+	if ric != 0 {
+		return errDynamicRef
+	}
+	b = b[n:]
+	// Sign bit and Delta Base, which are meaningless without a dynamic table.
+	if _, n = consumePrefixedInt(b, 7); n < 0 {
+		return errQPACK
+	}
+	b = b[n:]
+	for len(b) > 0 {
+		var (
+			fl  field
+			err error
+		)
+		c := b[0]
+		switch {
+		case c&0b1000_0000 != 0:
+			// Indexed Field Line.
+			if c&0b0100_0000 == 0 {
+				return errDynamicRef
+			}
+			idx, n := consumePrefixedInt(b, 6)
+			if n < 0 || idx >= uint64(len(staticTable)) {
+				return errQPACK
+			}
+			b = b[n:]
+			fl = staticTable[idx]
+		case c&0b0100_0000 != 0:
+			// Literal Field Line with Name Reference.
+			if c&0b0001_0000 == 0 {
+				return errDynamicRef
+			}
+			idx, n := consumePrefixedInt(b, 4)
+			if n < 0 || idx >= uint64(len(staticTable)) {
+				return errQPACK
+			}
+			b = b[n:]
+			fl.name = staticTable[idx].name
+			if fl.value, n, err = consumePrefixedString(b, 7); err != nil {
+				return err
+			}
+			b = b[n:]
+		case c&0b0010_0000 != 0:
+			// Literal Field Line with Literal Name.
+			if fl.name, n, err = consumePrefixedString(b, 3); err != nil {
+				return err
+			}
+			b = b[n:]
+			if fl.value, n, err = consumePrefixedString(b, 7); err != nil {
+				return err
+			}
+			b = b[n:]
+		default:
+			// Indexed Field Line with Post-Base Index, and
+			// Literal Field Line with Post-Base Name Reference.
+			return errDynamicRef
+		}
+		if err := f(fl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staticIndex returns the index of the static table entry matching
+// name and value. If no entry matches both, it returns an entry matching
+// the name alone, with nameOnly set.
+func staticIndex(name, value string) (idx int, nameOnly, ok bool) {
+	if i, ok := staticByField[field{name, value}]; ok {
+		return i, false, true
+	}
+	if i, ok := staticByName[name]; ok {
+		return i, true, true
+	}
+	return 0, false, false
+}
+
+var (
+	staticByField = make(map[field]int, len(staticTable))
+	staticByName  = make(map[string]int, len(staticTable))
+)
+
+func init() {
+	for i, f := range staticTable {
+		staticByField[f] = i
+		if _, ok := staticByName[f.name]; !ok {
+			staticByName[f.name] = i
+		}
+	}
+}
+
+// staticTable is the QPACK static table (RFC 9204, Appendix A).
+var staticTable = [...]field{
+	{":authority", ""},
+	{":path", "/"},
+	{"age", "0"},
+	{"content-disposition", ""},
+	{"content-length", "0"},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"referer", ""},
+	{"set-cookie", ""},
+	{":method", "CONNECT"},
+	{":method", "DELETE"},
+	{":method", "GET"},
+	{":method", "HEAD"},
+	{":method", "OPTIONS"},
+	{":method", "POST"},
+	{":method", "PUT"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "103"},
+	{":status", "200"},
+	{":status", "304"},
+	{":status", "404"},
+	{":status", "503"},
+	{"accept", "*/*"},
+	{"accept", "application/dns-message"},
+	{"accept-encoding", "gzip, deflate, br"},
+	{"accept-ranges", "bytes"},
+	{"access-control-allow-headers", "cache-control"},
+	{"access-control-allow-headers", "content-type"},
+	{"access-control-allow-origin", "*"},
+	{"cache-control", "max-age=0"},
+	{"cache-control", "max-age=2592000"},
+	{"cache-control", "max-age=604800"},
+	{"cache-control", "no-cache"},
+	{"cache-control", "no-store"},
+	{"cache-control", "public, max-age=31536000"},
+	{"content-encoding", "br"},
+	{"content-encoding", "gzip"},
+	{"content-type", "application/dns-message"},
+	{"content-type", "application/javascript"},
+	{"content-type", "application/json"},
+	{"content-type", "application/x-www-form-urlencoded"},
+	{"content-type", "image/gif"},
+	{"content-type", "image/jpeg"},
+	{"content-type", "image/png"},
+	{"content-type", "text/css"},
+	{"content-type", "text/html; charset=utf-8"},
+	{"content-type", "text/plain"},
+	{"content-type", "text/plain;charset=utf-8"},
+	{"range", "bytes=0-"},
+	{"strict-transport-security", "max-age=31536000"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains; preload"},
+	{"vary", "accept-encoding"},
+	{"vary", "origin"},
+	{"x-content-type-options", "nosniff"},
+	{"x-xss-protection", "1; mode=block"},
+	{":status", "100"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "302"},
+	{":status", "400"},
+	{":status", "403"},
+	{":status", "421"},
+	{":status", "425"},
+	{":status", "500"},
+	{"accept-language", ""},
+	{"access-control-allow-credentials", "FALSE"},
+	{"access-control-allow-credentials", "TRUE"},
+	{"access-control-allow-headers", "*"},
+	{"access-control-allow-methods", "get"},
+	{"access-control-allow-methods", "get, post, options"},
+	{"access-control-allow-methods", "options"},
+	{"access-control-expose-headers", "content-length"},
+	{"access-control-request-headers", "content-type"},
+	{"access-control-request-method", "get"},
+	{"access-control-request-method", "post"},
+	{"alt-svc", "clear"},
+	{"authorization", ""},
+	{"content-security-policy", "script-src 'none'; object-src 'none'; base-uri 'none'"},
+	{"early-data", "1"},
+	{"expect-ct", ""},
+	{"forwarded", ""},
+	{"if-range", ""},
+	{"origin", ""},
+	{"purpose", "prefetch"},
+	{"server", ""},
+	{"timing-allow-origin", "*"},
+	{"upgrade-insecure-requests", "1"},
+	{"user-agent", ""},
+	{"x-forwarded-for", ""},
+	{"x-frame-options", "deny"},
+	{"x-frame-options", "sameorigin"},
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeFields(b []byte) ([]field, error) {
+	var fields []field
+	err := parseFieldSection(b, func(f field) error {
+		fields = append(fields, f)
+		return nil
+	})
+	return fields, err
+}
+
+func TestQPACKDecodeRFCExample(t *testing.T) {
+	// RFC 9204, Appendix B.1: a literal field line with a static
+	// name reference.
+	b, _ := hex.DecodeString("0000510b2f696e6465782e68746d6c")
+	fields, err := decodeFields(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []field{{":path", "/index.html"}}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("decoded %v, want %v", fields, want)
+	}
+}
+
+func TestQPACKRoundTrip(t *testing.T) {
+	fields := []field{
+		{":method", "GET"},                     // static indexed
+		{":path", "/index.html"},               // static name reference
+		{":status", "200"},                     // static indexed
+		{"content-type", "text/plain"},         // static indexed
+		{"x-custom", "value"},                  // literal name
+		{"x-empty", ""},                        // empty value
+		{"x-long", strings.Repeat("abc", 100)}, // multi-byte length prefix
+		{"x-binary", "\x01\x7f\xff"},           // Huffman would be longer
+	}
+	b := appendFieldSection(nil, fields)
+	got, err := decodeFields(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, fields) {
+		t.Errorf("round trip:\n got %q\nwant %q", got, fields)
+	}
+	if !bytes.Equal(b[:4], []byte{0, 0, 0xc0 | 17, 0x50 | 1}) {
+		t.Errorf("encoding begins with %x, want static references", b[:4])
+	}
+}
+
+func TestQPACKPrefixedInt(t *testing.T) {
+	for _, v := range []uint64{0, 1, 30, 31, 32, 126, 127, 128, 1337, 1 << 20, 1<<62 - 1} {
+		for _, n := range []uint{3, 4, 5, 6, 7, 8} {
+			b := appendPrefixedInt([]byte{}, 0, n, v)
+			got, l := consumePrefixedInt(b, n)
+			if got != v || l != len(b) {
+				t.Errorf("%v-bit prefix: encoded %v as %x, decoded %v, %v", n, v, b, got, l)
+			}
+		}
+	}
+	// RFC 7541, Appendix C.1.2: 1337 with a 5-bit prefix.
+	if b := appendPrefixedInt(nil, 0, 5, 1337); !bytes.Equal(b, []byte{0x1f, 0x9a, 0x0a}) {
+		t.Errorf("1337 with 5-bit prefix = %x, want 1f9a0a", b)
+	}
+}
+
+func TestQPACKRejectsDynamicTable(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		b    []byte
+	}{
+		{"required insert count", []byte{0x01, 0x00}},
+		{"dynamic indexed", []byte{0x00, 0x00, 0x80}},
+		{"dynamic name reference", []byte{0x00, 0x00, 0x40, 0x00}},
+		{"post-base indexed", []byte{0x00, 0x00, 0x10}},
+		{"post-base name reference", []byte{0x00, 0x00, 0x00, 0x00}},
+	} {
+		if _, err := decodeFields(test.b); errorCode(err) != ErrCodeQPACKDecompressionFailed {
+			t.Errorf("%v: error %v, want QPACK_DECOMPRESSION_FAILED", test.name, err)
+		}
+	}
+}
+
+func TestQPACKMalformed(t *testing.T) {
+	valid := appendFieldSection(nil, []field{{"x-custom", "value"}})
+	for i := 3; i < len(valid); i++ {
+		if fields, err := decodeFields(valid[:i]); err == nil {
+			t.Errorf("truncated to %v bytes: decoded %q, want error", i, fields)
+		}
+	}
+	if _, err := decodeFields([]byte{0x00, 0x00, 0xc0 | 0x3f, 0x7f}); err == nil {
+		t.Errorf("static index out of range: got nil error")
+	}
+}
@@ -0,0 +1,823 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http3
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/quic"
+	"net/textproto"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Server is an HTTP/3 server.
+//
+// The zero value for Server is a valid configuration.
+type Server struct {
+	// Addr optionally specifies the UDP address for the server to listen on,
+	// in the form "host:port". If empty, ":https" (port 443) is used.
+	Addr string
+
+	// Handler is the handler to invoke. If nil, http.DefaultServeMux is used.
+	Handler http.Handler
+
+	// TLSConfig optionally provides a TLS configuration for use
+	// by ListenAndServe and ListenAndServeTLS. The NextProtos and
+	// MinVersion fields are overridden, since HTTP/3 requires
+	// the "h3" protocol and TLS 1.3.
+	TLSConfig *tls.Config
+
+	// QUICConfig optionally configures the QUIC connections accepted
+	// by ListenAndServe and ListenAndServeTLS. Its TLSConfig field
+	// is ignored.
+	QUICConfig *quic.Config
+
+	// MaxHeaderBytes controls the maximum number of bytes the server will
+	// read parsing a request header or trailer section.
+	// If zero, http.DefaultMaxHeaderBytes is used.
+	MaxHeaderBytes int
+
+	// ErrorLog specifies an optional logger for errors in handlers.
+	// If nil, logging is done via the log package's standard logger.
+	ErrorLog *log.Logger
+
+	mu           sync.Mutex
+	endpoints    map[*quic.Endpoint]struct{}
+	conns        map[*serverConn]struct{}
+	shuttingDown bool
+}
+
+// ListenAndServe listens on the UDP network address s.Addr and then calls
+// Serve to handle HTTP/3 requests on incoming connections.
+//
+// s.TLSConfig must contain at least one certificate
+// or provide a GetCertificate or GetConfigForClient function.
+//
+// ListenAndServe always returns a non-nil error. After Shutdown or Close,
+// the returned error is http.ErrServerClosed.
+func (s *Server) ListenAndServe() error {
+	return s.ListenAndServeTLS("", "")
+}
+
+// ListenAndServeTLS is like ListenAndServe, but loads the server's
+// certificate from certFile and keyFile, as with
+// [net/http.Server.ListenAndServeTLS].
+func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	if s.shuttingDownNow() {
+		return http.ErrServerClosed
+	}
+	config := s.TLSConfig.Clone()
+	if config == nil {
+		config = &tls.Config{}
+	}
+	configHasCert := len(config.Certificates) > 0 || config.GetCertificate != nil || config.GetConfigForClient != nil
+	if !configHasCert || certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		config.Certificates = append([]tls.Certificate{cert}, config.Certificates...)
+	}
+	config.NextProtos = []string{NextProto}
+	config.MinVersion = tls.VersionTLS13
+
+	var qconfig quic.Config
+	if s.QUICConfig != nil {
+		qconfig = *s.QUICConfig
+	}
+	qconfig.TLSConfig = config
+
+	addr := s.Addr
+	if addr == "" {
+		addr = ":https"
+	}
+	e, err := quic.Listen("udp", addr, &qconfig)
+	if err != nil {
+		return err
+	}
+	return s.Serve(e)
+}
+
+// Serve accepts incoming connections on the endpoint e, creating a new
+// service goroutine for each. The endpoint must have been created with
+// a TLS configuration that negotiates the "h3" protocol.
+//
+// Serve always returns a non-nil error and closes e.
+// After Shutdown or Close, the returned error is http.ErrServerClosed.
+func (s *Server) Serve(e *quic.Endpoint) error {
+	if !s.trackEndpoint(e, true) {
+		closeNow(e)
+		return http.ErrServerClosed
+	}
+	defer s.trackEndpoint(e, false)
+	for {
+		qc, err := e.Accept(context.Background())
+		if err != nil {
+			closeNow(e)
+			if s.shuttingDownNow() {
+				return http.ErrServerClosed
+			}
+			return err
+		}
+		sc := &serverConn{
+			srv:         s,
+			qc:          qc,
+			maxStreamID: -1,
+		}
+		if !s.trackConn(sc, true) {
+			qc.Abort(&quic.ApplicationError{Code: uint64(ErrCodeNoError)})
+			continue
+		}
+		go sc.serve()
+	}
+}
+
+// closeNow closes an endpoint without waiting for its connections
+// to close gracefully.
+func closeNow(e *quic.Endpoint) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return e.Close(ctx)
+}
+
+// Close immediately closes all endpoints and connections.
+// It does not wait for active requests to complete.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	endpoints := make([]*quic.Endpoint, 0, len(s.endpoints))
+	for e := range s.endpoints {
+		endpoints = append(endpoints, e)
+	}
+	for sc := range s.conns {
+		sc.qc.Abort(&quic.ApplicationError{Code: uint64(ErrCodeNoError)})
+	}
+	s.mu.Unlock()
+	var err error
+	for _, e := range endpoints {
+		if cerr := closeNow(e); cerr != nil && err == nil && !errors.Is(cerr, context.Canceled) {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Shutdown gracefully shuts down the server without interrupting any
+// active requests. It sends a GOAWAY frame on every connection, waits
+// for their active requests to complete, and then closes all endpoints.
+//
+// If ctx expires before the shutdown is complete, Shutdown closes
+// all endpoints and connections and returns the context's error.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	conns := make([]*serverConn, 0, len(s.conns))
+	for sc := range s.conns {
+		conns = append(conns, sc)
+	}
+	s.mu.Unlock()
+	for _, sc := range conns {
+		sc.shutdown()
+	}
+
+	const pollInterval = 10 * time.Millisecond
+	timer := time.NewTimer(pollInterval)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		n := len(s.conns)
+		s.mu.Unlock()
+		if n == 0 {
+			return s.Close()
+		}
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return ctx.Err()
+		case <-timer.C:
+			timer.Reset(pollInterval)
+		}
+	}
+}
+
+// AltSvcHandler returns a handler which advertises the server's HTTP/3
+// endpoint in an Alt-Svc header (RFC 7838) on responses to requests made
+// with earlier HTTP versions, and then calls h.
+//
+// The advertised port is that of an endpoint the server is serving on,
+// or of s.Addr if it is not yet serving.
+func (s *Server) AltSvcHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			if port := s.port(); port != 0 {
+				w.Header().Add("Alt-Svc", fmt.Sprintf(`%s=":%d"; ma=%d`, NextProto, port, altSvcMaxAge))
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// altSvcMaxAge is the lifetime in seconds of advertised alternative services.
+const altSvcMaxAge = 86400
+
+// port returns the UDP port the server is serving on.
+func (s *Server) port() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for e := range s.endpoints {
+		if a, ok := e.LocalAddr().(*net.UDPAddr); ok {
+			return a.Port
+		}
+	}
+	addr := s.Addr
+	if addr == "" {
+		return 443
+	}
+	_, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0
+	}
+	port, err := net.LookupPort("udp", p)
+	if err != nil {
+		return 0
+	}
+	return port
+}
+
+func (s *Server) shuttingDownNow() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shuttingDown
+}
+
+// trackEndpoint adds or removes an endpoint from the set of endpoints
+// being served. It reports false if the endpoint cannot be added
+// because the server is shutting down.
+func (s *Server) trackEndpoint(e *quic.Endpoint, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.endpoints, e)
+		return true
+	}
+	if s.shuttingDown {
+		return false
+	}
+	if s.endpoints == nil {
+		s.endpoints = make(map[*quic.Endpoint]struct{})
+	}
+	s.endpoints[e] = struct{}{}
+	return true
+}
+
+// trackConn is like trackEndpoint, for connections.
+func (s *Server) trackConn(sc *serverConn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.conns, sc)
+		return true
+	}
+	if s.shuttingDown {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[*serverConn]struct{})
+	}
+	s.conns[sc] = struct{}{}
+	return true
+}
+
+func (s *Server) maxHeaderBytes() int64 {
+	if s.MaxHeaderBytes > 0 {
+		return int64(s.MaxHeaderBytes)
+	}
+	return http.DefaultMaxHeaderBytes
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// A serverConn is a server connection.
+type serverConn struct {
+	srv        *Server
+	qc         *quic.Conn
+	sawControl atomic.Bool
+
+	mu          sync.Mutex
+	control     *stream // our control stream
+	active      int     // requests in progress
+	maxStreamID int64   // largest request stream ID accepted, or -1
+	goaway      bool    // GOAWAY sent
+	closeTimer  *time.Timer
+}
+
+// goAwayTimeout is how long the server waits after a connection with
+// a pending GOAWAY becomes idle for the client to close it, before
+// closing it itself. Closing the connection discards any response data
+// not yet delivered, so the client is given the chance to go first.
+var goAwayTimeout = 1 * time.Second
+
+func (sc *serverConn) serve() {
+	defer sc.srv.trackConn(sc, false)
+	qs, err := openControlStream(sc.qc, sc.srv.maxHeaderBytes())
+	if err != nil {
+		sc.qc.Abort(nil)
+		return
+	}
+	sc.mu.Lock()
+	sc.control = newStream(qs)
+	sc.mu.Unlock()
+	for {
+		qs, err := sc.qc.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		if qs.IsReadOnly() {
+			go func() {
+				if err := handleUniStream(qs, sc.handleControlStream); err != nil {
+					abortConn(sc.qc, err)
+				}
+			}()
+			continue
+		}
+		sc.mu.Lock()
+		if sc.goaway {
+			sc.mu.Unlock()
+			qs.StopSending(uint64(ErrCodeRequestRejected))
+			qs.Reset(uint64(ErrCodeRequestRejected))
+			continue
+		}
+		sc.active++
+		sc.maxStreamID = qs.ID()
+		sc.mu.Unlock()
+		go sc.serveRequest(qs)
+	}
+}
+
+func (sc *serverConn) handleControlStream(st *stream) error {
+	if sc.sawControl.Swap(true) {
+		return &connError{ErrCodeStreamCreationError, "duplicate control stream"}
+	}
+	return readControlStream(st, func(settings) {}, func(pushID uint64) error {
+		// A client's GOAWAY carries a push ID. We never push,
+		// so there is nothing to do.
+		return nil
+	})
+}
+
+// shutdown sends a GOAWAY frame and closes the connection once
+// all active requests are done.
+func (sc *serverConn) shutdown() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.goaway {
+		return
+	}
+	sc.goaway = true
+	if sc.control != nil {
+		// Requests on streams with lower IDs than the one in GOAWAY
+		// may be processed (RFC 9114, Section 5.2).
+		id := sc.maxStreamID + 4
+		if sc.maxStreamID < 0 {
+			id = 0
+		}
+		sc.control.writeFrame(frameTypeGoaway, appendVarint(nil, uint64(id)))
+	}
+	sc.closeIfIdleLocked()
+}
+
+func (sc *serverConn) closeIfIdleLocked() {
+	if sc.goaway && sc.active == 0 && sc.closeTimer == nil {
+		sc.closeTimer = time.AfterFunc(goAwayTimeout, func() {
+			sc.qc.Abort(&quic.ApplicationError{Code: uint64(ErrCodeNoError)})
+		})
+	}
+}
+
+func (sc *serverConn) requestDone() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.active--
+	sc.closeIfIdleLocked()
+}
+
+// errHeaderTooLarge is returned by readRequest when the request header
+// is larger than the server's limit.
+var errHeaderTooLarge = errors.New("http3: request header too large")
+
+func (sc *serverConn) serveRequest(qs *quic.Stream) {
+	defer sc.requestDone()
+	st := newStream(qs)
+	req, body, err := sc.readRequest(st)
+	if err != nil {
+		switch {
+		case err == errHeaderTooLarge:
+			fields := []field{{":status", strconv.Itoa(http.StatusRequestHeaderFieldsTooLarge)}}
+			st.writeFrame(frameTypeHeaders, appendFieldSection(nil, fields))
+			qs.StopSending(uint64(ErrCodeNoError))
+			qs.CloseWrite()
+		case errors.As(err, new(*connError)):
+			abortConn(sc.qc, err)
+		default:
+			code := errorCode(err)
+			qs.StopSending(uint64(code))
+			qs.Reset(uint64(code))
+		}
+		return
+	}
+	ctx := context.WithValue(context.Background(), http.LocalAddrContextKey, sc.qc.LocalAddr())
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req = req.WithContext(ctx)
+	body.trailer = &req.Trailer
+
+	rw := &responseWriter{
+		sc:            sc,
+		st:            st,
+		req:           req,
+		body:          body,
+		handlerHeader: make(http.Header),
+		contentLength: -1,
+	}
+	rw.bw = bufio.NewWriterSize(chunkWriter{rw}, 4096)
+	if !sc.runHandler(rw, req) {
+		return
+	}
+	rw.finish()
+}
+
+// runHandler calls the handler, recovering from panics.
+// It reports whether the handler returned normally.
+func (sc *serverConn) runHandler(rw *responseWriter, req *http.Request) (ok bool) {
+	defer func() {
+		if ok {
+			return
+		}
+		e := recover()
+		if e != http.ErrAbortHandler {
+			const size = 64 << 10
+			buf := make([]byte, size)
+			buf = buf[:runtime.Stack(buf, false)]
+			sc.srv.logf("http3: panic serving %v: %v\n%s", req.RemoteAddr, e, buf)
+		}
+		rw.st.qs.StopSending(uint64(ErrCodeInternalError))
+		rw.st.qs.Reset(uint64(ErrCodeInternalError))
+	}()
+	h := sc.srv.Handler
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	h.ServeHTTP(rw, req)
+	return true
+}
+
+// readRequest reads a request header from st.
+func (sc *serverConn) readRequest(st *stream) (*http.Request, *bodyReader, error) {
+	maxHeader := sc.srv.maxHeaderBytes()
+	var p []byte
+	for p == nil {
+		ftype, length, err := st.readFrameHeader()
+		if err == io.EOF {
+			return nil, nil, &streamError{ErrCodeRequestIncomplete, "no request header"}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case ftype == frameTypeHeaders:
+			if length > maxHeader {
+				return nil, nil, errHeaderTooLarge
+			}
+			if p, err = st.readFramePayload(maxHeader); err != nil {
+				return nil, nil, err
+			}
+		case ftype == frameTypeData || ftype == frameTypeSettings || ftype == frameTypeGoaway ||
+			ftype == frameTypeCancelPush || ftype == frameTypeMaxPushID || reservedHTTP2FrameType(ftype):
+			return nil, nil, &connError{ErrCodeFrameUnexpected, "unexpected frame on request stream"}
+		}
+	}
+	d := newHeaderDecoder(false)
+	if err := d.decode(p); err != nil {
+		return nil, nil, err
+	}
+	for k := range d.pseudo {
+		switch k {
+		case ":method", ":scheme", ":authority", ":path":
+		default:
+			return nil, nil, errMalformed
+		}
+	}
+	method := d.pseudo[":method"]
+	scheme := d.pseudo[":scheme"]
+	path := d.pseudo[":path"]
+	authority := d.pseudo[":authority"]
+	if authority == "" {
+		authority = d.header.Get("Host")
+	}
+	d.header.Del("Host")
+
+	var (
+		u          *url.URL
+		requestURI string
+	)
+	if method == "CONNECT" {
+		if scheme != "" || path != "" || authority == "" {
+			return nil, nil, errMalformed
+		}
+		u = &url.URL{Host: authority}
+		requestURI = authority
+	} else {
+		if method == "" || scheme == "" || path == "" {
+			return nil, nil, errMalformed
+		}
+		var err error
+		if u, err = url.ParseRequestURI(path); err != nil {
+			return nil, nil, errMalformed
+		}
+		requestURI = path
+	}
+
+	contentLength, err := parseContentLength(d.header)
+	if err != nil {
+		return nil, nil, err
+	}
+	var trailer http.Header
+	for _, v := range d.header["Trailer"] {
+		for _, k := range strings.Split(v, ",") {
+			k = http.CanonicalHeaderKey(textproto.TrimString(k))
+			if k == "" {
+				continue
+			}
+			if trailer == nil {
+				trailer = make(http.Header)
+			}
+			trailer[k] = nil
+		}
+	}
+
+	body := &bodyReader{
+		st:        st,
+		remain:    contentLength,
+		maxTrl:    maxHeader,
+		errClosed: http.ErrBodyReadAfterClose,
+	}
+	state := sc.qc.ConnectionState()
+	req := &http.Request{
+		Method:        method,
+		URL:           u,
+		Proto:         "HTTP/3.0",
+		ProtoMajor:    3,
+		ProtoMinor:    0,
+		Header:        d.header,
+		Body:          body,
+		ContentLength: contentLength,
+		Host:          authority,
+		Trailer:       trailer,
+		RemoteAddr:    sc.qc.RemoteAddr().String(),
+		RequestURI:    requestURI,
+		TLS:           &state,
+	}
+	return req, body, nil
+}
+
+// A responseWriter is the http.ResponseWriter for a request.
+type responseWriter struct {
+	sc   *serverConn
+	st   *stream
+	req  *http.Request
+	body *bodyReader
+	bw   *bufio.Writer
+
+	handlerHeader http.Header
+	status        int
+	wroteHeader   bool  // final status chosen
+	sentHeader    bool  // HEADERS frame with the final status sent
+	handlerDone   bool  // handler has returned
+	written       int64 // body bytes written by the handler
+	contentLength int64 // declared Content-Length, or -1
+	trailers      []string
+	err           error // error writing to the stream
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.handlerHeader
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	if code < 100 || code > 999 {
+		panic(fmt.Sprintf("invalid WriteHeader code %v", code))
+	}
+	if code >= 100 && code <= 199 {
+		// Informational responses are sent immediately.
+		// 101 Switching Protocols is not allowed in HTTP/3.
+		if code != http.StatusSwitchingProtocols {
+			fields := []field{{":status", strconv.Itoa(code)}}
+			fields = appendHeaderFields(fields, rw.handlerHeader, nil)
+			rw.writeFrame(frameTypeHeaders, appendFieldSection(nil, fields))
+		}
+		return
+	}
+	rw.wroteHeader = true
+	rw.status = code
+	if cl := rw.handlerHeader.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && n >= 0 {
+			rw.contentLength = n
+		} else {
+			rw.handlerHeader.Del("Content-Length")
+		}
+	}
+	for _, v := range rw.handlerHeader["Trailer"] {
+		for _, k := range strings.Split(v, ",") {
+			if k = http.CanonicalHeaderKey(textproto.TrimString(k)); k != "" {
+				rw.trailers = append(rw.trailers, k)
+			}
+		}
+	}
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if !bodyAllowedForStatus(rw.status) {
+		return 0, http.ErrBodyNotAllowed
+	}
+	if rw.contentLength >= 0 && rw.written+int64(len(p)) > rw.contentLength {
+		return 0, http.ErrContentLength
+	}
+	rw.written += int64(len(p))
+	if rw.req.Method == "HEAD" {
+		return len(p), nil
+	}
+	if rw.err != nil {
+		return 0, rw.err
+	}
+	return rw.bw.Write(p)
+}
+
+func (rw *responseWriter) Flush() {
+	rw.FlushError()
+}
+
+// FlushError flushes buffered data to the client.
+// It is used by [net/http.ResponseController].
+func (rw *responseWriter) FlushError() error {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if err := rw.bw.Flush(); err != nil {
+		return err
+	}
+	if !rw.sentHeader {
+		rw.sendHeader(nil)
+	}
+	return rw.err
+}
+
+// SetReadDeadline sets the deadline for reading the request body.
+// It is used by [net/http.ResponseController].
+func (rw *responseWriter) SetReadDeadline(t time.Time) error {
+	return rw.st.qs.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for writing the response.
+// It is used by [net/http.ResponseController].
+func (rw *responseWriter) SetWriteDeadline(t time.Time) error {
+	return rw.st.qs.SetWriteDeadline(t)
+}
+
+// EnableFullDuplex reports success: HTTP/3 handlers may always read
+// the request body while writing the response.
+// It is used by [net/http.ResponseController].
+func (rw *responseWriter) EnableFullDuplex() error {
+	return nil
+}
+
+// writeFrame writes a frame to the stream, recording any error.
+func (rw *responseWriter) writeFrame(ftype uint64, p []byte) error {
+	if rw.err != nil {
+		return rw.err
+	}
+	rw.err = rw.st.writeFrame(ftype, p)
+	return rw.err
+}
+
+// sendHeader sends the response header. If the handler is done,
+// firstChunk is the complete response body.
+func (rw *responseWriter) sendHeader(firstChunk []byte) {
+	rw.sentHeader = true
+	h := rw.handlerHeader
+	bodyAllowed := bodyAllowedForStatus(rw.status)
+	if bodyAllowed && len(firstChunk) > 0 && !hasHeader(h, "Content-Type") && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Type", http.DetectContentType(firstChunk))
+	}
+	if rw.handlerDone && bodyAllowed && !hasHeader(h, "Content-Length") && (rw.written > 0 || rw.req.Method != "HEAD") {
+		h.Set("Content-Length", strconv.FormatInt(rw.written, 10))
+	}
+	if !hasHeader(h, "Date") {
+		h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	fields := []field{{":status", strconv.Itoa(rw.status)}}
+	fields = appendHeaderFields(fields, h, nil)
+	rw.writeFrame(frameTypeHeaders, appendFieldSection(nil, fields))
+}
+
+// finish completes the response after the handler returns.
+func (rw *responseWriter) finish() {
+	rw.handlerDone = true
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.bw.Flush()
+	if !rw.sentHeader {
+		rw.sendHeader(nil)
+	}
+	trailer := make(http.Header)
+	for _, k := range rw.trailers {
+		if vv, ok := rw.handlerHeader[k]; ok {
+			trailer[k] = vv
+		}
+	}
+	if fields := trailerFields(rw.handlerHeader, trailer); len(fields) > 0 {
+		rw.writeFrame(frameTypeHeaders, appendFieldSection(nil, fields))
+	}
+	qs := rw.st.qs
+	if rw.err != nil {
+		qs.Reset(uint64(ErrCodeInternalError))
+	} else {
+		qs.CloseWrite()
+	}
+	rw.body.mu.Lock()
+	bodyDone := rw.body.err != nil
+	rw.body.mu.Unlock()
+	if !bodyDone {
+		// The response is complete, so we no longer need the request body
+		// (RFC 9114, Section 4.1).
+		qs.StopSending(uint64(ErrCodeNoError))
+	}
+}
+
+// A chunkWriter writes buffered response body data, sending the
+// response header before the first chunk.
+type chunkWriter struct {
+	rw *responseWriter
+}
+
+func (cw chunkWriter) Write(p []byte) (int, error) {
+	rw := cw.rw
+	if !rw.sentHeader {
+		rw.sendHeader(p)
+	}
+	if len(p) > 0 {
+		rw.writeFrame(frameTypeData, p)
+	}
+	if rw.err != nil {
+		return 0, rw.err
+	}
+	return len(p), nil
+}
+
+// bodyAllowedForStatus reports whether a given response status code
+// permits a body. See RFC 9110, Section 6.4.1.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == 204:
+		return false
+	case status == 304:
+		return false
+	}
+	return true
+}
+
+// hasHeader reports whether h contains the canonical key k,
+// even with no values.
+func hasHeader(h http.Header, k string) bool {
+	_, ok := h[k]
+	return ok
+}
@@ -0,0 +1,657 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/quic"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Transport is an [net/http.RoundTripper] which makes HTTP/3 requests.
+//
+// Transports should be reused instead of created as needed.
+// Transports are safe for concurrent use by multiple goroutines.
+type Transport struct {
+	// TLSClientConfig specifies the TLS configuration to use.
+	// If nil, the default configuration is used.
+	// The NextProtos and MinVersion fields are overridden, since
+	// HTTP/3 requires the "h3" protocol and TLS 1.3.
+	TLSClientConfig *tls.Config
+
+	// QUICConfig optionally configures QUIC connections.
+	// Its TLSConfig field is ignored.
+	QUICConfig *quic.Config
+
+	// Fallback optionally specifies a RoundTripper, such as an
+	// [net/http.Transport] supporting HTTP/2, for requests to origins
+	// not known to support HTTP/3, and for requests with schemes other
+	// than "https".
+	//
+	// When Fallback is set, the Transport sends a request with HTTP/3
+	// only after a response from the origin has advertised an HTTP/3
+	// endpoint with an Alt-Svc header (RFC 7838). If that endpoint cannot
+	// be reached, the request is retried using Fallback.
+	//
+	// If Fallback is nil, all requests are made using HTTP/3.
+	Fallback http.RoundTripper
+
+	// MaxResponseHeaderBytes specifies a limit on how many response
+	// bytes are allowed in the server's response header or trailer.
+	// If zero, a default limit is used.
+	MaxResponseHeaderBytes int64
+
+	mu       sync.Mutex
+	endpoint *quic.Endpoint
+	conns    map[string]*clientConn // keyed by dial address
+	altSvc   map[string]altSvc      // keyed by origin host:port
+}
+
+// An altSvc is an HTTP/3 alternative service for an origin.
+type altSvc struct {
+	addr    string // host:port
+	expires time.Time
+}
+
+var errClientConnUnusable = errors.New("http3: client conn not usable")
+
+// RoundTrip implements the [net/http.RoundTripper] interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL == nil {
+		closeRequestBody(req)
+		return nil, errors.New("http3: nil Request.URL")
+	}
+	if req.URL.Scheme != "https" {
+		if t.Fallback != nil {
+			return t.Fallback.RoundTrip(req)
+		}
+		closeRequestBody(req)
+		return nil, fmt.Errorf("http3: unsupported protocol scheme %q", req.URL.Scheme)
+	}
+	host := req.URL.Hostname()
+	origin := authorityAddr(req.URL.Host)
+	addr := origin
+	if t.Fallback != nil {
+		alt, ok := t.lookupAltSvc(origin)
+		if !ok {
+			resp, err := t.Fallback.RoundTrip(req)
+			if err == nil {
+				t.recordAltSvc(origin, host, resp.Header["Alt-Svc"])
+			}
+			return resp, err
+		}
+		addr = alt
+	}
+
+	for retry := 0; ; retry++ {
+		cc, err := t.getConn(addr, host)
+		if err != nil {
+			if t.Fallback != nil {
+				// The advertised endpoint is not reachable.
+				// Nothing has been sent, so the request can be retried.
+				t.forgetAltSvc(origin)
+				return t.Fallback.RoundTrip(req)
+			}
+			closeRequestBody(req)
+			return nil, err
+		}
+		resp, err := cc.roundTrip(req)
+		if err == errClientConnUnusable && retry < 2 {
+			// The connection was closed or is going away before
+			// the request was sent.
+			t.removeConn(cc)
+			continue
+		}
+		return resp, err
+	}
+}
+
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// authorityAddr returns a host:port for an authority,
+// adding the default HTTPS port if necessary.
+func authorityAddr(authority string) string {
+	host, port, err := net.SplitHostPort(authority)
+	if err != nil {
+		host, port = authority, "443"
+	}
+	if port == "" {
+		port = "443"
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return net.JoinHostPort(host, port)
+}
+
+// CloseIdleConnections closes any connections which have no requests
+// in progress. If Fallback has a CloseIdleConnections method, it is
+// called as well.
+func (t *Transport) CloseIdleConnections() {
+	t.mu.Lock()
+	for key, cc := range t.conns {
+		if cc.closeIfIdle() {
+			delete(t.conns, key)
+		}
+	}
+	var e *quic.Endpoint
+	if len(t.conns) == 0 {
+		e, t.endpoint = t.endpoint, nil
+	}
+	t.mu.Unlock()
+	if e != nil {
+		e.Close(context.Background())
+	}
+	if f, ok := t.Fallback.(interface{ CloseIdleConnections() }); ok {
+		f.CloseIdleConnections()
+	}
+}
+
+func (t *Transport) lookupAltSvc(origin string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	alt, ok := t.altSvc[origin]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(alt.expires) {
+		delete(t.altSvc, origin)
+		return "", false
+	}
+	return alt.addr, true
+}
+
+func (t *Transport) forgetAltSvc(origin string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.altSvc, origin)
+}
+
+// recordAltSvc records the HTTP/3 alternative service advertised
+// in a response from origin.
+func (t *Transport) recordAltSvc(origin, host string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, v := range values {
+		authority, maxAge, clear, ok := parseAltSvc(v)
+		if clear {
+			delete(t.altSvc, origin)
+			return
+		}
+		if !ok {
+			continue
+		}
+		althost, port, err := net.SplitHostPort(authority)
+		if err != nil {
+			continue
+		}
+		if althost == "" {
+			althost = host
+		}
+		if t.altSvc == nil {
+			t.altSvc = make(map[string]altSvc)
+		}
+		t.altSvc[origin] = altSvc{
+			addr:    net.JoinHostPort(althost, port),
+			expires: time.Now().Add(maxAge),
+		}
+		return
+	}
+}
+
+func (t *Transport) maxHeaderBytes() int64 {
+	if t.MaxResponseHeaderBytes > 0 {
+		return t.MaxResponseHeaderBytes
+	}
+	return 10 << 20
+}
+
+// getConn returns a connection to addr, dialing one if necessary.
+// host is the server name to verify the server's certificate against.
+func (t *Transport) getConn(addr, host string) (*clientConn, error) {
+	t.mu.Lock()
+	if cc := t.conns[addr]; cc != nil {
+		t.mu.Unlock()
+		<-cc.ready
+		if cc.err != nil {
+			t.removeConn(cc)
+			return nil, cc.err
+		}
+		return cc, nil
+	}
+	if t.endpoint == nil {
+		e, err := quic.Listen("udp", ":0", nil)
+		if err != nil {
+			t.mu.Unlock()
+			return nil, err
+		}
+		t.endpoint = e
+	}
+	e := t.endpoint
+	cc := &clientConn{
+		t:     t,
+		key:   addr,
+		ready: make(chan struct{}),
+	}
+	if t.conns == nil {
+		t.conns = make(map[string]*clientConn)
+	}
+	t.conns[addr] = cc
+	t.mu.Unlock()
+
+	cc.err = cc.dial(e, addr, host)
+	close(cc.ready)
+	if cc.err != nil {
+		t.removeConn(cc)
+		return nil, cc.err
+	}
+	return cc, nil
+}
+
+func (t *Transport) removeConn(cc *clientConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns[cc.key] == cc {
+		delete(t.conns, cc.key)
+	}
+}
+
+// A clientConn is a client connection.
+type clientConn struct {
+	t     *Transport
+	key   string
+	ready chan struct{} // closed when dialing is complete
+	err   error         // dial error, set before ready is closed
+
+	qc         *quic.Conn
+	control    *quic.Stream
+	sawControl atomic.Bool
+
+	mu      sync.Mutex
+	active  int  // requests in progress
+	goaway  bool // GOAWAY received, or the connection is closed
+	closing bool
+}
+
+func (cc *clientConn) dial(e *quic.Endpoint, addr, host string) error {
+	config := cc.t.TLSClientConfig.Clone()
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config.NextProtos = []string{NextProto}
+	config.MinVersion = tls.VersionTLS13
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	var qconfig quic.Config
+	if cc.t.QUICConfig != nil {
+		qconfig = *cc.t.QUICConfig
+	}
+	qconfig.TLSConfig = config
+
+	qc, err := e.Dial(context.Background(), "udp", addr, &qconfig)
+	if err != nil {
+		return err
+	}
+	control, err := openControlStream(qc, cc.t.maxHeaderBytes())
+	if err != nil {
+		qc.Abort(nil)
+		return err
+	}
+	cc.qc = qc
+	cc.control = control
+	go cc.acceptStreams()
+	return nil
+}
+
+// acceptStreams handles streams opened by the server.
+func (cc *clientConn) acceptStreams() {
+	defer func() {
+		cc.mu.Lock()
+		cc.goaway = true
+		cc.mu.Unlock()
+		cc.t.removeConn(cc)
+	}()
+	for {
+		qs, err := cc.qc.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		if !qs.IsReadOnly() {
+			// Servers may not open bidirectional streams
+			// (RFC 9114, Section 6.1).
+			abortConn(cc.qc, &connError{ErrCodeStreamCreationError, "server opened bidirectional stream"})
+			return
+		}
+		go func() {
+			if err := handleUniStream(qs, cc.handleControlStream); err != nil {
+				abortConn(cc.qc, err)
+			}
+		}()
+	}
+}
+
+func (cc *clientConn) handleControlStream(st *stream) error {
+	if cc.sawControl.Swap(true) {
+		return &connError{ErrCodeStreamCreationError, "duplicate control stream"}
+	}
+	return readControlStream(st, func(settings) {}, func(id uint64) error {
+		// Requests on streams with IDs of at least id were not processed.
+		// We make no new requests on this connection, and close it once
+		// the remaining requests are done (RFC 9114, Section 5.2).
+		cc.mu.Lock()
+		cc.goaway = true
+		cc.mu.Unlock()
+		cc.t.removeConn(cc)
+		cc.closeIfIdle()
+		return nil
+	})
+}
+
+// closeIfIdle closes the connection if it has no requests in progress,
+// and reports whether it did.
+func (cc *clientConn) closeIfIdle() bool {
+	select {
+	case <-cc.ready:
+	default:
+		return false
+	}
+	if cc.err != nil {
+		return true
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.active > 0 {
+		return false
+	}
+	cc.goaway = true
+	if !cc.closing {
+		cc.closing = true
+		cc.qc.Abort(&quic.ApplicationError{Code: uint64(ErrCodeNoError)})
+	}
+	return true
+}
+
+func (cc *clientConn) requestDone() {
+	cc.mu.Lock()
+	cc.active--
+	closeNow := cc.goaway && cc.active == 0 && !cc.closing
+	if closeNow {
+		cc.closing = true
+	}
+	cc.mu.Unlock()
+	if closeNow {
+		cc.qc.Abort(&quic.ApplicationError{Code: uint64(ErrCodeNoError)})
+	}
+}
+
+// errResponseBodyClosed is returned by reads from a response body
+// after it has been closed.
+var errResponseBodyClosed = errors.New("http3: response body closed")
+
+func (cc *clientConn) roundTrip(req *http.Request) (_ *http.Response, err error) {
+	cc.mu.Lock()
+	if cc.goaway {
+		cc.mu.Unlock()
+		return nil, errClientConnUnusable
+	}
+	cc.active++
+	cc.mu.Unlock()
+	ctx := req.Context()
+	var once sync.Once
+	done := func() { once.Do(cc.requestDone) }
+	defer func() {
+		if err != nil {
+			done()
+		}
+	}()
+
+	qs, err := cc.qc.NewStream(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			closeRequestBody(req)
+			return nil, ctx.Err()
+		}
+		return nil, errClientConnUnusable
+	}
+	st := newStream(qs)
+	cancel := func() {
+		qs.StopSending(uint64(ErrCodeRequestCancelled))
+		qs.Reset(uint64(ErrCodeRequestCancelled))
+	}
+	stop := context.AfterFunc(ctx, cancel)
+	ctxErr := func(err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	if err := st.writeFrame(frameTypeHeaders, appendFieldSection(nil, requestFields(req))); err != nil {
+		stop()
+		cancel()
+		closeRequestBody(req)
+		return nil, ctxErr(err)
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		closeRequestBody(req)
+		qs.CloseWrite()
+	} else {
+		go writeRequestBody(st, req)
+	}
+
+	resp, err := cc.readResponse(st, req)
+	if err != nil {
+		stop()
+		cancel()
+		var ce *connError
+		if errors.As(err, &ce) {
+			abortConn(cc.qc, err)
+		}
+		return nil, ctxErr(err)
+	}
+	body := resp.Body.(*bodyReader)
+	body.onClose = func() {
+		stop()
+		cancel()
+		done()
+	}
+	body.onEOF = func() {
+		stop()
+		done()
+	}
+	body.mapErr = ctxErr
+	if req.Method == "HEAD" || !bodyAllowedForStatus(resp.StatusCode) {
+		resp.Body = http.NoBody
+		body.Close()
+	}
+	return resp, nil
+}
+
+// requestFields returns the header fields of a request.
+func requestFields(req *http.Request) []field {
+	method := req.Method
+	if method == "" {
+		method = "GET"
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fields := []field{{":method", method}}
+	if method == "CONNECT" {
+		fields = append(fields, field{":authority", host})
+	} else {
+		fields = append(fields,
+			field{":scheme", "https"},
+			field{":authority", host},
+			field{":path", req.URL.RequestURI()},
+		)
+	}
+	fields = appendHeaderFields(fields, req.Header, map[string]bool{
+		"Host":           true,
+		"Content-Length": true,
+		"Trailer":        true,
+	})
+	if n := actualContentLength(req); shouldSendContentLength(method, n) {
+		fields = append(fields, field{"content-length", strconv.FormatInt(n, 10)})
+	}
+	if len(req.Trailer) > 0 {
+		keys := make([]string, 0, len(req.Trailer))
+		for k := range req.Trailer {
+			keys = append(keys, k)
+		}
+		fields = append(fields, field{"trailer", strings.Join(keys, ", ")})
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		fields = append(fields, field{"user-agent", "Go-http-client/3.0"})
+	}
+	return fields
+}
+
+// actualContentLength returns a sanitized version of req.ContentLength,
+// where 0 actually means zero (not unknown) and -1 means unknown.
+func actualContentLength(req *http.Request) int64 {
+	if req.Body == nil || req.Body == http.NoBody {
+		return 0
+	}
+	if req.ContentLength != 0 {
+		return req.ContentLength
+	}
+	return -1
+}
+
+// shouldSendContentLength reports whether a Content-Length header should
+// be sent for a request. A zero length is only sent for methods which
+// usually have bodies.
+func shouldSendContentLength(method string, contentLength int64) bool {
+	if contentLength > 0 {
+		return true
+	}
+	if contentLength < 0 {
+		return false
+	}
+	switch method {
+	case "POST", "PUT", "PATCH":
+		return true
+	}
+	return false
+}
+
+// writeRequestBody sends the request body and trailers, and closes
+// the sending side of the stream.
+func writeRequestBody(st *stream, req *http.Request) {
+	defer req.Body.Close()
+	buf := make([]byte, 16<<10)
+	n, err := io.CopyBuffer(bodyWriter{st}, req.Body, buf)
+	if err == nil && req.ContentLength > 0 && n != req.ContentLength {
+		err = fmt.Errorf("http3: request body length %v does not match ContentLength %v", n, req.ContentLength)
+	}
+	if err == nil && len(req.Trailer) > 0 {
+		if fields := trailerFields(nil, req.Trailer); len(fields) > 0 {
+			err = st.writeFrame(frameTypeHeaders, appendFieldSection(nil, fields))
+		}
+	}
+	if err != nil {
+		st.qs.Reset(uint64(ErrCodeRequestCancelled))
+		return
+	}
+	st.qs.CloseWrite()
+}
+
+// readResponse reads the response header from st.
+func (cc *clientConn) readResponse(st *stream, req *http.Request) (*http.Response, error) {
+	maxHeader := cc.t.maxHeaderBytes()
+	for {
+		ftype, length, err := st.readFrameHeader()
+		if err == io.EOF {
+			return nil, errors.New("http3: server closed stream without sending a response")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ftype == frameTypeHeaders:
+		case ftype == frameTypeData || ftype == frameTypeSettings || ftype == frameTypeGoaway ||
+			ftype == frameTypeCancelPush || ftype == frameTypeMaxPushID || reservedHTTP2FrameType(ftype):
+			return nil, &connError{ErrCodeFrameUnexpected, "unexpected frame on request stream"}
+		default:
+			continue
+		}
+		if length > maxHeader {
+			return nil, fmt.Errorf("http3: server response headers exceeded %d bytes", maxHeader)
+		}
+		p, err := st.readFramePayload(maxHeader)
+		if err != nil {
+			return nil, err
+		}
+		d := newHeaderDecoder(false)
+		if err := d.decode(p); err != nil {
+			return nil, err
+		}
+		status, ok := d.pseudo[":status"]
+		if !ok || len(d.pseudo) != 1 || len(status) != 3 {
+			return nil, errMalformed
+		}
+		code, err := strconv.Atoi(status)
+		if err != nil || code < 100 {
+			return nil, errMalformed
+		}
+		if code < 200 {
+			// Skip informational responses.
+			continue
+		}
+		contentLength, err := parseContentLength(d.header)
+		if err != nil {
+			return nil, err
+		}
+		state := cc.qc.ConnectionState()
+		resp := &http.Response{
+			Status:        status + " " + http.StatusText(code),
+			StatusCode:    code,
+			Proto:         "HTTP/3.0",
+			ProtoMajor:    3,
+			ProtoMinor:    0,
+			Header:        d.header,
+			ContentLength: contentLength,
+			Request:       req,
+			TLS:           &state,
+		}
+		for _, v := range d.header["Trailer"] {
+			for _, k := range strings.Split(v, ",") {
+				if k = http.CanonicalHeaderKey(textproto.TrimString(k)); k != "" {
+					if resp.Trailer == nil {
+						resp.Trailer = make(http.Header)
+					}
+					resp.Trailer[k] = nil
+				}
+			}
+		}
+		resp.Body = &bodyReader{
+			st:        st,
+			remain:    contentLength,
+			trailer:   &resp.Trailer,
+			maxTrl:    maxHeader,
+			errClosed: errResponseBodyClosed,
+		}
+		return resp, nil
+	}
+}
@@ -196,3 +196,49 @@ func BenchmarkHexEscapeNonASCII(b *testing.B) {
 		hexEscapeNonASCII(redirectURL)
 	}
 }
+
+func TestProtocols(t *testing.T) {
+	var p Protocols
+	if p.HTTP1() || p.HTTP2() || p.UnencryptedHTTP2() {
+		t.Errorf("zero Protocols = %v, want empty", p)
+	}
+	p.SetHTTP1(true)
+	p.SetUnencryptedHTTP2(true)
+	if !p.HTTP1() || p.HTTP2() || !p.UnencryptedHTTP2() {
+		t.Errorf("Protocols = %v, want {HTTP1,UnencryptedHTTP2}", p)
+	}
+	if got, want := p.String(), "{HTTP1,UnencryptedHTTP2}"; got != want {
+		t.Errorf("Protocols.String() = %q, want %q", got, want)
+	}
+	p.SetHTTP1(false)
+	p.SetHTTP2(true)
+	if got, want := p.String(), "{HTTP2,UnencryptedHTTP2}"; got != want {
+		t.Errorf("Protocols.String() = %q, want %q", got, want)
+	}
+}
+
+func TestAdjustNextProtos(t *testing.T) {
+	for _, tt := range []struct {
+		in    []string
+		http1 bool
+		http2 bool
+		want  []string
+	}{
+		{nil, true, true, []string{"h2", "http/1.1"}},
+		{nil, true, false, []string{"http/1.1"}},
+		{[]string{"h2", "http/1.1"}, false, true, []string{"h2"}},
+		{[]string{"acme", "h2", "http/1.1"}, true, false, []string{"acme", "http/1.1"}},
+		{[]string{"http/1.1", "h2"}, true, true, []string{"http/1.1", "h2"}},
+	} {
+		var p Protocols
+		p.SetHTTP1(tt.http1)
+		p.SetHTTP2(tt.http2)
+		in := slices.Clone(tt.in)
+		if got := adjustNextProtos(in, p); !slices.Equal(got, tt.want) {
+			t.Errorf("adjustNextProtos(%q, %v) = %q, want %q", tt.in, p, got, tt.want)
+		}
+		if !slices.Equal(in, tt.in) {
+			t.Errorf("adjustNextProtos(%q, %v) modified its input", tt.in, p)
+		}
+	}
+}
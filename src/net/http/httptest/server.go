@@ -29,7 +29,12 @@ type Server struct {
 
 	// EnableHTTP2 controls whether HTTP/2 is enabled
 	// on the server. It must be set between calling
-	// NewUnstartedServer and calling Server.StartTLS.
+	// NewUnstartedServer and calling Server.Start or Server.StartTLS.
+	//
+	// A server started with Start serves unencrypted HTTP/2 with prior
+	// knowledge (h2c) in addition to HTTP/1, and its Client uses
+	// unencrypted HTTP/2. A server started with StartTLS negotiates
+	// HTTP/2 using ALPN.
 	EnableHTTP2 bool
 
 	// TLS is the optional TLS configuration, populated with a new config
@@ -129,6 +134,17 @@ func (s *Server) Start() {
 	if s.client == nil {
 		s.client = &http.Client{Transport: &http.Transport{}}
 	}
+	if s.EnableHTTP2 {
+		if s.Config.Protocols == nil {
+			s.Config.Protocols = new(http.Protocols)
+			s.Config.Protocols.SetHTTP1(true)
+			s.Config.Protocols.SetUnencryptedHTTP2(true)
+		}
+		if tr, ok := s.client.Transport.(*http.Transport); ok {
+			tr.Protocols = new(http.Protocols)
+			tr.Protocols.SetUnencryptedHTTP2(true)
+		}
+	}
 	s.URL = "http://" + s.Listener.Addr().String()
 	s.wrap()
 	s.goServe()
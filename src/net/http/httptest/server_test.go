@@ -293,3 +293,29 @@ func TestTLSServerWithHTTP2(t *testing.T) {
 		})
 	}
 }
+
+func TestServerWithUnencryptedHTTP2(t *testing.T) {
+	cst := NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+	}))
+	cst.EnableHTTP2 = true
+	cst.Start()
+	defer cst.Close()
+
+	for _, tt := range []struct {
+		client    *http.Client
+		wantProto string
+	}{
+		{cst.Client(), "HTTP/2.0"},
+		{&http.Client{}, "HTTP/1.1"}, // HTTP/1 is still served
+	} {
+		res, err := tt.client.Get(cst.URL)
+		if err != nil {
+			t.Fatalf("Failed to make request: %v", err)
+		}
+		res.Body.Close()
+		if g, w := res.Header.Get("X-Proto"), tt.wantProto; g != w {
+			t.Errorf("X-Proto header mismatch:\n\tgot:  %q\n\twant: %q", g, w)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
 	"unicode"
 )
@@ -196,6 +197,93 @@ func isValidWildcardName(s string) bool {
 	return true
 }
 
+// buildPath returns an escaped path that p matches, substituting
+// values for its named wildcards. See BuildPath.
+func (p *pattern) buildPath(values map[string]string) (string, error) {
+	var b strings.Builder
+	used := 0
+	value := func(name string) (string, error) {
+		v, ok := values[name]
+		if !ok {
+			return "", fmt.Errorf("missing value for wildcard %q", name)
+		}
+		used++
+		return v, nil
+	}
+	for _, seg := range p.segments {
+		switch {
+		case !seg.wild && seg.s == "/":
+			// {$}
+			b.WriteByte('/')
+		case !seg.wild:
+			if !isCanonicalSegment(seg.s) {
+				return "", fmt.Errorf("literal segment %q cannot appear in a clean path", seg.s)
+			}
+			b.WriteByte('/')
+			b.WriteString(url.PathEscape(seg.s))
+		case seg.multi:
+			b.WriteByte('/')
+			if seg.s == "" {
+				// Trailing slash.
+				continue
+			}
+			v, err := value(seg.s)
+			if err != nil {
+				return "", err
+			}
+			// The value may span several segments. Only the last may be
+			// empty, making the path end in a slash.
+			parts := strings.Split(v, "/")
+			for i, part := range parts {
+				if !isCanonicalSegment(part) && (part != "" || i < len(parts)-1) {
+					return "", fmt.Errorf("value %q for wildcard %q cannot appear in a clean path", v, seg.s)
+				}
+				if i > 0 {
+					b.WriteByte('/')
+				}
+				b.WriteString(url.PathEscape(part))
+			}
+		default:
+			v, err := value(seg.s)
+			if err != nil {
+				return "", err
+			}
+			if !isCanonicalSegment(v) {
+				return "", fmt.Errorf("value %q for wildcard %q cannot appear in a clean path", v, seg.s)
+			}
+			b.WriteByte('/')
+			b.WriteString(url.PathEscape(v))
+		}
+	}
+	if used < len(values) {
+		var extra []string
+		for name := range values {
+			if !p.hasWildcard(name) {
+				extra = append(extra, name)
+			}
+		}
+		slices.Sort(extra)
+		return "", fmt.Errorf("no wildcard named %q", extra[0])
+	}
+	return b.String(), nil
+}
+
+// hasWildcard reports whether p has a wildcard with the given name.
+func (p *pattern) hasWildcard(name string) bool {
+	for _, seg := range p.segments {
+		if seg.wild && seg.s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isCanonicalSegment reports whether s can appear as a segment of a path
+// which ServeMux will not redirect to a cleaner one.
+func isCanonicalSegment(s string) bool {
+	return s != "" && s != "." && s != ".."
+}
+
 func pathUnescape(path string) string {
 	u, err := url.PathUnescape(path)
 	if err != nil {
@@ -0,0 +1,217 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"time"
+)
+
+// A ResponseWrapper is a [ResponseWriter] which wraps another ResponseWriter,
+// recording the status code, the number of body bytes written, and the time at
+// which the response began to be sent. It is intended for use by middleware
+// which logs requests or collects metrics:
+//
+//	func logRequests(h http.Handler) http.Handler {
+//		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//			start := time.Now()
+//			rw := http.NewResponseWrapper(w)
+//			h.ServeHTTP(rw, r)
+//			log.Printf("%s %s: %d, %d bytes, first byte after %v",
+//				r.Method, r.URL, rw.Status(), rw.BytesWritten(), rw.FirstByteTime().Sub(start))
+//		})
+//	}
+//
+// A ResponseWrapper implements [Flusher], [Hijacker], [Pusher],
+// [io.ReaderFrom], and [io.StringWriter] by forwarding to the wrapped
+// ResponseWriter. If the wrapped ResponseWriter does not support one of
+// these, Flush does nothing, Hijack and Push return an error matching
+// [ErrNotSupported], and ReadFrom and WriteString fall back to Write.
+// Its Unwrap method returns the wrapped ResponseWriter, so a
+// [ResponseController] created from a ResponseWrapper controls the
+// wrapped ResponseWriter:
+//
+//	err := http.NewResponseController(rw).SetWriteDeadline(deadline)
+//
+// Like a ResponseWriter, a ResponseWrapper may not be used concurrently,
+// and may not be used after the [Handler.ServeHTTP] method has returned.
+// Its accessor methods may be called after ServeHTTP returns.
+type ResponseWrapper struct {
+	// OnWriteHeader, if non-nil, is called in place of writing the
+	// response header: on each call to WriteHeader, including for
+	// informational (1xx) responses, and before the first Write
+	// or Flush if WriteHeader has not been called.
+	//
+	// OnWriteHeader writes the header by calling next, with code or with
+	// a different status code. It may modify the header map returned by
+	// Header before calling next. If it does not call next, no header is
+	// written to the wrapped ResponseWriter, which will then write one
+	// with a 200 OK status on the first Write, and Status reports 200.
+	OnWriteHeader func(code int, next func(code int))
+
+	rw          ResponseWriter
+	wroteHeader bool // WriteHeader called with a final status
+	status      int
+	written     int64
+	firstByte   time.Time
+}
+
+// NewResponseWrapper returns a [ResponseWrapper] which wraps rw.
+func NewResponseWrapper(rw ResponseWriter) *ResponseWrapper {
+	return &ResponseWrapper{rw: rw}
+}
+
+// Unwrap returns the wrapped ResponseWriter.
+func (w *ResponseWrapper) Unwrap() ResponseWriter {
+	return w.rw
+}
+
+// Status returns the final (non-informational) status code written to
+// the wrapped ResponseWriter, or zero if none has been written.
+//
+// If a handler returns without writing a response header,
+// the server responds with a 200 OK status.
+func (w *ResponseWrapper) Status() int {
+	return w.status
+}
+
+// BytesWritten returns the number of bytes of the response body
+// written to the wrapped ResponseWriter.
+func (w *ResponseWrapper) BytesWritten() int64 {
+	return w.written
+}
+
+// FirstByteTime returns the time at which the response first wrote an
+// informational (1xx) header, body bytes, or a flush to the wrapped
+// ResponseWriter, or the zero time if it has done none of these.
+// A response with an empty body which is not flushed is sent
+// when the handler returns.
+func (w *ResponseWrapper) FirstByteTime() time.Time {
+	return w.firstByte
+}
+
+// Header returns the header map of the wrapped ResponseWriter.
+func (w *ResponseWrapper) Header() Header {
+	return w.rw.Header()
+}
+
+// WriteHeader writes the response header, calling OnWriteHeader if it is set.
+func (w *ResponseWrapper) WriteHeader(code int) {
+	if !isInformational(code) {
+		w.wroteHeader = true
+	}
+	if w.OnWriteHeader != nil {
+		w.OnWriteHeader(code, w.writeHeader)
+		return
+	}
+	w.writeHeader(code)
+}
+
+func (w *ResponseWrapper) writeHeader(code int) {
+	if isInformational(code) {
+		// Informational headers are sent immediately.
+		w.markFirstByte()
+	} else if w.status == 0 {
+		w.status = code
+	}
+	w.rw.WriteHeader(code)
+}
+
+// isInformational reports whether code is the status of an informational
+// response, which precedes the final response.
+// 101 Switching Protocols ends the HTTP response, so is not informational here.
+func isInformational(code int) bool {
+	return code >= 100 && code <= 199 && code != StatusSwitchingProtocols
+}
+
+// implicitHeader writes a 200 OK header if WriteHeader has not been called.
+func (w *ResponseWrapper) implicitHeader() {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	if w.status == 0 {
+		// OnWriteHeader did not call next, so the wrapped
+		// ResponseWriter writes a 200 OK header itself.
+		w.status = StatusOK
+	}
+}
+
+func (w *ResponseWrapper) markFirstByte() {
+	if w.firstByte.IsZero() {
+		w.firstByte = time.Now()
+	}
+}
+
+func (w *ResponseWrapper) recordWrite(n int64) {
+	if n > 0 {
+		w.markFirstByte()
+		w.written += n
+	}
+}
+
+// Write writes data to the wrapped ResponseWriter.
+func (w *ResponseWrapper) Write(p []byte) (int, error) {
+	w.implicitHeader()
+	n, err := w.rw.Write(p)
+	w.recordWrite(int64(n))
+	return n, err
+}
+
+// WriteString writes a string to the wrapped ResponseWriter,
+// using its WriteString method if it has one.
+func (w *ResponseWrapper) WriteString(s string) (int, error) {
+	w.implicitHeader()
+	n, err := io.WriteString(w.rw, s)
+	w.recordWrite(int64(n))
+	return n, err
+}
+
+// ReadFrom copies data from src to the wrapped ResponseWriter,
+// using its ReadFrom method if it has one.
+func (w *ResponseWrapper) ReadFrom(src io.Reader) (int64, error) {
+	w.implicitHeader()
+	var n int64
+	var err error
+	if rf, ok := w.rw.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(writerOnly{w.rw}, src)
+	}
+	w.recordWrite(n)
+	return n, err
+}
+
+// Flush flushes buffered data to the client, if the wrapped
+// ResponseWriter supports flushing. See [ResponseController.Flush].
+func (w *ResponseWrapper) Flush() {
+	w.implicitHeader()
+	if NewResponseController(w.rw).Flush() == nil {
+		w.markFirstByte()
+	}
+}
+
+// Hijack lets the caller take over the connection.
+// See [ResponseController.Hijack].
+func (w *ResponseWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return NewResponseController(w.rw).Hijack()
+}
+
+// Push initiates an HTTP/2 server push, if the wrapped ResponseWriter
+// supports it. See [Pusher].
+func (w *ResponseWrapper) Push(target string, opts *PushOptions) error {
+	rw := w.rw
+	for {
+		switch t := rw.(type) {
+		case Pusher:
+			return t.Push(target, opts)
+		case rwUnwrapper:
+			rw = t.Unwrap()
+		default:
+			return ErrNotSupported
+		}
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	. "net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wrapperResult is what a handler wrapped by a ResponseWrapper recorded.
+type wrapperResult struct {
+	status    int
+	written   int64
+	firstByte time.Time
+	start     time.Time
+	end       time.Time
+}
+
+// recordingHandler returns a handler which serves h with a ResponseWrapper,
+// sending what it recorded on the returned channel.
+func recordingHandler(h Handler, setup func(*ResponseWrapper)) (Handler, <-chan wrapperResult) {
+	results := make(chan wrapperResult, 1)
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		start := time.Now()
+		rw := NewResponseWrapper(w)
+		if setup != nil {
+			setup(rw)
+		}
+		h.ServeHTTP(rw, r)
+		results <- wrapperResult{
+			status:    rw.Status(),
+			written:   rw.BytesWritten(),
+			firstByte: rw.FirstByteTime(),
+			start:     start,
+			end:       time.Now(),
+		}
+	}), results
+}
+
+func TestResponseWrapperRecords(t *testing.T) { run(t, testResponseWrapperRecords) }
+func testResponseWrapperRecords(t *testing.T, mode testMode) {
+	h, results := recordingHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.WriteHeader(StatusCreated)
+		w.WriteHeader(StatusAccepted) // superfluous; ignored
+		w.Write([]byte("one "))
+		io.WriteString(w, "two ")
+		io.Copy(w, strings.NewReader("three"))
+	}), nil)
+	cst := newClientServerTest(t, mode, h)
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "one two three"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+	if res.StatusCode != StatusCreated {
+		t.Errorf("response status = %v, want %v", res.StatusCode, StatusCreated)
+	}
+
+	got := <-results
+	if got.status != StatusCreated {
+		t.Errorf("Status() = %v, want %v", got.status, StatusCreated)
+	}
+	if got.written != int64(len(body)) {
+		t.Errorf("BytesWritten() = %v, want %v", got.written, len(body))
+	}
+	if got.firstByte.Before(got.start) || got.firstByte.After(got.end) {
+		t.Errorf("FirstByteTime() = %v, want between %v and %v", got.firstByte, got.start, got.end)
+	}
+}
+
+func TestResponseWrapperImplicitStatus(t *testing.T) { run(t, testResponseWrapperImplicitStatus) }
+func testResponseWrapperImplicitStatus(t *testing.T, mode testMode) {
+	h, results := recordingHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/write" {
+			w.Write([]byte("body"))
+		}
+	}), nil)
+	cst := newClientServerTest(t, mode, h)
+	for _, test := range []struct {
+		path      string
+		status    int
+		written   int64
+		firstByte bool
+	}{
+		{"/write", StatusOK, 4, true},
+		{"/empty", 0, 0, false},
+	} {
+		res, err := cst.c.Get(cst.ts.URL + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		if res.StatusCode != StatusOK {
+			t.Errorf("%v: response status = %v, want 200", test.path, res.StatusCode)
+		}
+		got := <-results
+		if got.status != test.status || got.written != test.written || got.firstByte.IsZero() == test.firstByte {
+			t.Errorf("%v: recorded status %v, %v bytes, first byte at %v; want %v, %v, first byte recorded = %v",
+				test.path, got.status, got.written, got.firstByte, test.status, test.written, test.firstByte)
+		}
+	}
+}
+
+func TestResponseWrapperOnWriteHeader(t *testing.T) { run(t, testResponseWrapperOnWriteHeader) }
+func testResponseWrapperOnWriteHeader(t *testing.T, mode testMode) {
+	var hooked []int
+	h, results := recordingHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(StatusEarlyHints)
+		w.Header().Del("Link")
+		w.Write([]byte("body")) // implicit WriteHeader(200)
+	}), func(rw *ResponseWrapper) {
+		rw.OnWriteHeader = func(code int, next func(int)) {
+			hooked = append(hooked, code)
+			if code == StatusOK {
+				rw.Header().Set("X-Hooked", "yes")
+				code = StatusAccepted
+			}
+			next(code)
+		}
+	})
+	cst := newClientServerTest(t, mode, h)
+
+	var got1xx []int
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			got1xx = append(got1xx, code)
+			if got, want := header.Get("Link"), "</style.css>; rel=preload; as=style"; got != want {
+				t.Errorf("%v response Link header = %q, want %q", code, got, want)
+			}
+			return nil
+		},
+	}
+	req, _ := NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "GET", cst.ts.URL, nil)
+	res, err := cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	got := <-results // hooked is written by the handler
+
+	if want := []int{StatusEarlyHints, StatusOK}; !slices.Equal(hooked, want) {
+		t.Errorf("OnWriteHeader called with %v, want %v", hooked, want)
+	}
+	if want := []int{StatusEarlyHints}; !slices.Equal(got1xx, want) {
+		t.Errorf("client received informational responses %v, want %v", got1xx, want)
+	}
+	if res.StatusCode != StatusAccepted || res.Header.Get("X-Hooked") != "yes" {
+		t.Errorf("response: status %v, X-Hooked %q; want %v, %q", res.StatusCode, res.Header.Get("X-Hooked"), StatusAccepted, "yes")
+	}
+	if got.status != StatusAccepted {
+		t.Errorf("Status() = %v, want %v", got.status, StatusAccepted)
+	}
+}
+
+func TestResponseWrapperController(t *testing.T) { run(t, testResponseWrapperController) }
+func testResponseWrapperController(t *testing.T, mode testMode) {
+	continuec := make(chan struct{})
+	h, results := recordingHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		ctl := NewResponseController(w)
+		if err := ctl.SetWriteDeadline(time.Now().Add(1 * time.Minute)); err != nil {
+			t.Errorf("SetWriteDeadline = %v, want nil", err)
+		}
+		if err := ctl.SetReadDeadline(time.Now().Add(1 * time.Minute)); err != nil {
+			t.Errorf("SetReadDeadline = %v, want nil", err)
+		}
+		if mode == http1Mode {
+			if err := ctl.EnableFullDuplex(); err != nil {
+				t.Errorf("EnableFullDuplex = %v, want nil", err)
+			}
+		}
+		if err := ctl.Flush(); err != nil {
+			t.Errorf("Flush = %v, want nil", err)
+		}
+		<-continuec
+		w.Write([]byte("body"))
+	}), nil)
+	// Wrap twice: the outer wrapper must forward to the inner one.
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		h.ServeHTTP(NewResponseWrapper(w), r)
+	}))
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The flushed header arrives before the handler writes the body.
+	close(continuec)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil || string(body) != "body" {
+		t.Errorf("body = %q, %v; want %q", body, err, "body")
+	}
+	got := <-results
+	if got.status != StatusOK || got.written != 4 || got.firstByte.IsZero() {
+		t.Errorf("recorded status %v, %v bytes, first byte at %v; want 200, 4, non-zero", got.status, got.written, got.firstByte)
+	}
+}
+
+func TestResponseWrapperHijack(t *testing.T) {
+	run(t, testResponseWrapperHijack, []testMode{http1Mode})
+}
+func testResponseWrapperHijack(t *testing.T, mode testMode) {
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if _, ok := w.(Hijacker); !ok {
+			t.Errorf("server ResponseWriter is not a Hijacker")
+		}
+		// Middleware which asserts that the ResponseWriter is a Hijacker
+		// hijacks the wrapped ResponseWriter.
+		var rw ResponseWriter = NewResponseWrapper(w)
+		hj, ok := rw.(Hijacker)
+		if !ok {
+			t.Errorf("ResponseWrapper is not a Hijacker")
+			return
+		}
+		c, _, err := hj.Hijack()
+		if err != nil {
+			t.Errorf("Hijack = %v", err)
+			return
+		}
+		defer c.Close()
+		io.WriteString(c, "HTTP/1.0 200 OK\r\nX-Hijacked: yes\r\nContent-Length: 0\r\n\r\n")
+	}))
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.Header.Get("X-Hijacked") != "yes" {
+		t.Errorf("response header X-Hijacked = %q, want %q", res.Header.Get("X-Hijacked"), "yes")
+	}
+}
+
+// minimalResponseWriter implements only the methods of ResponseWriter.
+type minimalResponseWriter struct {
+	header Header
+	code   int
+	body   strings.Builder
+}
+
+func (w *minimalResponseWriter) Header() Header              { return w.header }
+func (w *minimalResponseWriter) WriteHeader(code int)        { w.code = code }
+func (w *minimalResponseWriter) Write(p []byte) (int, error) { return w.body.Write(p) }
+
+// fullResponseWriter has all of the optional methods of a ResponseWriter.
+type fullResponseWriter struct {
+	minimalResponseWriter
+	readFrom bool // ReadFrom was called
+}
+
+func (w *fullResponseWriter) Flush() {}
+func (w *fullResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("hijacked")
+}
+func (w *fullResponseWriter) Push(string, *PushOptions) error { return nil }
+func (w *fullResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(&w.body, src)
+}
+func (w *fullResponseWriter) SetReadDeadline(deadline time.Time) error  { return nil }
+func (w *fullResponseWriter) SetWriteDeadline(deadline time.Time) error { return nil }
+func (w *fullResponseWriter) EnableFullDuplex() error                   { return nil }
+
+func TestResponseWrapperMethodSet(t *testing.T) {
+	for _, w := range []ResponseWriter{
+		&minimalResponseWriter{header: Header{}},
+		&fullResponseWriter{minimalResponseWriter: minimalResponseWriter{header: Header{}}},
+	} {
+		var rw any = NewResponseWrapper(w)
+		if _, ok := rw.(Flusher); !ok {
+			t.Errorf("ResponseWrapper of %T is not a Flusher", w)
+		}
+		if _, ok := rw.(Hijacker); !ok {
+			t.Errorf("ResponseWrapper of %T is not a Hijacker", w)
+		}
+		if _, ok := rw.(Pusher); !ok {
+			t.Errorf("ResponseWrapper of %T is not a Pusher", w)
+		}
+		if _, ok := rw.(io.ReaderFrom); !ok {
+			t.Errorf("ResponseWrapper of %T is not an io.ReaderFrom", w)
+		}
+	}
+}
+
+func TestResponseWrapperForwards(t *testing.T) {
+	w := &fullResponseWriter{minimalResponseWriter: minimalResponseWriter{header: Header{}}}
+	rw := NewResponseWrapper(w)
+	if _, _, err := rw.Hijack(); err == nil || err.Error() != "hijacked" {
+		t.Errorf("Hijack = %v, want the wrapped ResponseWriter's error", err)
+	}
+	if err := rw.Push("/style.css", nil); err != nil {
+		t.Errorf("Push = %v, want nil", err)
+	}
+	n, err := rw.ReadFrom(strings.NewReader("body"))
+	if err != nil || n != 4 {
+		t.Errorf("ReadFrom = %v, %v; want 4, nil", n, err)
+	}
+	if !w.readFrom {
+		t.Errorf("ReadFrom did not call the wrapped ResponseWriter's ReadFrom")
+	}
+	if rw.Status() != StatusOK || rw.BytesWritten() != 4 || rw.FirstByteTime().IsZero() {
+		t.Errorf("recorded status %v, %v bytes, first byte at %v; want 200, 4, non-zero", rw.Status(), rw.BytesWritten(), rw.FirstByteTime())
+	}
+}
+
+func TestResponseWrapperSkippedWriteHeader(t *testing.T) {
+	w := &minimalResponseWriter{header: Header{}}
+	rw := NewResponseWrapper(w)
+	rw.OnWriteHeader = func(code int, next func(int)) {}
+	rw.WriteHeader(StatusNotFound)
+	if got := rw.Status(); got != 0 {
+		t.Errorf("Status() = %v before writing the body, want 0", got)
+	}
+	rw.Write([]byte("body"))
+	if got := rw.Status(); got != StatusOK {
+		t.Errorf("Status() = %v after writing the body, want %v", got, StatusOK)
+	}
+}
+
+func TestResponseWrapperNotSupported(t *testing.T) {
+	w := &minimalResponseWriter{header: Header{}}
+	rw := NewResponseWrapper(w)
+	if rw.Unwrap() != w {
+		t.Errorf("Unwrap() = %v, want %v", rw.Unwrap(), w)
+	}
+	rw.Flush() // does not panic
+	if !rw.FirstByteTime().IsZero() {
+		t.Errorf("FirstByteTime() = %v after unsupported Flush, want zero", rw.FirstByteTime())
+	}
+	ctl := NewResponseController(rw)
+	for _, test := range []struct {
+		name string
+		err  error
+	}{
+		{"Hijack", func() error { _, _, err := rw.Hijack(); return err }()},
+		{"Push", rw.Push("/style.css", nil)},
+		{"SetReadDeadline", ctl.SetReadDeadline(time.Time{})},
+		{"SetWriteDeadline", ctl.SetWriteDeadline(time.Time{})},
+		{"EnableFullDuplex", ctl.EnableFullDuplex()},
+	} {
+		if !errors.Is(test.err, ErrNotSupported) {
+			t.Errorf("ResponseController.%v = %v, want ErrNotSupported", test.name, test.err)
+		}
+	}
+
+	// Copying and WriteString work without support from w.
+	n, err := io.Copy(rw, strings.NewReader("read "))
+	if err != nil || n != 5 {
+		t.Errorf("io.Copy = %v, %v; want 5, nil", n, err)
+	}
+	if n, err := rw.WriteString("string"); err != nil || n != 6 {
+		t.Errorf("WriteString = %v, %v; want 6, nil", n, err)
+	}
+	if got, want := w.body.String(), "read string"; got != want {
+		t.Errorf("wrapped body = %q, want %q", got, want)
+	}
+	if w.code != StatusOK || rw.Status() != StatusOK || rw.BytesWritten() != 11 {
+		t.Errorf("wrapped status %v, recorded status %v, %v bytes; want 200, 200, 11", w.code, rw.Status(), rw.BytesWritten())
+	}
+}
@@ -213,3 +213,28 @@ func (mux *serveMux121) shouldRedirectRLocked(host, path string) bool {
 
 	return false
 }
+
+// matchRequest implements ServeMux.Match. It is new, rather than
+// derived from Go 1.21: it follows findHandler, but reports no match
+// where findHandler would redirect.
+func (mux *serveMux121) matchRequest(r *Request) (RouteMatch, bool) {
+	path := r.URL.Path
+	// CONNECT requests are not canonicalized, and their
+	// trailing-slash redirect uses r.URL.Host.
+	host, redirectHost := r.Host, r.URL.Host
+	if r.Method != "CONNECT" {
+		host = stripHostPort(r.Host)
+		redirectHost = host
+		if cleanPath(path) != path {
+			return RouteMatch{}, false
+		}
+	}
+	if _, ok := mux.redirectToPathSlash(redirectHost, path, r.URL); ok {
+		return RouteMatch{}, false
+	}
+	h, pattern := mux.handler(host, path)
+	if pattern == "" {
+		return RouteMatch{}, false
+	}
+	return RouteMatch{Pattern: pattern, Handler: h}, true
+}
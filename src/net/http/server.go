@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"internal/godebug"
 	"io"
+	"iter"
 	"log"
 	"maps"
 	"math/rand"
@@ -2013,6 +2014,16 @@ func (c *conn) serve(ctx context.Context) {
 	c.bufr = newBufioReader(c.r)
 	c.bufw = newBufioWriterSize(checkConnErrorWriter{c}, 4<<10)
 
+	protos := c.server.protocols()
+	if c.tlsState == nil && protos.UnencryptedHTTP2() {
+		if c.maybeServeUnencryptedHTTP2(ctx) {
+			return
+		}
+	}
+	if !protos.HTTP1() {
+		return
+	}
+
 	for {
 		w, err := c.readRequest(ctx)
 		if c.r.remain != c.server.initialReadLimitSize() {
@@ -2503,10 +2514,17 @@ func RedirectHandler(url string, code int) Handler {
 //     This change mostly affects how paths with %2F escapes adjacent to slashes are treated.
 //     See https://go.dev/issue/21955 for details.
 type ServeMux struct {
-	mu     sync.RWMutex
-	tree   routingNode
-	index  routingIndex
-	mux121 serveMux121 // used only when GODEBUG=httpmuxgo121=1
+	mu       sync.RWMutex
+	tree     routingNode
+	index    routingIndex
+	patterns []muxPattern // in registration order
+	mux121   serveMux121  // used only when GODEBUG=httpmuxgo121=1
+}
+
+// A muxPattern is a pattern registered with a ServeMux.
+type muxPattern struct {
+	pat     *pattern
+	handler Handler
 }
 
 // NewServeMux allocates and returns a new [ServeMux].
@@ -2577,6 +2595,113 @@ func (mux *ServeMux) Handler(r *Request) (h Handler, pattern string) {
 	return h, p
 }
 
+// Patterns returns an iterator over the patterns registered with mux
+// and their handlers, in the order in which they were registered.
+// Patterns registered while the iteration is in progress may or may
+// not be visited.
+//
+// When the GODEBUG setting httpmuxgo121=1 is in effect, the patterns
+// are visited in lexical order.
+func (mux *ServeMux) Patterns() iter.Seq2[string, Handler] {
+	return func(yield func(string, Handler) bool) {
+		if use121 {
+			mux.mux121.mu.RLock()
+			entries := slices.SortedFunc(maps.Values(mux.mux121.m), func(a, b muxEntry) int {
+				return strings.Compare(a.pattern, b.pattern)
+			})
+			mux.mux121.mu.RUnlock()
+			for _, e := range entries {
+				if !yield(e.pattern, e.h) {
+					return
+				}
+			}
+			return
+		}
+		mux.mu.RLock()
+		patterns := mux.patterns
+		mux.mu.RUnlock()
+		for _, p := range patterns {
+			if !yield(p.pat.String(), p.handler) {
+				return
+			}
+		}
+	}
+}
+
+// A RouteMatch describes the registered pattern of a [ServeMux]
+// which matches a request.
+type RouteMatch struct {
+	// Pattern is the matching pattern, as passed to [ServeMux.Handle].
+	Pattern string
+
+	// Handler is the handler registered with Pattern.
+	Handler Handler
+
+	// Values holds the value of each named wildcard in Pattern,
+	// as [Request.PathValue] would report it to Handler.
+	Values map[string]string
+}
+
+// Match reports which registered pattern of mux matches r, without
+// serving the request. It consults r.Method, r.Host, and r.URL in the
+// same way as [ServeMux.Handler].
+//
+// Match reports false if no pattern matches r, including when
+// mux would redirect r to a canonical or trailing-slash path,
+// or reply with “405 Method Not Allowed”.
+func (mux *ServeMux) Match(r *Request) (RouteMatch, bool) {
+	if use121 {
+		return mux.mux121.matchRequest(r)
+	}
+	h, patStr, pat, matches := mux.findHandler(r)
+	if pat == nil {
+		return RouteMatch{}, false
+	}
+	m := RouteMatch{Pattern: patStr, Handler: h}
+	i := 0
+	for _, seg := range pat.segments {
+		if seg.wild && seg.s != "" {
+			if m.Values == nil {
+				m.Values = make(map[string]string)
+			}
+			m.Values[seg.s] = matches[i]
+			i++
+		}
+	}
+	return m, true
+}
+
+// BuildPath returns a URL path matched by the [ServeMux] pattern,
+// with the named wildcards in the pattern replaced by values.
+// The method and host of the pattern, if any, are ignored.
+//
+// Each value is escaped as needed, so that [Request.PathValue] reports
+// it unchanged for a request with the resulting path. A single-segment
+// wildcard's value may contain slashes, which are escaped as %2F.
+// A "..." wildcard's value may span several segments, and its slashes
+// are not escaped.
+//
+// The resulting path is clean, and so is served without redirection.
+// BuildPath returns an error if that is not possible, for example
+// because a value is empty or consists of "." or "..". It also
+// returns an error if the pattern is invalid, if a named wildcard in
+// the pattern has no value, or if values has an entry that does not
+// correspond to a named wildcard.
+//
+// When registered with a [ServeMux], a more specific pattern may
+// take precedence over the pattern for the resulting path.
+func BuildPath(pattern string, values map[string]string) (string, error) {
+	pat, err := parsePattern(pattern)
+	if err != nil {
+		return "", fmt.Errorf("parsing %q: %w", pattern, err)
+	}
+	path, err := pat.buildPath(values)
+	if err != nil {
+		return "", fmt.Errorf("pattern %q: %w", pattern, err)
+	}
+	return path, nil
+}
+
 // findHandler finds a handler for a request.
 // If there is a matching handler, it returns it and the pattern that matched.
 // Otherwise it returns a Redirect or NotFound handler with the path that would match
@@ -2837,6 +2962,7 @@ func (mux *ServeMux) registerErr(patstr string, handler Handler) error {
 	}
 	mux.tree.addPattern(pat, handler)
 	mux.index.addPattern(pat)
+	mux.patterns = append(mux.patterns, muxPattern{pat, handler})
 	return nil
 }
 
@@ -2944,6 +3070,9 @@ type Server struct {
 	// automatically closed when the function returns.
 	// If TLSNextProto is not nil, HTTP/2 support is not enabled
 	// automatically.
+	//
+	// Historically, TLSNextProto was used to disable HTTP/2 support.
+	// The Server.Protocols field now provides a simpler way to do this.
 	TLSNextProto map[string]func(*Server, *tls.Conn, Handler)
 
 	// ConnState specifies an optional callback function that is
@@ -2977,6 +3106,19 @@ type Server struct {
 	// See https://go.dev/issue/67813.
 	HTTP2 *HTTP2Config
 
+	// Protocols is the set of protocols accepted by the server.
+	//
+	// If Protocols includes UnencryptedHTTP2, the server will accept
+	// unencrypted HTTP/2 connections with prior knowledge: connections
+	// that begin with the HTTP/2 client connection preface rather than
+	// an HTTP/1 request. The server can serve both HTTP/1 and unencrypted
+	// HTTP/2 on the same port.
+	//
+	// If Protocols is nil, the default is usually HTTP/1 and HTTP/2.
+	// If TLSNextProto is non-nil and does not contain an "h2" entry,
+	// the default is HTTP/1 only.
+	Protocols *Protocols
+
 	inShutdown atomic.Bool // true when server is in shutdown
 
 	disableKeepAlives atomic.Bool
@@ -3284,7 +3426,10 @@ func (s *Server) shouldConfigureHTTP2ForServe() bool {
 	// passed this tls.Config to tls.NewListener. And if they did,
 	// it's too late anyway to fix it. It would only be potentially racy.
 	// See Issue 15908.
-	return slices.Contains(s.TLSConfig.NextProtos, http2NextProtoTLS)
+	//
+	// Unencrypted HTTP/2 doesn't touch the tls.Config, so configure
+	// HTTP/2 whenever it is enabled.
+	return s.protocols().UnencryptedHTTP2() || slices.Contains(s.TLSConfig.NextProtos, http2NextProtoTLS)
 }
 
 // ErrServerClosed is returned by the [Server.Serve], [ServeTLS], [ListenAndServe],
@@ -3387,9 +3532,7 @@ func (s *Server) ServeTLS(l net.Listener, certFile, keyFile string) error {
 	}
 
 	config := cloneTLSConfig(s.TLSConfig)
-	if !slices.Contains(config.NextProtos, "http/1.1") {
-		config.NextProtos = append(config.NextProtos, "http/1.1")
-	}
+	config.NextProtos = adjustNextProtos(config.NextProtos, s.protocols())
 
 	configHasCert := len(config.Certificates) > 0 || config.GetCertificate != nil || config.GetConfigForClient != nil
 	if !configHasCert || certFile != "" || keyFile != "" {
@@ -3602,12 +3745,43 @@ func (s *Server) onceSetNextProtoDefaults() {
 		http2server.IncNonDefault()
 		return
 	}
-	// Enable HTTP/2 by default if the user hasn't otherwise
-	// configured their TLSNextProto map.
-	if s.TLSNextProto == nil {
-		conf := &http2Server{}
-		s.nextProtoErr = http2ConfigureServer(s, conf)
+	p := s.protocols()
+	if !p.HTTP2() && !p.UnencryptedHTTP2() {
+		return
+	}
+	if _, ok := s.TLSNextProto[http2NextProtoTLS]; ok {
+		// The user has already configured HTTP/2,
+		// probably with golang.org/x/net/http2.ConfigureServer.
+		return
+	}
+	conf := &http2Server{}
+	s.nextProtoErr = http2ConfigureServer(s, conf)
+}
+
+// protocols returns the set of protocols served by s.
+func (s *Server) protocols() Protocols {
+	if s.Protocols != nil {
+		return *s.Protocols
+	}
+
+	// The historic way of disabling HTTP/2 is to set TLSNextProto to
+	// a non-nil map with no "h2" entry.
+	_, hasH2 := s.TLSNextProto[http2NextProtoTLS]
+	http2Disabled := s.TLSNextProto != nil && !hasH2
+
+	// If GODEBUG=http2server=0, then HTTP/2 is disabled unless
+	// the user has manually added an "h2" entry to TLSNextProto
+	// (probably by using golang.org/x/net/http2 directly).
+	if http2server.Value() == "0" && !hasH2 {
+		http2Disabled = true
+	}
+
+	var p Protocols
+	p.SetHTTP1(true) // default always includes HTTP/1
+	if !http2Disabled {
+		p.SetHTTP2(true)
 	}
+	return p
 }
 
 // TimeoutHandler returns a [Handler] that runs h with the given time limit.
@@ -3792,12 +3966,59 @@ func (globalOptionsHandler) ServeHTTP(w ResponseWriter, r *Request) {
 	}
 }
 
+// maybeServeUnencryptedHTTP2 hands c off to the HTTP/2 server if the
+// client starts the connection with the HTTP/2 client preface.
+// It reports whether it did so.
+func (c *conn) maybeServeUnencryptedHTTP2(ctx context.Context) bool {
+	fn, ok := c.server.TLSNextProto[nextProtoUnencryptedHTTP2]
+	if !ok {
+		return false
+	}
+	if d := c.server.readHeaderTimeout(); d > 0 {
+		c.rwc.SetReadDeadline(time.Now().Add(d))
+	}
+	c.r.setReadLimit(c.server.initialReadLimitSize())
+	const preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+	hasPreface := func(preface string) bool {
+		got, err := c.bufr.Peek(len(preface))
+		return err == nil && string(got) == preface
+	}
+	// Check for a prefix of the preface first, so that a short
+	// HTTP/1 request isn't left waiting for more bytes.
+	if !hasPreface(preface[:len("PRI * HTTP/2.0")]) || !hasPreface(preface) {
+		return false
+	}
+	c.rwc.SetReadDeadline(time.Time{})
+	c.r.setInfiniteReadLimit()
+
+	// The HTTP/2 server expects the preface to have been read.
+	// Any bytes following it have already been read from c.rwc
+	// into c.bufr; pass them along with the connection.
+	c.bufr.Discard(len(preface))
+	buffered, _ := c.bufr.Peek(c.bufr.Buffered())
+	nc := &bufferedConn{c.rwc, io.MultiReader(bytes.NewReader(bytes.Clone(buffered)), c.rwc)}
+
+	h := initALPNRequest{ctx, nc, serverHandler{c.server}}
+	c.setState(c.rwc, StateActive, skipHooks)
+	fn(c.server, unencryptedTLSConn(nc), h)
+	return true
+}
+
+// bufferedConn is a net.Conn whose reads come from r.
+type bufferedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
 // initALPNRequest is an HTTP handler that initializes certain
 // uninitialized fields in its *Request. Such partially-initialized
-// Requests come from ALPN protocol handlers.
+// Requests come from ALPN protocol handlers and from unencrypted
+// HTTP/2 connections.
 type initALPNRequest struct {
 	ctx context.Context
-	c   *tls.Conn
+	c   net.Conn // a *tls.Conn for ALPN protocol handlers
 	h   serverHandler
 }
 
@@ -3808,9 +4029,9 @@ type initALPNRequest struct {
 func (h initALPNRequest) BaseContext() context.Context { return h.ctx }
 
 func (h initALPNRequest) ServeHTTP(rw ResponseWriter, req *Request) {
-	if req.TLS == nil {
+	if tc, ok := h.c.(*tls.Conn); ok && req.TLS == nil {
 		req.TLS = &tls.ConnectionState{}
-		*req.TLS = h.c.ConnectionState()
+		*req.TLS = tc.ConnectionState()
 	}
 	if req.Body == nil {
 		req.Body = NoBody
@@ -8,8 +8,11 @@ package http
 
 import (
 	"fmt"
+	"maps"
 	"net/url"
 	"regexp"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -250,6 +253,213 @@ func TestEscapedPathsAndPatterns(t *testing.T) {
 	t.Run("1.21", func(t *testing.T) { run(t, true) })
 }
 
+func TestServeMuxPatterns(t *testing.T) {
+	run := func(t *testing.T, test121 bool, want []string) {
+		defer func(u bool) { use121 = u }(use121)
+		use121 = test121
+
+		mux := NewServeMux()
+		pats := []string{"/b", "GET /a/{x}", "/", "example.com/c/"}
+		for i, pat := range pats {
+			mux.Handle(pat, &handler{i})
+		}
+		var got []string
+		for pat, h := range mux.Patterns() {
+			got = append(got, pat)
+			if i := h.(*handler).i; pats[i] != pat {
+				t.Errorf("pattern %q has the handler for %q", pat, pats[i])
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		// Stopping iteration early.
+		for range mux.Patterns() {
+			break
+		}
+	}
+	t.Run("latest", func(t *testing.T) {
+		run(t, false, []string{"/b", "GET /a/{x}", "/", "example.com/c/"})
+	})
+	t.Run("1.21", func(t *testing.T) {
+		run(t, true, []string{"/", "/b", "GET /a/{x}", "example.com/c/"})
+	})
+}
+
+func TestServeMuxMatch(t *testing.T) {
+	mux := NewServeMux()
+	for i, pat := range []string{
+		"/",
+		"/foo/",
+		"GET /items/{id}",
+		"/files/{path...}",
+		"/a/{x}/b/{y}",
+		"example.com/host/{$}",
+	} {
+		mux.Handle(pat, &handler{i})
+	}
+	for _, test := range []struct {
+		method  string
+		host    string
+		path    string
+		want    string // pattern; empty for no match
+		handler int
+		values  map[string]string
+	}{
+		{"GET", "", "/", "/", 0, nil},
+		{"GET", "", "/other", "/", 0, nil},
+		{"GET", "", "/foo/bar", "/foo/", 1, nil},
+		{"GET", "", "/items/12", "GET /items/{id}", 2, map[string]string{"id": "12"}},
+		{"HEAD", "", "/items/12", "GET /items/{id}", 2, map[string]string{"id": "12"}},
+		{"GET", "", "/items/a%2Fb", "GET /items/{id}", 2, map[string]string{"id": "a/b"}},
+		{"GET", "", "/files/", "/files/{path...}", 3, map[string]string{"path": ""}},
+		{"GET", "", "/files/x/y%20z", "/files/{path...}", 3, map[string]string{"path": "x/y z"}},
+		{"GET", "", "/a/1/b/2", "/a/{x}/b/{y}", 4, map[string]string{"x": "1", "y": "2"}},
+		{"GET", "example.com:8080", "/host/", "example.com/host/{$}", 5, nil},
+		// Requests that ServeMux redirects do not match.
+		{"GET", "", "/foo", "", 0, nil},
+		{"GET", "", "/foo//bar", "", 0, nil},
+		{"GET", "", "/foo/../foo/bar", "", 0, nil},
+		{"GET", "", "/files", "", 0, nil},
+		// Patterns without a method match all methods.
+		{"POST", "", "/items/12", "/", 0, nil},
+	} {
+		u, err := url.Parse(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &Request{
+			Method: test.method,
+			Host:   test.host,
+			URL:    u,
+		}
+		if r.Host == "" {
+			r.Host = "other.example"
+		}
+		m, ok := mux.Match(r)
+		if ok != (test.want != "") || m.Pattern != test.want {
+			t.Errorf("%v %v%v: matched %q, %v; want %q", test.method, test.host, test.path, m.Pattern, ok, test.want)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if h := m.Handler.(*handler); h.i != test.handler {
+			t.Errorf("%v %v%v: handler %v, want %v", test.method, test.host, test.path, h.i, test.handler)
+		}
+		if !maps.Equal(m.Values, test.values) {
+			t.Errorf("%v %v%v: values %v, want %v", test.method, test.host, test.path, m.Values, test.values)
+		}
+	}
+
+	// A request which would get a 405 Method Not Allowed does not match.
+	mux = NewServeMux()
+	mux.Handle("GET /items/{id}", &handler{})
+	r := &Request{Method: "POST", Host: "example.com", URL: &url.URL{Path: "/items/12"}}
+	if m, ok := mux.Match(r); ok {
+		t.Errorf("POST /items/12: matched %q, want no match", m.Pattern)
+	}
+}
+
+func TestServeMuxMatch121(t *testing.T) {
+	defer func(u bool) { use121 = u }(use121)
+	use121 = true
+
+	mux := NewServeMux()
+	mux.Handle("/", &handler{0})
+	mux.Handle("/foo/", &handler{1})
+	for _, test := range []struct {
+		path string
+		want string
+	}{
+		{"/", "/"},
+		{"/foo/x", "/foo/"},
+		{"/foo", ""},
+		{"//foo/x", ""},
+	} {
+		r := &Request{Method: "GET", Host: "example.com", URL: &url.URL{Path: test.path}}
+		m, ok := mux.Match(r)
+		if ok != (test.want != "") || m.Pattern != test.want {
+			t.Errorf("%v: matched %q, %v; want %q", test.path, m.Pattern, ok, test.want)
+		}
+	}
+}
+
+func TestBuildPath(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		values  map[string]string
+		want    string
+	}{
+		{"/", nil, "/"},
+		{"/{$}", nil, "/"},
+		{"/a/b", nil, "/a/b"},
+		{"GET example.com/a/", nil, "/a/"},
+		{"/a/{$}", nil, "/a/"},
+		{"/a%20b/{x}", map[string]string{"x": "c"}, "/a%20b/c"},
+		{"/items/{id}", map[string]string{"id": "12"}, "/items/12"},
+		{"/items/{id}", map[string]string{"id": "a/b"}, "/items/a%2Fb"},
+		{"/items/{id}", map[string]string{"id": "100% x?#"}, "/items/100%25%20x%3F%23"},
+		{"/items/{id}/{$}", map[string]string{"id": "1"}, "/items/1/"},
+		{"/files/{path...}", map[string]string{"path": ""}, "/files/"},
+		{"/files/{path...}", map[string]string{"path": "a/b c/d"}, "/files/a/b%20c/d"},
+		{"/files/{path...}", map[string]string{"path": "dir/"}, "/files/dir/"},
+		{"/{a}/{b}", map[string]string{"a": "x", "b": "y"}, "/x/y"},
+	} {
+		got, err := BuildPath(test.pattern, test.values)
+		if err != nil || got != test.want {
+			t.Errorf("BuildPath(%q, %v) = %q, %v; want %q, nil", test.pattern, test.values, got, err, test.want)
+			continue
+		}
+
+		// The path matches the pattern, with the same values.
+		mux := NewServeMux()
+		mux.Handle(test.pattern, &handler{})
+		u, err := url.Parse(got)
+		if err != nil {
+			t.Errorf("%q: %v", got, err)
+			continue
+		}
+		r := &Request{Method: "GET", Host: "example.com", URL: u}
+		m, ok := mux.Match(r)
+		if !ok {
+			t.Errorf("BuildPath(%q, %v) = %q, which does not match the pattern", test.pattern, test.values, got)
+			continue
+		}
+		if len(m.Values) != 0 || len(test.values) != 0 {
+			if !maps.Equal(m.Values, test.values) {
+				t.Errorf("BuildPath(%q, %v) = %q, which matches with values %v", test.pattern, test.values, got, m.Values)
+			}
+		}
+	}
+}
+
+func TestBuildPathError(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		values  map[string]string
+		wantErr string
+	}{
+		{"", nil, "parsing"},
+		{"/{x", nil, "parsing"},
+		{"/{x}", nil, `missing value for wildcard "x"`},
+		{"/{x}", map[string]string{"x": "1", "y": "2"}, `no wildcard named "y"`},
+		{"/a", map[string]string{"a": "1"}, `no wildcard named "a"`},
+		{"/{x}", map[string]string{"x": ""}, "cannot appear in a clean path"},
+		{"/{x}", map[string]string{"x": "."}, "cannot appear in a clean path"},
+		{"/{x}", map[string]string{"x": ".."}, "cannot appear in a clean path"},
+		{"/{x...}", map[string]string{"x": "a//b"}, "cannot appear in a clean path"},
+		{"/{x...}", map[string]string{"x": "/a"}, "cannot appear in a clean path"},
+		{"/{x...}", map[string]string{"x": "a/../b"}, "cannot appear in a clean path"},
+		{"/a/./b", nil, "cannot appear in a clean path"},
+	} {
+		_, err := BuildPath(test.pattern, test.values)
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("BuildPath(%q, %v): error %v, want error containing %q", test.pattern, test.values, err, test.wantErr)
+		}
+	}
+}
+
 func TestCleanPath(t *testing.T) {
 	for _, test := range []struct {
 		in, want string
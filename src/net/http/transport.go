@@ -11,7 +11,6 @@ package http
 
 import (
 	"bufio"
-	"compress/gzip"
 	"container/list"
 	"context"
 	"crypto/tls"
@@ -23,7 +22,6 @@ import (
 	"maps"
 	"net"
 	"net/http/httptrace"
-	"net/http/internal/ascii"
 	"net/textproto"
 	"net/url"
 	"reflect"
@@ -200,6 +198,9 @@ type Transport struct {
 	// decoded in the Response.Body. However, if the user
 	// explicitly requested gzip it is not automatically
 	// uncompressed.
+	//
+	// Content codings added with [RegisterContentCoding] are
+	// requested and decoded in the same way as gzip.
 	DisableCompression bool
 
 	// MaxIdleConns controls the maximum number of idle (keep-alive)
@@ -249,6 +250,9 @@ type Transport struct {
 	// must return a RoundTripper that then handles the request.
 	// If TLSNextProto is not nil, HTTP/2 support is not enabled
 	// automatically.
+	//
+	// Historically, TLSNextProto was used to disable HTTP/2 support.
+	// The Transport.Protocols field now provides a simpler way to do this.
 	TLSNextProto map[string]func(authority string, c *tls.Conn) RoundTripper
 
 	// ProxyConnectHeader optionally specifies headers to send to
@@ -300,6 +304,18 @@ type Transport struct {
 	// This field does not yet have any effect.
 	// See https://go.dev/issue/67813.
 	HTTP2 *HTTP2Config
+
+	// Protocols is the set of protocols supported by the transport.
+	//
+	// If Protocols includes UnencryptedHTTP2 and does not include HTTP1,
+	// the transport will use unencrypted HTTP/2 with prior knowledge
+	// for requests to http:// URLs.
+	//
+	// If Protocols is nil, the default is usually HTTP/1 and HTTP/2.
+	// If TLSNextProto is non-nil and does not contain an "h2" entry,
+	// or if a custom TLSClientConfig or dialer is set and ForceAttemptHTTP2
+	// is false, the default is HTTP/1 only.
+	Protocols *Protocols
 }
 
 func (t *Transport) writeBufferSize() int {
@@ -349,6 +365,10 @@ func (t *Transport) Clone() *Transport {
 		t2.HTTP2 = &HTTP2Config{}
 		*t2.HTTP2 = *t.HTTP2
 	}
+	if t.Protocols != nil {
+		t2.Protocols = &Protocols{}
+		*t2.Protocols = *t.Protocols
+	}
 	if !t.tlsNextProtoWasNil {
 		npm := maps.Clone(t.TLSNextProto)
 		if npm == nil {
@@ -399,18 +419,12 @@ func (t *Transport) onceSetNextProtoDefaults() {
 		}
 	}
 
-	if t.TLSNextProto != nil {
-		// This is the documented way to disable http2 on a
-		// Transport.
+	if _, ok := t.TLSNextProto[http2NextProtoTLS]; ok {
+		// There's an existing HTTP/2 implementation installed.
 		return
 	}
-	if !t.ForceAttemptHTTP2 && (t.TLSClientConfig != nil || t.Dial != nil || t.DialContext != nil || t.hasCustomTLSDialer()) {
-		// Be conservative and don't automatically enable
-		// http2 if they've specified a custom TLS config or
-		// custom dialers. Let them opt-in themselves via
-		// http2.ConfigureTransport so we don't surprise them
-		// by modifying their tls.Config. Issue 14275.
-		// However, if ForceAttemptHTTP2 is true, it overrides the above checks.
+	protocols := t.protocols()
+	if !protocols.HTTP2() && !protocols.UnencryptedHTTP2() {
 		return
 	}
 	if omitBundledHTTP2 {
@@ -439,6 +453,34 @@ func (t *Transport) onceSetNextProtoDefaults() {
 	}
 }
 
+// protocols returns the set of protocols supported by t.
+func (t *Transport) protocols() Protocols {
+	if t.Protocols != nil {
+		return *t.Protocols
+	}
+	var p Protocols
+	p.SetHTTP1(true) // default always includes HTTP/1
+	switch {
+	case t.TLSNextProto != nil:
+		// Setting TLSNextProto to a non-nil map without an "h2" entry
+		// is the documented way to disable HTTP/2 on a Transport.
+		if t.TLSNextProto[http2NextProtoTLS] != nil {
+			p.SetHTTP2(true)
+		}
+	case !t.ForceAttemptHTTP2 && (t.TLSClientConfig != nil || t.Dial != nil || t.DialContext != nil || t.hasCustomTLSDialer()):
+		// Be conservative and don't automatically enable
+		// http2 if they've specified a custom TLS config or
+		// custom dialers. Let them opt-in themselves via
+		// http2.ConfigureTransport so we don't surprise them
+		// by modifying their tls.Config. Issue 14275.
+		// However, if ForceAttemptHTTP2 is true, it overrides the above checks.
+	case http2client.Value() == "0":
+	default:
+		p.SetHTTP2(true)
+	}
+	return p
+}
+
 // ProxyFromEnvironment returns the URL of the proxy to use for a
 // given request, as indicated by the environment variables
 // HTTP_PROXY, HTTPS_PROXY and NO_PROXY (or the lowercase versions
@@ -650,7 +692,23 @@ func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
 		var resp *Response
 		if pconn.alt != nil {
 			// HTTP/2 path.
-			resp, err = pconn.alt.RoundTrip(req)
+			//
+			// The HTTP/2 transport requests and decodes gzip on its own.
+			// If other content codings are registered, advertise them
+			// here and decode the response ourselves.
+			codings := t.requestCodings(req)
+			if codings != nil && !codings.onlyGzip() {
+				r2 := new(Request)
+				*r2 = *req
+				r2.Header = req.Header.Clone()
+				r2.Header.Set("Accept-Encoding", codings.acceptEncoding)
+				resp, err = pconn.alt.RoundTrip(r2)
+				if err == nil {
+					decodeResponse(resp, codings)
+				}
+			} else {
+				resp, err = pconn.alt.RoundTrip(req)
+			}
 		} else {
 			resp, err = pconn.roundTrip(treq)
 		}
@@ -1645,6 +1703,8 @@ func (pconn *persistConn) addTLS(ctx context.Context, name string, trace *httptr
 	}
 	if pconn.cacheKey.onlyH1 {
 		cfg.NextProtos = nil
+	} else if p := pconn.t.Protocols; p != nil {
+		cfg.NextProtos = adjustNextProtos(cfg.NextProtos, *p)
 	}
 	plainConn := pconn.conn
 	tlsConn := tls.Client(plainConn, cfg)
@@ -1879,6 +1939,27 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 		}
 	}
 
+	// Unencrypted HTTP/2 with prior knowledge, used when the transport
+	// is not permitted to fall back to HTTP/1.
+	if pconn.tlsState == nil && !pconn.isProxy && t.Protocols != nil &&
+		t.Protocols.UnencryptedHTTP2() && !t.Protocols.HTTP1() {
+		next, ok := t.TLSNextProto[nextProtoUnencryptedHTTP2]
+		if !ok {
+			pconn.conn.Close()
+			return nil, errors.New("http: Transport does not support unencrypted HTTP/2")
+		}
+		alt := next(cm.targetAddr, unencryptedTLSConn(pconn.conn))
+		if e, ok := alt.(erringRoundTripper); ok {
+			// pconn.conn was closed by next (http2configureTransports.upgradeFn).
+			return nil, e.RoundTripErr()
+		}
+		return &persistConn{t: t, cacheKey: pconn.cacheKey, alt: alt}, nil
+	}
+	if t.Protocols != nil && !t.Protocols.HTTP1() {
+		pconn.conn.Close()
+		return nil, errors.New("http: HTTP/1 is disabled and the server did not negotiate HTTP/2")
+	}
+
 	pconn.br = bufio.NewReaderSize(pconn, t.readBufferSize())
 	pconn.bw = bufio.NewWriterSize(persistConnWriter{pconn}, t.writeBufferSize())
 
@@ -2316,12 +2397,8 @@ func (pc *persistConn) readLoop() {
 		}
 
 		resp.Body = body
-		if rc.addedGzip && ascii.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
-			resp.Body = &gzipReader{body: body}
-			resp.Header.Del("Content-Encoding")
-			resp.Header.Del("Content-Length")
-			resp.ContentLength = -1
-			resp.Uncompressed = true
+		if rc.addedCodings != nil {
+			decodeResponse(resp, rc.addedCodings)
 		}
 
 		select {
@@ -2614,9 +2691,10 @@ type requestAndChan struct {
 	ch   chan responseAndError // unbuffered; always send in select on callerGone
 
 	// whether the Transport (as opposed to the user client code)
-	// added the Accept-Encoding gzip header. If the Transport
-	// set it, only then do we transparently decode the gzip.
-	addedGzip bool
+	// added the Accept-Encoding header. If the Transport
+	// set it, only then do we transparently decode the response
+	// using one of the codings it advertised.
+	addedCodings *contentCodings
 
 	// Optional blocking chan for Expect: 100-continue (for send).
 	// If the request has an "Expect: 100-continue" header and
@@ -2690,27 +2768,11 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 
 	// Ask for a compressed version if the caller didn't set their
 	// own value for Accept-Encoding. We only attempt to
-	// uncompress the gzip stream if we were the layer that
+	// decode the response if we were the layer that
 	// requested it.
-	requestedGzip := false
-	if !pc.t.DisableCompression &&
-		req.Header.Get("Accept-Encoding") == "" &&
-		req.Header.Get("Range") == "" &&
-		req.Method != "HEAD" {
-		// Request gzip only, not deflate. Deflate is ambiguous and
-		// not as universally supported anyway.
-		// See: https://zlib.net/zlib_faq.html#faq39
-		//
-		// Note that we don't request this for HEAD requests,
-		// due to a bug in nginx:
-		//   https://trac.nginx.org/nginx/ticket/358
-		//   https://golang.org/issue/5522
-		//
-		// We don't request gzip if the request is for a range, since
-		// auto-decoding a portion of a gzipped document will just fail
-		// anyway. See https://golang.org/issue/8923
-		requestedGzip = true
-		req.extraHeaders().Set("Accept-Encoding", "gzip")
+	requestedCodings := pc.t.requestCodings(req.Request)
+	if requestedCodings != nil {
+		req.extraHeaders().Set("Accept-Encoding", requestedCodings.acceptEncoding)
 	}
 
 	var continueCh chan struct{}
@@ -2738,11 +2800,11 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 
 	resc := make(chan responseAndError)
 	pc.reqch <- requestAndChan{
-		treq:       req,
-		ch:         resc,
-		addedGzip:  requestedGzip,
-		continueCh: continueCh,
-		callerGone: gone,
+		treq:         req,
+		ch:           resc,
+		addedCodings: requestedCodings,
+		continueCh:   continueCh,
+		callerGone:   gone,
 	}
 
 	handleResponse := func(re responseAndError) (*Response, error) {
@@ -2957,39 +3019,43 @@ func (es *bodyEOFSignal) condfn(err error) error {
 	return err
 }
 
-// gzipReader wraps a response body so it can lazily
-// call gzip.NewReader on the first call to Read
-type gzipReader struct {
-	_    incomparable
-	body *bodyEOFSignal // underlying HTTP/1 response body framing
-	zr   *gzip.Reader   // lazily-initialized gzip reader
-	zerr error          // any error from gzip.NewReader; sticky
-}
-
-func (gz *gzipReader) Read(p []byte) (n int, err error) {
-	if gz.zr == nil {
-		if gz.zerr == nil {
-			gz.zr, gz.zerr = gzip.NewReader(gz.body)
-		}
-		if gz.zerr != nil {
-			return 0, gz.zerr
-		}
-	}
-
-	gz.body.mu.Lock()
-	if gz.body.closed {
-		err = errReadOnClosedResBody
-	}
-	gz.body.mu.Unlock()
-
-	if err != nil {
-		return 0, err
+// requestCodings returns the content codings that the Transport
+// should advertise for req, or nil if it should not request
+// compression.
+func (t *Transport) requestCodings(req *Request) *contentCodings {
+	if t.DisableCompression ||
+		req.Header.Get("Accept-Encoding") != "" ||
+		req.Header.Get("Range") != "" ||
+		req.Method == "HEAD" {
+		return nil
 	}
-	return gz.zr.Read(p)
+	// Request gzip and any registered codings, but not deflate.
+	// Deflate is ambiguous and not as universally supported anyway.
+	// See: https://zlib.net/zlib_faq.html#faq39
+	//
+	// Note that we don't request this for HEAD requests,
+	// due to a bug in nginx:
+	//   https://trac.nginx.org/nginx/ticket/358
+	//   https://golang.org/issue/5522
+	//
+	// We don't request compression if the request is for a range, since
+	// auto-decoding a portion of a compressed document will just fail
+	// anyway. See https://golang.org/issue/8923
+	return registeredCodings.Load()
 }
 
-func (gz *gzipReader) Close() error {
-	return gz.body.Close()
+// decodeResponse arranges for resp.Body to be transparently decoded
+// if its Content-Encoding is one of the codings in cc.
+func decodeResponse(resp *Response, cc *contentCodings) {
+	c := cc.lookupAccepted(resp.Header.Get("Content-Encoding"))
+	if c == nil {
+		return
+	}
+	resp.Body = &decodingReader{body: resp.Body, newReader: c.NewReader}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
 }
 
 type tlsHandshakeTimeoutError struct{}
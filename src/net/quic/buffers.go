@@ -0,0 +1,169 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+// A signal is a notification channel with a buffer of one.
+// Notifying a signal never blocks; multiple notifications
+// before the signal is received are coalesced.
+type signal chan struct{}
+
+func newSignal() signal {
+	return make(signal, 1)
+}
+
+func (s signal) notify() {
+	select {
+	case s <- struct{}{}:
+	default:
+	}
+}
+
+// A recvBuf reassembles data received out of order.
+type recvBuf struct {
+	start int64    // offset of buf[0]; data before start has been consumed
+	buf   []byte   // received data, with holes
+	have  rangeset // ranges of received data at or after start
+}
+
+// write stores data received at offset off.
+// Data before the start of the buffer is discarded.
+func (b *recvBuf) write(off int64, data []byte) {
+	if skip := b.start - off; skip > 0 {
+		if skip >= int64(len(data)) {
+			return
+		}
+		off += skip
+		data = data[skip:]
+	}
+	end := off + int64(len(data))
+	if need := int(end - b.start); need > len(b.buf) {
+		b.buf = append(b.buf, make([]byte, need-len(b.buf))...)
+	}
+	copy(b.buf[off-b.start:], data)
+	b.have.add(off, end)
+}
+
+// readable returns the number of contiguous bytes available to read.
+func (b *recvBuf) readable() int64 {
+	if len(b.have) == 0 || b.have[0].start != b.start {
+		return 0
+	}
+	return b.have[0].end - b.start
+}
+
+// peek returns the contiguous bytes available to read, without consuming them.
+func (b *recvBuf) peek() []byte {
+	return b.buf[:b.readable()]
+}
+
+// read consumes up to len(p) contiguous bytes into p.
+func (b *recvBuf) read(p []byte) int {
+	n := copy(p, b.peek())
+	b.discard(int64(n))
+	return n
+}
+
+// discard consumes n bytes from the start of the buffer.
+func (b *recvBuf) discard(n int64) {
+	if n <= 0 {
+		return
+	}
+	b.have.sub(b.start, b.start+n)
+	b.start += n
+	if n >= int64(len(b.buf)) {
+		b.buf = b.buf[:0]
+	} else {
+		b.buf = b.buf[n:]
+	}
+}
+
+// discardTo consumes all data up to offset end, received or not.
+func (b *recvBuf) discardTo(end int64) {
+	b.discard(end - b.start)
+}
+
+// A sendBuf holds data to be sent and retransmitted.
+type sendBuf struct {
+	start  int64    // offset of buf[0]; data before start has been acknowledged
+	buf    []byte   // data not yet acknowledged
+	unsent rangeset // ranges that need to be sent
+	acked  rangeset // acknowledged ranges at or after start
+}
+
+// end returns the offset after the last byte written to the buffer.
+func (b *sendBuf) end() int64 {
+	return b.start + int64(len(b.buf))
+}
+
+// write appends data to the buffer.
+func (b *sendBuf) write(data []byte) {
+	end := b.end()
+	b.buf = append(b.buf, data...)
+	b.unsent.add(end, b.end())
+}
+
+// next returns the next range of unsent data, limited to max bytes.
+func (b *sendBuf) next(max int64) (off int64, data []byte, ok bool) {
+	if len(b.unsent) == 0 {
+		return 0, nil, false
+	}
+	r := b.unsent[0]
+	off = r.start
+	size := min(r.size(), max)
+	return off, b.buf[off-b.start:][:size], true
+}
+
+// hasUnsent reports whether any data needs to be sent.
+func (b *sendBuf) hasUnsent() bool {
+	return len(b.unsent) > 0
+}
+
+// markSent records that [off, off+n) has been sent.
+func (b *sendBuf) markSent(off, n int64) {
+	b.unsent.sub(off, off+n)
+}
+
+// markLost records that [off, off+n) was lost and must be resent,
+// unless it has since been acknowledged.
+func (b *sendBuf) markLost(off, n int64) {
+	start := max(off, b.start)
+	end := off + n
+	for _, r := range b.acked {
+		if r.start >= end {
+			break
+		}
+		if r.start > start {
+			b.unsent.add(start, r.start)
+		}
+		start = max(start, r.end)
+	}
+	b.unsent.add(start, end)
+}
+
+// markAcked records that [off, off+n) has been acknowledged.
+func (b *sendBuf) markAcked(off, n int64) {
+	b.acked.add(max(off, b.start), off+n)
+	b.unsent.sub(off, off+n)
+	if len(b.acked) > 0 && b.acked[0].start == b.start {
+		done := b.acked[0].end - b.start
+		b.acked.sub(b.start, b.acked[0].end)
+		b.start += done
+		b.buf = b.buf[done:]
+	}
+}
+
+// allAcked reports whether all data written to the buffer has been
+// acknowledged.
+func (b *sendBuf) allAcked() bool {
+	return len(b.buf) == 0
+}
+
+// reset discards all unacknowledged data.
+func (b *sendBuf) reset() {
+	b.start = b.end()
+	b.buf = nil
+	b.unsent = nil
+	b.acked = nil
+}
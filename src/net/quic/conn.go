@@ -0,0 +1,752 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// connSide is the side of a connection: client or server.
+// Its value matches the least significant bit of the IDs
+// of streams initiated by that side.
+type connSide int8
+
+const (
+	clientSide connSide = 0
+	serverSide connSide = 1
+)
+
+func (s connSide) String() string {
+	if s == clientSide {
+		return "client"
+	}
+	return "server"
+}
+
+// connState is the state of a connection (RFC 9000, Section 10).
+type connState int8
+
+const (
+	connStateOpen     connState = iota
+	connStateClosing            // CONNECTION_CLOSE sent
+	connStateDraining           // CONNECTION_CLOSE received
+	connStateDone
+)
+
+// A peerConnID is a connection ID issued by the peer.
+type peerConnID struct {
+	seq        int64
+	cid        []byte
+	resetToken []byte
+}
+
+// A Conn is a QUIC connection.
+//
+// Multiple goroutines may invoke methods on a Conn simultaneously.
+type Conn struct {
+	side     connSide
+	endpoint *Endpoint
+	config   *Config
+	peerAddr net.Addr
+
+	msgc           chan []byte   // datagrams received from the endpoint
+	wakec          signal        // wakes the connection loop
+	closedc        chan struct{} // closed when the connection begins closing
+	drainedc       chan struct{} // closed when the peer has acknowledged the close, or the connection is done
+	donec          chan struct{} // closed when the connection loop exits
+	handshakeDonec chan struct{} // closed when the handshake completes
+
+	mu sync.Mutex // guards everything below
+
+	tls    *tls.QUICConn
+	spaces [numberSpaceCount]space
+
+	// 1-RTT key updates.
+	keyPhase        bool
+	rkeysPrev       *packetKeys
+	firstNumInPhase int64
+
+	// Connection IDs.
+	localConnID          []byte
+	origDstConnID        []byte // destination connection ID of the client's first Initial packet
+	initialDstConnID     []byte // destination connection ID Initial keys were derived from
+	peerConnIDs          []peerConnID
+	peerRetirePriorTo    int64
+	retireConnIDs        []int64 // RETIRE_CONNECTION_ID frames to send
+	peerInitialSrcConnID []byte
+	retrySrcConnID       []byte
+	retryToken           []byte
+	receivedPacket       bool // any packet has been successfully processed
+
+	// Handshake.
+	handshakeComplete  bool
+	handshakeConfirmed bool
+	handshakeDoneState sendState
+	haveHandshakeAck   bool // client has received an ACK of a Handshake packet
+	peerParams         transportParameters
+	addrValidated      bool
+	bytesReceived      int64 // before address validation
+	bytesSent          int64 // before address validation
+	handshakeDeadline  time.Time
+
+	// Idle timeout and keep-alive.
+	idleTimeout      time.Duration
+	keepAlive        time.Duration
+	lastActivity     time.Time
+	ackElicitingSent bool // an ack-eliciting packet was sent since the last packet was received
+	lastSend         time.Time
+	lastKeepAlive    time.Time
+	keepAlivePending bool
+	pathResponses    [][8]byte
+
+	// Loss recovery and congestion control.
+	rtt      rttState
+	cc       congestion
+	ptoCount int
+
+	// Flow control.
+	outMax     int64 // peer's MAX_DATA
+	outUsed    int64
+	inWin      int64
+	inMax      int64 // our MAX_DATA
+	inUsed     int64 // highest offsets received, summed over all streams
+	inConsumed int64 // data read or discarded
+	inMaxState sendState
+	streams    streamsState
+
+	// Closing.
+	state         connState
+	closeErr      error // returned by operations on the closed connection
+	waitErr       error // returned by Wait
+	closeApp      bool
+	closeCode     uint64
+	closeReason   string
+	closeSend     bool // CONNECTION_CLOSE should be sent
+	closeDeadline time.Time
+}
+
+func newConn(now time.Time, side connSide, e *Endpoint, peerAddr net.Addr, config *Config, initialDstConnID, peerSrcConnID []byte) (*Conn, error) {
+	if config == nil || config.TLSConfig == nil {
+		return nil, errors.New("quic: Config.TLSConfig must be set")
+	}
+	c := &Conn{
+		side:           side,
+		endpoint:       e,
+		config:         config,
+		peerAddr:       peerAddr,
+		msgc:           make(chan []byte, 128),
+		wakec:          newSignal(),
+		closedc:        make(chan struct{}),
+		drainedc:       make(chan struct{}),
+		donec:          make(chan struct{}),
+		handshakeDonec: make(chan struct{}),
+		localConnID:    newConnID(),
+		lastActivity:   now,
+		lastSend:       now,
+	}
+	c.rtt.init()
+	c.cc.init()
+	for i := range c.spaces {
+		c.spaces[i].largestAcked = -1
+	}
+	c.streams.m = make(map[int64]*Stream)
+	c.streams.acceptSig = newSignal()
+	c.streams.openSig = [2]signal{newSignal(), newSignal()}
+	c.streams.localMaxConfig = [2]int64{config.maxBidiRemoteStreams(), config.maxUniRemoteStreams()}
+	c.streams.localMax = c.streams.localMaxConfig
+	c.inWin = config.maxConnReadBufferSize()
+	c.inMax = c.inWin
+	c.idleTimeout = config.maxIdleTimeout()
+	c.keepAlive = config.keepAlivePeriod()
+	if d := config.handshakeTimeout(); d > 0 {
+		c.handshakeDeadline = now.Add(d)
+	}
+
+	tlsConfig := config.TLSConfig.Clone()
+	tlsConfig.MinVersion = tls.VersionTLS13
+	qconfig := &tls.QUICConfig{TLSConfig: tlsConfig}
+	if side == clientSide {
+		c.origDstConnID = initialDstConnID
+		c.peerConnIDs = []peerConnID{{cid: initialDstConnID}}
+		c.tls = tls.QUICClient(qconfig)
+	} else {
+		c.origDstConnID = initialDstConnID
+		c.peerConnIDs = []peerConnID{{cid: peerSrcConnID}}
+		c.peerInitialSrcConnID = peerSrcConnID
+		c.tls = tls.QUICServer(qconfig)
+	}
+	c.setInitialKeys(initialDstConnID)
+
+	params := transportParameters{
+		maxIdleTimeout:                 max(config.maxIdleTimeout(), 0),
+		maxUDPPayloadSize:              65527,
+		initialMaxData:                 c.inMax,
+		initialMaxStreamDataBidiLocal:  config.maxStreamReadBufferSize(),
+		initialMaxStreamDataBidiRemote: config.maxStreamReadBufferSize(),
+		initialMaxStreamDataUni:        config.maxStreamReadBufferSize(),
+		initialMaxStreamsBidi:          c.streams.localMax[bidiStream],
+		initialMaxStreamsUni:           c.streams.localMax[uniStream],
+		ackDelayExponent:               ackDelayExponent,
+		maxAckDelay:                    maxAckDelay,
+		disableActiveMigration:         true,
+		activeConnIDLimit:              2,
+		initialSrcConnID:               c.localConnID,
+	}
+	if side == serverSide {
+		params.originalDstConnID = initialDstConnID
+	}
+	c.tls.SetTransportParameters(params.marshal())
+	if err := c.tls.Start(context.Background()); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.handleTLSEvents(now); err != nil {
+		c.tls.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// newConnID returns a new random connection ID.
+func newConnID() []byte {
+	id := make([]byte, connIDLen)
+	rand.Read(id)
+	return id
+}
+
+// setInitialKeys sets the Initial packet protection keys.
+func (c *Conn) setInitialKeys(dstConnID []byte) {
+	c.initialDstConnID = dstConnID
+	client, server := initialKeys(dstConnID)
+	sp := &c.spaces[initialSpace]
+	if c.side == clientSide {
+		sp.rkeys, sp.wkeys = server, client
+	} else {
+		sp.rkeys, sp.wkeys = client, server
+	}
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.endpoint.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.peerAddr
+}
+
+// ConnectionState returns basic TLS details about the connection.
+func (c *Conn) ConnectionState() tls.ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tls.ConnectionState()
+}
+
+// Close closes the connection.
+//
+// Close is equivalent to:
+//
+//	conn.Abort(nil)
+//	err := conn.Wait(context.Background())
+func (c *Conn) Close() error {
+	c.Abort(nil)
+	return c.Wait(context.Background())
+}
+
+// Abort closes the connection and returns immediately.
+//
+// If err is nil, Abort sends an application error code of 0 (no error)
+// to the peer. If err is an [*ApplicationError], its code and reason
+// are sent to the peer. Any other error is reported to the peer as an
+// INTERNAL_ERROR.
+//
+// Pending data on streams is discarded.
+func (c *Conn) Abort(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state != connStateOpen {
+		return
+	}
+	var ae *ApplicationError
+	switch {
+	case err == nil:
+		c.startClosing(errConnClosed, nil, true, 0, "")
+	case errors.As(err, &ae):
+		c.startClosing(errConnClosed, nil, true, ae.Code, ae.Reason)
+	default:
+		c.startClosing(err, nil, false, uint64(errInternal), "")
+	}
+}
+
+// Wait waits for the peer to close the connection.
+//
+// If the connection is closed locally and the peer does not close its
+// end of the connection, Wait returns when the connection times out.
+//
+// If the peer closes the connection with an application error code of 0,
+// or the connection is closed locally, Wait returns nil.
+// If the peer closes the connection with any other error code, Wait
+// returns an error wrapping that code. If ctx expires first, Wait
+// returns the context's error.
+func (c *Conn) Wait(ctx context.Context) error {
+	select {
+	case <-c.drainedc:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.waitErr
+}
+
+// exit ends the connection immediately, without waiting for
+// the closing or draining period to end.
+func (c *Conn) exit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == connStateClosing && c.closeSend {
+		// Let the peer know the connection is closed.
+		c.send(time.Now())
+	}
+	c.terminate(errEndpointClosed)
+	c.wake()
+}
+
+// wake wakes the connection loop.
+func (c *Conn) wake() {
+	c.wakec.notify()
+}
+
+// wait waits for sig, the deadline channel, ctx, or the connection to close.
+// It must be called with c.mu held, and releases it while waiting.
+// It returns nil when the caller should check its condition again.
+func (c *Conn) wait(ctx context.Context, sig signal, deadline <-chan struct{}) error {
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	c.mu.Unlock()
+	defer c.mu.Lock()
+	select {
+	case <-sig:
+		return nil
+	case <-c.closedc:
+		return nil
+	case <-done:
+		return ctx.Err()
+	case <-deadline:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+// deliver passes a datagram received by the endpoint to the connection.
+func (c *Conn) deliver(b []byte) {
+	select {
+	case c.msgc <- b:
+	default:
+		// Drop the datagram, as the network would.
+	}
+}
+
+// loop is the connection's main goroutine.
+// It processes received datagrams and timer events, and sends packets.
+func (c *Conn) loop() {
+	defer close(c.donec)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		c.mu.Lock()
+		now := time.Now()
+		c.handleTimers(now)
+		if c.state == connStateDone {
+			c.mu.Unlock()
+			c.cleanup()
+			return
+		}
+		c.send(now)
+		next := c.nextDeadline()
+		c.mu.Unlock()
+
+		if next.IsZero() {
+			timer.Stop()
+		} else {
+			timer.Reset(time.Until(next))
+		}
+		select {
+		case b := <-c.msgc:
+			c.mu.Lock()
+			c.handleDatagram(time.Now(), b)
+			// Process any other datagrams already waiting before sending.
+			for more := true; more; {
+				select {
+				case b := <-c.msgc:
+					c.handleDatagram(time.Now(), b)
+				default:
+					more = false
+				}
+			}
+			c.mu.Unlock()
+		case <-c.wakec:
+		case <-timer.C:
+		}
+	}
+}
+
+// cleanup releases resources after the connection is done.
+func (c *Conn) cleanup() {
+	c.endpoint.removeConn(c)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tls.Close()
+	if !isClosedChan(c.closedc) {
+		close(c.closedc)
+	}
+	if !isClosedChan(c.drainedc) {
+		close(c.drainedc)
+	}
+}
+
+// handleTLSEvents processes events produced by the TLS connection.
+func (c *Conn) handleTLSEvents(now time.Time) error {
+	for {
+		e := c.tls.NextEvent()
+		switch e.Kind {
+		case tls.QUICNoEvent:
+			return nil
+		case tls.QUICSetReadSecret, tls.QUICSetWriteSecret:
+			var sp numberSpace
+			switch e.Level {
+			case tls.QUICEncryptionLevelHandshake:
+				sp = handshakeSpace
+			case tls.QUICEncryptionLevelApplication:
+				sp = appDataSpace
+			default:
+				continue // 0-RTT is not supported
+			}
+			k, err := newPacketKeys(e.Suite, bytes.Clone(e.Data))
+			if err != nil {
+				return localTransportError{code: errInternal, reason: err.Error()}
+			}
+			if e.Kind == tls.QUICSetReadSecret {
+				c.spaces[sp].rkeys = k
+			} else {
+				c.spaces[sp].wkeys = k
+			}
+		case tls.QUICWriteData:
+			sp := spaceForLevel(e.Level)
+			c.spaces[sp].cryptoOut.write(e.Data)
+		case tls.QUICTransportParameters:
+			p, err := unmarshalTransportParameters(e.Data, c.side == clientSide)
+			if err != nil {
+				return err
+			}
+			if err := c.receiveTransportParameters(p); err != nil {
+				return err
+			}
+		case tls.QUICHandshakeDone:
+			c.handshakeComplete = true
+			close(c.handshakeDonec)
+			if c.side == serverSide {
+				c.handshakeDoneState = sendUnsent
+				c.confirmHandshake()
+				if err := c.tls.SendSessionTicket(tls.QUICSessionTicketOptions{}); err != nil {
+					return tlsError(err)
+				}
+				c.endpoint.enqueueAccept(c)
+			}
+		}
+	}
+}
+
+// spaceForLevel returns the number space for a TLS encryption level.
+func spaceForLevel(l tls.QUICEncryptionLevel) numberSpace {
+	switch l {
+	case tls.QUICEncryptionLevelInitial:
+		return initialSpace
+	case tls.QUICEncryptionLevelHandshake:
+		return handshakeSpace
+	}
+	return appDataSpace
+}
+
+// levelForSpace returns the TLS encryption level for a number space.
+func levelForSpace(sp numberSpace) tls.QUICEncryptionLevel {
+	switch sp {
+	case initialSpace:
+		return tls.QUICEncryptionLevelInitial
+	case handshakeSpace:
+		return tls.QUICEncryptionLevelHandshake
+	}
+	return tls.QUICEncryptionLevelApplication
+}
+
+// tlsError converts an error returned by crypto/tls into a transport error.
+func tlsError(err error) error {
+	var ae tls.AlertError
+	if errors.As(err, &ae) {
+		return localTransportError{code: errTLSBase + transportError(ae), reason: err.Error(), err: err}
+	}
+	return localTransportError{code: errInternal, reason: err.Error(), err: err}
+}
+
+// receiveTransportParameters validates and applies the peer's
+// transport parameters.
+func (c *Conn) receiveTransportParameters(p transportParameters) error {
+	errParam := func(reason string) error {
+		return localTransportError{code: errTransportParameter, reason: reason}
+	}
+	if c.side == clientSide {
+		if !bytes.Equal(p.originalDstConnID, c.origDstConnID) {
+			return errParam("original_destination_connection_id mismatch")
+		}
+		if !bytes.Equal(p.retrySrcConnID, c.retrySrcConnID) || (p.retrySrcConnID == nil) != (c.retrySrcConnID == nil) {
+			return errParam("retry_source_connection_id mismatch")
+		}
+	}
+	if !bytes.Equal(p.initialSrcConnID, c.peerInitialSrcConnID) {
+		return errParam("initial_source_connection_id mismatch")
+	}
+	c.peerParams = p
+	c.outMax = p.initialMaxData
+	c.streams.peerMax = [2]int64{p.initialMaxStreamsBidi, p.initialMaxStreamsUni}
+	if p.statelessResetToken != nil {
+		c.peerConnIDs[0].resetToken = p.statelessResetToken
+	}
+	if p.maxIdleTimeout > 0 && (c.idleTimeout == 0 || p.maxIdleTimeout < c.idleTimeout) {
+		c.idleTimeout = p.maxIdleTimeout
+	}
+	if c.keepAlive > 0 && c.idleTimeout > 0 {
+		c.keepAlive = min(c.keepAlive, c.idleTimeout/2)
+	}
+	c.streams.openSig[bidiStream].notify()
+	c.streams.openSig[uniStream].notify()
+	return nil
+}
+
+// confirmHandshake records that the handshake is confirmed
+// (RFC 9001, Section 4.1.2).
+func (c *Conn) confirmHandshake() {
+	if c.handshakeConfirmed {
+		return
+	}
+	c.handshakeConfirmed = true
+	c.addrValidated = true
+	c.discardKeys(handshakeSpace)
+}
+
+// discardKeys discards the keys and state for a number space.
+func (c *Conn) discardKeys(sp numberSpace) {
+	s := &c.spaces[sp]
+	if s.discarded {
+		return
+	}
+	s.discarded = true
+	for _, p := range s.sent {
+		if p != nil {
+			c.cc.onDiscarded(p)
+		}
+	}
+	s.sent = nil
+	s.ackElicitingInFlight = 0
+	s.lossTime = time.Time{}
+	s.probes = 0
+	s.ackSent()
+	s.cryptoIn = recvBuf{}
+	s.cryptoOut = sendBuf{}
+	c.ptoCount = 0
+}
+
+// startClosing begins closing the connection after a local error or close.
+// closeErr is the error returned by operations on the connection,
+// and waitErr is returned by Wait.
+func (c *Conn) startClosing(closeErr, waitErr error, app bool, code uint64, reason string) {
+	if c.state != connStateOpen {
+		return
+	}
+	c.state = connStateClosing
+	c.closeErr = closeErr
+	c.waitErr = waitErr
+	c.closeApp = app
+	c.closeCode = code
+	c.closeReason = reason
+	c.closeSend = true
+	c.closeDeadline = time.Now().Add(3 * c.ptoDuration())
+	c.closeStreams()
+	c.wake()
+}
+
+// abortWithError closes the connection after an error detected locally.
+func (c *Conn) abortWithError(err error) {
+	var te localTransportError
+	if errors.As(err, &te) {
+		c.startClosing(te, te, false, uint64(te.code), te.reason)
+		return
+	}
+	c.startClosing(err, err, false, uint64(errInternal), "")
+}
+
+// enterDraining enters the draining state after the peer closes the connection.
+func (c *Conn) enterDraining(err, waitErr error) {
+	switch c.state {
+	case connStateOpen:
+		c.closeErr = err
+		c.waitErr = waitErr
+		c.closeDeadline = time.Now().Add(3 * c.ptoDuration())
+		c.closeStreams()
+	case connStateClosing:
+	default:
+		return
+	}
+	c.state = connStateDraining
+	close(c.drainedc)
+}
+
+// terminate immediately ends the connection without sending anything to the peer.
+func (c *Conn) terminate(err error) {
+	if c.state == connStateDone {
+		return
+	}
+	if c.state == connStateOpen {
+		c.closeErr = err
+		c.waitErr = err
+		c.closeStreams()
+	}
+	c.state = connStateDone
+}
+
+// closeStreams wakes any goroutines blocked on the connection
+// when it begins closing.
+func (c *Conn) closeStreams() {
+	close(c.closedc)
+	c.streams.queue = nil
+}
+
+// ptoDuration returns the current probe timeout, including max_ack_delay
+// once the handshake is confirmed.
+func (c *Conn) ptoDuration() time.Duration {
+	d := c.rtt.pto()
+	if c.handshakeConfirmed {
+		d += c.peerParams.maxAckDelay
+	}
+	return d
+}
+
+// idleDeadline returns the time at which the connection will be closed
+// if idle, or the zero time if there is no idle timeout.
+func (c *Conn) idleDeadline() time.Time {
+	if c.idleTimeout <= 0 {
+		return time.Time{}
+	}
+	return c.lastActivity.Add(max(c.idleTimeout, 3*c.ptoDuration()))
+}
+
+// keepAliveDeadline returns the time at which a keep-alive PING should
+// be sent, or the zero time if keep-alives are disabled.
+func (c *Conn) keepAliveDeadline() time.Time {
+	if c.keepAlive <= 0 || !c.handshakeComplete {
+		return time.Time{}
+	}
+	last := c.lastActivity
+	if c.lastKeepAlive.After(last) {
+		last = c.lastKeepAlive
+	}
+	return last.Add(c.keepAlive)
+}
+
+// nextDeadline returns the next time at which the connection
+// has timer-based work to do.
+func (c *Conn) nextDeadline() time.Time {
+	var next time.Time
+	update := func(t time.Time) {
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	if c.state != connStateOpen {
+		update(c.closeDeadline)
+		return next
+	}
+	update(c.idleDeadline())
+	update(c.keepAliveDeadline())
+	if !c.handshakeComplete {
+		update(c.handshakeDeadline)
+	}
+	update(c.lossDetectionDeadline())
+	if s := &c.spaces[appDataSpace]; s.ackElicitingUnacked > 0 {
+		update(s.ackDeadline)
+	}
+	return next
+}
+
+// handleTimers handles any expired timers.
+func (c *Conn) handleTimers(now time.Time) {
+	if c.state != connStateOpen {
+		if c.state != connStateDone && !now.Before(c.closeDeadline) {
+			if !isClosedChan(c.drainedc) {
+				close(c.drainedc)
+			}
+			c.state = connStateDone
+		}
+		return
+	}
+	if t := c.idleDeadline(); !t.IsZero() && !now.Before(t) {
+		c.terminate(errIdleTimeout)
+		return
+	}
+	if !c.handshakeComplete && !c.handshakeDeadline.IsZero() && !now.Before(c.handshakeDeadline) {
+		// As with the idle timeout, the connection is discarded
+		// silently: the peer is likely unreachable.
+		c.terminate(errHandshakeTimeout)
+		return
+	}
+	if t := c.keepAliveDeadline(); !t.IsZero() && !now.Before(t) {
+		c.keepAlivePending = true
+		c.lastKeepAlive = now
+	}
+	if t := c.lossDetectionDeadline(); !t.IsZero() && !now.Before(t) {
+		c.onLossDetectionTimeout(now)
+	}
+}
+
+// flowReceived records n bytes of new stream data received,
+// returning an error if connection flow control is exceeded.
+func (c *Conn) flowReceived(n int64) error {
+	c.inUsed += n
+	if c.inUsed > c.inMax {
+		return localTransportError{code: errFlowControl, reason: "connection flow control exceeded"}
+	}
+	return nil
+}
+
+// flowConsumed records n bytes of stream data read or discarded.
+func (c *Conn) flowConsumed(n int64) {
+	c.inConsumed += n
+	if c.inMax-c.inConsumed < c.inWin/2 {
+		c.inMax = c.inConsumed + c.inWin
+		c.inMaxState = sendUnsent
+		c.wake()
+	}
+}
+
+// flowAvail returns the amount of new stream data that may be sent
+// under connection flow control.
+func (c *Conn) flowAvail() int64 {
+	return max(c.outMax-c.outUsed, 0)
+}
+
+// flowSent records n bytes of new stream data sent.
+func (c *Conn) flowSent(n int64) {
+	c.outUsed += n
+}
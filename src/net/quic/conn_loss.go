@@ -0,0 +1,274 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import "time"
+
+// handleAck handles an ACK frame received in a number space
+// (RFC 9002, Section 6).
+func (c *Conn) handleAck(now time.Time, sp numberSpace, payload []byte) (n int, err error) {
+	s := &c.spaces[sp]
+	var (
+		acked      []*sentPacket
+		invalid    bool
+		largestNew *sentPacket
+	)
+	largest, delay, n := consumeAckFrame(payload, func(start, end int64) {
+		if end > s.nextNum {
+			invalid = true
+			return
+		}
+		i := s.sentIndex(start)
+		for ; i < len(s.sent); i++ {
+			p := s.sent[i]
+			if p == nil {
+				continue
+			}
+			if p.num >= end {
+				break
+			}
+			acked = append(acked, s.removeSent(i))
+			// removeSent may trim the start of s.sent.
+			i = s.sentIndex(p.num+1) - 1
+		}
+	})
+	if n < 0 {
+		return n, localTransportError{code: errFrameEncoding, reason: "malformed ACK frame"}
+	}
+	if invalid {
+		return n, localTransportError{code: errProtocolViolation, reason: "acknowledgement of unsent packet"}
+	}
+	if len(acked) == 0 {
+		return n, nil
+	}
+	if largest > s.largestAcked {
+		s.largestAcked = largest
+	}
+	ackEliciting := false
+	for _, p := range acked {
+		if p.num == largest {
+			largestNew = p
+		}
+		ackEliciting = ackEliciting || p.ackEliciting
+	}
+	if largestNew != nil && ackEliciting {
+		var ackDelay time.Duration
+		if sp == appDataSpace {
+			ackDelay = durationFromAckDelay(delay, c.peerParams.ackDelayExponent)
+		}
+		c.rtt.update(now.Sub(largestNew.time), ackDelay, c.peerParams.maxAckDelay, c.handshakeConfirmed)
+	}
+	if sp == handshakeSpace && c.side == clientSide {
+		c.haveHandshakeAck = true
+	}
+	for _, p := range acked {
+		c.cc.onAcked(p)
+		for _, f := range p.frames {
+			c.handleFrameAck(sp, f)
+		}
+	}
+	c.detectLostPackets(now, sp)
+	c.ptoCount = 0
+	return n, nil
+}
+
+// handleFrameAck handles the acknowledgement of a frame.
+func (c *Conn) handleFrameAck(sp numberSpace, f sentFrame) {
+	s := &c.spaces[sp]
+	switch f.ftype {
+	case frameTypeAck:
+		// The peer has seen our acknowledgement of packets up to f.off,
+		// so we need not acknowledge them again.
+		if f.off >= s.ackFloor {
+			s.seen.sub(s.ackFloor, f.off+1)
+			s.ackFloor = f.off + 1
+		}
+	case frameTypeCrypto:
+		s.cryptoOut.markAcked(f.off, f.n)
+	case frameTypeHandshakeDone:
+		c.handshakeDoneState = sendNone
+	case frameTypeMaxData:
+		if f.off == c.inMax {
+			c.inMaxState = sendNone
+		}
+	case frameTypeMaxStreamsBidi, frameTypeMaxStreamsUni:
+		dir := int(f.ftype - frameTypeMaxStreamsBidi)
+		if f.off == c.streams.localMax[dir] {
+			c.streams.localMaxState[dir] = sendNone
+		}
+	case frameTypeStreamBase, frameTypeResetStream, frameTypeStopSending, frameTypeMaxStreamData:
+		c.handleStreamAck(f)
+	}
+}
+
+// handleFrameLoss handles the loss of a frame.
+func (c *Conn) handleFrameLoss(sp numberSpace, f sentFrame) {
+	s := &c.spaces[sp]
+	switch f.ftype {
+	case frameTypeCrypto:
+		s.cryptoOut.markLost(f.off, f.n)
+	case frameTypeHandshakeDone:
+		c.handshakeDoneState.lost()
+	case frameTypeMaxData:
+		if f.off == c.inMax {
+			c.inMaxState.lost()
+		}
+	case frameTypeMaxStreamsBidi, frameTypeMaxStreamsUni:
+		dir := int(f.ftype - frameTypeMaxStreamsBidi)
+		if f.off == c.streams.localMax[dir] {
+			c.streams.localMaxState[dir].lost()
+		}
+	case frameTypeRetireConnectionID:
+		c.retireConnIDs = append(c.retireConnIDs, f.id)
+	case frameTypeStreamBase, frameTypeResetStream, frameTypeStopSending, frameTypeMaxStreamData:
+		c.handleStreamLoss(f)
+	}
+}
+
+// detectLostPackets declares packets lost based on the largest
+// acknowledged packet number (RFC 9002, Section 6.1).
+func (c *Conn) detectLostPackets(now time.Time, sp numberSpace) {
+	s := &c.spaces[sp]
+	s.lossTime = time.Time{}
+	lossDelay := c.rtt.lossDelay()
+	lostSendTime := now.Add(-lossDelay)
+	for i := 0; i < len(s.sent); i++ {
+		p := s.sent[i]
+		if p == nil {
+			continue
+		}
+		if p.num > s.largestAcked {
+			break
+		}
+		if !p.time.After(lostSendTime) || s.largestAcked >= p.num+3 {
+			s.removeSent(i)
+			i = s.sentIndex(p.num+1) - 1
+			c.cc.onLost(now, p)
+			for _, f := range p.frames {
+				c.handleFrameLoss(sp, f)
+			}
+			continue
+		}
+		if t := p.time.Add(lossDelay); s.lossTime.IsZero() || t.Before(s.lossTime) {
+			s.lossTime = t
+		}
+	}
+}
+
+// peerCompletedAddressValidation reports whether the peer has validated
+// our address (RFC 9002, Appendix A.6).
+func (c *Conn) peerCompletedAddressValidation() bool {
+	return c.side == serverSide || c.haveHandshakeAck || c.handshakeConfirmed
+}
+
+// amplificationLimited reports whether a server is blocked by the
+// anti-amplification limit (RFC 9000, Section 8.1).
+func (c *Conn) amplificationLimited() bool {
+	return c.side == serverSide && !c.addrValidated && 3*c.bytesReceived-c.bytesSent < maxDatagramSize
+}
+
+// lossDetectionDeadline returns the time of the loss detection timer,
+// or the zero time if it is not set (RFC 9002, Appendix A.8).
+func (c *Conn) lossDetectionDeadline() time.Time {
+	if t, _ := c.earliestLossTime(); !t.IsZero() {
+		return t
+	}
+	if c.amplificationLimited() {
+		return time.Time{}
+	}
+	t, _ := c.ptoTimeAndSpace()
+	return t
+}
+
+// earliestLossTime returns the earliest loss time across number spaces.
+func (c *Conn) earliestLossTime() (time.Time, numberSpace) {
+	var (
+		t  time.Time
+		sp numberSpace
+	)
+	for i := range c.spaces {
+		s := &c.spaces[i]
+		if !s.lossTime.IsZero() && (t.IsZero() || s.lossTime.Before(t)) {
+			t, sp = s.lossTime, numberSpace(i)
+		}
+	}
+	return t, sp
+}
+
+// ptoTimeAndSpace returns the time of the probe timeout and the number
+// space to probe, or the zero time if no timeout is set.
+func (c *Conn) ptoTimeAndSpace() (time.Time, numberSpace) {
+	d := c.rtt.pto() << min(c.ptoCount, 16)
+	inFlight := false
+	for i := range c.spaces {
+		if c.spaces[i].ackElicitingInFlight > 0 {
+			inFlight = true
+		}
+	}
+	if !inFlight {
+		if c.peerCompletedAddressValidation() {
+			return time.Time{}, 0
+		}
+		// Client anti-deadlock timer: send a probe so the server
+		// can continue the handshake.
+		sp := initialSpace
+		if c.spaces[handshakeSpace].canSend() {
+			sp = handshakeSpace
+		}
+		return c.lastSend.Add(d), sp
+	}
+	var (
+		t  time.Time
+		sp numberSpace
+	)
+	for i := range c.spaces {
+		s := &c.spaces[i]
+		if s.ackElicitingInFlight == 0 {
+			continue
+		}
+		pto := d
+		if numberSpace(i) == appDataSpace {
+			if !c.handshakeConfirmed {
+				break
+			}
+			pto += c.peerParams.maxAckDelay << min(c.ptoCount, 16)
+		}
+		if pt := s.lastAckElicitingSent.Add(pto); t.IsZero() || pt.Before(t) {
+			t, sp = pt, numberSpace(i)
+		}
+	}
+	return t, sp
+}
+
+// onLossDetectionTimeout handles the expiration of the loss
+// detection timer (RFC 9002, Appendix A.9).
+func (c *Conn) onLossDetectionTimeout(now time.Time) {
+	if t, sp := c.earliestLossTime(); !t.IsZero() {
+		c.detectLostPackets(now, sp)
+		return
+	}
+	t, sp := c.ptoTimeAndSpace()
+	if t.IsZero() {
+		return
+	}
+	c.ptoCount++
+	s := &c.spaces[sp]
+	if !s.canSend() {
+		return
+	}
+	s.probes = 2
+	// Retransmit unacknowledged data in probe packets.
+	for _, p := range s.sent {
+		if p == nil {
+			continue
+		}
+		for _, f := range p.frames {
+			switch f.ftype {
+			case frameTypeCrypto, frameTypeStreamBase:
+				c.handleFrameLoss(sp, f)
+			}
+		}
+	}
+}
@@ -0,0 +1,539 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// handleDatagram processes a datagram received from the peer.
+func (c *Conn) handleDatagram(now time.Time, b []byte) {
+	if c.state == connStateDone {
+		return
+	}
+	if !c.addrValidated {
+		c.bytesReceived += int64(len(b))
+	}
+	dgram := b
+	processed := false
+	for len(b) > 0 {
+		var n int
+		var ok bool
+		if isLongHeader(b[0]) {
+			n, ok = c.handleLongPacket(now, b)
+		} else {
+			n, ok = c.handleShortPacket(now, b)
+		}
+		processed = processed || ok
+		if n <= 0 {
+			break
+		}
+		b = b[n:]
+	}
+	if !processed {
+		c.checkStatelessReset(dgram)
+		return
+	}
+	if c.state == connStateClosing {
+		// Respond to packets received while closing with another
+		// CONNECTION_CLOSE, after a delay to limit the response rate.
+		c.closeSend = true
+	}
+	c.wake()
+}
+
+// checkStatelessReset checks whether a datagram that could not be
+// processed is a stateless reset (RFC 9000, Section 10.3).
+func (c *Conn) checkStatelessReset(b []byte) {
+	if len(b) < 21 || isLongHeader(b[0]) {
+		return
+	}
+	tail := b[len(b)-16:]
+	for _, id := range c.peerConnIDs {
+		if id.resetToken != nil && subtle.ConstantTimeCompare(tail, id.resetToken) == 1 {
+			c.terminate(errStatelessReset)
+			if !isClosedChan(c.drainedc) {
+				close(c.drainedc)
+			}
+			return
+		}
+	}
+}
+
+// handleLongPacket processes a long header packet at the start of b.
+// It returns the length of the packet, and whether it was successfully
+// processed.
+func (c *Conn) handleLongPacket(now time.Time, b []byte) (n int, ok bool) {
+	p, ok := parseLongHeader(b)
+	if !ok {
+		return -1, false
+	}
+	if p.version != quicVersion1 {
+		if p.ptype == packetTypeVersionNegotiation {
+			c.handleVersionNegotiation(p)
+		}
+		return -1, false
+	}
+	if !bytes.Equal(p.dstConnID, c.localConnID) && !(c.side == serverSide && bytes.Equal(p.dstConnID, c.initialDstConnID)) {
+		return p.length, false
+	}
+	var sp numberSpace
+	switch p.ptype {
+	case packetTypeInitial:
+		sp = initialSpace
+	case packetTypeHandshake:
+		sp = handshakeSpace
+	case packetTypeRetry:
+		c.handleRetry(b, p)
+		return -1, false
+	default:
+		return p.length, false // 0-RTT is not supported
+	}
+	s := &c.spaces[sp]
+	if s.rkeys == nil || s.discarded {
+		return p.length, false
+	}
+	pkt := b[:p.length]
+	pnumLen, truncated, ok := unprotectHeader(s.rkeys.hp, pkt, p.pnumOff)
+	if !ok {
+		return p.length, false
+	}
+	pnum := decodePacketNumber(s.largestSeen(), truncated, pnumLen)
+	payload, err := s.rkeys.open(pkt, p.pnumOff+pnumLen, pnum)
+	if err != nil {
+		return p.length, false
+	}
+	if pkt[0]&0x0c != 0 {
+		c.abortWithError(localTransportError{code: errProtocolViolation, reason: "reserved header bits set"})
+		return -1, false
+	}
+	if s.isDuplicate(pnum) {
+		return p.length, true
+	}
+	if c.side == clientSide && !c.receivedPacket {
+		if sp != initialSpace {
+			return p.length, false
+		}
+		// The server's first packet sets our destination connection ID.
+		c.peerConnIDs[0].cid = bytes.Clone(p.srcConnID)
+		c.peerInitialSrcConnID = c.peerConnIDs[0].cid
+	}
+	if c.side == clientSide && sp == initialSpace && !bytes.Equal(p.srcConnID, c.peerInitialSrcConnID) {
+		return p.length, false
+	}
+	c.receivedPacket = true
+	if sp == handshakeSpace && c.side == serverSide {
+		// Receipt of a Handshake packet validates the client's address
+		// and means the client no longer needs Initial packets.
+		c.addrValidated = true
+		c.discardKeys(initialSpace)
+	}
+	c.handlePayload(now, sp, pnum, payload)
+	return p.length, true
+}
+
+// handleShortPacket processes a 1-RTT packet, which occupies all of b.
+func (c *Conn) handleShortPacket(now time.Time, b []byte) (n int, ok bool) {
+	s := &c.spaces[appDataSpace]
+	if s.rkeys == nil || len(b) < 1+connIDLen || !bytes.Equal(b[1:1+connIDLen], c.localConnID) {
+		return -1, false
+	}
+	pnumOff := 1 + connIDLen
+	// Header protection keys do not change on key update.
+	pnumLen, truncated, ok := unprotectHeader(s.rkeys.hp, b, pnumOff)
+	if !ok {
+		return -1, false
+	}
+	pnum := decodePacketNumber(s.largestSeen(), truncated, pnumLen)
+	hdrLen := pnumOff + pnumLen
+	keyPhase := b[0]&keyPhaseBit != 0
+	var (
+		payload []byte
+		err     error
+	)
+	switch {
+	case keyPhase == c.keyPhase:
+		payload, err = s.rkeys.open(b, hdrLen, pnum)
+	case c.rkeysPrev != nil && pnum < c.firstNumInPhase:
+		payload, err = c.rkeysPrev.open(b, hdrLen, pnum)
+	default:
+		// The peer has initiated a key update (RFC 9001, Section 6.2).
+		next := s.rkeys.next()
+		if payload, err = next.open(b, hdrLen, pnum); err == nil {
+			c.rkeysPrev = s.rkeys
+			s.rkeys = next
+			s.wkeys = s.wkeys.next()
+			c.keyPhase = keyPhase
+			c.firstNumInPhase = pnum
+		}
+	}
+	if err != nil {
+		return -1, false
+	}
+	if b[0]&0x18 != 0 {
+		c.abortWithError(localTransportError{code: errProtocolViolation, reason: "reserved header bits set"})
+		return -1, false
+	}
+	if s.isDuplicate(pnum) {
+		return -1, true
+	}
+	c.receivedPacket = true
+	if c.side == clientSide && !c.handshakeComplete {
+		// The client cannot process 1-RTT packets before the handshake
+		// completes. The server will retransmit any lost data.
+		return -1, false
+	}
+	c.handlePayload(now, appDataSpace, pnum, payload)
+	return -1, true
+}
+
+// handleVersionNegotiation handles a Version Negotiation packet
+// (RFC 9000, Section 6.2).
+func (c *Conn) handleVersionNegotiation(p longPacket) {
+	if c.side != clientSide || c.receivedPacket || !bytes.Equal(p.dstConnID, c.localConnID) {
+		return
+	}
+	for v := p.versions; len(v) >= 4; v = v[4:] {
+		if binary.BigEndian.Uint32(v) == quicVersion1 {
+			return
+		}
+	}
+	c.terminate(errors.New("quic: server does not support QUIC version 1"))
+}
+
+// handleRetry handles a Retry packet (RFC 9000, Section 17.2.5).
+func (c *Conn) handleRetry(b []byte, p longPacket) {
+	if c.side != clientSide || c.receivedPacket || c.retrySrcConnID != nil || len(p.token) == 0 {
+		return
+	}
+	tag := retryIntegrityTag(c.origDstConnID, b[:len(b)-aeadOverhead])
+	if subtle.ConstantTimeCompare(tag, b[len(b)-aeadOverhead:]) != 1 {
+		return
+	}
+	c.retrySrcConnID = bytes.Clone(p.srcConnID)
+	c.retryToken = bytes.Clone(p.token)
+	c.peerConnIDs[0].cid = c.retrySrcConnID
+	c.setInitialKeys(c.retrySrcConnID)
+	// Resend everything sent in Initial packets with the new keys.
+	s := &c.spaces[initialSpace]
+	for _, sp := range s.sent {
+		if sp != nil {
+			c.cc.onDiscarded(sp)
+		}
+	}
+	s.sent = nil
+	s.ackElicitingInFlight = 0
+	s.cryptoOut.markLost(s.cryptoOut.start, int64(len(s.cryptoOut.buf)))
+	c.wake()
+}
+
+// handlePayload processes the decrypted payload of a packet.
+func (c *Conn) handlePayload(now time.Time, sp numberSpace, pnum int64, payload []byte) {
+	if len(payload) == 0 {
+		c.abortWithError(localTransportError{code: errProtocolViolation, reason: "packet with no frames"})
+		return
+	}
+	wasOpen := c.state == connStateOpen
+	ackEliciting, err := c.handleFrames(now, sp, payload)
+	if err != nil {
+		var pe peerTransportError
+		var ae *ApplicationError
+		switch {
+		case errors.As(err, &pe):
+			var waitErr error
+			if pe.code != errNo {
+				waitErr = pe
+			}
+			c.enterDraining(pe, waitErr)
+		case errors.As(err, &ae):
+			var waitErr error
+			if ae.Code != 0 {
+				waitErr = ae
+			}
+			c.enterDraining(ae, waitErr)
+		default:
+			c.abortWithError(err)
+		}
+		if wasOpen && c.state == connStateDraining {
+			c.sendCloseResponse()
+		}
+	}
+	if c.state == connStateOpen || c.state == connStateClosing {
+		c.spaces[sp].receivedPacket(now, pnum, ackEliciting, sp)
+	}
+	c.lastActivity = now
+	c.ackElicitingSent = false
+}
+
+// sendCloseResponse sends a single CONNECTION_CLOSE in response to the
+// peer's close, before draining (RFC 9000, Section 10.2.2).
+func (c *Conn) sendCloseResponse() {
+	c.closeApp = false
+	c.closeCode = uint64(errNo)
+	c.closeReason = ""
+	c.closeSend = true
+}
+
+// handleFrames processes the frames in a packet payload.
+func (c *Conn) handleFrames(now time.Time, sp numberSpace, payload []byte) (ackEliciting bool, err error) {
+	errEncoding := func(reason string) error {
+		return localTransportError{code: errFrameEncoding, reason: reason}
+	}
+	for len(payload) > 0 {
+		ftype, n := consumeVarint(payload)
+		if n < 0 {
+			return ackEliciting, errEncoding("malformed frame type")
+		}
+		if !frameAllowedInSpace(ftype, sp) {
+			return ackEliciting, localTransportError{code: errProtocolViolation, reason: "frame not allowed in packet type"}
+		}
+		switch ftype {
+		case frameTypePadding, frameTypeAck, frameTypeAckECN,
+			frameTypeConnectionCloseTransport, frameTypeConnectionCloseApplication:
+		default:
+			if c.state != connStateOpen {
+				// A closing connection only looks for the peer's
+				// CONNECTION_CLOSE (RFC 9000, Section 10.2.1).
+				return false, nil
+			}
+			ackEliciting = true
+		}
+		switch {
+		case ftype == frameTypePadding:
+			n = 1
+			for n < len(payload) && payload[n] == 0 {
+				n++
+			}
+		case ftype == frameTypePing:
+			n = 1
+		case ftype == frameTypeAck || ftype == frameTypeAckECN:
+			n, err = c.handleAck(now, sp, payload)
+		case ftype == frameTypeCrypto:
+			off, data, m := consumeCryptoFrame(payload)
+			if n = m; n < 0 {
+				return ackEliciting, errEncoding("malformed CRYPTO frame")
+			}
+			err = c.handleCrypto(now, sp, off, data)
+		case ftype >= frameTypeStreamBase && ftype <= frameTypeStreamBase|0x07:
+			id, off, fin, data, m := consumeStreamFrame(payload)
+			if n = m; n < 0 {
+				return ackEliciting, errEncoding("malformed STREAM frame")
+			}
+			var s *Stream
+			if s, err = c.streamForFrame(id, true); s != nil {
+				err = s.handleData(off, data, fin)
+			}
+		case ftype == frameTypeResetStream:
+			var id, code, size uint64
+			if n = consumeVarintFields(payload, &id, &code, &size); n < 0 {
+				return ackEliciting, errEncoding("malformed RESET_STREAM frame")
+			}
+			var s *Stream
+			if s, err = c.streamForFrame(int64(id), true); s != nil {
+				err = s.handleReset(code, int64(size))
+			}
+		case ftype == frameTypeStopSending:
+			var id, code uint64
+			if n = consumeVarintFields(payload, &id, &code); n < 0 {
+				return ackEliciting, errEncoding("malformed STOP_SENDING frame")
+			}
+			var s *Stream
+			if s, err = c.streamForFrame(int64(id), false); s != nil {
+				s.handleStopSending(code)
+			}
+		case ftype == frameTypeMaxStreamData:
+			var id, v uint64
+			if n = consumeVarintFields(payload, &id, &v); n < 0 {
+				return ackEliciting, errEncoding("malformed MAX_STREAM_DATA frame")
+			}
+			var s *Stream
+			if s, err = c.streamForFrame(int64(id), false); s != nil {
+				s.handleMaxStreamData(int64(v))
+			}
+		case ftype == frameTypeStreamDataBlocked:
+			var id, v uint64
+			if n = consumeVarintFields(payload, &id, &v); n < 0 {
+				return ackEliciting, errEncoding("malformed STREAM_DATA_BLOCKED frame")
+			}
+			_, err = c.streamForFrame(int64(id), true)
+		case ftype == frameTypeMaxData:
+			var v uint64
+			if n = consumeVarintFields(payload, &v); n < 0 {
+				return ackEliciting, errEncoding("malformed MAX_DATA frame")
+			}
+			if int64(v) > c.outMax {
+				c.outMax = int64(v)
+				for _, s := range c.streams.m {
+					if s.out.hasUnsent() {
+						c.queueStream(s)
+					}
+				}
+			}
+		case ftype == frameTypeMaxStreamsBidi || ftype == frameTypeMaxStreamsUni:
+			var v uint64
+			if n = consumeVarintFields(payload, &v); n < 0 {
+				return ackEliciting, errEncoding("malformed MAX_STREAMS frame")
+			}
+			if v > maxStreamsLimit {
+				return ackEliciting, errEncoding("MAX_STREAMS too large")
+			}
+			dir := int(ftype - frameTypeMaxStreamsBidi)
+			if int64(v) > c.streams.peerMax[dir] {
+				c.streams.peerMax[dir] = int64(v)
+				c.streams.openSig[dir].notify()
+			}
+		case ftype == frameTypeDataBlocked || ftype == frameTypeStreamsBlockedBidi || ftype == frameTypeStreamsBlockedUni:
+			var v uint64
+			if n = consumeVarintFields(payload, &v); n < 0 {
+				return ackEliciting, errEncoding("malformed blocked frame")
+			}
+		case ftype == frameTypeNewToken:
+			if c.side == serverSide {
+				return ackEliciting, localTransportError{code: errProtocolViolation, reason: "NEW_TOKEN from client"}
+			}
+			_, n = consumeVarint(payload)
+			token, m := consumeVarintBytes(payload[n:])
+			if m < 0 || len(token) == 0 {
+				return ackEliciting, errEncoding("malformed NEW_TOKEN frame")
+			}
+			n += m
+		case ftype == frameTypeNewConnectionID:
+			n, err = c.handleNewConnectionID(payload)
+		case ftype == frameTypeRetireConnectionID:
+			var seq uint64
+			if n = consumeVarintFields(payload, &seq); n < 0 {
+				return ackEliciting, errEncoding("malformed RETIRE_CONNECTION_ID frame")
+			}
+			// We only issue the connection ID with sequence number 0,
+			// which the peer may not retire while it is in use.
+			if seq > 0 {
+				return ackEliciting, localTransportError{code: errProtocolViolation, reason: "retirement of unissued connection ID"}
+			}
+		case ftype == frameTypePathChallenge || ftype == frameTypePathResponse:
+			if len(payload) < 9 {
+				return ackEliciting, errEncoding("malformed path frame")
+			}
+			n = 9
+			if ftype == frameTypePathChallenge && len(c.pathResponses) < 4 {
+				c.pathResponses = append(c.pathResponses, [8]byte(payload[1:9]))
+			}
+		case ftype == frameTypeConnectionCloseTransport:
+			var code, frameType uint64
+			if n = consumeVarintFields(payload, &code, &frameType); n < 0 {
+				return ackEliciting, errEncoding("malformed CONNECTION_CLOSE frame")
+			}
+			reason, m := consumeVarintBytes(payload[n:])
+			if m < 0 {
+				return ackEliciting, errEncoding("malformed CONNECTION_CLOSE frame")
+			}
+			return ackEliciting, peerTransportError{code: transportError(code), reason: string(reason)}
+		case ftype == frameTypeConnectionCloseApplication:
+			var code uint64
+			if n = consumeVarintFields(payload, &code); n < 0 {
+				return ackEliciting, errEncoding("malformed CONNECTION_CLOSE frame")
+			}
+			reason, m := consumeVarintBytes(payload[n:])
+			if m < 0 {
+				return ackEliciting, errEncoding("malformed CONNECTION_CLOSE frame")
+			}
+			return ackEliciting, &ApplicationError{Code: code, Reason: string(reason)}
+		case ftype == frameTypeHandshakeDone:
+			if c.side == serverSide {
+				return ackEliciting, localTransportError{code: errProtocolViolation, reason: "HANDSHAKE_DONE from client"}
+			}
+			n = 1
+			c.confirmHandshake()
+		default:
+			return ackEliciting, errEncoding("unknown frame type")
+		}
+		if err != nil {
+			return ackEliciting, err
+		}
+		if n <= 0 {
+			return ackEliciting, errEncoding("malformed frame")
+		}
+		payload = payload[n:]
+	}
+	return ackEliciting, nil
+}
+
+// maxCryptoBuffer is the maximum amount of out-of-order CRYPTO data
+// buffered in each number space.
+const maxCryptoBuffer = 64 << 10
+
+// handleCrypto handles data received in a CRYPTO frame.
+func (c *Conn) handleCrypto(now time.Time, sp numberSpace, off int64, data []byte) error {
+	s := &c.spaces[sp]
+	if off+int64(len(data)) > s.cryptoIn.start+maxCryptoBuffer {
+		return localTransportError{code: errCryptoBufferExceeded, reason: "too much out-of-order CRYPTO data"}
+	}
+	s.cryptoIn.write(off, data)
+	b := s.cryptoIn.peek()
+	if len(b) == 0 {
+		return nil
+	}
+	if err := c.tls.HandleData(levelForSpace(sp), b); err != nil {
+		return tlsError(err)
+	}
+	s.cryptoIn.discard(int64(len(b)))
+	return c.handleTLSEvents(now)
+}
+
+// handleNewConnectionID handles a NEW_CONNECTION_ID frame
+// (RFC 9000, Section 19.15).
+func (c *Conn) handleNewConnectionID(b []byte) (n int, err error) {
+	errEncoding := localTransportError{code: errFrameEncoding, reason: "malformed NEW_CONNECTION_ID frame"}
+	var seq, retirePriorTo uint64
+	if n = consumeVarintFields(b, &seq, &retirePriorTo); n < 0 {
+		return -1, errEncoding
+	}
+	cid, m := consumeUint8Bytes(b[n:])
+	if m < 0 || len(cid) < 1 || len(cid) > maxConnIDLen || len(b) < n+m+16 {
+		return -1, errEncoding
+	}
+	n += m
+	token := b[n : n+16]
+	n += 16
+	if retirePriorTo > seq {
+		return -1, errEncoding
+	}
+	if len(c.peerConnIDs[0].cid) == 0 {
+		return -1, localTransportError{code: errProtocolViolation, reason: "NEW_CONNECTION_ID with zero-length connection IDs"}
+	}
+	if int64(seq) < c.peerRetirePriorTo {
+		c.retireConnIDs = append(c.retireConnIDs, int64(seq))
+		return n, nil
+	}
+	for _, id := range c.peerConnIDs {
+		if id.seq == int64(seq) {
+			return n, nil // duplicate
+		}
+	}
+	c.peerConnIDs = append(c.peerConnIDs, peerConnID{
+		seq:        int64(seq),
+		cid:        bytes.Clone(cid),
+		resetToken: bytes.Clone(token),
+	})
+	if int64(retirePriorTo) > c.peerRetirePriorTo {
+		c.peerRetirePriorTo = int64(retirePriorTo)
+		ids := c.peerConnIDs[:0]
+		for _, id := range c.peerConnIDs {
+			if id.seq < c.peerRetirePriorTo {
+				c.retireConnIDs = append(c.retireConnIDs, id.seq)
+			} else {
+				ids = append(ids, id)
+			}
+		}
+		c.peerConnIDs = ids
+	}
+	if int64(len(c.peerConnIDs)) > 2 {
+		return -1, localTransportError{code: errConnectionIDLimit, reason: "too many connection IDs"}
+	}
+	return n, nil
+}
@@ -0,0 +1,416 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import "time"
+
+// A packetWriter assembles packets into a datagram.
+//
+// Frames for each packet are first collected in a payload buffer.
+// A packet is added to the datagram only if it contains frames,
+// and the last packet in the datagram remains unprotected until the
+// datagram is finished so that it may be padded.
+type packetWriter struct {
+	dgram []byte // datagram being built
+	limit int    // maximum datagram size
+
+	// The packet being built.
+	payload  []byte
+	frameMax int // maximum payload size
+	sent     *sentPacket
+	space    numberSpace
+
+	// The last packet added to the datagram, not yet protected.
+	last struct {
+		valid     bool
+		keys      *packetKeys
+		start     int
+		lengthOff int // long header only
+		pnumOff   int
+		pnumLen   int
+		pnum      int64
+		long      bool
+		sent      *sentPacket
+		space     numberSpace
+	}
+
+	// Packets in the datagram.
+	packets    []*sentPacket
+	spaces     []numberSpace
+	hasInitial bool // the datagram contains an Initial packet
+	padInitial bool // the datagram must be padded to maxDatagramSize
+}
+
+func (w *packetWriter) reset(limit int) {
+	if w.dgram == nil {
+		// Leave room for the padding added by sealLast.
+		w.dgram = make([]byte, 0, maxDatagramSize+aeadOverhead)
+	}
+	w.dgram = w.dgram[:0]
+	w.limit = limit
+	w.last.valid = false
+	w.packets = w.packets[:0]
+	w.spaces = w.spaces[:0]
+	w.hasInitial = false
+	w.padInitial = false
+}
+
+// beginPacket starts a new packet with a header of hdrLen bytes.
+// It reports false if there is no room for the packet.
+func (w *packetWriter) beginPacket(now time.Time, sp numberSpace, pnum int64, hdrLen int) bool {
+	used := len(w.dgram)
+	if w.last.valid {
+		used += aeadOverhead
+	}
+	w.frameMax = w.limit - used - hdrLen - aeadOverhead
+	if w.frameMax < 32 {
+		return false
+	}
+	w.payload = w.payload[:0]
+	w.space = sp
+	w.sent = &sentPacket{num: pnum, time: now}
+	return true
+}
+
+// avail returns the remaining room for frames in the current packet.
+func (w *packetWriter) avail() int {
+	return w.frameMax - len(w.payload)
+}
+
+// appendFrame appends a frame to the current packet and records it.
+// It reports false if there is not enough room.
+func (w *packetWriter) appendFrame(b []byte, f sentFrame) bool {
+	if len(b) > w.avail() {
+		return false
+	}
+	w.payload = append(w.payload, b...)
+	switch f.ftype {
+	case frameTypePadding, frameTypeAck,
+		frameTypeConnectionCloseTransport, frameTypeConnectionCloseApplication:
+	default:
+		w.sent.ackEliciting = true
+	}
+	switch f.ftype {
+	case frameTypePadding, frameTypePing, frameTypePathResponse,
+		frameTypeConnectionCloseTransport, frameTypeConnectionCloseApplication:
+	default:
+		w.sent.frames = append(w.sent.frames, f)
+	}
+	return true
+}
+
+// sealLast applies packet protection to the last packet in the datagram.
+func (w *packetWriter) sealLast() {
+	l := &w.last
+	if !l.valid {
+		return
+	}
+	l.valid = false
+	pkt := w.dgram[l.start:]
+	if minPayload := 4 - l.pnumLen; len(pkt)-l.pnumOff-l.pnumLen < minPayload {
+		// Ensure there is enough ciphertext to sample for header protection.
+		pkt = append(pkt, make([]byte, minPayload-(len(pkt)-l.pnumOff-l.pnumLen))...)
+	}
+	if l.long {
+		setLongHeaderLength(pkt, l.lengthOff, l.pnumOff, len(pkt)+aeadOverhead)
+	}
+	pkt = l.keys.protect(pkt, l.pnumOff, l.pnumLen, l.pnum)
+	w.dgram = w.dgram[:l.start+len(pkt)]
+	l.sent.size = len(pkt)
+}
+
+// padLast pads the last packet so that the datagram is n bytes long.
+func (w *packetWriter) padLast(n int) {
+	if pad := n - len(w.dgram) - aeadOverhead; w.last.valid && pad > 0 {
+		w.dgram = append(w.dgram, make([]byte, pad)...)
+		w.last.sent.inFlight = true
+	}
+}
+
+// finish finishes the datagram, returning it.
+func (w *packetWriter) finish() []byte {
+	if w.padInitial {
+		w.padLast(maxDatagramSize)
+	}
+	w.sealLast()
+	return w.dgram
+}
+
+// send sends as many datagrams as flow control, congestion control,
+// and the anti-amplification limit allow.
+func (c *Conn) send(now time.Time) {
+	if c.state == connStateDone || (c.state == connStateDraining && !c.closeSend) {
+		return
+	}
+	var w packetWriter
+	for range 64 {
+		if c.amplificationLimited() {
+			return
+		}
+		w.reset(maxDatagramSize)
+		if c.state != connStateOpen {
+			if c.closeSend {
+				c.closeSend = false
+				c.buildClose(now, &w)
+				c.writeDatagram(now, &w)
+			}
+			return
+		}
+		ccLimited := !c.cc.canSend()
+		for sp := initialSpace; sp < numberSpaceCount; sp++ {
+			c.buildPacket(now, &w, sp, ccLimited)
+		}
+		if len(w.dgram) == 0 {
+			return
+		}
+		c.writeDatagram(now, &w)
+	}
+	// There may be more to send.
+	c.wake()
+}
+
+// writeDatagram finishes and sends a datagram, and records the packets in it.
+func (c *Conn) writeDatagram(now time.Time, w *packetWriter) {
+	b := w.finish()
+	if len(b) == 0 {
+		return
+	}
+	c.endpoint.writeTo(b, c.peerAddr)
+	c.lastSend = now
+	if !c.addrValidated {
+		c.bytesSent += int64(len(b))
+	}
+	for i, p := range w.packets {
+		sp := w.spaces[i]
+		p.inFlight = p.inFlight || p.ackEliciting
+		if p.ackEliciting || p.inFlight {
+			c.spaces[sp].addSent(p)
+			c.cc.onSent(p)
+		}
+		if p.ackEliciting && !c.ackElicitingSent {
+			c.ackElicitingSent = true
+			c.lastActivity = now
+		}
+		if sp == handshakeSpace && c.side == clientSide {
+			// A client discards Initial keys when it first sends
+			// a Handshake packet (RFC 9001, Section 4.9.1).
+			c.discardKeys(initialSpace)
+		}
+	}
+}
+
+// headerLen returns the length of a packet header in a number space.
+func (c *Conn) headerLen(sp numberSpace, pnumLen int) int {
+	dcid := c.peerConnIDs[0].cid
+	if sp == appDataSpace {
+		return 1 + len(dcid) + pnumLen
+	}
+	n := 1 + 4 + 1 + len(dcid) + 1 + len(c.localConnID) + 2 + pnumLen
+	if sp == initialSpace {
+		n += sizeVarint(uint64(len(c.retryToken))) + len(c.retryToken)
+	}
+	return n
+}
+
+// beginPacket starts a packet in a number space.
+func (c *Conn) beginPacket(now time.Time, w *packetWriter, sp numberSpace) (pnumLen int, ok bool) {
+	s := &c.spaces[sp]
+	pnumLen = packetNumberLength(s.nextNum, s.largestAcked)
+	return pnumLen, w.beginPacket(now, sp, s.nextNum, c.headerLen(sp, pnumLen))
+}
+
+// commitPacket adds the current packet to the datagram, if it has any frames.
+func (c *Conn) commitPacket(w *packetWriter, sp numberSpace, pnumLen int) {
+	if len(w.payload) == 0 {
+		return
+	}
+	w.sealLast()
+	s := &c.spaces[sp]
+	l := &w.last
+	l.valid = true
+	l.keys = s.wkeys
+	l.start = len(w.dgram)
+	l.pnumLen = pnumLen
+	l.pnum = s.nextNum
+	l.sent = w.sent
+	l.space = sp
+	dcid := c.peerConnIDs[0].cid
+	if sp == appDataSpace {
+		l.long = false
+		var pnumOff int
+		w.dgram, pnumOff = appendShortHeader(w.dgram, dcid, c.keyPhase, s.nextNum, pnumLen)
+		l.pnumOff = pnumOff - l.start
+	} else {
+		l.long = true
+		var lengthOff, pnumOff int
+		w.dgram, lengthOff, pnumOff = appendLongHeader(w.dgram, packetTypeForSpace(sp), dcid, c.localConnID, c.retryToken, s.nextNum, pnumLen)
+		l.lengthOff = lengthOff - l.start
+		l.pnumOff = pnumOff - l.start
+	}
+	w.dgram = append(w.dgram, w.payload...)
+	s.nextNum++
+	w.packets = append(w.packets, w.sent)
+	w.spaces = append(w.spaces, sp)
+	if sp == initialSpace {
+		w.hasInitial = true
+		if c.side == clientSide || w.sent.ackEliciting {
+			w.padInitial = true
+		}
+	}
+	if w.sent.ackEliciting && s.probes > 0 {
+		s.probes--
+	}
+}
+
+// buildClose builds a datagram containing CONNECTION_CLOSE frames.
+func (c *Conn) buildClose(now time.Time, w *packetWriter) {
+	for sp := initialSpace; sp < numberSpaceCount; sp++ {
+		s := &c.spaces[sp]
+		if !s.canSend() {
+			continue
+		}
+		if sp == appDataSpace && !c.handshakeComplete && c.side == clientSide {
+			continue
+		}
+		pnumLen, ok := c.beginPacket(now, w, sp)
+		if !ok {
+			continue
+		}
+		b := appendConnectionCloseFrame(nil, sp, c.closeApp, c.closeCode, c.closeReason)
+		w.appendFrame(b, sentFrame{ftype: frameTypeConnectionCloseTransport})
+		c.commitPacket(w, sp, pnumLen)
+	}
+}
+
+// buildPacket adds a packet for a number space to the datagram,
+// if there is anything to send in the space.
+func (c *Conn) buildPacket(now time.Time, w *packetWriter, sp numberSpace, ccLimited bool) {
+	s := &c.spaces[sp]
+	if !s.canSend() {
+		return
+	}
+	pnumLen, ok := c.beginPacket(now, w, sp)
+	if !ok {
+		return
+	}
+
+	// Prepare an ACK frame, which is added to the packet if it contains
+	// other frames or if an acknowledgement is due.
+	var ack []byte
+	if s.ackPending && len(s.seen) > 0 {
+		var delay uint64
+		if sp == appDataSpace {
+			delay = ackDelayFromDuration(now.Sub(s.largestSeenTime))
+		}
+		ack = appendAckFrame(nil, s.seen, delay, min(w.avail(), 256))
+		w.frameMax -= len(ack)
+	}
+
+	if !ccLimited || s.probes > 0 {
+		c.appendFrames(now, w, sp)
+		if s.probes > 0 && !w.sent.ackEliciting {
+			w.appendFrame([]byte{frameTypePing}, sentFrame{ftype: frameTypePing})
+		}
+	}
+
+	if ack != nil {
+		w.frameMax += len(ack)
+		if len(w.payload) > 0 || s.ackDue(now) {
+			w.appendFrame(ack, sentFrame{ftype: frameTypeAck, off: s.seen.max() - 1})
+			s.ackSent()
+		}
+	}
+	c.commitPacket(w, sp, pnumLen)
+}
+
+// appendFrames appends ack-eliciting frames for a number space
+// to the current packet.
+func (c *Conn) appendFrames(now time.Time, w *packetWriter, sp numberSpace) {
+	s := &c.spaces[sp]
+
+	if sp == appDataSpace {
+		if c.handshakeDoneState == sendUnsent {
+			if w.appendFrame([]byte{frameTypeHandshakeDone}, sentFrame{ftype: frameTypeHandshakeDone}) {
+				c.handshakeDoneState = sendSent
+			}
+		}
+	}
+
+	// CRYPTO data.
+	for s.cryptoOut.hasUnsent() {
+		avail := w.avail() - 1 - 8 - 2
+		if avail <= 0 {
+			break
+		}
+		off, data, _ := s.cryptoOut.next(int64(avail))
+		b := appendCryptoFrame(nil, off, len(data))
+		b = append(b, data...)
+		if !w.appendFrame(b, sentFrame{ftype: frameTypeCrypto, off: off, n: int64(len(data))}) {
+			break
+		}
+		s.cryptoOut.markSent(off, int64(len(data)))
+	}
+
+	if sp != appDataSpace || !c.spaces[appDataSpace].canSend() {
+		return
+	}
+	if c.side == clientSide && !c.handshakeComplete {
+		return
+	}
+
+	for len(c.pathResponses) > 0 {
+		b := append([]byte{frameTypePathResponse}, c.pathResponses[0][:]...)
+		if !w.appendFrame(b, sentFrame{ftype: frameTypePathResponse}) {
+			break
+		}
+		c.pathResponses = c.pathResponses[1:]
+	}
+	if c.inMaxState == sendUnsent {
+		b := appendVarint([]byte{frameTypeMaxData}, uint64(c.inMax))
+		if w.appendFrame(b, sentFrame{ftype: frameTypeMaxData, off: c.inMax}) {
+			c.inMaxState = sendSent
+		}
+	}
+	for dir := range 2 {
+		if c.streams.localMaxState[dir] != sendUnsent {
+			continue
+		}
+		ftype := uint8(frameTypeMaxStreamsBidi + dir)
+		v := c.streams.localMax[dir]
+		b := appendVarint([]byte{ftype}, uint64(v))
+		if w.appendFrame(b, sentFrame{ftype: ftype, off: v}) {
+			c.streams.localMaxState[dir] = sendSent
+		}
+	}
+	for len(c.retireConnIDs) > 0 {
+		seq := c.retireConnIDs[0]
+		b := appendVarint([]byte{frameTypeRetireConnectionID}, uint64(seq))
+		if !w.appendFrame(b, sentFrame{ftype: frameTypeRetireConnectionID, id: seq}) {
+			break
+		}
+		c.retireConnIDs = c.retireConnIDs[1:]
+	}
+
+	// Stream frames, round-robin.
+	for n := len(c.streams.queue); n > 0 && w.avail() > 0; n-- {
+		st := c.streams.queue[0]
+		c.streams.queue = c.streams.queue[1:]
+		if st.done || !c.appendStreamFrames(w, st) {
+			st.queued = false
+			continue
+		}
+		// Still has frames: keep it in the queue.
+		c.streams.queue = append(c.streams.queue, st)
+		if w.avail() < 32 {
+			break
+		}
+	}
+
+	if c.keepAlivePending {
+		if w.sent.ackEliciting || w.appendFrame([]byte{frameTypePing}, sentFrame{ftype: frameTypePing}) {
+			c.keepAlivePending = false
+		}
+	}
+}
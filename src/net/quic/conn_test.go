@@ -0,0 +1,390 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testCertOnce sync.Once
+	testCert     tls.Certificate
+	testCertPool *x509.CertPool
+)
+
+func testTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	testCertOnce.Do(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "example.com"},
+			DNSNames:              []string{"example.com"},
+			IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			panic(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			panic(err)
+		}
+		testCert = tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+		testCertPool = x509.NewCertPool()
+		testCertPool.AddCert(cert)
+	})
+	server = &tls.Config{
+		MinVersion:   tls.VersionTLS13,
+		Certificates: []tls.Certificate{testCert},
+		NextProtos:   []string{"test"},
+	}
+	client = &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		RootCAs:    testCertPool,
+		ServerName: "example.com",
+		NextProtos: []string{"test"},
+	}
+	return server, client
+}
+
+// newTestEndpoints returns a listening server endpoint and a client endpoint
+// on the loopback interface. Either PacketConn may be wrapped by wrap.
+func newTestEndpoints(t *testing.T, wrap func(net.PacketConn) net.PacketConn) (server, client *Endpoint, clientConfig *Config) {
+	t.Helper()
+	serverTLS, clientTLS := testTLSConfigs(t)
+	listen := func(config *Config) *Endpoint {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Skipf("cannot listen on loopback: %v", err)
+		}
+		if wrap != nil {
+			pc = wrap(pc)
+		}
+		e := NewEndpoint(pc, config)
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			e.Close(ctx)
+		})
+		return e
+	}
+	server = listen(&Config{TLSConfig: serverTLS})
+	client = listen(nil)
+	return server, client, &Config{TLSConfig: clientTLS}
+}
+
+func dialTestConn(t *testing.T, server, client *Endpoint, config *Config) (cc, sc *Conn) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	type result struct {
+		c   *Conn
+		err error
+	}
+	accepted := make(chan result, 1)
+	go func() {
+		c, err := server.Accept(ctx)
+		accepted <- result{c, err}
+	}()
+	cc, err := client.Dial(ctx, "udp", server.LocalAddr().String(), config)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	r := <-accepted
+	if r.err != nil {
+		t.Fatalf("Accept: %v", r.err)
+	}
+	return cc, r.c
+}
+
+func TestConnHandshake(t *testing.T) {
+	server, client, config := newTestEndpoints(t, nil)
+	cc, sc := dialTestConn(t, server, client, config)
+	if got := cc.ConnectionState().NegotiatedProtocol; got != "test" {
+		t.Errorf("client NegotiatedProtocol = %q, want %q", got, "test")
+	}
+	if got := sc.ConnectionState().ServerName; got != "example.com" {
+		t.Errorf("server ServerName = %q, want %q", got, "example.com")
+	}
+	if got, want := cc.RemoteAddr().String(), server.LocalAddr().String(); got != want {
+		t.Errorf("client RemoteAddr = %v, want %v", got, want)
+	}
+}
+
+func TestConnHandshakeBadCertificate(t *testing.T) {
+	server, client, config := newTestEndpoints(t, nil)
+	config.TLSConfig.ServerName = "wrong.example"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := client.Dial(ctx, "udp", server.LocalAddr().String(), config)
+	if err == nil {
+		t.Fatal("Dial with wrong server name succeeded, want error")
+	}
+	var alert tls.AlertError
+	if !errors.As(err, &alert) {
+		t.Errorf("Dial error = %v, want tls.AlertError", err)
+	}
+}
+
+func testStreamEcho(t *testing.T, cc, sc *Conn, size int) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	serverErr := make(chan error, 1)
+	go func() {
+		s, err := sc.AcceptStream(ctx)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		if _, err := io.Copy(s, s); err != nil {
+			serverErr <- err
+			return
+		}
+		s.CloseWrite()
+		serverErr <- nil
+	}()
+
+	s, err := cc.NewStream(ctx)
+	if err != nil {
+		t.Fatalf("NewStream: %v", err)
+	}
+	s.SetDeadline(time.Now().Add(30 * time.Second))
+	want := make([]byte, size)
+	rand.Read(want)
+	writeErr := make(chan error, 1)
+	go func() {
+		_, err := s.Write(want)
+		s.CloseWrite()
+		writeErr <- err
+	}()
+	got, err := io.ReadAll(s)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if err := <-writeErr; err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := <-serverErr; err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("echoed %v bytes, want %v bytes of sent data", len(got), len(want))
+	}
+}
+
+func TestStreamEcho(t *testing.T) {
+	server, client, config := newTestEndpoints(t, nil)
+	cc, sc := dialTestConn(t, server, client, config)
+	testStreamEcho(t, cc, sc, 1<<20)
+}
+
+func TestStreamManyConcurrent(t *testing.T) {
+	server, client, config := newTestEndpoints(t, nil)
+	cc, sc := dialTestConn(t, server, client, config)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	go func() {
+		for {
+			s, err := sc.AcceptStream(ctx)
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(s, s)
+				s.CloseWrite()
+			}()
+		}
+	}()
+	const n = 200 // more than the default stream limit
+	var wg sync.WaitGroup
+	errc := make(chan error, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := cc.NewStream(ctx)
+			if err != nil {
+				errc <- err
+				return
+			}
+			msg := []byte{byte(i), byte(i >> 8)}
+			s.Write(msg)
+			s.CloseWrite()
+			got, err := io.ReadAll(s)
+			if err != nil {
+				errc <- err
+				return
+			}
+			if !bytes.Equal(got, msg) {
+				errc <- errors.New("stream echoed wrong data")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		t.Error(err)
+	}
+}
+
+func TestStreamReset(t *testing.T) {
+	server, client, config := newTestEndpoints(t, nil)
+	cc, sc := dialTestConn(t, server, client, config)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s, err := cc.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("hello"))
+	ss, err := sc.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Reset(42)
+	ss.SetReadDeadline(time.Now().Add(10 * time.Second))
+	_, err = io.ReadAll(ss)
+	var code StreamErrorCode
+	if !errors.As(err, &code) || code != 42 {
+		t.Errorf("Read after Reset(42): %v, want StreamErrorCode(42)", err)
+	}
+}
+
+func TestStreamReadDeadline(t *testing.T) {
+	server, client, config := newTestEndpoints(t, nil)
+	cc, _ := dialTestConn(t, server, client, config)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s, err := cc.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	var buf [1]byte
+	_, err = s.Read(buf[:])
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Errorf("Read past deadline: %v, want timeout error", err)
+	}
+}
+
+func TestConnCloseApplicationError(t *testing.T) {
+	server, client, config := newTestEndpoints(t, nil)
+	cc, sc := dialTestConn(t, server, client, config)
+	cc.Abort(&ApplicationError{Code: 7, Reason: "bye"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := sc.Wait(ctx)
+	if want := (&ApplicationError{Code: 7}); !errors.Is(err, want) {
+		t.Errorf("server Wait = %v, want %v", err, want)
+	}
+	if _, err := sc.AcceptStream(ctx); err == nil {
+		t.Errorf("AcceptStream on closed conn succeeded")
+	}
+}
+
+func TestConnCloseNoError(t *testing.T) {
+	server, client, config := newTestEndpoints(t, nil)
+	cc, sc := dialTestConn(t, server, client, config)
+	if err := cc.Close(); err != nil {
+		t.Errorf("client Close = %v, want nil", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sc.Wait(ctx); err != nil {
+		t.Errorf("server Wait = %v, want nil", err)
+	}
+}
+
+func TestConnIdleTimeout(t *testing.T) {
+	server, client, config := newTestEndpoints(t, nil)
+	config.MaxIdleTimeout = 100 * time.Millisecond
+	cc, _ := dialTestConn(t, server, client, config)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cc.Wait(ctx); !errors.Is(err, errIdleTimeout) {
+		t.Errorf("Wait = %v, want idle timeout", err)
+	}
+}
+
+func TestConnKeepAlive(t *testing.T) {
+	server, client, config := newTestEndpoints(t, nil)
+	config.MaxIdleTimeout = 200 * time.Millisecond
+	config.KeepAlivePeriod = 50 * time.Millisecond
+	cc, _ := dialTestConn(t, server, client, config)
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+	if err := cc.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Wait = %v, want connection kept alive", err)
+	}
+}
+
+// lossyPacketConn drops every nth datagram it sends.
+type lossyPacketConn struct {
+	net.PacketConn
+	mu sync.Mutex
+	n  int
+	i  int
+}
+
+func (c *lossyPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	c.i++
+	drop := c.i%c.n == 0
+	c.mu.Unlock()
+	if drop {
+		return len(b), nil
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func TestStreamEchoLossy(t *testing.T) {
+	server, client, config := newTestEndpoints(t, func(pc net.PacketConn) net.PacketConn {
+		return &lossyPacketConn{PacketConn: pc, n: 5}
+	})
+	cc, sc := dialTestConn(t, server, client, config)
+	testStreamEcho(t, cc, sc, 256<<10)
+}
+
+func TestEndpointClose(t *testing.T) {
+	server, client, config := newTestEndpoints(t, nil)
+	cc, _ := dialTestConn(t, server, client, config)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Close(ctx); err != nil {
+		t.Fatalf("server Close: %v", err)
+	}
+	if _, err := server.Accept(ctx); err == nil {
+		t.Errorf("Accept on closed endpoint succeeded")
+	}
+	if err := cc.Wait(ctx); err != nil {
+		t.Errorf("client Wait after server endpoint Close = %v, want nil", err)
+	}
+}
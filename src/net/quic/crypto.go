@@ -0,0 +1,255 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"hash"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// aeadOverhead is the size of the authentication tag added by
+	// every AEAD used by QUIC.
+	aeadOverhead = 16
+
+	// headerProtectionSampleSize is the size of the ciphertext sample
+	// used for header protection (RFC 9001, Section 5.4.2).
+	headerProtectionSampleSize = 16
+)
+
+// initialSalt is the salt used to derive Initial keys (RFC 9001, Section 5.2).
+var initialSalt = []byte{
+	0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+	0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a,
+}
+
+// hkdfExpandLabel implements HKDF-Expand-Label from RFC 8446, Section 7.1,
+// with an empty context.
+func hkdfExpandLabel(h func() hash.Hash, secret []byte, label string, length int) []byte {
+	const prefix = "tls13 "
+	info := make([]byte, 0, 4+len(prefix)+len(label))
+	info = binary.BigEndian.AppendUint16(info, uint16(length))
+	info = append(info, byte(len(prefix)+len(label)))
+	info = append(info, prefix...)
+	info = append(info, label...)
+	info = append(info, 0) // context
+	out, err := hkdf.Expand(h, secret, string(info), length)
+	if err != nil {
+		panic("quic: " + err.Error())
+	}
+	return out
+}
+
+// suiteHash returns the hash function and AEAD key size for a TLS 1.3 cipher suite.
+func suiteHash(suite uint16) (h func() hash.Hash, keyLen int, err error) {
+	switch suite {
+	case tls.TLS_AES_128_GCM_SHA256:
+		return sha256.New, 16, nil
+	case tls.TLS_AES_256_GCM_SHA384:
+		return sha512.New384, 32, nil
+	case tls.TLS_CHACHA20_POLY1305_SHA256:
+		return sha256.New, chacha20poly1305.KeySize, nil
+	}
+	return nil, 0, errors.New("quic: unsupported cipher suite")
+}
+
+// headerProtection applies and removes QUIC header protection
+// (RFC 9001, Section 5.4).
+type headerProtection struct {
+	block     cipher.Block // AES-based suites
+	chachaKey []byte       // ChaCha20-based suites
+}
+
+// mask returns the header protection mask for a ciphertext sample.
+func (hp *headerProtection) mask(sample []byte) (mask [5]byte) {
+	if hp.block != nil {
+		var out [aes.BlockSize]byte
+		hp.block.Encrypt(out[:], sample)
+		copy(mask[:], out[:])
+		return mask
+	}
+	c, err := chacha20.NewUnauthenticatedCipher(hp.chachaKey, sample[4:16])
+	if err != nil {
+		panic("quic: " + err.Error())
+	}
+	c.SetCounter(binary.LittleEndian.Uint32(sample[0:4]))
+	c.XORKeyStream(mask[:], mask[:])
+	return mask
+}
+
+// packetKeys are the keys used to protect packets in one direction
+// at one encryption level.
+type packetKeys struct {
+	suite  uint16
+	secret []byte
+	hp     *headerProtection
+	aead   cipher.AEAD
+	iv     []byte
+}
+
+// newPacketKeys derives packet protection keys from a TLS traffic secret
+// (RFC 9001, Section 5.1).
+func newPacketKeys(suite uint16, secret []byte) (*packetKeys, error) {
+	h, keyLen, err := suiteHash(suite)
+	if err != nil {
+		return nil, err
+	}
+	hpKey := hkdfExpandLabel(h, secret, "quic hp", keyLen)
+	hp := &headerProtection{}
+	if suite == tls.TLS_CHACHA20_POLY1305_SHA256 {
+		hp.chachaKey = hpKey
+	} else if hp.block, err = aes.NewCipher(hpKey); err != nil {
+		return nil, err
+	}
+	return newPacketKeysWithHP(suite, secret, hp)
+}
+
+func newPacketKeysWithHP(suite uint16, secret []byte, hp *headerProtection) (*packetKeys, error) {
+	h, keyLen, err := suiteHash(suite)
+	if err != nil {
+		return nil, err
+	}
+	key := hkdfExpandLabel(h, secret, "quic key", keyLen)
+	k := &packetKeys{
+		suite:  suite,
+		secret: secret,
+		hp:     hp,
+		iv:     hkdfExpandLabel(h, secret, "quic iv", 12),
+	}
+	if suite == tls.TLS_CHACHA20_POLY1305_SHA256 {
+		k.aead, err = chacha20poly1305.New(key)
+	} else {
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err == nil {
+			k.aead, err = cipher.NewGCM(block)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// next returns the keys for the next key phase (RFC 9001, Section 6).
+// Header protection keys do not change.
+func (k *packetKeys) next() *packetKeys {
+	h, _, _ := suiteHash(k.suite)
+	secret := hkdfExpandLabel(h, k.secret, "quic ku", len(k.secret))
+	nk, err := newPacketKeysWithHP(k.suite, secret, k.hp)
+	if err != nil {
+		panic("quic: " + err.Error())
+	}
+	return nk
+}
+
+func (k *packetKeys) nonce(pnum int64) []byte {
+	nonce := make([]byte, len(k.iv))
+	copy(nonce, k.iv)
+	for i := range 8 {
+		nonce[len(nonce)-1-i] ^= byte(pnum >> (8 * i))
+	}
+	return nonce
+}
+
+// protect encrypts the payload of the packet in pkt, starting at
+// pnumOff+pnumLen, appends the authentication tag, and applies header
+// protection. The packet number field must already be written.
+// pkt must have at least aeadOverhead bytes of spare capacity.
+func (k *packetKeys) protect(pkt []byte, pnumOff, pnumLen int, pnum int64) []byte {
+	hdrLen := pnumOff + pnumLen
+	k.aead.Seal(pkt[hdrLen:hdrLen], k.nonce(pnum), pkt[hdrLen:], pkt[:hdrLen])
+	pkt = pkt[:len(pkt)+aeadOverhead]
+	sample := pkt[pnumOff+4:][:headerProtectionSampleSize]
+	mask := k.hp.mask(sample)
+	if isLongHeader(pkt[0]) {
+		pkt[0] ^= mask[0] & 0x0f
+	} else {
+		pkt[0] ^= mask[0] & 0x1f
+	}
+	for i := range pnumLen {
+		pkt[pnumOff+i] ^= mask[1+i]
+	}
+	return pkt
+}
+
+// unprotectHeader removes header protection from pkt in place,
+// returning the length of the packet number field and its truncated value.
+// pkt is modified even if the packet cannot be authenticated later.
+func unprotectHeader(hp *headerProtection, pkt []byte, pnumOff int) (pnumLen int, pnum int64, ok bool) {
+	if len(pkt) < pnumOff+4+headerProtectionSampleSize {
+		return 0, 0, false
+	}
+	mask := hp.mask(pkt[pnumOff+4:][:headerProtectionSampleSize])
+	if isLongHeader(pkt[0]) {
+		pkt[0] ^= mask[0] & 0x0f
+	} else {
+		pkt[0] ^= mask[0] & 0x1f
+	}
+	pnumLen = int(pkt[0]&0x03) + 1
+	for i := range pnumLen {
+		pkt[pnumOff+i] ^= mask[1+i]
+		pnum = pnum<<8 | int64(pkt[pnumOff+i])
+	}
+	return pnumLen, pnum, true
+}
+
+// open decrypts the payload of a packet whose header protection has been
+// removed, returning the plaintext payload.
+func (k *packetKeys) open(pkt []byte, hdrLen int, pnum int64) ([]byte, error) {
+	return k.aead.Open(pkt[hdrLen:hdrLen], k.nonce(pnum), pkt[hdrLen:], pkt[:hdrLen])
+}
+
+// initialKeys returns the client and server Initial packet protection
+// keys for a connection with the given original destination connection ID
+// (RFC 9001, Section 5.2).
+func initialKeys(dstConnID []byte) (client, server *packetKeys) {
+	initialSecret, err := hkdf.Extract(sha256.New, dstConnID, initialSalt)
+	if err != nil {
+		panic("quic: " + err.Error())
+	}
+	derive := func(label string) *packetKeys {
+		secret := hkdfExpandLabel(sha256.New, initialSecret, label, sha256.Size)
+		k, err := newPacketKeys(tls.TLS_AES_128_GCM_SHA256, secret)
+		if err != nil {
+			panic("quic: " + err.Error())
+		}
+		return k
+	}
+	return derive("client in"), derive("server in")
+}
+
+// retryIntegrityTag computes the Retry Integrity Tag for a Retry packet
+// (RFC 9001, Section 5.8). pkt is the Retry packet without its tag.
+func retryIntegrityTag(origDstConnID, pkt []byte) []byte {
+	key := []byte{
+		0xbe, 0x0c, 0x69, 0x0b, 0x9f, 0x66, 0x57, 0x5a,
+		0x1d, 0x76, 0x6b, 0x54, 0xe3, 0x68, 0xc8, 0x4e,
+	}
+	nonce := []byte{
+		0x46, 0x15, 0x99, 0xd3, 0x5d, 0x63, 0x2b, 0xf2,
+		0x23, 0x98, 0x25, 0xbb,
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic("quic: " + err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic("quic: " + err.Error())
+	}
+	pseudo := appendUint8Bytes(nil, origDstConnID)
+	pseudo = append(pseudo, pkt...)
+	return aead.Seal(nil, nonce, nil, pseudo)
+}
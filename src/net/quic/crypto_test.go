@@ -0,0 +1,96 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Test vectors from RFC 9001, Appendix A.
+
+func TestInitialKeys(t *testing.T) {
+	client, server := initialKeys(unhex(t, "8394c8f03e515708"))
+	for _, test := range []struct {
+		name   string
+		k      *packetKeys
+		secret string
+		iv     string
+	}{{
+		name:   "client",
+		k:      client,
+		secret: "c00cf151ca5be075ed0ebfb5c80323c42d6b7db67881289af4008f1f6c357aea",
+		iv:     "fa044b2f42a3fd3b46fb255c",
+	}, {
+		name:   "server",
+		k:      server,
+		secret: "3c199828fd139efd216c155ad844cc81fb82fa8d7446fa7d78be803acdda951b",
+		iv:     "0ac1493ca1905853b0bba03e",
+	}} {
+		if got, want := test.k.secret, unhex(t, test.secret); !bytes.Equal(got, want) {
+			t.Errorf("%v secret = %x, want %x", test.name, got, want)
+		}
+		if got, want := test.k.iv, unhex(t, test.iv); !bytes.Equal(got, want) {
+			t.Errorf("%v iv = %x, want %x", test.name, got, want)
+		}
+	}
+}
+
+func TestProtectChaCha20(t *testing.T) {
+	// RFC 9001, Appendix A.5.
+	secret := unhex(t, "9ac312a7f877468ebe69422748ad00a15443f18203a07d6060f688f30f21632b")
+	k, err := newPacketKeys(tls.TLS_CHACHA20_POLY1305_SHA256, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := k.next().secret, unhex(t, "1223504755036d556342ee9361d253421a826c9ecdf3c7148684b36b714881f9"); !bytes.Equal(got, want) {
+		t.Errorf("key update secret = %x, want %x", got, want)
+	}
+
+	const pnum = 654360564
+	pkt := make([]byte, 0, 64)
+	pkt = append(pkt, unhex(t, "4200bff4")...)
+	pkt = append(pkt, 0x01)
+	pkt = k.protect(pkt, 1, 3, pnum)
+	want := unhex(t, "4cfe4189655e5cd55c41f69080575d7999c25a5bfb")
+	if !bytes.Equal(pkt, want) {
+		t.Fatalf("protected packet:\n got %x\nwant %x", pkt, want)
+	}
+
+	pnumLen, truncated, ok := unprotectHeader(k.hp, pkt, 1)
+	if !ok || pnumLen != 3 {
+		t.Fatalf("unprotectHeader = %v, %v, %v; want 3, _, true", pnumLen, truncated, ok)
+	}
+	if got := decodePacketNumber(pnum-1, truncated, pnumLen); got != pnum {
+		t.Fatalf("packet number = %v, want %v", got, pnum)
+	}
+	payload, err := k.open(pkt, 1+pnumLen, pnum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payload, []byte{0x01}) {
+		t.Fatalf("payload = %x, want 01", payload)
+	}
+}
+
+func TestRetryIntegrityTag(t *testing.T) {
+	// RFC 9001, Appendix A.4.
+	pkt := unhex(t, "ff000000010008f067a5502a4262b5746f6b656e")
+	want := unhex(t, "04a265ba2eff4d829058fb3f0f2496ba")
+	if got := retryIntegrityTag(unhex(t, "8394c8f03e515708"), pkt); !bytes.Equal(got, want) {
+		t.Errorf("retryIntegrityTag = %x, want %x", got, want)
+	}
+}
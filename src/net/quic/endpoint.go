@@ -0,0 +1,307 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// An Endpoint handles QUIC traffic on a network address.
+// It can accept inbound connections or create outbound ones.
+//
+// Multiple goroutines may invoke methods on an Endpoint simultaneously.
+type Endpoint struct {
+	pc     net.PacketConn
+	config *Config
+
+	readDone chan struct{}
+
+	mu        sync.Mutex
+	conns     map[string]*Conn // keyed by connection ID
+	acceptQ   []*Conn
+	acceptSig signal
+	closing   bool
+	closec    chan struct{} // closed when the endpoint begins closing
+}
+
+// Listen listens on a local network address.
+//
+// The network must be "udp", "udp4", or "udp6".
+// The config is used for connections accepted by the endpoint.
+// If config is nil, the endpoint will not accept connections,
+// and may only be used to create outbound ones with [Endpoint.Dial].
+func Listen(network, address string, config *Config) (*Endpoint, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+	pc, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewEndpoint(pc, config), nil
+}
+
+// NewEndpoint returns an endpoint that sends and receives QUIC packets
+// on pc. The endpoint takes ownership of pc, and closes it when the
+// endpoint is closed.
+//
+// The config is used for connections accepted by the endpoint,
+// as with [Listen].
+func NewEndpoint(pc net.PacketConn, config *Config) *Endpoint {
+	e := &Endpoint{
+		pc:        pc,
+		config:    config,
+		readDone:  make(chan struct{}),
+		conns:     make(map[string]*Conn),
+		acceptSig: newSignal(),
+		closec:    make(chan struct{}),
+	}
+	go e.readLoop()
+	return e
+}
+
+// LocalAddr returns the local network address.
+func (e *Endpoint) LocalAddr() net.Addr {
+	return e.pc.LocalAddr()
+}
+
+// Close closes the endpoint.
+//
+// Close closes all connections, waits for their peers to acknowledge
+// the close (or for the connections to time out), and then closes the
+// underlying network connection. If ctx expires first, the remaining
+// connections and the network connection are closed immediately,
+// and Close returns ctx's error.
+func (e *Endpoint) Close(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closing {
+		e.closing = true
+		close(e.closec)
+	}
+	conns := e.uniqueConnsLocked()
+	e.mu.Unlock()
+
+	for _, c := range conns {
+		c.Abort(nil)
+	}
+	var err error
+	for _, c := range conns {
+		select {
+		case <-c.drainedc:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+	}
+	// Connections that have finished closing send nothing more,
+	// so there is no need to wait out the rest of their draining period.
+	for _, c := range conns {
+		c.exit()
+	}
+	for _, c := range conns {
+		<-c.donec
+	}
+	if cerr := e.pc.Close(); err == nil && cerr != nil && !errors.Is(cerr, net.ErrClosed) {
+		err = cerr
+	}
+	<-e.readDone
+	return err
+}
+
+func (e *Endpoint) uniqueConnsLocked() []*Conn {
+	seen := make(map[*Conn]bool)
+	var conns []*Conn
+	for _, c := range e.conns {
+		if !seen[c] {
+			seen[c] = true
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+// Accept waits for and returns the next connection to the endpoint.
+// The handshake of a returned connection has completed.
+func (e *Endpoint) Accept(ctx context.Context) (*Conn, error) {
+	for {
+		e.mu.Lock()
+		if len(e.acceptQ) > 0 {
+			c := e.acceptQ[0]
+			e.acceptQ = e.acceptQ[1:]
+			if len(e.acceptQ) > 0 {
+				e.acceptSig.notify()
+			}
+			e.mu.Unlock()
+			return c, nil
+		}
+		closing := e.closing
+		e.mu.Unlock()
+		if closing {
+			return nil, errEndpointClosed
+		}
+		select {
+		case <-e.acceptSig:
+		case <-e.closec:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Dial creates and returns a connection to a network address.
+// The config cannot be nil.
+// Dial returns after the connection handshake completes.
+func (e *Endpoint) Dial(ctx context.Context, network, address string, config *Config) (*Conn, error) {
+	addr, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil && config.TLSConfig != nil && config.TLSConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		cc := *config
+		cc.TLSConfig = config.TLSConfig.Clone()
+		cc.TLSConfig.ServerName = host
+		config = &cc
+	}
+	e.mu.Lock()
+	if e.closing {
+		e.mu.Unlock()
+		return nil, errEndpointClosed
+	}
+	c, err := newConn(time.Now(), clientSide, e, addr, config, newConnID(), nil)
+	if err != nil {
+		e.mu.Unlock()
+		return nil, err
+	}
+	e.conns[string(c.localConnID)] = c
+	e.mu.Unlock()
+	go c.loop()
+
+	select {
+	case <-c.handshakeDonec:
+		return c, nil
+	case <-c.closedc:
+		c.mu.Lock()
+		err := c.closeErr
+		c.mu.Unlock()
+		return nil, err
+	case <-ctx.Done():
+		c.Abort(nil)
+		return nil, ctx.Err()
+	}
+}
+
+// enqueueAccept adds a connection that has completed its handshake
+// to the accept queue.
+func (e *Endpoint) enqueueAccept(c *Conn) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.acceptQ = append(e.acceptQ, c)
+	e.acceptSig.notify()
+}
+
+// removeConn removes a connection that is done.
+func (e *Endpoint) removeConn(c *Conn) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id, cc := range e.conns {
+		if cc == c {
+			delete(e.conns, id)
+		}
+	}
+}
+
+// writeTo sends a datagram.
+func (e *Endpoint) writeTo(b []byte, addr net.Addr) {
+	// Errors are treated as packet loss.
+	e.pc.WriteTo(b, addr)
+}
+
+// readLoop reads datagrams and dispatches them to connections.
+func (e *Endpoint) readLoop() {
+	defer close(e.readDone)
+	buf := make([]byte, 64<<10)
+	for {
+		n, addr, err := e.pc.ReadFrom(buf)
+		if err != nil {
+			e.mu.Lock()
+			closing := e.closing
+			conns := e.uniqueConnsLocked()
+			e.mu.Unlock()
+			if !closing {
+				for _, c := range conns {
+					c.Abort(errEndpointClosed)
+				}
+			}
+			return
+		}
+		if n == 0 {
+			continue
+		}
+		e.handleDatagram(addr, append([]byte(nil), buf[:n]...))
+	}
+}
+
+// handleDatagram dispatches a datagram to its connection,
+// creating a new connection if necessary.
+func (e *Endpoint) handleDatagram(addr net.Addr, b []byte) {
+	dcid, ok := dstConnIDForDatagram(b)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	c := e.conns[string(dcid)]
+	if c == nil && isLongHeader(b[0]) {
+		c = e.newServerConn(addr, b)
+	}
+	e.mu.Unlock()
+	if c != nil {
+		c.deliver(b)
+	}
+}
+
+// newServerConn handles a long header packet for an unknown connection.
+// It creates a new connection for a valid client Initial packet.
+func (e *Endpoint) newServerConn(addr net.Addr, b []byte) *Conn {
+	if e.config == nil || e.closing {
+		return nil
+	}
+	p, ok := parseLongHeader(b)
+	if !ok {
+		return nil
+	}
+	if p.version != quicVersion1 {
+		if p.ptype != packetTypeVersionNegotiation && len(b) >= maxDatagramSize {
+			vn := appendVersionNegotiation(nil, p.srcConnID, p.dstConnID, quicVersion1)
+			e.writeTo(vn, addr)
+		}
+		return nil
+	}
+	// A client's first datagram must be at least 1200 bytes,
+	// and its destination connection ID at least 8 bytes
+	// (RFC 9000, Sections 7.2 and 14.1).
+	if p.ptype != packetTypeInitial || len(b) < maxDatagramSize || len(p.dstConnID) < 8 {
+		return nil
+	}
+	c, err := newConn(time.Now(), serverSide, e, addr, e.config, append([]byte(nil), p.dstConnID...), append([]byte(nil), p.srcConnID...))
+	if err != nil {
+		return nil
+	}
+	e.conns[string(c.localConnID)] = c
+	e.conns[string(c.origDstConnID)] = c
+	go c.loop()
+	return c
+}
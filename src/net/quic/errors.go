@@ -0,0 +1,140 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A transportError is a QUIC transport error code (RFC 9000, Section 20.1).
+type transportError uint64
+
+const (
+	errNo                   = transportError(0x00)
+	errInternal             = transportError(0x01)
+	errConnectionRefused    = transportError(0x02)
+	errFlowControl          = transportError(0x03)
+	errStreamLimit          = transportError(0x04)
+	errStreamState          = transportError(0x05)
+	errFinalSize            = transportError(0x06)
+	errFrameEncoding        = transportError(0x07)
+	errTransportParameter   = transportError(0x08)
+	errConnectionIDLimit    = transportError(0x09)
+	errProtocolViolation    = transportError(0x0a)
+	errInvalidToken         = transportError(0x0b)
+	errApplicationError     = transportError(0x0c)
+	errCryptoBufferExceeded = transportError(0x0d)
+	errKeyUpdate            = transportError(0x0e)
+	errAEADLimitReached     = transportError(0x0f)
+	errNoViablePath         = transportError(0x10)
+	errTLSBase              = transportError(0x0100) // 0x0100-0x01ff; base + TLS alert code
+)
+
+var transportErrorNames = [...]string{
+	errNo:                   "NO_ERROR",
+	errInternal:             "INTERNAL_ERROR",
+	errConnectionRefused:    "CONNECTION_REFUSED",
+	errFlowControl:          "FLOW_CONTROL_ERROR",
+	errStreamLimit:          "STREAM_LIMIT_ERROR",
+	errStreamState:          "STREAM_STATE_ERROR",
+	errFinalSize:            "FINAL_SIZE_ERROR",
+	errFrameEncoding:        "FRAME_ENCODING_ERROR",
+	errTransportParameter:   "TRANSPORT_PARAMETER_ERROR",
+	errConnectionIDLimit:    "CONNECTION_ID_LIMIT_ERROR",
+	errProtocolViolation:    "PROTOCOL_VIOLATION",
+	errInvalidToken:         "INVALID_TOKEN",
+	errApplicationError:     "APPLICATION_ERROR",
+	errCryptoBufferExceeded: "CRYPTO_BUFFER_EXCEEDED",
+	errKeyUpdate:            "KEY_UPDATE_ERROR",
+	errAEADLimitReached:     "AEAD_LIMIT_REACHED",
+	errNoViablePath:         "NO_VIABLE_PATH",
+}
+
+func (e transportError) String() string {
+	if e < transportError(len(transportErrorNames)) {
+		return transportErrorNames[e]
+	}
+	if e >= errTLSBase && e <= errTLSBase+0xff {
+		return fmt.Sprintf("CRYPTO_ERROR(%v)", uint64(e-errTLSBase))
+	}
+	return fmt.Sprintf("ERROR %d", uint64(e))
+}
+
+// A localTransportError is a transport error detected by this endpoint
+// and sent to the peer.
+type localTransportError struct {
+	code   transportError
+	reason string
+	err    error // underlying error, if any
+}
+
+func (e localTransportError) Error() string {
+	if e.reason == "" {
+		return "quic: " + e.code.String()
+	}
+	return "quic: " + e.code.String() + ": " + e.reason
+}
+
+func (e localTransportError) Unwrap() error { return e.err }
+
+// A peerTransportError is a transport error received from the peer.
+type peerTransportError struct {
+	code   transportError
+	reason string
+}
+
+func (e peerTransportError) Error() string {
+	return fmt.Sprintf("quic: peer closed connection: %v: %q", e.code, e.reason)
+}
+
+// An ApplicationError is an application protocol error code
+// (RFC 9000, Section 20.2).
+//
+// When returned by a [Conn] or [Stream] method, it indicates that the
+// peer closed the connection with this error. When passed to
+// [Conn.Abort], it is sent to the peer.
+type ApplicationError struct {
+	Code   uint64
+	Reason string
+}
+
+func (e *ApplicationError) Error() string {
+	return fmt.Sprintf("quic: peer closed connection: %v: %q", e.Code, e.Reason)
+}
+
+// Is reports whether target is an ApplicationError with the same code.
+func (e *ApplicationError) Is(target error) bool {
+	t, ok := target.(*ApplicationError)
+	return ok && t.Code == e.Code
+}
+
+// A StreamErrorCode is an application protocol error code
+// (RFC 9000, Section 20.2) indicating why a stream is being closed.
+//
+// It is returned by [Stream.Read] when the peer resets the stream,
+// and by [Stream.Write] when the peer asks to stop sending.
+type StreamErrorCode uint64
+
+func (e StreamErrorCode) Error() string {
+	return fmt.Sprintf("quic: stream error code %v", uint64(e))
+}
+
+// timeoutError is returned when a connection is closed after a timeout.
+type timeoutError string
+
+func (e timeoutError) Error() string   { return string(e) }
+func (e timeoutError) Timeout() bool   { return true }
+func (e timeoutError) Temporary() bool { return true }
+
+var (
+	errIdleTimeout      = timeoutError("quic: connection timed out due to inactivity")
+	errHandshakeTimeout = timeoutError("quic: handshake timed out")
+
+	errConnClosed     = errors.New("quic: connection closed")
+	errEndpointClosed = errors.New("quic: endpoint closed")
+	errStreamClosed   = errors.New("quic: stream closed")
+	errStatelessReset = errors.New("quic: received stateless reset")
+)
@@ -0,0 +1,286 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import "time"
+
+// Frame types (RFC 9000, Section 19).
+const (
+	frameTypePadding                    = 0x00
+	frameTypePing                       = 0x01
+	frameTypeAck                        = 0x02
+	frameTypeAckECN                     = 0x03
+	frameTypeResetStream                = 0x04
+	frameTypeStopSending                = 0x05
+	frameTypeCrypto                     = 0x06
+	frameTypeNewToken                   = 0x07
+	frameTypeStreamBase                 = 0x08 // 0x08-0x0f
+	frameTypeMaxData                    = 0x10
+	frameTypeMaxStreamData              = 0x11
+	frameTypeMaxStreamsBidi             = 0x12
+	frameTypeMaxStreamsUni              = 0x13
+	frameTypeDataBlocked                = 0x14
+	frameTypeStreamDataBlocked          = 0x15
+	frameTypeStreamsBlockedBidi         = 0x16
+	frameTypeStreamsBlockedUni          = 0x17
+	frameTypeNewConnectionID            = 0x18
+	frameTypeRetireConnectionID         = 0x19
+	frameTypePathChallenge              = 0x1a
+	frameTypePathResponse               = 0x1b
+	frameTypeConnectionCloseTransport   = 0x1c
+	frameTypeConnectionCloseApplication = 0x1d
+	frameTypeHandshakeDone              = 0x1e
+)
+
+// Bits in the type of a STREAM frame.
+const (
+	streamFinBit = 0x01
+	streamLenBit = 0x02
+	streamOffBit = 0x04
+)
+
+// maxStreamsLimit is the maximum value of a MAX_STREAMS frame or
+// initial_max_streams transport parameter (RFC 9000, Section 4.6).
+const maxStreamsLimit = 1 << 60
+
+// frameAllowedInSpace reports whether a frame type may appear in packets
+// of the given number space (RFC 9000, Section 12.4, Table 3).
+func frameAllowedInSpace(ftype uint64, space numberSpace) bool {
+	if space == appDataSpace {
+		return true
+	}
+	switch ftype {
+	case frameTypePadding, frameTypePing, frameTypeAck, frameTypeAckECN,
+		frameTypeCrypto, frameTypeConnectionCloseTransport:
+		return true
+	}
+	return false
+}
+
+// ackDelayFromDuration converts a duration to an ACK frame's ack_delay field.
+func ackDelayFromDuration(d time.Duration) uint64 {
+	return uint64(max(d, 0).Microseconds()) >> ackDelayExponent
+}
+
+// durationFromAckDelay converts an ACK frame's ack_delay field to a duration,
+// given the peer's ack_delay_exponent.
+func durationFromAckDelay(v uint64, exp uint8) time.Duration {
+	if v > uint64(time.Duration(1<<62)/time.Microsecond)>>exp {
+		return 1 << 62
+	}
+	return time.Duration(v<<exp) * time.Microsecond
+}
+
+// appendAckFrame appends an ACK frame acknowledging the packet numbers in
+// seen to b, including as many ranges as will fit in size bytes.
+// It returns b unmodified if no ranges fit.
+func appendAckFrame(b []byte, seen rangeset, delay uint64, size int) []byte {
+	if len(seen) == 0 {
+		return b
+	}
+	last := seen[len(seen)-1]
+	largest := uint64(last.end - 1)
+	hdr := 1 + sizeVarint(largest) + sizeVarint(delay) + 1 + sizeVarint(uint64(last.size()-1))
+	if hdr > size {
+		return b
+	}
+	size -= hdr
+	count := 0
+	for i := len(seen) - 2; i >= 0 && count < 63; i-- {
+		gap := uint64(seen[i+1].start - seen[i].end - 1)
+		n := sizeVarint(gap) + sizeVarint(uint64(seen[i].size()-1))
+		if n > size {
+			break
+		}
+		size -= n
+		count++
+	}
+	b = append(b, frameTypeAck)
+	b = appendVarint(b, largest)
+	b = appendVarint(b, delay)
+	b = appendVarint(b, uint64(count))
+	b = appendVarint(b, uint64(last.size()-1))
+	for i := len(seen) - 2; i >= len(seen)-1-count; i-- {
+		b = appendVarint(b, uint64(seen[i+1].start-seen[i].end-1))
+		b = appendVarint(b, uint64(seen[i].size()-1))
+	}
+	return b
+}
+
+// consumeAckFrame parses an ACK frame at the start of b, which includes
+// the frame type. It calls f for each acknowledged range, in descending
+// order. It returns n < 0 if the frame is malformed.
+func consumeAckFrame(b []byte, f func(start, end int64)) (largest int64, delay uint64, n int) {
+	ftype := b[0]
+	n = 1
+	v, m := consumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, -1
+	}
+	n += m
+	largest = int64(v)
+	if delay, m = consumeVarint(b[n:]); m < 0 {
+		return 0, 0, -1
+	}
+	n += m
+	count, m := consumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, -1
+	}
+	n += m
+	rangeLen, m := consumeVarint(b[n:])
+	if m < 0 || rangeLen > uint64(largest) {
+		return 0, 0, -1
+	}
+	n += m
+	end := largest + 1
+	start := end - int64(rangeLen) - 1
+	f(start, end)
+	for ; count > 0; count-- {
+		gap, m := consumeVarint(b[n:])
+		if m < 0 {
+			return 0, 0, -1
+		}
+		n += m
+		rangeLen, m := consumeVarint(b[n:])
+		if m < 0 {
+			return 0, 0, -1
+		}
+		n += m
+		end = start - int64(gap) - 1
+		start = end - int64(rangeLen) - 1
+		if end <= 0 || start < 0 {
+			return 0, 0, -1
+		}
+		f(start, end)
+	}
+	if ftype == frameTypeAckECN {
+		for range 3 {
+			if _, m := consumeVarint(b[n:]); m < 0 {
+				return 0, 0, -1
+			}
+			n += m
+		}
+	}
+	return largest, delay, n
+}
+
+// consumeVarintFields parses a frame type followed by count variable-length
+// integer fields, storing the fields in v.
+func consumeVarintFields(b []byte, v ...*uint64) (n int) {
+	_, n = consumeVarint(b)
+	for _, p := range v {
+		var m int
+		if *p, m = consumeVarint(b[n:]); m < 0 {
+			return -1
+		}
+		n += m
+	}
+	return n
+}
+
+// streamFrameSize returns the size of a STREAM frame header.
+func streamFrameSize(id, off int64, size int) int {
+	n := 1 + sizeVarint(uint64(id)) + sizeVarint(uint64(size))
+	if off > 0 {
+		n += sizeVarint(uint64(off))
+	}
+	return n
+}
+
+// appendStreamFrame appends a STREAM frame header to b.
+// The frame data must be appended by the caller.
+func appendStreamFrame(b []byte, id, off int64, size int, fin bool) []byte {
+	ftype := byte(frameTypeStreamBase | streamLenBit)
+	if off > 0 {
+		ftype |= streamOffBit
+	}
+	if fin {
+		ftype |= streamFinBit
+	}
+	b = append(b, ftype)
+	b = appendVarint(b, uint64(id))
+	if off > 0 {
+		b = appendVarint(b, uint64(off))
+	}
+	return appendVarint(b, uint64(size))
+}
+
+// consumeStreamFrame parses a STREAM frame at the start of b.
+func consumeStreamFrame(b []byte) (id, off int64, fin bool, data []byte, n int) {
+	ftype := b[0]
+	n = 1
+	v, m := consumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, false, nil, -1
+	}
+	n += m
+	id = int64(v)
+	if ftype&streamOffBit != 0 {
+		if v, m = consumeVarint(b[n:]); m < 0 {
+			return 0, 0, false, nil, -1
+		}
+		n += m
+		off = int64(v)
+	}
+	if ftype&streamLenBit != 0 {
+		if data, m = consumeVarintBytes(b[n:]); m < 0 {
+			return 0, 0, false, nil, -1
+		}
+		n += m
+	} else {
+		data = b[n:]
+		n = len(b)
+	}
+	if uint64(off)+uint64(len(data)) > maxVarint {
+		return 0, 0, false, nil, -1
+	}
+	return id, off, ftype&streamFinBit != 0, data, n
+}
+
+// consumeCryptoFrame parses a CRYPTO frame at the start of b.
+func consumeCryptoFrame(b []byte) (off int64, data []byte, n int) {
+	var v uint64
+	if n = consumeVarintFields(b, &v); n < 0 {
+		return 0, nil, -1
+	}
+	data, m := consumeVarintBytes(b[n:])
+	if m < 0 || v+uint64(len(data)) > maxVarint {
+		return 0, nil, -1
+	}
+	return int64(v), data, n + m
+}
+
+// appendCryptoFrame appends a CRYPTO frame header to b.
+// The frame data must be appended by the caller.
+func appendCryptoFrame(b []byte, off int64, size int) []byte {
+	b = append(b, frameTypeCrypto)
+	b = appendVarint(b, uint64(off))
+	return appendVarint(b, uint64(size))
+}
+
+// appendConnectionCloseFrame appends a CONNECTION_CLOSE frame to b.
+// If the frame is an application close sent in an Initial or Handshake
+// packet, it is converted to a transport APPLICATION_ERROR with no reason
+// (RFC 9000, Section 10.2.3).
+func appendConnectionCloseFrame(b []byte, space numberSpace, app bool, code uint64, reason string) []byte {
+	if app && space != appDataSpace {
+		app = false
+		code = uint64(errApplicationError)
+		reason = ""
+	}
+	if len(reason) > 512 {
+		reason = reason[:512]
+	}
+	if app {
+		b = append(b, frameTypeConnectionCloseApplication)
+		b = appendVarint(b, code)
+	} else {
+		b = append(b, frameTypeConnectionCloseTransport)
+		b = appendVarint(b, code)
+		b = appendVarint(b, 0) // frame type
+	}
+	return appendVarintBytes(b, []byte(reason))
+}
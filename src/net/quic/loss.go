@@ -0,0 +1,291 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// A sentPacket is a packet that has been sent and not yet acknowledged
+// or declared lost.
+type sentPacket struct {
+	num          int64
+	time         time.Time
+	size         int
+	ackEliciting bool
+	inFlight     bool
+	frames       []sentFrame
+}
+
+// A sentFrame records a frame sent in a packet, with enough information
+// to handle the frame's acknowledgement or loss.
+type sentFrame struct {
+	ftype uint8
+	id    int64 // stream ID or connection ID sequence number
+	off   int64 // data offset, largest acknowledged, or limit value
+	n     int64 // data length
+	fin   bool
+}
+
+// A sendState is the state of a frame which must be delivered reliably
+// but does not carry data, such as MAX_DATA or RESET_STREAM.
+type sendState uint8
+
+const (
+	sendNone   sendState = iota // nothing to send, or acknowledged
+	sendUnsent                  // frame must be sent
+	sendSent                    // frame sent, awaiting acknowledgement
+)
+
+// lost updates the state on the loss of a frame.
+func (s *sendState) lost() {
+	if *s == sendSent {
+		*s = sendUnsent
+	}
+}
+
+// A space holds the state of one packet number space.
+type space struct {
+	rkeys, wkeys *packetKeys
+	discarded    bool
+
+	// Sending.
+	nextNum              int64
+	sent                 []*sentPacket // ordered by num, with nil entries for removed packets
+	largestAcked         int64         // -1 if no packets have been acknowledged
+	lossTime             time.Time
+	lastAckElicitingSent time.Time
+	ackElicitingInFlight int
+	probes               int // number of probe packets to send
+
+	// Receiving.
+	seen                rangeset // packet numbers received
+	ackFloor            int64    // packets below this number are discarded
+	largestSeenTime     time.Time
+	ackPending          bool // a packet has been received since the last ACK was sent
+	ackElicitingUnacked int  // ack-eliciting packets received since the last ACK was sent
+	ackDeadline         time.Time
+
+	// CRYPTO stream.
+	cryptoIn  recvBuf
+	cryptoOut sendBuf
+}
+
+// canSend reports whether packets may be sent in the space.
+func (s *space) canSend() bool {
+	return s.wkeys != nil && !s.discarded
+}
+
+// receivedPacket records the receipt of a packet.
+func (s *space) receivedPacket(now time.Time, num int64, ackEliciting bool, sp numberSpace) {
+	if num >= s.seen.max() {
+		s.largestSeenTime = now
+	}
+	s.seen.add(num, num+1)
+	// Keep the set of ranges bounded.
+	for len(s.seen) > 64 {
+		s.ackFloor = s.seen[0].end
+		s.seen = s.seen[1:]
+	}
+	s.ackPending = true
+	if !ackEliciting {
+		return
+	}
+	s.ackElicitingUnacked++
+	if s.ackDeadline.IsZero() {
+		if sp == appDataSpace {
+			s.ackDeadline = now.Add(maxAckDelay)
+		} else {
+			s.ackDeadline = now
+		}
+	}
+}
+
+// isDuplicate reports whether a packet number has already been received.
+func (s *space) isDuplicate(num int64) bool {
+	return num < s.ackFloor || s.seen.contains(num)
+}
+
+// ackDue reports whether an ACK-only packet must be sent.
+func (s *space) ackDue(now time.Time) bool {
+	if s.ackElicitingUnacked == 0 {
+		return false
+	}
+	return s.ackElicitingUnacked >= 2 || !now.Before(s.ackDeadline)
+}
+
+// ackSent records that an ACK frame has been sent.
+func (s *space) ackSent() {
+	s.ackPending = false
+	s.ackElicitingUnacked = 0
+	s.ackDeadline = time.Time{}
+}
+
+// largestSeen returns the largest packet number received, or -1.
+func (s *space) largestSeen() int64 {
+	if len(s.seen) == 0 {
+		return s.ackFloor - 1
+	}
+	return s.seen.max() - 1
+}
+
+// addSent records a sent packet.
+func (s *space) addSent(p *sentPacket) {
+	s.sent = append(s.sent, p)
+	if p.ackEliciting {
+		s.ackElicitingInFlight++
+		s.lastAckElicitingSent = p.time
+	}
+}
+
+// removeSent removes the packet at index i from s.sent.
+func (s *space) removeSent(i int) *sentPacket {
+	p := s.sent[i]
+	s.sent[i] = nil
+	if p.ackEliciting {
+		s.ackElicitingInFlight--
+	}
+	for len(s.sent) > 0 && s.sent[0] == nil {
+		s.sent = s.sent[1:]
+	}
+	return p
+}
+
+// sentIndex returns the index in s.sent of the first packet
+// with a number of at least num.
+func (s *space) sentIndex(num int64) int {
+	return sort.Search(len(s.sent), func(i int) bool {
+		for j := i; j < len(s.sent); j++ {
+			if p := s.sent[j]; p != nil {
+				return p.num >= num
+			}
+		}
+		return true
+	})
+}
+
+// rttState is the connection's round-trip time estimate
+// (RFC 9002, Section 5).
+type rttState struct {
+	latest   time.Duration
+	min      time.Duration
+	smoothed time.Duration
+	rttvar   time.Duration
+	sampled  bool
+}
+
+const initialRTT = 333 * time.Millisecond
+
+func (r *rttState) init() {
+	r.smoothed = initialRTT
+	r.rttvar = initialRTT / 2
+}
+
+// update updates the estimate with a new sample.
+func (r *rttState) update(latest, ackDelay, maxAckDelay time.Duration, handshakeConfirmed bool) {
+	r.latest = latest
+	if !r.sampled {
+		r.sampled = true
+		r.min = latest
+		r.smoothed = latest
+		r.rttvar = latest / 2
+		return
+	}
+	r.min = min(r.min, latest)
+	if handshakeConfirmed {
+		ackDelay = min(ackDelay, maxAckDelay)
+	}
+	adjusted := latest
+	if latest >= r.min+ackDelay {
+		adjusted -= ackDelay
+	}
+	diff := r.smoothed - adjusted
+	if diff < 0 {
+		diff = -diff
+	}
+	r.rttvar = (3*r.rttvar + diff) / 4
+	r.smoothed = (7*r.smoothed + adjusted) / 8
+}
+
+// pto returns the probe timeout, not including max_ack_delay
+// (RFC 9002, Section 6.2.1).
+func (r *rttState) pto() time.Duration {
+	return r.smoothed + max(4*r.rttvar, timerGranularity)
+}
+
+// lossDelay returns the time threshold for loss detection
+// (RFC 9002, Section 6.1.2).
+func (r *rttState) lossDelay() time.Duration {
+	d := max(r.latest, r.smoothed)
+	return max(d*9/8, timerGranularity)
+}
+
+// congestion implements the NewReno congestion controller
+// (RFC 9002, Section 7).
+type congestion struct {
+	cwnd          int
+	ssthresh      int
+	bytesInFlight int
+	recoveryStart time.Time
+}
+
+const (
+	initialWindow = 10 * maxDatagramSize
+	minimumWindow = 2 * maxDatagramSize
+)
+
+func (cc *congestion) init() {
+	cc.cwnd = initialWindow
+	cc.ssthresh = math.MaxInt
+}
+
+// canSend reports whether another full-sized packet may be sent.
+func (cc *congestion) canSend() bool {
+	return cc.bytesInFlight+maxDatagramSize <= cc.cwnd
+}
+
+func (cc *congestion) onSent(p *sentPacket) {
+	if p.inFlight {
+		cc.bytesInFlight += p.size
+	}
+}
+
+func (cc *congestion) onAcked(p *sentPacket) {
+	if !p.inFlight {
+		return
+	}
+	cc.bytesInFlight -= p.size
+	if !p.time.After(cc.recoveryStart) {
+		return
+	}
+	if cc.cwnd < cc.ssthresh {
+		cc.cwnd += p.size
+	} else {
+		cc.cwnd += maxDatagramSize * p.size / cc.cwnd
+	}
+}
+
+func (cc *congestion) onLost(now time.Time, p *sentPacket) {
+	if !p.inFlight {
+		return
+	}
+	cc.bytesInFlight -= p.size
+	if !p.time.After(cc.recoveryStart) {
+		return
+	}
+	cc.recoveryStart = now
+	cc.ssthresh = max(cc.cwnd/2, minimumWindow)
+	cc.cwnd = cc.ssthresh
+}
+
+// onDiscarded removes a packet from bytes in flight without
+// signaling congestion.
+func (cc *congestion) onDiscarded(p *sentPacket) {
+	if p.inFlight {
+		cc.bytesInFlight -= p.size
+	}
+}
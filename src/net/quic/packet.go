@@ -0,0 +1,244 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import "encoding/binary"
+
+// A packetType is a QUIC packet type.
+type packetType uint8
+
+const (
+	packetTypeInvalid packetType = iota
+	packetTypeInitial
+	packetType0RTT
+	packetTypeHandshake
+	packetTypeRetry
+	packetType1RTT
+	packetTypeVersionNegotiation
+)
+
+func (p packetType) String() string {
+	switch p {
+	case packetTypeInitial:
+		return "Initial"
+	case packetType0RTT:
+		return "0-RTT"
+	case packetTypeHandshake:
+		return "Handshake"
+	case packetTypeRetry:
+		return "Retry"
+	case packetType1RTT:
+		return "1-RTT"
+	case packetTypeVersionNegotiation:
+		return "VersionNegotiation"
+	}
+	return "Invalid"
+}
+
+// Bits in the first byte of a packet.
+const (
+	headerFormLong  = 0x80
+	fixedBit        = 0x40
+	longTypeMask    = 0x30
+	keyPhaseBit     = 0x04
+	pnumLenMask     = 0x03
+	longTypeInitial = 0x00 << 4
+	longType0RTT    = 0x01 << 4
+	longTypeHS      = 0x02 << 4
+	longTypeRetry   = 0x03 << 4
+)
+
+func isLongHeader(b byte) bool {
+	return b&headerFormLong != 0
+}
+
+// A numberSpace is a packet number space (RFC 9000, Section 12.3).
+type numberSpace int
+
+const (
+	initialSpace numberSpace = iota
+	handshakeSpace
+	appDataSpace
+	numberSpaceCount
+)
+
+func (s numberSpace) String() string {
+	switch s {
+	case initialSpace:
+		return "Initial"
+	case handshakeSpace:
+		return "Handshake"
+	case appDataSpace:
+		return "AppData"
+	}
+	return "Invalid"
+}
+
+// packetTypeForSpace returns the type of packets sent in a number space.
+func packetTypeForSpace(s numberSpace) packetType {
+	switch s {
+	case initialSpace:
+		return packetTypeInitial
+	case handshakeSpace:
+		return packetTypeHandshake
+	}
+	return packetType1RTT
+}
+
+// A longPacket is a parsed long header packet (RFC 9000, Section 17.2),
+// not including its protected fields.
+type longPacket struct {
+	ptype     packetType
+	version   uint32
+	dstConnID []byte
+	srcConnID []byte
+	token     []byte // Initial and Retry packets
+	versions  []byte // Version Negotiation packets
+	pnumOff   int    // offset of the packet number field
+	length    int    // total length of the packet, including the header
+}
+
+// parseLongHeader parses the unprotected fields of a long header packet
+// at the start of b. It returns ok == false if the packet is malformed.
+//
+// Version Negotiation packets and packets of unknown versions
+// are assumed to occupy all of b.
+func parseLongHeader(b []byte) (p longPacket, ok bool) {
+	if len(b) < 7 || !isLongHeader(b[0]) {
+		return p, false
+	}
+	p.version = binary.BigEndian.Uint32(b[1:5])
+	off := 5
+	var n int
+	if p.dstConnID, n = consumeUint8Bytes(b[off:]); n < 0 || len(p.dstConnID) > maxConnIDLen && p.version == quicVersion1 {
+		return p, false
+	}
+	off += n
+	if p.srcConnID, n = consumeUint8Bytes(b[off:]); n < 0 || len(p.srcConnID) > maxConnIDLen && p.version == quicVersion1 {
+		return p, false
+	}
+	off += n
+	if p.version == 0 {
+		p.ptype = packetTypeVersionNegotiation
+		p.versions = b[off:]
+		p.length = len(b)
+		return p, true
+	}
+	if p.version != quicVersion1 {
+		p.length = len(b)
+		return p, true
+	}
+	switch b[0] & longTypeMask {
+	case longTypeInitial:
+		p.ptype = packetTypeInitial
+		if p.token, n = consumeVarintBytes(b[off:]); n < 0 {
+			return p, false
+		}
+		off += n
+	case longType0RTT:
+		p.ptype = packetType0RTT
+	case longTypeHS:
+		p.ptype = packetTypeHandshake
+	case longTypeRetry:
+		p.ptype = packetTypeRetry
+		if len(b)-off < aeadOverhead {
+			return p, false
+		}
+		p.token = b[off : len(b)-aeadOverhead]
+		p.length = len(b)
+		return p, true
+	}
+	length, n := consumeVarint(b[off:])
+	if n < 0 || length > uint64(len(b)-off-n) {
+		return p, false
+	}
+	off += n
+	p.pnumOff = off
+	p.length = off + int(length)
+	return p, true
+}
+
+// dstConnIDForDatagram returns the destination connection ID of the first
+// packet in a datagram. Short header packets are assumed to carry a
+// connection ID of length connIDLen.
+func dstConnIDForDatagram(b []byte) (id []byte, ok bool) {
+	if len(b) < 1 {
+		return nil, false
+	}
+	if isLongHeader(b[0]) {
+		if len(b) < 6 {
+			return nil, false
+		}
+		id, n := consumeUint8Bytes(b[5:])
+		return id, n >= 0
+	}
+	if len(b) < 1+connIDLen {
+		return nil, false
+	}
+	return b[1 : 1+connIDLen], true
+}
+
+// appendLongHeader appends the header of an Initial or Handshake packet,
+// up to and including the packet number field, to b.
+// The length field is written as a two-byte placeholder to be filled in
+// by setLongHeaderLength. It returns the offset of the length field and
+// of the packet number field.
+func appendLongHeader(b []byte, ptype packetType, dstConnID, srcConnID, token []byte, pnum int64, pnumLen int) (_ []byte, lengthOff, pnumOff int) {
+	first := byte(headerFormLong | fixedBit | byte(pnumLen-1))
+	if ptype == packetTypeHandshake {
+		first |= longTypeHS
+	}
+	b = append(b, first)
+	b = binary.BigEndian.AppendUint32(b, quicVersion1)
+	b = appendUint8Bytes(b, dstConnID)
+	b = appendUint8Bytes(b, srcConnID)
+	if ptype == packetTypeInitial {
+		b = appendVarintBytes(b, token)
+	}
+	lengthOff = len(b)
+	b = append(b, 0x40, 0) // two-byte varint placeholder
+	pnumOff = len(b)
+	return appendPacketNumber(b, pnum, pnumLen), lengthOff, pnumOff
+}
+
+// setLongHeaderLength fills in the length field of a long header packet
+// starting at b[0], given the final size of the packet after protection.
+func setLongHeaderLength(b []byte, lengthOff, pnumOff, size int) {
+	binary.BigEndian.PutUint16(b[lengthOff:], uint16(size-pnumOff)|0x40<<8)
+}
+
+// appendShortHeader appends the header of a 1-RTT packet to b.
+// It returns the offset of the packet number field.
+func appendShortHeader(b []byte, dstConnID []byte, keyPhase bool, pnum int64, pnumLen int) (_ []byte, pnumOff int) {
+	first := byte(fixedBit | byte(pnumLen-1))
+	if keyPhase {
+		first |= keyPhaseBit
+	}
+	b = append(b, first)
+	b = append(b, dstConnID...)
+	pnumOff = len(b)
+	return appendPacketNumber(b, pnum, pnumLen), pnumOff
+}
+
+func appendPacketNumber(b []byte, pnum int64, pnumLen int) []byte {
+	for i := pnumLen - 1; i >= 0; i-- {
+		b = append(b, byte(pnum>>(8*i)))
+	}
+	return b
+}
+
+// appendVersionNegotiation appends a Version Negotiation packet
+// (RFC 9000, Section 17.2.1) to b, in response to a packet with the
+// given connection IDs.
+func appendVersionNegotiation(b, dstConnID, srcConnID []byte, versions ...uint32) []byte {
+	b = append(b, headerFormLong|fixedBit)
+	b = binary.BigEndian.AppendUint32(b, 0)
+	b = appendUint8Bytes(b, dstConnID)
+	b = appendUint8Bytes(b, srcConnID)
+	for _, v := range versions {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b
+}
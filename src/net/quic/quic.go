@@ -0,0 +1,155 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package quic implements the QUIC transport protocol,
+// as defined in RFC 9000, RFC 9001 and RFC 9002.
+//
+// An [Endpoint] sends and receives QUIC packets on a UDP socket.
+// It may accept connections from peers ([Endpoint.Accept]),
+// create connections to peers ([Endpoint.Dial]), or both.
+// Each [Conn] carries any number of concurrent, reliable,
+// flow-controlled byte streams ([Stream]).
+//
+// The TLS handshake is performed by package [crypto/tls],
+// using its [tls.QUICConn] type.
+//
+// Only QUIC version 1 is supported. 0-RTT data, connection migration
+// and path MTU discovery are not implemented: every datagram sent is at
+// most 1200 bytes, the smallest size every QUIC path must support.
+package quic
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+const (
+	// quicVersion1 is the QUIC version number (RFC 9000, Section 15).
+	quicVersion1 = 1
+
+	// maxDatagramSize is the largest UDP payload sent by this implementation.
+	// RFC 9000, Section 14.
+	maxDatagramSize = 1200
+
+	// connIDLen is the length of the connection IDs chosen by this
+	// implementation. Connection IDs chosen by a peer may be up to
+	// maxConnIDLen bytes long.
+	connIDLen    = 8
+	maxConnIDLen = 20
+
+	// ackDelayExponent and maxAckDelay are the values of the
+	// ack_delay_exponent and max_ack_delay transport parameters sent
+	// to peers.
+	ackDelayExponent = 3
+	maxAckDelay      = 25 * time.Millisecond
+
+	// timerGranularity is the system timer granularity (RFC 9002, Section 6.1.2).
+	timerGranularity = 1 * time.Millisecond
+)
+
+// A Config structure is used to configure a QUIC endpoint or connection.
+// A Config must not be modified after it has been passed to a function
+// in this package. A Config may be reused; the quic package will not
+// modify it.
+type Config struct {
+	// TLSConfig is the TLS configuration used for connections.
+	// It must be non-nil, and it must set NextProtos,
+	// since QUIC requires ALPN protocol negotiation.
+	// Servers must also configure a certificate.
+	TLSConfig *tls.Config
+
+	// MaxBidiRemoteStreams limits the number of simultaneous
+	// bidirectional streams a peer may open.
+	// If zero, the default value of 100 is used.
+	// If negative, the limit is zero.
+	MaxBidiRemoteStreams int64
+
+	// MaxUniRemoteStreams limits the number of simultaneous
+	// unidirectional streams a peer may open.
+	// If zero, the default value of 100 is used.
+	// If negative, the limit is zero.
+	MaxUniRemoteStreams int64
+
+	// MaxStreamReadBufferSize is the maximum amount of data sent by
+	// the peer that a stream will buffer for reading.
+	// If zero, the default value of 1MiB is used.
+	// If negative, the limit is zero.
+	MaxStreamReadBufferSize int64
+
+	// MaxStreamWriteBufferSize is the maximum amount of data a stream
+	// will buffer for sending to the peer.
+	// If zero, the default value of 1MiB is used.
+	// If negative, the limit is zero.
+	MaxStreamWriteBufferSize int64
+
+	// MaxConnReadBufferSize is the maximum amount of data sent by the
+	// peer that a connection will buffer for reading, across all streams.
+	// If zero, the default value of 1MiB is used.
+	// If negative, the limit is zero.
+	MaxConnReadBufferSize int64
+
+	// HandshakeTimeout is the maximum time in which a connection
+	// handshake must complete.
+	// If zero, the default of 10 seconds is used.
+	// If negative, there is no handshake timeout.
+	HandshakeTimeout time.Duration
+
+	// MaxIdleTimeout is the maximum time after which an idle connection
+	// will be closed.
+	// If zero, the default of 30 seconds is used.
+	// If negative, idle connections are never closed.
+	//
+	// The idle timeout of a connection is the smaller of the maximum
+	// idle timeouts of the two endpoints.
+	MaxIdleTimeout time.Duration
+
+	// KeepAlivePeriod is the time after which a packet will be sent to
+	// keep an idle connection alive.
+	// If zero or negative, keep-alive packets are not sent.
+	// The keep-alive period used is the smaller of KeepAlivePeriod and
+	// half the connection's idle timeout.
+	KeepAlivePeriod time.Duration
+}
+
+func configDefault[T ~int64](v, def T) T {
+	switch {
+	case v == 0:
+		return def
+	case v < 0:
+		return 0
+	}
+	return v
+}
+
+func (c *Config) maxBidiRemoteStreams() int64 {
+	return configDefault(c.MaxBidiRemoteStreams, 100)
+}
+
+func (c *Config) maxUniRemoteStreams() int64 {
+	return configDefault(c.MaxUniRemoteStreams, 100)
+}
+
+func (c *Config) maxStreamReadBufferSize() int64 {
+	return configDefault(c.MaxStreamReadBufferSize, 1<<20)
+}
+
+func (c *Config) maxStreamWriteBufferSize() int64 {
+	return configDefault(c.MaxStreamWriteBufferSize, 1<<20)
+}
+
+func (c *Config) maxConnReadBufferSize() int64 {
+	return configDefault(c.MaxConnReadBufferSize, 1<<20)
+}
+
+func (c *Config) handshakeTimeout() time.Duration {
+	return configDefault(c.HandshakeTimeout, 10*time.Second)
+}
+
+func (c *Config) maxIdleTimeout() time.Duration {
+	return configDefault(c.MaxIdleTimeout, 30*time.Second)
+}
+
+func (c *Config) keepAlivePeriod() time.Duration {
+	return max(c.KeepAlivePeriod, 0)
+}
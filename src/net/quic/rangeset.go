@@ -0,0 +1,95 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"slices"
+	"sort"
+)
+
+// A span is the range of integers [start, end).
+type span struct {
+	start, end int64
+}
+
+func (s span) size() int64 { return s.end - s.start }
+
+// A rangeset is a set of int64s, stored as an ordered list of
+// non-overlapping, non-adjacent, non-empty spans.
+type rangeset []span
+
+// add adds [start, end) to the set.
+func (s *rangeset) add(start, end int64) {
+	if start >= end {
+		return
+	}
+	r := *s
+	i := sort.Search(len(r), func(i int) bool { return r[i].end >= start })
+	j := i
+	for j < len(r) && r[j].start <= end {
+		start = min(start, r[j].start)
+		end = max(end, r[j].end)
+		j++
+	}
+	*s = slices.Replace(r, i, j, span{start, end})
+}
+
+// sub removes [start, end) from the set.
+func (s *rangeset) sub(start, end int64) {
+	if start >= end {
+		return
+	}
+	r := *s
+	i := sort.Search(len(r), func(i int) bool { return r[i].end > start })
+	j := i
+	for j < len(r) && r[j].start < end {
+		j++
+	}
+	if i == j {
+		return
+	}
+	var repl [2]span
+	n := 0
+	if r[i].start < start {
+		repl[n] = span{r[i].start, start}
+		n++
+	}
+	if r[j-1].end > end {
+		repl[n] = span{end, r[j-1].end}
+		n++
+	}
+	*s = slices.Replace(r, i, j, repl[:n]...)
+}
+
+// contains reports whether v is in the set.
+func (s rangeset) contains(v int64) bool {
+	i := sort.Search(len(s), func(i int) bool { return s[i].end > v })
+	return i < len(s) && s[i].start <= v
+}
+
+// min returns the smallest value in the set, or 0 if the set is empty.
+func (s rangeset) min() int64 {
+	if len(s) == 0 {
+		return 0
+	}
+	return s[0].start
+}
+
+// max returns the largest value in the set plus one,
+// or 0 if the set is empty.
+func (s rangeset) max() int64 {
+	if len(s) == 0 {
+		return 0
+	}
+	return s[len(s)-1].end
+}
+
+// size returns the number of values in the set.
+func (s rangeset) size() (n int64) {
+	for _, r := range s {
+		n += r.size()
+	}
+	return n
+}
@@ -0,0 +1,728 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// Stream directions, as encoded in the second-least significant bit
+// of a stream ID (RFC 9000, Section 2.1).
+const (
+	bidiStream = 0
+	uniStream  = 1
+)
+
+func streamIDFor(num int64, initiator connSide, dir int) int64 {
+	return num<<2 | int64(dir)<<1 | int64(initiator)
+}
+
+func streamIDInitiator(id int64) connSide { return connSide(id & 1) }
+func streamIDDir(id int64) int            { return int(id>>1) & 1 }
+func streamIDNum(id int64) int64          { return id >> 2 }
+
+// A Stream is an ordered byte stream.
+//
+// Streams may be bidirectional, read-only, or write-only.
+// Methods of Stream are safe for concurrent use by multiple goroutines,
+// although concurrent calls to Read (or to Write) are not ordered.
+type Stream struct {
+	conn *Conn
+	id   int64
+
+	// Everything below is guarded by conn.mu.
+
+	// Receive side.
+	in               recvBuf
+	inWin            int64 // receive window size
+	inMax            int64 // flow control limit sent to the peer
+	inHigh           int64 // highest offset received
+	inSize           int64 // final size, or -1 if not yet known
+	inReset          bool  // peer reset the stream
+	inResetCode      uint64
+	inClosed         bool // reads have been stopped locally
+	inMaxState       sendState
+	stopSendingState sendState
+	stopSendingCode  uint64
+	inSig            signal
+	readDeadline     deadline
+
+	// Send side.
+	out           sendBuf
+	outMax        int64 // flow control limit set by the peer
+	outHigh       int64 // highest offset sent
+	outFin        bool  // no more data will be written
+	finSent       bool
+	finAcked      bool
+	outReset      bool // stream has been reset locally
+	resetState    sendState
+	resetAcked    bool
+	resetCode     uint64
+	outStopped    bool // peer sent STOP_SENDING
+	outStopCode   uint64
+	outBufMax     int64 // maximum amount of data to buffer
+	outSig        signal
+	writeDeadline deadline
+
+	queued bool // in conn.streams.queue
+	done   bool // stream is complete and removed from the conn
+}
+
+func newStream(c *Conn, id int64) *Stream {
+	s := &Stream{
+		conn:          c,
+		id:            id,
+		inSize:        -1,
+		inSig:         newSignal(),
+		outSig:        newSignal(),
+		readDeadline:  makeDeadline(),
+		writeDeadline: makeDeadline(),
+	}
+	local := streamIDInitiator(id) == c.side
+	uni := streamIDDir(id) == uniStream
+	if local && uni {
+		s.inSize = 0 // no receive side
+	} else {
+		s.inWin = c.config.maxStreamReadBufferSize()
+		s.inMax = s.inWin
+	}
+	if !local && uni {
+		s.outFin, s.finSent, s.finAcked = true, true, true // no send side
+	} else {
+		s.outBufMax = max(c.config.maxStreamWriteBufferSize(), 1)
+		p := &c.peerParams
+		switch {
+		case uni:
+			s.outMax = p.initialMaxStreamDataUni
+		case local:
+			s.outMax = p.initialMaxStreamDataBidiRemote
+		default:
+			s.outMax = p.initialMaxStreamDataBidiLocal
+		}
+	}
+	return s
+}
+
+// ID returns the QUIC stream ID of s.
+func (s *Stream) ID() int64 {
+	return s.id
+}
+
+// IsReadOnly reports whether s is a read-only stream.
+func (s *Stream) IsReadOnly() bool {
+	return streamIDDir(s.id) == uniStream && streamIDInitiator(s.id) != s.conn.side
+}
+
+// IsWriteOnly reports whether s is a write-only stream.
+func (s *Stream) IsWriteOnly() bool {
+	return streamIDDir(s.id) == uniStream && streamIDInitiator(s.id) == s.conn.side
+}
+
+// Read reads data from the stream.
+//
+// Read returns io.EOF when the peer has closed its side of the stream
+// and all data has been read. If the peer resets the stream, Read
+// returns a [StreamErrorCode] with the peer's error code.
+// If the read deadline is exceeded, Read returns an error wrapping
+// [os.ErrDeadlineExceeded].
+func (s *Stream) Read(b []byte) (int, error) {
+	if s.IsWriteOnly() {
+		return 0, errors.New("quic: read from write-only stream")
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		switch {
+		case s.inReset:
+			return 0, StreamErrorCode(s.inResetCode)
+		case s.inClosed:
+			return 0, errStreamClosed
+		}
+		if s.in.readable() > 0 {
+			if len(b) == 0 {
+				return 0, nil
+			}
+			n := s.in.read(b)
+			s.consumed(int64(n))
+			return n, nil
+		}
+		if s.inSize >= 0 && s.in.start == s.inSize {
+			return 0, io.EOF
+		}
+		if c.closeErr != nil {
+			return 0, c.closeErr
+		}
+		if err := c.wait(nil, s.inSig, s.readDeadline.wait()); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// consumed updates flow control after n bytes have been read or
+// discarded by the application.
+func (s *Stream) consumed(n int64) {
+	c := s.conn
+	c.flowConsumed(n)
+	if s.inSize < 0 && s.inMax-s.in.start < s.inWin/2 {
+		s.inMax = s.in.start + s.inWin
+		s.inMaxState = sendUnsent
+		c.queueStream(s)
+	}
+	s.checkDone()
+}
+
+// Write writes data to the stream.
+//
+// Write blocks until all data has been buffered for sending.
+// If the peer asks the stream to stop sending, Write returns a
+// [StreamErrorCode] with the peer's error code.
+// If the write deadline is exceeded, Write returns an error wrapping
+// [os.ErrDeadlineExceeded].
+func (s *Stream) Write(b []byte) (n int, err error) {
+	if s.IsReadOnly() {
+		return 0, errors.New("quic: write to read-only stream")
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		switch {
+		case s.outStopped:
+			return n, StreamErrorCode(s.outStopCode)
+		case s.outReset || s.outFin:
+			return n, errStreamClosed
+		case c.closeErr != nil:
+			return n, c.closeErr
+		}
+		if len(b) == 0 {
+			return n, nil
+		}
+		if avail := s.outBufMax - int64(len(s.out.buf)); avail > 0 {
+			m := int(min(avail, int64(len(b))))
+			s.out.write(b[:m])
+			b = b[m:]
+			n += m
+			c.queueStream(s)
+			continue
+		}
+		if err := c.wait(nil, s.outSig, s.writeDeadline.wait()); err != nil {
+			return n, err
+		}
+	}
+}
+
+// Close closes the stream.
+// It is equivalent to calling CloseRead and CloseWrite.
+// Close does not wait for the peer to acknowledge data written to the stream.
+func (s *Stream) Close() error {
+	if !s.IsWriteOnly() {
+		s.CloseRead()
+	}
+	if !s.IsReadOnly() {
+		s.CloseWrite()
+	}
+	return nil
+}
+
+// CloseRead aborts reads on the stream.
+// Any buffered data is discarded, and the peer is asked to stop sending
+// with an error code of 0. It is equivalent to StopSending(0).
+func (s *Stream) CloseRead() {
+	s.StopSending(0)
+}
+
+// StopSending aborts reads on the stream, discarding any buffered data.
+// If the peer has not yet finished sending, it is asked to stop
+// sending with the given application protocol error code.
+func (s *Stream) StopSending(code uint64) {
+	if s.IsWriteOnly() {
+		return
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s.inClosed {
+		return
+	}
+	s.inClosed = true
+	if !s.inReset && (s.inSize < 0 || s.in.readable() < s.inSize-s.in.start) {
+		s.stopSendingState = sendUnsent
+		s.stopSendingCode = code
+		c.queueStream(s)
+	}
+	s.discardInput()
+	s.inSig.notify()
+}
+
+// discardInput discards data received but not read after reads are stopped.
+func (s *Stream) discardInput() {
+	if n := s.in.readable(); n > 0 {
+		s.in.discard(n)
+		s.consumed(n)
+	}
+}
+
+// CloseWrite closes the write side of the stream.
+// Data written to the stream is sent to the peer, followed by
+// an end-of-stream indication.
+func (s *Stream) CloseWrite() {
+	if s.IsReadOnly() {
+		return
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s.outFin || s.outReset {
+		return
+	}
+	s.outFin = true
+	c.queueStream(s)
+	s.outSig.notify()
+}
+
+// Reset aborts writes on the stream and notifies the peer
+// that the stream was terminated abruptly with the given
+// application protocol error code.
+// Any data written to the stream and not yet sent is discarded.
+// Reset has no effect if all data has already been sent
+// and acknowledged.
+func (s *Stream) Reset(code uint64) {
+	if s.IsReadOnly() {
+		return
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.resetLocked(code)
+}
+
+func (s *Stream) resetLocked(code uint64) {
+	if s.outReset || s.finAcked {
+		return
+	}
+	s.outReset = true
+	s.resetCode = code
+	s.resetState = sendUnsent
+	s.conn.queueStream(s)
+	s.outSig.notify()
+}
+
+// SetDeadline sets the read and write deadlines for the stream.
+// It is equivalent to calling both SetReadDeadline and SetWriteDeadline.
+func (s *Stream) SetDeadline(t time.Time) error {
+	s.readDeadline.set(t)
+	s.writeDeadline.set(t)
+	return nil
+}
+
+// SetReadDeadline sets the deadline for future Read calls and
+// any currently-blocked Read call. A zero value for t means
+// Read will not time out.
+func (s *Stream) SetReadDeadline(t time.Time) error {
+	s.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for future Write calls and
+// any currently-blocked Write call. A zero value for t means
+// Write will not time out.
+func (s *Stream) SetWriteDeadline(t time.Time) error {
+	s.writeDeadline.set(t)
+	return nil
+}
+
+// hasFramesToSend reports whether the stream has frames waiting to be sent,
+// ignoring flow control.
+func (s *Stream) hasFramesToSend() bool {
+	if s.inMaxState == sendUnsent || s.stopSendingState == sendUnsent || s.resetState == sendUnsent {
+		return true
+	}
+	if s.outReset {
+		return false
+	}
+	return s.out.hasUnsent() || (s.outFin && !s.finSent)
+}
+
+// handleData handles data received in a STREAM frame.
+func (s *Stream) handleData(off int64, data []byte, fin bool) error {
+	c := s.conn
+	end := off + int64(len(data))
+	if s.inSize >= 0 && (end > s.inSize || fin && end != s.inSize) {
+		return localTransportError{code: errFinalSize, reason: "stream data past final size"}
+	}
+	if fin && end < s.inHigh {
+		return localTransportError{code: errFinalSize, reason: "final size less than data received"}
+	}
+	if end > s.inMax {
+		return localTransportError{code: errFlowControl, reason: "stream flow control exceeded"}
+	}
+	if end > s.inHigh {
+		if err := c.flowReceived(end - s.inHigh); err != nil {
+			return err
+		}
+		s.inHigh = end
+	}
+	if fin {
+		s.inSize = end
+	}
+	if s.inReset {
+		return nil
+	}
+	s.in.write(off, data)
+	if s.inClosed {
+		s.discardInput()
+	}
+	s.inSig.notify()
+	s.checkDone()
+	return nil
+}
+
+// handleReset handles a RESET_STREAM frame.
+func (s *Stream) handleReset(code uint64, finalSize int64) error {
+	c := s.conn
+	if s.inSize >= 0 && finalSize != s.inSize || finalSize < s.inHigh {
+		return localTransportError{code: errFinalSize, reason: "invalid final size in RESET_STREAM"}
+	}
+	if finalSize > s.inMax {
+		return localTransportError{code: errFlowControl, reason: "stream flow control exceeded"}
+	}
+	if err := c.flowReceived(finalSize - s.inHigh); err != nil {
+		return err
+	}
+	s.inHigh = finalSize
+	s.inSize = finalSize
+	if s.inReset {
+		return nil
+	}
+	s.inReset = true
+	s.inResetCode = code
+	s.stopSendingState = sendNone
+	s.inMaxState = sendNone
+	unread := finalSize - s.in.start
+	s.in.discardTo(finalSize)
+	c.flowConsumed(unread)
+	s.inSig.notify()
+	s.checkDone()
+	return nil
+}
+
+// handleStopSending handles a STOP_SENDING frame.
+func (s *Stream) handleStopSending(code uint64) {
+	if !s.outStopped {
+		s.outStopped = true
+		s.outStopCode = code
+	}
+	s.resetLocked(code)
+	s.outSig.notify()
+}
+
+// handleMaxStreamData handles a MAX_STREAM_DATA frame.
+func (s *Stream) handleMaxStreamData(v int64) {
+	if v > s.outMax {
+		s.outMax = v
+		s.conn.queueStream(s)
+	}
+}
+
+// checkDone removes the stream from the connection when both
+// sides are complete.
+func (s *Stream) checkDone() {
+	if s.done {
+		return
+	}
+	inDone := s.inReset || s.inSize >= 0 && s.in.start == s.inSize
+	outDone := s.resetAcked || (s.outReset && s.finAcked) || (s.finAcked && s.out.allAcked())
+	if inDone && outDone {
+		s.done = true
+		s.conn.streamDone(s)
+	}
+}
+
+// A streamsState is the state of a connection's streams.
+type streamsState struct {
+	m map[int64]*Stream
+
+	// Locally-initiated streams, by direction.
+	localOpened [2]int64 // number of streams opened
+	peerMax     [2]int64 // stream limit set by the peer
+
+	// Remotely-initiated streams, by direction.
+	remoteOpened   [2]int64 // number of streams opened
+	remoteDone     [2]int64 // number of streams completed
+	localMax       [2]int64 // stream limit sent to the peer
+	localMaxState  [2]sendState
+	localMaxConfig [2]int64
+
+	accept    []*Stream
+	acceptSig signal
+	openSig   [2]signal // by stream direction
+
+	queue []*Stream // streams with frames to send
+}
+
+// AcceptStream waits for and returns the next stream created by the peer.
+func (c *Conn) AcceptStream(ctx context.Context) (*Stream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		if len(c.streams.accept) > 0 {
+			s := c.streams.accept[0]
+			c.streams.accept = c.streams.accept[1:]
+			if len(c.streams.accept) > 0 {
+				// Wake the next waiting caller, if any.
+				c.streams.acceptSig.notify()
+			}
+			return s, nil
+		}
+		if c.closeErr != nil {
+			return nil, c.closeErr
+		}
+		if err := c.wait(ctx, c.streams.acceptSig, nil); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// NewStream creates a bidirectional stream.
+// If the peer's maximum stream limit for the connection has been reached,
+// NewStream blocks until the peer raises the limit.
+func (c *Conn) NewStream(ctx context.Context) (*Stream, error) {
+	return c.newLocalStream(ctx, bidiStream)
+}
+
+// NewSendOnlyStream creates a unidirectional, write-only stream.
+// If the peer's maximum stream limit for the connection has been reached,
+// NewSendOnlyStream blocks until the peer raises the limit.
+func (c *Conn) NewSendOnlyStream(ctx context.Context) (*Stream, error) {
+	return c.newLocalStream(ctx, uniStream)
+}
+
+func (c *Conn) newLocalStream(ctx context.Context, dir int) (*Stream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		if c.closeErr != nil {
+			return nil, c.closeErr
+		}
+		if num := c.streams.localOpened[dir]; num < c.streams.peerMax[dir] {
+			c.streams.localOpened[dir]++
+			if num+1 < c.streams.peerMax[dir] {
+				// Wake the next waiting caller, if any.
+				c.streams.openSig[dir].notify()
+			}
+			s := newStream(c, streamIDFor(num, c.side, dir))
+			c.streams.m[s.id] = s
+			return s, nil
+		}
+		if err := c.wait(ctx, c.streams.openSig[dir], nil); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// streamForFrame returns the stream with the given ID for a frame received
+// from the peer, creating it (and any lower-numbered streams of the same
+// type) if it is a new remotely-initiated stream. It returns nil if the
+// stream has already completed.
+//
+// sending reports whether the frame concerns the sending part of the stream
+// from the peer's perspective (STREAM, RESET_STREAM, STREAM_DATA_BLOCKED).
+func (c *Conn) streamForFrame(id int64, sending bool) (*Stream, error) {
+	dir := streamIDDir(id)
+	num := streamIDNum(id)
+	if streamIDInitiator(id) == c.side {
+		if dir == uniStream && sending {
+			return nil, localTransportError{code: errStreamState, reason: "peer sent on send-only stream"}
+		}
+		if num >= c.streams.localOpened[dir] {
+			return nil, localTransportError{code: errStreamState, reason: "frame for stream not yet opened"}
+		}
+		return c.streams.m[id], nil
+	}
+	if dir == uniStream && !sending {
+		return nil, localTransportError{code: errStreamState, reason: "frame for receive-only stream"}
+	}
+	if num >= c.streams.localMax[dir] {
+		return nil, localTransportError{code: errStreamLimit, reason: "stream limit exceeded"}
+	}
+	for c.streams.remoteOpened[dir] <= num {
+		s := newStream(c, streamIDFor(c.streams.remoteOpened[dir], c.side^1, dir))
+		c.streams.remoteOpened[dir]++
+		c.streams.m[s.id] = s
+		c.streams.accept = append(c.streams.accept, s)
+		c.streams.acceptSig.notify()
+	}
+	return c.streams.m[id], nil
+}
+
+// streamDone removes a completed stream from the connection.
+func (c *Conn) streamDone(s *Stream) {
+	delete(c.streams.m, s.id)
+	if streamIDInitiator(s.id) == c.side {
+		return
+	}
+	dir := streamIDDir(s.id)
+	c.streams.remoteDone[dir]++
+	if v := c.streams.remoteDone[dir] + c.streams.localMaxConfig[dir]; v > c.streams.localMax[dir] && v <= maxStreamsLimit {
+		c.streams.localMax[dir] = v
+		c.streams.localMaxState[dir] = sendUnsent
+		c.wake()
+	}
+}
+
+// queueStream adds a stream to the queue of streams with frames to send.
+func (c *Conn) queueStream(s *Stream) {
+	if !s.queued && !s.done {
+		s.queued = true
+		c.streams.queue = append(c.streams.queue, s)
+	}
+	c.wake()
+}
+
+// appendStreamFrames appends frames for stream s to the packet being built.
+// It reports whether the stream still has frames to send afterwards.
+func (c *Conn) appendStreamFrames(w *packetWriter, s *Stream) (more bool) {
+	if s.stopSendingState == sendUnsent {
+		b := appendVarint(nil, frameTypeStopSending)
+		b = appendVarint(b, uint64(s.id))
+		b = appendVarint(b, s.stopSendingCode)
+		if !w.appendFrame(b, sentFrame{ftype: frameTypeStopSending, id: s.id}) {
+			return true
+		}
+		s.stopSendingState = sendSent
+	}
+	if s.inMaxState == sendUnsent {
+		b := appendVarint(nil, frameTypeMaxStreamData)
+		b = appendVarint(b, uint64(s.id))
+		b = appendVarint(b, uint64(s.inMax))
+		if !w.appendFrame(b, sentFrame{ftype: frameTypeMaxStreamData, id: s.id, off: s.inMax}) {
+			return true
+		}
+		s.inMaxState = sendSent
+	}
+	if s.resetState == sendUnsent {
+		b := appendVarint(nil, frameTypeResetStream)
+		b = appendVarint(b, uint64(s.id))
+		b = appendVarint(b, s.resetCode)
+		b = appendVarint(b, uint64(s.outHigh))
+		if !w.appendFrame(b, sentFrame{ftype: frameTypeResetStream, id: s.id}) {
+			return true
+		}
+		s.resetState = sendSent
+	}
+	if s.outReset {
+		return false
+	}
+	for {
+		avail := w.avail()
+		if avail <= 0 {
+			return s.out.hasUnsent() || s.outFin && !s.finSent
+		}
+		off, data, ok := s.out.next(int64(avail))
+		if !ok {
+			if !s.outFin || s.finSent {
+				return false
+			}
+			// Send a FIN with no data.
+			off = s.out.end()
+			data = nil
+		}
+		// Limit new data to flow control windows.
+		limit := min(s.outMax, s.outHigh+c.flowAvail())
+		if end := off + int64(len(data)); end > limit {
+			if off >= limit && len(data) > 0 {
+				return true // blocked on flow control
+			}
+			data = data[:max(limit-off, 0)]
+		}
+		hdr := streamFrameSize(s.id, off, len(data))
+		if hdr+1 > avail && len(data) > 0 || hdr > avail {
+			return true
+		}
+		if hdr+len(data) > avail {
+			data = data[:avail-hdr]
+		}
+		end := off + int64(len(data))
+		fin := s.outFin && end == s.out.end()
+		b := appendStreamFrame(nil, s.id, off, len(data), fin)
+		b = append(b, data...)
+		w.appendFrame(b, sentFrame{ftype: frameTypeStreamBase, id: s.id, off: off, n: int64(len(data)), fin: fin})
+		s.out.markSent(off, int64(len(data)))
+		if fin {
+			s.finSent = true
+		}
+		if end > s.outHigh {
+			c.flowSent(end - s.outHigh)
+			s.outHigh = end
+		}
+		if !s.out.hasUnsent() && (!s.outFin || s.finSent) {
+			return false
+		}
+	}
+}
+
+// handleStreamAck handles the acknowledgement of a stream-related frame.
+func (c *Conn) handleStreamAck(f sentFrame) {
+	s := c.streams.m[f.id]
+	if s == nil {
+		return
+	}
+	switch f.ftype {
+	case frameTypeStreamBase:
+		if s.outReset {
+			return
+		}
+		s.out.markAcked(f.off, f.n)
+		if f.fin {
+			s.finAcked = true
+		}
+		s.outSig.notify()
+	case frameTypeResetStream:
+		s.resetAcked = true
+		s.resetState = sendNone
+	case frameTypeStopSending:
+		s.stopSendingState = sendNone
+	case frameTypeMaxStreamData:
+		if f.off == s.inMax {
+			s.inMaxState = sendNone
+		}
+	}
+	s.checkDone()
+}
+
+// handleStreamLoss handles the loss of a stream-related frame.
+func (c *Conn) handleStreamLoss(f sentFrame) {
+	s := c.streams.m[f.id]
+	if s == nil {
+		return
+	}
+	switch f.ftype {
+	case frameTypeStreamBase:
+		if s.outReset {
+			return
+		}
+		s.out.markLost(f.off, f.n)
+		if f.fin && !s.finAcked {
+			s.finSent = false
+		}
+	case frameTypeResetStream:
+		s.resetState.lost()
+	case frameTypeStopSending:
+		if !s.inReset && s.inSize < 0 {
+			s.stopSendingState.lost()
+		}
+	case frameTypeMaxStreamData:
+		if f.off == s.inMax && s.inSize < 0 {
+			s.inMaxState.lost()
+		}
+	}
+	if s.hasFramesToSend() {
+		c.queueStream(s)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"time"
+)
+
+// Transport parameter IDs (RFC 9000, Section 18.2).
+const (
+	paramOriginalDestinationConnectionID = 0x00
+	paramMaxIdleTimeout                  = 0x01
+	paramStatelessResetToken             = 0x02
+	paramMaxUDPPayloadSize               = 0x03
+	paramInitialMaxData                  = 0x04
+	paramInitialMaxStreamDataBidiLocal   = 0x05
+	paramInitialMaxStreamDataBidiRemote  = 0x06
+	paramInitialMaxStreamDataUni         = 0x07
+	paramInitialMaxStreamsBidi           = 0x08
+	paramInitialMaxStreamsUni            = 0x09
+	paramAckDelayExponent                = 0x0a
+	paramMaxAckDelay                     = 0x0b
+	paramDisableActiveMigration          = 0x0c
+	paramPreferredAddress                = 0x0d
+	paramActiveConnectionIDLimit         = 0x0e
+	paramInitialSourceConnectionID       = 0x0f
+	paramRetrySourceConnectionID         = 0x10
+)
+
+// transportParameters are the QUIC transport parameters
+// (RFC 9000, Section 18).
+type transportParameters struct {
+	originalDstConnID              []byte
+	maxIdleTimeout                 time.Duration
+	statelessResetToken            []byte
+	maxUDPPayloadSize              int64
+	initialMaxData                 int64
+	initialMaxStreamDataBidiLocal  int64
+	initialMaxStreamDataBidiRemote int64
+	initialMaxStreamDataUni        int64
+	initialMaxStreamsBidi          int64
+	initialMaxStreamsUni           int64
+	ackDelayExponent               uint8
+	maxAckDelay                    time.Duration
+	disableActiveMigration         bool
+	preferredAddress               []byte
+	activeConnIDLimit              int64
+	initialSrcConnID               []byte
+	retrySrcConnID                 []byte
+}
+
+// defaultTransportParameters returns the parameters in effect when
+// none are sent.
+func defaultTransportParameters() transportParameters {
+	return transportParameters{
+		maxUDPPayloadSize: 65527,
+		ackDelayExponent:  3,
+		maxAckDelay:       25 * time.Millisecond,
+		activeConnIDLimit: 2,
+	}
+}
+
+// marshal encodes p. Parameters with default values are omitted.
+func (p *transportParameters) marshal() []byte {
+	var b []byte
+	def := defaultTransportParameters()
+	appendBytes := func(id uint64, v []byte) {
+		if v != nil {
+			b = appendVarint(b, id)
+			b = appendVarintBytes(b, v)
+		}
+	}
+	appendInt := func(id uint64, v, def int64) {
+		if v != def {
+			b = appendVarint(b, id)
+			b = appendVarint(b, uint64(sizeVarint(uint64(v))))
+			b = appendVarint(b, uint64(v))
+		}
+	}
+	appendBytes(paramOriginalDestinationConnectionID, p.originalDstConnID)
+	appendInt(paramMaxIdleTimeout, p.maxIdleTimeout.Milliseconds(), 0)
+	appendBytes(paramStatelessResetToken, p.statelessResetToken)
+	appendInt(paramMaxUDPPayloadSize, p.maxUDPPayloadSize, def.maxUDPPayloadSize)
+	appendInt(paramInitialMaxData, p.initialMaxData, 0)
+	appendInt(paramInitialMaxStreamDataBidiLocal, p.initialMaxStreamDataBidiLocal, 0)
+	appendInt(paramInitialMaxStreamDataBidiRemote, p.initialMaxStreamDataBidiRemote, 0)
+	appendInt(paramInitialMaxStreamDataUni, p.initialMaxStreamDataUni, 0)
+	appendInt(paramInitialMaxStreamsBidi, p.initialMaxStreamsBidi, 0)
+	appendInt(paramInitialMaxStreamsUni, p.initialMaxStreamsUni, 0)
+	appendInt(paramAckDelayExponent, int64(p.ackDelayExponent), int64(def.ackDelayExponent))
+	appendInt(paramMaxAckDelay, p.maxAckDelay.Milliseconds(), def.maxAckDelay.Milliseconds())
+	if p.disableActiveMigration {
+		b = appendVarint(b, paramDisableActiveMigration)
+		b = appendVarint(b, 0)
+	}
+	appendBytes(paramPreferredAddress, p.preferredAddress)
+	appendInt(paramActiveConnectionIDLimit, p.activeConnIDLimit, def.activeConnIDLimit)
+	appendBytes(paramInitialSourceConnectionID, p.initialSrcConnID)
+	appendBytes(paramRetrySourceConnectionID, p.retrySrcConnID)
+	return b
+}
+
+// unmarshalTransportParameters decodes and validates transport parameters
+// sent by the peer. Unknown parameters are ignored.
+func unmarshalTransportParameters(b []byte, fromServer bool) (transportParameters, error) {
+	p := defaultTransportParameters()
+	errParam := func(reason string) error {
+		return localTransportError{code: errTransportParameter, reason: reason}
+	}
+	seen := map[uint64]bool{}
+	for len(b) > 0 {
+		id, n := consumeVarint(b)
+		if n < 0 {
+			return p, errParam("malformed parameter")
+		}
+		b = b[n:]
+		val, n := consumeVarintBytes(b)
+		if n < 0 {
+			return p, errParam("malformed parameter")
+		}
+		b = b[n:]
+		if id <= paramRetrySourceConnectionID {
+			if seen[id] {
+				return p, errParam("duplicate parameter")
+			}
+			seen[id] = true
+		}
+		var v uint64
+		switch id {
+		case paramMaxIdleTimeout, paramMaxUDPPayloadSize, paramInitialMaxData,
+			paramInitialMaxStreamDataBidiLocal, paramInitialMaxStreamDataBidiRemote,
+			paramInitialMaxStreamDataUni, paramInitialMaxStreamsBidi,
+			paramInitialMaxStreamsUni, paramAckDelayExponent, paramMaxAckDelay,
+			paramActiveConnectionIDLimit:
+			var m int
+			if v, m = consumeVarint(val); m < 0 || m != len(val) {
+				return p, errParam("malformed integer parameter")
+			}
+		}
+		if !fromServer {
+			switch id {
+			case paramOriginalDestinationConnectionID, paramStatelessResetToken,
+				paramPreferredAddress, paramRetrySourceConnectionID:
+				return p, errParam("server-only parameter sent by client")
+			}
+		}
+		switch id {
+		case paramOriginalDestinationConnectionID:
+			p.originalDstConnID = bytes.Clone(val)
+		case paramMaxIdleTimeout:
+			p.maxIdleTimeout = time.Duration(min(v, 1<<32)) * time.Millisecond
+		case paramStatelessResetToken:
+			if len(val) != 16 {
+				return p, errParam("invalid stateless_reset_token")
+			}
+			p.statelessResetToken = bytes.Clone(val)
+		case paramMaxUDPPayloadSize:
+			if v < 1200 {
+				return p, errParam("max_udp_payload_size too small")
+			}
+			p.maxUDPPayloadSize = int64(v)
+		case paramInitialMaxData:
+			p.initialMaxData = int64(v)
+		case paramInitialMaxStreamDataBidiLocal:
+			p.initialMaxStreamDataBidiLocal = int64(v)
+		case paramInitialMaxStreamDataBidiRemote:
+			p.initialMaxStreamDataBidiRemote = int64(v)
+		case paramInitialMaxStreamDataUni:
+			p.initialMaxStreamDataUni = int64(v)
+		case paramInitialMaxStreamsBidi, paramInitialMaxStreamsUni:
+			if v > maxStreamsLimit {
+				return p, errParam("initial_max_streams too large")
+			}
+			if id == paramInitialMaxStreamsBidi {
+				p.initialMaxStreamsBidi = int64(v)
+			} else {
+				p.initialMaxStreamsUni = int64(v)
+			}
+		case paramAckDelayExponent:
+			if v > 20 {
+				return p, errParam("ack_delay_exponent too large")
+			}
+			p.ackDelayExponent = uint8(v)
+		case paramMaxAckDelay:
+			if v >= 1<<14 {
+				return p, errParam("max_ack_delay too large")
+			}
+			p.maxAckDelay = time.Duration(v) * time.Millisecond
+		case paramDisableActiveMigration:
+			if len(val) != 0 {
+				return p, errParam("malformed disable_active_migration")
+			}
+			p.disableActiveMigration = true
+		case paramPreferredAddress:
+			p.preferredAddress = bytes.Clone(val)
+		case paramActiveConnectionIDLimit:
+			if v < 2 {
+				return p, errParam("active_connection_id_limit too small")
+			}
+			p.activeConnIDLimit = int64(v)
+		case paramInitialSourceConnectionID:
+			p.initialSrcConnID = bytes.Clone(val)
+		case paramRetrySourceConnectionID:
+			p.retrySrcConnID = bytes.Clone(val)
+		}
+	}
+	if !seen[paramInitialSourceConnectionID] {
+		return p, errParam("missing initial_source_connection_id")
+	}
+	if fromServer && !seen[paramOriginalDestinationConnectionID] {
+		return p, errParam("missing original_destination_connection_id")
+	}
+	return p, nil
+}
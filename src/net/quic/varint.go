@@ -0,0 +1,129 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import "encoding/binary"
+
+// maxVarint is the largest value that can be encoded as a variable-length
+// integer (RFC 9000, Section 16).
+const maxVarint = 1<<62 - 1
+
+// consumeVarint parses a variable-length integer from the start of b,
+// returning the value and the number of bytes consumed.
+// It returns n < 0 if b does not begin with a complete integer.
+func consumeVarint(b []byte) (v uint64, n int) {
+	if len(b) == 0 {
+		return 0, -1
+	}
+	n = 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, -1
+	}
+	v = uint64(b[0] & 0x3f)
+	for i := 1; i < n; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, n
+}
+
+// consumeVarintInt64 is like consumeVarint, but returns an int64.
+func consumeVarintInt64(b []byte) (v int64, n int) {
+	u, n := consumeVarint(b)
+	return int64(u), n
+}
+
+// appendVarint appends the variable-length encoding of v to b.
+// v must be at most maxVarint.
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 1<<6-1:
+		return append(b, byte(v))
+	case v <= 1<<14-1:
+		return binary.BigEndian.AppendUint16(b, uint16(v)|0x40<<8)
+	case v <= 1<<30-1:
+		return binary.BigEndian.AppendUint32(b, uint32(v)|0x80<<24)
+	default:
+		return binary.BigEndian.AppendUint64(b, v|0xc0<<56)
+	}
+}
+
+// sizeVarint returns the size of the variable-length encoding of v.
+func sizeVarint(v uint64) int {
+	switch {
+	case v <= 1<<6-1:
+		return 1
+	case v <= 1<<14-1:
+		return 2
+	case v <= 1<<30-1:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// consumeVarintBytes parses a byte string preceded by its length as
+// a variable-length integer.
+func consumeVarintBytes(b []byte) (data []byte, n int) {
+	size, n := consumeVarint(b)
+	if n < 0 || size > uint64(len(b)-n) {
+		return nil, -1
+	}
+	return b[n:][:size], n + int(size)
+}
+
+// appendVarintBytes appends data preceded by its length as a
+// variable-length integer.
+func appendVarintBytes(b, data []byte) []byte {
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// consumeUint8Bytes parses a byte string preceded by a one-byte length.
+func consumeUint8Bytes(b []byte) (data []byte, n int) {
+	if len(b) < 1 || int(b[0]) > len(b)-1 {
+		return nil, -1
+	}
+	return b[1:][:b[0]], 1 + int(b[0])
+}
+
+// appendUint8Bytes appends data preceded by a one-byte length.
+func appendUint8Bytes(b, data []byte) []byte {
+	b = append(b, byte(len(data)))
+	return append(b, data...)
+}
+
+// decodePacketNumber returns the full packet number for a packet number
+// field of the given size and value, given the largest packet number
+// received so far in the space (RFC 9000, Appendix A.3).
+func decodePacketNumber(largest, truncated int64, size int) int64 {
+	expected := largest + 1
+	win := int64(1) << (size * 8)
+	hwin := win / 2
+	mask := win - 1
+	candidate := (expected &^ mask) | truncated
+	switch {
+	case candidate <= expected-hwin && candidate < 1<<62-win:
+		return candidate + win
+	case candidate > expected+hwin && candidate >= win:
+		return candidate - win
+	}
+	return candidate
+}
+
+// packetNumberLength returns the number of bytes needed to encode packet
+// number pn, given the largest acknowledged packet number in the space,
+// or -1 if none has been acknowledged (RFC 9000, Appendix A.2).
+func packetNumberLength(pn, largestAcked int64) int {
+	unacked := pn - largestAcked
+	switch {
+	case unacked < 1<<7:
+		return 1
+	case unacked < 1<<15:
+		return 2
+	case unacked < 1<<23:
+		return 3
+	}
+	return 4
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVarint(t *testing.T) {
+	// Examples from RFC 9000, Appendix A.1.
+	for _, test := range []struct {
+		b []byte
+		v uint64
+	}{
+		{[]byte{0xc2, 0x19, 0x7c, 0x5e, 0xff, 0x14, 0xe8, 0x8c}, 151288809941952652},
+		{[]byte{0x9d, 0x7f, 0x3e, 0x7d}, 494878333},
+		{[]byte{0x7b, 0xbd}, 15293},
+		{[]byte{0x25}, 37},
+	} {
+		v, n := consumeVarint(test.b)
+		if v != test.v || n != len(test.b) {
+			t.Errorf("consumeVarint(%x) = %v, %v; want %v, %v", test.b, v, n, test.v, len(test.b))
+		}
+		if got := appendVarint(nil, test.v); !bytes.Equal(got, test.b) {
+			t.Errorf("appendVarint(%v) = %x, want %x", test.v, got, test.b)
+		}
+		if got := sizeVarint(test.v); got != len(test.b) {
+			t.Errorf("sizeVarint(%v) = %v, want %v", test.v, got, len(test.b))
+		}
+		if _, n := consumeVarint(test.b[:len(test.b)-1]); n >= 0 {
+			t.Errorf("consumeVarint(%x) = _, %v; want error", test.b[:len(test.b)-1], n)
+		}
+	}
+}
+
+func TestDecodePacketNumber(t *testing.T) {
+	for _, test := range []struct {
+		largest   int64
+		truncated int64
+		size      int
+		want      int64
+	}{
+		// RFC 9000, Appendix A.3.
+		{0xa82f30ea, 0x9b32, 2, 0xa82f9b32},
+		{-1, 0, 1, 0},
+		{0xff, 0x01, 1, 0x101},
+		{0x100, 0xff, 1, 0xff},
+	} {
+		if got := decodePacketNumber(test.largest, test.truncated, test.size); got != test.want {
+			t.Errorf("decodePacketNumber(%#x, %#x, %v) = %#x, want %#x", test.largest, test.truncated, test.size, got, test.want)
+		}
+	}
+}
+
+func TestRangeset(t *testing.T) {
+	var s rangeset
+	s.add(10, 20)
+	s.add(0, 5)
+	s.add(5, 10)
+	s.add(30, 40)
+	if want := (rangeset{{0, 20}, {30, 40}}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("after adds: %v, want %v", s, want)
+	}
+	s.sub(15, 35)
+	if want := (rangeset{{0, 15}, {35, 40}}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("after sub: %v, want %v", s, want)
+	}
+	if !s.contains(0) || s.contains(15) || !s.contains(39) || s.contains(40) {
+		t.Errorf("contains gives wrong results for %v", s)
+	}
+	if got, want := s.min(), int64(0); got != want {
+		t.Errorf("min = %v, want %v", got, want)
+	}
+	if got, want := s.max(), int64(40); got != want {
+		t.Errorf("max = %v, want %v", got, want)
+	}
+	if got, want := s.size(), int64(20); got != want {
+		t.Errorf("size = %v, want %v", got, want)
+	}
+}
+
+func TestTransportParametersRoundTrip(t *testing.T) {
+	p := defaultTransportParameters()
+	p.originalDstConnID = []byte{1, 2, 3, 4}
+	p.initialSrcConnID = []byte{5, 6, 7, 8}
+	p.statelessResetToken = make([]byte, 16)
+	p.maxIdleTimeout = 30 * time.Second
+	p.initialMaxData = 1 << 20
+	p.initialMaxStreamDataBidiLocal = 1 << 16
+	p.initialMaxStreamDataBidiRemote = 1 << 17
+	p.initialMaxStreamDataUni = 1 << 18
+	p.initialMaxStreamsBidi = 100
+	p.initialMaxStreamsUni = 3
+	p.maxAckDelay = 10 * time.Millisecond
+	p.disableActiveMigration = true
+	got, err := unmarshalTransportParameters(p.marshal(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, p)
+	}
+
+	// Clients may not send server-only parameters.
+	if _, err := unmarshalTransportParameters(p.marshal(), false); err == nil {
+		t.Errorf("unmarshal server parameters from client: got nil error, want error")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package os
+
+import (
+	"errors"
+	"internal/filepathlite"
+	"runtime"
+	"slices"
+	"sync/atomic"
+	"syscall"
+)
+
+// root implementation for platforms with no openat.
+// Currently windows, plan9, js, and wasip1.
+type root struct {
+	name   string
+	closed atomic.Bool
+}
+
+// openRootNolog is OpenRoot.
+func openRootNolog(name string) (*Root, error) {
+	r, err := newRoot(name)
+	if err != nil {
+		return nil, &PathError{Op: "open", Path: name, Err: err}
+	}
+	return r, nil
+}
+
+// openRootInRoot is Root.OpenRoot.
+func openRootInRoot(r *Root, name string) (*Root, error) {
+	if err := checkPathEscapes(r, name); err != nil {
+		return nil, &PathError{Op: "openat", Path: name, Err: err}
+	}
+	r, err := newRoot(joinPath(r.root.name, name))
+	if err != nil {
+		return nil, &PathError{Op: "openat", Path: name, Err: err}
+	}
+	return r, nil
+}
+
+// newRoot returns a new Root.
+// If name is not a directory, it returns an error.
+func newRoot(name string) (*Root, error) {
+	fi, err := Stat(name)
+	if err != nil {
+		return nil, underlyingError(err)
+	}
+	if !fi.IsDir() {
+		return nil, errors.New("not a directory")
+	}
+	return &Root{&root{name: name}}, nil
+}
+
+func (r *root) Close() error {
+	// For consistency with platforms where Root.Close closes a handle,
+	// mark the Root as closed and return errors from future calls.
+	r.closed.Store(true)
+	return nil
+}
+
+func (r *root) Name() string {
+	return r.name
+}
+
+// rootOpenFileNolog is Root.OpenFile.
+func rootOpenFileNolog(r *Root, name string, flag int, perm FileMode) (*File, error) {
+	if err := checkPathEscapes(r, name); err != nil {
+		return nil, &PathError{Op: "openat", Path: name, Err: err}
+	}
+	f, err := openFileNolog(joinPath(r.root.name, name), flag, perm)
+	if err != nil {
+		return nil, &PathError{Op: "openat", Path: name, Err: underlyingError(err)}
+	}
+	return f, nil
+}
+
+func rootStat(r *Root, name string, lstat bool) (FileInfo, error) {
+	var fi FileInfo
+	var err error
+	if lstat {
+		err = checkPathEscapesLstat(r, name)
+		if err == nil {
+			fi, err = Lstat(joinPath(r.root.name, name))
+		}
+	} else {
+		err = checkPathEscapes(r, name)
+		if err == nil {
+			fi, err = Stat(joinPath(r.root.name, name))
+		}
+	}
+	if err != nil {
+		return nil, &PathError{Op: "statat", Path: name, Err: underlyingError(err)}
+	}
+	return fi, nil
+}
+
+func rootMkdir(r *Root, name string, perm FileMode) error {
+	if err := checkPathEscapes(r, name); err != nil {
+		return &PathError{Op: "mkdirat", Path: name, Err: err}
+	}
+	if err := Mkdir(joinPath(r.root.name, name), perm); err != nil {
+		return &PathError{Op: "mkdirat", Path: name, Err: underlyingError(err)}
+	}
+	return nil
+}
+
+func rootRemove(r *Root, name string) error {
+	if err := checkPathEscapesLstat(r, name); err != nil {
+		return &PathError{Op: "removeat", Path: name, Err: err}
+	}
+	if err := Remove(joinPath(r.root.name, name)); err != nil {
+		return &PathError{Op: "removeat", Path: name, Err: underlyingError(err)}
+	}
+	return nil
+}
+
+// checkPathEscapes reports whether name escapes the root.
+//
+// Due to the lack of openat, checkPathEscapes is subject to TOCTOU races
+// when symlinks change during the resolution process.
+func checkPathEscapes(r *Root, name string) error {
+	return checkPathEscapesInternal(r, name, false)
+}
+
+// checkPathEscapesLstat reports whether name escapes the root.
+// It does not resolve symlinks in the final path component.
+func checkPathEscapesLstat(r *Root, name string) error {
+	return checkPathEscapesInternal(r, name, true)
+}
+
+func checkPathEscapesInternal(r *Root, name string, lstat bool) error {
+	if r.root.closed.Load() {
+		return ErrClosed
+	}
+	parts, _, err := splitPathInRoot(name, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	symlinks := 0
+	base := r.root.name
+	for i < len(parts) {
+		if parts[i] == ".." {
+			// Resolve one or more parent ("..") path components.
+			end := i + 1
+			for end < len(parts) && parts[end] == ".." {
+				end++
+			}
+			count := end - i
+			if count > i {
+				return errPathEscapes
+			}
+			parts = slices.Delete(parts, i-count, end)
+			i -= count
+			base = r.root.name
+			for j := range i {
+				base = joinPath(base, parts[j])
+			}
+			continue
+		}
+
+		if runtime.GOOS == "windows" && parts[i] != "." && !filepathlite.IsLocal(parts[i]) {
+			// Reject reserved device names such as NUL and COM1.
+			return errPathEscapes
+		}
+
+		if lstat && i == len(parts)-1 {
+			break
+		}
+
+		next := joinPath(base, parts[i])
+		fi, err := Lstat(next)
+		if err != nil {
+			if IsNotExist(err) {
+				return nil
+			}
+			return underlyingError(err)
+		}
+		if fi.Mode()&ModeSymlink != 0 {
+			link, err := Readlink(next)
+			if err != nil {
+				return errPathEscapes
+			}
+			symlinks++
+			if symlinks > rootMaxSymlinks {
+				return errors.New("too many symlinks")
+			}
+			newparts, _, err := splitPathInRoot(link, parts[:i], parts[i+1:])
+			if err != nil {
+				return err
+			}
+			parts = newparts
+			continue
+		}
+		if !fi.IsDir() && i < len(parts)-1 {
+			return syscall.ENOTDIR
+		}
+
+		base = next
+		i++
+	}
+	return nil
+}
@@ -42,6 +42,7 @@ const (
 	lockRankRoot
 	lockRankItab
 	lockRankReflectOffs
+	lockRankSynctest
 	lockRankUserArenaState
 	// TRACEGLOBAL
 	lockRankTraceBuf
@@ -114,6 +115,7 @@ var lockNames = []string{
 	lockRankRoot:            "root",
 	lockRankItab:            "itab",
 	lockRankReflectOffs:     "reflectOffs",
+	lockRankSynctest:        "synctest",
 	lockRankUserArenaState:  "userArenaState",
 	lockRankTraceBuf:        "traceBuf",
 	lockRankTraceStrings:    "traceStrings",
@@ -193,6 +195,7 @@ var lockPartialOrder [][]lockRank = [][]lockRank{
 	lockRankRoot:            {},
 	lockRankItab:            {},
 	lockRankReflectOffs:     {lockRankItab},
+	lockRankSynctest:        {lockRankSysmon, lockRankScavenge, lockRankSweep, lockRankTestR, lockRankTimerSend, lockRankPollDesc, lockRankWakeableSleep, lockRankHchan, lockRankNotifyList, lockRankTimers, lockRankTimer, lockRankRoot, lockRankItab, lockRankReflectOffs},
 	lockRankUserArenaState:  {},
 	lockRankTraceBuf:        {lockRankSysmon, lockRankScavenge},
 	lockRankTraceStrings:    {lockRankSysmon, lockRankScavenge, lockRankTraceBuf},
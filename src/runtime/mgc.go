@@ -1269,6 +1269,12 @@ func gcBgMarkStartWorkers() {
 	//
 	// TODO(prattmic): cleanup gcStart to use a more explicit "in gcStart"
 	// check for bailing.
+	//
+	// The workers are never part of a synctest bubble, so neither
+	// is the ready channel nor this goroutine while it waits on it.
+	gp := getg()
+	sg := gp.syncGroup
+	gp.syncGroup = nil
 	mp := acquirem()
 	ready := make(chan struct{}, 1)
 	releasem(mp)
@@ -1291,6 +1297,7 @@ func gcBgMarkStartWorkers() {
 
 		gcBgMarkWorkerCount++
 	}
+	gp.syncGroup = sg
 }
 
 // gcBgMarkPrepare sets up state for background marking.
@@ -1725,7 +1732,13 @@ func boring_registerCache(p unsafe.Pointer) {
 //go:linkname unique_runtime_registerUniqueMapCleanup unique.runtime_registerUniqueMapCleanup
 func unique_runtime_registerUniqueMapCleanup(f func()) {
 	// Start the goroutine in the runtime so it's counted as a system goroutine.
+	// The channel is shared with that goroutine, so it must not be
+	// associated with any synctest bubble the caller is in.
+	gp := getg()
+	sg := gp.syncGroup
+	gp.syncGroup = nil
 	uniqueMapCleanup = make(chan struct{}, 1)
+	gp.syncGroup = sg
 	go func(cleanup func()) {
 		for {
 			<-uniqueMapCleanup
@@ -1858,7 +1871,7 @@ func gcTestIsReachable(ptrs ...unsafe.Pointer) (mask uint64) {
 		s := (*specialReachable)(mheap_.specialReachableAlloc.alloc())
 		unlock(&mheap_.speciallock)
 		s.special.kind = _KindSpecialReachable
-		if !addspecial(p, &s.special) {
+		if !addspecial(p, &s.special, false) {
 			throw("already have a reachable special (duplicate pointer?)")
 		}
 		specials[i] = s
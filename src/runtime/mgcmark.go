@@ -180,6 +180,10 @@ func markroot(gcw *gcWork, i uint32, flushBgCredit bool) int64 {
 			cnt := uintptr(atomic.Load(&fb.cnt))
 			scanblock(uintptr(unsafe.Pointer(&fb.fin[0])), cnt*unsafe.Sizeof(fb.fin[0]), &finptrmask[0], gcw, nil)
 		}
+		for cb := allcleanup; cb != nil; cb = cb.alllink {
+			cnt := uintptr(atomic.Load(&cb.cnt))
+			scanblock(uintptr(unsafe.Pointer(&cb.fn[0])), cnt*goarch.PtrSize, &cleanupptrmask[0], gcw, nil)
+		}
 
 	case i == fixedRootFreeGStacks:
 		// Switch to the system stack so we can call
@@ -401,6 +405,12 @@ func markrootSpans(gcw *gcWork, shard int) {
 					// The special itself is a root.
 					spw := (*specialWeakHandle)(unsafe.Pointer(sp))
 					scanblock(uintptr(unsafe.Pointer(&spw.handle)), goarch.PtrSize, &oneptrmask[0], gcw, nil)
+				case _KindSpecialCleanup:
+					// The cleanup function, and everything it references,
+					// is a root. Unlike a finalizer, the object itself is
+					// neither marked nor scanned.
+					spc := (*specialCleanup)(unsafe.Pointer(sp))
+					scanblock(uintptr(unsafe.Pointer(&spc.fn)), goarch.PtrSize, &oneptrmask[0], gcw, nil)
 				}
 			}
 			unlock(&s.speciallock)
@@ -1386,12 +1386,15 @@ func goroutineProfileWithLabelsConcurrent(p []profilerecord.StackRecord, labels
 	// alive and permanently marked as "system". But to make this count agree
 	// with what we'd get from isSystemGoroutine, we need special handling for
 	// goroutines that can vary between user and system to ensure that the count
-	// doesn't change during the collection. So, check the finalizer goroutine
-	// in particular.
+	// doesn't change during the collection. So, check the finalizer and
+	// cleanup goroutines in particular.
 	n = int(gcount())
 	if fingStatus.Load()&fingRunningFinalizer != 0 {
 		n++
 	}
+	if cleanupStatus.Load()&cleanupRunning != 0 {
+		n++
+	}
 
 	if n > len(p) {
 		// There's not enough space in p to store the whole profile, so (per the
@@ -1431,6 +1434,13 @@ func goroutineProfileWithLabelsConcurrent(p []profilerecord.StackRecord, labels
 			doRecordGoroutineProfile(fing, pcbuf)
 		}
 	}
+	// Likewise for the cleanup goroutine.
+	if cleanupg != nil {
+		cleanupg.goroutineProfiled.Store(goroutineProfileSatisfied)
+		if readgstatus(cleanupg) != _Gdead && !isSystemGoroutine(cleanupg, false) {
+			doRecordGoroutineProfile(cleanupg, pcbuf)
+		}
+	}
 	startTheWorld(stw)
 
 	// Visit each goroutine that existed as of the startTheWorld call above.
@@ -29,6 +29,7 @@ type timer struct {
 	astate atomic.Uint8 // atomic copy of state bits at last unlock
 	state  uint8        // state bits
 	isChan bool         // timer has a channel; immutable; can be read without lock
+	isFake bool         // timer is using fake time; immutable; can be read without lock
 
 	// isSending is used to handle races between running a
 	// channel timer and stopping or resetting the timer.
@@ -140,6 +141,10 @@ type timers struct {
 	// heap[i].when over timers with the timerModified bit set.
 	// If minWhenModified = 0, it means there are no timerModified timers in the heap.
 	minWhenModified atomic.Int64
+
+	// syncGroup is the synctest group that owns these timers,
+	// or nil if these are the timers of a P.
+	syncGroup *synctestGroup
 }
 
 type timerWhen struct {
@@ -292,6 +297,30 @@ const verifyTimers = false
 
 // time.now is implemented in assembly.
 
+// time_runtimeNow returns the current time.
+// Within a synctest bubble, it returns the group's fake clock.
+//
+//go:linkname time_runtimeNow time.runtimeNow
+func time_runtimeNow() (sec int64, nsec int32, mono int64) {
+	if sg := getg().syncGroup; sg != nil {
+		sec = sg.now / (1000 * 1000 * 1000)
+		nsec = int32(sg.now % (1000 * 1000 * 1000))
+		return sec, nsec, sg.now
+	}
+	return time_now()
+}
+
+// time_runtimeNano returns the current value of the runtime clock.
+// Within a synctest bubble, it returns the group's fake clock.
+//
+//go:linkname time_runtimeNano time.runtimeNano
+func time_runtimeNano() int64 {
+	if sg := getg().syncGroup; sg != nil {
+		return sg.now
+	}
+	return nanotime()
+}
+
 // timeSleep puts the current goroutine to sleep for at least ns nanoseconds.
 //
 //go:linkname timeSleep time.Sleep
@@ -302,17 +331,39 @@ func timeSleep(ns int64) {
 
 	gp := getg()
 	t := gp.timer
+	if t != nil && t.isFake != (gp.syncGroup != nil) {
+		// The goroutine has moved into or out of a synctest bubble
+		// since its timer was allocated.
+		t = nil
+	}
 	if t == nil {
 		t = new(timer)
 		t.init(goroutineReady, gp)
+		if gp.syncGroup != nil {
+			t.isFake = true
+		}
 		gp.timer = t
 	}
-	when := nanotime() + ns
+	var now int64
+	if sg := gp.syncGroup; sg != nil {
+		now = sg.now
+	} else {
+		now = nanotime()
+	}
+	when := now + ns
 	if when < 0 { // check for overflow.
 		when = maxWhen
 	}
 	gp.sleepWhen = when
-	gopark(resetForSleep, nil, waitReasonSleep, traceBlockSleep, 1)
+	if t.isFake {
+		// Call timer.reset in this goroutine, since it's the one in a syncGroup.
+		// We don't need to worry about the timer function running before the goroutine
+		// is parked, because time won't advance until we park.
+		resetForSleep(gp, nil)
+		gopark(nil, nil, waitReasonSleep, traceBlockSleep, 1)
+	} else {
+		gopark(resetForSleep, nil, waitReasonSleep, traceBlockSleep, 1)
+	}
 }
 
 // resetForSleep is called after the goroutine is parked for timeSleep.
@@ -352,6 +403,9 @@ func newTimer(when, period int64, f func(arg any, seq uintptr, delay int64), arg
 			throw("invalid timer channel: no capacity")
 		}
 	}
+	if getg().syncGroup != nil {
+		t.isFake = true
+	}
 	t.modify(when, period, f, arg, 0)
 	t.init = true
 	return t
@@ -362,6 +416,9 @@ func newTimer(when, period int64, f func(arg any, seq uintptr, delay int64), arg
 //
 //go:linkname stopTimer time.stopTimer
 func stopTimer(t *timeTimer) bool {
+	if t.isFake && getg().syncGroup == nil {
+		panic("stop of synctest timer from outside bubble")
+	}
 	return t.stop()
 }
 
@@ -371,6 +428,9 @@ func stopTimer(t *timeTimer) bool {
 //
 //go:linkname resetTimer time.resetTimer
 func resetTimer(t *timeTimer, when, period int64) bool {
+	if t.isFake && getg().syncGroup == nil {
+		panic("reset of synctest timer from outside bubble")
+	}
 	if raceenabled {
 		racerelease(unsafe.Pointer(&t.timer))
 	}
@@ -585,7 +645,7 @@ func (t *timer) modify(when, period int64, f func(arg any, seq uintptr, delay in
 	if add {
 		t.maybeAdd()
 	}
-	if wake {
+	if wake && !t.isFake {
 		wakeNetPoller(when)
 	}
 
@@ -596,7 +656,7 @@ func (t *timer) modify(when, period int64, f func(arg any, seq uintptr, delay in
 // t must be locked.
 func (t *timer) needsAdd() bool {
 	assertLockHeld(&t.mu)
-	need := t.state&timerHeaped == 0 && t.when > 0 && (!t.isChan || t.blocked > 0)
+	need := t.state&timerHeaped == 0 && t.when > 0 && (!t.isChan || t.isFake || t.blocked > 0)
 	if need {
 		t.trace("needsAdd+")
 	} else {
@@ -633,8 +693,20 @@ func (t *timer) maybeAdd() {
 	// go unnoticed until long after t.when.
 	// Calling acquirem instead of using getg().m makes sure that
 	// we end up locking and inserting into the current P's timers.
+	//
+	// Timers using a synctest group's fake clock are added to the
+	// group's timers instead, which are run by synctest.Run.
 	mp := acquirem()
-	ts := &mp.p.ptr().timers
+	var ts *timers
+	if t.isFake {
+		sg := getg().syncGroup
+		if sg == nil {
+			throw("invalid timer: fake time but no syncgroup")
+		}
+		ts = &sg.timers
+	} else {
+		ts = &mp.p.ptr().timers
+	}
 	ts.lock()
 	ts.cleanHead()
 	t.lock()
@@ -651,7 +723,7 @@ func (t *timer) maybeAdd() {
 	t.unlock()
 	ts.unlock()
 	releasem(mp)
-	if wake {
+	if wake && !t.isFake {
 		wakeNetPoller(when)
 	}
 }
@@ -1094,6 +1166,16 @@ func (t *timer) unlockAndRun(now int64) {
 		ts.unlock()
 	}
 
+	if ts != nil && ts.syncGroup != nil {
+		// Temporarily use the timer's synctest group for the G running this timer.
+		gp := getg()
+		if gp.syncGroup != nil {
+			throw("unexpected syncgroup set")
+		}
+		gp.syncGroup = ts.syncGroup
+		ts.syncGroup.changegstatus(gp, _Gdead, _Grunning)
+	}
+
 	if !async && t.isChan {
 		// For a timer channel, we want to make sure that no stale sends
 		// happen after a t.stop or t.modify, but we cannot hold t.mu
@@ -1130,6 +1212,12 @@ func (t *timer) unlockAndRun(now int64) {
 		unlock(&t.sendLock)
 	}
 
+	if ts != nil && ts.syncGroup != nil {
+		gp := getg()
+		ts.syncGroup.changegstatus(gp, _Grunning, _Gdead)
+		gp.syncGroup = nil
+	}
+
 	if ts != nil {
 		ts.lock()
 	}
@@ -1315,6 +1403,24 @@ func badTimer() {
 // to send a value to its associated channel. If so, it does.
 // The timer must not be locked.
 func (t *timer) maybeRunChan() {
+	if t.isFake {
+		t.lock()
+		var timerGroup *synctestGroup
+		if t.ts != nil {
+			timerGroup = t.ts.syncGroup
+		}
+		t.unlock()
+		sg := getg().syncGroup
+		if sg == nil {
+			panic(plainError("synctest timer accessed from outside bubble"))
+		}
+		if timerGroup != nil && sg != timerGroup {
+			panic(plainError("timer moved between synctest bubbles"))
+		}
+		// No need to do anything here.
+		// synctest.Run will run the timer when it advances its fake clock.
+		return
+	}
 	if t.astate.Load()&timerHeaped != 0 {
 		// If the timer is in the heap, the ordinary timer code
 		// is in charge of sending when appropriate.
@@ -1384,7 +1490,7 @@ func unblockTimerChan(c *hchan) {
 		badTimer()
 	}
 	t.blocked--
-	if t.blocked == 0 && t.state&timerHeaped != 0 && t.state&timerZombie == 0 {
+	if t.blocked == 0 && t.state&timerHeaped != 0 && t.state&timerZombie == 0 && !t.isFake {
 		// Last goroutine that was blocked on this timer.
 		// Mark for removal from heap but do not clear t.when,
 		// so that we know what time it is still meant to trigger.
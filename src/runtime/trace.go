@@ -952,8 +952,14 @@ type traceAdvancerState struct {
 // start starts a new traceAdvancer.
 func (s *traceAdvancerState) start() {
 	// Start a goroutine to periodically advance the trace generation.
+	// It is a system goroutine, so the state it shares with the caller
+	// must not be associated with any synctest bubble the caller is in.
+	gp := getg()
+	sg := gp.syncGroup
+	gp.syncGroup = nil
 	s.done = make(chan struct{})
 	s.timer = newWakeableSleep()
+	gp.syncGroup = sg
 	go func() {
 		for traceEnabled() {
 			// Set a timer to wake us up
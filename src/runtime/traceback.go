@@ -1329,11 +1329,12 @@ func tracebackHexdump(stk stack, frame *stkframe, bad uintptr) {
 // isSystemGoroutine reports whether the goroutine g must be omitted
 // in stack dumps and deadlock detector. This is any goroutine that
 // starts at a runtime.* entry point, except for runtime.main,
-// runtime.handleAsyncEvent (wasm only) and sometimes runtime.runfinq.
+// runtime.handleAsyncEvent (wasm only) and sometimes runtime.runfinq
+// and runtime.runCleanups.
 //
 // If fixed is true, any goroutine that can vary between user and
-// system (that is, the finalizer goroutine) is considered a user
-// goroutine.
+// system (that is, the finalizer and cleanup goroutines) is considered
+// a user goroutine.
 func isSystemGoroutine(gp *g, fixed bool) bool {
 	// Keep this in sync with internal/trace.IsSystemGoroutine.
 	f := findfunc(gp.startpc)
@@ -1353,6 +1354,13 @@ func isSystemGoroutine(gp *g, fixed bool) bool {
 		}
 		return fingStatus.Load()&fingRunningFinalizer == 0
 	}
+	if f.funcID == abi.FuncID_runCleanups {
+		// Likewise for the cleanup goroutine.
+		if fixed {
+			return false
+		}
+		return cleanupStatus.Load()&cleanupRunning == 0
+	}
 	return stringslite.HasPrefix(funcname(f), "runtime.")
 }
 
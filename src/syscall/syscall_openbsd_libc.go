@@ -83,3 +83,5 @@ func syscall9X(fn, a1, a2, a3, a4, a5, a6, a7, a8, a9 uintptr) (r1, r2 uintptr,
 //sys   fstatat(fd int, path string, stat *Stat_t, flags int) (err error)
 //sys   unlinkat(fd int, path string, flags int) (err error)
 //sys   openat(fd int, path string, flags int, perm uint32) (fdret int, err error)
+//sys   mkdirat(fd int, path string, mode uint32) (err error)
+//sys   readlinkat(fd int, path string, buf []byte) (n int, err error)